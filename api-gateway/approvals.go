@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/approvals"
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/middleware"
+	"microservices-platform/pkg/proxy"
+)
+
+// approvalsAPI serves the queue of admin requests held for a second admin's
+// approval, and runs them through the gateway's routes once approved
+type approvalsAPI struct {
+	store     approvals.Store
+	router    http.Handler
+	jwtSecret string
+	cfg       config.ApprovalConfig
+}
+
+// setupApprovals connects the approval queue to Redis. If Redis is
+// unavailable, requests that need approval are refused.
+func setupApprovals(cfg *Config, router http.Handler) *approvalsAPI {
+	api := &approvalsAPI{router: router, jwtSecret: cfg.JWTSecret, cfg: cfg.Approvals}
+
+	store, err := approvals.NewRedisStore(cfg.Redis, cfg.Approvals)
+	if err != nil {
+		log.Printf("Redis unavailable, requests that need approval are refused: %v", err)
+		return api
+	}
+	api.store = store
+
+	return api
+}
+
+// require holds the admin requests that rule matches for approval
+func (a *approvalsAPI) require(name string, rule middleware.ApprovalRule) gin.HandlerFunc {
+	return middleware.RequireApproval(a.jwtSecret, a.store, a.cfg.TTL, name, rule)
+}
+
+// refundOverThreshold matches refunds of more than the threshold. Full
+// refunds, which name no amount, match too, since the gateway does not know
+// the payment's amount.
+func (a *approvalsAPI) refundOverThreshold() middleware.ApprovalRule {
+	return func(c *gin.Context, adminID string, body []byte) bool {
+		var req struct {
+			Amount float64 `json:"amount"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			// Let the payment service reject the malformed request
+			return false
+		}
+		return req.Amount <= 0 || req.Amount > a.cfg.RefundThreshold
+	}
+}
+
+// bulkDelete matches batches with at least the threshold of deletes
+func (a *approvalsAPI) bulkDelete() middleware.ApprovalRule {
+	return func(c *gin.Context, adminID string, body []byte) bool {
+		var req batchRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return false
+		}
+		deletes := 0
+		for _, item := range req.Requests {
+			if strings.EqualFold(item.Method, http.MethodDelete) {
+				deletes++
+			}
+		}
+		return a.cfg.BulkDeleteThreshold > 0 && deletes >= a.cfg.BulkDeleteThreshold
+	}
+}
+
+// otherUserDeletion matches an admin deleting someone else's account
+func otherUserDeletion() middleware.ApprovalRule {
+	return func(c *gin.Context, adminID string, body []byte) bool {
+		return c.Param("id") != adminID
+	}
+}
+
+// available writes an error response if the queue is unavailable
+func (a *approvalsAPI) available(c *gin.Context) bool {
+	if a.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Approval queue is unavailable"})
+		return false
+	}
+	return true
+}
+
+// listHandler lists actions within retention, newest first, optionally
+// filtered by status, e.g. ?status=pending
+func (a *approvalsAPI) listHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.available(c) {
+			return
+		}
+
+		actions, err := a.store.List(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Approval queue is unavailable"})
+			return
+		}
+
+		now := time.Now()
+		status := c.Query("status")
+		filtered := make([]*approvals.Action, 0, len(actions))
+		for _, action := range actions {
+			action.Expire(now)
+			if status == "" || action.Status == status {
+				filtered = append(filtered, action)
+			}
+		}
+		proxy.WriteList(c, filtered, len(filtered))
+	}
+}
+
+// getHandler returns an action
+func (a *approvalsAPI) getHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.available(c) {
+			return
+		}
+
+		action, err := a.store.Get(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Approval queue is unavailable"})
+			return
+		}
+		if action == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Action not found"})
+			return
+		}
+		action.Expire(time.Now())
+		c.JSON(http.StatusOK, gin.H{"action": action})
+	}
+}
+
+// approveHandler approves a pending action and runs its request with the
+// approving admin's credentials. The admin who requested the action cannot
+// approve it.
+func (a *approvalsAPI) approveHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.available(c) {
+			return
+		}
+
+		adminID := c.GetString("user_id")
+		ctx := c.Request.Context()
+		action, err := a.store.Update(ctx, c.Param("id"), func(action *approvals.Action) error {
+			if action.RequestedBy == adminID {
+				return errSelfApproval
+			}
+			return action.Decide(approvals.StatusApproved, adminID, "")
+		})
+		if !a.decided(c, err) {
+			return
+		}
+
+		log.Printf("AUDIT approval granted: action=%s rule=%s requested_by=%s approved_by=%s method=%s path=%s",
+			action.ID, action.Rule, action.RequestedBy, adminID, action.Method, action.Path)
+
+		req, err := http.NewRequestWithContext(approvals.WithApproval(ctx, action.ID), action.Method, action.Path, bytes.NewReader(action.Body))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid action request"})
+			return
+		}
+		req.RemoteAddr = c.Request.RemoteAddr
+		req.Host = c.Request.Host
+		for _, name := range batchSharedHeaders {
+			if value := c.GetHeader(name); value != "" {
+				req.Header.Set(name, value)
+			}
+		}
+		if action.ContentType != "" {
+			req.Header.Set("Content-Type", action.ContentType)
+		}
+
+		w := &batchResponseWriter{header: make(http.Header)}
+		a.router.ServeHTTP(w, req)
+
+		result := bytes.TrimSpace(w.body.Bytes())
+		if len(result) > 0 && !json.Valid(result) {
+			result, _ = json.Marshal(string(result))
+		}
+		completed, err := a.store.Update(ctx, action.ID, func(current *approvals.Action) error {
+			current.Complete(w.statusCode(), result)
+			return nil
+		})
+		if err != nil {
+			// The request ran; report its outcome even if it can't be recorded
+			log.Printf("Failed to record outcome of action %s: %v", action.ID, err)
+			action.Complete(w.statusCode(), result)
+			completed = action
+		}
+
+		log.Printf("AUDIT approved action completed: action=%s status=%s result_status=%d", completed.ID, completed.Status, completed.ResultStatus)
+		c.JSON(http.StatusOK, gin.H{"action": completed})
+	}
+}
+
+// rejectRequest gives the reason an action is rejected
+type rejectRequest struct {
+	Reason string `json:"reason"`
+}
+
+// rejectHandler rejects a pending action. The admin who requested it may
+// reject it to withdraw it.
+func (a *approvalsAPI) rejectHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.available(c) {
+			return
+		}
+
+		var req rejectRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+				return
+			}
+		}
+
+		adminID := c.GetString("user_id")
+		action, err := a.store.Update(c.Request.Context(), c.Param("id"), func(action *approvals.Action) error {
+			return action.Decide(approvals.StatusRejected, adminID, req.Reason)
+		})
+		if !a.decided(c, err) {
+			return
+		}
+
+		log.Printf("AUDIT approval rejected: action=%s rule=%s requested_by=%s rejected_by=%s reason=%q",
+			action.ID, action.Rule, action.RequestedBy, adminID, req.Reason)
+		c.JSON(http.StatusOK, gin.H{"action": action})
+	}
+}
+
+// errSelfApproval is returned when an admin tries to approve their own action
+var errSelfApproval = errors.New("actions must be approved by a different admin")
+
+// decided writes an error response if deciding an action failed
+func (a *approvalsAPI) decided(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, approvals.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Action not found"})
+	case errors.Is(err, approvals.ErrNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "Action is no longer pending"})
+	case errors.Is(err, errSelfApproval):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Actions must be approved by a different admin"})
+	default:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Approval queue is unavailable"})
+	}
+	return false
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/config"
+)
+
+// apiPrefix is the root of the versioned API that batch paths are relative to
+const apiPrefix = "/api/v1"
+
+// batchSharedHeaders are copied from the batch request to every sub-request,
+// so they share the caller's credentials and context
+var batchSharedHeaders = []string{
+	"Authorization",
+	"Accept-Language",
+	"User-Agent",
+	"Traceparent",
+	"Tracestate",
+	"Baggage",
+}
+
+// batchItemHeaders are the headers a sub-request may set for itself
+var batchItemHeaders = map[string]bool{
+	"Content-Type":        true,
+	"Idempotency-Key":     true,
+	"If-Match":            true,
+	"If-None-Match":       true,
+	"If-Modified-Since":   true,
+	"If-Unmodified-Since": true,
+}
+
+// batchResultHeaders are the sub-response headers returned with its result
+var batchResultHeaders = []string{
+	"Content-Type",
+	"ETag",
+	"Location",
+	"Retry-After",
+	"X-Operation-ID",
+	"X-Request-ID",
+}
+
+// batchMethods are the methods a sub-request may use
+var batchMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// batchRequest is a batch of API requests sent in one round trip
+type batchRequest struct {
+	Requests []batchItem `json:"requests"`
+}
+
+// batchItem is one request of a batch. Path is relative to /api/v1, e.g.
+// "/users/123?fields=email", and ID is echoed in its result.
+type batchItem struct {
+	ID      string            `json:"id"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// batchResult is the response to one request of a batch. A body that is not
+// JSON is returned as a JSON string.
+type batchResult struct {
+	ID      string            `json:"id,omitempty"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// batchHandler runs the sub-requests of a batch through the gateway's own
+// routes, so each is authenticated, rate limited and proxied like a separate
+// request. Up to MaxConcurrency run at once; results keep the request order.
+func batchHandler(router http.Handler, cfg config.GatewayBatchConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req batchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch request", "message": err.Error()})
+			return
+		}
+		if len(req.Requests) == 0 || len(req.Requests) > cfg.MaxRequests {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A batch must contain between 1 and %d requests", cfg.MaxRequests)})
+			return
+		}
+		for i := range req.Requests {
+			if err := normalizeBatchItem(&req.Requests[i]); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch request", "message": fmt.Sprintf("requests[%d]: %v", i, err)})
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.Timeout)
+		defer cancel()
+
+		concurrency := cfg.MaxConcurrency
+		if concurrency < 1 {
+			concurrency = 1
+		}
+		slots := make(chan struct{}, concurrency)
+
+		results := make([]batchResult, len(req.Requests))
+		var wg sync.WaitGroup
+		for i, item := range req.Requests {
+			wg.Add(1)
+			go func(i int, item batchItem) {
+				defer wg.Done()
+				select {
+				case slots <- struct{}{}:
+					defer func() { <-slots }()
+					results[i] = runBatchItem(ctx, router, c.Request, item)
+				case <-ctx.Done():
+					results[i] = batchError(item, http.StatusGatewayTimeout, "Batch timed out before the request started")
+				}
+			}(i, item)
+		}
+		wg.Wait()
+
+		c.JSON(http.StatusOK, gin.H{"responses": results})
+	}
+}
+
+// normalizeBatchItem validates a sub-request and resolves its path
+func normalizeBatchItem(item *batchItem) error {
+	item.Method = strings.ToUpper(item.Method)
+	if !batchMethods[item.Method] {
+		return fmt.Errorf("unsupported method %q", item.Method)
+	}
+
+	if !strings.HasPrefix(item.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	if !strings.HasPrefix(item.Path, apiPrefix+"/") {
+		item.Path = apiPrefix + item.Path
+	}
+	route := strings.SplitN(item.Path, "?", 2)[0]
+	if route == apiPrefix+"/batch" {
+		return fmt.Errorf("batches cannot be nested")
+	}
+	if strings.HasPrefix(route, apiPrefix+"/operations/") && strings.HasSuffix(route, "/events") {
+		return fmt.Errorf("event streams cannot be batched")
+	}
+
+	for name := range item.Headers {
+		if !batchItemHeaders[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("header %q cannot be set per request", name)
+		}
+	}
+	return nil
+}
+
+// runBatchItem serves one sub-request and captures its response
+func runBatchItem(ctx context.Context, router http.Handler, parent *http.Request, item batchItem) batchResult {
+	req, err := http.NewRequestWithContext(ctx, item.Method, item.Path, bytes.NewReader(item.Body))
+	if err != nil {
+		return batchError(item, http.StatusBadRequest, "Invalid request path")
+	}
+	req.RemoteAddr = parent.RemoteAddr
+	req.Host = parent.Host
+	for _, name := range batchSharedHeaders {
+		if value := parent.Header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	for name, value := range item.Headers {
+		req.Header.Set(name, value)
+	}
+	if len(item.Body) > 0 && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	w := &batchResponseWriter{header: make(http.Header)}
+	router.ServeHTTP(w, req)
+
+	result := batchResult{ID: item.ID, Status: w.statusCode()}
+	for _, name := range batchResultHeaders {
+		if value := w.header.Get(name); value != "" {
+			if result.Headers == nil {
+				result.Headers = make(map[string]string)
+			}
+			result.Headers[name] = value
+		}
+	}
+	if body := bytes.TrimSpace(w.body.Bytes()); len(body) > 0 {
+		if json.Valid(body) {
+			result.Body = body
+		} else {
+			result.Body, _ = json.Marshal(string(body))
+		}
+	}
+	return result
+}
+
+// batchError is the result of a sub-request the gateway did not serve
+func batchError(item batchItem, status int, message string) batchResult {
+	body, _ := json.Marshal(gin.H{"error": message})
+	return batchResult{ID: item.ID, Status: status, Body: body}
+}
+
+// batchResponseWriter buffers a sub-request's response
+type batchResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *batchResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *batchResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *batchResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// Flush is a no-op; the response is returned once complete
+func (w *batchResponseWriter) Flush() {}
+
+func (w *batchResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/cdn"
+	"microservices-platform/pkg/events"
+)
+
+// cdnAPI purges cached catalog responses from the CDN, on request and when
+// products change
+type cdnAPI struct {
+	purger cdn.Purger
+}
+
+// setupCDN creates the purger of the configured CDN and purges products
+// from it as their events arrive on bus. Without a purge API, or without
+// Redis, cached responses only expire.
+func setupCDN(cfg *Config, bus events.EventBus) *cdnAPI {
+	api := &cdnAPI{}
+	purger := cdn.NewPurger(cfg.CDN)
+	if purger == nil {
+		log.Println("No CDN purge API configured, cached catalog responses only expire")
+		return api
+	}
+	api.purger = purger
+
+	if bus == nil {
+		log.Println("Redis unavailable, products are not purged from the CDN as they change")
+		return api
+	}
+	for _, eventType := range []events.EventType{events.ProductCreated, events.ProductUpdated, events.ProductInventoryChanged} {
+		if err := bus.Subscribe(eventType, cdn.HandleProductEvent(purger)); err != nil {
+			log.Printf("Failed to subscribe to %s, products are not purged from the CDN as they change: %v", eventType, err)
+			return api
+		}
+	}
+	return api
+}
+
+// purgeHandler purges the responses carrying the given surrogate keys, e.g.
+// "product-<id>" or "product-list"
+func (a *cdnAPI) purgeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.purger == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "CDN purging is not configured"})
+			return
+		}
+
+		var req struct {
+			SurrogateKeys []string `json:"surrogate_keys" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		var keys []string
+		for _, key := range req.SurrogateKeys {
+			// Keys are separated by spaces in the Surrogate-Key header
+			if key = strings.TrimSpace(key); key != "" && !strings.ContainsAny(key, " \t") {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No valid surrogate keys"})
+			return
+		}
+
+		if err := a.purger.Purge(c.Request.Context(), cdn.PurgeReasonAPI, keys...); err != nil {
+			log.Printf("CDN purge of %v failed: %v", keys, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "CDN purge failed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"purged": keys})
+	}
+}
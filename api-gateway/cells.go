@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/cells"
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/proxy"
+)
+
+// cellsAPI serves the tenant placement table to admins, who move tenants
+// between cells with it
+type cellsAPI struct {
+	store cells.Store
+	table *cells.Table
+	cells map[string]bool
+	cfg   config.CellConfig
+}
+
+// setupCells connects the placement table to Redis and routes tenants to
+// their cells. Without GATEWAY_CELLS, or if Redis is unavailable, tenants
+// are not placed in cells.
+func setupCells(cfg *Config, gateway *proxy.Gateway) *cellsAPI {
+	api := &cellsAPI{cfg: cfg.Cells, cells: map[string]bool{cells.DefaultCell: true}}
+	for _, cell := range cfg.Cells.Cells {
+		if cell = strings.TrimSpace(cell); cell != "" {
+			api.cells[cell] = true
+		}
+	}
+	if len(api.cells) == 1 {
+		return api
+	}
+
+	store, err := cells.NewRedisStore(cfg.Redis)
+	if err != nil {
+		log.Printf("Redis unavailable, tenants are not placed in cells: %v", err)
+		return api
+	}
+	api.store = store
+	api.table = cells.NewTable(store, cfg.Cells.RefreshInterval)
+	if cfg.Cells.DrainPeriod < cfg.Cells.RefreshInterval {
+		log.Printf("WARNING: GATEWAY_CELL_DRAIN_PERIOD %s is shorter than the placement refresh interval %s, writes may reach the old cell after a move",
+			cfg.Cells.DrainPeriod, cfg.Cells.RefreshInterval)
+	}
+
+	go api.table.Start(context.Background())
+	gateway.PlaceTenantsInCells(api.table)
+	return api
+}
+
+// available reports whether cells are in use, answering the request if not
+func (a *cellsAPI) available(c *gin.Context) bool {
+	if a.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Cell placement is unavailable"})
+		return false
+	}
+	return true
+}
+
+// listHandler returns the cells and the tenants placed outside the default
+// cell
+func (a *cellsAPI) listHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.available(c) {
+			return
+		}
+
+		placements, err := a.store.List(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Cell placement is unavailable"})
+			return
+		}
+		sort.Slice(placements, func(i, j int) bool { return placements[i].Tenant < placements[j].Tenant })
+
+		names := make([]string, 0, len(a.cells))
+		for cell := range a.cells {
+			names = append(names, cell)
+		}
+		sort.Strings(names)
+		c.JSON(http.StatusOK, gin.H{"cells": names, "placements": placements})
+	}
+}
+
+// getHandler returns a tenant's placement
+func (a *cellsAPI) getHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.available(c) {
+			return
+		}
+
+		placement, err := a.store.Get(c.Request.Context(), c.Param("tenant"))
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Cell placement is unavailable"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"placement": placement})
+	}
+}
+
+// moveRequest names the cell to move a tenant to
+type moveRequest struct {
+	Cell string `json:"cell" binding:"required"`
+}
+
+// moveHandler starts moving a tenant to another cell. The tenant drains for
+// the drain period: its writes are refused while its data is copied, and its
+// reads are still served by its current cell.
+func (a *cellsAPI) moveHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.available(c) {
+			return
+		}
+
+		var req moveRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cell is required"})
+			return
+		}
+		if !a.cells[req.Cell] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown cell"})
+			return
+		}
+
+		adminID := c.GetString("user_id")
+		placement, err := a.store.Update(c.Request.Context(), c.Param("tenant"), func(placement *cells.Placement) error {
+			return placement.StartMove(req.Cell, adminID, a.cfg.DrainPeriod)
+		})
+		if !a.updated(c, placement, err) {
+			return
+		}
+
+		log.Printf("AUDIT cell move started: tenant=%s from=%s to=%s drain_until=%s by=%s",
+			placement.Tenant, placement.Cell, placement.TargetCell, placement.DrainUntil.Format(time.RFC3339), adminID)
+		c.JSON(http.StatusAccepted, gin.H{"placement": placement})
+	}
+}
+
+// completeHandler routes a drained tenant to its new cell
+func (a *cellsAPI) completeHandler() gin.HandlerFunc {
+	return a.finishHandler("completed", (*cells.Placement).CompleteMove)
+}
+
+// abortHandler keeps a moving tenant in its cell and accepts its writes
+// again
+func (a *cellsAPI) abortHandler() gin.HandlerFunc {
+	return a.finishHandler("aborted", (*cells.Placement).AbortMove)
+}
+
+// finishHandler ends a tenant's move with finish
+func (a *cellsAPI) finishHandler(outcome string, finish func(*cells.Placement, string) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.available(c) {
+			return
+		}
+
+		adminID := c.GetString("user_id")
+		placement, err := a.store.Update(c.Request.Context(), c.Param("tenant"), func(placement *cells.Placement) error {
+			return finish(placement, adminID)
+		})
+		if !a.updated(c, placement, err) {
+			return
+		}
+
+		log.Printf("AUDIT cell move %s: tenant=%s cell=%s by=%s", outcome, placement.Tenant, placement.Cell, adminID)
+		c.JSON(http.StatusOK, gin.H{"placement": placement})
+	}
+}
+
+// updated reports whether a placement was updated, answering the request
+// with the error if not. This gateway applies the change right away; others
+// pick it up on their next refresh.
+func (a *cellsAPI) updated(c *gin.Context, placement *cells.Placement, err error) bool {
+	switch {
+	case err == nil:
+		a.table.Set(placement)
+		return true
+	case errors.Is(err, cells.ErrSameCell):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tenant is already in this cell"})
+	case errors.Is(err, cells.ErrMoving):
+		c.JSON(http.StatusConflict, gin.H{"error": "Tenant is already moving"})
+	case errors.Is(err, cells.ErrNotMoving):
+		c.JSON(http.StatusConflict, gin.H{"error": "Tenant is not moving"})
+	case errors.Is(err, cells.ErrStillDraining):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, cells.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Placement changed, try again"})
+	default:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Cell placement is unavailable"})
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"microservices-platform/pkg/clusterconfig"
+	"microservices-platform/pkg/middleware"
+	"microservices-platform/pkg/proxy"
+)
+
+// clusterConfigLoadTimeout bounds reading the cluster configuration at
+// startup
+const clusterConfigLoadTimeout = 10 * time.Second
+
+// watchClusterConfig applies the route limits, rate limits and feature flags
+// of the ConfigMap or PlatformConfig named by KUBE_CONFIG_SOURCE, and again
+// whenever it changes. Without KUBE_CONFIG_SOURCE, or outside a cluster, the
+// environment settings stay in effect.
+func watchClusterConfig(cfg *Config, gateway *proxy.Gateway, rateLimits *middleware.RateLimitOverrides, launches *middleware.SoftLaunches) {
+	if !cfg.Cluster.Enabled() {
+		return
+	}
+
+	watcher, err := clusterconfig.NewWatcher(cfg.Cluster, func(platform *clusterconfig.Platform) error {
+		applyClusterConfig(platform, gateway, rateLimits, launches)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Cluster configuration disabled: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterConfigLoadTimeout)
+	defer cancel()
+	if err := watcher.Load(ctx); err != nil {
+		log.Printf("Failed to load cluster configuration, using environment settings until it loads: %v", err)
+	}
+	go watcher.Start(context.Background())
+}
+
+// applyClusterConfig replaces the gateway's runtime overrides with those of
+// platform. Sections missing from platform clear their overrides, so the
+// environment settings apply again.
+func applyClusterConfig(platform *clusterconfig.Platform, gateway *proxy.Gateway, rateLimits *middleware.RateLimitOverrides, launches *middleware.SoftLaunches) {
+	routes := make(map[string]proxy.RouteLimits, len(platform.Routes))
+	for route, limits := range platform.Routes {
+		routes[route] = proxy.RouteLimits{
+			Timeout:      time.Duration(limits.Timeout),
+			MaxBodySize:  limits.MaxBodySize,
+			ContentTypes: limits.ContentTypes,
+		}
+	}
+	gateway.SetRouteLimits(routes)
+
+	if platform.RateLimits != nil {
+		rateLimits.Set(time.Duration(platform.RateLimits.Window), platform.RateLimits.Tiers)
+	} else {
+		rateLimits.Set(0, nil)
+	}
+
+	flags := make(map[string]middleware.SoftLaunch, len(platform.FeatureFlags))
+	for flag, settings := range platform.FeatureFlags {
+		flags[flag] = middleware.SoftLaunch{
+			Tenants: settings.Tenants,
+			Users:   settings.Users,
+			Percent: settings.Percent,
+			Visible: settings.Visible,
+		}
+	}
+	launches.Override(flags)
+}
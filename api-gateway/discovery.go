@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+
+	"microservices-platform/pkg/discovery"
+	"microservices-platform/pkg/proxy"
+)
+
+// watchDiscoveredServices replaces the instances of the services listed in
+// GATEWAY_DISCOVERY_SERVICES with those found in the service discovery
+// registry, and again whenever they change. A service is looked up by the
+// host and port of its configured URL, which is used until the first lookup
+// succeeds.
+func watchDiscoveredServices(cfg *Config, gateway *proxy.Gateway, services []*proxy.ServiceConfig) {
+	if len(cfg.Balancer.DiscoveryServices) == 0 {
+		return
+	}
+	if !cfg.Discovery.Enabled() {
+		log.Printf("WARNING: GATEWAY_DISCOVERY_SERVICES is set without DISCOVERY_PROVIDER, using the configured instances")
+		return
+	}
+	registry, err := discovery.New(cfg.Discovery)
+	if err != nil {
+		log.Printf("Service discovery disabled, using the configured instances: %v", err)
+		return
+	}
+
+	discovered := make(map[string]bool)
+	for _, name := range cfg.Balancer.DiscoveryServices {
+		discovered[strings.TrimSpace(name)] = true
+	}
+	for _, service := range services {
+		if !discovered[service.Name] || len(service.Instances) == 0 {
+			continue
+		}
+		if service.Resolver != nil {
+			log.Printf("WARNING: %s is resolved through DNS, not service discovery", service.Name)
+			continue
+		}
+		upstream, err := url.Parse(service.Instances[0])
+		if err != nil || upstream.Host == "" {
+			log.Printf("WARNING: cannot discover %s from its URL %q", service.Name, service.Instances[0])
+			continue
+		}
+
+		name, scheme := service.Name, upstream.Scheme
+		go registry.Watch(context.Background(), upstream.Host, func(addresses []string) {
+			urls := make([]string, len(addresses))
+			for i, address := range addresses {
+				urls[i] = scheme + "://" + address
+			}
+			if err := gateway.SetInstances(name, urls); err != nil {
+				log.Printf("Failed to apply the instances of %s: %v", name, err)
+			}
+		})
+	}
+}
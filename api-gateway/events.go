@@ -0,0 +1,164 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/proxy"
+)
+
+// eventBrowser serves the admin event browser from the shared event store
+type eventBrowser struct {
+	store       events.EventStore
+	bus         events.EventBus
+	jaegerUIURL string
+}
+
+// setupEventBrowser connects the event browser to Redis. If Redis is
+// unavailable the browser endpoints report the store as unavailable.
+func setupEventBrowser(cfg *Config) *eventBrowser {
+	browser := &eventBrowser{jaegerUIURL: strings.TrimRight(cfg.JaegerUIURL, "/")}
+
+	store, err := events.NewRedisEventStore(cfg.Redis)
+	if err != nil {
+		log.Printf("Redis unavailable, event browser disabled: %v", err)
+		return browser
+	}
+	browser.store = store
+
+	bus, err := events.NewRedisEventBus(cfg.Redis, config.LoadEventBusConfig("api-gateway"))
+	if err != nil {
+		log.Printf("Redis unavailable, event replay disabled: %v", err)
+		return browser
+	}
+	browser.bus = bus
+
+	return browser
+}
+
+// listHandler searches stored events by type, subject, source, time range
+// (RFC 3339 "from" and "to") and free text in the event data ("q")
+func (b *eventBrowser) listHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if b.store == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Event store is unavailable"})
+			return
+		}
+
+		query := events.EventQuery{
+			Type:    events.EventType(c.Query("type")),
+			Subject: c.Query("subject"),
+			Source:  c.Query("source"),
+			Text:    c.Query("q"),
+		}
+
+		var err error
+		if value := c.Query("from"); value != "" {
+			if query.From, err = time.Parse(time.RFC3339, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be an RFC 3339 timestamp"})
+				return
+			}
+		}
+		if value := c.Query("to"); value != "" {
+			if query.To, err = time.Parse(time.RFC3339, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC 3339 timestamp"})
+				return
+			}
+		}
+		if value := c.Query("limit"); value != "" {
+			query.Limit, err = strconv.Atoi(value)
+			if err != nil || query.Limit < 1 || query.Limit > events.MaxQueryLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+				return
+			}
+		}
+
+		results, err := b.store.Query(c.Request.Context(), query)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Event store is unavailable"})
+			return
+		}
+
+		if results == nil {
+			results = []*events.Event{}
+		}
+		proxy.WriteList(c, results, len(results))
+	}
+}
+
+// getHandler returns a single stored event with a link to its trace
+func (b *eventBrowser) getHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if b.store == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Event store is unavailable"})
+			return
+		}
+
+		event, err := b.store.GetEvent(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Event store is unavailable"})
+			return
+		}
+		if event == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+
+		response := gin.H{"event": event}
+		if traceID := event.Metadata[events.MetadataTraceID]; traceID != "" && b.jaegerUIURL != "" {
+			response["trace_url"] = b.jaegerUIURL + "/trace/" + traceID
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// replayRequest names the consumer that should handle a replayed event
+type replayRequest struct {
+	Handler string `json:"handler" binding:"required"`
+}
+
+// replayHandler republishes a stored event to a single handler, identified
+// by its consumer name (for example "order-service:cart-reminders")
+func (b *eventBrowser) replayHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if b.store == nil || b.bus == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Event replay is unavailable"})
+			return
+		}
+
+		var req replayRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "handler is required"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		event, err := b.store.GetEvent(ctx, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Event store is unavailable"})
+			return
+		}
+		if event == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+
+		if err := events.Replay(ctx, b.bus, event, req.Handler); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to replay event"})
+			return
+		}
+
+		log.Printf("AUDIT event replay: event=%s type=%s handler=%s admin=%s", event.ID, event.Type, req.Handler, c.GetString("user_id"))
+		c.JSON(http.StatusAccepted, gin.H{
+			"event_id": event.ID,
+			"handler":  req.Handler,
+		})
+	}
+}
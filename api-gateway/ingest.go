@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/events"
+)
+
+// maxIngestBodySize bounds the size of an ingested CloudEvent
+const maxIngestBodySize = 1 << 20
+
+// MetadataIngestedBy records the partner that published an ingested event
+const MetadataIngestedBy = "ingested_by"
+
+// eventIngest publishes CloudEvents from external producers onto the event
+// bus. Only allowlisted event types are accepted.
+type eventIngest struct {
+	bus   events.EventBus
+	types map[string]bool
+}
+
+// setupEventIngest allows the types in EVENT_INGEST_TYPES. With no types
+// configured every ingested event is rejected.
+func setupEventIngest(bus events.EventBus) *eventIngest {
+	ingest := &eventIngest{bus: bus, types: make(map[string]bool)}
+	for _, eventType := range config.LoadEventBusConfig("api-gateway").IngestTypes {
+		ingest.types[eventType] = true
+	}
+	return ingest
+}
+
+// handler accepts a CloudEvent in structured or binary HTTP mode. Producers
+// that retry with the same event id are deduplicated by consumers.
+func (i *eventIngest) handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if i.bus == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Event ingest is unavailable"})
+			return
+		}
+
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxIngestBodySize)
+		event, err := events.DecodeCloudEventHTTP(c.Request.Header, body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !i.types[string(event.Type)] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Event type is not accepted"})
+			return
+		}
+
+		// Platform metadata is only set by the platform; a producer must not
+		// be able to target a replay or forge a trace link
+		delete(event.Metadata, events.MetadataReplayTo)
+		delete(event.Metadata, events.MetadataReplayedAt)
+		delete(event.Metadata, events.MetadataTraceID)
+		delete(event.Metadata, events.MetadataSpanID)
+		if event.Metadata == nil {
+			event.Metadata = make(map[string]string)
+		}
+		event.Metadata[MetadataIngestedBy] = c.GetString("user_id")
+
+		if err := i.bus.Publish(c.Request.Context(), event); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to publish event"})
+			return
+		}
+
+		log.Printf("Ingested event: event=%s type=%s source=%s partner=%s", event.ID, event.Type, event.Source, c.GetString("user_id"))
+		c.JSON(http.StatusAccepted, gin.H{"event_id": event.ID})
+	}
+}
@@ -2,15 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/jaeger"
@@ -18,9 +17,24 @@ import (
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 
+	"microservices-platform/pkg/app"
+	"microservices-platform/pkg/approvals"
+	"microservices-platform/pkg/buildinfo"
+	"microservices-platform/pkg/cdn"
+	"microservices-platform/pkg/clusterconfig"
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/discovery"
+	"microservices-platform/pkg/incidents"
+	"microservices-platform/pkg/instrumentation"
+	"microservices-platform/pkg/logging"
+	"microservices-platform/pkg/metrics"
 	"microservices-platform/pkg/middleware"
+	"microservices-platform/pkg/plans"
 	"microservices-platform/pkg/proxy"
 	"microservices-platform/pkg/resilience"
+	"microservices-platform/pkg/reviews"
+	"microservices-platform/pkg/revocation"
+	"microservices-platform/pkg/watchdog"
 )
 
 type Config struct {
@@ -30,8 +44,38 @@ type Config struct {
 	ProductServiceURL      string
 	PaymentServiceURL      string
 	NotificationServiceURL string
+	SubscriptionServiceURL string
 	JWTSecret              string
+	// JWTPublicKeyFile holds the PEM-encoded RSA public key of RS256 tokens.
+	// When set, it verifies tokens in place of JWTSecret.
+	JWTPublicKeyFile       string
 	Environment            string
+	JaegerUIURL            string
+	Identity               config.InternalIdentityConfig
+	Redis                  config.RedisConfig
+	RateLimit              config.RateLimitConfig
+	Routes                 config.GatewayRouteConfig
+	Outliers               config.OutlierDetectionConfig
+	Balancer               config.GatewayBalancerConfig
+	Failover               config.GatewayFailoverConfig
+	Transport              config.GatewayTransportConfig
+	Retry                  config.GatewayRetryConfig
+	Cells                  config.CellConfig
+	Operations             config.OperationsConfig
+	Batch                  config.GatewayBatchConfig
+	Anomalies              config.AnomalyDetectionConfig
+	Approvals              config.ApprovalConfig
+	Reviews                config.ReviewConfig
+	SoftLaunches           config.SoftLaunchConfig
+	CDN                    config.CDNConfig
+	Stats                  config.StatsConfig
+	Logging                logging.Config
+	ErrorBudgets           config.ErrorBudgetConfig
+	Observability          config.ObservabilityConfig
+	Watchdog               watchdog.Config
+	App                    app.Config
+	Cluster                clusterconfig.Config
+	Discovery              discovery.Config
 }
 
 func loadConfig() *Config {
@@ -42,8 +86,36 @@ func loadConfig() *Config {
 		ProductServiceURL:      getEnv("PRODUCT_SERVICE_URL", "product-service:8083"),
 		PaymentServiceURL:      getEnv("PAYMENT_SERVICE_URL", "payment-service:8084"),
 		NotificationServiceURL: getEnv("NOTIFICATION_SERVICE_URL", "notification-service:8085"),
+		SubscriptionServiceURL: getEnv("SUBSCRIPTION_SERVICE_URL", "subscription-service:8086"),
 		JWTSecret:              getEnv("JWT_SECRET", "your-jwt-secret-key"),
+		JWTPublicKeyFile:       getEnv("JWT_PUBLIC_KEY_FILE", ""),
 		Environment:            getEnv("ENVIRONMENT", "development"),
+		JaegerUIURL:            getEnv("JAEGER_UI_URL", "http://localhost:16686"),
+		Identity:               config.LoadInternalIdentityConfig(),
+		Redis:                  config.LoadRedisConfig(),
+		RateLimit:              config.LoadRateLimitConfig(),
+		Routes:                 config.LoadGatewayRouteConfig(),
+		Outliers:               config.LoadOutlierDetectionConfig(),
+		Balancer:               config.LoadGatewayBalancerConfig(),
+		Failover:               config.LoadGatewayFailoverConfig(),
+		Transport:              config.LoadGatewayTransportConfig(),
+		Retry:                  config.LoadGatewayRetryConfig(),
+		Cells:                  config.LoadCellConfig(),
+		Operations:             config.LoadOperationsConfig(),
+		Batch:                  config.LoadGatewayBatchConfig(),
+		Anomalies:              config.LoadAnomalyDetectionConfig(),
+		Approvals:              config.LoadApprovalConfig(),
+		Reviews:                config.LoadReviewConfig(),
+		SoftLaunches:           config.LoadSoftLaunchConfig(),
+		CDN:                    config.LoadCDNConfig(),
+		Stats:                  config.LoadStatsConfig(),
+		Logging:                logging.LoadConfig("api-gateway"),
+		ErrorBudgets:           config.LoadErrorBudgetConfig(),
+		Observability:          config.LoadObservabilityConfig(),
+		Watchdog:               watchdog.LoadConfig(),
+		App:                    app.LoadConfig(),
+		Cluster:                clusterconfig.LoadConfig(),
+		Discovery:              discovery.LoadConfig(),
 	}
 }
 
@@ -54,21 +126,65 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// instanceURLs turns a comma-separated list of service instance addresses,
+// e.g. "order-service-1:8082,order-service-2:8082", into upstream URLs
+func instanceURLs(addresses string) []string {
+	var urls []string
+	for _, address := range strings.Split(addresses, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			urls = append(urls, "http://"+address)
+		}
+	}
+	return urls
+}
+
 func main() {
 	// Initialize OpenTelemetry
 	tp, err := initTracer("api-gateway")
 	if err != nil {
 		log.Fatalf("Failed to initialize tracer: %v", err)
 	}
-	defer func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
-		}
-	}()
 
 	// Load configuration
 	cfg := loadConfig()
 
+	// Verify RS256 tokens with the public key of the key user-service signs
+	// them with; the middleware takes a PEM-encoded key in place of a secret
+	if cfg.JWTPublicKeyFile != "" {
+		key, err := os.ReadFile(cfg.JWTPublicKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to read JWT public key: %v", err)
+		}
+		cfg.JWTSecret = string(key)
+	}
+
+	// Run the gateway through serve, drain and shutdown. Resources are
+	// released in the reverse order they are registered.
+	application := app.New("api-gateway", cfg.App)
+	application.OnShutdown("tracer", tp.Shutdown)
+
+	// Filter logs by level and rate limit repeated errors
+	if err := logging.Setup(cfg.Logging); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+
+	// Announce the build and report it in build_info
+	buildinfo.LogStartup("api-gateway")
+
+	// Continuously profile CPU and heap when enabled
+	profiler, err := instrumentation.StartProfiling("api-gateway", cfg.Observability)
+	if err != nil {
+		log.Fatalf("Failed to start profiling: %v", err)
+	}
+	application.OnShutdown("profiler", func(ctx context.Context) error {
+		profiler.Stop()
+		return nil
+	})
+
+	// Watch for leaked goroutines and file descriptors, e.g. from proxied
+	// connections that are never closed
+	watchdog.New("api-gateway", cfg.Watchdog).Start(context.Background())
+
 	// Initialize gateway with services
 	gateway := setupGateway(cfg)
 
@@ -85,51 +201,51 @@ func main() {
 	router.Use(middleware.TracingMiddleware("api-gateway"))
 	router.Use(middleware.MetricsMiddleware())
 	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.LanguageMiddleware())
 
 	// Health checks
 	router.GET("/health", gateway.HealthCheckHandler())
 	router.GET("/health/:service", serviceHealthHandler(gateway))
 
 	// Metrics endpoint
-	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	router.GET(buildinfo.Path, gin.WrapH(buildinfo.Handler("api-gateway")))
 
 	// API routes with proper authentication and authorization
 	setupAPIRoutes(router, gateway, cfg)
 
-	// Create HTTP server with timeouts
+	// Create HTTP server with timeouts. Responses of the slowest route must
+	// be written before the write timeout.
+	writeTimeout := 30 * time.Second
+	if timeout := gateway.MaxTimeout() + 5*time.Second; timeout > writeTimeout {
+		writeTimeout = timeout
+	}
 	srv := &http.Server{
 		Addr:           ":" + cfg.Port,
 		Handler:        router,
 		ReadTimeout:    30 * time.Second,
-		WriteTimeout:   30 * time.Second,
+		WriteTimeout:   writeTimeout,
 		IdleTimeout:    120 * time.Second,
 		MaxHeaderBytes: 1 << 20, // 1MB
 	}
 
-	// Start server in a goroutine
-	go func() {
+	application.OnServe("http", func(ctx context.Context) error {
 		log.Printf("🚀 API Gateway starting on port %s (environment: %s)", cfg.Port, cfg.Environment)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			return err
 		}
-	}()
-
-	// Wait for interrupt signal to gracefully shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	log.Println("🛑 Shutting down API Gateway...")
-
-	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("API Gateway forced to shutdown: %v", err)
+		return nil
+	})
+	// Stop accepting requests and wait for in-flight ones
+	application.OnDrain("http", func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	})
+
+	if err := application.Run(); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
 	}
-
-	log.Println("✅ API Gateway stopped gracefully")
 }
 
 // setupGateway configures the gateway with all microservices
@@ -140,48 +256,203 @@ func setupGateway(cfg *Config) *proxy.Gateway {
 	services := []*proxy.ServiceConfig{
 		{
 			Name:       "user-service",
-			URL:        "http://" + cfg.UserServiceURL,
+			Instances:  instanceURLs(cfg.UserServiceURL),
 			HealthPath: "/health",
 			Timeout:    30 * time.Second,
+			// Avatars and user imports are uploaded inline as JSON
+			Routes: map[string]proxy.RouteLimits{
+				"POST /api/v1/users/:id/avatar":   {MaxBodySize: 10 << 20, ContentTypes: []string{"application/json"}},
+				"POST /api/v1/admin/users/import": {Timeout: 2 * time.Minute, MaxBodySize: 50 << 20, ContentTypes: []string{"application/json"}},
+			},
 			CircuitBreaker: resilience.NewCircuitBreaker(resilience.CircuitBreakerSettings{
 				MaxFailures:      5,
 				ResetTimeout:     60 * time.Second,
 				SuccessThreshold: 3,
 				Timeout:          30 * time.Second,
+				SlowStartWindow:  30 * time.Second,
 			}),
 		},
 		{
 			Name:       "order-service",
-			URL:        "http://" + cfg.OrderServiceURL,
+			Instances:  instanceURLs(cfg.OrderServiceURL),
 			HealthPath: "/health",
 			Timeout:    30 * time.Second,
 			CircuitBreaker: resilience.NewCircuitBreaker(resilience.DefaultSettings()),
 		},
 		{
 			Name:       "product-service",
-			URL:        "http://" + cfg.ProductServiceURL,
+			Instances:  instanceURLs(cfg.ProductServiceURL),
 			HealthPath: "/health",
 			Timeout:    30 * time.Second,
+			// Search is interactive; a slow search is better abandoned
+			Routes: map[string]proxy.RouteLimits{
+				"GET /api/v1/products/search": {Timeout: 5 * time.Second},
+			},
 			CircuitBreaker: resilience.NewCircuitBreaker(resilience.DefaultSettings()),
 		},
 		{
 			Name:       "payment-service",
-			URL:        "http://" + cfg.PaymentServiceURL,
+			Instances:  instanceURLs(cfg.PaymentServiceURL),
 			HealthPath: "/health",
 			Timeout:    30 * time.Second,
+			// Payment providers sign their webhook callbacks, and some wait
+			// on the payment being settled before they get a response
+			Headers:    proxy.AllowHeaders("Stripe-Signature", "Paypal-*"),
+			Routes: map[string]proxy.RouteLimits{
+				"POST /api/v1/webhooks/payments/:provider": {
+					Timeout:      60 * time.Second,
+					MaxBodySize:  256 << 10,
+					ContentTypes: []string{"application/json", "application/x-www-form-urlencoded"},
+				},
+			},
 			CircuitBreaker: resilience.NewCircuitBreaker(resilience.DefaultSettings()),
 		},
 		{
 			Name:       "notification-service",
-			URL:        "http://" + cfg.NotificationServiceURL,
+			Instances:  instanceURLs(cfg.NotificationServiceURL),
+			HealthPath: "/health",
+			Timeout:    30 * time.Second,
+			CircuitBreaker: resilience.NewCircuitBreaker(resilience.DefaultSettings()),
+		},
+		{
+			Name:       "subscription-service",
+			Instances:  instanceURLs(cfg.SubscriptionServiceURL),
 			HealthPath: "/health",
 			Timeout:    30 * time.Second,
 			CircuitBreaker: resilience.NewCircuitBreaker(resilience.DefaultSettings()),
 		},
 	}
 
+	// Keep users on the same instance of services with instance-local caches
+	sticky := make(map[string]bool)
+	for _, name := range cfg.Balancer.StickyServices {
+		sticky[strings.TrimSpace(name)] = true
+	}
+	resolved := make(map[string]bool)
+	for _, name := range cfg.Balancer.DNSServices {
+		resolved[strings.TrimSpace(name)] = true
+	}
+
 	for _, service := range services {
-		gateway.RegisterService(service)
+		service.Transport = proxy.TransportSettings{
+			MaxIdleConns:        cfg.Transport.MaxIdleConns,
+			MaxIdleConnsPerHost: cfg.Transport.MaxIdleConnsPerHost,
+			MaxConnsPerHost:     cfg.Transport.MaxConnsPerHost,
+			IdleConnTimeout:     cfg.Transport.IdleConnTimeout,
+			DialTimeout:         cfg.Transport.DialTimeout,
+			DisableKeepAlives:   cfg.Transport.DisableKeepAlives,
+		}
+		service.MaxBodySize = cfg.Routes.MaxBodySize
+		service.Retries = cfg.Retry.Retries
+		service.RetryBackoff = proxy.DefaultRetryBackoff()
+		service.RetryBackoff.BaseDelay = cfg.Retry.BaseDelay
+		service.RetryBackoff.MaxDelay = cfg.Retry.MaxDelay
+		service.RetryBudget = cfg.Retry.Budget
+		strategy, ok := cfg.Balancer.Strategies[service.Name]
+		if !ok {
+			strategy = cfg.Balancer.Strategy
+			if sticky[service.Name] {
+				strategy = proxy.StrategyConsistentHash
+			}
+		}
+		balancer, err := proxy.NewBalancer(strategy)
+		if err != nil {
+			log.Fatalf("Failed to set up gateway: %s: %v", service.Name, err)
+		}
+		service.Balancer = balancer
+		if strategy == proxy.StrategyConsistentHash {
+			service.HashKey = proxy.HashByUserOrSession(cfg.Balancer.SessionCookie)
+		}
+
+		// Balance over the addresses the service's host resolves to, such
+		// as the pods behind a headless service
+		if resolved[service.Name] && len(service.Instances) > 0 {
+			resolver, err := proxy.NewDNSResolver(service.Instances[0])
+			if err != nil {
+				log.Fatalf("Failed to set up gateway: %s: %v", service.Name, err)
+			}
+			service.Resolver = resolver
+		}
+
+		// Dedicated pools isolate the tenants mapped to them
+		if pools := cfg.Balancer.Pools[service.Name]; len(pools) > 0 {
+			service.Pools = make(map[string][]string, len(pools))
+			for name, addresses := range pools {
+				service.Pools[name] = instanceURLs(strings.Join(addresses, ","))
+			}
+			service.TenantPools = cfg.Balancer.TenantPools[service.Name]
+		}
+
+		// Fail over to the secondary region while local instances are down
+		if addresses := cfg.Failover.Upstreams[service.Name]; len(addresses) > 0 {
+			service.Failover = instanceURLs(strings.Join(addresses, ","))
+			service.FailoverRegion = cfg.Failover.SecondaryRegion
+		}
+		if err := gateway.RegisterService(service); err != nil {
+			log.Fatalf("Failed to set up gateway: %v", err)
+		}
+	}
+
+	// Eject outlier instances of services running more than one
+	if cfg.Outliers.Enabled {
+		outliers := gateway.DetectOutliers(proxy.OutlierDetectionSettings{
+			Interval:           cfg.Outliers.Interval,
+			BaseEjectionTime:   cfg.Outliers.BaseEjectionTime,
+			MaxEjectionTime:    cfg.Outliers.MaxEjectionTime,
+			MaxEjectionPercent: cfg.Outliers.MaxEjectionPercent,
+			ConsecutiveErrors:  cfg.Outliers.ConsecutiveErrors,
+			FailurePercentage:  cfg.Outliers.FailurePercentage,
+			LatencyFactor:      cfg.Outliers.LatencyFactor,
+			MinRequests:        int64(cfg.Outliers.MinRequests),
+			SlowStartWindow:    cfg.Outliers.SlowStartWindow,
+		})
+		go outliers.Start(context.Background())
+	}
+
+	// Take instances failing their health checks out of the balancer, and
+	// follow the addresses of services resolved through DNS or service
+	// discovery
+	if cfg.Balancer.HealthInterval > 0 {
+		go gateway.CheckInstances(proxy.InstanceHealthSettings{
+			Interval:           cfg.Balancer.HealthInterval,
+			Timeout:            cfg.Balancer.HealthTimeout,
+			UnhealthyThreshold: cfg.Balancer.UnhealthyThreshold,
+			HealthyThreshold:   cfg.Balancer.HealthyThreshold,
+		}).Start(context.Background())
+	}
+	if len(resolved) > 0 {
+		go gateway.ResolveInstances(cfg.Balancer.DNSRefresh).Start(context.Background())
+	}
+	watchDiscoveredServices(cfg, gateway, services)
+
+	// Move services to the secondary region while their health checks fail
+	if len(cfg.Failover.Upstreams) > 0 {
+		go gateway.WatchFailover(cfg.Failover.CheckInterval).Start(context.Background())
+	}
+
+	if cfg.Balancer.TenantHeader != "" {
+		gateway.RouteTenantsByHeader(cfg.Balancer.TenantHeader)
+	}
+
+	if cfg.Identity.Secret != "" {
+		gateway.SignIdentities(cfg.Identity.Secret, cfg.Identity.TTL)
+	} else {
+		log.Printf("INTERNAL_IDENTITY_SECRET not set, caller identity is not signed for backend services")
+	}
+
+	// Per-route timeouts and slow route detection
+	gateway.SetRouteTimeouts(cfg.Routes.Timeouts)
+	latency := gateway.TrackRouteLatency(proxy.SlowRouteSettings{
+		Threshold:     cfg.Routes.SlowThreshold,
+		CheckInterval: cfg.Routes.SlowCheckInterval,
+		Checks:        cfg.Routes.SlowChecks,
+	})
+	go latency.Start(context.Background())
+
+	// Identical concurrent GETs, such as a burst for a hot product, share
+	// one upstream call
+	if cfg.Routes.Dedup {
+		gateway.DeduplicateRequests(cfg.Routes.DedupExclude)
 	}
 
 	return gateway
@@ -189,32 +460,138 @@ func setupGateway(cfg *Config) *proxy.Gateway {
 
 // setupAPIRoutes configures API routes with proper authentication
 func setupAPIRoutes(router *gin.Engine, gateway *proxy.Gateway, cfg *Config) {
+	limiter := setupRateLimiter(cfg)
+	browser := setupEventBrowser(cfg)
+	ingest := setupEventIngest(browser.bus)
+	ops := setupOperations(cfg)
+	approvalQueue := setupApprovals(cfg, router)
+	reviewQueue := setupReviews(cfg, router, browser.bus)
+	cdnAPI := setupCDN(cfg, browser.bus)
+	statsAPI := setupStats(cfg, browser.bus)
+	cellAPI := setupCells(cfg, gateway)
+
+	// The bus subscribes to the events handled so far when it starts
+	if browser.bus != nil {
+		if err := browser.bus.Start(context.Background()); err != nil {
+			log.Printf("Failed to start event bus, gateway event consumers are disabled: %v", err)
+		}
+	}
+
+	// Report open circuits and fast error budget burn as incidents for the
+	// ops channel
+	reporter := incidents.NewReporter("api-gateway", browser.bus)
+	gateway.ReportCircuitBreakers(reporter)
+	if cfg.ErrorBudgets.Enabled {
+		budgets := gateway.TrackErrorBudgets(proxy.ErrorBudgetSettings{
+			Objective:         cfg.ErrorBudgets.Objective,
+			BurnRateThreshold: cfg.ErrorBudgets.BurnRateThreshold,
+			ShortWindow:       cfg.ErrorBudgets.ShortWindow,
+			LongWindow:        cfg.ErrorBudgets.LongWindow,
+			CheckInterval:     cfg.ErrorBudgets.CheckInterval,
+			MinRequests:       int64(cfg.ErrorBudgets.MinRequests),
+		}, reporter)
+		go budgets.Start(context.Background())
+	}
+
+	// Watch each caller for traffic spikes and error bursts
+	var anomalies *middleware.AnomalyDetector
+	if cfg.Anomalies.Enabled {
+		anomalies = middleware.NewAnomalyDetector(middleware.AnomalySettings{
+			Interval:         cfg.Anomalies.Interval,
+			SpikeFactor:      cfg.Anomalies.SpikeFactor,
+			ErrorRatio:       cfg.Anomalies.ErrorRatio,
+			MinRequests:      int64(cfg.Anomalies.MinRequests),
+			RestrictFactor:   cfg.Anomalies.RestrictFactor,
+			RestrictDuration: cfg.Anomalies.RestrictDuration,
+		}, browser.bus)
+		go anomalies.Start(context.Background())
+	}
+
+	// Tokens of suspended users are refused straight away, not when they expire
+	revocations := setupRevocations(cfg)
+
+	// Middleware of every API version. Cluster configuration can override
+	// the rate limits while the gateway runs.
+	rateLimits := middleware.NewRateLimitOverrides()
+	apiMiddleware := []gin.HandlerFunc{
+		middleware.TieredRateLimitMiddleware(limiter, middleware.RateLimitPolicy{
+			Window:           cfg.RateLimit.Window,
+			Tiers:            cfg.RateLimit.Tiers,
+			Plans:            setupPlanLookup(cfg),
+			WarningThreshold: cfg.RateLimit.WarningThreshold,
+			Anomalies:        anomalies,
+			Overrides:        rateLimits,
+		}, cfg.JWTSecret),
+		middleware.TenantMiddleware(cfg.JWTSecret),
+		middleware.BusinessBaggageMiddleware(),
+	}
+
 	api := router.Group("/api/v1")
+	api.Use(apiMiddleware...)
 	
+	// Several API requests in one round trip. Each sub-request runs through
+	// these routes with the batch's credentials, so it is authenticated and
+	// rate limited on its own. Admin batches of many deletes need a second
+	// admin's approval.
+	api.POST("/batch", approvalQueue.require(approvals.RuleBulkDelete, approvalQueue.bulkDelete()), batchHandler(router, cfg.Batch))
+
 	// Public routes (no authentication required)
 	public := api.Group("/")
 	{
 		// Authentication endpoint
 		public.POST("/auth/login", gateway.ProxyHandler("user-service"))
+		public.POST("/auth/password-reset", gateway.ProxyHandler("user-service"))
+		public.POST("/auth/secure-account", gateway.ProxyHandler("user-service"))
 		
 		// Public product endpoints
-		public.GET("/products", gateway.ProxyHandler("product-service"))
-		public.GET("/products/:id", gateway.ProxyHandler("product-service"))
-		public.GET("/products/search", gateway.ProxyHandler("product-service"))
+		public.GET("/products", cdn.Cache(cfg.CDN, cdn.ProductListKeys), gateway.ListProxyHandler("product-service", "products"))
+		public.GET("/products/:id", cdn.Cache(cfg.CDN, cdn.ProductKeys), gateway.ProxyHandler("product-service"))
+		public.GET("/products/search", cdn.Cache(cfg.CDN, cdn.ProductListKeys), gateway.ListProxyHandler("product-service", "products"))
+
+		// Subscription plans on offer
+		public.GET("/plans", gateway.ListProxyHandler("subscription-service", "plans"))
 	}
 
 	// Protected routes (authentication required)
 	protected := api.Group("/")
 	protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	protected.Use(middleware.RejectRevokedTokens(revocations))
+	protected.Use(middleware.ImpersonationMiddleware(cfg.JWTSecret))
 	{
+		// Caller's own rate limit usage
+		protected.GET("/me/usage", usageHandler(limiter, rateLimits, cfg))
+
+		// Status of long-running operations the caller started, polled or
+		// streamed as server-sent events
+		operationGroup := protected.Group("/operations")
+		operationGroup.Use(middleware.RequireRole(cfg.JWTSecret, "user", "partner", "admin"))
+		{
+			operationGroup.GET("/:id", ops.getHandler())
+			operationGroup.GET("/:id/events", ops.eventsHandler())
+		}
+
 		// User management
-		userGroup := protected.Group("/users")
+		setupUserRoutes(protected, gateway, cfg, approvalQueue)
+
+		// Organizations and team invitations
+		orgGroup := protected.Group("/organizations")
 		{
-			userGroup.POST("", gateway.ProxyHandler("user-service"))
-			userGroup.GET("/:id", gateway.ProxyHandler("user-service"))
-			userGroup.PUT("/:id", gateway.ProxyHandler("user-service"))
-			userGroup.DELETE("/:id", gateway.ProxyHandler("user-service"))
-			userGroup.GET("", gateway.ProxyHandler("user-service"))
+			orgGroup.POST("", gateway.ProxyHandler("user-service"))
+			orgGroup.GET("/:id", gateway.ProxyHandler("user-service"))
+			orgGroup.GET("/:id/members", gateway.ListProxyHandler("user-service", "members"))
+			orgGroup.PUT("/:id/members/:user_id", gateway.ProxyHandler("user-service"))
+			orgGroup.DELETE("/:id/members/:user_id", gateway.ProxyHandler("user-service"))
+			orgGroup.POST("/:id/invitations", gateway.ProxyHandler("user-service"))
+			orgGroup.GET("/:id/invitations", gateway.ListProxyHandler("user-service", "invitations"))
+			orgGroup.DELETE("/:id/invitations/:invitation_id", gateway.ProxyHandler("user-service"))
+		}
+		protected.POST("/invitations/accept", gateway.ProxyHandler("user-service"))
+
+		// CloudEvents ingest for partners
+		ingestGroup := protected.Group("/events")
+		ingestGroup.Use(middleware.RequireRole(cfg.JWTSecret, "partner", "admin"))
+		{
+			ingestGroup.POST("", ingest.handler())
 		}
 
 		// Order management
@@ -224,7 +601,31 @@ func setupAPIRoutes(router *gin.Engine, gateway *proxy.Gateway, cfg *Config) {
 			orderGroup.GET("/:id", gateway.ProxyHandler("order-service"))
 			orderGroup.PUT("/:id/status", gateway.ProxyHandler("order-service"))
 			orderGroup.POST("/:id/cancel", gateway.ProxyHandler("order-service"))
-			orderGroup.GET("", gateway.ProxyHandler("order-service"))
+			orderGroup.POST("/:id/retrieve", gateway.ProxyHandler("order-service"))
+			// Shipping captures the shipped items' price from the payment
+			orderGroup.POST("/:id/shipments", middleware.RequireRole(cfg.JWTSecret, "admin"), gateway.ProxyHandler("order-service"))
+			orderGroup.GET("/:id/shipments", gateway.ListProxyHandler("order-service", "shipments"))
+			orderGroup.GET("", gateway.ListProxyHandler("order-service", "orders"))
+		}
+
+		// Shopping carts
+		cartGroup := protected.Group("/carts")
+		{
+			cartGroup.GET("/:id", gateway.ProxyHandler("order-service"))
+			cartGroup.POST("/:id/items", gateway.ProxyHandler("order-service"))
+			cartGroup.DELETE("/:id/items/:product_id", gateway.ProxyHandler("order-service"))
+			cartGroup.DELETE("/:id", gateway.ProxyHandler("order-service"))
+		}
+
+		// Tenant subscriptions
+		subscriptionGroup := protected.Group("/subscriptions")
+		{
+			subscriptionGroup.POST("", gateway.ProxyHandler("subscription-service"))
+			subscriptionGroup.GET("/:id", gateway.ProxyHandler("subscription-service"))
+			subscriptionGroup.PUT("/:id/plan", gateway.ProxyHandler("subscription-service"))
+			subscriptionGroup.POST("/:id/cancel", gateway.ProxyHandler("subscription-service"))
+			subscriptionGroup.GET("/:id/charges", gateway.ListProxyHandler("subscription-service", "charges"))
+			subscriptionGroup.GET("/:id/limits", gateway.ProxyHandler("subscription-service"))
 		}
 
 		// Payment management
@@ -232,8 +633,14 @@ func setupAPIRoutes(router *gin.Engine, gateway *proxy.Gateway, cfg *Config) {
 		{
 			paymentGroup.POST("", gateway.ProxyHandler("payment-service"))
 			paymentGroup.GET("/:id", gateway.ProxyHandler("payment-service"))
-			paymentGroup.POST("/:id/refund", gateway.ProxyHandler("payment-service"))
-			paymentGroup.GET("", gateway.ProxyHandler("payment-service"))
+			paymentGroup.POST("/:id/refund",
+				approvalQueue.require(approvals.RuleRefundOverThreshold, approvalQueue.refundOverThreshold()),
+				reviewQueue.require(reviews.KindRefundRequest, "payment", reviewQueue.refundOverThreshold()),
+				gateway.ProxyHandler("payment-service"))
+			paymentGroup.GET("", gateway.ListProxyHandler("payment-service", "payments"))
+			// Captures and voids of authorized payments
+			paymentGroup.POST("/:id/captures", middleware.RequireRole(cfg.JWTSecret, "admin"), gateway.ProxyHandler("payment-service"))
+			paymentGroup.POST("/:id/void", middleware.RequireRole(cfg.JWTSecret, "admin"), gateway.ProxyHandler("payment-service"))
 		}
 
 		// Notification management
@@ -241,7 +648,7 @@ func setupAPIRoutes(router *gin.Engine, gateway *proxy.Gateway, cfg *Config) {
 		{
 			notificationGroup.POST("", gateway.ProxyHandler("notification-service"))
 			notificationGroup.GET("/:id", gateway.ProxyHandler("notification-service"))
-			notificationGroup.GET("", gateway.ProxyHandler("notification-service"))
+			notificationGroup.GET("", gateway.ListProxyHandler("notification-service", "notifications"))
 			notificationGroup.PUT("/:id/read", gateway.ProxyHandler("notification-service"))
 			notificationGroup.DELETE("/:id", gateway.ProxyHandler("notification-service"))
 			notificationGroup.POST("/subscribe", gateway.ProxyHandler("notification-service"))
@@ -251,13 +658,96 @@ func setupAPIRoutes(router *gin.Engine, gateway *proxy.Gateway, cfg *Config) {
 	// Admin routes (admin authentication required)
 	admin := api.Group("/admin")
 	admin.Use(middleware.AuthMiddleware(cfg.JWTSecret))
-	// TODO: Add admin role validation
+	admin.Use(middleware.RejectRevokedTokens(revocations))
+	admin.Use(middleware.ImpersonationMiddleware(cfg.JWTSecret))
+	admin.Use(middleware.DenyImpersonation())
+	admin.Use(middleware.RequireRole(cfg.JWTSecret, "admin"))
 	{
+		// User management (admin only)
+		adminUserGroup := admin.Group("/users")
+		{
+			adminUserGroup.GET("", gateway.ListProxyHandler("user-service", "users"))
+			adminUserGroup.POST("/:id/suspend", gateway.ProxyHandler("user-service"))
+			adminUserGroup.POST("/:id/unsuspend", gateway.ProxyHandler("user-service"))
+			adminUserGroup.POST("/:id/password-reset", gateway.ProxyHandler("user-service"))
+			adminUserGroup.PUT("/:id/role", gateway.ProxyHandler("user-service"))
+			adminUserGroup.POST("/import", gateway.ProxyHandler("user-service"))
+		}
+
+		// Customer support impersonation (admin only)
+		admin.POST("/users/:id/impersonate", gateway.ProxyHandler("user-service"))
+
+		// Right-to-be-forgotten deletion progress (admin only)
+		admin.GET("/users/:id/deletion", gateway.ProxyHandler("user-service"))
+
+		// Event browser for debugging async flows (admin only)
+		adminEventGroup := admin.Group("/events")
+		{
+			adminEventGroup.GET("", browser.listHandler())
+			adminEventGroup.GET("/:id", browser.getHandler())
+			adminEventGroup.POST("/:id/replay", browser.replayHandler())
+		}
+
+		// Destructive admin requests held for a second admin's approval
+		adminActionGroup := admin.Group("/actions")
+		{
+			adminActionGroup.GET("", approvalQueue.listHandler())
+			adminActionGroup.GET("/:id", approvalQueue.getHandler())
+			adminActionGroup.POST("/:id/approve", approvalQueue.approveHandler())
+			adminActionGroup.POST("/:id/reject", approvalQueue.rejectHandler())
+		}
+
+		// Manual review queue for flagged payments, refunds and content
+		adminReviewGroup := admin.Group("/reviews")
+		{
+			adminReviewGroup.GET("", reviewQueue.listHandler())
+			adminReviewGroup.GET("/:id", reviewQueue.getHandler())
+			adminReviewGroup.POST("/:id/assign", reviewQueue.assignHandler())
+			adminReviewGroup.POST("/:id/resolve", reviewQueue.resolveHandler())
+		}
+
+		// Tenant placement in cells and moves between them (admin only)
+		adminCellGroup := admin.Group("/cells")
+		{
+			adminCellGroup.GET("", cellAPI.listHandler())
+			adminCellGroup.GET("/tenants/:tenant", cellAPI.getHandler())
+			adminCellGroup.POST("/tenants/:tenant/move", cellAPI.moveHandler())
+			adminCellGroup.POST("/tenants/:tenant/complete", cellAPI.completeHandler())
+			adminCellGroup.POST("/tenants/:tenant/abort", cellAPI.abortHandler())
+		}
+
+		// Per-route latency against route timeouts (admin only)
+		admin.GET("/routes/latency", gateway.RouteLatencyHandler())
+
+		// Purge cached catalog responses from the CDN by surrogate key
+		// (admin only)
+		admin.POST("/cdn/purge", cdnAPI.purgeHandler())
+
+		// Dashboard counters kept up to date from events (admin only)
+		admin.GET("/stats", statsAPI.statsHandler())
+		admin.GET("/stats/trends", statsAPI.trendsHandler())
+
+		// Gateway log level, changeable without a restart (admin only)
+		admin.GET("/log-level", gin.WrapH(logging.Handler("")))
+		admin.PUT("/log-level", gin.WrapH(logging.Handler("")))
+
+		// Nightly order totals audits (admin only)
+		admin.GET("/order-audits", gateway.ListProxyHandler("order-service", "audits"))
+		admin.GET("/order-audits/:id", gateway.ProxyHandler("order-service"))
+
+		// Subscription plan management (admin only)
+		adminPlanGroup := admin.Group("/plans")
+		{
+			adminPlanGroup.POST("", gateway.ProxyHandler("subscription-service"))
+			adminPlanGroup.PUT("/:code", gateway.ProxyHandler("subscription-service"))
+		}
+
 		// Product management (admin only)
 		adminProductGroup := admin.Group("/products")
 		{
 			adminProductGroup.POST("", gateway.ProxyHandler("product-service"))
 			adminProductGroup.PUT("/:id", gateway.ProxyHandler("product-service"))
+			adminProductGroup.PATCH("/:id", mergePatch(productPatchFields), gateway.ProxyHandler("product-service"))
 			adminProductGroup.DELETE("/:id", gateway.ProxyHandler("product-service"))
 			adminProductGroup.PUT("/:id/inventory", gateway.ProxyHandler("product-service"))
 		}
@@ -268,18 +758,148 @@ func setupAPIRoutes(router *gin.Engine, gateway *proxy.Gateway, cfg *Config) {
 	{
 		webhooks.POST("/payments/:provider", gateway.ProxyHandler("payment-service"))
 	}
+
+	// Routes being soft launched, open only to the launch's cohorts. The gate
+	// runs before authentication so hidden routes stay hidden.
+	launches := setupSoftLaunches(cfg)
+	v2 := router.Group("/api/v2")
+	v2.Use(apiMiddleware...)
+	{
+		v2.POST("/checkout", launches.Gate("checkout_v2"),
+			middleware.AuthMiddleware(cfg.JWTSecret),
+			middleware.RejectRevokedTokens(revocations),
+			middleware.ImpersonationMiddleware(cfg.JWTSecret),
+			gateway.ProxyHandler("order-service"))
+	}
+
+	watchClusterConfig(cfg, gateway, rateLimits, launches)
+}
+
+// setupUserRoutes registers the routes of users and the resources each user
+// owns. Only the user and admins may change a user or reach what they own.
+func setupUserRoutes(protected *gin.RouterGroup, gateway *proxy.Gateway, cfg *Config, approvalQueue *approvalsAPI) {
+	userGroup := protected.Group("/users")
+	{
+		userGroup.POST("", gateway.ProxyHandler("user-service"))
+		userGroup.GET("/:id", gateway.ProxyHandler("user-service"))
+		userGroup.PUT("/:id", middleware.RequireSelfOrRole("id", "admin"), rejectFields("status"), gateway.ProxyHandler("user-service"))
+		userGroup.PATCH("/:id", middleware.RequireSelfOrRole("id", "admin"), mergePatch(userPatchFields), gateway.ProxyHandler("user-service"))
+		userGroup.DELETE("/:id", approvalQueue.require(approvals.RuleUserDeletion, otherUserDeletion()), gateway.ProxyHandler("user-service"))
+		userGroup.GET("", gateway.ListProxyHandler("user-service", "users"))
+
+		// Avatar upload
+		userGroup.POST("/:id/avatar", middleware.RequireSelfOrRole("id", "admin"), gateway.ProxyHandler("user-service"))
+		userGroup.POST("/:id/export", middleware.RequireSelfOrRole("id", "admin"), gateway.ProxyHandler("user-service"))
+
+		// Address book
+		userGroup.POST("/:id/addresses", middleware.RequireSelfOrRole("id", "admin"), gateway.ProxyHandler("user-service"))
+		userGroup.GET("/:id/addresses", middleware.RequireSelfOrRole("id", "admin"), gateway.ListProxyHandler("user-service", "addresses"))
+		userGroup.GET("/:id/addresses/:address_id", middleware.RequireSelfOrRole("id", "admin"), gateway.ProxyHandler("user-service"))
+		userGroup.PUT("/:id/addresses/:address_id", middleware.RequireSelfOrRole("id", "admin"), gateway.ProxyHandler("user-service"))
+		userGroup.DELETE("/:id/addresses/:address_id", middleware.RequireSelfOrRole("id", "admin"), gateway.ProxyHandler("user-service"))
+
+		// Organizations a user belongs to
+		userGroup.GET("/:id/organizations", gateway.ListProxyHandler("user-service", "organizations"))
+
+		// Partner webhooks
+		webhookGroup := userGroup.Group("/:id/webhooks")
+		webhookGroup.Use(middleware.RequireRole(cfg.JWTSecret, "partner", "admin"))
+		webhookGroup.Use(middleware.RequireSelfOrRole("id", "admin"))
+		{
+			webhookGroup.POST("", gateway.ProxyHandler("user-service"))
+			webhookGroup.GET("", gateway.ListProxyHandler("user-service", "endpoints"))
+			webhookGroup.DELETE("/:endpoint_id", gateway.ProxyHandler("user-service"))
+			webhookGroup.GET("/:endpoint_id/deliveries", gateway.ListProxyHandler("user-service", "deliveries"))
+			webhookGroup.POST("/:endpoint_id/deliveries/:delivery_id/replay", gateway.ProxyHandler("user-service"))
+			webhookGroup.POST("/:endpoint_id/keys", gateway.ProxyHandler("user-service"))
+			webhookGroup.GET("/:endpoint_id/keys", gateway.ListProxyHandler("user-service", "keys"))
+			webhookGroup.DELETE("/:endpoint_id/keys/:key_id", gateway.ProxyHandler("user-service"))
+		}
+	}
+}
+
+// setupRateLimiter returns a Redis-backed limiter shared by all gateway
+// instances, falling back to per-instance limits if Redis is unavailable
+func setupRateLimiter(cfg *Config) middleware.RateLimiter {
+	limiter, err := middleware.NewRedisRateLimiter(cfg.Redis)
+	if err != nil {
+		log.Printf("Redis unavailable, using in-memory rate limiting: %v", err)
+		memory := middleware.NewMemoryRateLimiter()
+		go memory.Start(context.Background(), time.Minute)
+		return memory
+	}
+	return limiter
+}
+
+// planLimitsCacheTTL is how long the gateway keeps a tenant's plan limits
+// before reading them again
+const planLimitsCacheTTL = 30 * time.Second
+
+// setupPlanLookup returns the plan limits published by the subscription
+// service, or nil if Redis is unavailable so tier limits apply
+func setupPlanLookup(cfg *Config) plans.Lookup {
+	store, err := plans.NewRedisStore(cfg.Redis)
+	if err != nil {
+		log.Printf("Redis unavailable, plan rate limits disabled: %v", err)
+		return nil
+	}
+	return plans.NewCachedLookup(store, planLimitsCacheTTL)
 }
 
-// serviceHealthHandler returns health status for a specific service
+// setupRevocations returns the token revocations recorded by user-service,
+// or nil if Redis is unavailable so revoked tokens are accepted until they
+// expire
+func setupRevocations(cfg *Config) revocation.Lookup {
+	store, err := revocation.NewRedisStore(cfg.Redis)
+	if err != nil {
+		log.Printf("Redis unavailable, revoked tokens are accepted until they expire: %v", err)
+		return nil
+	}
+	return store
+}
+
+// setupSoftLaunches returns the gates of soft launched routes, open to the
+// cohorts configured for each launch
+func setupSoftLaunches(cfg *Config) *middleware.SoftLaunches {
+	launches := make(map[string]middleware.SoftLaunch)
+	for launch, tenants := range cfg.SoftLaunches.Tenants {
+		settings := launches[launch]
+		settings.Tenants = tenants
+		launches[launch] = settings
+	}
+	for launch, users := range cfg.SoftLaunches.Users {
+		settings := launches[launch]
+		settings.Users = users
+		launches[launch] = settings
+	}
+	for launch, percent := range cfg.SoftLaunches.Percent {
+		settings := launches[launch]
+		settings.Percent = percent
+		launches[launch] = settings
+	}
+	for _, launch := range cfg.SoftLaunches.Visible {
+		launch = strings.TrimSpace(launch)
+		settings := launches[launch]
+		settings.Visible = true
+		launches[launch] = settings
+	}
+	return middleware.NewSoftLaunches(cfg.JWTSecret, launches)
+}
+
+// serviceHealthHandler checks the health of a single service
 func serviceHealthHandler(gateway *proxy.Gateway) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		serviceName := c.Param("service")
-		
-		// This would require exposing service health checking from the gateway
-		c.JSON(http.StatusOK, gin.H{
-			"service": serviceName,
-			"message": "Service health check endpoint - implementation needed",
-		})
+		health, err := gateway.CheckService(c.Param("service"))
+		if errors.Is(err, proxy.ErrUnknownService) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown service"})
+			return
+		}
+
+		status := http.StatusOK
+		if !health.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, health)
 	}
 }
 
@@ -293,12 +913,15 @@ func initTracer(serviceName string) (*tracesdk.TracerProvider, error) {
 
 	tp := tracesdk.NewTracerProvider(
 		tracesdk.WithBatcher(exp),
+		// Record business baggage, e.g. the tenant, on every span
+		tracesdk.WithSpanProcessor(instrumentation.NewBaggageSpanProcessor()),
 		tracesdk.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(serviceName),
+			buildinfo.ResourceAttributes(serviceName)...,
 		)),
 	)
 
 	otel.SetTracerProvider(tp)
+	instrumentation.SetupPropagation()
 	return tp, nil
 }
\ No newline at end of file
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/operations"
+)
+
+// operationHeartbeat is how often an idle operation stream sends a comment so
+// proxies keep the connection open
+const operationHeartbeat = 15 * time.Second
+
+// operationsAPI serves the status of long-running operations from the shared
+// operation store
+type operationsAPI struct {
+	store operations.Store
+}
+
+// setupOperations connects the operations API to Redis. If Redis is
+// unavailable the endpoints report the store as unavailable.
+func setupOperations(cfg *Config) *operationsAPI {
+	api := &operationsAPI{}
+
+	store, err := operations.NewRedisStore(cfg.Redis, cfg.Operations)
+	if err != nil {
+		log.Printf("Redis unavailable, operation status disabled: %v", err)
+		return api
+	}
+	api.store = store
+
+	return api
+}
+
+// load returns the operation named in the path, writing an error response if
+// it can't be read. Operations belong to the user they act for; other callers
+// are told it does not exist, unless they are admins.
+func (a *operationsAPI) load(c *gin.Context) (*operations.Operation, bool) {
+	if a.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Operation status is unavailable"})
+		return nil, false
+	}
+
+	op, err := a.store.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Operation status is unavailable"})
+		return nil, false
+	}
+	if op == nil || (c.GetString("role") != "admin" && op.UserID != c.GetString("user_id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Operation not found"})
+		return nil, false
+	}
+	return op, true
+}
+
+// getHandler returns an operation's current status
+func (a *operationsAPI) getHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op, ok := a.load(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"operation": op})
+	}
+}
+
+// eventsHandler streams an operation's status as server-sent "operation"
+// events, starting with the current status and ending once it is done
+func (a *operationsAPI) eventsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op, ok := a.load(c)
+		if !ok {
+			return
+		}
+
+		ctx := c.Request.Context()
+		var updates <-chan *operations.Operation
+		if !op.Done() {
+			var err error
+			if updates, err = a.store.Watch(ctx, op.ID); err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Operation status is unavailable"})
+				return
+			}
+			// Re-read so an update made before the watch began isn't missed
+			if current, err := a.store.Get(ctx, op.ID); err == nil && current != nil {
+				op = current
+			}
+		}
+
+		// The stream outlives the server's write timeout
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			log.Printf("Failed to lift write deadline for operation stream: %v", err)
+		}
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.SSEvent("operation", op)
+		c.Writer.Flush()
+		if op.Done() {
+			return
+		}
+
+		heartbeat := time.NewTicker(operationHeartbeat)
+		defer heartbeat.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case update, ok := <-updates:
+				if !ok {
+					return false
+				}
+				c.SSEvent("operation", update)
+				return !update.Done()
+			case <-heartbeat.C:
+				_, err := io.WriteString(w, ": keepalive\n\n")
+				return err == nil
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}
+}
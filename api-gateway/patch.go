@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mergePatchContentType is the media type of an RFC 7386 JSON merge patch
+const mergePatchContentType = "application/merge-patch+json"
+
+// maxPatchBodySize bounds the size of a merge patch
+const maxPatchBodySize = 1 << 20
+
+// userPatchFields are the user fields a merge patch may change, and whether
+// null may clear them. A user's status is changed by the admin suspend and
+// unsuspend routes, never by the user.
+var userPatchFields = map[string]bool{
+	"email":             false,
+	"username":          false,
+	"first_name":        true,
+	"last_name":         true,
+	"marketing_opt_out": false,
+	"locale":            true,
+	"time_zone":         true,
+}
+
+// productPatchFields are the product fields a merge patch may change, and
+// whether null may clear them
+var productPatchFields = map[string]bool{
+	"name":        false,
+	"description": true,
+	"price":       false,
+	"category":    false,
+	"brand":       false,
+	"sku":         false,
+	"images":      true,
+	"status":      false,
+}
+
+// mergePatch translates a JSON merge patch into the service's Update RPC.
+// The members of the patch become the update_mask, so only they change; a
+// member set to null is sent as its zero value, which clears it. The patched
+// resources have no nested objects, so the patch is flat.
+func mergePatch(fields map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if mediaType != mergePatchContentType && mediaType != "application/json" {
+			c.Header("Accept-Patch", mergePatchContentType)
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be " + mergePatchContentType})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPatchBodySize))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge patch", "message": err.Error()})
+			c.Abort()
+			return
+		}
+		var patch map[string]json.RawMessage
+		if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge patch", "message": "the patch must be a JSON object"})
+			c.Abort()
+			return
+		}
+		if len(patch) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge patch", "message": "the patch changes no fields"})
+			c.Abort()
+			return
+		}
+
+		paths := make([]string, 0, len(patch))
+		for name, value := range patch {
+			nullable, ok := fields[name]
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge patch", "message": fmt.Sprintf("field %q cannot be patched", name)})
+				c.Abort()
+				return
+			}
+			if string(bytes.TrimSpace(value)) == "null" {
+				if !nullable {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge patch", "message": fmt.Sprintf("field %q cannot be removed", name)})
+					c.Abort()
+					return
+				}
+				delete(patch, name)
+			}
+			paths = append(paths, fieldMaskPath(name))
+		}
+		sort.Strings(paths)
+
+		// The JSON form of a FieldMask is its lowerCamelCase paths joined by commas
+		patch["update_mask"], _ = json.Marshal(strings.Join(paths, ","))
+		rewritten, err := json.Marshal(patch)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge patch", "message": err.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(rewritten))
+		c.Request.ContentLength = int64(len(rewritten))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Next()
+	}
+}
+
+// rejectFields rejects request bodies that set any of fields, for fields a
+// route must not change even though its RPC accepts them
+func rejectFields(fields ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPatchBodySize))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "message": err.Error()})
+			c.Abort()
+			return
+		}
+		var members map[string]json.RawMessage
+		if json.Unmarshal(body, &members) == nil {
+			for _, name := range fields {
+				if _, ok := members[name]; ok {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "message": fmt.Sprintf("field %q cannot be changed here", name)})
+					c.Abort()
+					return
+				}
+			}
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+		c.Next()
+	}
+}
+
+// fieldMaskPath converts a snake_case field name to the lowerCamelCase form
+// used by FieldMask paths in JSON
+func fieldMaskPath(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
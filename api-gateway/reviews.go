@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/middleware"
+	"microservices-platform/pkg/proxy"
+	"microservices-platform/pkg/reviews"
+)
+
+// reviewsAPI serves the manual review queue to admins, runs the requests held
+// by approved items through the gateway's routes and announces resolutions
+// to the services that flagged the items
+type reviewsAPI struct {
+	store     reviews.Store
+	router    http.Handler
+	bus       events.EventBus
+	jwtSecret string
+	cfg       config.ReviewConfig
+}
+
+// setupReviews connects the review queue to Redis. If Redis is unavailable,
+// requests that need review are refused. Resolutions are published on bus
+// when it is connected.
+func setupReviews(cfg *Config, router http.Handler, bus events.EventBus) *reviewsAPI {
+	api := &reviewsAPI{router: router, bus: bus, jwtSecret: cfg.JWTSecret, cfg: cfg.Reviews}
+
+	store, err := reviews.NewRedisStore(cfg.Redis, cfg.Reviews)
+	if err != nil {
+		log.Printf("Redis unavailable, requests that need review are refused: %v", err)
+		return api
+	}
+	api.store = store
+
+	return api
+}
+
+// require holds the requests that rule flags for review
+func (r *reviewsAPI) require(kind, entityType string, rule middleware.ReviewRule) gin.HandlerFunc {
+	return middleware.RequireReview(r.jwtSecret, r.store, kind, entityType, "id", rule)
+}
+
+// refundOverThreshold flags refunds of more than the threshold. Full refunds,
+// which name no amount, are flagged too, since the gateway does not know the
+// payment's amount.
+func (r *reviewsAPI) refundOverThreshold() middleware.ReviewRule {
+	return func(c *gin.Context, body []byte) []string {
+		var req struct {
+			Amount float64 `json:"amount"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			// Let the payment service reject the malformed request
+			return nil
+		}
+		if req.Amount <= 0 {
+			return []string{"full refund"}
+		}
+		if req.Amount > r.cfg.RefundThreshold {
+			return []string{fmt.Sprintf("refund of %.2f is over the review threshold of %.2f", req.Amount, r.cfg.RefundThreshold)}
+		}
+		return nil
+	}
+}
+
+// available writes an error response if the queue is unavailable
+func (r *reviewsAPI) available(c *gin.Context) bool {
+	if r.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Review queue is unavailable"})
+		return false
+	}
+	return true
+}
+
+// listHandler lists items within retention, oldest first, optionally
+// filtered, e.g. ?status=open&kind=fraud_check&assignee=admin-id
+func (r *reviewsAPI) listHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !r.available(c) {
+			return
+		}
+
+		items, err := r.store.List(c.Request.Context(), reviews.Filter{
+			Status:   c.Query("status"),
+			Kind:     c.Query("kind"),
+			Assignee: c.Query("assignee"),
+		})
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Review queue is unavailable"})
+			return
+		}
+		if items == nil {
+			items = []*reviews.Item{}
+		}
+		proxy.WriteList(c, items, len(items))
+	}
+}
+
+// getHandler returns an item
+func (r *reviewsAPI) getHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !r.available(c) {
+			return
+		}
+
+		item, err := r.store.Get(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Review queue is unavailable"})
+			return
+		}
+		if item == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Review item not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"item": item})
+	}
+}
+
+// assignRequest names the admin to assign an item to
+type assignRequest struct {
+	Assignee *string `json:"assignee"`
+}
+
+// assignHandler assigns an item to the admin named in the body, to the
+// calling admin if none is named, or back to the open queue for an empty
+// assignee
+func (r *reviewsAPI) assignHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !r.available(c) {
+			return
+		}
+
+		var req assignRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+				return
+			}
+		}
+		adminID := c.GetString("user_id")
+		assignee := adminID
+		if req.Assignee != nil {
+			assignee = *req.Assignee
+		}
+
+		item, err := r.store.Update(c.Request.Context(), c.Param("id"), func(item *reviews.Item) error {
+			return item.Assign(assignee)
+		})
+		if !r.updated(c, err) {
+			return
+		}
+
+		log.Printf("AUDIT review assigned: item=%s kind=%s assignee=%q by=%s", item.ID, item.Kind, assignee, adminID)
+		c.JSON(http.StatusOK, gin.H{"item": item})
+	}
+}
+
+// resolveRequest resolves an item
+type resolveRequest struct {
+	Resolution string `json:"resolution" binding:"required"`
+	Note       string `json:"note"`
+}
+
+// resolveHandler approves or rejects an item. Approving an item that holds a
+// request runs it with the reviewing admin's credentials and records its
+// outcome. The resolution is published as review.resolved.
+func (r *reviewsAPI) resolveHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !r.available(c) {
+			return
+		}
+
+		var req resolveRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "resolution is required"})
+			return
+		}
+
+		adminID := c.GetString("user_id")
+		ctx := c.Request.Context()
+		item, err := r.store.Update(ctx, c.Param("id"), func(item *reviews.Item) error {
+			return item.Resolve(adminID, req.Resolution, req.Note)
+		})
+		if !r.updated(c, err) {
+			return
+		}
+
+		log.Printf("AUDIT review resolved: item=%s kind=%s entity=%s/%s resolution=%s by=%s note=%q",
+			item.ID, item.Kind, item.EntityType, item.EntityID, item.Resolution, adminID, item.Note)
+
+		if item.Resolution == reviews.ResolutionApprove && item.Request != nil {
+			item = r.runHeldRequest(c, item)
+		}
+		r.publishResolved(c, item)
+
+		c.JSON(http.StatusOK, gin.H{"item": item})
+	}
+}
+
+// runHeldRequest runs an approved item's request and records the response
+func (r *reviewsAPI) runHeldRequest(c *gin.Context, item *reviews.Item) *reviews.Item {
+	ctx := c.Request.Context()
+	held := item.Request
+
+	req, err := http.NewRequestWithContext(ctx, held.Method, held.Path, bytes.NewReader(held.Body))
+	if err != nil {
+		log.Printf("Invalid request held by review item %s: %v", item.ID, err)
+		return item
+	}
+	req.RemoteAddr = c.Request.RemoteAddr
+	req.Host = c.Request.Host
+	for _, name := range batchSharedHeaders {
+		if value := c.GetHeader(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	if held.ContentType != "" {
+		req.Header.Set("Content-Type", held.ContentType)
+	}
+
+	w := &batchResponseWriter{header: make(http.Header)}
+	r.router.ServeHTTP(w, req)
+
+	result := bytes.TrimSpace(w.body.Bytes())
+	if len(result) > 0 && !json.Valid(result) {
+		result, _ = json.Marshal(string(result))
+	}
+	recorded, err := r.store.Update(ctx, item.ID, func(current *reviews.Item) error {
+		current.ResultStatus = w.statusCode()
+		current.Result = result
+		return nil
+	})
+	if err != nil {
+		// The request ran; report its outcome even if it can't be recorded
+		log.Printf("Failed to record outcome of review item %s: %v", item.ID, err)
+		item.ResultStatus = w.statusCode()
+		item.Result = result
+		recorded = item
+	}
+
+	log.Printf("AUDIT reviewed request completed: item=%s method=%s path=%s result_status=%d",
+		recorded.ID, held.Method, held.Path, recorded.ResultStatus)
+	return recorded
+}
+
+// publishResolved tells the service that flagged an item how it was resolved
+func (r *reviewsAPI) publishResolved(c *gin.Context, item *reviews.Item) {
+	if r.bus == nil {
+		return
+	}
+	err := r.bus.Publish(c.Request.Context(), &events.Event{
+		Type:    events.ReviewResolved,
+		Source:  "api-gateway",
+		Subject: item.ID,
+		Data: map[string]interface{}{
+			"item_id":       item.ID,
+			"kind":          item.Kind,
+			"entity_type":   item.EntityType,
+			"entity_id":     item.EntityID,
+			"source":        item.Source,
+			"resolution":    item.Resolution,
+			"resolved_by":   item.ResolvedBy,
+			"note":          item.Note,
+			"result_status": item.ResultStatus,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to publish resolution of review item %s: %v", item.ID, err)
+	}
+}
+
+// updated writes an error response if updating an item failed
+func (r *reviewsAPI) updated(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, reviews.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review item not found"})
+	case errors.Is(err, reviews.ErrInvalidResolution):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "resolution must be approve or reject"})
+	case errors.Is(err, reviews.ErrResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": "Review item is already resolved"})
+	case errors.Is(err, reviews.ErrAssignedElsewhere):
+		c.JSON(http.StatusConflict, gin.H{"error": "Review item is assigned to another admin"})
+	case errors.Is(err, reviews.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Review item changed, try again"})
+	default:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Review queue is unavailable"})
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"microservices-platform/pkg/middleware"
+	"microservices-platform/pkg/proxy"
+)
+
+const testSecret = "test-secret"
+
+// TestUserRoutesAreLimitedToSelfOrAdmin checks every route acting on a
+// user's own resources refuses other users, and lets the user and admins
+// through to user-service
+func TestUserRoutesAreLimitedToSelfOrAdmin(t *testing.T) {
+	var reached atomic.Int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	gateway := proxy.NewGateway()
+	if err := gateway.RegisterService(&proxy.ServiceConfig{
+		Name:      "user-service",
+		Instances: []string{backend.URL},
+		Timeout:   5 * time.Second,
+	}); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// Without a store the approval queue refuses the requests needing approval
+	approvalQueue := &approvalsAPI{router: router, jwtSecret: testSecret}
+	setupUserRoutes(router.Group("/api/v1", middleware.AuthMiddleware(testSecret)), gateway, &Config{JWTSecret: testSecret}, approvalQueue)
+
+	routes := []struct{ method, path string }{
+		{http.MethodPut, "/users/%s"},
+		{http.MethodPatch, "/users/%s"},
+		{http.MethodPost, "/users/%s/avatar"},
+		{http.MethodPost, "/users/%s/export"},
+		{http.MethodPost, "/users/%s/addresses"},
+		{http.MethodGet, "/users/%s/addresses"},
+		{http.MethodGet, "/users/%s/addresses/address-1"},
+		{http.MethodPut, "/users/%s/addresses/address-1"},
+		{http.MethodDelete, "/users/%s/addresses/address-1"},
+		{http.MethodPost, "/users/%s/webhooks"},
+		{http.MethodGet, "/users/%s/webhooks"},
+		{http.MethodDelete, "/users/%s/webhooks/endpoint-1"},
+		{http.MethodGet, "/users/%s/webhooks/endpoint-1/deliveries"},
+		{http.MethodPost, "/users/%s/webhooks/endpoint-1/deliveries/delivery-1/replay"},
+		{http.MethodPost, "/users/%s/webhooks/endpoint-1/keys"},
+		{http.MethodGet, "/users/%s/webhooks/endpoint-1/keys"},
+		{http.MethodDelete, "/users/%s/webhooks/endpoint-1/keys/key-1"},
+	}
+	callers := []struct {
+		name, userID, role string
+		allowed            bool
+	}{
+		{"self", "partner-1", "partner", true},
+		{"other user", "partner-2", "partner", false},
+		{"admin", "admin-1", "admin", true},
+	}
+	for _, route := range routes {
+		path := "/api/v1" + strings.Replace(route.path, "%s", "partner-1", 1)
+		for _, caller := range callers {
+			t.Run(route.method+" "+path+" as "+caller.name, func(t *testing.T) {
+				before := reached.Load()
+				req := httptest.NewRequest(route.method, path, strings.NewReader(`{"first_name":"Ada"}`))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Authorization", bearer(t, caller.userID, caller.role))
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				forwarded := reached.Load() > before
+				if caller.allowed && (rec.Code != http.StatusOK || !forwarded) {
+					t.Fatalf("status %d, forwarded %v; want the request forwarded", rec.Code, forwarded)
+				}
+				if !caller.allowed && (rec.Code != http.StatusForbidden || forwarded) {
+					t.Fatalf("status %d, forwarded %v; want %d", rec.Code, forwarded, http.StatusForbidden)
+				}
+			})
+		}
+	}
+}
+
+// bearer returns an Authorization header for a user holding role
+func bearer(t *testing.T, userID, role string) string {
+	t.Helper()
+	claims := &middleware.Claims{
+		UserID: userID,
+		Role:   role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return "Bearer " + token
+}
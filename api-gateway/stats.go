@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/stats"
+)
+
+// maxStatsDays caps how many days of counters the dashboard can request
+const maxStatsDays = 90
+
+// maxTrendPoints caps how many intervals a trend request can span
+const maxTrendPoints = 1000
+
+// statsAPI serves the admin dashboard's precomputed counters and their
+// long-term trends
+type statsAPI struct {
+	counters *stats.Counters
+	rollups  *stats.RollupStore
+}
+
+// setupStats subscribes the dashboard counters to order and user events on
+// bus. Every gateway instance receives each event, so events are counted
+// once across instances through the idempotency store. Without Redis there
+// are no counters.
+func setupStats(cfg *Config, bus events.EventBus) *statsAPI {
+	api := &statsAPI{}
+	if bus == nil {
+		log.Println("Redis unavailable, dashboard stats are disabled")
+		return api
+	}
+	counters, err := stats.NewRedisCounters(cfg.Redis)
+	if err != nil {
+		log.Printf("Redis unavailable, dashboard stats are disabled: %v", err)
+		return api
+	}
+	idempotency, err := events.NewRedisIdempotencyStore(cfg.Redis)
+	if err != nil {
+		log.Printf("Redis unavailable, dashboard stats are disabled: %v", err)
+		return api
+	}
+
+	handlers := map[events.EventType]events.EventHandler{
+		events.OrderCreated:     stats.HandleOrderCreated(counters),
+		events.UserCreated:      stats.HandleUserActivity(counters),
+		events.PaymentProcessed: stats.HandlePayment(counters),
+		events.PaymentFailed:    stats.HandlePayment(counters),
+	}
+	for eventType, handler := range handlers {
+		consumer := "api-gateway:stats:" + string(eventType)
+		if err := bus.Subscribe(eventType, events.Idempotent(idempotency, consumer, events.DefaultIdempotencyTTL, handler)); err != nil {
+			log.Printf("Failed to subscribe to %s, dashboard stats are disabled: %v", eventType, err)
+			return api
+		}
+	}
+	api.counters = counters
+
+	if cfg.Stats.DatabaseURL == "" {
+		log.Println("STATS_DATABASE_URL not set, business metric trends are disabled")
+		return api
+	}
+	rollups, err := stats.OpenRollupStore(cfg.Stats.DatabaseURL)
+	if err == nil {
+		err = rollups.Migrate()
+	}
+	if err != nil {
+		log.Printf("Stats database unavailable, business metric trends are disabled: %v", err)
+		return api
+	}
+	api.rollups = rollups
+	roller := stats.NewRoller(counters, rollups, stats.RollupSettings{
+		Interval: cfg.Stats.RollupInterval,
+		Lookback: cfg.Stats.RollupLookback,
+	})
+	if cfg.Stats.AnomaliesEnabled {
+		roller.DetectAnomalies(stats.NewAnomalyDetector("api-gateway", rollups, counters, bus, stats.AnomalySettings{
+			OrderDropRatio:       cfg.Stats.OrderDropRatio,
+			MinBaselineOrders:    int64(cfg.Stats.MinBaselineOrders),
+			PaymentFailureRate:   cfg.Stats.PaymentFailureRate,
+			PaymentFailureFactor: cfg.Stats.PaymentFailureFactor,
+			MinPayments:          int64(cfg.Stats.MinPayments),
+			SuppressionWindow:    cfg.Stats.AnomalySuppression,
+		}))
+	}
+	go roller.Start(context.Background())
+	return api
+}
+
+// statsHandler reports the dashboard counters of today and the days before
+// it, most recent first
+func (a *statsAPI) statsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.counters == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Stats are unavailable"})
+			return
+		}
+
+		days := 1
+		if value := c.Query("days"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > maxStatsDays {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 90"})
+				return
+			}
+			days = parsed
+		}
+
+		counters, err := a.counters.Days(c.Request.Context(), time.Now(), days)
+		if err != nil {
+			log.Printf("Failed to read dashboard stats: %v", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Stats are unavailable"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"today": counters[0], "days": counters})
+	}
+}
+
+// trendsHandler reports a business metric per hour or day from the hourly
+// snapshots, e.g. ?metric=orders&interval=day&from=2024-01-01&to=2024-02-01.
+// from is inclusive and to exclusive; both take a date or an RFC 3339 time.
+func (a *statsAPI) trendsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.rollups == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Trends are unavailable"})
+			return
+		}
+
+		metric := c.Query("metric")
+		switch metric {
+		case stats.TrendOrders, stats.TrendRevenue, stats.TrendPayments, stats.TrendPaymentSuccessRate:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "metric must be orders, revenue, payments or payment_success_rate"})
+			return
+		}
+		interval := c.DefaultQuery("interval", stats.IntervalDay)
+		step := 24 * time.Hour
+		switch interval {
+		case stats.IntervalDay:
+		case stats.IntervalHour:
+			step = time.Hour
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be hour or day"})
+			return
+		}
+
+		to, err := parseTrendTime(c.Query("to"), time.Now().UTC())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to: " + err.Error()})
+			return
+		}
+		from, err := parseTrendTime(c.Query("from"), to.Add(-30*step))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from: " + err.Error()})
+			return
+		}
+		if !from.Before(to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+			return
+		}
+		if to.Sub(from) > maxTrendPoints*step {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A trend spans at most %d intervals", maxTrendPoints)})
+			return
+		}
+
+		points, err := a.rollups.Trend(c.Request.Context(), metric, interval, from, to)
+		if err != nil {
+			log.Printf("Failed to read %s trend: %v", metric, err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Trends are unavailable"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"metric":   metric,
+			"interval": interval,
+			"from":     from,
+			"to":       to,
+			"points":   points,
+		})
+	}
+}
+
+// parseTrendTime parses a date or an RFC 3339 time, or returns fallback for
+// an empty value
+func parseTrendTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if day, err := time.Parse("2006-01-02", value); err == nil {
+		return day, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a date or an RFC 3339 time")
+	}
+	return t.UTC(), nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/middleware"
+)
+
+// maxUsageHistoryHours caps how much hourly history a caller can request
+const maxUsageHistoryHours = 168
+
+// usageHandler reports the caller's rate limit consumption, current quota
+// window and hourly request history so API consumers can self-monitor
+func usageHandler(limiter middleware.RateLimiter, overrides *middleware.RateLimitOverrides, cfg *Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tier := c.GetString("rate_limit_tier")
+		key := c.GetString("rate_limit_key")
+		if key == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Usage information is unavailable"})
+			return
+		}
+
+		hours := 24
+		if value := c.Query("hours"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > maxUsageHistoryHours {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "hours must be between 1 and 168"})
+				return
+			}
+			hours = parsed
+		}
+
+		ctx := c.Request.Context()
+		window := c.GetDuration("rate_limit_window")
+		if window == 0 {
+			window = overrides.Window(cfg.RateLimit.Window)
+		}
+		limit := c.GetInt("rate_limit_limit")
+		if limit == 0 {
+			limit, _ = overrides.Limit(cfg.RateLimit.Tiers, tier)
+		}
+
+		used, err := limiter.Current(ctx, key, window)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Usage information is unavailable"})
+			return
+		}
+
+		since := time.Now().Add(-time.Duration(hours-1) * time.Hour)
+		history, err := limiter.History(ctx, key, since)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Usage information is unavailable"})
+			return
+		}
+
+		var total int64
+		for _, bucket := range history {
+			total += bucket.Requests
+		}
+
+		remaining := int64(limit) - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		windowStart := time.Now().Truncate(window)
+
+		c.JSON(http.StatusOK, gin.H{
+			"tier": tier,
+			"current_window": gin.H{
+				"limit":          limit,
+				"used":           used,
+				"remaining":      remaining,
+				"window_seconds": int(window.Seconds()),
+				"started_at":     windowStart.UTC(),
+				"resets_at":      windowStart.Add(window).UTC(),
+			},
+			"tiers": overrides.Tiers(cfg.RateLimit.Tiers),
+			"history": gin.H{
+				"hours":          hours,
+				"total_requests": total,
+				"buckets":        history,
+			},
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Command event-replicator forwards events published in one region to
+// another region's Redis, so read models in a passive region stay warm.
+//
+// Usage:
+//
+//	REGION=eu-west-1 REPLICATION_TARGET_REGION=us-east-1 \
+//	REPLICATION_TARGET_REDIS_URL=redis.us-east-1:6379 \
+//	REPLICATION_EVENT_TYPES=user.created,user.updated,order.created \
+//		go run ./cmd/event-replicator [-metrics :9090]
+//
+// The local Redis is read from the usual REDIS_* settings. Run one
+// replicator per direction; events replicated in from another region are
+// never forwarded again.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/metrics"
+)
+
+func main() {
+	metricsAddr := flag.String("metrics", ":9090", "address serving Prometheus metrics, empty to disable")
+	flag.Parse()
+
+	log.SetPrefix("event-replicator: ")
+
+	cfg := config.LoadEventReplicationConfig()
+	if cfg.Target.URL == "" && len(cfg.Target.Addrs) == 0 {
+		log.Fatal("REPLICATION_TARGET_REDIS_URL or REPLICATION_TARGET_REDIS_ADDRS must name the target region's Redis")
+	}
+	types := make([]events.EventType, 0, len(cfg.Types))
+	for _, eventType := range cfg.Types {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			types = append(types, events.EventType(eventType))
+		}
+	}
+
+	sourceCfg := config.LoadEventBusConfig("event-replicator")
+	sourceCfg.Region = cfg.Region
+	source, err := events.NewRedisEventBus(config.LoadRedisConfig(), sourceCfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to the local Redis: %v", err)
+	}
+	targetCfg := sourceCfg
+	targetCfg.Region = cfg.TargetRegion
+	target, err := events.NewRedisEventBus(cfg.Target, targetCfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to the Redis of %s: %v", cfg.TargetRegion, err)
+	}
+
+	replicator, err := events.NewReplicator(source, target, cfg.Region, cfg.TargetRegion, types)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *metricsAddr != "" {
+		metrics.SetRegion(cfg.Region)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		go func() {
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				log.Printf("Metrics server stopped: %v", err)
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := replicator.Start(ctx); err != nil {
+		log.Fatalf("Failed to start replication: %v", err)
+	}
+	<-ctx.Done()
+
+	// Events already received are forwarded before exiting
+	if err := source.Stop(); err != nil {
+		log.Printf("Failed to stop the event bus: %v", err)
+	}
+}
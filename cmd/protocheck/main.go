@@ -0,0 +1,53 @@
+// Command protocheck lints the platform's proto definitions and checks them
+// for breaking changes against another git revision.
+//
+// Usage:
+//
+//	go run ./cmd/protocheck [-dir proto] [-against origin/main] [-lint=false]
+//
+// It exits with status 1 when it reports any violation.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"microservices-platform/pkg/protocheck"
+)
+
+func main() {
+	dir := flag.String("dir", "proto", "directory holding the .proto files")
+	against := flag.String("against", "", "git revision to check for breaking changes against, e.g. origin/main")
+	lint := flag.Bool("lint", true, "lint the proto files")
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("protocheck: ")
+
+	files, err := protocheck.LoadDir(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var findings []protocheck.Finding
+	if *lint {
+		findings = append(findings, protocheck.Lint(files)...)
+	}
+	if *against != "" {
+		previous, err := protocheck.LoadGitRef(*against, *dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		findings = append(findings, protocheck.Breaking(previous, files)...)
+	}
+
+	for _, finding := range findings {
+		fmt.Println(finding)
+	}
+	if len(findings) > 0 {
+		log.Printf("%d violation(s) in %d file(s)", len(findings), len(files))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,260 @@
+// Package app runs a service through its lifecycle, so every service starts
+// and stops the same way:
+//
+//  1. init connects to dependencies
+//  2. migrate brings the database schema up to date
+//  3. serve runs the servers and background workers until the process is
+//     signalled or one of them fails
+//  4. drain stops taking new work and finishes in-flight work
+//  5. shutdown releases resources, such as the event bus, the database and
+//     the tracer
+//
+// Init, migrate and drain hooks run in the order they are registered.
+// Shutdown hooks run in reverse order, like deferred calls, so a resource is
+// released after everything registered later that uses it.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Phase is a lifecycle phase
+type Phase string
+
+// Lifecycle phases
+const (
+	PhaseInit     Phase = "init"
+	PhaseMigrate  Phase = "migrate"
+	PhaseServe    Phase = "serve"
+	PhaseDrain    Phase = "drain"
+	PhaseShutdown Phase = "shutdown"
+)
+
+// Hook is a step of a lifecycle phase
+type Hook func(ctx context.Context) error
+
+// Config holds lifecycle settings
+type Config struct {
+	// ShutdownTimeout bounds stopping the serve hooks and running the
+	// shutdown hooks. Drain hooks bound themselves, e.g. with
+	// GRPC_SHUTDOWN_TIMEOUT.
+	ShutdownTimeout time.Duration
+	// ObservabilityAddr is where ServeObservability listens. Keep it
+	// internal: it serves metrics, health, admin and debug endpoints.
+	// HEALTH_ADDR, its name when it only served health, is still read.
+	ObservabilityAddr string
+}
+
+// LoadConfig loads lifecycle settings from environment variables
+func LoadConfig() Config {
+	return Config{
+		ShutdownTimeout:   getDurationEnv("APP_SHUTDOWN_TIMEOUT", 30*time.Second),
+		ObservabilityAddr: getEnv("OBSERVABILITY_ADDR", getEnv("HEALTH_ADDR", ":8090")),
+	}
+}
+
+type namedHook struct {
+	name string
+	fn   Hook
+}
+
+type route struct {
+	pattern string
+	handler http.Handler
+}
+
+// App is a service's lifecycle
+type App struct {
+	name string
+	cfg  Config
+
+	mu     sync.Mutex
+	hooks  map[Phase][]namedHook
+	routes []route
+
+	signals chan os.Signal
+}
+
+// New creates the lifecycle of service name
+func New(name string, cfg Config) *App {
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = 30 * time.Second
+	}
+	if cfg.ObservabilityAddr == "" {
+		cfg.ObservabilityAddr = ":8090"
+	}
+	return &App{
+		name:    name,
+		cfg:     cfg,
+		hooks:   make(map[Phase][]namedHook),
+		signals: make(chan os.Signal, 1),
+	}
+}
+
+// OnInit adds a hook connecting to a dependency
+func (a *App) OnInit(name string, fn Hook) {
+	a.add(PhaseInit, name, fn)
+}
+
+// OnMigrate adds a hook migrating a database
+func (a *App) OnMigrate(name string, fn Hook) {
+	a.add(PhaseMigrate, name, fn)
+}
+
+// OnServe adds a server or background worker. It runs until its context is
+// cancelled, which happens once the drain hooks have run, or its server is
+// stopped by a drain hook. A serve hook returning an error before shutdown
+// begins stops the service; one returning nil just ends.
+func (a *App) OnServe(name string, fn Hook) {
+	a.add(PhaseServe, name, fn)
+}
+
+// OnDrain adds a hook that stops a server taking new work and waits for its
+// in-flight work
+func (a *App) OnDrain(name string, fn Hook) {
+	a.add(PhaseDrain, name, fn)
+}
+
+// OnShutdown adds a hook releasing a resource
+func (a *App) OnShutdown(name string, fn Hook) {
+	a.add(PhaseShutdown, name, fn)
+}
+
+func (a *App) add(phase Phase, name string, fn Hook) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.hooks[phase] = append(a.hooks[phase], namedHook{name: name, fn: fn})
+}
+
+func (a *App) phase(phase Phase) []namedHook {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]namedHook(nil), a.hooks[phase]...)
+}
+
+// Start runs the init and migrate hooks. If one fails, the shutdown hooks
+// registered so far are run and its error is returned.
+func (a *App) Start(ctx context.Context) error {
+	for _, phase := range []Phase{PhaseInit, PhaseMigrate} {
+		for _, hook := range a.phase(phase) {
+			if err := hook.fn(ctx); err != nil {
+				err = fmt.Errorf("%s %s: %w", phase, hook.name, err)
+				a.shutdown()
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Run runs the serve hooks until the process receives SIGINT or SIGTERM or a
+// serve hook fails, then drains and shuts the service down. It returns the
+// serve hook's error, if any.
+func (a *App) Run() error {
+	signal.Notify(a.signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(a.signals)
+
+	serveCtx, stopServing := context.WithCancel(context.Background())
+	defer stopServing()
+
+	serving := a.phase(PhaseServe)
+	failed := make(chan error, len(serving))
+	var wg sync.WaitGroup
+	// Closed once shutdown begins, after which serve hooks' errors are
+	// just logged
+	stopped := make(chan struct{})
+	for _, hook := range serving {
+		hook := hook
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := hook.fn(serveCtx); err != nil {
+				select {
+				case <-stopped:
+					log.Printf("%s stopped: %v", hook.name, err)
+				default:
+					failed <- fmt.Errorf("serve %s: %w", hook.name, err)
+				}
+			}
+		}()
+	}
+
+	var runErr error
+	select {
+	case sig := <-a.signals:
+		log.Printf("Shutting down %s on %s...", a.name, sig)
+	case runErr = <-failed:
+		log.Printf("Shutting down %s: %v", a.name, runErr)
+	}
+	close(stopped)
+
+	for _, hook := range a.phase(PhaseDrain) {
+		if err := hook.fn(context.Background()); err != nil {
+			log.Printf("Failed to drain %s: %v", hook.name, err)
+		}
+	}
+
+	// Background workers stop before the resources they use are released
+	stopServing()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(a.cfg.ShutdownTimeout):
+		log.Printf("WARNING: %s serve hooks still running after %s", a.name, a.cfg.ShutdownTimeout)
+	}
+
+	a.shutdown()
+	log.Printf("%s stopped", a.name)
+	return runErr
+}
+
+// Stop makes Run shut the service down as if it was signalled
+func (a *App) Stop() {
+	select {
+	case a.signals <- syscall.SIGTERM:
+	default:
+	}
+}
+
+// shutdown runs the shutdown hooks in reverse order within the shutdown
+// timeout
+func (a *App) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancel()
+
+	hooks := a.phase(PhaseShutdown)
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].fn(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Failed to shut down %s: %v", hooks[i].name, err)
+		}
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// MetricsPath is where the observability server serves Prometheus metrics
+const MetricsPath = "/metrics"
+
+// Handle serves an operational endpoint, such as health, build info or an
+// admin endpoint, on the observability server. It must be called before
+// ServeObservability.
+func (a *App) Handle(pattern string, handler http.Handler) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.routes = append(a.routes, route{pattern: pattern, handler: handler})
+}
+
+// ServeObservability starts the observability server on ObservabilityAddr,
+// serving Prometheus metrics and the endpoints added with Handle. It starts
+// immediately, so orchestrators can observe a service while it initializes,
+// and stays up while the service drains. It is stopped during shutdown,
+// before the resources registered ahead of it are released.
+func (a *App) ServeObservability() error {
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, metrics.Handler())
+	a.mu.Lock()
+	for _, r := range a.routes {
+		mux.Handle(r.pattern, r.handler)
+	}
+	a.mu.Unlock()
+
+	lis, err := net.Listen("tcp", a.cfg.ObservabilityAddr)
+	if err != nil {
+		return fmt.Errorf("observability server: %w", err)
+	}
+	server := &http.Server{Handler: mux}
+	go func() {
+		log.Printf("Observability server starting on %s", a.cfg.ObservabilityAddr)
+		if err := server.Serve(lis); err != nil && err != http.ErrServerClosed {
+			log.Printf("Observability server stopped: %v", err)
+		}
+	}()
+
+	a.OnShutdown("observability", func(ctx context.Context) error {
+		return server.Shutdown(ctx)
+	})
+	return nil
+}
@@ -0,0 +1,303 @@
+// Package approvals holds destructive admin requests until a second admin
+// approves them. The gateway answers such a request with 202 Accepted and a
+// pending action; once another admin approves it, the gateway runs the
+// original request and records its outcome. Actions are kept in Redis so
+// every gateway instance sees the same queue.
+package approvals
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/redisclient"
+)
+
+// Action statuses. Approved actions are running; they end up succeeded or
+// failed by the response status of the request they held.
+const (
+	StatusPending   = "pending"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusExpired   = "expired"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Rules that hold requests for approval
+const (
+	RuleRefundOverThreshold = "refund_over_threshold"
+	RuleBulkDelete          = "bulk_delete"
+	RuleUserDeletion        = "user_deletion"
+)
+
+// Errors returned when updating actions
+var (
+	ErrNotFound = errors.New("action not found")
+	// ErrNotPending is returned when deciding an action that was already
+	// decided or has expired
+	ErrNotPending = errors.New("action is no longer pending")
+)
+
+// Action is an admin request held for approval
+type Action struct {
+	ID string `json:"id"`
+	// Rule is why the request needs approval
+	Rule        string          `json:"rule"`
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	RequestedBy string          `json:"requested_by"`
+	Status      string          `json:"status"`
+	// DecidedBy is the admin who approved or rejected the action
+	DecidedBy string `json:"decided_by,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	// ResultStatus and Result are the response to the request once run
+	ResultStatus int             `json:"result_status,omitempty"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	ExpiresAt    time.Time       `json:"expires_at"`
+	DecidedAt    *time.Time      `json:"decided_at,omitempty"`
+	CompletedAt  *time.Time      `json:"completed_at,omitempty"`
+}
+
+// New creates a pending action that expires after ttl
+func New(rule, method, path, contentType string, body json.RawMessage, requestedBy string, ttl time.Duration) *Action {
+	now := time.Now().UTC()
+	return &Action{
+		ID:          generateActionID(),
+		Rule:        rule,
+		Method:      method,
+		Path:        path,
+		ContentType: contentType,
+		Body:        body,
+		RequestedBy: requestedBy,
+		Status:      StatusPending,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+}
+
+// Expire marks a pending action past its expiry as expired, reporting
+// whether it did
+func (a *Action) Expire(now time.Time) bool {
+	if a.Status != StatusPending || now.Before(a.ExpiresAt) {
+		return false
+	}
+	a.Status = StatusExpired
+	return true
+}
+
+// Decide records an admin's approval or rejection of a pending action
+func (a *Action) Decide(status, adminID, reason string) error {
+	now := time.Now().UTC()
+	if a.Expire(now) || a.Status != StatusPending {
+		return ErrNotPending
+	}
+	a.Status = status
+	a.DecidedBy = adminID
+	a.Reason = reason
+	a.DecidedAt = &now
+	return nil
+}
+
+// Complete records the response to an approved action's request
+func (a *Action) Complete(statusCode int, result json.RawMessage) {
+	now := time.Now().UTC()
+	a.Status = StatusSucceeded
+	if statusCode < 200 || statusCode > 299 {
+		a.Status = StatusFailed
+	}
+	a.ResultStatus = statusCode
+	a.Result = result
+	a.CompletedAt = &now
+}
+
+// generateActionID generates a random action ID
+func generateActionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("act_%d", time.Now().UnixNano())
+	}
+	return "act_" + hex.EncodeToString(b)
+}
+
+type approvedKey struct{}
+
+// WithApproval marks a context as running an approved action, so the request
+// it carries is not held for approval again
+func WithApproval(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, approvedKey{}, id)
+}
+
+// Approved returns the ID of the approved action a context runs, if any
+func Approved(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(approvedKey{}).(string)
+	return id, ok
+}
+
+// Store keeps actions
+type Store interface {
+	// Create records a new action
+	Create(ctx context.Context, action *Action) error
+	// Get returns an action, or nil if it is unknown or past retention
+	Get(ctx context.Context, id string) (*Action, error)
+	// List returns the actions within retention, newest first
+	List(ctx context.Context) ([]*Action, error)
+	// Update applies fn to the current action and saves it, atomically, so
+	// that two admins cannot both approve an action. An error from fn is
+	// returned without saving.
+	Update(ctx context.Context, id string, fn func(*Action) error) (*Action, error)
+}
+
+// RedisStore implements Store in Redis
+type RedisStore struct {
+	client    redis.UniversalClient
+	retention time.Duration
+}
+
+// NewRedisStore creates a new Redis-backed action store
+func NewRedisStore(cfg config.RedisConfig, approvalCfg config.ApprovalConfig) (*RedisStore, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisStore{client: client, retention: approvalCfg.Retention}, nil
+}
+
+// actionIndexKey is a sorted set of action IDs scored by creation time
+const actionIndexKey = "approvals:index"
+
+func actionKey(id string) string {
+	return fmt.Sprintf("approvals:id:%s", id)
+}
+
+// Create records an action and adds it to the index
+func (s *RedisStore) Create(ctx context.Context, action *Action) error {
+	data, err := json.Marshal(action)
+	if err != nil {
+		return fmt.Errorf("failed to marshal action: %v", err)
+	}
+
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, actionKey(action.ID), data, s.retention)
+		pipe.ZAdd(ctx, actionIndexKey, &redis.Z{Score: float64(action.CreatedAt.Unix()), Member: action.ID})
+		return nil
+	})
+	return err
+}
+
+// Get returns an action
+func (s *RedisStore) Get(ctx context.Context, id string) (*Action, error) {
+	data, err := s.client.Get(ctx, actionKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalAction(data)
+}
+
+// List returns the actions within retention, pruning the index of expired
+// ones
+func (s *RedisStore) List(ctx context.Context) ([]*Action, error) {
+	cutoff := time.Now().Add(-s.retention).Unix()
+	if err := s.client.ZRemRangeByScore(ctx, actionIndexKey, "-inf", fmt.Sprintf("(%d", cutoff)).Err(); err != nil {
+		return nil, err
+	}
+
+	ids, err := s.client.ZRevRange(ctx, actionIndexKey, 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	// Individual GETs rather than MGET, since the keys may live on different
+	// cluster nodes
+	cmds := make([]*redis.StringCmd, len(ids))
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.Get(ctx, actionKey(id))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	actions := make([]*Action, 0, len(cmds))
+	for _, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		action, err := unmarshalAction(data)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// Update applies fn to an action inside a WATCH transaction
+func (s *RedisStore) Update(ctx context.Context, id string, fn func(*Action) error) (*Action, error) {
+	var updated *Action
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, actionKey(id)).Bytes()
+		if err == redis.Nil {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		action, err := unmarshalAction(data)
+		if err != nil {
+			return err
+		}
+		if err := fn(action); err != nil {
+			return err
+		}
+
+		data, err = json.Marshal(action)
+		if err != nil {
+			return fmt.Errorf("failed to marshal action: %v", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, actionKey(id), data, s.retention)
+			return nil
+		})
+		updated = action
+		return err
+	}, actionKey(id))
+	if err == redis.TxFailedErr {
+		// Another admin changed the action between the read and the write,
+		// which only deciding it does
+		return nil, ErrNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func unmarshalAction(data []byte) (*Action, error) {
+	var action Action
+	if err := json.Unmarshal(data, &action); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal action: %v", err)
+	}
+	return &action, nil
+}
@@ -0,0 +1,572 @@
+// Package backup takes logical backups of service databases and of the event
+// store into object storage, and restores them.
+//
+// A service's database is read in one repeatable read transaction, so its
+// backup is a consistent snapshot even while the service keeps writing. Rows
+// are stored as gzipped NDJSON next to a manifest describing the schema they
+// were read from. Before restoring, the manifest is checked against the
+// schema of the target database: every table and column in the backup must
+// still exist with the same type. Columns and tables added since are left to
+// their defaults.
+//
+// A backup is laid out in its bucket as:
+//
+//	<id>/backup.json                  the Index, written last
+//	<id>/<service>/manifest.json      the service's Manifest
+//	<id>/<service>/data.ndjson.gz     one Record per row
+//	<id>/events.ndjson.gz             the stored events, one per line
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/storage"
+)
+
+// Format is the version of the backup layout written by this build
+const Format = 1
+
+// restoreBatchSize is how many rows are inserted per statement
+const restoreBatchSize = 500
+
+// Errors returned when restoring
+var (
+	// ErrIncompatibleSchema is returned when the target database lacks
+	// tables or columns of the backup, or has them with another type
+	ErrIncompatibleSchema = errors.New("backup schema is incompatible with the database")
+	// ErrNotEmpty is returned when restoring into tables that hold rows
+	ErrNotEmpty = errors.New("database tables are not empty")
+)
+
+// Column describes a table column
+type Column struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+// Table describes a backed up table
+type Table struct {
+	Name    string   `json:"name"`
+	Columns []Column `json:"columns"`
+	Rows    int      `json:"rows"`
+}
+
+// Manifest describes the backup of a service's database
+type Manifest struct {
+	Format  int       `json:"format"`
+	ID      string    `json:"id"`
+	Service string    `json:"service"`
+	TakenAt time.Time `json:"taken_at"`
+	// Version is the build version of the backup tool
+	Version string `json:"version"`
+	// SchemaVersion identifies the schema the rows were read from. Backups
+	// with the same schema version restore into each other's databases.
+	SchemaVersion string  `json:"schema_version"`
+	Tables        []Table `json:"tables"`
+}
+
+// Index lists what a backup holds. It is written once every part of the
+// backup is uploaded, so a backup without an index is incomplete.
+type Index struct {
+	Format   int       `json:"format"`
+	ID       string    `json:"id"`
+	TakenAt  time.Time `json:"taken_at"`
+	Version  string    `json:"version"`
+	Services []string  `json:"services"`
+	// Events is how many stored events were exported, if EventStore is set
+	EventStore bool `json:"event_store"`
+	Events     int  `json:"events"`
+}
+
+// Record is a row of a backed up table, one line of a data object
+type Record struct {
+	Table string          `json:"table"`
+	Row   json.RawMessage `json:"row"`
+}
+
+// RestoreOptions controls a restore
+type RestoreOptions struct {
+	// Truncate empties the backed up tables before restoring. Without it,
+	// restoring into tables that hold rows fails with ErrNotEmpty.
+	Truncate bool
+	// DryRun checks the backup against the database, and that its tables
+	// are empty unless Truncate is set, without restoring it
+	DryRun bool
+}
+
+// NewID returns the ID of a backup taken at t
+func NewID(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func indexKey(id string) string             { return id + "/backup.json" }
+func manifestKey(id, service string) string { return id + "/" + service + "/manifest.json" }
+func dataKey(id, service string) string     { return id + "/" + service + "/data.ndjson.gz" }
+func eventsKey(id string) string            { return id + "/events.ndjson.gz" }
+
+// Open connects to a service database at databaseURL
+func Open(databaseURL string) (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Warn),
+	})
+}
+
+// BackupDatabase uploads a consistent backup of a service's database to
+// objects as backup id, and returns its manifest
+func BackupDatabase(ctx context.Context, db *gorm.DB, objects storage.ObjectStore, id, service, version string) (*Manifest, error) {
+	// A repeatable read transaction sees one snapshot across all tables
+	tx := db.WithContext(ctx).Begin(&sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if tx.Error != nil {
+		return nil, fmt.Errorf("failed to begin backup of %s: %v", service, tx.Error)
+	}
+	defer tx.Rollback()
+
+	tables, err := readSchema(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the schema of %s: %v", service, err)
+	}
+
+	var data bytes.Buffer
+	gz := gzip.NewWriter(&data)
+	out := bufio.NewWriter(gz)
+	for i := range tables {
+		count, err := dumpTable(tx, &tables[i], out)
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up %s.%s: %v", service, tables[i].Name, err)
+		}
+		tables[i].Rows = count
+	}
+	if err := out.Flush(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		Format:        Format,
+		ID:            id,
+		Service:       service,
+		TakenAt:       time.Now().UTC(),
+		Version:       version,
+		SchemaVersion: schemaVersion(tables),
+		Tables:        tables,
+	}
+	if _, err := objects.Put(ctx, dataKey(id, service), &data, int64(data.Len()), "application/gzip"); err != nil {
+		return nil, err
+	}
+	if err := putJSON(ctx, objects, manifestKey(id, service), manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// dumpTable writes a table's rows as records and returns how many it wrote
+func dumpTable(tx *gorm.DB, table *Table, out io.Writer) (int, error) {
+	rows, err := tx.Raw(fmt.Sprintf("SELECT row_to_json(t)::text FROM %s t", quoteIdent(table.Name))).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	encoder := json.NewEncoder(out)
+	for rows.Next() {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			return count, err
+		}
+		if err := encoder.Encode(Record{Table: table.Name, Row: json.RawMessage(row)}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// BackupEvents uploads the stored events to objects as part of backup id and
+// returns how many it exported
+func BackupEvents(ctx context.Context, store *events.RedisEventStore, objects storage.ObjectStore, id string) (int, error) {
+	var data bytes.Buffer
+	gz := gzip.NewWriter(&data)
+	count, err := store.Export(ctx, gz)
+	if err != nil {
+		return 0, fmt.Errorf("failed to export events: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return 0, err
+	}
+	if _, err := objects.Put(ctx, eventsKey(id), &data, int64(data.Len()), "application/gzip"); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// WriteIndex uploads the index of backup id, completing it
+func WriteIndex(ctx context.Context, objects storage.ObjectStore, index *Index) error {
+	index.Format = Format
+	return putJSON(ctx, objects, indexKey(index.ID), index)
+}
+
+// ReadIndex downloads the index of backup id
+func ReadIndex(ctx context.Context, objects storage.ObjectStore, id string) (*Index, error) {
+	var index Index
+	if err := getJSON(ctx, objects, indexKey(id), &index); err != nil {
+		return nil, fmt.Errorf("backup %s is missing or incomplete: %v", id, err)
+	}
+	if index.Format > Format {
+		return nil, fmt.Errorf("backup %s has format %d, this build reads up to %d", id, index.Format, Format)
+	}
+	return &index, nil
+}
+
+// RestoreDatabase restores a service's database from backup id, after
+// checking that the backup's schema is compatible with the database. The
+// restore runs in one transaction: it applies completely or not at all.
+//
+// Foreign keys are not checked while rows are loaded, so tables can be
+// restored in any order. This needs a superuser connection.
+func RestoreDatabase(ctx context.Context, db *gorm.DB, objects storage.ObjectStore, id, service string, opts RestoreOptions) (*Manifest, error) {
+	var manifest Manifest
+	if err := getJSON(ctx, objects, manifestKey(id, service), &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Format > Format {
+		return nil, fmt.Errorf("backup of %s has format %d, this build reads up to %d", service, manifest.Format, Format)
+	}
+
+	current, err := readSchema(db.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the schema of %s: %v", service, err)
+	}
+	if err := CheckSchema(&manifest, current); err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		if !opts.Truncate {
+			err = prepareTables(db.WithContext(ctx), manifest.Tables, false)
+		}
+		return &manifest, err
+	}
+
+	data, err := objects.Get(ctx, dataKey(id, service))
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup of %s: %v", service, err)
+	}
+	defer gz.Close()
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SET LOCAL session_replication_role = replica").Error; err != nil {
+			return fmt.Errorf("failed to disable foreign key checks: %v", err)
+		}
+		if err := prepareTables(tx, manifest.Tables, opts.Truncate); err != nil {
+			return err
+		}
+		if err := loadRecords(tx, manifest.Tables, gz); err != nil {
+			return err
+		}
+		return resetSequences(tx, manifest.Tables)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// prepareTables empties the tables to restore, or checks that they are empty
+func prepareTables(tx *gorm.DB, tables []Table, truncate bool) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	if truncate {
+		names := make([]string, len(tables))
+		for i, table := range tables {
+			names[i] = quoteIdent(table.Name)
+		}
+		return tx.Exec("TRUNCATE " + strings.Join(names, ", ")).Error
+	}
+
+	var full []string
+	for _, table := range tables {
+		var exists bool
+		if err := tx.Raw(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s)", quoteIdent(table.Name))).Scan(&exists).Error; err != nil {
+			return err
+		}
+		if exists {
+			full = append(full, table.Name)
+		}
+	}
+	if len(full) > 0 {
+		return fmt.Errorf("%w: %s", ErrNotEmpty, strings.Join(full, ", "))
+	}
+	return nil
+}
+
+// loadRecords inserts the records read from r in batches per table. Columns
+// the backup does not have keep their defaults.
+func loadRecords(tx *gorm.DB, tables []Table, r io.Reader) error {
+	byName := make(map[string]*Table, len(tables))
+	for i := range tables {
+		byName[tables[i].Name] = &tables[i]
+	}
+
+	var table *Table
+	var batch []json.RawMessage
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		rows, err := json.Marshal(batch)
+		if err != nil {
+			return err
+		}
+		columns := make([]string, len(table.Columns))
+		for i, column := range table.Columns {
+			columns[i] = quoteIdent(column.Name)
+		}
+		list := strings.Join(columns, ", ")
+		name := quoteIdent(table.Name)
+		err = tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM json_populate_recordset(NULL::%s, ?::json)", name, list, list, name), string(rows)).Error
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %v", table.Name, err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	decoder := json.NewDecoder(r)
+	for {
+		var record Record
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read backup: %v", err)
+		}
+		if table == nil || record.Table != table.Name || len(batch) == restoreBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+			if table = byName[record.Table]; table == nil {
+				return fmt.Errorf("backup holds rows of %s, which its manifest does not list", record.Table)
+			}
+		}
+		batch = append(batch, record.Row)
+	}
+	return flush()
+}
+
+// resetSequences moves the sequences of serial columns past the restored
+// rows, so new rows don't collide with them
+func resetSequences(tx *gorm.DB, tables []Table) error {
+	restored := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		restored[table.Name] = true
+	}
+
+	var serials []struct {
+		TableName  string
+		ColumnName string
+	}
+	err := tx.Raw(`SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND column_default LIKE 'nextval(%'`).Scan(&serials).Error
+	if err != nil {
+		return err
+	}
+	for _, serial := range serials {
+		if !restored[serial.TableName] {
+			continue
+		}
+		table, column := quoteIdent(serial.TableName), quoteIdent(serial.ColumnName)
+		err := tx.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence(?, ?), COALESCE((SELECT MAX(%s) FROM %s), 0) + 1, false)", column, table),
+			table, serial.ColumnName).Error
+		if err != nil {
+			return fmt.Errorf("failed to reset the sequence of %s.%s: %v", serial.TableName, serial.ColumnName, err)
+		}
+	}
+	return nil
+}
+
+// RestoreEvents stores the events of backup id that the event store does not
+// hold, and returns how many it stored
+func RestoreEvents(ctx context.Context, store *events.RedisEventStore, objects storage.ObjectStore, id string) (int, error) {
+	data, err := objects.Get(ctx, eventsKey(id))
+	if err != nil {
+		return 0, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the event backup: %v", err)
+	}
+	defer gz.Close()
+	return store.Import(ctx, gz)
+}
+
+// CheckSchema checks that the rows of a backup fit the current schema: every
+// backed up table and column exists with the same type. Columns and tables
+// added since, as migrations do, are fine.
+func CheckSchema(manifest *Manifest, current []Table) error {
+	if schemaVersion(current) == manifest.SchemaVersion {
+		return nil
+	}
+
+	tables := make(map[string]map[string]Column, len(current))
+	for _, table := range current {
+		columns := make(map[string]Column, len(table.Columns))
+		for _, column := range table.Columns {
+			columns[column.Name] = column
+		}
+		tables[table.Name] = columns
+	}
+
+	var problems []string
+	for _, table := range manifest.Tables {
+		columns, ok := tables[table.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("table %s is missing", table.Name))
+			continue
+		}
+		for _, column := range table.Columns {
+			existing, ok := columns[column.Name]
+			switch {
+			case !ok:
+				problems = append(problems, fmt.Sprintf("column %s.%s is missing", table.Name, column.Name))
+			case existing.Type != column.Type:
+				problems = append(problems, fmt.Sprintf("column %s.%s is %s, the backup has %s", table.Name, column.Name, existing.Type, column.Type))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrIncompatibleSchema, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// readSchema describes the tables of the current schema
+func readSchema(db *gorm.DB) ([]Table, error) {
+	var columns []struct {
+		TableName  string
+		ColumnName string
+		DataType   string
+		IsNullable string
+	}
+	err := db.Raw(`SELECT c.table_name, c.column_name, c.data_type, c.is_nullable
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = current_schema() AND t.table_type = 'BASE TABLE'
+		ORDER BY c.table_name, c.ordinal_position`).Scan(&columns).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []Table
+	for _, column := range columns {
+		if len(tables) == 0 || tables[len(tables)-1].Name != column.TableName {
+			tables = append(tables, Table{Name: column.TableName})
+		}
+		table := &tables[len(tables)-1]
+		table.Columns = append(table.Columns, Column{
+			Name:     column.ColumnName,
+			Type:     column.DataType,
+			Nullable: column.IsNullable == "YES",
+		})
+	}
+	return tables, nil
+}
+
+// schemaVersion hashes the tables and columns of a schema, ignoring column
+// order
+func schemaVersion(tables []Table) string {
+	var lines []string
+	for _, table := range tables {
+		for _, column := range table.Columns {
+			lines = append(lines, fmt.Sprintf("%s.%s %s %t", table.Name, column.Name, column.Type, column.Nullable))
+		}
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// quoteIdent quotes a Postgres identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func putJSON(ctx context.Context, objects storage.ObjectStore, key string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = objects.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "application/json")
+	return err
+}
+
+func getJSON(ctx context.Context, objects storage.ObjectStore, key string, value interface{}) error {
+	data, err := objects.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("failed to read %s: %v", key, err)
+	}
+	return nil
+}
+
+// NewStore connects to the object storage holding backups
+func NewStore(ctx context.Context, cfg config.BackupConfig) (storage.ObjectStore, error) {
+	return storage.NewS3Store(ctx, storage.Config{
+		Endpoint:  cfg.StorageEndpoint,
+		AccessKey: cfg.StorageAccessKey,
+		SecretKey: cfg.StorageSecretKey,
+		Bucket:    cfg.Bucket,
+		UseSSL:    cfg.StorageUseSSL,
+	})
+}
+
+// Services returns the services named in list, a comma-separated list, or
+// every service in databases if list is empty. Services without a database
+// are an error.
+func Services(databases map[string]string, list string) ([]string, error) {
+	var services []string
+	if strings.TrimSpace(list) == "" {
+		for service := range databases {
+			services = append(services, service)
+		}
+		sort.Strings(services)
+		return services, nil
+	}
+	for _, service := range strings.Split(list, ",") {
+		if service = strings.TrimSpace(service); service == "" {
+			continue
+		}
+		if databases[service] == "" {
+			return nil, fmt.Errorf("no database configured for %s, add it to BACKUP_DATABASES", service)
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
@@ -0,0 +1,118 @@
+// Package buildinfo describes the running build. Version, Commit and Date
+// are set at build time, e.g.
+//
+//	go build -ldflags "-X microservices-platform/pkg/buildinfo.Version=1.4.0 \
+//	  -X microservices-platform/pkg/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X microservices-platform/pkg/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without them, the commit and date recorded by the Go toolchain are used.
+package buildinfo
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// Set with -ldflags -X
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Path is where services serve Handler
+const Path = "/version"
+
+// Info describes a service's build
+type Info struct {
+	Service   string `json:"service"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+	// Region is where the service runs, from REGION; empty for a
+	// single-region deployment
+	Region string `json:"region,omitempty"`
+}
+
+// Get returns the build info of service
+func Get(service string) Info {
+	info := Info{
+		Service:   service,
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Region:    os.Getenv("REGION"),
+	}
+	if info.Commit != "" && info.Date != "" {
+		return info
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+				if len(info.Commit) > 12 {
+					info.Commit = info.Commit[:12]
+				}
+			case setting.Key == "vcs.time" && info.Date == "":
+				info.Date = setting.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.Date == "" {
+		info.Date = "unknown"
+	}
+	return info
+}
+
+// LogStartup logs a startup banner with the build info of service, reports
+// it in the build_info metric and labels every metric with the region
+func LogStartup(service string) {
+	info := Get(service)
+	log.Printf("Starting %s %s (commit %s, built %s, %s)", info.Service, info.Version, info.Commit, info.Date, info.GoVersion)
+	metrics.SetBuildInfo(info.Service, info.Version, info.Commit, info.Date, info.GoVersion)
+	if info.Region != "" {
+		log.Printf("Running in region %s", info.Region)
+		metrics.SetRegion(info.Region)
+	}
+}
+
+// ResourceAttributes returns the trace resource attributes of service,
+// naming it, its build and its region so traces can be correlated with
+// deployments
+func ResourceAttributes(service string) []attribute.KeyValue {
+	info := Get(service)
+	attributes := []attribute.KeyValue{
+		semconv.ServiceNameKey.String(info.Service),
+		semconv.ServiceVersionKey.String(info.Version),
+		attribute.String("build.commit", info.Commit),
+		attribute.String("build.date", info.Date),
+	}
+	if info.Region != "" {
+		attributes = append(attributes, semconv.CloudRegionKey.String(info.Region))
+	}
+	return attributes
+}
+
+// Handler serves the build info of service as JSON
+func Handler(service string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Get(service))
+	})
+}
@@ -0,0 +1,581 @@
+package cache
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// AdminPath is where services serve the cache admin API
+const AdminPath = "/admin/cache/"
+
+// Admin API limits
+const (
+	defaultKeyLimit = 100
+	maxKeyLimit     = 1000
+	maxValueBytes   = 64 * 1024
+	scanBatch       = 500
+)
+
+// AdminConfig holds the settings of the cache admin API. It is served only
+// when Token is set. Caches switched off or on by an admin are picked up by
+// the service's other instances every SyncInterval.
+type AdminConfig struct {
+	Token        string
+	SyncInterval time.Duration
+}
+
+// LoadAdminConfig loads the cache admin API settings from environment
+// variables
+func LoadAdminConfig() AdminConfig {
+	return AdminConfig{
+		Token:        getEnv("CACHE_ADMIN_TOKEN", ""),
+		SyncInterval: getDurationEnv("CACHE_ADMIN_SYNC_INTERVAL", 10*time.Second),
+	}
+}
+
+// namespace is a cache created with New. Its keys start with "<name>:".
+type namespace struct {
+	name string
+	// client is nil when the cache runs without Redis
+	client  redis.UniversalClient
+	enabled atomic.Bool
+}
+
+// prefix is what every key of the namespace starts with
+func (n *namespace) prefix() string {
+	return n.name + ":"
+}
+
+// namespaces holds the caches created in this process, by name
+var (
+	namespacesMu sync.RWMutex
+	namespaces   = make(map[string]*namespace)
+)
+
+// register records a cache created with New, which starts enabled
+func register(service, name string, client redis.UniversalClient) *namespace {
+	namespacesMu.Lock()
+	defer namespacesMu.Unlock()
+	ns, ok := namespaces[name]
+	if !ok {
+		ns = &namespace{name: name}
+		ns.enabled.Store(true)
+		namespaces[name] = ns
+		metrics.CacheEnabled.WithLabelValues(service, name).Set(1)
+	}
+	if client != nil {
+		ns.client = client
+	}
+	return ns
+}
+
+// lookupNamespace returns the namespace named name
+func lookupNamespace(name string) (*namespace, bool) {
+	namespacesMu.RLock()
+	defer namespacesMu.RUnlock()
+	ns, ok := namespaces[name]
+	return ns, ok
+}
+
+// namespaceOf returns the namespace a key or key pattern belongs to
+func namespaceOf(key string) (*namespace, bool) {
+	name, _, ok := strings.Cut(key, ":")
+	if !ok {
+		return nil, false
+	}
+	return lookupNamespace(name)
+}
+
+// sortedNamespaces returns every namespace by name
+func sortedNamespaces() []*namespace {
+	namespacesMu.RLock()
+	defer namespacesMu.RUnlock()
+	list := make([]*namespace, 0, len(namespaces))
+	for _, ns := range namespaces {
+		list = append(list, ns)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// switchableCache is a cache an admin can switch off. While it is off,
+// lookups miss and writes are dropped, but deletes still go through so no
+// invalidation is lost before it is switched back on.
+type switchableCache struct {
+	Cache
+	ns      *namespace
+	service string
+}
+
+// Get retrieves a value from cache while the cache is on
+func (c *switchableCache) Get(ctx context.Context, key string, dest interface{}) error {
+	if !c.ns.enabled.Load() {
+		metrics.RecordCacheMiss(c.service, c.ns.name)
+		return ErrCacheMiss
+	}
+	return c.Cache.Get(ctx, key, dest)
+}
+
+// Set stores a value in cache while the cache is on
+func (c *switchableCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if !c.ns.enabled.Load() {
+		return nil
+	}
+	return c.Cache.Set(ctx, key, value, ttl)
+}
+
+// Exists checks if a key exists in cache, reporting false while the cache
+// is off
+func (c *switchableCache) Exists(ctx context.Context, key string) (bool, error) {
+	if !c.ns.enabled.Load() {
+		return false, nil
+	}
+	return c.Cache.Exists(ctx, key)
+}
+
+// Admin serves the cache admin API: it lists the service's caches and their
+// keys with TTLs and sizes, invalidates keys, and switches caches off and on
+// at runtime. Only keys of the service's caches can be read or deleted.
+type Admin struct {
+	service string
+	cfg     AdminConfig
+}
+
+// NewAdmin creates the cache admin API of service
+func NewAdmin(service string, cfg AdminConfig) *Admin {
+	return &Admin{service: service, cfg: cfg}
+}
+
+// switchesKey is the Redis hash holding which of the service's caches are
+// switched off, shared by every instance
+func (a *Admin) switchesKey() string {
+	return "cache-admin:" + a.service + ":disabled"
+}
+
+// Start applies the cache switches set by admins on any instance every
+// SyncInterval until ctx is cancelled
+func (a *Admin) Start(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		a.sync(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync reads the cache switches from Redis
+func (a *Admin) sync(ctx context.Context) {
+	for _, ns := range sortedNamespaces() {
+		if ns.client == nil {
+			continue
+		}
+		disabled, err := ns.client.HExists(ctx, a.switchesKey(), ns.name).Result()
+		if err != nil {
+			log.Printf("Failed to read cache switches of %s: %v", a.service, err)
+			return
+		}
+		a.apply(ns, !disabled)
+	}
+}
+
+// apply switches a cache off or on in this instance
+func (a *Admin) apply(ns *namespace, enabled bool) {
+	if ns.enabled.Swap(enabled) == enabled {
+		return
+	}
+	value := 0.0
+	if enabled {
+		value = 1
+	}
+	metrics.CacheEnabled.WithLabelValues(a.service, ns.name).Set(value)
+	log.Printf("Cache %s switched %s", ns.name, onOff(enabled))
+}
+
+// keyInfo describes a cached key
+type keyInfo struct {
+	Key string `json:"key"`
+	// TTLSeconds is -1 for keys without an expiry
+	TTLSeconds int64           `json:"ttl_seconds"`
+	SizeBytes  int64           `json:"size_bytes"`
+	Value      json.RawMessage `json:"value,omitempty"`
+	Truncated  bool            `json:"value_truncated,omitempty"`
+}
+
+// Handler serves the admin API under AdminPath:
+//
+//	GET    namespaces                  caches and whether they are on
+//	PUT    namespaces/<name>           {"enabled": false} switches a cache off
+//	GET    keys?pattern=<p>&limit=<n>  keys matching a pattern, with TTLs and sizes
+//	DELETE keys?pattern=<p>            deletes the keys matching a pattern
+//	GET    keys/<key>                  a key with its TTL, size and value
+//	DELETE keys/<key>                  deletes a key
+//
+// Patterns use Redis glob syntax and must start with a cache's name, as in
+// "product:42:*". Requests must carry the token as a bearer token.
+func (a *Admin) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if a.cfg.Token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(a.cfg.Token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing token"})
+			return
+		}
+
+		path := strings.TrimPrefix(r.URL.Path, AdminPath)
+		switch {
+		case path == "namespaces" && r.Method == http.MethodGet:
+			a.listNamespaces(w)
+		case strings.HasPrefix(path, "namespaces/") && r.Method == http.MethodPut:
+			a.switchNamespace(w, r, strings.TrimPrefix(path, "namespaces/"))
+		case path == "keys" && r.Method == http.MethodGet:
+			a.listKeys(w, r)
+		case path == "keys" && r.Method == http.MethodDelete:
+			a.deleteKeys(w, r)
+		case strings.HasPrefix(path, "keys/") && r.Method == http.MethodGet:
+			a.getKey(w, r, strings.TrimPrefix(path, "keys/"))
+		case strings.HasPrefix(path, "keys/") && r.Method == http.MethodDelete:
+			a.deleteKey(w, r, strings.TrimPrefix(path, "keys/"))
+		default:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+	})
+}
+
+// listNamespaces lists the service's caches
+func (a *Admin) listNamespaces(w http.ResponseWriter) {
+	list := make([]map[string]interface{}, 0)
+	for _, ns := range sortedNamespaces() {
+		list = append(list, map[string]interface{}{
+			"name":      ns.name,
+			"prefix":    ns.prefix(),
+			"enabled":   ns.enabled.Load(),
+			"available": ns.client != nil,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"service": a.service, "namespaces": list})
+}
+
+// switchNamespace switches a cache off or on for every instance. Switching
+// a cache off does not delete its keys; they are served again if it is
+// switched back on before they expire.
+func (a *Admin) switchNamespace(w http.ResponseWriter, r *http.Request, name string) {
+	ns, ok := lookupNamespace(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown cache " + name})
+		return
+	}
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Enabled == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": `body must be {"enabled": true|false}`})
+		return
+	}
+
+	if ns.client != nil {
+		var err error
+		if *body.Enabled {
+			err = ns.client.HDel(r.Context(), a.switchesKey(), ns.name).Err()
+		} else {
+			err = ns.client.HSet(r.Context(), a.switchesKey(), ns.name, time.Now().UTC().Format(time.RFC3339)).Err()
+		}
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "failed to switch the cache: " + err.Error()})
+			return
+		}
+	}
+	a.apply(ns, *body.Enabled)
+	log.Printf("Cache admin switched cache %s %s", ns.name, onOff(*body.Enabled))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"name": ns.name, "enabled": *body.Enabled})
+}
+
+// patternNamespace returns the namespace a request's key pattern belongs to,
+// answering the request if it has none
+func patternNamespace(w http.ResponseWriter, pattern string) (*namespace, bool) {
+	ns, ok := namespaceOf(pattern)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "pattern must start with the name of a cache, e.g. \"<cache>:*\""})
+		return nil, false
+	}
+	if ns.client == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "cache " + ns.name + " is running without Redis"})
+		return nil, false
+	}
+	return ns, true
+}
+
+// listKeys lists the keys matching a pattern with their TTLs and sizes
+func (a *Admin) listKeys(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("pattern")
+	ns, ok := patternNamespace(w, pattern)
+	if !ok {
+		return
+	}
+	limit := defaultKeyLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxKeyLimit {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("limit must be between 1 and %d", maxKeyLimit)})
+			return
+		}
+		limit = parsed
+	}
+
+	var mu sync.Mutex
+	keys := make([]keyInfo, 0)
+	truncated := false
+	err := forEachNode(r.Context(), ns.client, func(ctx context.Context, node redis.Cmdable) error {
+		found, more, err := scanKeys(ctx, node, pattern, limit)
+		if err != nil {
+			return err
+		}
+		infos, err := describeKeys(ctx, node, found)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append(keys, infos...)
+		truncated = truncated || more
+		return nil
+	})
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "failed to list keys: " + err.Error()})
+		return
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	if len(keys) > limit {
+		keys, truncated = keys[:limit], true
+	}
+	var total int64
+	for _, key := range keys {
+		total += key.SizeBytes
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pattern":          pattern,
+		"keys":             keys,
+		"count":            len(keys),
+		"total_size_bytes": total,
+		"truncated":        truncated,
+	})
+}
+
+// deleteKeys deletes the keys matching a pattern
+func (a *Admin) deleteKeys(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("pattern")
+	ns, ok := patternNamespace(w, pattern)
+	if !ok {
+		return
+	}
+
+	var deleted int64
+	err := forEachNode(r.Context(), ns.client, func(ctx context.Context, node redis.Cmdable) error {
+		count, err := deleteMatching(ctx, node, pattern)
+		atomic.AddInt64(&deleted, count)
+		return err
+	})
+	if deleted > 0 {
+		metrics.CacheInvalidationsTotal.WithLabelValues(a.service, ns.name).Add(float64(deleted))
+	}
+	log.Printf("Cache admin invalidated %d keys matching %s", deleted, pattern)
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"error": "failed to delete keys: " + err.Error(), "deleted": deleted})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"pattern": pattern, "deleted": deleted})
+}
+
+// getKey returns a key with its TTL, size and value
+func (a *Admin) getKey(w http.ResponseWriter, r *http.Request, key string) {
+	ns, ok := patternNamespace(w, key)
+	if !ok {
+		return
+	}
+	infos, err := describeKeys(r.Context(), ns.client, []string{key})
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "failed to read key: " + err.Error()})
+		return
+	}
+	if len(infos) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "key not found"})
+		return
+	}
+
+	info := infos[0]
+	value, err := ns.client.Get(r.Context(), key).Bytes()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "failed to read key: " + err.Error()})
+		return
+	}
+	if len(value) > maxValueBytes {
+		info.Truncated = true
+	} else if json.Valid(value) {
+		info.Value = value
+	} else {
+		info.Value, _ = json.Marshal(string(value))
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// deleteKey deletes a key
+func (a *Admin) deleteKey(w http.ResponseWriter, r *http.Request, key string) {
+	ns, ok := patternNamespace(w, key)
+	if !ok {
+		return
+	}
+	deleted, err := ns.client.Del(r.Context(), key).Result()
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "failed to delete key: " + err.Error()})
+		return
+	}
+	if deleted > 0 {
+		metrics.CacheInvalidationsTotal.WithLabelValues(a.service, ns.name).Add(float64(deleted))
+	}
+	log.Printf("Cache admin invalidated key %s", key)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"key": key, "deleted": deleted})
+}
+
+// forEachNode runs fn on every master of a cluster, or on the client itself
+func forEachNode(ctx context.Context, client redis.UniversalClient, fn func(context.Context, redis.Cmdable) error) error {
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return fn(ctx, node)
+		})
+	}
+	return fn(ctx, client)
+}
+
+// scanKeys returns up to limit keys of a node matching a pattern, and
+// whether there are more. SCAN is used rather than KEYS so a large cache
+// does not block Redis.
+func scanKeys(ctx context.Context, node redis.Cmdable, pattern string, limit int) ([]string, bool, error) {
+	var keys []string
+	var cursor uint64
+	for {
+		batch, next, err := node.Scan(ctx, cursor, pattern, scanBatch).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		keys = append(keys, batch...)
+		if len(keys) > limit {
+			return keys[:limit], true, nil
+		}
+		if next == 0 {
+			return keys, false, nil
+		}
+		cursor = next
+	}
+}
+
+// deleteMatching deletes the keys of a node matching a pattern, one batch of
+// the scan at a time
+func deleteMatching(ctx context.Context, node redis.Cmdable, pattern string) (int64, error) {
+	var deleted int64
+	var cursor uint64
+	for {
+		batch, next, err := node.Scan(ctx, cursor, pattern, scanBatch).Result()
+		if err != nil {
+			return deleted, err
+		}
+		if len(batch) > 0 {
+			// Deleted one by one so a cluster node never sees a cross-slot DEL
+			pipe := node.Pipeline()
+			cmds := make([]*redis.IntCmd, len(batch))
+			for i, key := range batch {
+				cmds[i] = pipe.Del(ctx, key)
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				return deleted, err
+			}
+			for _, cmd := range cmds {
+				deleted += cmd.Val()
+			}
+		}
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
+
+// describeKeys reads the TTL and memory usage of keys, skipping keys that
+// no longer exist
+func describeKeys(ctx context.Context, node redis.Cmdable, keys []string) ([]keyInfo, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	pipe := node.Pipeline()
+	ttls := make([]*redis.DurationCmd, len(keys))
+	sizes := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		ttls[i] = pipe.TTL(ctx, key)
+		sizes[i] = pipe.MemoryUsage(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	infos := make([]keyInfo, 0, len(keys))
+	for i, key := range keys {
+		ttl := ttls[i].Val()
+		if ttl == -2 {
+			continue
+		}
+		info := keyInfo{Key: key, TTLSeconds: -1, SizeBytes: sizes[i].Val()}
+		if ttl >= 0 {
+			info.TTLSeconds = int64(ttl / time.Second)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/metrics"
+)
+
+// New creates a Redis cache for the named cache, recording its hits and
+// misses in the cache metrics and its Redis commands in the dependency
+// metrics. With degradation enabled a Redis outage
+// never fails the caller: if Redis is unreachable at startup a no-op cache is
+// returned, and Redis errors at runtime are treated as misses. The cache's
+// keys must start with "<name>:"; they can then be inspected and
+// invalidated, and the cache switched off, through the cache admin API.
+func New(cfg config.RedisConfig, service, name string, degrade bool) (Cache, error) {
+	redisCache, err := NewRedisCache(cfg)
+	if err != nil {
+		if !degrade {
+			return nil, err
+		}
+		log.Printf("Cache %s unavailable, running without cache: %v", name, err)
+		ns := register(service, name, nil)
+		return &switchableCache{Cache: NewNoopCache(service, name), ns: ns, service: service}, nil
+	}
+	redisCache.client.AddHook(dependencyHook{service: service})
+	ns := register(service, name, redisCache.client)
+
+	if !degrade {
+		return &switchableCache{Cache: &meteredCache{Cache: redisCache, service: service, name: name}, ns: ns, service: service}, nil
+	}
+	return &switchableCache{Cache: &DegradingCache{cache: redisCache, service: service, name: name}, ns: ns, service: service}, nil
+}
+
+// meteredCache records the hits and misses of a cache whose errors are
+// returned to the caller
+type meteredCache struct {
+	Cache
+	service string
+	name    string
+}
+
+// Get retrieves a value from cache, recording a hit or a miss
+func (c *meteredCache) Get(ctx context.Context, key string, dest interface{}) error {
+	err := c.Cache.Get(ctx, key, dest)
+	switch {
+	case err == nil:
+		metrics.RecordCacheHit(c.service, c.name)
+	case errors.Is(err, ErrCacheMiss):
+		metrics.RecordCacheMiss(c.service, c.name)
+	}
+	return err
+}
+
+// NoopCache implements Cache without storing anything. Every lookup is
+// recorded as a miss so the lost hit ratio stays visible.
+type NoopCache struct {
+	service string
+	name    string
+}
+
+// NewNoopCache creates a new no-op cache
+func NewNoopCache(service, name string) *NoopCache {
+	return &NoopCache{service: service, name: name}
+}
+
+// Get always misses
+func (c *NoopCache) Get(ctx context.Context, key string, dest interface{}) error {
+	metrics.RecordCacheMiss(c.service, c.name)
+	return ErrCacheMiss
+}
+
+// Set discards the value
+func (c *NoopCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return nil
+}
+
+// Delete does nothing
+func (c *NoopCache) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
+// Exists always reports false
+func (c *NoopCache) Exists(ctx context.Context, key string) (bool, error) {
+	return false, nil
+}
+
+// Clear does nothing
+func (c *NoopCache) Clear(ctx context.Context, pattern string) error {
+	return nil
+}
+
+// DegradingCache wraps a cache so that backend errors behave like misses and
+// writes are skipped, letting callers fall through to the database
+type DegradingCache struct {
+	cache   Cache
+	service string
+	name    string
+}
+
+// Get retrieves a value from cache, reporting backend errors as misses
+func (c *DegradingCache) Get(ctx context.Context, key string, dest interface{}) error {
+	err := c.cache.Get(ctx, key, dest)
+	switch {
+	case err == nil:
+		metrics.RecordCacheHit(c.service, c.name)
+		return nil
+	case !errors.Is(err, ErrCacheMiss):
+		log.Printf("Cache %s degraded, treating lookup as miss: %v", c.name, err)
+	}
+
+	metrics.RecordCacheMiss(c.service, c.name)
+	return ErrCacheMiss
+}
+
+// Set stores a value in cache, ignoring backend errors
+func (c *DegradingCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := c.cache.Set(ctx, key, value, ttl); err != nil {
+		log.Printf("Cache %s degraded, skipping write: %v", c.name, err)
+	}
+	return nil
+}
+
+// Delete removes a key from cache, ignoring backend errors
+func (c *DegradingCache) Delete(ctx context.Context, key string) error {
+	if err := c.cache.Delete(ctx, key); err != nil {
+		log.Printf("Cache %s degraded, skipping delete: %v", c.name, err)
+	}
+	return nil
+}
+
+// Exists checks if a key exists in cache, reporting false on backend errors
+func (c *DegradingCache) Exists(ctx context.Context, key string) (bool, error) {
+	exists, err := c.cache.Exists(ctx, key)
+	if err != nil {
+		log.Printf("Cache %s degraded, treating key as missing: %v", c.name, err)
+		return false, nil
+	}
+	return exists, nil
+}
+
+// Clear removes all keys matching a pattern, ignoring backend errors
+func (c *DegradingCache) Clear(ctx context.Context, pattern string) error {
+	if err := c.cache.Clear(ctx, pattern); err != nil {
+		log.Printf("Cache %s degraded, skipping clear: %v", c.name, err)
+	}
+	return nil
+}
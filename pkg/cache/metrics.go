@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// dependencyName labels the cache's Redis in the dependency metrics
+const dependencyName = "redis"
+
+// dependencyStartKey is the context key under which the hook keeps the time
+// a command started
+type dependencyStartKey struct{}
+
+// dependencyHook records every Redis command and pipeline of a cache in the
+// dependency metrics. Misses are not errors.
+type dependencyHook struct {
+	service string
+}
+
+func (h dependencyHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, dependencyStartKey{}, time.Now()), nil
+}
+
+func (h dependencyHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.record(ctx, cmd.Name(), cmd.Err())
+	return nil
+}
+
+func (h dependencyHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, dependencyStartKey{}, time.Now()), nil
+}
+
+func (h dependencyHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmdErr := cmd.Err(); cmdErr != nil && cmdErr != redis.Nil {
+			err = cmdErr
+			break
+		}
+	}
+	h.record(ctx, "pipeline", err)
+	return nil
+}
+
+func (h dependencyHook) record(ctx context.Context, operation string, err error) {
+	start, ok := ctx.Value(dependencyStartKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	errorCode := ""
+	if err != nil && err != redis.Nil {
+		errorCode = "error"
+		if err == context.DeadlineExceeded {
+			errorCode = "timeout"
+		}
+	}
+	metrics.RecordDependencyRequest(h.service, dependencyName, operation, errorCode, time.Since(start))
+}
@@ -3,12 +3,18 @@ package cache
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/redisclient"
 )
 
+// ErrCacheMiss is returned by Get when a key is not cached
+var ErrCacheMiss = errors.New("key not found")
+
 // Cache interface defines caching operations
 type Cache interface {
 	Get(ctx context.Context, key string, dest interface{}) error
@@ -20,24 +26,15 @@ type Cache interface {
 
 // RedisCache implements Cache interface using Redis
 type RedisCache struct {
-	client *redis.Client
+	client redis.UniversalClient
 }
 
-// NewRedisCache creates a new Redis cache instance
-func NewRedisCache(redisURL string) (*RedisCache, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     redisURL,
-		Password: "", // no password
-		DB:       0,  // default DB
-	})
-
-	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err := client.Ping(ctx).Result()
+// NewRedisCache creates a new Redis cache instance for a standalone, cluster
+// or sentinel deployment
+func NewRedisCache(cfg config.RedisConfig) (*RedisCache, error) {
+	client, err := redisclient.New(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+		return nil, err
 	}
 
 	return &RedisCache{client: client}, nil
@@ -48,7 +45,7 @@ func (c *RedisCache) Get(ctx context.Context, key string, dest interface{}) erro
 	val, err := c.client.Get(ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return fmt.Errorf("key not found")
+			return ErrCacheMiss
 		}
 		return err
 	}
@@ -77,18 +74,35 @@ func (c *RedisCache) Exists(ctx context.Context, key string) (bool, error) {
 	return count > 0, err
 }
 
-// Clear removes all keys matching a pattern
+// Clear removes all keys matching a pattern. In a cluster every master is
+// searched, since keys are spread across nodes.
 func (c *RedisCache) Clear(ctx context.Context, pattern string) error {
-	keys, err := c.client.Keys(ctx, pattern).Result()
+	if cluster, ok := c.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return clearNode(ctx, node, pattern)
+		})
+	}
+
+	return clearNode(ctx, c.client, pattern)
+}
+
+// clearNode removes the keys matching a pattern on a single node. Keys are
+// deleted one by one so a cluster node never sees a cross-slot DEL.
+func clearNode(ctx context.Context, client redis.Cmdable, pattern string) error {
+	keys, err := client.Keys(ctx, pattern).Result()
 	if err != nil {
 		return err
 	}
-
-	if len(keys) > 0 {
-		return c.client.Del(ctx, keys...).Err()
+	if len(keys) == 0 {
+		return nil
 	}
 
-	return nil
+	pipe := client.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
 }
 
 // Close closes the Redis connection
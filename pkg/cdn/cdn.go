@@ -0,0 +1,249 @@
+// Package cdn makes public catalog responses cacheable by a CDN in front of
+// the gateway. Responses carry Cache-Control, an ETag and surrogate keys
+// naming the products in them, so a changed product can be purged from every
+// cached response it appears in.
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/metrics"
+)
+
+// ProductListKey is the surrogate key of every product list and search
+// response, purged when products are added or change in ways that reorder
+// lists
+const ProductListKey = "product-list"
+
+// maxKeysPerPurge is how many surrogate keys one purge request carries, the
+// limit of Fastly's purge API
+const maxKeysPerPurge = 256
+
+// PurgeReasonAPI labels purges requested through the admin API in
+// cdn_purges_total
+const PurgeReasonAPI = "api"
+
+// ProductKey returns the surrogate key of the responses a product appears in
+func ProductKey(productID string) string {
+	return "product-" + productID
+}
+
+// KeyFunc returns the surrogate keys of a successful response
+type KeyFunc func(c *gin.Context, body []byte) []string
+
+// ProductKeys keys a product response by the product in the path
+func ProductKeys(c *gin.Context, body []byte) []string {
+	return []string{ProductKey(c.Param("id"))}
+}
+
+// ProductListKeys keys a product list response by the list and every
+// product in it
+func ProductListKeys(c *gin.Context, body []byte) []string {
+	keys := []string{ProductListKey}
+	var list struct {
+		Data []struct {
+			ProductID string `json:"product_id"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(body, &list) != nil {
+		return keys
+	}
+	for _, product := range list.Data {
+		if product.ProductID != "" {
+			keys = append(keys, ProductKey(product.ProductID))
+		}
+	}
+	return keys
+}
+
+// Cache makes successful GET responses cacheable by browsers and the CDN,
+// with an ETag and the surrogate keys returned by keys. A request whose
+// If-None-Match matches the ETag gets 304 Not Modified. Requests carrying
+// credentials may see more than the public catalog, so their responses are
+// kept private, as are errors.
+func Cache(cfg config.CDNConfig, keys KeyFunc) gin.HandlerFunc {
+	public := fmt.Sprintf("public, max-age=%d, s-maxage=%d, stale-while-revalidate=%d",
+		int(cfg.MaxAge.Seconds()), int(cfg.SharedMaxAge.Seconds()), int(cfg.StaleWhileRevalidate.Seconds()))
+
+	return func(c *gin.Context) {
+		if !cfg.Enabled || c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		if c.GetHeader("Authorization") != "" {
+			c.Header("Cache-Control", "private, no-store")
+			c.Next()
+			return
+		}
+
+		writer := &holdingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		header := writer.Header()
+		if writer.Status() != http.StatusOK {
+			header.Set("Cache-Control", "no-store")
+			writer.ResponseWriter.Write(writer.buffer.Bytes())
+			return
+		}
+
+		body := writer.buffer.Bytes()
+		etag := header.Get("ETag")
+		if etag == "" {
+			sum := sha256.Sum256(body)
+			etag = `W/"` + hex.EncodeToString(sum[:16]) + `"`
+			header.Set("ETag", etag)
+		}
+		header.Set("Cache-Control", public)
+		if header.Get("Content-Encoding") == "" {
+			header.Set("Surrogate-Key", strings.Join(keys(c, body), " "))
+		} else {
+			// The products in a compressed body can't be read; purging the
+			// list still reaches it
+			header.Set("Surrogate-Key", ProductListKey)
+		}
+
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			header.Del("Content-Length")
+			header.Del("Content-Type")
+			writer.ResponseWriter.WriteHeader(http.StatusNotModified)
+			writer.ResponseWriter.WriteHeaderNow()
+			return
+		}
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+		writer.ResponseWriter.Write(body)
+	}
+}
+
+// etagMatches reports whether an If-None-Match header matches an ETag,
+// comparing weakly as RFC 9110 requires for GET
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// holdingWriter holds back a response until the handler is done, so its
+// caching headers can be derived from its body
+type holdingWriter struct {
+	gin.ResponseWriter
+	buffer bytes.Buffer
+}
+
+func (w *holdingWriter) Write(data []byte) (int, error) {
+	return w.buffer.Write(data)
+}
+
+func (w *holdingWriter) WriteString(s string) (int, error) {
+	return w.buffer.WriteString(s)
+}
+
+func (w *holdingWriter) WriteHeaderNow() {}
+
+func (w *holdingWriter) Flush() {}
+
+// Purger purges responses from the CDN by surrogate key
+type Purger interface {
+	// Purge purges the responses carrying any of keys. reason labels the
+	// purge in cdn_purges_total.
+	Purge(ctx context.Context, reason string, keys ...string) error
+}
+
+// HTTPPurger purges surrogate keys through a CDN's HTTP purge API
+type HTTPPurger struct {
+	cfg    config.CDNConfig
+	client *http.Client
+}
+
+// NewPurger creates a purger for the configured purge API, or returns nil if
+// none is configured
+func NewPurger(cfg config.CDNConfig) *HTTPPurger {
+	if cfg.PurgeURL == "" {
+		return nil
+	}
+	return &HTTPPurger{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.PurgeTimeout},
+	}
+}
+
+// Purge requests a purge of keys, at most maxKeysPerPurge at a time
+func (p *HTTPPurger) Purge(ctx context.Context, reason string, keys ...string) error {
+	for len(keys) > 0 {
+		batch := keys[:min(len(keys), maxKeysPerPurge)]
+		keys = keys[len(batch):]
+
+		err := p.purge(ctx, batch)
+		metrics.RecordCDNPurge(reason, len(batch), err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *HTTPPurger) purge(ctx context.Context, keys []string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.PurgeURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Surrogate-Key", strings.Join(keys, " "))
+	if p.cfg.PurgeToken != "" {
+		req.Header.Set(p.cfg.PurgeTokenHeader, p.cfg.PurgeToken)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("purge request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("purge rejected with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// HandleProductEvent purges the responses a product appears in when a
+// product.created, product.updated or product.inventory_changed event
+// arrives. Lists are purged too unless only the product's inventory changed,
+// which does not move it in or out of a list.
+func HandleProductEvent(purger Purger) events.EventHandler {
+	return func(ctx context.Context, event *events.Event) error {
+		productID, _ := event.Data["product_id"].(string)
+		if productID == "" {
+			productID = event.Subject
+		}
+		if productID == "" {
+			return fmt.Errorf("malformed %s event %s", event.Type, event.ID)
+		}
+
+		keys := []string{ProductKey(productID)}
+		if event.Type != events.ProductInventoryChanged {
+			keys = append(keys, ProductListKey)
+		}
+		return purger.Purge(ctx, string(event.Type), keys...)
+	}
+}
@@ -0,0 +1,306 @@
+// Package cells places tenants in cells: isolated sets of service instances,
+// each with its own databases. The placement table lives in Redis and every
+// gateway keeps a copy it refreshes, routing a tenant's requests to the
+// instances of its cell. Tenants without a placement are in DefaultCell, the
+// shared instances.
+//
+// Moving a tenant drains it first: while it drains, gateways reject the
+// tenant's writes and keep serving its reads from the old cell, so its data
+// can be copied to the new cell without changing underneath. Once the drain
+// period has passed and the data is copied, completing the move routes the
+// tenant to the new cell.
+package cells
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/redisclient"
+)
+
+// DefaultCell is the cell of tenants without a placement
+const DefaultCell = "default"
+
+// Placement states. A tenant is active in its cell, or draining while it
+// moves to TargetCell.
+const (
+	StateActive   = "active"
+	StateDraining = "draining"
+)
+
+// Errors returned when changing placements
+var (
+	// ErrSameCell is returned when moving a tenant to the cell it is in
+	ErrSameCell = errors.New("tenant is already in this cell")
+	// ErrMoving is returned when moving a tenant that is already moving
+	ErrMoving = errors.New("tenant is already moving")
+	// ErrNotMoving is returned when completing or aborting a move of a
+	// tenant that is not moving
+	ErrNotMoving = errors.New("tenant is not moving")
+	// ErrStillDraining is returned when completing a move before the drain
+	// period has passed
+	ErrStillDraining = errors.New("tenant is still draining")
+	// ErrConflict is returned when a placement changed while it was updated
+	ErrConflict = errors.New("placement changed concurrently")
+)
+
+// Placement is the cell a tenant is in
+type Placement struct {
+	Tenant string `json:"tenant"`
+	Cell   string `json:"cell"`
+	State  string `json:"state"`
+	// TargetCell and DrainUntil are set while the tenant is moving
+	TargetCell string     `json:"target_cell,omitempty"`
+	DrainUntil *time.Time `json:"drain_until,omitempty"`
+	UpdatedBy  string     `json:"updated_by,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Draining reports whether the tenant's writes are held for a move
+func (p *Placement) Draining() bool {
+	return p.State == StateDraining
+}
+
+// StartMove starts moving the tenant to cell, draining it for drain
+func (p *Placement) StartMove(cell, adminID string, drain time.Duration) error {
+	if p.Draining() {
+		return fmt.Errorf("%w to %s", ErrMoving, p.TargetCell)
+	}
+	if cell == p.Cell {
+		return ErrSameCell
+	}
+	now := time.Now().UTC()
+	drainUntil := now.Add(drain)
+	p.State = StateDraining
+	p.TargetCell = cell
+	p.DrainUntil = &drainUntil
+	p.UpdatedBy = adminID
+	p.UpdatedAt = now
+	return nil
+}
+
+// CompleteMove places the tenant in its target cell once the drain period
+// has passed
+func (p *Placement) CompleteMove(adminID string) error {
+	if !p.Draining() {
+		return ErrNotMoving
+	}
+	now := time.Now().UTC()
+	if p.DrainUntil != nil && now.Before(*p.DrainUntil) {
+		return fmt.Errorf("%w until %s", ErrStillDraining, p.DrainUntil.Format(time.RFC3339))
+	}
+	p.Cell = p.TargetCell
+	p.finishMove(adminID, now)
+	return nil
+}
+
+// AbortMove keeps the tenant in its cell and accepts its writes again
+func (p *Placement) AbortMove(adminID string) error {
+	if !p.Draining() {
+		return ErrNotMoving
+	}
+	p.finishMove(adminID, time.Now().UTC())
+	return nil
+}
+
+func (p *Placement) finishMove(adminID string, now time.Time) {
+	p.State = StateActive
+	p.TargetCell = ""
+	p.DrainUntil = nil
+	p.UpdatedBy = adminID
+	p.UpdatedAt = now
+}
+
+// Store keeps the placement table
+type Store interface {
+	// List returns every placement
+	List(ctx context.Context) ([]*Placement, error)
+	// Get returns a tenant's placement, in DefaultCell if it has none
+	Get(ctx context.Context, tenant string) (*Placement, error)
+	// Update applies fn to a tenant's current placement and saves it,
+	// atomically, so two admins cannot move a tenant at once. An error from
+	// fn is returned without saving.
+	Update(ctx context.Context, tenant string, fn func(*Placement) error) (*Placement, error)
+}
+
+// placementsKey is a hash of placements by tenant
+const placementsKey = "cells:placements"
+
+// RedisStore implements Store in Redis
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisStore creates a new Redis-backed placement store
+func NewRedisStore(cfg config.RedisConfig) (*RedisStore, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisStore{client: client}, nil
+}
+
+// List returns every placement
+func (s *RedisStore) List(ctx context.Context) ([]*Placement, error) {
+	values, err := s.client.HGetAll(ctx, placementsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	placements := make([]*Placement, 0, len(values))
+	for _, data := range values {
+		placement, err := unmarshalPlacement([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		placements = append(placements, placement)
+	}
+	return placements, nil
+}
+
+// Get returns a tenant's placement
+func (s *RedisStore) Get(ctx context.Context, tenant string) (*Placement, error) {
+	data, err := s.client.HGet(ctx, placementsKey, tenant).Bytes()
+	if err == redis.Nil {
+		return &Placement{Tenant: tenant, Cell: DefaultCell, State: StateActive}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalPlacement(data)
+}
+
+// Update applies fn to a placement inside a WATCH transaction
+func (s *RedisStore) Update(ctx context.Context, tenant string, fn func(*Placement) error) (*Placement, error) {
+	var updated *Placement
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		placement := &Placement{Tenant: tenant, Cell: DefaultCell, State: StateActive}
+		data, err := tx.HGet(ctx, placementsKey, tenant).Bytes()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err == nil {
+			if placement, err = unmarshalPlacement(data); err != nil {
+				return err
+			}
+		}
+		if err := fn(placement); err != nil {
+			return err
+		}
+
+		data, err = json.Marshal(placement)
+		if err != nil {
+			return fmt.Errorf("failed to marshal placement: %v", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// Tenants back in the default cell need no placement
+			if placement.Cell == DefaultCell && !placement.Draining() {
+				pipe.HDel(ctx, placementsKey, tenant)
+			} else {
+				pipe.HSet(ctx, placementsKey, tenant, data)
+			}
+			return nil
+		})
+		updated = placement
+		return err
+	}, placementsKey)
+	if err == redis.TxFailedErr {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func unmarshalPlacement(data []byte) (*Placement, error) {
+	var placement Placement
+	if err := json.Unmarshal(data, &placement); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal placement: %v", err)
+	}
+	return &placement, nil
+}
+
+// Table is a gateway's copy of the placement table, refreshed from the store
+// every interval. Changes reach every gateway within an interval, which the
+// drain period has to cover.
+type Table struct {
+	store    Store
+	interval time.Duration
+
+	mu         sync.RWMutex
+	placements map[string]*Placement
+}
+
+// NewTable creates a placement table refreshed from store every interval.
+// The caller runs its Start.
+func NewTable(store Store, interval time.Duration) *Table {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &Table{store: store, interval: interval, placements: make(map[string]*Placement)}
+}
+
+// Start refreshes the table until ctx is cancelled. If a refresh fails, the
+// last copy keeps being used.
+func (t *Table) Start(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Refresh(ctx); err != nil {
+			log.Printf("Failed to refresh cell placements: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh loads the placement table from the store
+func (t *Table) Refresh(ctx context.Context) error {
+	list, err := t.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	placements := make(map[string]*Placement, len(list))
+	for _, placement := range list {
+		placements[placement.Tenant] = placement
+	}
+
+	t.mu.Lock()
+	t.placements = placements
+	t.mu.Unlock()
+	return nil
+}
+
+// Set records a placement changed by this gateway, ahead of the next refresh
+func (t *Table) Set(placement *Placement) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.placements[placement.Tenant] = placement
+}
+
+// Placement returns the cell serving a tenant and whether its writes are
+// held for a move
+func (t *Table) Placement(tenant string) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	placement, ok := t.placements[tenant]
+	if !ok {
+		return DefaultCell, false
+	}
+	return placement.Cell, placement.Draining()
+}
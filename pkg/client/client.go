@@ -0,0 +1,287 @@
+// Package client is a Go SDK for the platform's REST API, served by the API
+// gateway under /api/v1. It is meant for internal tools and partners.
+//
+//	c := client.New(client.Config{
+//		BaseURL:  "https://api.example.com",
+//		Email:    "ops@example.com",
+//		Password: os.Getenv("PLATFORM_PASSWORD"),
+//	})
+//	products := c.Products.List(client.ListProductsOptions{Category: "Electronics"})
+//	for products.Next(ctx) {
+//		fmt.Println(products.Item().Name)
+//	}
+//	if err := products.Err(); err != nil {
+//		...
+//	}
+//
+// Errors returned by the API are *APIError values.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiPrefix is the path prefix of the versioned REST API
+const apiPrefix = "/api/v1"
+
+// tokenRefreshMargin is how long before expiry a token obtained from
+// credentials is renewed
+const tokenRefreshMargin = time.Minute
+
+// Config holds the client settings. Either Token or Email and Password
+// authenticate requests; with credentials the client signs in on first use
+// and again whenever its token expires or is rejected.
+type Config struct {
+	// BaseURL is the gateway address, e.g. "https://api.example.com"
+	BaseURL string
+
+	Token    string
+	Email    string
+	Password string
+	// DeviceID identifies this client's device across sign-ins, so signing
+	// in again does not alert the user to a new device. Keep it stable.
+	DeviceID string
+
+	// HTTPClient sends the requests; defaults to a client with Timeout
+	HTTPClient *http.Client
+	Timeout    time.Duration
+
+	// Retry controls retries of failed requests; the zero value uses
+	// DefaultRetryPolicy and NoRetries disables them
+	Retry RetryPolicy
+
+	UserAgent string
+}
+
+// Client is a client of the platform REST API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retry      RetryPolicy
+	userAgent  string
+
+	email    string
+	password string
+	deviceID string
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+
+	Users         *UsersService
+	Products      *ProductsService
+	Orders        *OrdersService
+	Payments      *PaymentsService
+	Notifications *NotificationsService
+}
+
+// New creates a client. Zero settings use defaults: 30s timeout and
+// DefaultRetryPolicy.
+func New(cfg Config) *Client {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		httpClient = &http.Client{Timeout: timeout}
+	}
+	retry := cfg.Retry
+	if retry == (RetryPolicy{}) {
+		retry = DefaultRetryPolicy()
+	}
+	userAgent := cfg.UserAgent
+	if userAgent == "" {
+		userAgent = "microservices-platform-go-client"
+	}
+
+	c := &Client{
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		httpClient: httpClient,
+		retry:      retry,
+		userAgent:  userAgent,
+		email:      cfg.Email,
+		password:   cfg.Password,
+		deviceID:   cfg.DeviceID,
+		token:      cfg.Token,
+	}
+	c.Users = &UsersService{client: c}
+	c.Products = &ProductsService{client: c}
+	c.Orders = &OrdersService{client: c}
+	c.Payments = &PaymentsService{client: c}
+	c.Notifications = &NotificationsService{client: c}
+	return c
+}
+
+// LoginResult is the result of signing in
+type LoginResult struct {
+	AccessToken  string      `json:"access_token"`
+	RefreshToken string      `json:"refresh_token"`
+	ExpiresIn    json.Number `json:"expires_in"`
+	User         *User       `json:"user"`
+}
+
+// Login signs in with an email and password. Later requests use the returned
+// access token.
+func (c *Client) Login(ctx context.Context, email, password string) (*LoginResult, error) {
+	var result LoginResult
+	body := map[string]string{"email": email, "password": password}
+	if c.deviceID != "" {
+		body["device_id"] = c.deviceID
+	}
+	if err := c.send(ctx, http.MethodPost, "/auth/login", nil, body, &result, false); err != nil {
+		return nil, err
+	}
+
+	var expiry time.Time
+	if seconds, err := result.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		expiry = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	c.mu.Lock()
+	c.token = result.AccessToken
+	c.tokenExpiry = expiry
+	c.mu.Unlock()
+	return &result, nil
+}
+
+// SetToken replaces the access token used for requests
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+	c.tokenExpiry = time.Time{}
+}
+
+// accessToken returns the token for a request, signing in with the
+// configured credentials when there is no valid token
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	token, expiry := c.token, c.tokenExpiry
+	c.mu.Unlock()
+
+	expired := !expiry.IsZero() && time.Until(expiry) < tokenRefreshMargin
+	if (token != "" && !expired) || c.email == "" {
+		return token, nil
+	}
+	result, err := c.Login(ctx, c.email, c.password)
+	if err != nil {
+		return "", fmt.Errorf("client: sign in: %w", err)
+	}
+	return result.AccessToken, nil
+}
+
+// do sends an authenticated API request
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	return c.send(ctx, method, path, query, body, out, true)
+}
+
+// send sends a request to path under the API prefix, retrying it according
+// to the retry policy, and decodes a successful JSON response into out
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body, out interface{}, authenticate bool) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("client: encode request: %w", err)
+		}
+	}
+	target := c.baseURL + apiPrefix + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	reauthenticated := false
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("client: %w", err)
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("User-Agent", c.userAgent)
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if authenticate {
+			token, err := c.accessToken(ctx)
+			if err != nil {
+				return err
+			}
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() == nil && attempt < c.retry.MaxRetries && idempotent(method) {
+				if err := c.retry.wait(ctx, attempt, 0); err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("client: %s %s: %w", method, path, err)
+		}
+
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("client: read response: %w", err)
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			if out == nil || len(bytes.TrimSpace(data)) == 0 {
+				return nil
+			}
+			if err := json.Unmarshal(data, out); err != nil {
+				return fmt.Errorf("client: decode response: %w", err)
+			}
+			return nil
+		}
+
+		apiErr := newAPIError(resp, data)
+		// A rejected token obtained from credentials is renewed once
+		if resp.StatusCode == http.StatusUnauthorized && authenticate && c.email != "" && !reauthenticated {
+			reauthenticated = true
+			c.SetToken("")
+			continue
+		}
+		if attempt < c.retry.MaxRetries && retryable(method, resp.StatusCode) {
+			if err := c.retry.wait(ctx, attempt, apiErr.RetryAfter); err != nil {
+				return err
+			}
+			continue
+		}
+		return apiErr
+	}
+}
+
+// idempotent reports whether a request may be repeated without side effects
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a failed request may be retried. Requests
+// rejected by rate limiting or an open circuit were not processed, so they
+// are retried whatever the method.
+func retryable(method string, statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent(method)
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// NotificationsService accesses the /notifications endpoints
+type NotificationsService struct {
+	client *Client
+}
+
+// ListNotificationsOptions filters a notification listing
+type ListNotificationsOptions struct {
+	UserID     string
+	Type       string
+	UnreadOnly bool
+	PageSize   int
+	// Limit stops the iteration after that many notifications; 0 lists all
+	Limit int
+}
+
+// notificationResponse wraps a single notification
+type notificationResponse struct {
+	Notification *Notification `json:"notification"`
+}
+
+// Get returns a notification
+func (s *NotificationsService) Get(ctx context.Context, id string) (*Notification, error) {
+	var resp notificationResponse
+	if err := s.client.do(ctx, http.MethodGet, "/notifications/"+url.PathEscape(id), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Notification, nil
+}
+
+// MarkAsRead marks a notification as read
+func (s *NotificationsService) MarkAsRead(ctx context.Context, id string) (*Notification, error) {
+	var resp notificationResponse
+	if err := s.client.do(ctx, http.MethodPut, "/notifications/"+url.PathEscape(id)+"/read", nil, struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Notification, nil
+}
+
+// Delete deletes a notification
+func (s *NotificationsService) Delete(ctx context.Context, id string) error {
+	return s.client.do(ctx, http.MethodDelete, "/notifications/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// List iterates over notifications
+func (s *NotificationsService) List(opts ListNotificationsOptions) *Iterator[Notification] {
+	query := url.Values{}
+	setIfNotEmpty(query, "user_id", opts.UserID)
+	setIfNotEmpty(query, "type", opts.Type)
+	if opts.UnreadOnly {
+		query.Set("unread_only", "true")
+	}
+	return listPages[Notification](s.client, "/notifications", query, opts.PageSize, opts.Limit)
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// OrdersService accesses the /orders endpoints
+type OrdersService struct {
+	client *Client
+}
+
+// CreateOrderRequest holds a new order. Saved address IDs take precedence
+// over the free-text addresses; without either the user's default address is
+// used.
+type CreateOrderRequest struct {
+	UserID            string            `json:"user_id"`
+	Items             []CreateOrderItem `json:"items"`
+	ShippingAddressID string            `json:"shipping_address_id,omitempty"`
+	BillingAddressID  string            `json:"billing_address_id,omitempty"`
+	ShippingAddress   string            `json:"shipping_address,omitempty"`
+	BillingAddress    string            `json:"billing_address,omitempty"`
+	// OrganizationID places the order for an organization the user belongs to
+	OrganizationID string `json:"organization_id,omitempty"`
+	// ShippingMethod is one of the configured methods, e.g. "express"; the
+	// default method is used if it is empty
+	ShippingMethod string `json:"shipping_method,omitempty"`
+}
+
+// CreateOrderItem is a line of a new order
+type CreateOrderItem struct {
+	ProductID string `json:"product_id"`
+	Quantity  int32  `json:"quantity"`
+}
+
+// ListOrdersOptions filters an order listing
+type ListOrdersOptions struct {
+	UserID string
+	// OrganizationID lists an organization's orders instead of the user's
+	OrganizationID string
+	Status         string
+	PageSize       int
+	// Limit stops the iteration after that many orders; 0 lists all
+	Limit int
+}
+
+// orderResponse wraps a single order
+type orderResponse struct {
+	Order *Order `json:"order"`
+}
+
+// Create places an order
+func (s *OrdersService) Create(ctx context.Context, req CreateOrderRequest) (*Order, error) {
+	var resp orderResponse
+	if err := s.client.do(ctx, http.MethodPost, "/orders", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Order, nil
+}
+
+// Get returns an order
+func (s *OrdersService) Get(ctx context.Context, id string) (*Order, error) {
+	var resp orderResponse
+	if err := s.client.do(ctx, http.MethodGet, "/orders/"+url.PathEscape(id), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Order, nil
+}
+
+// UpdateStatus moves an order to a new status, e.g. "confirmed"
+func (s *OrdersService) UpdateStatus(ctx context.Context, id, status string) (*Order, error) {
+	var resp orderResponse
+	body := map[string]string{"status": status}
+	if err := s.client.do(ctx, http.MethodPut, "/orders/"+url.PathEscape(id)+"/status", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Order, nil
+}
+
+// Cancel cancels an order that has not shipped
+func (s *OrdersService) Cancel(ctx context.Context, id, reason string) (*Order, error) {
+	var resp orderResponse
+	body := map[string]string{"reason": reason}
+	if err := s.client.do(ctx, http.MethodPost, "/orders/"+url.PathEscape(id)+"/cancel", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Order, nil
+}
+
+// CreateShipmentRequest ships items of an order
+type CreateShipmentRequest struct {
+	// Items to ship; empty ships everything not yet shipped
+	Items          []ShipmentItem `json:"items,omitempty"`
+	Carrier        string         `json:"carrier,omitempty"`
+	TrackingNumber string         `json:"tracking_number,omitempty"`
+}
+
+// CreateShipment ships items of an order, capturing their price from the
+// order's authorized payment
+func (s *OrdersService) CreateShipment(ctx context.Context, id string, req CreateShipmentRequest) (*Shipment, *Order, error) {
+	var resp struct {
+		Shipment *Shipment `json:"shipment"`
+		Order    *Order    `json:"order"`
+	}
+	if err := s.client.do(ctx, http.MethodPost, "/orders/"+url.PathEscape(id)+"/shipments", nil, req, &resp); err != nil {
+		return nil, nil, err
+	}
+	return resp.Shipment, resp.Order, nil
+}
+
+// ListShipments returns an order's shipments
+func (s *OrdersService) ListShipments(ctx context.Context, id string) ([]Shipment, error) {
+	var resp listEnvelope[Shipment]
+	if err := s.client.do(ctx, http.MethodGet, "/orders/"+url.PathEscape(id)+"/shipments", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// List iterates over orders
+func (s *OrdersService) List(opts ListOrdersOptions) *Iterator[Order] {
+	query := url.Values{}
+	setIfNotEmpty(query, "user_id", opts.UserID)
+	setIfNotEmpty(query, "organization_id", opts.OrganizationID)
+	setIfNotEmpty(query, "status", opts.Status)
+	return listPages[Order](s.client, "/orders", query, opts.PageSize, opts.Limit)
+}
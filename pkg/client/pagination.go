@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// defaultPageSize is the page size of list requests that don't set one
+const defaultPageSize = 50
+
+// listEnvelope is the shape of every list response
+type listEnvelope[T any] struct {
+	Data       []T `json:"data"`
+	Pagination struct {
+		NextCursor string `json:"next_cursor"`
+		Total      int    `json:"total"`
+	} `json:"pagination"`
+}
+
+// pageFetcher fetches the page of a list a cursor points at, or the first
+// page for an empty cursor
+type pageFetcher[T any] func(ctx context.Context, cursor string, size int) (*listEnvelope[T], error)
+
+// Iterator walks the items of a paginated list, fetching pages as needed:
+//
+//	for it.Next(ctx) {
+//		item := it.Item()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator[T any] struct {
+	fetch    pageFetcher[T]
+	pageSize int
+	limit    int
+
+	cursor  string
+	buffer  []T
+	current T
+	seen    int
+	total   int
+	last    bool
+	err     error
+}
+
+// newIterator creates an iterator over a list. limit > 0 stops after that
+// many items.
+func newIterator[T any](fetch pageFetcher[T], pageSize, limit int) *Iterator[T] {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	return &Iterator[T]{fetch: fetch, pageSize: pageSize, limit: limit}
+}
+
+// Next advances to the next item, fetching the next page when the current one
+// is exhausted. It returns false at the end of the list or on error.
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	if it.err != nil || (it.limit > 0 && it.seen >= it.limit) {
+		return false
+	}
+	for len(it.buffer) == 0 {
+		if it.last {
+			return false
+		}
+		page, err := it.fetch(ctx, it.cursor, it.pageSize)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.buffer = page.Data
+		it.total = page.Pagination.Total
+		it.cursor = page.Pagination.NextCursor
+		it.last = it.cursor == ""
+	}
+
+	it.current = it.buffer[0]
+	it.buffer = it.buffer[1:]
+	it.seen++
+	return true
+}
+
+// Item returns the current item
+func (it *Iterator[T]) Item() T {
+	return it.current
+}
+
+// Total returns the number of items in the list as of the last page fetched
+func (it *Iterator[T]) Total() int {
+	return it.total
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All collects the remaining items
+func (it *Iterator[T]) All(ctx context.Context) ([]T, error) {
+	var items []T
+	for it.Next(ctx) {
+		items = append(items, it.Item())
+	}
+	return items, it.Err()
+}
+
+// listPages creates an iterator over the list at path. After the first page
+// the cursor carries the page size and position, and query the filters.
+func listPages[T any](c *Client, path string, query url.Values, pageSize, limit int) *Iterator[T] {
+	return newIterator(func(ctx context.Context, cursor string, size int) (*listEnvelope[T], error) {
+		params := url.Values{}
+		for name, values := range query {
+			params[name] = values
+		}
+		if cursor != "" {
+			params.Set("cursor", cursor)
+		} else {
+			params.Set("page_size", strconv.Itoa(size))
+		}
+		var page listEnvelope[T]
+		if err := c.do(ctx, http.MethodGet, path, params, nil, &page); err != nil {
+			return nil, err
+		}
+		return &page, nil
+	}, pageSize, limit)
+}
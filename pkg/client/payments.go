@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// PaymentsService accesses the /payments endpoints
+type PaymentsService struct {
+	client *Client
+}
+
+// ListPaymentsOptions filters a payment listing
+type ListPaymentsOptions struct {
+	UserID   string
+	OrderID  string
+	Status   string
+	PageSize int
+	// Limit stops the iteration after that many payments; 0 lists all
+	Limit int
+}
+
+// RefundResult is the outcome of a refund
+type RefundResult struct {
+	Payment  *Payment `json:"payment"`
+	RefundID string   `json:"refund_id"`
+}
+
+// CreatePaymentRequest pays for an order
+type CreatePaymentRequest struct {
+	OrderID  string  `json:"order_id"`
+	UserID   string  `json:"user_id"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+	// Method is e.g. "PAYMENT_METHOD_CREDIT_CARD"
+	Method string       `json:"method"`
+	Card   *PaymentCard `json:"card_details,omitempty"`
+	// CaptureMethod "CAPTURE_METHOD_MANUAL" only authorizes the amount, to
+	// be captured as the order ships
+	CaptureMethod string `json:"capture_method,omitempty"`
+	// Retries of the same payment reuse the key so it is only collected once
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// PaymentCard holds card details of a payment
+type PaymentCard struct {
+	Number      string `json:"card_number"`
+	ExpiryMonth string `json:"expiry_month"`
+	ExpiryYear  string `json:"expiry_year"`
+	CVV         string `json:"cvv"`
+	HolderName  string `json:"holder_name"`
+}
+
+// PaymentResult is the outcome of a payment. RedirectURL is set when the
+// payer must complete the payment with the provider.
+type PaymentResult struct {
+	Payment     *Payment `json:"payment"`
+	RedirectURL string   `json:"redirect_url,omitempty"`
+}
+
+// Create pays for an order
+func (s *PaymentsService) Create(ctx context.Context, req CreatePaymentRequest) (*PaymentResult, error) {
+	var result PaymentResult
+	if err := s.client.do(ctx, http.MethodPost, "/payments", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Get returns a payment
+func (s *PaymentsService) Get(ctx context.Context, id string) (*Payment, error) {
+	var resp struct {
+		Payment *Payment `json:"payment"`
+	}
+	if err := s.client.do(ctx, http.MethodGet, "/payments/"+url.PathEscape(id), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Payment, nil
+}
+
+// Refund refunds amount of a payment; 0 refunds it in full
+func (s *PaymentsService) Refund(ctx context.Context, id string, amount float64, reason string) (*RefundResult, error) {
+	body := map[string]interface{}{"amount": amount, "reason": reason}
+	var result RefundResult
+	if err := s.client.do(ctx, http.MethodPost, "/payments/"+url.PathEscape(id)+"/refund", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CaptureResult is the outcome of a capture
+type CaptureResult struct {
+	Payment *Payment `json:"payment"`
+	Capture *Capture `json:"capture"`
+}
+
+// Capture captures amount of an authorized payment; final releases the rest
+// of the authorization
+func (s *PaymentsService) Capture(ctx context.Context, id string, amount float64, final bool, idempotencyKey string) (*CaptureResult, error) {
+	body := map[string]interface{}{"amount": amount, "final_capture": final, "idempotency_key": idempotencyKey}
+	var result CaptureResult
+	if err := s.client.do(ctx, http.MethodPost, "/payments/"+url.PathEscape(id)+"/captures", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Void releases an authorized payment nothing was captured from
+func (s *PaymentsService) Void(ctx context.Context, id, reason string) (*Payment, error) {
+	body := map[string]string{"reason": reason}
+	var resp struct {
+		Payment *Payment `json:"payment"`
+	}
+	if err := s.client.do(ctx, http.MethodPost, "/payments/"+url.PathEscape(id)+"/void", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Payment, nil
+}
+
+// List iterates over payments
+func (s *PaymentsService) List(opts ListPaymentsOptions) *Iterator[Payment] {
+	query := url.Values{}
+	setIfNotEmpty(query, "user_id", opts.UserID)
+	setIfNotEmpty(query, "order_id", opts.OrderID)
+	setIfNotEmpty(query, "status", opts.Status)
+	return listPages[Payment](s.client, "/payments", query, opts.PageSize, opts.Limit)
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ProductsService accesses the catalog. Creating and changing products
+// requires an admin token.
+type ProductsService struct {
+	client *Client
+}
+
+// ProductRequest holds the fields of a created or updated product
+type ProductRequest struct {
+	Name              string   `json:"name,omitempty"`
+	Description       string   `json:"description,omitempty"`
+	Price             float64  `json:"price,omitempty"`
+	Category          string   `json:"category,omitempty"`
+	Brand             string   `json:"brand,omitempty"`
+	SKU               string   `json:"sku,omitempty"`
+	InventoryQuantity int32    `json:"inventory_quantity,omitempty"`
+	Images            []string `json:"images,omitempty"`
+}
+
+// ListProductsOptions filters a product listing
+type ListProductsOptions struct {
+	Category string
+	Brand    string
+	// OrganizationID lists an organization's private catalog
+	OrganizationID string
+	PageSize       int
+	// Limit stops the iteration after that many products; 0 lists all
+	Limit int
+}
+
+// SearchProductsOptions narrows a product search
+type SearchProductsOptions struct {
+	Category string
+	MinPrice float64
+	MaxPrice float64
+	PageSize int
+	// Limit stops the iteration after that many products; 0 lists all
+	Limit int
+}
+
+// productResponse wraps a single product
+type productResponse struct {
+	Product *Product `json:"product"`
+}
+
+// Get returns a product
+func (s *ProductsService) Get(ctx context.Context, id string) (*Product, error) {
+	var resp productResponse
+	if err := s.client.do(ctx, http.MethodGet, "/products/"+url.PathEscape(id), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Product, nil
+}
+
+// List iterates over the catalog
+func (s *ProductsService) List(opts ListProductsOptions) *Iterator[Product] {
+	query := url.Values{}
+	setIfNotEmpty(query, "category", opts.Category)
+	setIfNotEmpty(query, "brand", opts.Brand)
+	setIfNotEmpty(query, "organization_id", opts.OrganizationID)
+	return listPages[Product](s.client, "/products", query, opts.PageSize, opts.Limit)
+}
+
+// Search iterates over the products matching a query
+func (s *ProductsService) Search(query string, opts SearchProductsOptions) *Iterator[Product] {
+	params := url.Values{"query": {query}}
+	setIfNotEmpty(params, "category", opts.Category)
+	if opts.MinPrice > 0 {
+		params.Set("min_price", strconv.FormatFloat(opts.MinPrice, 'f', -1, 64))
+	}
+	if opts.MaxPrice > 0 {
+		params.Set("max_price", strconv.FormatFloat(opts.MaxPrice, 'f', -1, 64))
+	}
+	return listPages[Product](s.client, "/products/search", params, opts.PageSize, opts.Limit)
+}
+
+// Create adds a product to the catalog
+func (s *ProductsService) Create(ctx context.Context, req ProductRequest) (*Product, error) {
+	var resp productResponse
+	if err := s.client.do(ctx, http.MethodPost, "/admin/products", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Product, nil
+}
+
+// Update changes a product
+func (s *ProductsService) Update(ctx context.Context, id string, req ProductRequest) (*Product, error) {
+	var resp productResponse
+	if err := s.client.do(ctx, http.MethodPut, "/admin/products/"+url.PathEscape(id), nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Product, nil
+}
+
+// Patch changes the product fields named in patch, a JSON merge patch such
+// as {"price": 19.99, "description": nil}; fields set to nil are cleared
+func (s *ProductsService) Patch(ctx context.Context, id string, patch map[string]interface{}) (*Product, error) {
+	var resp productResponse
+	if err := s.client.do(ctx, http.MethodPatch, "/admin/products/"+url.PathEscape(id), nil, patch, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Product, nil
+}
+
+// Delete removes a product from the catalog
+func (s *ProductsService) Delete(ctx context.Context, id string) error {
+	return s.client.do(ctx, http.MethodDelete, "/admin/products/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// UpdateInventory changes a product's stock by quantityChange
+func (s *ProductsService) UpdateInventory(ctx context.Context, id string, quantityChange int32, reason string) (*Product, error) {
+	body := map[string]interface{}{"quantity_change": quantityChange, "reason": reason}
+	var resp productResponse
+	if err := s.client.do(ctx, http.MethodPut, "/admin/products/"+url.PathEscape(id)+"/inventory", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Product, nil
+}
+
+// setIfNotEmpty sets a query parameter unless its value is empty
+func setIfNotEmpty(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried. Idempotent requests
+// are retried on network errors and 429, 502, 503 and 504 responses; other
+// requests only on 429 and 503, which the gateway returns before forwarding.
+// The delay doubles from BaseDelay up to MaxDelay with jitter, unless the
+// response carries a Retry-After header.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultRetryPolicy returns the default retry policy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  200 * time.Millisecond,
+		MaxDelay:   5 * time.Second,
+	}
+}
+
+// NoRetries is a policy that never retries
+var NoRetries = RetryPolicy{MaxRetries: -1}
+
+// wait sleeps before retry attempt+1, or until ctx is cancelled
+func (p RetryPolicy) wait(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	delay := retryAfter
+	if delay <= 0 {
+		delay = p.BaseDelay << attempt
+		if p.MaxDelay > 0 && (delay > p.MaxDelay || delay <= 0) {
+			delay = p.MaxDelay
+		}
+		// Full jitter keeps clients retrying together from hitting the
+		// gateway in lockstep
+		if delay > 0 {
+			delay = time.Duration(rand.Int63n(int64(delay)) + 1)
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package client
+
+import "time"
+
+// User is a platform user
+type User struct {
+	ID                    string     `json:"user_id"`
+	Email                 string     `json:"email"`
+	Username              string     `json:"username"`
+	FirstName             string     `json:"first_name"`
+	LastName              string     `json:"last_name"`
+	Status                string     `json:"status"`
+	Role                  string     `json:"role"`
+	AvatarURL             string     `json:"avatar_url,omitempty"`
+	PasswordResetRequired bool       `json:"password_reset_required,omitempty"`
+	SuspensionReason      string     `json:"suspension_reason,omitempty"`
+	SuspendedAt           *time.Time `json:"suspended_at,omitempty"`
+	MarketingOptOut       bool       `json:"marketing_opt_out,omitempty"`
+	Locale                string     `json:"locale,omitempty"`
+	TimeZone              string     `json:"time_zone,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+	Version               int64      `json:"version,string,omitempty"`
+}
+
+// Product is a catalog product
+type Product struct {
+	ID                string    `json:"product_id"`
+	Name              string    `json:"name"`
+	Description       string    `json:"description"`
+	Price             float64   `json:"price"`
+	Category          string    `json:"category"`
+	Brand             string    `json:"brand"`
+	SKU               string    `json:"sku"`
+	InventoryQuantity int32     `json:"inventory_quantity"`
+	Images            []string  `json:"images,omitempty"`
+	Status            string    `json:"status"`
+	OrganizationID    string    `json:"organization_id,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	Version           int64     `json:"version,string,omitempty"`
+}
+
+// Order is a placed order
+type Order struct {
+	ID                string      `json:"order_id"`
+	UserID            string      `json:"user_id"`
+	OrganizationID    string      `json:"organization_id,omitempty"`
+	Items             []OrderItem `json:"items"`
+	TotalAmount       float64     `json:"total_amount"`
+	Status            string      `json:"status"`
+	ShippingAddress   string      `json:"shipping_address"`
+	BillingAddress    string      `json:"billing_address"`
+	ShippingAddressID string      `json:"shipping_address_id,omitempty"`
+	BillingAddressID  string      `json:"billing_address_id,omitempty"`
+	CreatedAt         time.Time   `json:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at"`
+	Version           int64       `json:"version,string,omitempty"`
+
+	// Delivery estimate, revised as the carrier reports tracking updates
+	ShippingMethod            string     `json:"shipping_method,omitempty"`
+	EstimatedDeliveryEarliest *time.Time `json:"estimated_delivery_earliest,omitempty"`
+	EstimatedDeliveryLatest   *time.Time `json:"estimated_delivery_latest,omitempty"`
+	EstimateSource            string     `json:"estimate_source,omitempty"`
+	Carrier                   string     `json:"carrier,omitempty"`
+	TrackingNumber            string     `json:"tracking_number,omitempty"`
+	DeliveredAt               *time.Time `json:"delivered_at,omitempty"`
+
+	// Authorized payment, captured as the order ships
+	PaymentID              string     `json:"payment_id,omitempty"`
+	PaymentStatus          string     `json:"payment_status,omitempty"`
+	AuthorizedAmount       float64    `json:"authorized_amount,omitempty"`
+	CapturedAmount         float64    `json:"captured_amount,omitempty"`
+	AuthorizationExpiresAt *time.Time `json:"authorization_expires_at,omitempty"`
+}
+
+// Shipment is a part of an order shipped together
+type Shipment struct {
+	ID             string         `json:"shipment_id"`
+	OrderID        string         `json:"order_id"`
+	Items          []ShipmentItem `json:"items"`
+	Amount         float64        `json:"amount"`
+	CaptureID      string         `json:"capture_id,omitempty"`
+	Carrier        string         `json:"carrier,omitempty"`
+	TrackingNumber string         `json:"tracking_number,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// ShipmentItem is the quantity of an order item in a shipment
+type ShipmentItem struct {
+	ItemID   string `json:"item_id"`
+	Quantity int32  `json:"quantity"`
+}
+
+// OrderItem is a line of an order
+type OrderItem struct {
+	ID          string  `json:"item_id"`
+	ProductID   string  `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Quantity    int32   `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	TotalPrice  float64 `json:"total_price"`
+}
+
+// Payment is a payment for an order
+type Payment struct {
+	ID            string    `json:"payment_id"`
+	OrderID       string    `json:"order_id"`
+	UserID        string    `json:"user_id"`
+	Amount        float64   `json:"amount"`
+	Currency      string    `json:"currency"`
+	Method        string    `json:"method"`
+	Status        string    `json:"status"`
+	TransactionID string    `json:"transaction_id"`
+	Gateway       string    `json:"gateway"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	// Set for payments authorized to be captured later
+	CaptureMethod          string     `json:"capture_method,omitempty"`
+	CapturedAmount         float64    `json:"captured_amount,omitempty"`
+	RefundedAmount         float64    `json:"refunded_amount,omitempty"`
+	AuthorizationExpiresAt *time.Time `json:"authorization_expires_at,omitempty"`
+	Captures               []Capture  `json:"captures,omitempty"`
+}
+
+// Capture is an amount captured from an authorized payment
+type Capture struct {
+	ID         string    `json:"capture_id"`
+	Amount     float64   `json:"amount"`
+	ShipmentID string    `json:"shipment_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Notification is a notification sent to a user
+type Notification struct {
+	ID        string            `json:"notification_id"`
+	UserID    string            `json:"user_id"`
+	Title     string            `json:"title"`
+	Message   string            `json:"message"`
+	Type      string            `json:"type"`
+	Channel   string            `json:"channel"`
+	Status    string            `json:"status"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Read      bool              `json:"read"`
+	CreatedAt time.Time         `json:"created_at"`
+	ReadAt    *time.Time        `json:"read_at,omitempty"`
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// UsersService accesses the /users endpoints
+type UsersService struct {
+	client *Client
+}
+
+// CreateUserRequest holds the fields of a new user
+type CreateUserRequest struct {
+	Email     string `json:"email"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+}
+
+// UpdateUserRequest holds the user fields to change. Empty fields are left
+// unchanged.
+type UpdateUserRequest struct {
+	Email           string `json:"email,omitempty"`
+	Username        string `json:"username,omitempty"`
+	FirstName       string `json:"first_name,omitempty"`
+	LastName        string `json:"last_name,omitempty"`
+	MarketingOptOut *bool  `json:"marketing_opt_out,omitempty"`
+	Locale          string `json:"locale,omitempty"`
+	TimeZone        string `json:"time_zone,omitempty"`
+}
+
+// DeletionResult reports the progress of a user deletion
+type DeletionResult struct {
+	Success    bool   `json:"success"`
+	DeletionID string `json:"deletion_id"`
+	Status     string `json:"status"`
+}
+
+// ListUsersOptions filters a user listing
+type ListUsersOptions struct {
+	// Filter searches email, username and names
+	Filter   string
+	PageSize int
+	// Limit stops the iteration after that many users; 0 lists all
+	Limit int
+}
+
+// userResponse wraps a single user
+type userResponse struct {
+	User *User `json:"user"`
+}
+
+// Create creates a user
+func (s *UsersService) Create(ctx context.Context, req CreateUserRequest) (*User, error) {
+	var resp userResponse
+	if err := s.client.do(ctx, http.MethodPost, "/users", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.User, nil
+}
+
+// Get returns a user
+func (s *UsersService) Get(ctx context.Context, id string) (*User, error) {
+	var resp userResponse
+	if err := s.client.do(ctx, http.MethodGet, "/users/"+url.PathEscape(id), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.User, nil
+}
+
+// Update changes a user's profile
+func (s *UsersService) Update(ctx context.Context, id string, req UpdateUserRequest) (*User, error) {
+	var resp userResponse
+	if err := s.client.do(ctx, http.MethodPut, "/users/"+url.PathEscape(id), nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.User, nil
+}
+
+// Patch changes the user fields named in patch, a JSON merge patch such as
+// {"last_name": nil, "marketing_opt_out": true}; fields set to nil are cleared
+func (s *UsersService) Patch(ctx context.Context, id string, patch map[string]interface{}) (*User, error) {
+	var resp userResponse
+	if err := s.client.do(ctx, http.MethodPatch, "/users/"+url.PathEscape(id), nil, patch, &resp); err != nil {
+		return nil, err
+	}
+	return resp.User, nil
+}
+
+// Delete starts the deletion of a user and their data across services
+func (s *UsersService) Delete(ctx context.Context, id string) (*DeletionResult, error) {
+	var result DeletionResult
+	if err := s.client.do(ctx, http.MethodDelete, "/users/"+url.PathEscape(id), nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// List iterates over users
+func (s *UsersService) List(opts ListUsersOptions) *Iterator[User] {
+	query := url.Values{}
+	if opts.Filter != "" {
+		query.Set("filter", opts.Filter)
+	}
+	return listPages[User](s.client, "/users", query, opts.PageSize, opts.Limit)
+}
+
+// ImportUsersRequest holds a file of users to import. Importing requires an
+// admin token.
+type ImportUsersRequest struct {
+	AdminUserID string `json:"admin_user_id"`
+	// Data is the file's contents, in Format: "csv" or "ndjson"
+	Data   []byte `json:"data"`
+	Format string `json:"format"`
+	// OnDuplicate is "skip" (default), "update" or "fail"
+	OnDuplicate string `json:"on_duplicate,omitempty"`
+	DryRun      bool   `json:"dry_run,omitempty"`
+}
+
+// ImportUsersResult reports the outcome of each row of an import
+type ImportUsersResult struct {
+	Results      []ImportUserResult `json:"results"`
+	CreatedCount int                `json:"created_count"`
+	UpdatedCount int                `json:"updated_count"`
+	SkippedCount int                `json:"skipped_count"`
+	FailedCount  int                `json:"failed_count"`
+}
+
+// ImportUserResult is the outcome of one row of an import
+type ImportUserResult struct {
+	Row    int    `json:"row"`
+	Email  string `json:"email"`
+	UserID string `json:"user_id,omitempty"`
+	// Status is "created", "updated", "skipped" or "failed"
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Import imports users from a CSV or NDJSON file
+func (s *UsersService) Import(ctx context.Context, req ImportUsersRequest) (*ImportUsersResult, error) {
+	var result ImportUsersResult
+	if err := s.client.do(ctx, http.MethodPost, "/admin/users/import", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,149 @@
+// Package clusterconfig reads platform configuration (route limits, rate
+// limits and feature flags) from a Kubernetes ConfigMap or PlatformConfig
+// custom resource, and watches it so changes apply without a restart. It is
+// an alternative to environment variables for cluster deployments; settings
+// it does not cover still come from the environment.
+package clusterconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Kinds of object a Source can name
+const (
+	KindConfigMap      = "configmap"
+	KindPlatformConfig = "platformconfig"
+)
+
+// Config holds the loader's settings. Source names the object to read as
+// "configmap/<name>" or "platformconfig/<name>"; empty disables the loader.
+// Namespace defaults to the pod's own.
+type Config struct {
+	Source    string
+	Namespace string
+}
+
+// Enabled reports whether a source is configured
+func (c Config) Enabled() bool {
+	return c.Source != ""
+}
+
+// LoadConfig loads the loader's settings from environment variables
+func LoadConfig() Config {
+	return Config{
+		Source:    os.Getenv("KUBE_CONFIG_SOURCE"),
+		Namespace: os.Getenv("KUBE_CONFIG_NAMESPACE"),
+	}
+}
+
+// Platform is the configuration read from the cluster. A nil or empty
+// section leaves the settings it covers as the environment set them.
+type Platform struct {
+	// Routes overrides the limits of gateway routes, keyed by
+	// "METHOD /route/:param"
+	Routes map[string]Route `json:"routes,omitempty"`
+	// RateLimits replaces the gateway's rate limit window and tier limits
+	RateLimits *RateLimits `json:"rateLimits,omitempty"`
+	// FeatureFlags sets who each soft launch is open to, by launch
+	FeatureFlags map[string]FeatureFlag `json:"featureFlags,omitempty"`
+}
+
+// Route overrides a gateway route's limits. Zero fields keep the route's
+// own settings.
+type Route struct {
+	Timeout      Duration `json:"timeout,omitempty"`
+	MaxBodySize  int64    `json:"maxBodySize,omitempty"`
+	ContentTypes []string `json:"contentTypes,omitempty"`
+}
+
+// RateLimits sets the requests allowed per window for each tier. Tiers not
+// listed keep their limits.
+type RateLimits struct {
+	Window Duration       `json:"window,omitempty"`
+	Tiers  map[string]int `json:"tiers,omitempty"`
+}
+
+// FeatureFlag is who a soft launch is open to, see middleware.SoftLaunch
+type FeatureFlag struct {
+	Tenants []string `json:"tenants,omitempty"`
+	Users   []string `json:"users,omitempty"`
+	Percent int      `json:"percent,omitempty"`
+	Visible bool     `json:"visible,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "30s"
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Validate reports settings that can't be applied
+func (p *Platform) Validate() error {
+	for route, limits := range p.Routes {
+		if _, path, ok := strings.Cut(route, " "); !ok || !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("route %q must be written as \"METHOD /path\"", route)
+		}
+		if limits.Timeout < 0 || limits.MaxBodySize < 0 {
+			return fmt.Errorf("route %q has a negative limit", route)
+		}
+	}
+	if p.RateLimits != nil {
+		if p.RateLimits.Window < 0 {
+			return fmt.Errorf("rate limit window must not be negative")
+		}
+		for tier, limit := range p.RateLimits.Tiers {
+			if limit <= 0 {
+				return fmt.Errorf("rate limit of tier %q must be positive", tier)
+			}
+		}
+	}
+	for flag, settings := range p.FeatureFlags {
+		if settings.Percent < 0 || settings.Percent > 100 {
+			return fmt.Errorf("feature flag %q percent must be between 0 and 100", flag)
+		}
+	}
+	return nil
+}
+
+// configMapKeys are the ConfigMap keys holding each section as JSON
+var configMapKeys = []string{"routes", "rateLimits", "featureFlags"}
+
+// fromConfigMap decodes the sections of a ConfigMap's data
+func fromConfigMap(data map[string]string) (*Platform, error) {
+	platform := &Platform{}
+	targets := map[string]interface{}{
+		"routes":       &platform.Routes,
+		"rateLimits":   &platform.RateLimits,
+		"featureFlags": &platform.FeatureFlags,
+	}
+	for _, key := range configMapKeys {
+		value := strings.TrimSpace(data[key])
+		if value == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(value), targets[key]); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", key, err)
+		}
+	}
+	return platform, nil
+}
@@ -0,0 +1,188 @@
+package clusterconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"microservices-platform/pkg/kubeapi"
+	"microservices-platform/pkg/metrics"
+)
+
+// PlatformConfig custom resource, defined in k8s/platform-config.yaml
+const (
+	platformConfigGroup   = "platform.microservices.io"
+	platformConfigVersion = "v1alpha1"
+	platformConfigPlural  = "platformconfigs"
+)
+
+// maxWatchBackoff caps the wait before retrying a failed watch
+const maxWatchBackoff = time.Minute
+
+// Apply applies a configuration read from the cluster. An error rejects it
+// and the previous configuration stays in effect.
+type Apply func(*Platform) error
+
+// Watcher reads a ConfigMap or PlatformConfig from the Kubernetes API and
+// applies it again whenever it changes
+type Watcher struct {
+	kind   string
+	name   string
+	apply  Apply
+	client *kubeapi.Client
+
+	resourceVersion string
+}
+
+// NewWatcher creates a watcher for the object cfg.Source names, using the
+// pod's service account to reach the Kubernetes API
+func NewWatcher(cfg Config, apply Apply) (*Watcher, error) {
+	kind, name, ok := strings.Cut(cfg.Source, "/")
+	kind = strings.ToLower(kind)
+	if !ok || name == "" || (kind != KindConfigMap && kind != KindPlatformConfig) {
+		return nil, fmt.Errorf("invalid source %q, expected configmap/<name> or platformconfig/<name>", cfg.Source)
+	}
+
+	client, err := kubeapi.NewInCluster(cfg.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{kind: kind, name: name, apply: apply, client: client}, nil
+}
+
+// source identifies the watched object in logs and metrics
+func (w *Watcher) source() string {
+	return w.kind + "/" + w.client.Namespace() + "/" + w.name
+}
+
+// collection returns the API path of the watched object's collection
+func (w *Watcher) collection() string {
+	if w.kind == KindConfigMap {
+		return "/api/v1/namespaces/" + w.client.Namespace() + "/configmaps"
+	}
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", platformConfigGroup, platformConfigVersion, w.client.Namespace(), platformConfigPlural)
+}
+
+// object is the part of a ConfigMap or PlatformConfig the watcher reads
+type object struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Data map[string]string `json:"data"`
+	Spec *Platform         `json:"spec"`
+}
+
+// platform decodes the object's configuration
+func (w *Watcher) platform(obj *object) (*Platform, error) {
+	if w.kind == KindConfigMap {
+		return fromConfigMap(obj.Data)
+	}
+	if obj.Spec == nil {
+		return &Platform{}, nil
+	}
+	return obj.Spec, nil
+}
+
+// Load reads the object and applies it
+func (w *Watcher) Load(ctx context.Context) error {
+	var obj object
+	if err := w.client.Get(ctx, w.collection()+"/"+url.PathEscape(w.name), &obj); err != nil {
+		return err
+	}
+	return w.update(&obj)
+}
+
+// Start watches the object until ctx is cancelled, applying every change.
+// Call Load first. A deleted object leaves the last configuration in effect.
+func (w *Watcher) Start(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := w.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case err == nil:
+			backoff = time.Second
+			continue
+		case errors.Is(err, kubeapi.ErrGone):
+			err = w.Load(ctx)
+		}
+		if err != nil {
+			log.Printf("Watching %s failed, retrying in %s: %v", w.source(), backoff, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > maxWatchBackoff {
+				backoff = maxWatchBackoff
+			}
+		}
+	}
+}
+
+// watch runs one watch request from the last resource version seen, until
+// the API server ends it
+func (w *Watcher) watch(ctx context.Context) error {
+	stream, err := w.client.Watch(ctx, w.collection(), w.name, w.resourceVersion)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	for {
+		event, err := stream.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			var obj object
+			if err := json.Unmarshal(event.Object, &obj); err != nil {
+				return fmt.Errorf("invalid watch event: %v", err)
+			}
+			if err := w.update(&obj); err != nil {
+				log.Printf("WARNING: %v", err)
+			}
+		case "DELETED":
+			log.Printf("WARNING: %s was deleted, keeping its last configuration", w.source())
+		}
+	}
+}
+
+// update applies the object unless this version was applied already.
+// Configuration that fails to decode, validate or apply is rejected.
+func (w *Watcher) update(obj *object) error {
+	version := obj.Metadata.ResourceVersion
+	if version != "" && version == w.resourceVersion {
+		return nil
+	}
+	w.resourceVersion = version
+
+	platform, err := w.platform(obj)
+	if err == nil {
+		err = platform.Validate()
+	}
+	if err == nil {
+		err = w.apply(platform)
+	}
+	if err != nil {
+		metrics.ClusterConfigReloadsTotal.WithLabelValues(w.kind, "rejected").Inc()
+		return fmt.Errorf("rejected version %s of %s, keeping the previous configuration: %v", version, w.source(), err)
+	}
+
+	metrics.ClusterConfigReloadsTotal.WithLabelValues(w.kind, "applied").Inc()
+	log.Printf("Applied version %s of %s", version, w.source())
+	return nil
+}
@@ -0,0 +1,185 @@
+package dbhealth
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/metrics"
+)
+
+// Config holds connection pool and health check configuration
+type Config struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	CheckInterval   time.Duration
+	CheckTimeout    time.Duration
+}
+
+// LoadConfig loads database health configuration from environment variables
+func LoadConfig() Config {
+	return Config{
+		MaxOpenConns:    getIntEnv("DB_MAX_CONNECTIONS", 25),
+		MaxIdleConns:    getIntEnv("DB_MAX_IDLE_CONNECTIONS", 10),
+		ConnMaxLifetime: getDurationEnv("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		CheckInterval:   getDurationEnv("DB_HEALTH_CHECK_INTERVAL", 10*time.Second),
+		CheckTimeout:    getDurationEnv("DB_HEALTH_CHECK_TIMEOUT", 3*time.Second),
+	}
+}
+
+// Monitor watches a database connection pool for broken connections, such as
+// after a Postgres restart or failover. When the database stops answering it
+// drops pooled connections so the next request re-dials (picking up a new
+// primary behind the same DNS name), and reports the outage through metrics,
+// events and an optional callback.
+type Monitor struct {
+	service  string
+	database string
+	db       *sql.DB
+	cfg      Config
+	eventBus events.EventBus
+
+	mu        sync.RWMutex
+	healthy   bool
+	lostAt    time.Time
+	listeners []func(err error)
+}
+
+// NewMonitor creates a new database monitor and applies the pool settings.
+// eventBus may be nil, in which case no connection events are published.
+func NewMonitor(service, database string, db *sql.DB, cfg Config, eventBus events.EventBus) *Monitor {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	// Recycle connections periodically so a failover is picked up even if
+	// old connections never error
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	metrics.UpdateDatabaseUp(service, database, true)
+
+	return &Monitor{
+		service:  service,
+		database: database,
+		db:       db,
+		cfg:      cfg,
+		eventBus: eventBus,
+		healthy:  true,
+	}
+}
+
+// OnChange registers a callback invoked when the connection breaks (with the
+// error) or recovers (with nil)
+func (m *Monitor) OnChange(fn func(err error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// Healthy reports whether the last health check succeeded
+func (m *Monitor) Healthy() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.healthy
+}
+
+// Start runs health checks until the context is cancelled
+func (m *Monitor) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(m.cfg.CheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.check(ctx)
+			}
+		}
+	}()
+}
+
+// check pings the database and handles transitions between healthy and broken
+func (m *Monitor) check(ctx context.Context) {
+	pingCtx, cancel := context.WithTimeout(ctx, m.cfg.CheckTimeout)
+	err := m.db.PingContext(pingCtx)
+	cancel()
+
+	if err != nil {
+		// Drop idle connections; they point at the old server and would
+		// otherwise be handed to requests one by one as they fail
+		m.db.SetMaxIdleConns(0)
+		m.db.SetMaxIdleConns(m.cfg.MaxIdleConns)
+	}
+
+	m.mu.Lock()
+	wasHealthy := m.healthy
+	m.healthy = err == nil
+	var downtime time.Duration
+	switch {
+	case wasHealthy && err != nil:
+		m.lostAt = time.Now()
+	case !wasHealthy && err == nil:
+		downtime = time.Since(m.lostAt)
+	}
+	listeners := m.listeners
+	m.mu.Unlock()
+
+	if wasHealthy == (err == nil) {
+		return
+	}
+
+	metrics.UpdateDatabaseUp(m.service, m.database, err == nil)
+
+	event := &events.Event{
+		Source:  m.service,
+		Subject: m.database,
+		Data: map[string]interface{}{
+			"service":  m.service,
+			"database": m.database,
+		},
+	}
+	if err != nil {
+		log.Printf("Database %s connection lost, dropping pooled connections: %v", m.database, err)
+		event.Type = events.DatabaseConnectionLost
+		event.Data["error"] = err.Error()
+	} else {
+		log.Printf("Database %s connection restored after %v", m.database, downtime.Round(time.Second))
+		metrics.RecordDatabaseReconnect(m.service, m.database)
+		event.Type = events.DatabaseConnectionRestored
+		event.Data["downtime_seconds"] = downtime.Seconds()
+	}
+
+	if m.eventBus != nil {
+		if pubErr := m.eventBus.Publish(ctx, event); pubErr != nil {
+			log.Printf("Failed to publish %s event: %v", event.Type, pubErr)
+		}
+	}
+
+	for _, fn := range listeners {
+		fn(err)
+	}
+}
+
+func getIntEnv(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,97 @@
+package debug
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Options controls debugging facilities that expose internals and should not
+// normally run in production
+type Options struct {
+	GRPCReflection bool
+	VerboseSQL     bool
+	Pprof          bool
+	// AllowInProduction acknowledges that debug facilities are deliberately
+	// enabled in production, e.g. while investigating an incident
+	AllowInProduction bool
+}
+
+// LoadOptions loads debug options from environment variables. Reflection and
+// verbose SQL logging default to on outside production; pprof is always opt-in.
+func LoadOptions(environment string) Options {
+	development := environment != "production"
+
+	return Options{
+		GRPCReflection:    getBoolEnv("GRPC_REFLECTION_ENABLED", development),
+		VerboseSQL:        getBoolEnv("DB_VERBOSE_LOGGING", development),
+		Pprof:             getBoolEnv("PPROF_ENABLED", false),
+		AllowInProduction: getBoolEnv("ALLOW_DEBUG_IN_PRODUCTION", false),
+	}
+}
+
+// Enabled returns the names of the enabled debug facilities
+func (o Options) Enabled() []string {
+	var enabled []string
+	if o.GRPCReflection {
+		enabled = append(enabled, "grpc reflection")
+	}
+	if o.VerboseSQL {
+		enabled = append(enabled, "verbose SQL logging")
+	}
+	if o.Pprof {
+		enabled = append(enabled, "pprof")
+	}
+	return enabled
+}
+
+// Check refuses debug facilities in production unless they are explicitly
+// allowed, in which case it logs a warning at startup
+func (o Options) Check(environment string) error {
+	enabled := o.Enabled()
+	if len(enabled) == 0 {
+		return nil
+	}
+
+	if environment != "production" {
+		log.Printf("Debug facilities enabled: %s", strings.Join(enabled, ", "))
+		return nil
+	}
+
+	if !o.AllowInProduction {
+		return fmt.Errorf("debug facilities enabled in production: %s (set ALLOW_DEBUG_IN_PRODUCTION=true to override)",
+			strings.Join(enabled, ", "))
+	}
+
+	log.Printf("WARNING: debug facilities enabled in production: %s", strings.Join(enabled, ", "))
+	return nil
+}
+
+// PprofPath is where the pprof endpoints are served
+const PprofPath = "/debug/pprof/"
+
+// PprofHandler returns the pprof endpoints, to be served on the internal
+// observability port when Pprof is enabled. They are never registered on a
+// service's public port.
+func (o Options) PprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PprofPath, pprof.Index)
+	mux.HandleFunc(PprofPath+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(PprofPath+"profile", pprof.Profile)
+	mux.HandleFunc(PprofPath+"symbol", pprof.Symbol)
+	mux.HandleFunc(PprofPath+"trace", pprof.Trace)
+	return mux
+}
+
+func getBoolEnv(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,106 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// consul reads instances from Consul's health API. Watches are blocking
+// queries, which Consul answers as soon as the instances change.
+type consul struct {
+	addr   string
+	token  string
+	wait   time.Duration
+	client *http.Client
+}
+
+func newConsul(cfg Config) (*consul, error) {
+	addr := strings.TrimSuffix(cfg.ConsulAddr, "/")
+	if addr == "" {
+		return nil, fmt.Errorf("CONSUL_HTTP_ADDR is not set")
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return &consul{addr: addr, token: cfg.ConsulToken, wait: cfg.WaitTime, client: &http.Client{}}, nil
+}
+
+// consulEntry is the part of a health API entry the resolver reads
+type consulEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+// lookup returns the addresses of service's instances passing their health
+// checks, versioned by Consul's index
+func (c *consul) lookup(ctx context.Context, service, port, version string) ([]string, string, error) {
+	query := url.Values{"passing": {"true"}}
+	if version != "" {
+		query.Set("index", version)
+		query.Set("wait", fmt.Sprintf("%ds", int(c.wait.Seconds())))
+		// Consul adds up to wait/16 of jitter to a blocking query
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.wait+c.wait/16+10*time.Second)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.addr+"/v1/health/service/"+url.PathEscape(service)+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("consul returned %s", resp.Status)
+	}
+
+	// An index that went backwards means Consul's state was reset
+	index := resp.Header.Get("X-Consul-Index")
+	if version != "" {
+		previous, _ := strconv.ParseUint(version, 10, 64)
+		current, _ := strconv.ParseUint(index, 10, 64)
+		if current == previous {
+			return nil, "", errUnchanged
+		}
+		if current < previous {
+			return nil, "", errStale
+		}
+	}
+
+	var entries []consulEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, "", fmt.Errorf("invalid consul response: %v", err)
+	}
+	addresses := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		if entry.Service.Port == 0 {
+			addresses = append(addresses, host)
+			continue
+		}
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)))
+	}
+	return addresses, index, nil
+}
@@ -0,0 +1,272 @@
+// Package discovery finds the addresses of service instances in a service
+// registry (Consul, etcd or Kubernetes Endpoints) and watches them, so
+// clients follow instances as they come and go instead of relying on
+// addresses fixed in environment variables.
+//
+// A target names a service, optionally with a port: "user-service" or
+// "user-service:8081". With Kubernetes the port selects an Endpoints port by
+// name or number. Consul and etcd register one address per instance, so the
+// port only applies to instances registered without one.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// Providers a Config can name
+const (
+	ProviderConsul     = "consul"
+	ProviderEtcd       = "etcd"
+	ProviderKubernetes = "kubernetes"
+)
+
+// maxWatchBackoff caps the wait before retrying a failed lookup
+const maxWatchBackoff = time.Minute
+
+// minWatchInterval is the least time between the lookups of a watch, so a
+// registry answering a watch straight away, unchanged or not, is not polled
+// in a busy loop
+const minWatchInterval = time.Second
+
+// Resolver looks up the addresses ("host:port") of a service's instances
+type Resolver interface {
+	// Resolve returns the current addresses of target's instances
+	Resolve(ctx context.Context, target string) ([]string, error)
+	// Watch calls update with the addresses of target's instances, first
+	// with the current ones and then whenever they change, until ctx is
+	// cancelled. Failed lookups are retried and an empty result keeps the
+	// last addresses, so update is never called without instances.
+	Watch(ctx context.Context, target string, update func([]string))
+}
+
+// Config holds the service discovery settings. Provider is "consul", "etcd"
+// or "kubernetes"; empty disables discovery.
+//
+// Consul instances are read from ConsulAddr's health API and only those
+// passing their checks are used. etcd instances are the values of the keys
+// under EtcdPrefix + "<service>/", each an address or a JSON object with an
+// "Addr" field as written by etcd's endpoints manager. Kubernetes instances
+// are the ready addresses of the service's Endpoints in Namespace, which
+// defaults to the pod's own.
+//
+// Consul and etcd watches wait up to WaitTime for a change before asking
+// again; Kubernetes watches are ended by the API server.
+type Config struct {
+	Provider      string
+	ConsulAddr    string
+	ConsulToken   string
+	EtcdEndpoints []string
+	EtcdPrefix    string
+	Namespace     string
+	WaitTime      time.Duration
+}
+
+// Enabled reports whether a provider is configured
+func (c Config) Enabled() bool {
+	return c.Provider != ""
+}
+
+// LoadConfig loads the service discovery settings from environment variables
+func LoadConfig() Config {
+	return Config{
+		Provider:      strings.ToLower(getEnv("DISCOVERY_PROVIDER", "")),
+		ConsulAddr:    getEnv("CONSUL_HTTP_ADDR", "http://consul:8500"),
+		ConsulToken:   getEnv("CONSUL_HTTP_TOKEN", ""),
+		EtcdEndpoints: getStringSliceEnv("ETCD_ENDPOINTS", []string{"http://etcd:2379"}),
+		EtcdPrefix:    getEnv("DISCOVERY_ETCD_PREFIX", "/services/"),
+		Namespace:     getEnv("DISCOVERY_NAMESPACE", ""),
+		WaitTime:      getDurationEnv("DISCOVERY_WAIT_TIME", 5*time.Minute),
+	}
+}
+
+// New creates the resolver of the configured provider
+func New(cfg Config) (Resolver, error) {
+	if cfg.WaitTime <= 0 {
+		cfg.WaitTime = 5 * time.Minute
+	}
+
+	var registry backend
+	var err error
+	switch cfg.Provider {
+	case ProviderConsul:
+		registry, err = newConsul(cfg)
+	case ProviderEtcd:
+		registry, err = newEtcd(cfg)
+	case ProviderKubernetes:
+		registry, err = newKubernetes(cfg)
+	default:
+		return nil, fmt.Errorf("unknown discovery provider %q, expected consul, etcd or kubernetes", cfg.Provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &watcher{provider: cfg.Provider, backend: registry}, nil
+}
+
+// errUnchanged is returned by a lookup whose wait ended without a change
+var errUnchanged = errors.New("instances unchanged")
+
+// errStale is returned by a lookup whose version can no longer be waited on,
+// so the instances have to be read again
+var errStale = errors.New("version no longer available")
+
+// backend reads the instances of a service from a registry
+type backend interface {
+	// lookup returns the addresses of service's instances and a version
+	// identifying them. Given the version of a previous lookup it first
+	// waits until they change, returning errUnchanged if they did not.
+	// port selects the addresses' port where the registry has several.
+	lookup(ctx context.Context, service, port, version string) ([]string, string, error)
+}
+
+// watcher implements Resolver over a backend
+type watcher struct {
+	provider string
+	backend  backend
+}
+
+// Resolve returns the current addresses of target's instances
+func (w *watcher) Resolve(ctx context.Context, target string) ([]string, error) {
+	service, port := splitTarget(target)
+	addresses, _, err := w.backend.lookup(ctx, service, port, "")
+	if err != nil {
+		metrics.DiscoveryErrorsTotal.WithLabelValues(w.provider, service).Inc()
+		return nil, err
+	}
+	return withPort(addresses, port), nil
+}
+
+// Watch calls update with the addresses of target's instances whenever they
+// change, until ctx is cancelled
+func (w *watcher) Watch(ctx context.Context, target string, update func([]string)) {
+	service, port := splitTarget(target)
+	var version string
+	var current []string
+	backoff := time.Second
+	var last time.Time
+
+	for ctx.Err() == nil {
+		if wait := minWatchInterval - time.Since(last); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+		last = time.Now()
+
+		addresses, next, err := w.backend.lookup(ctx, service, port, version)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case errors.Is(err, errUnchanged):
+			continue
+		case errors.Is(err, errStale):
+			version = ""
+			continue
+		case err != nil:
+			metrics.DiscoveryErrorsTotal.WithLabelValues(w.provider, service).Inc()
+			log.Printf("Discovering instances of %s through %s failed, retrying in %s: %v", target, w.provider, backoff, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > maxWatchBackoff {
+				backoff = maxWatchBackoff
+			}
+			continue
+		}
+		backoff = time.Second
+		version = next
+
+		addresses = withPort(addresses, port)
+		if len(addresses) == 0 {
+			log.Printf("WARNING: %s found no instances of %s, keeping the last ones", w.provider, target)
+			continue
+		}
+		if equal(addresses, current) {
+			continue
+		}
+		current = addresses
+		metrics.DiscoveryInstances.WithLabelValues(w.provider, service).Set(float64(len(addresses)))
+		log.Printf("Found %d instances of %s through %s", len(addresses), target, w.provider)
+		update(addresses)
+	}
+}
+
+// splitTarget splits a target into its service and optional port
+func splitTarget(target string) (string, string) {
+	if service, port, err := net.SplitHostPort(target); err == nil {
+		return service, port
+	}
+	return target, ""
+}
+
+// withPort adds port to addresses without one, dropping addresses that still
+// have none, and sorts them so every client lists them in the same order
+func withPort(addresses []string, port string) []string {
+	result := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if _, _, err := net.SplitHostPort(address); err == nil {
+			result = append(result, address)
+		} else if port != "" {
+			result = append(result, net.JoinHostPort(strings.Trim(address, "[]"), port))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// equal reports whether two sorted address lists are the same
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getStringSliceEnv(key string, fallback []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
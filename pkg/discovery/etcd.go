@@ -0,0 +1,213 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// etcd reads instances from etcd's v3 JSON API. Watches open a watch on the
+// service's keys from the revision last read.
+type etcd struct {
+	endpoints []string
+	prefix    string
+	wait      time.Duration
+	client    *http.Client
+}
+
+func newEtcd(cfg Config) (*etcd, error) {
+	if len(cfg.EtcdEndpoints) == 0 {
+		return nil, fmt.Errorf("ETCD_ENDPOINTS is not set")
+	}
+	endpoints := make([]string, len(cfg.EtcdEndpoints))
+	for i, endpoint := range cfg.EtcdEndpoints {
+		endpoint = strings.TrimSuffix(endpoint, "/")
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+		endpoints[i] = endpoint
+	}
+	return &etcd{endpoints: endpoints, prefix: cfg.EtcdPrefix, wait: cfg.WaitTime, client: &http.Client{}}, nil
+}
+
+// etcdHeader is the header of every etcd response. Its 64-bit integers are
+// JSON strings.
+type etcdHeader struct {
+	Revision string `json:"revision"`
+}
+
+// lookup returns the addresses registered under service's keys, versioned by
+// etcd's revision
+func (e *etcd) lookup(ctx context.Context, service, port, version string) ([]string, string, error) {
+	key := e.prefix + service + "/"
+	if version != "" {
+		if err := e.waitForChange(ctx, key, version); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var result struct {
+		Header etcdHeader `json:"header"`
+		Kvs    []struct {
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	body := map[string]string{"key": encodeKey(key), "range_end": encodeKey(prefixEnd(key))}
+	resp, err := e.post(ctx, "/v3/kv/range", body)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("invalid etcd response: %v", err)
+	}
+
+	addresses := make([]string, 0, len(result.Kvs))
+	for _, kv := range result.Kvs {
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			continue
+		}
+		if address := parseEtcdAddress(value); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses, result.Header.Revision, nil
+}
+
+// waitForChange watches the keys under key for a change after revision,
+// returning errUnchanged if there was none within the wait time and errStale
+// if the revision was compacted
+func (e *etcd) waitForChange(ctx context.Context, key, revision string) error {
+	start, err := strconv.ParseInt(revision, 10, 64)
+	if err != nil {
+		return errStale
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.wait)
+	defer cancel()
+	body := map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            encodeKey(key),
+			"range_end":      encodeKey(prefixEnd(key)),
+			"start_revision": strconv.FormatInt(start+1, 10),
+		},
+	}
+	resp, err := e.post(ctx, "/v3/watch", body)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return errUnchanged
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var message struct {
+			Result struct {
+				CompactRevision string            `json:"compact_revision"`
+				Canceled        bool              `json:"canceled"`
+				Events          []json.RawMessage `json:"events"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			return fmt.Errorf("invalid etcd watch response: %v", err)
+		}
+		switch {
+		case message.Error != nil:
+			return fmt.Errorf("etcd watch failed: %s", message.Error.Message)
+		case message.Result.CompactRevision != "" && message.Result.CompactRevision != "0":
+			return errStale
+		case message.Result.Canceled:
+			return errors.New("etcd cancelled the watch")
+		case len(message.Result.Events) > 0:
+			return nil
+		}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errUnchanged
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errUnchanged
+}
+
+// post sends a JSON request to the first etcd endpoint that answers
+func (e *etcd) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, endpoint := range e.endpoints {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+path, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := e.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("etcd %s returned %s", endpoint, resp.Status)
+			continue
+		}
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+// parseEtcdAddress reads an instance's address from its value: either the
+// address itself or a JSON object with an "Addr" field
+func parseEtcdAddress(value []byte) string {
+	value = bytes.TrimSpace(value)
+	if len(value) > 0 && value[0] == '{' {
+		var endpoint struct {
+			Addr string `json:"Addr"`
+		}
+		if json.Unmarshal(value, &endpoint) != nil {
+			return ""
+		}
+		return endpoint.Addr
+	}
+	return string(value)
+}
+
+// prefixEnd returns the end of the key range holding every key starting with
+// prefix
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return "\x00"
+}
+
+func encodeKey(key string) string {
+	return base64.StdEncoding.EncodeToString([]byte(key))
+}
@@ -0,0 +1,57 @@
+package discovery
+
+import (
+	"context"
+	"log"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// Scheme is the gRPC target scheme resolved through service discovery, as in
+// "discovery:///user-service:8081"
+const Scheme = "discovery"
+
+// GRPCResolver returns a gRPC resolver for Scheme targets that watches their
+// instances with r. Pass it to grpc.WithResolvers.
+func GRPCResolver(r Resolver) resolver.Builder {
+	return &grpcBuilder{resolver: r}
+}
+
+type grpcBuilder struct {
+	resolver Resolver
+}
+
+// Build starts watching the instances of target, updating the connection's
+// addresses whenever they change
+func (b *grpcBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	endpoint := target.Endpoint()
+	go b.resolver.Watch(ctx, endpoint, func(addresses []string) {
+		state := resolver.State{Addresses: make([]resolver.Address, len(addresses))}
+		for i, address := range addresses {
+			state.Addresses[i] = resolver.Address{Addr: address}
+		}
+		if err := cc.UpdateState(state); err != nil {
+			log.Printf("Failed to apply the instances of %s: %v", endpoint, err)
+		}
+	})
+	return &grpcWatch{cancel: cancel}, nil
+}
+
+// Scheme returns the scheme the builder resolves
+func (b *grpcBuilder) Scheme() string {
+	return Scheme
+}
+
+// grpcWatch is the watch of a connection's target
+type grpcWatch struct {
+	cancel context.CancelFunc
+}
+
+// ResolveNow does nothing since changes are applied as they are watched
+func (w *grpcWatch) ResolveNow(resolver.ResolveNowOptions) {}
+
+// Close stops the watch
+func (w *grpcWatch) Close() {
+	w.cancel()
+}
@@ -0,0 +1,116 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+
+	"microservices-platform/pkg/kubeapi"
+)
+
+// kubernetes reads instances from a service's Endpoints. Watches resume from
+// the Endpoints' resource version.
+type kubernetes struct {
+	client *kubeapi.Client
+}
+
+func newKubernetes(cfg Config) (*kubernetes, error) {
+	client, err := kubeapi.NewInCluster(cfg.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return &kubernetes{client: client}, nil
+}
+
+// endpoints is the part of an Endpoints object the resolver reads
+type endpoints struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Subsets []struct {
+		Addresses []struct {
+			IP string `json:"ip"`
+		} `json:"addresses"`
+		Ports []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"subsets"`
+}
+
+// lookup returns the ready addresses of service's Endpoints, versioned by
+// their resource version
+func (k *kubernetes) lookup(ctx context.Context, service, port, version string) ([]string, string, error) {
+	collection := "/api/v1/namespaces/" + k.client.Namespace() + "/endpoints"
+	if version == "" {
+		var obj endpoints
+		if err := k.client.Get(ctx, collection+"/"+url.PathEscape(service), &obj); err != nil {
+			return nil, "", err
+		}
+		return obj.addresses(port), obj.Metadata.ResourceVersion, nil
+	}
+
+	stream, err := k.client.Watch(ctx, collection, service, version)
+	if errors.Is(err, kubeapi.ErrGone) {
+		return nil, "", errStale
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer stream.Close()
+
+	for {
+		event, err := stream.Next()
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil, "", errUnchanged
+		case errors.Is(err, kubeapi.ErrGone):
+			return nil, "", errStale
+		case err != nil:
+			return nil, "", err
+		}
+
+		var obj endpoints
+		if err := json.Unmarshal(event.Object, &obj); err != nil {
+			return nil, "", fmt.Errorf("invalid watch event: %v", err)
+		}
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			return obj.addresses(port), obj.Metadata.ResourceVersion, nil
+		case "DELETED":
+			return nil, obj.Metadata.ResourceVersion, nil
+		}
+	}
+}
+
+// addresses returns the ready addresses with the port named or numbered
+// port, or the first port when port is empty. Not-ready addresses, such as
+// pods failing their readiness probe, are left out.
+func (e *endpoints) addresses(port string) []string {
+	var addresses []string
+	for _, subset := range e.Subsets {
+		selected := ""
+		for _, candidate := range subset.Ports {
+			number := strconv.Itoa(candidate.Port)
+			if port == "" || candidate.Name == port || number == port {
+				selected = number
+				break
+			}
+		}
+		if selected == "" {
+			if _, err := strconv.Atoi(port); err != nil {
+				continue
+			}
+			selected = port
+		}
+		for _, address := range subset.Addresses {
+			addresses = append(addresses, net.JoinHostPort(address.IP, selected))
+		}
+	}
+	return addresses
+}
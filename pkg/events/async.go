@@ -0,0 +1,167 @@
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/metrics"
+)
+
+// Reasons an event goes to the outbox instead of being published in the
+// background
+const (
+	fallbackBufferFull    = "buffer_full"
+	fallbackPublishFailed = "publish_failed"
+	fallbackShutdown      = "shutdown"
+)
+
+// AsyncEventBus publishes events in the background, so request paths return
+// without waiting on the broker. Publish only queues the event in a bounded
+// buffer; workers publish it on the wrapped bus. When the buffer is full, or
+// a publish fails, the event is appended to the outbox, which the degrading
+// bus flushes in order once the broker keeps up again. Scheduling and
+// subscriptions go straight to the wrapped bus.
+type AsyncEventBus struct {
+	EventBus
+	outbox  Outbox
+	service string
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	queue  chan *Event
+	closed bool
+	// abandon makes workers move the events left at shutdown to the outbox
+	// instead of publishing them
+	abandon atomic.Bool
+	workers sync.WaitGroup
+}
+
+// NewAsyncEventBus wraps bus so events are published by cfg.PublishWorkers
+// background workers. With cfg.PublishAsync unset, events are published
+// synchronously as before.
+func NewAsyncEventBus(bus EventBus, outbox Outbox, cfg config.EventBusConfig) *AsyncEventBus {
+	b := &AsyncEventBus{
+		EventBus: bus,
+		outbox:   outbox,
+		service:  cfg.Service,
+		timeout:  cfg.PublishTimeout,
+	}
+	if !cfg.PublishAsync || cfg.PublishWorkers <= 0 || cfg.PublishBuffer <= 0 {
+		return b
+	}
+
+	b.queue = make(chan *Event, cfg.PublishBuffer)
+	for i := 0; i < cfg.PublishWorkers; i++ {
+		b.workers.Add(1)
+		go b.work()
+	}
+	return b
+}
+
+// Publish queues an event for publishing. The event's ID, timestamp, trace
+// and baggage are set now, so handlers still see the request that published
+// it. An error means the event could be neither queued nor buffered in the
+// outbox.
+func (b *AsyncEventBus) Publish(ctx context.Context, event *Event) error {
+	if b.queue == nil {
+		return b.EventBus.Publish(ctx, event)
+	}
+
+	if event.ID == "" {
+		event.ID = generateEventID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	withTraceContext(ctx, event)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return b.EventBus.Publish(ctx, event)
+	}
+
+	select {
+	case b.queue <- event:
+		metrics.UpdateEventPublishBufferDepth(b.service, len(b.queue))
+		return nil
+	default:
+		return b.fallback(event, fallbackBufferFull)
+	}
+}
+
+// work publishes queued events until the queue is closed
+func (b *AsyncEventBus) work() {
+	defer b.workers.Done()
+	for event := range b.queue {
+		metrics.UpdateEventPublishBufferDepth(b.service, len(b.queue))
+		if b.abandon.Load() {
+			b.fallback(event, fallbackShutdown)
+			continue
+		}
+
+		ctx, cancel := b.publishContext()
+		err := b.EventBus.Publish(ctx, event)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to publish %s event %s in the background: %v", event.Type, event.ID, err)
+			b.fallback(event, fallbackPublishFailed)
+		}
+	}
+}
+
+func (b *AsyncEventBus) publishContext() (context.Context, context.CancelFunc) {
+	if b.timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), b.timeout)
+}
+
+// fallback appends an event to the outbox. The outbox is local, so this is
+// fast even while the broker is slow.
+func (b *AsyncEventBus) fallback(event *Event, reason string) error {
+	metrics.RecordEventPublishFallback(b.service, reason)
+	if err := b.outbox.Append(context.Background(), event); err != nil {
+		log.Printf("Failed to buffer %s event %s in outbox (%s): %v", event.Type, event.ID, reason, err)
+		return err
+	}
+	return nil
+}
+
+// Close stops queueing events and waits for the queued ones to be published.
+// Events still queued when ctx is done are moved to the outbox. Events
+// published after Close are published synchronously.
+func (b *AsyncEventBus) Close(ctx context.Context) error {
+	if b.queue == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.queue)
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		b.abandon.Store(true)
+		<-done
+		log.Printf("Event publisher did not drain in time, remaining events were moved to the outbox")
+		return ctx.Err()
+	}
+}
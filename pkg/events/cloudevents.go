@@ -0,0 +1,352 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CloudEvents 1.0 constants
+const (
+	CloudEventsSpecVersion = "1.0"
+
+	// ContentTypeCloudEventsJSON is the content type of structured mode
+	// messages
+	ContentTypeCloudEventsJSON = "application/cloudevents+json"
+
+	// cloudEventsHeaderPrefix prefixes context attributes in binary mode
+	cloudEventsHeaderPrefix = "Ce-"
+)
+
+// CloudEventsMode selects how an event is carried in an HTTP message
+type CloudEventsMode string
+
+const (
+	// CloudEventsStructured puts the whole event in the body as JSON
+	CloudEventsStructured CloudEventsMode = "structured"
+	// CloudEventsBinary puts context attributes in ce-* headers and only the
+	// event data in the body
+	CloudEventsBinary CloudEventsMode = "binary"
+)
+
+// platformExtensions maps platform metadata keys to CloudEvents extension
+// names, which may only contain lowercase letters and digits
+var platformExtensions = map[string]string{
+	MetadataTraceID:    "traceid",
+	MetadataSpanID:     "spanid",
+	MetadataBaggage:    "baggage",
+	MetadataReplayTo:   "replayto",
+	MetadataReplayedAt: "replayedat",
+}
+
+// CloudEvent is a CloudEvents 1.0 event in its JSON format. Extension
+// attributes are carried in Extensions and flattened when marshalled.
+type CloudEvent struct {
+	SpecVersion     string            `json:"specversion"`
+	ID              string            `json:"id"`
+	Source          string            `json:"source"`
+	Type            string            `json:"type"`
+	Subject         string            `json:"subject,omitempty"`
+	Time            string            `json:"time,omitempty"`
+	DataContentType string            `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage   `json:"data,omitempty"`
+	Extensions      map[string]string `json:"-"`
+}
+
+// cloudEventAttributes are the attributes that are not extensions
+var cloudEventAttributes = map[string]bool{
+	"specversion": true, "id": true, "source": true, "type": true, "subject": true,
+	"time": true, "datacontenttype": true, "data": true, "dataschema": true, "data_base64": true,
+}
+
+// MarshalJSON writes the event with its extensions as top-level attributes
+func (ce CloudEvent) MarshalJSON() ([]byte, error) {
+	type plain CloudEvent
+	data, err := json.Marshal(plain(ce))
+	if err != nil || len(ce.Extensions) == 0 {
+		return data, err
+	}
+
+	var attributes map[string]interface{}
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return nil, err
+	}
+	for name, value := range ce.Extensions {
+		if !cloudEventAttributes[name] {
+			attributes[name] = value
+		}
+	}
+	return json.Marshal(attributes)
+}
+
+// UnmarshalJSON reads the event, collecting unknown string attributes as
+// extensions
+func (ce *CloudEvent) UnmarshalJSON(data []byte) error {
+	type plain CloudEvent
+	if err := json.Unmarshal(data, (*plain)(ce)); err != nil {
+		return err
+	}
+
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return err
+	}
+	for name, raw := range attributes {
+		if cloudEventAttributes[name] {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			// Non-string extensions are kept in their JSON form
+			value = string(raw)
+		}
+		if ce.Extensions == nil {
+			ce.Extensions = make(map[string]string)
+		}
+		ce.Extensions[name] = value
+	}
+	return nil
+}
+
+// ToCloudEvent converts a platform event to a CloudEvent. Metadata becomes
+// extension attributes.
+func ToCloudEvent(event *Event) (*CloudEvent, error) {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event data: %v", err)
+	}
+
+	ce := &CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              event.ID,
+		Source:          event.Source,
+		Type:            string(event.Type),
+		Subject:         event.Subject,
+		DataContentType: "application/json",
+		Data:            data,
+	}
+	if !event.Timestamp.IsZero() {
+		ce.Time = event.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+
+	for key, value := range event.Metadata {
+		name := extensionName(key)
+		if name == "" || cloudEventAttributes[name] {
+			continue
+		}
+		if ce.Extensions == nil {
+			ce.Extensions = make(map[string]string)
+		}
+		ce.Extensions[name] = value
+	}
+
+	return ce, nil
+}
+
+// FromCloudEvent converts a CloudEvent to a platform event. Only JSON object
+// data is supported.
+func FromCloudEvent(ce *CloudEvent) (*Event, error) {
+	if ce.SpecVersion != CloudEventsSpecVersion {
+		return nil, fmt.Errorf("unsupported CloudEvents specversion %q", ce.SpecVersion)
+	}
+	if ce.ID == "" || ce.Source == "" || ce.Type == "" {
+		return nil, errors.New("CloudEvent id, source and type are required")
+	}
+	if ce.DataContentType != "" && !isJSONContentType(ce.DataContentType) {
+		return nil, fmt.Errorf("unsupported datacontenttype %q", ce.DataContentType)
+	}
+
+	event := &Event{
+		ID:      ce.ID,
+		Type:    EventType(ce.Type),
+		Source:  ce.Source,
+		Subject: ce.Subject,
+	}
+
+	if ce.Time != "" {
+		timestamp, err := time.Parse(time.RFC3339Nano, ce.Time)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CloudEvent time: %v", err)
+		}
+		event.Timestamp = timestamp.UTC()
+	}
+
+	if len(ce.Data) > 0 && string(ce.Data) != "null" {
+		if err := json.Unmarshal(ce.Data, &event.Data); err != nil {
+			return nil, fmt.Errorf("CloudEvent data must be a JSON object: %v", err)
+		}
+	}
+
+	for name, value := range ce.Extensions {
+		if event.Metadata == nil {
+			event.Metadata = make(map[string]string)
+		}
+		event.Metadata[metadataKey(name)] = value
+	}
+
+	return event, nil
+}
+
+// MarshalCloudEvent encodes a platform event in structured mode
+func MarshalCloudEvent(event *Event) ([]byte, error) {
+	ce, err := ToCloudEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ce)
+}
+
+// UnmarshalCloudEvent decodes a structured mode event
+func UnmarshalCloudEvent(data []byte) (*Event, error) {
+	var ce CloudEvent
+	if err := json.Unmarshal(data, &ce); err != nil {
+		return nil, fmt.Errorf("invalid CloudEvent: %v", err)
+	}
+	return FromCloudEvent(&ce)
+}
+
+// EncodeCloudEventHTTP returns the headers and body that carry an event in
+// an HTTP message in the given mode
+func EncodeCloudEventHTTP(event *Event, mode CloudEventsMode) (http.Header, []byte, error) {
+	ce, err := ToCloudEvent(event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := make(http.Header)
+	if mode != CloudEventsBinary {
+		body, err := json.Marshal(ce)
+		if err != nil {
+			return nil, nil, err
+		}
+		header.Set("Content-Type", ContentTypeCloudEventsJSON)
+		return header, body, nil
+	}
+
+	header.Set("Content-Type", ce.DataContentType)
+	setCloudEventHeader(header, "specversion", ce.SpecVersion)
+	setCloudEventHeader(header, "id", ce.ID)
+	setCloudEventHeader(header, "source", ce.Source)
+	setCloudEventHeader(header, "type", ce.Type)
+	setCloudEventHeader(header, "subject", ce.Subject)
+	setCloudEventHeader(header, "time", ce.Time)
+	for name, value := range ce.Extensions {
+		setCloudEventHeader(header, name, value)
+	}
+
+	return header, ce.Data, nil
+}
+
+// DecodeCloudEventHTTP reads an event from an HTTP message in either mode.
+// Messages with the CloudEvents JSON content type are structured; any
+// message with a ce-specversion header is binary.
+func DecodeCloudEventHTTP(header http.Header, body io.Reader) (*Event, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if mediaType == ContentTypeCloudEventsJSON {
+		return UnmarshalCloudEvent(data)
+	}
+
+	if header.Get(cloudEventsHeaderPrefix+"Specversion") == "" {
+		return nil, errors.New("request is not a CloudEvent")
+	}
+
+	ce := &CloudEvent{DataContentType: header.Get("Content-Type")}
+	for key, values := range header {
+		if len(values) == 0 || !strings.HasPrefix(key, cloudEventsHeaderPrefix) {
+			continue
+		}
+		value, err := url.PathUnescape(values[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s header: %v", key, err)
+		}
+
+		switch name := strings.ToLower(strings.TrimPrefix(key, cloudEventsHeaderPrefix)); name {
+		case "specversion":
+			ce.SpecVersion = value
+		case "id":
+			ce.ID = value
+		case "source":
+			ce.Source = value
+		case "type":
+			ce.Type = value
+		case "subject":
+			ce.Subject = value
+		case "time":
+			ce.Time = value
+		default:
+			if ce.Extensions == nil {
+				ce.Extensions = make(map[string]string)
+			}
+			ce.Extensions[name] = value
+		}
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		ce.Data = data
+	}
+
+	return FromCloudEvent(ce)
+}
+
+// setCloudEventHeader sets a binary mode attribute header, percent-encoding
+// characters that may not appear in header values
+func setCloudEventHeader(header http.Header, name, value string) {
+	if value == "" {
+		return
+	}
+
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		if b <= ' ' || b >= 0x7f || b == '"' || b == '%' {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+			continue
+		}
+		encoded.WriteByte(b)
+	}
+	header.Set(cloudEventsHeaderPrefix+name, encoded.String())
+}
+
+// extensionName converts a metadata key to a valid extension name
+func extensionName(key string) string {
+	if name, ok := platformExtensions[key]; ok {
+		return name
+	}
+
+	var name strings.Builder
+	for _, r := range strings.ToLower(key) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			name.WriteRune(r)
+		}
+	}
+	return name.String()
+}
+
+// metadataKey converts an extension name back to a metadata key
+func metadataKey(name string) string {
+	for key, extension := range platformExtensions {
+		if extension == name {
+			return key
+		}
+	}
+	return name
+}
+
+// isJSONContentType reports whether a content type carries JSON
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"context"
+	"fmt"
+)
+
+// Deletion step statuses reported by services taking part in a
+// right-to-be-forgotten deletion
+const (
+	DeletionStepPending   = "pending"
+	DeletionStepCompleted = "completed"
+	DeletionStepFailed    = "failed"
+)
+
+// DeletionRequest is the payload of a user.deletion_requested event
+type DeletionRequest struct {
+	DeletionID string
+	UserID     string
+}
+
+// NewDeletionRequestedEvent creates the event that asks every service to
+// delete or anonymize the records it holds for a user
+func NewDeletionRequestedEvent(source, deletionID, userID string) *Event {
+	return &Event{
+		Type:    UserDeletionRequested,
+		Source:  source,
+		Subject: userID,
+		Data: map[string]interface{}{
+			"deletion_id": deletionID,
+			"user_id":     userID,
+		},
+	}
+}
+
+// ParseDeletionRequest extracts the deletion request from a user.deletion_requested event
+func ParseDeletionRequest(event *Event) (*DeletionRequest, error) {
+	deletionID, _ := event.Data["deletion_id"].(string)
+	userID, _ := event.Data["user_id"].(string)
+	if deletionID == "" || userID == "" {
+		return nil, fmt.Errorf("invalid %s event %s: missing deletion_id or user_id", event.Type, event.ID)
+	}
+
+	return &DeletionRequest{DeletionID: deletionID, UserID: userID}, nil
+}
+
+// DeletionStepResult is the payload of a user.deletion_step_completed event
+type DeletionStepResult struct {
+	DeletionID string
+	UserID     string
+	Service    string
+	Status     string
+	Error      string
+}
+
+// NewDeletionStepCompletedEvent creates the event a service publishes once it
+// has processed a deletion request. A non-nil stepErr marks the step failed.
+func NewDeletionStepCompletedEvent(service string, request *DeletionRequest, stepErr error) *Event {
+	data := map[string]interface{}{
+		"deletion_id": request.DeletionID,
+		"user_id":     request.UserID,
+		"service":     service,
+		"status":      DeletionStepCompleted,
+	}
+	if stepErr != nil {
+		data["status"] = DeletionStepFailed
+		data["error"] = stepErr.Error()
+	}
+
+	return &Event{
+		Type:    UserDeletionStepCompleted,
+		Source:  service,
+		Subject: request.UserID,
+		Data:    data,
+	}
+}
+
+// ParseDeletionStepResult extracts the step result from a user.deletion_step_completed event
+func ParseDeletionStepResult(event *Event) (*DeletionStepResult, error) {
+	result := &DeletionStepResult{}
+	result.DeletionID, _ = event.Data["deletion_id"].(string)
+	result.UserID, _ = event.Data["user_id"].(string)
+	result.Service, _ = event.Data["service"].(string)
+	result.Status, _ = event.Data["status"].(string)
+	result.Error, _ = event.Data["error"].(string)
+
+	if result.DeletionID == "" || result.Service == "" {
+		return nil, fmt.Errorf("invalid %s event %s: missing deletion_id or service", event.Type, event.ID)
+	}
+
+	return result, nil
+}
+
+// DeletionParticipant returns an event handler for a service taking part in
+// user deletion. It runs deleteFn for each request and reports the outcome.
+func DeletionParticipant(bus EventBus, service string, deleteFn func(ctx context.Context, userID string) error) EventHandler {
+	return func(ctx context.Context, event *Event) error {
+		request, err := ParseDeletionRequest(event)
+		if err != nil {
+			return err
+		}
+
+		stepErr := deleteFn(ctx, request.UserID)
+		if err := bus.Publish(ctx, NewDeletionStepCompletedEvent(service, request, stepErr)); err != nil {
+			return fmt.Errorf("failed to report deletion step for %s: %v", request.DeletionID, err)
+		}
+
+		return stepErr
+	}
+}
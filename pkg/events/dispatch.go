@@ -0,0 +1,169 @@
+package events
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/metrics"
+)
+
+// dispatchItem is an event waiting to be handled by a worker
+type dispatchItem struct {
+	ctx      context.Context
+	event    *Event
+	handlers []EventHandler
+}
+
+// dispatcher runs event handlers on a fixed pool of workers, each with a
+// bounded queue. Events with the same subject are always routed to the same
+// worker, so they are handled one at a time in the order they arrived, while
+// different subjects are handled in parallel. When a worker's queue is full,
+// dispatch blocks, pushing backpressure onto the subscriber instead of
+// buffering without limit.
+type dispatcher struct {
+	cfg    config.EventBusConfig
+	queues []chan dispatchItem
+	depth  int64
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// newDispatcher creates a new dispatcher and starts its workers
+func newDispatcher(cfg config.EventBusConfig) *dispatcher {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize < 1 {
+		cfg.QueueSize = 1
+	}
+
+	d := &dispatcher{cfg: cfg, queues: make([]chan dispatchItem, cfg.Workers)}
+
+	for i := range d.queues {
+		d.queues[i] = make(chan dispatchItem, cfg.QueueSize)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+
+	return d
+}
+
+// dispatch queues an event for the worker that owns its subject. It blocks
+// while that worker's queue is full.
+func (d *dispatcher) dispatch(ctx context.Context, event *Event, handlers []EventHandler) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		log.Printf("Event dispatcher stopped, dropping %s event %s", event.Type, event.ID)
+		return
+	}
+
+	item := dispatchItem{ctx: ctx, event: event, handlers: handlers}
+	queue := d.queues[d.workerFor(event)]
+
+	select {
+	case queue <- item:
+	default:
+		metrics.RecordEventDispatchBlocked(d.cfg.Service)
+		queue <- item
+	}
+	metrics.UpdateEventQueueDepth(d.cfg.Service, atomic.AddInt64(&d.depth, 1))
+}
+
+// workerFor hashes the event subject to a worker. Events without a subject
+// have no ordering requirement and are spread by ID.
+func (d *dispatcher) workerFor(event *Event) int {
+	key := event.Subject
+	if key == "" {
+		key = event.ID
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(d.queues)))
+}
+
+// work handles queued events until the queue is closed
+func (d *dispatcher) work(queue chan dispatchItem) {
+	defer d.wg.Done()
+
+	for item := range queue {
+		metrics.UpdateEventQueueDepth(d.cfg.Service, atomic.AddInt64(&d.depth, -1))
+		for _, handler := range item.handlers {
+			d.runHandler(item.ctx, handler, item.event)
+		}
+	}
+}
+
+// stop stops accepting events and drains queued events, waiting at most the
+// configured drain timeout
+func (d *dispatcher) stop() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	for _, queue := range d.queues {
+		close(queue)
+	}
+	d.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		log.Println("Event dispatcher drained")
+	case <-time.After(d.cfg.DrainTimeout):
+		log.Printf("Event dispatcher drain timed out with %d events unhandled", atomic.LoadInt64(&d.depth))
+	}
+}
+
+// runHandler runs a single handler, recovering from panics so one bad handler
+// cannot take down its worker
+func (d *dispatcher) runHandler(ctx context.Context, handler EventHandler, event *Event) {
+	start := time.Now()
+	status := "success"
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Event handler panicked: %v", r)
+			status = "panic"
+		}
+		metrics.RecordEventProcessed(d.cfg.Service, string(event.Type), status, time.Since(start))
+	}()
+
+	ctx, span := otel.Tracer("events").Start(handlerContext(ctx, event), "event.handle "+string(event.Type),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("event.id", event.ID),
+			attribute.String("event.type", string(event.Type)),
+			attribute.String("event.source", event.Source),
+			attribute.String("event.subject", event.Subject),
+		),
+	)
+	defer span.End()
+
+	if err := handler(ctx, event); err != nil {
+		log.Printf("Event handler failed: %v", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "event handler failed")
+		status = "error"
+	}
+}
@@ -10,6 +10,9 @@ import (
 	"time"
 
 	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/redisclient"
 )
 
 // EventType represents the type of event
@@ -19,16 +22,37 @@ const (
 	UserCreated           EventType = "user.created"
 	UserUpdated           EventType = "user.updated"
 	UserDeleted           EventType = "user.deleted"
+	UserDeletionRequested EventType = "user.deletion_requested"
+	UserDeletionStepCompleted EventType = "user.deletion_step_completed"
+	UserPasswordResetRequested EventType = "user.password_reset_requested"
+	UserSuspiciousLogin        EventType = "user.suspicious_login"
 	OrderCreated          EventType = "order.created"
 	OrderStatusChanged    EventType = "order.status_changed"
 	OrderCancelled        EventType = "order.cancelled"
+	ShipmentTrackingUpdated EventType = "shipment.tracking_updated"
+	CartIdleCheck         EventType = "cart.idle_check"
+	CartAbandoned         EventType = "cart.abandoned"
 	PaymentProcessed      EventType = "payment.processed"
 	PaymentFailed         EventType = "payment.failed"
 	PaymentRefunded       EventType = "payment.refunded"
+	PaymentAuthorized     EventType = "payment.authorized"
+	PaymentAuthorizationExpired EventType = "payment.authorization_expired"
+	SubscriptionCreated       EventType = "subscription.created"
+	SubscriptionRenewed       EventType = "subscription.renewed"
+	SubscriptionPlanChanged   EventType = "subscription.plan_changed"
+	SubscriptionPaymentFailed EventType = "subscription.payment_failed"
+	SubscriptionCancelled     EventType = "subscription.cancelled"
 	ProductCreated        EventType = "product.created"
 	ProductUpdated        EventType = "product.updated"
 	ProductInventoryChanged EventType = "product.inventory_changed"
 	NotificationSent      EventType = "notification.sent"
+	DatabaseConnectionLost     EventType = "database.connection_lost"
+	DatabaseConnectionRestored EventType = "database.connection_restored"
+	SecurityAnomaly            EventType = "security.anomaly"
+	ReviewResolved             EventType = "review.resolved"
+	DataIntegrityDivergence    EventType = "data_integrity.divergence"
+	PlatformIncident           EventType = "platform.incident"
+	BusinessAnomaly            EventType = "platform.business_anomaly"
 )
 
 // Event represents a domain event
@@ -48,6 +72,10 @@ type EventHandler func(ctx context.Context, event *Event) error
 // EventBus defines the interface for event publishing and subscribing
 type EventBus interface {
 	Publish(ctx context.Context, event *Event) error
+	// PublishAt schedules an event to be published at a future time
+	PublishAt(ctx context.Context, event *Event, at time.Time) error
+	// PublishAfter schedules an event to be published after a delay
+	PublishAfter(ctx context.Context, event *Event, delay time.Duration) error
 	Subscribe(eventType EventType, handler EventHandler) error
 	Unsubscribe(eventType EventType) error
 	Start(ctx context.Context) error
@@ -56,34 +84,48 @@ type EventBus interface {
 
 // RedisEventBus implements EventBus using Redis Pub/Sub
 type RedisEventBus struct {
-	client    *redis.Client
+	client    redis.UniversalClient
 	handlers  map[EventType][]EventHandler
 	mu        sync.RWMutex
 	pubsub    *redis.PubSub
+	dispatcher *dispatcher
+	busCfg    config.EventBusConfig
+	store     *RedisEventStore
 	stopChan  chan struct{}
 	started   bool
 }
 
-// NewRedisEventBus creates a new Redis-based event bus
-func NewRedisEventBus(redisURL string) (*RedisEventBus, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
-
-	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err := client.Ping(ctx).Result()
+// NewRedisEventBus creates a new Redis-based event bus for a standalone,
+// cluster or sentinel deployment. busCfg sizes the handler worker pool.
+func NewRedisEventBus(cfg config.RedisConfig, busCfg config.EventBusConfig) (*RedisEventBus, error) {
+	client, err := redisclient.New(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+		return nil, err
 	}
 
-	return &RedisEventBus{
+	bus := &RedisEventBus{
 		client:   client,
 		handlers: make(map[EventType][]EventHandler),
+		busCfg:   busCfg,
 		stopChan: make(chan struct{}),
-	}, nil
+	}
+	if busCfg.StoreEvents {
+		bus.store = &RedisEventStore{client: client, retention: busCfg.StoreRetention}
+	}
+
+	return bus, nil
+}
+
+// RedisDialer returns a function that connects a Redis event bus, for use
+// with NewDegradingEventBus
+func RedisDialer(cfg config.RedisConfig, busCfg config.EventBusConfig) func() (EventBus, error) {
+	return func() (EventBus, error) {
+		bus, err := NewRedisEventBus(cfg, busCfg)
+		if err != nil {
+			return nil, err
+		}
+		return bus, nil
+	}
 }
 
 // Publish publishes an event to the event bus
@@ -94,14 +136,39 @@ func (eb *RedisEventBus) Publish(ctx context.Context, event *Event) error {
 	if event.Timestamp.IsZero() {
 		event.Timestamp = time.Now().UTC()
 	}
+	withTraceContext(ctx, event)
+	if eb.busCfg.Region != "" && event.Metadata[MetadataRegion] == "" {
+		if event.Metadata == nil {
+			event.Metadata = make(map[string]string)
+		}
+		event.Metadata[MetadataRegion] = eb.busCfg.Region
+	}
 
 	data, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %v", err)
 	}
 
-	channel := fmt.Sprintf("events:%s", event.Type)
-	return eb.client.Publish(ctx, channel, data).Err()
+	if err := eb.client.Publish(ctx, eb.channel(event.Type), data).Err(); err != nil {
+		return err
+	}
+
+	// Keep a copy for the event browser; losing it must not fail the publish
+	if eb.store != nil {
+		if err := eb.store.Store(ctx, event); err != nil {
+			log.Printf("Failed to store %s event %s: %v", event.Type, event.ID, err)
+		}
+	}
+	return nil
+}
+
+// channel returns the Pub/Sub channel of an event type, pinned to the bus's
+// region if it has one
+func (eb *RedisEventBus) channel(eventType EventType) string {
+	if eb.busCfg.Region == "" {
+		return fmt.Sprintf("events:%s", eventType)
+	}
+	return fmt.Sprintf("events:%s:%s", eb.busCfg.Region, eventType)
 }
 
 // Subscribe subscribes to events of a specific type
@@ -122,7 +189,8 @@ func (eb *RedisEventBus) Unsubscribe(eventType EventType) error {
 	return nil
 }
 
-// Start starts the event bus and begins processing events
+// Start starts the event bus and begins processing events and publishing
+// scheduled events
 func (eb *RedisEventBus) Start(ctx context.Context) error {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
@@ -130,20 +198,24 @@ func (eb *RedisEventBus) Start(ctx context.Context) error {
 	if eb.started {
 		return fmt.Errorf("event bus already started")
 	}
+	eb.started = true
+
+	// Publish scheduled events even if this service handles none
+	go eb.runScheduler(ctx)
 
 	// Subscribe to all event types we have handlers for
 	channels := make([]string, 0, len(eb.handlers))
 	for eventType := range eb.handlers {
-		channels = append(channels, fmt.Sprintf("events:%s", eventType))
+		channels = append(channels, eb.channel(eventType))
 	}
 
 	if len(channels) == 0 {
-		log.Println("No event handlers registered, not starting event bus")
+		log.Println("No event handlers registered, not subscribing to events")
 		return nil
 	}
 
 	eb.pubsub = eb.client.Subscribe(ctx, channels...)
-	eb.started = true
+	eb.dispatcher = newDispatcher(eb.busCfg)
 
 	// Start processing messages
 	go eb.processMessages(ctx)
@@ -152,12 +224,13 @@ func (eb *RedisEventBus) Start(ctx context.Context) error {
 	return nil
 }
 
-// Stop stops the event bus
+// Stop stops the event bus. Events already received are drained before it
+// returns, up to the configured drain timeout.
 func (eb *RedisEventBus) Stop() error {
 	eb.mu.Lock()
-	defer eb.mu.Unlock()
 
 	if !eb.started {
+		eb.mu.Unlock()
 		return nil
 	}
 
@@ -168,6 +241,14 @@ func (eb *RedisEventBus) Stop() error {
 	}
 
 	eb.started = false
+	dispatcher := eb.dispatcher
+	eb.mu.Unlock()
+
+	// Drain outside the lock so handlers can still use the bus
+	if dispatcher != nil {
+		dispatcher.stop()
+	}
+
 	log.Println("Event bus stopped")
 	return nil
 }
@@ -204,20 +285,8 @@ func (eb *RedisEventBus) handleMessage(ctx context.Context, msg *redis.Message)
 		return
 	}
 
-	// Execute all handlers for this event type
-	for _, handler := range handlers {
-		go func(h EventHandler) {
-			defer func() {
-				if r := recover(); r != nil {
-					log.Printf("Event handler panicked: %v", r)
-				}
-			}()
-
-			if err := h(ctx, &event); err != nil {
-				log.Printf("Event handler failed: %v", err)
-			}
-		}(handler)
-	}
+	// Handlers for the same subject run in order on one worker
+	eb.dispatcher.dispatch(ctx, &event, handlers)
 }
 
 // generateEventID generates a unique event ID
@@ -230,33 +299,34 @@ type EventStore interface {
 	Store(ctx context.Context, event *Event) error
 	GetEvents(ctx context.Context, subject string, fromTime time.Time) ([]*Event, error)
 	GetEventsByType(ctx context.Context, eventType EventType, fromTime time.Time) ([]*Event, error)
+	// GetEvent retrieves a single event by ID, or nil if it is not stored
+	GetEvent(ctx context.Context, id string) (*Event, error)
+	// Query searches stored events, newest first
+	Query(ctx context.Context, query EventQuery) ([]*Event, error)
 }
 
 // RedisEventStore implements EventStore using Redis
 type RedisEventStore struct {
-	client *redis.Client
+	client    redis.UniversalClient
+	retention time.Duration
 }
 
 // NewRedisEventStore creates a new Redis-based event store
-func NewRedisEventStore(redisURL string) (*RedisEventStore, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
-
-	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err := client.Ping(ctx).Result()
+func NewRedisEventStore(cfg config.RedisConfig) (*RedisEventStore, error) {
+	client, err := redisclient.New(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+		return nil, err
 	}
 
 	return &RedisEventStore{client: client}, nil
 }
 
-// Store stores an event in the event store
+// Store stores an event in the event store. Replays are not stored again.
 func (es *RedisEventStore) Store(ctx context.Context, event *Event) error {
+	if event.Metadata[MetadataReplayTo] != "" {
+		return nil
+	}
+
 	data, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %v", err)
@@ -264,6 +334,13 @@ func (es *RedisEventStore) Store(ctx context.Context, event *Event) error {
 
 	// Store in multiple keys for different query patterns
 	pipe := es.client.Pipeline()
+
+	// Store by ID and in the time index browsed by Query
+	pipe.Set(ctx, eventIDKey(event.ID), data, es.retention)
+	pipe.ZAdd(ctx, eventsAllKey, &redis.Z{
+		Score:  float64(event.Timestamp.Unix()),
+		Member: string(data),
+	})
 	
 	// Store by subject
 	subjectKey := fmt.Sprintf("events:subject:%s", event.Subject)
@@ -286,6 +363,14 @@ func (es *RedisEventStore) Store(ctx context.Context, event *Event) error {
 		Member: string(data),
 	})
 
+	// Drop events older than the retention period
+	if es.retention > 0 {
+		cutoff := fmt.Sprintf("(%d", time.Now().Add(-es.retention).Unix())
+		for _, key := range []string{eventsAllKey, subjectKey, typeKey, sourceKey} {
+			pipe.ZRemRangeByScore(ctx, key, "-inf", cutoff)
+		}
+	}
+
 	_, err = pipe.Exec(ctx)
 	return err
 }
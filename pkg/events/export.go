@@ -0,0 +1,74 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// exportPageSize is how many stored events are read from Redis at a time
+const exportPageSize = 1000
+
+// Export writes the stored events to w as NDJSON, oldest first, and returns
+// how many it wrote. Events stored after the export started are left out.
+func (es *RedisEventStore) Export(ctx context.Context, w io.Writer) (int, error) {
+	max := fmt.Sprintf("%d", time.Now().Unix())
+	out := bufio.NewWriter(w)
+
+	count := 0
+	for offset := int64(0); ; offset += exportPageSize {
+		results, err := es.client.ZRangeByScore(ctx, eventsAllKey, &redis.ZRangeBy{
+			Min:    "-inf",
+			Max:    max,
+			Offset: offset,
+			Count:  exportPageSize,
+		}).Result()
+		if err != nil {
+			return count, err
+		}
+
+		for _, result := range results {
+			if _, err := out.WriteString(result + "\n"); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if len(results) < exportPageSize {
+			break
+		}
+	}
+	return count, out.Flush()
+}
+
+// Import stores the events read from r, NDJSON as written by Export, and
+// returns how many it stored. Events that are already stored are skipped, so
+// importing the same events again stores nothing.
+func (es *RedisEventStore) Import(ctx context.Context, r io.Reader) (int, error) {
+	decoder := json.NewDecoder(r)
+	count := 0
+	for {
+		var event Event
+		if err := decoder.Decode(&event); err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, fmt.Errorf("failed to read exported event: %v", err)
+		}
+
+		existing, err := es.GetEvent(ctx, event.ID)
+		if err != nil {
+			return count, err
+		}
+		if existing != nil {
+			continue
+		}
+		if err := es.Store(ctx, &event); err != nil {
+			return count, fmt.Errorf("failed to store event %s: %v", event.ID, err)
+		}
+		count++
+	}
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/redisclient"
+)
+
+// DefaultIdempotencyTTL is how long processed event IDs are remembered
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyClaimTTL bounds how long an in-flight claim blocks redeliveries,
+// so an event is retried if its consumer crashed mid-handling
+const idempotencyClaimTTL = 5 * time.Minute
+
+// IdempotencyStore records which events a consumer has processed
+type IdempotencyStore interface {
+	// Claim marks an event as in flight. It returns false if the event was
+	// already processed or is being processed.
+	Claim(ctx context.Context, key string) (bool, error)
+	// Complete marks a claimed event as processed for ttl
+	Complete(ctx context.Context, key string, ttl time.Duration) error
+	// Release drops a claim so a redelivery is processed again
+	Release(ctx context.Context, key string) error
+}
+
+// RedisIdempotencyStore implements IdempotencyStore with Redis keys
+type RedisIdempotencyStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisIdempotencyStore creates a new Redis-backed idempotency store
+func NewRedisIdempotencyStore(cfg config.RedisConfig) (*RedisIdempotencyStore, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisIdempotencyStore{client: client}, nil
+}
+
+// Claim marks an event as in flight
+func (s *RedisIdempotencyStore) Claim(ctx context.Context, key string) (bool, error) {
+	return s.client.SetNX(ctx, key, "processing", idempotencyClaimTTL).Result()
+}
+
+// Complete marks a claimed event as processed
+func (s *RedisIdempotencyStore) Complete(ctx context.Context, key string, ttl time.Duration) error {
+	return s.client.Set(ctx, key, "done", ttl).Err()
+}
+
+// Release drops a claim
+func (s *RedisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, key).Err()
+}
+
+// Idempotent wraps a handler so that an event delivered more than once is
+// handled once per consumer. Processed event IDs are remembered for ttl. A
+// failed handler releases its claim so the event can be retried. If the store
+// is nil or unavailable the handler runs anyway, keeping at-least-once
+// delivery rather than dropping events. Replays (see Replay) bypass dedup
+// and are only handled by the consumer they name.
+func Idempotent(store IdempotencyStore, consumer string, ttl time.Duration, handler EventHandler) EventHandler {
+	return func(ctx context.Context, event *Event) error {
+		if replayTo := event.Metadata[MetadataReplayTo]; replayTo != "" {
+			if replayTo != consumer {
+				return nil
+			}
+			log.Printf("Replaying %s event %s to %s", event.Type, event.ID, consumer)
+			return handler(ctx, event)
+		}
+
+		if store == nil || event.ID == "" {
+			return handler(ctx, event)
+		}
+		key := fmt.Sprintf("idempotency:%s:%s", consumer, event.ID)
+
+		claimed, err := store.Claim(ctx, key)
+		if err != nil {
+			log.Printf("Idempotency store unavailable, handling %s event %s without dedup: %v", event.Type, event.ID, err)
+			return handler(ctx, event)
+		}
+		if !claimed {
+			log.Printf("Skipping duplicate %s event %s for %s", event.Type, event.ID, consumer)
+			return nil
+		}
+
+		if err := handler(ctx, event); err != nil {
+			if releaseErr := store.Release(ctx, key); releaseErr != nil {
+				log.Printf("Failed to release idempotency claim for event %s: %v", event.ID, releaseErr)
+			}
+			return err
+		}
+
+		if err := store.Complete(ctx, key, ttl); err != nil {
+			log.Printf("Failed to record event %s as processed: %v", event.ID, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"time"
+)
+
+// Incident statuses
+const (
+	IncidentFiring   = "firing"
+	IncidentResolved = "resolved"
+)
+
+// IncidentReport is the payload of a platform.incident event. Signals about
+// the same service while an incident is open, such as its circuit breaker
+// opening and its error budget burning, share the incident's ID.
+type IncidentReport struct {
+	ID      string `json:"incident_id"`
+	Status  string `json:"status"`
+	Service string `json:"service"`
+	// Kind is the signal that changed, e.g. "circuit_open"
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	// Signals are the incident's kinds still firing
+	Signals   []string               `json:"signals"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	StartedAt time.Time              `json:"started_at"`
+	// ResolvedAt is zero until the incident is resolved
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// NewIncidentEvent creates the event reporting that an incident was opened,
+// changed or resolved, for the ops channel
+func NewIncidentEvent(source string, report *IncidentReport) *Event {
+	data := map[string]interface{}{
+		"incident_id": report.ID,
+		"status":      report.Status,
+		"service":     report.Service,
+		"kind":        report.Kind,
+		"severity":    report.Severity,
+		"summary":     report.Summary,
+		"signals":     report.Signals,
+		"details":     report.Details,
+		"started_at":  report.StartedAt.UTC().Format(time.RFC3339),
+	}
+	if report.ResolvedAt != nil {
+		data["resolved_at"] = report.ResolvedAt.UTC().Format(time.RFC3339)
+	}
+
+	return &Event{
+		Type:    PlatformIncident,
+		Source:  source,
+		Subject: report.Service,
+		Data:    data,
+	}
+}
+
+// NewBusinessAnomalyEvent creates the event reporting an anomaly in the
+// business metrics. It carries an incident report, so the ops channels route
+// it like an incident.
+func NewBusinessAnomalyEvent(source string, report *IncidentReport) *Event {
+	event := NewIncidentEvent(source, report)
+	event.Type = BusinessAnomaly
+	return event
+}
+
+// IncidentReportFromEvent reads the report of a platform.incident or
+// platform.business_anomaly event
+func IncidentReportFromEvent(event *Event) *IncidentReport {
+	report := &IncidentReport{
+		ID:       stringField(event.Data, "incident_id"),
+		Status:   stringField(event.Data, "status"),
+		Service:  stringField(event.Data, "service"),
+		Kind:     stringField(event.Data, "kind"),
+		Severity: stringField(event.Data, "severity"),
+		Summary:  stringField(event.Data, "summary"),
+		Signals:  []string{},
+	}
+	// Events that went through Redis carry JSON types
+	switch signals := event.Data["signals"].(type) {
+	case []string:
+		report.Signals = append(report.Signals, signals...)
+	case []interface{}:
+		for _, signal := range signals {
+			if s, ok := signal.(string); ok {
+				report.Signals = append(report.Signals, s)
+			}
+		}
+	}
+	if details, ok := event.Data["details"].(map[string]interface{}); ok {
+		report.Details = details
+	}
+	report.StartedAt, _ = time.Parse(time.RFC3339, stringField(event.Data, "started_at"))
+	if resolvedAt, err := time.Parse(time.RFC3339, stringField(event.Data, "resolved_at")); err == nil {
+		report.ResolvedAt = &resolvedAt
+	}
+	return report
+}
+
+func stringField(data map[string]interface{}, key string) string {
+	s, _ := data[key].(string)
+	return s
+}
@@ -0,0 +1,597 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// ErrOutboxFull is returned when an event cannot be buffered because the
+// outbox is at capacity and the overflow policy rejects new events
+var ErrOutboxFull = errors.New("event outbox is full")
+
+// OverflowPolicy decides what happens when an event is buffered into a full
+// outbox
+type OverflowPolicy string
+
+// Outbox overflow policies
+const (
+	// OverflowReject fails the publish with ErrOutboxFull
+	OverflowReject OverflowPolicy = "reject"
+	// OverflowDropOldest discards the oldest buffered event to make room
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+	// OverflowDropNewest discards the event being published
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+)
+
+// Outbox buffers events locally until they can be published
+type Outbox interface {
+	Append(ctx context.Context, event *Event) error
+	// Peek returns up to limit of the oldest buffered events
+	Peek(ctx context.Context, limit int) ([]*Event, error)
+	// Remove drops events once they are published
+	Remove(ctx context.Context, events []*Event) error
+	Len(ctx context.Context) (int, error)
+}
+
+// OutboxConfig holds outbox configuration
+type OutboxConfig struct {
+	Service string
+	MaxSize int
+	Policy  OverflowPolicy
+	// Path is the file buffered events are persisted to. Empty keeps them in
+	// memory only, so they are lost if the process exits.
+	Path string
+}
+
+// NewOutbox creates a file-backed outbox if a path is configured and an
+// in-memory one otherwise
+func NewOutbox(cfg OutboxConfig) (Outbox, error) {
+	switch cfg.Policy {
+	case OverflowReject, OverflowDropOldest, OverflowDropNewest:
+	case "":
+		cfg.Policy = OverflowReject
+	default:
+		return nil, fmt.Errorf("unsupported outbox overflow policy: %s", cfg.Policy)
+	}
+
+	if cfg.Path == "" {
+		return NewMemoryOutbox(cfg), nil
+	}
+	return NewFileOutbox(cfg)
+}
+
+// boundedQueue is a FIFO of events with an overflow policy. It is not safe for
+// concurrent use; outboxes guard it with their own lock.
+type boundedQueue struct {
+	cfg    OutboxConfig
+	events []*Event
+}
+
+// push adds an event, applying the overflow policy if the queue is full. It
+// returns the event that was dropped, if any.
+func (q *boundedQueue) push(event *Event) (*Event, error) {
+	if len(q.events) < q.cfg.MaxSize {
+		q.events = append(q.events, event)
+		q.updateSize()
+		return nil, nil
+	}
+
+	metrics.RecordEventDropped(q.cfg.Service, string(q.cfg.Policy))
+
+	switch q.cfg.Policy {
+	case OverflowDropOldest:
+		dropped := q.events[0]
+		q.events = append(q.events[1:], event)
+		log.Printf("Event outbox full, dropped oldest %s event %s", dropped.Type, dropped.ID)
+		return dropped, nil
+	case OverflowDropNewest:
+		log.Printf("Event outbox full, dropped %s event %s", event.Type, event.ID)
+		return event, nil
+	default:
+		return nil, ErrOutboxFull
+	}
+}
+
+func (q *boundedQueue) peek(limit int) []*Event {
+	if limit > len(q.events) {
+		limit = len(q.events)
+	}
+	return append([]*Event(nil), q.events[:limit]...)
+}
+
+// remove drops the given events. Events dropped by the overflow policy since
+// they were peeked are simply absent, so removal is by ID, not count.
+func (q *boundedQueue) remove(events []*Event) {
+	published := make(map[string]bool, len(events))
+	for _, event := range events {
+		published[event.ID] = true
+	}
+
+	remaining := q.events[:0]
+	for _, event := range q.events {
+		if !published[event.ID] {
+			remaining = append(remaining, event)
+		}
+	}
+	q.events = remaining
+	q.updateSize()
+}
+
+func (q *boundedQueue) updateSize() {
+	metrics.UpdateEventOutboxSize(q.cfg.Service, len(q.events))
+}
+
+// MemoryOutbox implements Outbox as a bounded in-process queue. Buffered
+// events are lost if the process exits before the broker comes back.
+type MemoryOutbox struct {
+	mu    sync.Mutex
+	queue boundedQueue
+}
+
+// NewMemoryOutbox creates a new in-memory outbox
+func NewMemoryOutbox(cfg OutboxConfig) *MemoryOutbox {
+	return &MemoryOutbox{queue: boundedQueue{cfg: cfg}}
+}
+
+// Append adds an event to the end of the outbox
+func (o *MemoryOutbox) Append(ctx context.Context, event *Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_, err := o.queue.push(event)
+	return err
+}
+
+// Peek returns up to limit of the oldest buffered events
+func (o *MemoryOutbox) Peek(ctx context.Context, limit int) ([]*Event, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.queue.peek(limit), nil
+}
+
+// Remove drops published events
+func (o *MemoryOutbox) Remove(ctx context.Context, events []*Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.queue.remove(events)
+	return nil
+}
+
+// Len returns the number of buffered events
+func (o *MemoryOutbox) Len(ctx context.Context) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.queue.events), nil
+}
+
+// FileOutbox implements Outbox with events persisted to a JSON lines file, so
+// buffered events survive a restart. Appends are written through to the end
+// of the file; the file is rewritten when events are removed or dropped.
+type FileOutbox struct {
+	mu    sync.Mutex
+	queue boundedQueue
+	path  string
+}
+
+// NewFileOutbox creates a file-backed outbox, loading any events left over
+// from a previous run
+func NewFileOutbox(cfg OutboxConfig) (*FileOutbox, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %v", err)
+	}
+
+	o := &FileOutbox{queue: boundedQueue{cfg: cfg}, path: cfg.Path}
+
+	file, err := os.Open(cfg.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open outbox: %v", err)
+	}
+	if err == nil {
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for scanner.Scan() {
+			var event Event
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				log.Printf("Skipping corrupt outbox entry: %v", err)
+				continue
+			}
+			o.queue.events = append(o.queue.events, &event)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read outbox: %v", err)
+		}
+	}
+
+	o.queue.updateSize()
+	if len(o.queue.events) > 0 {
+		log.Printf("Loaded %d buffered events from outbox %s", len(o.queue.events), cfg.Path)
+	}
+
+	return o, nil
+}
+
+// Append adds an event to the end of the outbox and persists it
+func (o *FileOutbox) Append(ctx context.Context, event *Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	dropped, err := o.queue.push(event)
+	switch {
+	case err != nil:
+		return err
+	case dropped == event:
+		return nil
+	case dropped != nil:
+		return o.rewrite()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	file, err := os.OpenFile(o.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write outbox: %v", err)
+	}
+	return file.Sync()
+}
+
+// Peek returns up to limit of the oldest buffered events
+func (o *FileOutbox) Peek(ctx context.Context, limit int) ([]*Event, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.queue.peek(limit), nil
+}
+
+// Remove drops published events and compacts the file
+func (o *FileOutbox) Remove(ctx context.Context, events []*Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.queue.remove(events)
+	return o.rewrite()
+}
+
+// Len returns the number of buffered events
+func (o *FileOutbox) Len(ctx context.Context) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.queue.events), nil
+}
+
+// rewrite atomically replaces the file with the buffered events
+func (o *FileOutbox) rewrite() error {
+	tmp := o.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite outbox: %v", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, event := range o.queue.events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to marshal event: %v", err)
+		}
+		writer.Write(append(data, '\n'))
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to rewrite outbox: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to rewrite outbox: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to rewrite outbox: %v", err)
+	}
+
+	return os.Rename(tmp, o.path)
+}
+
+// outboxFlushBatch is the number of buffered events published per attempt
+const outboxFlushBatch = 100
+
+// DegradingEventBus wraps an event bus so that a Redis outage degrades
+// publishing instead of failing it. While the bus is unavailable events are
+// buffered in the outbox and published in order once it recovers; if the bus
+// could not be reached at startup it is re-dialed in the background and
+// subscriptions are attached when it connects.
+type DegradingEventBus struct {
+	dial          func() (EventBus, error)
+	outbox        Outbox
+	retryInterval time.Duration
+
+	mu        sync.RWMutex
+	bus       EventBus
+	handlers  map[EventType][]EventHandler
+	startCtx  context.Context
+	started   bool
+	degraded  bool
+	listeners []func(err error)
+	stopChan  chan struct{}
+}
+
+// NewDegradingEventBus creates a new degrading event bus. bus may be nil if
+// the initial connection failed, in which case dial is retried every
+// retryInterval.
+func NewDegradingEventBus(bus EventBus, dial func() (EventBus, error), outbox Outbox, retryInterval time.Duration) *DegradingEventBus {
+	return &DegradingEventBus{
+		dial:          dial,
+		outbox:        outbox,
+		retryInterval: retryInterval,
+		bus:           bus,
+		handlers:      make(map[EventType][]EventHandler),
+		degraded:      bus == nil,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// OnChange registers a callback invoked when the bus becomes degraded (with
+// the error) or recovers (with nil)
+func (d *DegradingEventBus) OnChange(fn func(err error)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listeners = append(d.listeners, fn)
+}
+
+// Degraded reports whether events are currently being buffered
+func (d *DegradingEventBus) Degraded() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.degraded
+}
+
+// Publish publishes an event, buffering it in the outbox if the bus is
+// unavailable or older events are still waiting to be flushed
+func (d *DegradingEventBus) Publish(ctx context.Context, event *Event) error {
+	if event.ID == "" {
+		event.ID = generateEventID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	withTraceContext(ctx, event)
+
+	d.mu.RLock()
+	bus := d.bus
+	d.mu.RUnlock()
+
+	pending, err := d.outbox.Len(ctx)
+	if err != nil {
+		return err
+	}
+
+	if bus != nil && pending == 0 {
+		err := bus.Publish(ctx, event)
+		if err == nil {
+			return nil
+		}
+		d.setDegraded(err)
+	}
+
+	if err := d.outbox.Append(ctx, event); err != nil {
+		return err
+	}
+	log.Printf("Event bus degraded, buffered %s event %s in outbox", event.Type, event.ID)
+	return nil
+}
+
+// PublishAt schedules an event on the underlying bus. Scheduled events live in
+// Redis, so scheduling fails rather than buffering while the bus is down.
+func (d *DegradingEventBus) PublishAt(ctx context.Context, event *Event, at time.Time) error {
+	d.mu.RLock()
+	bus := d.bus
+	d.mu.RUnlock()
+
+	if bus == nil {
+		return fmt.Errorf("event bus unavailable, cannot schedule %s event", event.Type)
+	}
+
+	err := bus.PublishAt(ctx, event, at)
+	if err != nil {
+		d.setDegraded(err)
+	}
+	return err
+}
+
+// PublishAfter schedules an event on the underlying bus after a delay
+func (d *DegradingEventBus) PublishAfter(ctx context.Context, event *Event, delay time.Duration) error {
+	return d.PublishAt(ctx, event, time.Now().Add(delay))
+}
+
+// Subscribe subscribes to events of a specific type. Subscriptions made while
+// the bus is unreachable are attached once it connects.
+func (d *DegradingEventBus) Subscribe(eventType EventType, handler EventHandler) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.handlers[eventType] = append(d.handlers[eventType], handler)
+	if d.bus != nil {
+		return d.bus.Subscribe(eventType, handler)
+	}
+	return nil
+}
+
+// Unsubscribe removes all handlers for a specific event type
+func (d *DegradingEventBus) Unsubscribe(eventType EventType) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.handlers, eventType)
+	if d.bus != nil {
+		return d.bus.Unsubscribe(eventType)
+	}
+	return nil
+}
+
+// Start starts the underlying bus, if connected, and the background loop that
+// reconnects and flushes the outbox
+func (d *DegradingEventBus) Start(ctx context.Context) error {
+	d.mu.Lock()
+	d.startCtx = ctx
+	d.started = true
+	bus := d.bus
+	d.mu.Unlock()
+
+	if bus != nil {
+		if err := bus.Start(ctx); err != nil {
+			return err
+		}
+	}
+
+	go d.run(ctx)
+	return nil
+}
+
+// Stop stops the background loop and the underlying bus
+func (d *DegradingEventBus) Stop() error {
+	close(d.stopChan)
+
+	d.mu.RLock()
+	bus := d.bus
+	d.mu.RUnlock()
+
+	if bus != nil {
+		return bus.Stop()
+	}
+	return nil
+}
+
+// run reconnects and flushes the outbox until stopped
+func (d *DegradingEventBus) run(ctx context.Context) {
+	ticker := time.NewTicker(d.retryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stopChan:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.connect(); err != nil {
+				continue
+			}
+			d.flush(ctx)
+		}
+	}
+}
+
+// connect dials the bus if it has never connected and attaches subscriptions
+func (d *DegradingEventBus) connect() error {
+	d.mu.RLock()
+	connected := d.bus != nil
+	d.mu.RUnlock()
+	if connected {
+		return nil
+	}
+
+	bus, err := d.dial()
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	for eventType, handlers := range d.handlers {
+		for _, handler := range handlers {
+			bus.Subscribe(eventType, handler)
+		}
+	}
+	d.bus = bus
+	started, startCtx := d.started, d.startCtx
+	d.mu.Unlock()
+
+	if started {
+		if err := bus.Start(startCtx); err != nil {
+			log.Printf("Failed to start reconnected event bus: %v", err)
+		}
+	}
+
+	log.Println("Event bus connected")
+	return nil
+}
+
+// flush publishes buffered events in order, stopping at the first failure
+func (d *DegradingEventBus) flush(ctx context.Context) {
+	d.mu.RLock()
+	bus := d.bus
+	d.mu.RUnlock()
+
+	for {
+		pending, err := d.outbox.Peek(ctx, outboxFlushBatch)
+		if err != nil {
+			log.Printf("Failed to read event outbox: %v", err)
+			return
+		}
+
+		published := 0
+		for _, event := range pending {
+			if err := bus.Publish(ctx, event); err != nil {
+				d.setDegraded(err)
+				break
+			}
+			published++
+		}
+
+		if published > 0 {
+			if err := d.outbox.Remove(ctx, pending[:published]); err != nil {
+				log.Printf("Failed to remove published events from outbox: %v", err)
+				return
+			}
+			log.Printf("Flushed %d buffered events from outbox", published)
+		}
+		if published < len(pending) {
+			return
+		}
+		if len(pending) < outboxFlushBatch {
+			break
+		}
+	}
+
+	d.setDegraded(nil)
+}
+
+// setDegraded records a transition into (err != nil) or out of degraded mode
+func (d *DegradingEventBus) setDegraded(err error) {
+	d.mu.Lock()
+	changed := d.degraded != (err != nil)
+	d.degraded = err != nil
+	listeners := d.listeners
+	d.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if err != nil {
+		log.Printf("Event bus unavailable, buffering events in outbox: %v", err)
+	} else {
+		log.Println("Event bus recovered")
+	}
+	for _, fn := range listeners {
+		fn(err)
+	}
+}
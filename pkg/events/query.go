@@ -0,0 +1,209 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Event metadata keys set by the platform
+const (
+	// MetadataTraceID and MetadataSpanID link an event to the trace that
+	// published it
+	MetadataTraceID = "trace_id"
+	MetadataSpanID  = "span_id"
+	// MetadataBaggage carries the publisher's OpenTelemetry baggage, such as
+	// the tenant, to handlers
+	MetadataBaggage = "baggage"
+	// MetadataRegion is the region an event was published in
+	MetadataRegion = "region"
+	// MetadataOriginRegion is set on events replicated from another region
+	// to the region they were first published in
+	MetadataOriginRegion = "origin_region"
+)
+
+// eventsAllKey indexes every stored event by time
+const eventsAllKey = "events:all"
+
+// Query limits
+const (
+	DefaultQueryLimit = 50
+	MaxQueryLimit     = 500
+
+	// queryPageSize and maxQueryScan bound how many stored events a filtered
+	// query reads before giving up
+	queryPageSize = 500
+	maxQueryScan  = 10000
+)
+
+// EventQuery selects stored events. Empty fields are not applied; Text
+// matches case-insensitively anywhere in the event data.
+type EventQuery struct {
+	Type    EventType
+	Subject string
+	Source  string
+	From    time.Time
+	To      time.Time
+	Text    string
+	Limit   int
+}
+
+func eventIDKey(id string) string {
+	return fmt.Sprintf("events:id:%s", id)
+}
+
+// withTraceContext records the publishing span and baggage on the event so
+// stored events can be linked to their trace and handlers continue it
+func withTraceContext(ctx context.Context, event *Event) {
+	if bag := baggage.FromContext(ctx); bag.Len() > 0 && event.Metadata[MetadataBaggage] == "" {
+		if event.Metadata == nil {
+			event.Metadata = make(map[string]string)
+		}
+		event.Metadata[MetadataBaggage] = bag.String()
+	}
+
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() || event.Metadata[MetadataTraceID] != "" {
+		return
+	}
+
+	if event.Metadata == nil {
+		event.Metadata = make(map[string]string)
+	}
+	event.Metadata[MetadataTraceID] = spanContext.TraceID().String()
+	event.Metadata[MetadataSpanID] = spanContext.SpanID().String()
+}
+
+// handlerContext returns ctx with the trace and baggage an event was
+// published with, so its handlers' spans join the publisher's trace
+func handlerContext(ctx context.Context, event *Event) context.Context {
+	if bag, err := baggage.Parse(event.Metadata[MetadataBaggage]); err == nil && bag.Len() > 0 {
+		ctx = baggage.ContextWithBaggage(ctx, bag)
+	}
+
+	traceID, err := trace.TraceIDFromHex(event.Metadata[MetadataTraceID])
+	if err != nil {
+		return ctx
+	}
+	spanID, err := trace.SpanIDFromHex(event.Metadata[MetadataSpanID])
+	if err != nil {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+}
+
+// GetEvent retrieves a single event by ID
+func (es *RedisEventStore) GetEvent(ctx context.Context, id string) (*Event, error) {
+	data, err := es.client.Get(ctx, eventIDKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stored event: %v", err)
+	}
+	return &event, nil
+}
+
+// Query searches stored events, newest first. The most selective index is
+// read and the remaining filters are applied while paging through it.
+func (es *RedisEventStore) Query(ctx context.Context, query EventQuery) ([]*Event, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+	if limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+
+	key := eventsAllKey
+	switch {
+	case query.Subject != "":
+		key = fmt.Sprintf("events:subject:%s", query.Subject)
+	case query.Type != "":
+		key = fmt.Sprintf("events:type:%s", query.Type)
+	case query.Source != "":
+		key = fmt.Sprintf("events:source:%s", query.Source)
+	}
+
+	min, max := "-inf", "+inf"
+	if !query.From.IsZero() {
+		min = fmt.Sprintf("%d", query.From.Unix())
+	}
+	if !query.To.IsZero() {
+		max = fmt.Sprintf("%d", query.To.Unix())
+	}
+	text := strings.ToLower(query.Text)
+
+	var events []*Event
+	for offset := 0; offset < maxQueryScan && len(events) < limit; offset += queryPageSize {
+		results, err := es.client.ZRevRangeByScore(ctx, key, &redis.ZRangeBy{
+			Min:    min,
+			Max:    max,
+			Offset: int64(offset),
+			Count:  queryPageSize,
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range results {
+			var event Event
+			if err := json.Unmarshal([]byte(result), &event); err != nil {
+				log.Printf("Failed to unmarshal stored event: %v", err)
+				continue
+			}
+			if query.matches(&event, text) {
+				events = append(events, &event)
+				if len(events) == limit {
+					break
+				}
+			}
+		}
+
+		if len(results) < queryPageSize {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+// matches applies the query filters to an event. text is the lowercased
+// free-text filter.
+func (q EventQuery) matches(event *Event, text string) bool {
+	if q.Type != "" && event.Type != q.Type {
+		return false
+	}
+	if q.Subject != "" && event.Subject != q.Subject {
+		return false
+	}
+	if q.Source != "" && event.Source != q.Source {
+		return false
+	}
+	if text == "" {
+		return true
+	}
+
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(data)), text)
+}
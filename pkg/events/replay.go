@@ -0,0 +1,34 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Replay metadata keys. A replayed event is only handled by the consumer
+// named in MetadataReplayTo.
+const (
+	MetadataReplayTo   = "replay_to"
+	MetadataReplayedAt = "replayed_at"
+)
+
+// Replay publishes a copy of a stored event for a single consumer to handle
+// again. Consumers are the names given to Idempotent; every other consumer
+// ignores the replay, and the named one handles it even though it already
+// processed the event.
+func Replay(ctx context.Context, bus EventBus, event *Event, consumer string) error {
+	if consumer == "" {
+		return errors.New("replay consumer is required")
+	}
+
+	replay := *event
+	replay.Metadata = make(map[string]string, len(event.Metadata)+2)
+	for key, value := range event.Metadata {
+		replay.Metadata[key] = value
+	}
+	replay.Metadata[MetadataReplayTo] = consumer
+	replay.Metadata[MetadataReplayedAt] = time.Now().UTC().Format(time.RFC3339)
+
+	return bus.Publish(ctx, &replay)
+}
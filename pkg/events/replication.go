@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// Replicator forwards events of selected types published in its region to
+// another region's event bus, so read models in a passive region stay warm.
+//
+// Forwarded events keep their ID, so idempotent handlers in the target
+// region handle each event once even if it is forwarded more than once, and
+// carry the region they were first published in as MetadataOriginRegion.
+// Events replicated in from another region are never forwarded again, so
+// replicators running in both directions don't send events back and forth.
+type Replicator struct {
+	source       EventBus
+	target       EventBus
+	region       string
+	targetRegion string
+	types        []EventType
+}
+
+// NewReplicator creates a replicator forwarding events of types from source,
+// the bus of region, to target, the bus of targetRegion
+func NewReplicator(source, target EventBus, region, targetRegion string, types []EventType) (*Replicator, error) {
+	if region == "" || targetRegion == "" {
+		return nil, errors.New("replication needs a source and a target region")
+	}
+	if region == targetRegion {
+		return nil, fmt.Errorf("replication target region %s is the local region", targetRegion)
+	}
+	if len(types) == 0 {
+		return nil, errors.New("replication needs at least one event type")
+	}
+
+	return &Replicator{
+		source:       source,
+		target:       target,
+		region:       region,
+		targetRegion: targetRegion,
+		types:        types,
+	}, nil
+}
+
+// Start subscribes to the replicated event types and starts the source bus.
+// The caller stops the source bus.
+func (r *Replicator) Start(ctx context.Context) error {
+	for _, eventType := range r.types {
+		if err := r.source.Subscribe(eventType, r.forward); err != nil {
+			return fmt.Errorf("failed to subscribe to %s: %v", eventType, err)
+		}
+	}
+	if err := r.source.Start(ctx); err != nil {
+		return err
+	}
+
+	log.Printf("Replicating %d event types from %s to %s", len(r.types), r.region, r.targetRegion)
+	return nil
+}
+
+// forward publishes a copy of a local event on the target bus
+func (r *Replicator) forward(ctx context.Context, event *Event) error {
+	// Replays are meant for one local consumer
+	if event.Metadata[MetadataReplayTo] != "" {
+		return nil
+	}
+	origin := event.Metadata[MetadataOriginRegion]
+	if origin == "" {
+		origin = event.Metadata[MetadataRegion]
+	}
+	if origin != "" && origin != r.region {
+		return nil
+	}
+
+	replica := *event
+	replica.Metadata = make(map[string]string, len(event.Metadata)+1)
+	for key, value := range event.Metadata {
+		replica.Metadata[key] = value
+	}
+	replica.Metadata[MetadataOriginRegion] = r.region
+	// The target bus records its own region
+	delete(replica.Metadata, MetadataRegion)
+
+	if err := r.target.Publish(ctx, &replica); err != nil {
+		metrics.RecordEventReplicated(string(event.Type), r.targetRegion, "failed")
+		return fmt.Errorf("failed to replicate %s event %s to %s: %v", event.Type, event.ID, r.targetRegion, err)
+	}
+	metrics.RecordEventReplicated(string(event.Type), r.targetRegion, "replicated")
+	return nil
+}
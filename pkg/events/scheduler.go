@@ -0,0 +1,123 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// scheduledEventsKey is the sorted set holding events waiting to be
+// published, scored by their due time in unix milliseconds
+const scheduledEventsKey = "events:scheduled"
+
+// scheduledKey returns the sorted set of the bus's scheduled events, pinned
+// to its region like its channels
+func (eb *RedisEventBus) scheduledKey() string {
+	if eb.busCfg.Region == "" {
+		return scheduledEventsKey
+	}
+	return "events:" + eb.busCfg.Region + ":scheduled"
+}
+
+// Scheduler polling settings
+const (
+	schedulerPollInterval = time.Second
+	schedulerBatchSize    = 100
+)
+
+// PublishAt schedules an event to be published at the given time. Scheduled
+// events are kept in Redis, so they survive restarts and are published by
+// whichever instance picks them up first once they are due.
+func (eb *RedisEventBus) PublishAt(ctx context.Context, event *Event, at time.Time) error {
+	if !at.After(time.Now()) {
+		return eb.Publish(ctx, event)
+	}
+
+	if event.ID == "" {
+		event.ID = generateEventID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	return eb.client.ZAdd(ctx, eb.scheduledKey(), &redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: string(data),
+	}).Err()
+}
+
+// PublishAfter schedules an event to be published after the given delay
+func (eb *RedisEventBus) PublishAfter(ctx context.Context, event *Event, delay time.Duration) error {
+	return eb.PublishAt(ctx, event, time.Now().Add(delay))
+}
+
+// runScheduler publishes due scheduled events until the bus is stopped
+func (eb *RedisEventBus) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(schedulerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-eb.stopChan:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := eb.publishDue(ctx); err != nil {
+				log.Printf("Failed to publish scheduled events: %v", err)
+			}
+		}
+	}
+}
+
+// publishDue publishes scheduled events whose time has come. Each event is
+// claimed by removing it from the sorted set first, so when several
+// instances poll at once only one of them publishes it.
+func (eb *RedisEventBus) publishDue(ctx context.Context) error {
+	members, err := eb.client.ZRangeByScore(ctx, eb.scheduledKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: schedulerBatchSize,
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		removed, err := eb.client.ZRem(ctx, eb.scheduledKey(), member).Result()
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			// Another instance claimed it
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal([]byte(member), &event); err != nil {
+			log.Printf("Dropping malformed scheduled event: %v", err)
+			continue
+		}
+
+		if err := eb.Publish(ctx, &event); err != nil {
+			// Put it back so it is retried on the next poll
+			eb.client.ZAdd(ctx, eb.scheduledKey(), &redis.Z{
+				Score:  float64(time.Now().UnixMilli()),
+				Member: member,
+			})
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package events
+
+import "time"
+
+// SecurityAnomalyReport is the payload of a security.anomaly event
+type SecurityAnomalyReport struct {
+	// Client is the rate limit key of the client, e.g. "user:<id>" or
+	// "ip:<address>"
+	Client string
+	Tier   string
+	// Kind is what was unusual, e.g. "request_spike" or "error_ratio"
+	Kind     string
+	Requests int64
+	Errors   int64
+	// Baseline is the client's usual number of requests per interval
+	Baseline float64
+	Interval time.Duration
+	// RestrictedUntil is when a stricter limit applied to the client ends;
+	// zero if none was applied
+	RestrictedUntil time.Time
+}
+
+// NewSecurityAnomalyEvent creates the event reporting unusual traffic from a
+// client, for security monitoring to alert on or investigate
+func NewSecurityAnomalyEvent(source string, report *SecurityAnomalyReport) *Event {
+	data := map[string]interface{}{
+		"client":           report.Client,
+		"tier":             report.Tier,
+		"kind":             report.Kind,
+		"requests":         report.Requests,
+		"errors":           report.Errors,
+		"baseline":         report.Baseline,
+		"interval_seconds": report.Interval.Seconds(),
+	}
+	if !report.RestrictedUntil.IsZero() {
+		data["restricted_until"] = report.RestrictedUntil.UTC().Format(time.RFC3339)
+	}
+
+	return &Event{
+		Type:    SecurityAnomaly,
+		Source:  source,
+		Subject: report.Client,
+		Data:    data,
+	}
+}
+
+// Reasons a sign-in is suspicious
+const (
+	LoginReasonNewDevice        = "new_device"
+	LoginReasonImpossibleTravel = "impossible_travel"
+)
+
+// SuspiciousLoginReport is the payload of a user.suspicious_login event
+type SuspiciousLoginReport struct {
+	UserID  string
+	Email   string
+	LoginID string
+	// Reasons are why the sign-in is suspicious, e.g. "new_device" or
+	// "impossible_travel"
+	Reasons   []string
+	IPAddress string
+	UserAgent string
+	Country   string
+	City      string
+	// PreviousCountry, PreviousCity and DistanceKm describe the previous
+	// located sign-in, for impossible travel
+	PreviousCountry string
+	PreviousCity    string
+	DistanceKm      float64
+	// SecureToken is the single-use token of the alert's "secure my account"
+	// link
+	SecureToken          string
+	SecureTokenExpiresAt time.Time
+	LoggedInAt           time.Time
+}
+
+// NewSuspiciousLoginEvent creates the event asking notification-service to
+// alert a user to a sign-in they may not have made
+func NewSuspiciousLoginEvent(source string, report *SuspiciousLoginReport) *Event {
+	data := map[string]interface{}{
+		"user_id":                 report.UserID,
+		"email":                   report.Email,
+		"login_id":                report.LoginID,
+		"reasons":                 report.Reasons,
+		"ip_address":              report.IPAddress,
+		"user_agent":              report.UserAgent,
+		"secure_token":            report.SecureToken,
+		"secure_token_expires_at": report.SecureTokenExpiresAt.Unix(),
+		"logged_in_at":            report.LoggedInAt.UTC().Format(time.RFC3339),
+	}
+	if report.Country != "" || report.City != "" {
+		data["country"] = report.Country
+		data["city"] = report.City
+	}
+	if report.DistanceKm > 0 {
+		data["previous_country"] = report.PreviousCountry
+		data["previous_city"] = report.PreviousCity
+		data["distance_km"] = report.DistanceKm
+	}
+
+	return &Event{
+		Type:    UserSuspiciousLogin,
+		Source:  source,
+		Subject: report.UserID,
+		Data:    data,
+	}
+}
@@ -0,0 +1,122 @@
+// Package geoip resolves client IP addresses to approximate locations, so
+// that successive sign-ins can be compared by where they came from.
+package geoip
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// earthRadiusKm is the mean radius of the Earth
+const earthRadiusKm = 6371.0
+
+// Location is the approximate location of an IP address
+type Location struct {
+	// Country is an ISO 3166-1 alpha-2 code where the lookup provides one
+	Country   string
+	City      string
+	Latitude  float64
+	Longitude float64
+}
+
+// Locator resolves IP addresses to locations
+type Locator interface {
+	// Locate returns the location of an IP address, or nil if it has none,
+	// as for private and loopback addresses
+	Locate(ctx context.Context, ip string) (*Location, error)
+}
+
+// HTTPLocator looks addresses up with a JSON geolocation API. Its URL
+// contains "{ip}", which is replaced with the address, e.g.
+// "https://ipapi.co/{ip}/json/". The response must have latitude and
+// longitude members; country_code (or country) and city are used if present.
+type HTTPLocator struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPLocator creates a locator querying the API at urlTemplate
+func NewHTTPLocator(urlTemplate string, timeout time.Duration) *HTTPLocator {
+	return &HTTPLocator{
+		url:    urlTemplate,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// lookupResponse is the part of a geolocation API response that is used
+type lookupResponse struct {
+	Latitude    *float64 `json:"latitude"`
+	Longitude   *float64 `json:"longitude"`
+	CountryCode string   `json:"country_code"`
+	Country     string   `json:"country"`
+	City        string   `json:"city"`
+}
+
+// Locate looks up the location of an IP address
+func (l *HTTPLocator) Locate(ctx context.Context, ip string) (*Location, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil || !Public(addr) {
+		return nil, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(l.url, "{ip}", url.PathEscape(addr.String())), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("geolocation lookup returned %s", resp.Status)
+	}
+
+	var body lookupResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid geolocation response: %v", err)
+	}
+	if body.Latitude == nil || body.Longitude == nil {
+		return nil, nil
+	}
+
+	country := body.CountryCode
+	if country == "" {
+		country = body.Country
+	}
+	return &Location{
+		Country:   country,
+		City:      body.City,
+		Latitude:  *body.Latitude,
+		Longitude: *body.Longitude,
+	}, nil
+}
+
+// Public reports whether an address is routable on the internet, and so can
+// have a location
+func Public(addr net.IP) bool {
+	return !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsUnspecified() &&
+		!addr.IsLinkLocalUnicast() && !addr.IsMulticast()
+}
+
+// DistanceKm returns the great-circle distance between two locations in
+// kilometres
+func DistanceKm(a, b Location) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
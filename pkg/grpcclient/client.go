@@ -0,0 +1,325 @@
+package grpcclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	// Registers the gzip compressor used by Compressor
+	_ "google.golang.org/grpc/encoding/gzip"
+	// Registers the client-side health checking used by HealthCheck
+	_ "google.golang.org/grpc/health"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/protobuf/proto"
+
+	"microservices-platform/pkg/discovery"
+	"microservices-platform/pkg/metrics"
+	"microservices-platform/pkg/middleware"
+)
+
+// Config holds the connection policy shared by inter-service gRPC clients.
+//
+// Clients ping idle connections every KeepaliveTime and drop them when a ping
+// is not answered within KeepaliveTimeout, so a connection broken by a network
+// blip is detected instead of hanging. Calls wait for a ready connection
+// while the client reconnects with exponential backoff, bounded by
+// CallTimeout when the caller set no deadline.
+//
+// Targets without a scheme are resolved with Resolver. The "dns" resolver
+// re-resolves whenever a connection is lost, so with a headless Kubernetes
+// Service the client follows pods as they are replaced, and
+// LoadBalancingPolicy spreads calls over every address. With HealthCheck,
+// addresses whose server reports NOT_SERVING, e.g. while draining, are skipped.
+// With Resolver "discovery" the addresses come from Discovery's registry and
+// are updated as soon as it changes.
+//
+// Requests to CompressMethods of at least CompressMinBytes are compressed with
+// Compressor, e.g. the ID lists of batch lookups. Messages larger than the
+// max sizes fail the call with RESOURCE_EXHAUSTED.
+//
+// Calls of connections made with Dial are recorded in the dependency metrics,
+// labelled with Service as the caller and the target's host as the
+// dependency.
+type Config struct {
+	Service string
+
+	KeepaliveTime     time.Duration
+	KeepaliveTimeout  time.Duration
+	MinConnectTimeout time.Duration
+	BackoffBaseDelay  time.Duration
+	BackoffMaxDelay   time.Duration
+	BackoffMultiplier float64
+	BackoffJitter     float64
+	WaitForReady      bool
+	CallTimeout       time.Duration
+
+	Resolver            string
+	LoadBalancingPolicy string
+	HealthCheck         bool
+	Discovery           discovery.Config
+
+	MaxRecvMsgSize   int
+	MaxSendMsgSize   int
+	Compressor       string
+	CompressMethods  []string
+	CompressMinBytes int
+}
+
+// DefaultCompressMethods are the calls whose messages can grow large: exports,
+// list and search results and batch lookups
+var DefaultCompressMethods = []string{"Export*", "List*", "Search*", "Batch*"}
+
+// LoadConfig loads the gRPC client policy from environment variables
+func LoadConfig() Config {
+	return Config{
+		Service: getEnv("SERVICE_NAME", ""),
+
+		KeepaliveTime:     getDurationEnv("GRPC_KEEPALIVE_TIME", 30*time.Second),
+		KeepaliveTimeout:  getDurationEnv("GRPC_KEEPALIVE_TIMEOUT", 10*time.Second),
+		MinConnectTimeout: getDurationEnv("GRPC_MIN_CONNECT_TIMEOUT", 5*time.Second),
+		BackoffBaseDelay:  getDurationEnv("GRPC_BACKOFF_BASE_DELAY", time.Second),
+		BackoffMaxDelay:   getDurationEnv("GRPC_BACKOFF_MAX_DELAY", 30*time.Second),
+		BackoffMultiplier: getFloatEnv("GRPC_BACKOFF_MULTIPLIER", 1.6),
+		BackoffJitter:     getFloatEnv("GRPC_BACKOFF_JITTER", 0.2),
+		WaitForReady:      getBoolEnv("GRPC_WAIT_FOR_READY", true),
+		CallTimeout:       getDurationEnv("GRPC_CALL_TIMEOUT", 10*time.Second),
+
+		Resolver:            getEnv("GRPC_RESOLVER", "dns"),
+		LoadBalancingPolicy: getEnv("GRPC_LB_POLICY", "round_robin"),
+		HealthCheck:         getBoolEnv("GRPC_CLIENT_HEALTH_CHECK", true),
+		Discovery:           discovery.LoadConfig(),
+
+		MaxRecvMsgSize:   getIntEnv("GRPC_MAX_RECV_MSG_SIZE", 16*1024*1024),
+		MaxSendMsgSize:   getIntEnv("GRPC_MAX_SEND_MSG_SIZE", 16*1024*1024),
+		Compressor:       getEnv("GRPC_COMPRESSOR", "gzip"),
+		CompressMethods:  getStringSliceEnv("GRPC_COMPRESS_METHODS", DefaultCompressMethods),
+		CompressMinBytes: getIntEnv("GRPC_COMPRESS_MIN_BYTES", 1024),
+	}
+}
+
+// Dial creates a client connection to another service with the shared
+// connection policy. Like grpc.Dial it does not wait for the connection.
+func Dial(target string, cfg Config, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	dialOpts := append(DialOptions(cfg),
+		grpc.WithStatsHandler(metrics.NewGRPCDependencyStatsHandler(cfg.Service, DependencyName(target), IsDependencyError)))
+	if cfg.Resolver == discovery.Scheme {
+		registry, err := discovery.New(cfg.Discovery)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up service discovery: %v", err)
+		}
+		dialOpts = append(dialOpts, grpc.WithResolvers(discovery.GRPCResolver(registry)))
+	}
+	return grpc.Dial(Target(target, cfg), append(dialOpts, opts...)...)
+}
+
+// DependencyName names the service at a target in metrics: its host, e.g.
+// "user-service" for "dns:///user-service:8081"
+func DependencyName(target string) string {
+	if strings.HasPrefix(target, "unix:") {
+		return target
+	}
+	if i := strings.Index(target, ":///"); i >= 0 {
+		target = target[i+len(":///"):]
+	}
+	if host, _, err := net.SplitHostPort(target); err == nil {
+		return host
+	}
+	return target
+}
+
+// IsDependencyError reports whether a call failing with code failed because
+// of the service called, rather than because of the request, so that the
+// dependency error rate shows a degrading dependency
+func IsDependencyError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal,
+		codes.Unavailable, codes.DataLoss, codes.Unimplemented:
+		return true
+	}
+	return false
+}
+
+// Target prefixes an address without a scheme, e.g. "user-service:8081",
+// with the configured resolver
+func Target(address string, cfg Config) string {
+	if cfg.Resolver == "" || strings.Contains(address, ":///") || strings.HasPrefix(address, "unix:") {
+		return address
+	}
+	return cfg.Resolver + ":///" + address
+}
+
+// DialOptions returns the dial options implementing the connection policy
+func DialOptions(cfg Config) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                cfg.KeepaliveTime,
+			Timeout:             cfg.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  cfg.BackoffBaseDelay,
+				Multiplier: cfg.BackoffMultiplier,
+				Jitter:     cfg.BackoffJitter,
+				MaxDelay:   cfg.BackoffMaxDelay,
+			},
+			MinConnectTimeout: cfg.MinConnectTimeout,
+		}),
+		grpc.WithDefaultServiceConfig(serviceConfig(cfg)),
+		grpc.WithDefaultCallOptions(
+			grpc.WaitForReady(cfg.WaitForReady),
+			grpc.MaxCallRecvMsgSize(cfg.MaxRecvMsgSize),
+			grpc.MaxCallSendMsgSize(cfg.MaxSendMsgSize),
+		),
+		grpc.WithChainUnaryInterceptor(
+			// Continue the caller's trace and pass its baggage on
+			otelgrpc.UnaryClientInterceptor(),
+			callTimeoutInterceptor(cfg.CallTimeout),
+			compressionInterceptor(cfg),
+			// Call on behalf of the user the call being served is for
+			middleware.ForwardIdentityUnaryInterceptor(),
+		),
+		grpc.WithChainStreamInterceptor(otelgrpc.StreamClientInterceptor()),
+		grpc.WithStatsHandler(metrics.NewGRPCMessageStatsHandler("client")),
+	}
+}
+
+// MatchesMethod reports whether a full method name, e.g.
+// "/user.v1.UserService/ExportUserData", matches one of the patterns. A
+// pattern is a full method name, a method name, or a method name prefix ending
+// in "*". "*" matches every method.
+func MatchesMethod(patterns []string, fullMethod string) bool {
+	name := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+			continue
+		}
+		if pattern == fullMethod || pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceConfig returns the default service config selecting the load
+// balancing policy and health checking
+func serviceConfig(cfg Config) string {
+	policy := cfg.LoadBalancingPolicy
+	if policy == "" {
+		policy = "pick_first"
+	}
+	if !cfg.HealthCheck {
+		return fmt.Sprintf(`{"loadBalancingConfig":[{%q:{}}]}`, policy)
+	}
+	return fmt.Sprintf(`{"loadBalancingConfig":[{%q:{}}],"healthCheckConfig":{"serviceName":""}}`, policy)
+}
+
+// KeepaliveEnforcementPolicy returns the server option that accepts the
+// keepalive pings of clients using Config. Without it servers answer pings
+// more frequent than every 5 minutes by closing the connection.
+func KeepaliveEnforcementPolicy(cfg Config) grpc.ServerOption {
+	minTime := cfg.KeepaliveTime / 2
+	if minTime <= 0 {
+		minTime = 10 * time.Second
+	}
+	return grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+		MinTime:             minTime,
+		PermitWithoutStream: true,
+	})
+}
+
+// callTimeoutInterceptor bounds calls made without a deadline, since a call
+// waiting for a ready connection would otherwise wait forever
+func callTimeoutInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// compressionInterceptor compresses large requests to the configured methods.
+// Servers compress their responses independently.
+func compressionInterceptor(cfg Config) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if cfg.Compressor != "" && MatchesMethod(cfg.CompressMethods, method) {
+			if msg, ok := req.(proto.Message); ok && proto.Size(msg) >= cfg.CompressMinBytes {
+				opts = append(opts, grpc.UseCompressor(cfg.Compressor))
+			}
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getIntEnv(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getStringSliceEnv(key string, fallback []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+func getFloatEnv(key string, fallback float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getBoolEnv(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,65 @@
+package grpcserver
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"microservices-platform/pkg/buildinfo"
+)
+
+// BuildInfoMethod returns the service's build info, as served on /version,
+// in a google.protobuf.Struct. It takes a google.protobuf.Empty.
+const BuildInfoMethod = "/platform.v1.BuildInfoService/BuildInfo"
+
+// buildInfoServer is the handler type of the build info service, which is
+// described by hand since it only uses well-known types
+type buildInfoServer interface {
+	BuildInfo(ctx context.Context, req *emptypb.Empty) (*structpb.Struct, error)
+}
+
+type buildInfoService struct {
+	service string
+}
+
+// BuildInfo implements buildInfoServer
+func (s *buildInfoService) BuildInfo(ctx context.Context, req *emptypb.Empty) (*structpb.Struct, error) {
+	info := buildinfo.Get(s.service)
+	return structpb.NewStruct(map[string]interface{}{
+		"service":    info.Service,
+		"version":    info.Version,
+		"commit":     info.Commit,
+		"date":       info.Date,
+		"go_version": info.GoVersion,
+	})
+}
+
+var buildInfoServiceDesc = grpc.ServiceDesc{
+	ServiceName: "platform.v1.BuildInfoService",
+	HandlerType: (*buildInfoServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "BuildInfo", Handler: buildInfoHandler},
+	},
+}
+
+func buildInfoHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(emptypb.Empty)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(buildInfoServer).BuildInfo(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: BuildInfoMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(buildInfoServer).BuildInfo(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+// RegisterBuildInfo registers the build info service for service
+func RegisterBuildInfo(server *grpc.Server, service string) {
+	server.RegisterService(&buildInfoServiceDesc, &buildInfoService{service: service})
+}
@@ -0,0 +1,212 @@
+package grpcserver
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	// Registers the gzip compressor used by Compressor
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/protobuf/proto"
+
+	"microservices-platform/pkg/grpcclient"
+	"microservices-platform/pkg/metrics"
+)
+
+// Config holds gRPC server connection lifetime and shutdown settings.
+//
+// Connections are closed with a GOAWAY after MaxConnectionAge, giving
+// in-flight calls MaxConnectionAgeGrace to finish, so clients reconnect
+// regularly and spread over new instances. On shutdown the service reports
+// not ready for DrainDelay before it stops accepting new calls, and waits up
+// to ShutdownTimeout for in-flight calls.
+//
+// Responses of CompressMethods of at least CompressMinBytes are compressed
+// with Compressor when the client accepts it. Streamed responses of those
+// methods are always compressed.
+type Config struct {
+	MaxConnectionIdle     time.Duration
+	MaxConnectionAge      time.Duration
+	MaxConnectionAgeGrace time.Duration
+	DrainDelay            time.Duration
+	ShutdownTimeout       time.Duration
+
+	MaxRecvMsgSize   int
+	MaxSendMsgSize   int
+	Compressor       string
+	CompressMethods  []string
+	CompressMinBytes int
+}
+
+// LoadConfig loads gRPC server settings from environment variables
+func LoadConfig() Config {
+	return Config{
+		MaxConnectionIdle:     getDurationEnv("GRPC_MAX_CONNECTION_IDLE", 5*time.Minute),
+		MaxConnectionAge:      getDurationEnv("GRPC_MAX_CONNECTION_AGE", 5*time.Minute),
+		MaxConnectionAgeGrace: getDurationEnv("GRPC_MAX_CONNECTION_AGE_GRACE", 30*time.Second),
+		DrainDelay:            getDurationEnv("GRPC_DRAIN_DELAY", 5*time.Second),
+		ShutdownTimeout:       getDurationEnv("GRPC_SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		MaxRecvMsgSize:   getIntEnv("GRPC_MAX_RECV_MSG_SIZE", 16*1024*1024),
+		MaxSendMsgSize:   getIntEnv("GRPC_MAX_SEND_MSG_SIZE", 16*1024*1024),
+		Compressor:       getEnv("GRPC_COMPRESSOR", "gzip"),
+		CompressMethods:  getStringSliceEnv("GRPC_COMPRESS_METHODS", grpcclient.DefaultCompressMethods),
+		CompressMinBytes: getIntEnv("GRPC_COMPRESS_MIN_BYTES", 1024),
+	}
+}
+
+// KeepaliveParams returns the server option applying the connection lifetimes
+func KeepaliveParams(cfg Config) grpc.ServerOption {
+	return grpc.KeepaliveParams(keepalive.ServerParameters{
+		MaxConnectionIdle:     cfg.MaxConnectionIdle,
+		MaxConnectionAge:      cfg.MaxConnectionAge,
+		MaxConnectionAgeGrace: cfg.MaxConnectionAgeGrace,
+	})
+}
+
+// MessageOptions returns the server options applying the message size limits
+// and response compression, and recording message sizes
+func MessageOptions(cfg Config) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize),
+		grpc.MaxSendMsgSize(cfg.MaxSendMsgSize),
+		grpc.ChainUnaryInterceptor(compressionUnaryInterceptor(cfg)),
+		grpc.ChainStreamInterceptor(compressionStreamInterceptor(cfg)),
+		grpc.StatsHandler(metrics.NewGRPCMessageStatsHandler("server")),
+	}
+}
+
+// compressionUnaryInterceptor compresses large responses of the configured
+// methods. The response size is only known once the handler returned, which
+// is still before the response headers are sent.
+func compressionUnaryInterceptor(cfg Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil || cfg.Compressor == "" || !grpcclient.MatchesMethod(cfg.CompressMethods, info.FullMethod) {
+			return resp, err
+		}
+		if msg, ok := resp.(proto.Message); ok && proto.Size(msg) >= cfg.CompressMinBytes {
+			setSendCompressor(ctx, cfg.Compressor)
+		}
+		return resp, err
+	}
+}
+
+// compressionStreamInterceptor compresses the responses of configured
+// streaming methods
+func compressionStreamInterceptor(cfg Config) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if cfg.Compressor != "" && grpcclient.MatchesMethod(cfg.CompressMethods, info.FullMethod) {
+			setSendCompressor(stream.Context(), cfg.Compressor)
+		}
+		return handler(srv, stream)
+	}
+}
+
+// setSendCompressor compresses the call's responses if the client accepts the
+// compressor
+func setSendCompressor(ctx context.Context, name string) {
+	accepted, err := grpc.ClientSupportedCompressors(ctx)
+	if err != nil {
+		return
+	}
+	for _, compressor := range accepted {
+		if strings.TrimSpace(compressor) == name {
+			if err := grpc.SetSendCompressor(ctx, name); err != nil {
+				log.Printf("Failed to compress gRPC response with %s: %v", name, err)
+			}
+			return
+		}
+	}
+}
+
+// RegisterHealth registers the standard gRPC health service, reporting the
+// server as serving. Clients with health checking enabled stop sending calls
+// to the server once it reports NOT_SERVING.
+func RegisterHealth(server *grpc.Server) *health.Server {
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	return healthServer
+}
+
+// Drain shuts a server down without failing calls. It waits DrainDelay so
+// load balancers and clients notice the service reporting not ready, then
+// sends GOAWAY, stops accepting new calls and waits for in-flight calls. Calls
+// still running after ShutdownTimeout are cancelled.
+//
+// Mark the service not ready, and shut its health server down, before calling
+// Drain.
+func Drain(server *grpc.Server, cfg Config) {
+	if cfg.DrainDelay > 0 {
+		log.Printf("Draining gRPC server for %s", cfg.DrainDelay)
+		time.Sleep(cfg.DrainDelay)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	if cfg.ShutdownTimeout <= 0 {
+		<-stopped
+		return
+	}
+
+	timer := time.NewTimer(cfg.ShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		log.Printf("gRPC calls still running after %s, stopping server", cfg.ShutdownTimeout)
+		server.Stop()
+		<-stopped
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
+
+func getIntEnv(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getStringSliceEnv(key string, fallback []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
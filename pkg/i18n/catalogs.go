@@ -0,0 +1,240 @@
+package i18n
+
+// catalogs holds the translations of messages by language. A message without
+// a translation is returned in English.
+var catalogs = map[string]map[string]string{
+	"de": {
+		// Gateway
+		"Action is no longer pending":                                        "Die Aktion steht nicht mehr aus",
+		"Action not found":                                                   "Aktion nicht gefunden",
+		"Actions must be approved by a different admin":                      "Aktionen müssen von einem anderen Administrator genehmigt werden",
+		"Approval queue is unavailable":                                      "Die Genehmigungswarteschlange ist nicht verfügbar",
+		"Authorization header required":                                      "Authorization-Header erforderlich",
+		"Bad gateway":                                                        "Fehlerhaftes Gateway",
+		"Event ingest is unavailable":                                        "Die Ereignisaufnahme ist nicht verfügbar",
+		"Event not found":                                                    "Ereignis nicht gefunden",
+		"Event replay is unavailable":                                        "Die Ereigniswiedergabe ist nicht verfügbar",
+		"Event store is unavailable":                                         "Der Ereignisspeicher ist nicht verfügbar",
+		"Event type is not accepted":                                         "Der Ereignistyp wird nicht akzeptiert",
+		"Failed to publish event":                                            "Das Ereignis konnte nicht veröffentlicht werden",
+		"Failed to read request body":                                        "Der Anfragetext konnte nicht gelesen werden",
+		"Failed to replay event":                                             "Das Ereignis konnte nicht wiedergegeben werden",
+		"Gateway timeout":                                                    "Zeitüberschreitung am Gateway",
+		"Insufficient permissions":                                           "Unzureichende Berechtigungen",
+		"Invalid action request":                                             "Ungültige Aktionsanfrage",
+		"Invalid batch request":                                              "Ungültige Sammelanfrage",
+		"Invalid caller identity":                                            "Ungültige Aufruferidentität",
+		"Invalid cursor":                                                     "Ungültiger Cursor",
+		"Invalid merge patch":                                                "Ungültiger Merge-Patch",
+		"Invalid request body":                                               "Ungültiger Anfragetext",
+		"Invalid token":                                                      "Ungültiges Token",
+		"Not allowed while impersonating a user":                             "Nicht erlaubt, während Sie als ein anderer Benutzer handeln",
+		"Not found":                                                          "Nicht gefunden",
+		"Operation not found":                                                "Vorgang nicht gefunden",
+		"Operation status is unavailable":                                    "Der Vorgangsstatus ist nicht verfügbar",
+		"Plan information is unavailable":                                    "Tarifinformationen sind nicht verfügbar",
+		"Rate limit exceeded":                                                "Anfragelimit überschritten",
+		"Request body too large":                                             "Anfragetext zu groß",
+		"Requests that need approval must have a JSON body":                  "Genehmigungspflichtige Anfragen benötigen einen JSON-Text",
+		"Requests that need review must have a JSON body":                    "Prüfpflichtige Anfragen benötigen einen JSON-Text",
+		"Review item is already resolved":                                    "Der Prüfeintrag ist bereits erledigt",
+		"Review item is assigned to another admin":                           "Der Prüfeintrag ist einem anderen Administrator zugewiesen",
+		"Review item changed, try again":                                     "Der Prüfeintrag wurde geändert, bitte erneut versuchen",
+		"Review item not found":                                              "Prüfeintrag nicht gefunden",
+		"Review queue is unavailable":                                        "Die Prüfwarteschlange ist nicht verfügbar",
+		"Route latency tracking is disabled":                                 "Die Latenzmessung der Routen ist deaktiviert",
+		"Service not found":                                                  "Dienst nicht gefunden",
+		"Service temporarily unavailable":                                    "Dienst vorübergehend nicht verfügbar",
+		"This feature is not available to you yet":                           "Diese Funktion ist für Sie noch nicht verfügbar",
+		"This request is waiting for manual review":                          "Diese Anfrage wartet auf eine manuelle Prüfung",
+		"This request needs a second admin's approval before it runs":        "Diese Anfrage muss vor der Ausführung von einem zweiten Administrator genehmigt werden",
+		"This request needs a second admin's approval, which is unavailable": "Diese Anfrage benötigt die Genehmigung eines zweiten Administrators, die nicht verfügbar ist",
+		"This request needs manual review, which is unavailable":             "Diese Anfrage benötigt eine manuelle Prüfung, die nicht verfügbar ist",
+		"Token expired":                                                      "Das Token ist abgelaufen",
+		"Usage information is unavailable":                                   "Nutzungsinformationen sind nicht verfügbar",
+		"Your plan does not include this feature":                            "Ihr Tarif umfasst diese Funktion nicht",
+		"from must be an RFC 3339 timestamp":                                 "from muss ein RFC-3339-Zeitstempel sein",
+		"hours must be between 1 and 168":                                    "hours muss zwischen 1 und 168 liegen",
+		"invalid or missing token":                                           "Ungültiges oder fehlendes Token",
+		"invalid request body":                                               "Ungültiger Anfragetext",
+		"limit must be between 1 and 500":                                    "limit muss zwischen 1 und 500 liegen",
+		"method not allowed":                                                 "Methode nicht erlaubt",
+		"resolution is required":                                             "resolution ist erforderlich",
+		"resolution must be approve or reject":                               "resolution muss approve oder reject sein",
+		"the patch changes no fields":                                        "Der Patch ändert keine Felder",
+		"the patch must be a JSON object":                                    "Der Patch muss ein JSON-Objekt sein",
+		"to must be an RFC 3339 timestamp":                                   "to muss ein RFC-3339-Zeitstempel sein",
+
+		// Password policy
+		"password does not meet the policy: %s":              "Das Passwort erfüllt die Richtlinie nicht: %s",
+		"must be at least %d characters long":                "muss mindestens %d Zeichen lang sein",
+		"must be at most %d characters long":                 "darf höchstens %d Zeichen lang sein",
+		"must contain an uppercase letter":                   "muss einen Großbuchstaben enthalten",
+		"must contain a lowercase letter":                    "muss einen Kleinbuchstaben enthalten",
+		"must contain a digit":                               "muss eine Ziffer enthalten",
+		"must contain a symbol":                              "muss ein Sonderzeichen enthalten",
+		"is too common":                                      "ist zu verbreitet",
+		"must not contain your email address or username":    "darf weder Ihre E-Mail-Adresse noch Ihren Benutzernamen enthalten",
+		"has appeared in a data breach and must not be used": "ist in einem Datenleck aufgetaucht und darf nicht verwendet werden",
+
+		// Cart reminders
+		"You left something in your cart":                    "Sie haben etwas in Ihrem Warenkorb vergessen",
+		"You have %d item(s) waiting in your cart since %s.": "Seit %[2]s warten %[1]d Artikel in Ihrem Warenkorb.",
+	},
+	"es": {
+		// Gateway
+		"Action is no longer pending":                                        "La acción ya no está pendiente",
+		"Action not found":                                                   "Acción no encontrada",
+		"Actions must be approved by a different admin":                      "Las acciones deben ser aprobadas por otro administrador",
+		"Approval queue is unavailable":                                      "La cola de aprobaciones no está disponible",
+		"Authorization header required":                                      "Se requiere la cabecera Authorization",
+		"Bad gateway":                                                        "Puerta de enlace incorrecta",
+		"Event ingest is unavailable":                                        "La ingesta de eventos no está disponible",
+		"Event not found":                                                    "Evento no encontrado",
+		"Event replay is unavailable":                                        "La reproducción de eventos no está disponible",
+		"Event store is unavailable":                                         "El almacén de eventos no está disponible",
+		"Event type is not accepted":                                         "El tipo de evento no se acepta",
+		"Failed to publish event":                                            "No se pudo publicar el evento",
+		"Failed to read request body":                                        "No se pudo leer el cuerpo de la solicitud",
+		"Failed to replay event":                                             "No se pudo reproducir el evento",
+		"Gateway timeout":                                                    "Tiempo de espera agotado en la puerta de enlace",
+		"Insufficient permissions":                                           "Permisos insuficientes",
+		"Invalid action request":                                             "Solicitud de acción no válida",
+		"Invalid batch request":                                              "Solicitud por lotes no válida",
+		"Invalid caller identity":                                            "Identidad del llamante no válida",
+		"Invalid cursor":                                                     "Cursor no válido",
+		"Invalid merge patch":                                                "Merge patch no válido",
+		"Invalid request body":                                               "Cuerpo de la solicitud no válido",
+		"Invalid token":                                                      "Token no válido",
+		"Not allowed while impersonating a user":                             "No permitido mientras suplanta a un usuario",
+		"Not found":                                                          "No encontrado",
+		"Operation not found":                                                "Operación no encontrada",
+		"Operation status is unavailable":                                    "El estado de la operación no está disponible",
+		"Plan information is unavailable":                                    "La información del plan no está disponible",
+		"Rate limit exceeded":                                                "Límite de solicitudes superado",
+		"Request body too large":                                             "Cuerpo de la solicitud demasiado grande",
+		"Requests that need approval must have a JSON body":                  "Las solicitudes que requieren aprobación deben tener un cuerpo JSON",
+		"Requests that need review must have a JSON body":                    "Las solicitudes que requieren revisión deben tener un cuerpo JSON",
+		"Review item is already resolved":                                    "El elemento de revisión ya está resuelto",
+		"Review item is assigned to another admin":                           "El elemento de revisión está asignado a otro administrador",
+		"Review item changed, try again":                                     "El elemento de revisión ha cambiado, inténtelo de nuevo",
+		"Review item not found":                                              "Elemento de revisión no encontrado",
+		"Review queue is unavailable":                                        "La cola de revisión no está disponible",
+		"Route latency tracking is disabled":                                 "El seguimiento de latencia de rutas está desactivado",
+		"Service not found":                                                  "Servicio no encontrado",
+		"Service temporarily unavailable":                                    "Servicio no disponible temporalmente",
+		"This feature is not available to you yet":                           "Esta función aún no está disponible para usted",
+		"This request is waiting for manual review":                          "Esta solicitud está pendiente de revisión manual",
+		"This request needs a second admin's approval before it runs":        "Esta solicitud necesita la aprobación de un segundo administrador antes de ejecutarse",
+		"This request needs a second admin's approval, which is unavailable": "Esta solicitud necesita la aprobación de un segundo administrador, que no está disponible",
+		"This request needs manual review, which is unavailable":             "Esta solicitud necesita revisión manual, que no está disponible",
+		"Token expired":                                                      "El token ha caducado",
+		"Usage information is unavailable":                                   "La información de uso no está disponible",
+		"Your plan does not include this feature":                            "Su plan no incluye esta función",
+		"from must be an RFC 3339 timestamp":                                 "from debe ser una marca de tiempo RFC 3339",
+		"hours must be between 1 and 168":                                    "hours debe estar entre 1 y 168",
+		"invalid or missing token":                                           "Token no válido o ausente",
+		"invalid request body":                                               "Cuerpo de la solicitud no válido",
+		"limit must be between 1 and 500":                                    "limit debe estar entre 1 y 500",
+		"method not allowed":                                                 "Método no permitido",
+		"resolution is required":                                             "resolution es obligatorio",
+		"resolution must be approve or reject":                               "resolution debe ser approve o reject",
+		"the patch changes no fields":                                        "El patch no cambia ningún campo",
+		"the patch must be a JSON object":                                    "El patch debe ser un objeto JSON",
+		"to must be an RFC 3339 timestamp":                                   "to debe ser una marca de tiempo RFC 3339",
+
+		// Password policy
+		"password does not meet the policy: %s":              "La contraseña no cumple la política: %s",
+		"must be at least %d characters long":                "debe tener al menos %d caracteres",
+		"must be at most %d characters long":                 "debe tener como máximo %d caracteres",
+		"must contain an uppercase letter":                   "debe contener una letra mayúscula",
+		"must contain a lowercase letter":                    "debe contener una letra minúscula",
+		"must contain a digit":                               "debe contener un dígito",
+		"must contain a symbol":                              "debe contener un símbolo",
+		"is too common":                                      "es demasiado común",
+		"must not contain your email address or username":    "no debe contener su correo electrónico ni su nombre de usuario",
+		"has appeared in a data breach and must not be used": "ha aparecido en una filtración de datos y no debe usarse",
+
+		// Cart reminders
+		"You left something in your cart":                    "Olvidó algo en su carrito",
+		"You have %d item(s) waiting in your cart since %s.": "Tiene %d artículo(s) esperando en su carrito desde el %s.",
+	},
+	"fr": {
+		// Gateway
+		"Action is no longer pending":                                        "L'action n'est plus en attente",
+		"Action not found":                                                   "Action introuvable",
+		"Actions must be approved by a different admin":                      "Les actions doivent être approuvées par un autre administrateur",
+		"Approval queue is unavailable":                                      "La file d'approbation est indisponible",
+		"Authorization header required":                                      "En-tête Authorization requis",
+		"Bad gateway":                                                        "Passerelle incorrecte",
+		"Event ingest is unavailable":                                        "L'ingestion d'événements est indisponible",
+		"Event not found":                                                    "Événement introuvable",
+		"Event replay is unavailable":                                        "La relecture d'événements est indisponible",
+		"Event store is unavailable":                                         "Le magasin d'événements est indisponible",
+		"Event type is not accepted":                                         "Ce type d'événement n'est pas accepté",
+		"Failed to publish event":                                            "Échec de la publication de l'événement",
+		"Failed to read request body":                                        "Impossible de lire le corps de la requête",
+		"Failed to replay event":                                             "Échec de la relecture de l'événement",
+		"Gateway timeout":                                                    "Délai d'attente de la passerelle dépassé",
+		"Insufficient permissions":                                           "Autorisations insuffisantes",
+		"Invalid action request":                                             "Demande d'action invalide",
+		"Invalid batch request":                                              "Requête groupée invalide",
+		"Invalid caller identity":                                            "Identité de l'appelant invalide",
+		"Invalid cursor":                                                     "Curseur invalide",
+		"Invalid merge patch":                                                "Merge patch invalide",
+		"Invalid request body":                                               "Corps de requête invalide",
+		"Invalid token":                                                      "Jeton invalide",
+		"Not allowed while impersonating a user":                             "Interdit pendant l'usurpation d'un utilisateur",
+		"Not found":                                                          "Introuvable",
+		"Operation not found":                                                "Opération introuvable",
+		"Operation status is unavailable":                                    "Le statut de l'opération est indisponible",
+		"Plan information is unavailable":                                    "Les informations de forfait sont indisponibles",
+		"Rate limit exceeded":                                                "Limite de requêtes dépassée",
+		"Request body too large":                                             "Corps de requête trop volumineux",
+		"Requests that need approval must have a JSON body":                  "Les requêtes soumises à approbation doivent avoir un corps JSON",
+		"Requests that need review must have a JSON body":                    "Les requêtes soumises à vérification doivent avoir un corps JSON",
+		"Review item is already resolved":                                    "L'élément à vérifier est déjà traité",
+		"Review item is assigned to another admin":                           "L'élément à vérifier est attribué à un autre administrateur",
+		"Review item changed, try again":                                     "L'élément à vérifier a changé, réessayez",
+		"Review item not found":                                              "Élément à vérifier introuvable",
+		"Review queue is unavailable":                                        "La file de vérification est indisponible",
+		"Route latency tracking is disabled":                                 "Le suivi de latence des routes est désactivé",
+		"Service not found":                                                  "Service introuvable",
+		"Service temporarily unavailable":                                    "Service temporairement indisponible",
+		"This feature is not available to you yet":                           "Cette fonctionnalité n'est pas encore disponible pour vous",
+		"This request is waiting for manual review":                          "Cette requête attend une vérification manuelle",
+		"This request needs a second admin's approval before it runs":        "Cette requête doit être approuvée par un second administrateur avant d'être exécutée",
+		"This request needs a second admin's approval, which is unavailable": "Cette requête doit être approuvée par un second administrateur, ce qui est indisponible",
+		"This request needs manual review, which is unavailable":             "Cette requête nécessite une vérification manuelle, qui est indisponible",
+		"Token expired":                                                      "Le jeton a expiré",
+		"Usage information is unavailable":                                   "Les informations d'utilisation sont indisponibles",
+		"Your plan does not include this feature":                            "Votre forfait n'inclut pas cette fonctionnalité",
+		"from must be an RFC 3339 timestamp":                                 "from doit être un horodatage RFC 3339",
+		"hours must be between 1 and 168":                                    "hours doit être compris entre 1 et 168",
+		"invalid or missing token":                                           "Jeton invalide ou manquant",
+		"invalid request body":                                               "Corps de requête invalide",
+		"limit must be between 1 and 500":                                    "limit doit être compris entre 1 et 500",
+		"method not allowed":                                                 "Méthode non autorisée",
+		"resolution is required":                                             "resolution est obligatoire",
+		"resolution must be approve or reject":                               "resolution doit valoir approve ou reject",
+		"the patch changes no fields":                                        "Le patch ne modifie aucun champ",
+		"the patch must be a JSON object":                                    "Le patch doit être un objet JSON",
+		"to must be an RFC 3339 timestamp":                                   "to doit être un horodatage RFC 3339",
+
+		// Password policy
+		"password does not meet the policy: %s":              "Le mot de passe ne respecte pas la politique : %s",
+		"must be at least %d characters long":                "doit contenir au moins %d caractères",
+		"must be at most %d characters long":                 "doit contenir au plus %d caractères",
+		"must contain an uppercase letter":                   "doit contenir une lettre majuscule",
+		"must contain a lowercase letter":                    "doit contenir une lettre minuscule",
+		"must contain a digit":                               "doit contenir un chiffre",
+		"must contain a symbol":                              "doit contenir un symbole",
+		"is too common":                                      "est trop courant",
+		"must not contain your email address or username":    "ne doit contenir ni votre adresse e-mail ni votre nom d'utilisateur",
+		"has appeared in a data breach and must not be used": "est apparu dans une fuite de données et ne doit pas être utilisé",
+
+		// Cart reminders
+		"You left something in your cart":                    "Vous avez oublié quelque chose dans votre panier",
+		"You have %d item(s) waiting in your cart since %s.": "Vous avez %d article(s) qui vous attendent dans votre panier depuis le %s.",
+	},
+}
@@ -0,0 +1,131 @@
+// Package i18n localizes the messages returned to users. Messages are keyed
+// by their English text, which is also what users get when their language has
+// no translation, so code keeps readable messages and a missing translation
+// degrades to English rather than to a key.
+//
+// The gateway picks a language from the Accept-Language header and passes it
+// on as the user.language trace baggage member, so services and the event
+// handlers acting on a request localize their messages with T.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/baggage"
+
+	"microservices-platform/pkg/instrumentation"
+)
+
+// DefaultLanguage is the language of the messages in code
+const DefaultLanguage = "en"
+
+// Supported returns the languages messages are available in,
+// DefaultLanguage first
+func Supported() []string {
+	languages := make([]string, 0, len(catalogs)+1)
+	languages = append(languages, DefaultLanguage)
+	for language := range catalogs {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages[1:])
+	return languages
+}
+
+// Negotiate returns the supported language a client prefers most in an
+// Accept-Language header, e.g. "fr-CH, fr;q=0.9, en;q=0.8". A regional tag
+// matches its language. Without a match it returns DefaultLanguage.
+func Negotiate(header string) string {
+	best, bestQuality := DefaultLanguage, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		language := supportedLanguage(tag)
+		if language != "" && quality > bestQuality {
+			best, bestQuality = language, quality
+		}
+	}
+	return best
+}
+
+// supportedLanguage returns the supported language of a language tag, or ""
+func supportedLanguage(tag string) string {
+	language, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	if language == DefaultLanguage {
+		return language
+	}
+	if _, ok := catalogs[language]; ok {
+		return language
+	}
+	return ""
+}
+
+// ValidLocale reports whether locale is shaped like a language tag, e.g.
+// "de" or "pt-BR". Any language is a valid preference; unsupported ones get
+// DefaultLanguage.
+func ValidLocale(locale string) bool {
+	for i, subtag := range strings.Split(locale, "-") {
+		if len(subtag) < 1 || len(subtag) > 8 || (i == 0 && (len(subtag) < 2 || len(subtag) > 3)) {
+			return false
+		}
+		for _, r := range subtag {
+			isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+			if !isLetter && (i == 0 || r < '0' || r > '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Lookup returns the translation of message into language, if it has one
+func Lookup(language, message string) (string, bool) {
+	translated, ok := catalogs[language][message]
+	return translated, ok
+}
+
+// Translate returns message in language, formatted with args like
+// fmt.Sprintf when there are any
+func Translate(language, message string, args ...interface{}) string {
+	if translated, ok := Lookup(language, message); ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// FromContext returns the language of the request ctx belongs to
+func FromContext(ctx context.Context) string {
+	value := baggage.FromContext(ctx).Member(instrumentation.BaggageLanguage).Value()
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		value = unescaped
+	}
+	if language := supportedLanguage(value); language != "" {
+		return language
+	}
+	return DefaultLanguage
+}
+
+// WithLanguage returns ctx for a request in language, e.g. to localize the
+// messages of work done on a user's behalf outside a request
+func WithLanguage(ctx context.Context, language string) context.Context {
+	return instrumentation.WithBusinessContext(ctx, instrumentation.BaggageLanguage, language)
+}
+
+// T returns message in the language of the request ctx belongs to
+func T(ctx context.Context, message string, args ...interface{}) string {
+	return Translate(FromContext(ctx), message, args...)
+}
@@ -0,0 +1,81 @@
+package i18n
+
+import (
+	"time"
+)
+
+// timeLayouts are how each language writes a date and time. Languages
+// without one use DefaultLanguage's.
+var timeLayouts = map[string]string{
+	DefaultLanguage: "Jan 2, 2006 3:04 PM MST",
+	"de":            "02.01.2006 15:04 MST",
+	"es":            "02/01/2006 15:04 MST",
+	"fr":            "02/01/2006 15:04 MST",
+}
+
+// ValidTimeZone reports whether name is an IANA time zone, e.g.
+// "Europe/Berlin". The empty name is valid and means UTC.
+func ValidTimeZone(name string) bool {
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// Location returns the time zone named name, or UTC for an empty or unknown
+// name, so a stale preference degrades to UTC rather than failing
+func Location(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// FormatTime formats t for a user, as language writes dates and in the time
+// zone named timeZone
+func FormatTime(t time.Time, language, timeZone string) string {
+	layout, ok := timeLayouts[supportedLanguage(language)]
+	if !ok {
+		layout = timeLayouts[DefaultLanguage]
+	}
+	return t.In(Location(timeZone)).Format(layout)
+}
+
+// NextLocalTime returns the first instant after t at which the wall clock in
+// loc reads hour:minute, e.g. when a daily digest is next due. Days are
+// counted on the wall clock, so across a daylight saving change the result is
+// 23 or 25 hours after the same time the day before. A wall clock time that
+// a change skips happens that day as late as the change pushes it, e.g. 2:30
+// becomes 3:30 on the day clocks go forward.
+func NextLocalTime(t time.Time, loc *time.Location, hour, minute int) time.Time {
+	local := t.In(loc)
+	next := localTime(local.Year(), local.Month(), local.Day(), hour, minute, loc)
+	for day := 1; !next.After(t); day++ {
+		next = localTime(local.Year(), local.Month(), local.Day()+day, hour, minute, loc)
+	}
+	return next
+}
+
+// localTime is time.Date, except that a wall clock time a daylight saving
+// change skips is moved past the change rather than possibly before it
+func localTime(year int, month time.Month, day, hour, minute int, loc *time.Location) time.Time {
+	t := time.Date(year, month, day, hour, minute, 0, 0, loc)
+	local := t.In(loc)
+	if skipped := time.Duration(hour-local.Hour())*time.Hour + time.Duration(minute-local.Minute())*time.Minute; skipped > 0 {
+		t = t.Add(skipped)
+	}
+	return t
+}
+
+// WithinLocalHours reports whether the wall clock in loc reads at least
+// start and before end o'clock at t. A window with end before start spans
+// midnight; one with end equal to start is the whole day.
+func WithinLocalHours(t time.Time, loc *time.Location, start, end int) bool {
+	hour := t.In(loc).Hour()
+	if start == end {
+		return true
+	}
+	if start < end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
@@ -0,0 +1,262 @@
+package incidents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"microservices-platform/pkg/events"
+)
+
+// Config holds the ops channel settings. Each channel is used if it is
+// configured; incidents go to all of them.
+type Config struct {
+	// SlackWebhookURL is a Slack incoming webhook
+	SlackWebhookURL string
+	// WebhookURL receives each incident report as JSON, e.g. for a paging
+	// service
+	WebhookURL string
+
+	EmailTo      []string
+	EmailFrom    string
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+
+	Timeout time.Duration
+}
+
+// LoadConfig loads the ops channel settings from environment variables
+func LoadConfig() Config {
+	var emailTo []string
+	for _, address := range strings.Split(os.Getenv("INCIDENT_EMAIL_TO"), ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			emailTo = append(emailTo, address)
+		}
+	}
+
+	return Config{
+		SlackWebhookURL: os.Getenv("INCIDENT_SLACK_WEBHOOK_URL"),
+		WebhookURL:      os.Getenv("INCIDENT_WEBHOOK_URL"),
+		EmailTo:         emailTo,
+		EmailFrom:       getEnv("INCIDENT_EMAIL_FROM", "incidents@localhost"),
+		SMTPAddr:        os.Getenv("SMTP_ADDR"),
+		SMTPUsername:    os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:    os.Getenv("SMTP_PASSWORD"),
+		Timeout:         getDurationEnv("INCIDENT_NOTIFY_TIMEOUT", 10*time.Second),
+	}
+}
+
+// Channels returns the configured channels
+func (c Config) Channels() []Channel {
+	client := &http.Client{Timeout: c.Timeout}
+	var channels []Channel
+	if c.SlackWebhookURL != "" {
+		channels = append(channels, &SlackChannel{URL: c.SlackWebhookURL, Client: client})
+	}
+	if c.WebhookURL != "" {
+		channels = append(channels, &WebhookChannel{URL: c.WebhookURL, Client: client})
+	}
+	if len(c.EmailTo) > 0 && c.SMTPAddr != "" {
+		channel := &EmailChannel{Addr: c.SMTPAddr, From: c.EmailFrom, To: c.EmailTo}
+		if c.SMTPUsername != "" {
+			host := c.SMTPAddr
+			if i := strings.LastIndex(host, ":"); i >= 0 {
+				host = host[:i]
+			}
+			channel.Auth = smtp.PlainAuth("", c.SMTPUsername, c.SMTPPassword, host)
+		}
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// Channel delivers incident reports to operators
+type Channel interface {
+	Send(ctx context.Context, report *events.IncidentReport) error
+	// Name names the channel in logs
+	Name() string
+}
+
+// Router delivers platform.incident events to every ops channel
+type Router struct {
+	channels []Channel
+}
+
+// NewRouter creates a new router to channels
+func NewRouter(channels ...Channel) *Router {
+	return &Router{channels: channels}
+}
+
+// Subscribe routes the incidents published on bus
+func (r *Router) Subscribe(bus events.EventBus) error {
+	return bus.Subscribe(events.PlatformIncident, r.Handle)
+}
+
+// Handle sends an incident event to every channel. It fails only if no
+// channel took it, so a retry does not repeat it on the channels that did.
+func (r *Router) Handle(ctx context.Context, event *events.Event) error {
+	if len(r.channels) == 0 {
+		return nil
+	}
+
+	report := events.IncidentReportFromEvent(event)
+	var errs []error
+	for _, channel := range r.channels {
+		if err := channel.Send(ctx, report); err != nil {
+			log.Printf("Failed to send incident %s to %s: %v", report.ID, channel.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name(), err))
+		}
+	}
+	if len(errs) == len(r.channels) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// title is a one-line description of an incident report
+func title(report *events.IncidentReport) string {
+	if report.Status == events.IncidentResolved {
+		return fmt.Sprintf("[RESOLVED] %s: %s", report.Service, report.Summary)
+	}
+	return fmt.Sprintf("[%s] %s: %s", strings.ToUpper(report.Severity), report.Service, report.Summary)
+}
+
+// body describes an incident report in plain text
+func body(report *events.IncidentReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Incident: %s\n", report.ID)
+	fmt.Fprintf(&b, "Status: %s\n", report.Status)
+	fmt.Fprintf(&b, "Service: %s\n", report.Service)
+	fmt.Fprintf(&b, "Signal: %s\n", report.Kind)
+	fmt.Fprintf(&b, "Firing: %s\n", strings.Join(report.Signals, ", "))
+	fmt.Fprintf(&b, "Started: %s\n", report.StartedAt.Format(time.RFC3339))
+	if report.ResolvedAt != nil {
+		fmt.Fprintf(&b, "Resolved: %s\n", report.ResolvedAt.Format(time.RFC3339))
+	}
+	keys := make([]string, 0, len(report.Details))
+	for key := range report.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s: %v\n", key, report.Details[key])
+	}
+	return b.String()
+}
+
+// SlackChannel posts incidents to a Slack incoming webhook
+type SlackChannel struct {
+	URL    string
+	Client *http.Client
+}
+
+// Name implements Channel
+func (c *SlackChannel) Name() string {
+	return "slack"
+}
+
+// Send implements Channel
+func (c *SlackChannel) Send(ctx context.Context, report *events.IncidentReport) error {
+	return postJSON(ctx, c.Client, c.URL, map[string]string{
+		"text": "*" + title(report) + "*\n```" + body(report) + "```",
+	})
+}
+
+// WebhookChannel posts incident reports as JSON
+type WebhookChannel struct {
+	URL    string
+	Client *http.Client
+}
+
+// Name implements Channel
+func (c *WebhookChannel) Name() string {
+	return "webhook"
+}
+
+// Send implements Channel
+func (c *WebhookChannel) Send(ctx context.Context, report *events.IncidentReport) error {
+	return postJSON(ctx, c.Client, c.URL, report)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// EmailChannel mails incidents through an SMTP server
+type EmailChannel struct {
+	Addr string
+	From string
+	To   []string
+	// Auth is nil for servers that do not need it
+	Auth smtp.Auth
+}
+
+// Name implements Channel
+func (c *EmailChannel) Name() string {
+	return "email"
+}
+
+// Send implements Channel
+func (c *EmailChannel) Send(ctx context.Context, report *events.IncidentReport) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", c.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", title(report))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body(report), "\n", "\r\n"))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(c.Addr, c.Auth, c.From, c.To, []byte(msg.String()))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
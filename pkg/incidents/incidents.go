@@ -0,0 +1,179 @@
+// Package incidents turns platform health signals, such as a circuit breaker
+// opening or an error budget burning too fast, into platform.incident events
+// and routes those events to the ops channel.
+//
+// Signals about the same service are correlated: the first one opens an
+// incident, later ones join it under the same ID, and the incident is
+// resolved once every signal has cleared, so a failing service pages once
+// rather than once per symptom.
+package incidents
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"microservices-platform/pkg/events"
+)
+
+// Signal kinds
+const (
+	KindCircuitOpen     = "circuit_open"
+	KindErrorBudgetBurn = "error_budget_burn"
+)
+
+// Severities
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+)
+
+// publishTimeout bounds publishing an incident event
+const publishTimeout = 5 * time.Second
+
+// Signal is a health signal about a service
+type Signal struct {
+	Service  string
+	Kind     string
+	Severity string
+	Summary  string
+	Details  map[string]interface{}
+}
+
+// incident is an open incident and its firing signals by kind
+type incident struct {
+	id        string
+	startedAt time.Time
+	signals   map[string]Signal
+}
+
+// Reporter correlates signals into incidents and publishes them
+type Reporter struct {
+	source string
+	bus    events.EventBus
+
+	mu   sync.Mutex
+	open map[string]*incident
+}
+
+// NewReporter creates a new incident reporter publishing as source. Without
+// a bus, incidents are only logged.
+func NewReporter(source string, bus events.EventBus) *Reporter {
+	return &Reporter{
+		source: source,
+		bus:    bus,
+		open:   make(map[string]*incident),
+	}
+}
+
+// Fire reports that a signal started firing. A signal already firing is
+// not reported again.
+func (r *Reporter) Fire(ctx context.Context, signal Signal) {
+	r.mu.Lock()
+	current, ok := r.open[signal.Service]
+	if !ok {
+		current = &incident{
+			id:        newIncidentID(),
+			startedAt: time.Now().UTC(),
+			signals:   make(map[string]Signal),
+		}
+		r.open[signal.Service] = current
+	}
+	if _, firing := current.signals[signal.Kind]; firing {
+		r.mu.Unlock()
+		return
+	}
+	current.signals[signal.Kind] = signal
+	report := current.report(events.IncidentFiring, signal)
+	r.mu.Unlock()
+
+	r.publish(ctx, report)
+}
+
+// Resolve reports that a signal stopped firing. The incident is resolved
+// when it was the last one.
+func (r *Reporter) Resolve(ctx context.Context, service, kind, summary string) {
+	r.mu.Lock()
+	current, ok := r.open[service]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
+	signal, firing := current.signals[kind]
+	if !firing {
+		r.mu.Unlock()
+		return
+	}
+	delete(current.signals, kind)
+	signal.Summary = summary
+	signal.Details = nil
+
+	status := events.IncidentFiring
+	if len(current.signals) == 0 {
+		status = events.IncidentResolved
+		delete(r.open, service)
+	}
+	report := current.report(status, signal)
+	if status == events.IncidentResolved {
+		resolvedAt := time.Now().UTC()
+		report.ResolvedAt = &resolvedAt
+	}
+	r.mu.Unlock()
+
+	r.publish(ctx, report)
+}
+
+// report describes the incident after signal changed. The severity is the
+// highest of its firing signals.
+func (i *incident) report(status string, signal Signal) *events.IncidentReport {
+	severity := signal.Severity
+	signals := make([]string, 0, len(i.signals))
+	for kind, firing := range i.signals {
+		signals = append(signals, kind)
+		if firing.Severity == SeverityCritical {
+			severity = SeverityCritical
+		}
+	}
+	sort.Strings(signals)
+
+	return &events.IncidentReport{
+		ID:        i.id,
+		Status:    status,
+		Service:   signal.Service,
+		Kind:      signal.Kind,
+		Severity:  severity,
+		Summary:   signal.Summary,
+		Signals:   signals,
+		Details:   signal.Details,
+		StartedAt: i.startedAt,
+	}
+}
+
+// newIncidentID generates a random incident ID
+func newIncidentID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("inc_%d", time.Now().UnixNano())
+	}
+	return "inc_" + hex.EncodeToString(b)
+}
+
+// publish logs and publishes an incident report. Publishing is best effort.
+func (r *Reporter) publish(ctx context.Context, report *events.IncidentReport) {
+	log.Printf("WARNING: incident %s %s: service=%s kind=%s severity=%s signals=%v: %s",
+		report.ID, report.Status, report.Service, report.Kind, report.Severity, report.Signals, report.Summary)
+
+	if r.bus == nil {
+		return
+	}
+	publishCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+	if err := r.bus.Publish(publishCtx, events.NewIncidentEvent(r.source, report)); err != nil {
+		log.Printf("Failed to publish incident %s: %v", report.ID, err)
+	}
+}
@@ -0,0 +1,127 @@
+package instrumentation
+
+import (
+	"context"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Business attributes carried as baggage from the gateway through gRPC calls
+// and events, and recorded on every span so traces can be searched by them
+const (
+	BaggageTenantID        = "tenant.id"
+	BaggageOrderID         = "order.id"
+	BaggageCartValueBucket = "cart.value_bucket"
+	BaggageLanguage        = "user.language"
+)
+
+// businessKeys are the baggage members copied onto spans. Other members are
+// propagated but not recorded.
+var businessKeys = []string{BaggageTenantID, BaggageOrderID, BaggageCartValueBucket, BaggageLanguage}
+
+// cartValueBuckets are the upper bounds of the cart value buckets
+var cartValueBuckets = []struct {
+	max   float64
+	label string
+}{
+	{25, "0-25"},
+	{50, "25-50"},
+	{100, "50-100"},
+	{250, "100-250"},
+	{500, "250-500"},
+	{1000, "500-1000"},
+}
+
+// CartValueBucket returns the bucket of a cart or order value, e.g.
+// "50-100", so traces can be grouped by value without recording amounts
+func CartValueBucket(amount float64) string {
+	for _, bucket := range cartValueBuckets {
+		if amount < bucket.max {
+			return bucket.label
+		}
+	}
+	return "1000+"
+}
+
+// SetupPropagation propagates trace context and baggage across process
+// boundaries. The otel default propagates neither.
+func SetupPropagation() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
+
+// WithBusinessContext adds business attributes to the context's baggage,
+// given as key and value pairs, and records them on the current span. Empty
+// values are skipped.
+func WithBusinessContext(ctx context.Context, keyValues ...string) context.Context {
+	bag := baggage.FromContext(ctx)
+	var attrs []attribute.KeyValue
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		key, value := keyValues[i], keyValues[i+1]
+		if value == "" {
+			continue
+		}
+		member, err := baggage.NewMember(key, url.PathEscape(value))
+		if err != nil {
+			continue
+		}
+		if updated, err := bag.SetMember(member); err == nil {
+			bag = updated
+			attrs = append(attrs, attribute.String(key, value))
+		}
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attrs...)
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// WithoutBusinessContext removes the business attributes from the context's
+// baggage, e.g. ones a client sent, which are not to be trusted
+func WithoutBusinessContext(ctx context.Context) context.Context {
+	bag := baggage.FromContext(ctx)
+	for _, key := range businessKeys {
+		bag = bag.DeleteMember(key)
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// BusinessAttributes returns the business attributes in the context's
+// baggage as span attributes
+func BusinessAttributes(ctx context.Context) []attribute.KeyValue {
+	bag := baggage.FromContext(ctx)
+	var attrs []attribute.KeyValue
+	for _, key := range businessKeys {
+		if value := bag.Member(key).Value(); value != "" {
+			attrs = append(attrs, attribute.String(key, value))
+		}
+	}
+	return attrs
+}
+
+// baggageSpanProcessor records business baggage on every span as it starts
+type baggageSpanProcessor struct{}
+
+// NewBaggageSpanProcessor returns a span processor that records the business
+// attributes in a span's parent context on the span, so spans started by
+// instrumentation libraries, such as gRPC server spans, carry them too
+func NewBaggageSpanProcessor() tracesdk.SpanProcessor {
+	return baggageSpanProcessor{}
+}
+
+func (baggageSpanProcessor) OnStart(parent context.Context, span tracesdk.ReadWriteSpan) {
+	span.SetAttributes(BusinessAttributes(parent)...)
+}
+
+func (baggageSpanProcessor) OnEnd(tracesdk.ReadOnlySpan) {}
+
+func (baggageSpanProcessor) Shutdown(context.Context) error { return nil }
+
+func (baggageSpanProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,242 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// QueryTracingConfig controls per-query tracing. Queries taking at least
+// SlowQueryThreshold are logged as slow and, with Explain set, their plan is
+// attached to the span and the log. A statement is explained at most once
+// per ExplainInterval.
+type QueryTracingConfig struct {
+	SlowQueryThreshold time.Duration
+	Explain            bool
+	ExplainInterval    time.Duration
+	ExplainTimeout     time.Duration
+}
+
+// LoadQueryTracingConfig loads query tracing configuration from environment
+// variables
+func LoadQueryTracingConfig() QueryTracingConfig {
+	return QueryTracingConfig{
+		SlowQueryThreshold: getDurationEnv("SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+		Explain:            getBoolEnv("SLOW_QUERY_EXPLAIN", true),
+		ExplainInterval:    getDurationEnv("SLOW_QUERY_EXPLAIN_INTERVAL", 5*time.Minute),
+		ExplainTimeout:     getDurationEnv("SLOW_QUERY_EXPLAIN_TIMEOUT", 2*time.Second),
+	}
+}
+
+// maxExplained bounds the statements remembered as recently explained
+const maxExplained = 1000
+
+// queryStartKey stores a query's span and start time on its statement
+const queryStartKey = "instrumentation:query"
+
+type queryStart struct {
+	span  trace.Span
+	start time.Time
+}
+
+// QueryTracer is a GORM plugin that traces every query with its
+// parameterized SQL, records database query metrics and explains slow
+// queries
+type QueryTracer struct {
+	service string
+	cfg     QueryTracingConfig
+	tracer  trace.Tracer
+
+	mu        sync.Mutex
+	explained map[string]time.Time
+}
+
+// NewQueryTracer creates a query tracer for service, to be installed with
+// db.Use
+func NewQueryTracer(service string, cfg QueryTracingConfig) *QueryTracer {
+	return &QueryTracer{
+		service:   service,
+		cfg:       cfg,
+		tracer:    otel.Tracer("gorm"),
+		explained: make(map[string]time.Time),
+	}
+}
+
+// Name implements gorm.Plugin
+func (t *QueryTracer) Name() string {
+	return "instrumentation:query_tracer"
+}
+
+// Initialize implements gorm.Plugin
+func (t *QueryTracer) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("instrumentation:before_create", t.before("create")),
+		callbacks.Create().After("gorm:create").Register("instrumentation:after_create", t.after("create")),
+		callbacks.Query().Before("gorm:query").Register("instrumentation:before_query", t.before("query")),
+		callbacks.Query().After("gorm:query").Register("instrumentation:after_query", t.after("query")),
+		callbacks.Update().Before("gorm:update").Register("instrumentation:before_update", t.before("update")),
+		callbacks.Update().After("gorm:update").Register("instrumentation:after_update", t.after("update")),
+		callbacks.Delete().Before("gorm:delete").Register("instrumentation:before_delete", t.before("delete")),
+		callbacks.Delete().After("gorm:delete").Register("instrumentation:after_delete", t.after("delete")),
+		callbacks.Row().Before("gorm:row").Register("instrumentation:before_row", t.before("row")),
+		callbacks.Row().After("gorm:row").Register("instrumentation:after_row", t.after("row")),
+		callbacks.Raw().Before("gorm:raw").Register("instrumentation:before_raw", t.before("raw")),
+		callbacks.Raw().After("gorm:raw").Register("instrumentation:after_raw", t.after("raw")),
+	)
+}
+
+func (t *QueryTracer) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, span := t.tracer.Start(ctx, "db."+operation, trace.WithSpanKind(trace.SpanKindClient))
+		db.Statement.Context = ctx
+		db.InstanceSet(queryStartKey, &queryStart{span: span, start: time.Now()})
+	}
+}
+
+func (t *QueryTracer) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(queryStartKey)
+		if !ok {
+			return
+		}
+		started := value.(*queryStart)
+		duration := time.Since(started.start)
+		span := started.span
+		defer span.End()
+
+		// The statement keeps its values apart from the SQL, so no
+		// parameter is recorded
+		statement := db.Statement.SQL.String()
+		table := db.Statement.Table
+		span.SetName("db." + operation + " " + table)
+		span.SetAttributes(
+			attribute.String("db.system", db.Dialector.Name()),
+			attribute.String("db.operation", operation),
+			attribute.String("db.sql.table", table),
+			attribute.String("db.statement", statement),
+			attribute.Int64("db.rows_affected", db.RowsAffected),
+		)
+
+		status := "success"
+		if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			status = "error"
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		metrics.RecordDatabaseQuery(t.service, operation, table, status, duration)
+
+		if statement == "" || duration < t.cfg.SlowQueryThreshold {
+			return
+		}
+		metrics.RecordSlowQuery(t.service, table)
+		span.SetAttributes(attribute.Bool("db.slow_query", true))
+
+		// Rows still being read hold the connection, so row queries are
+		// not explained. Neither are queries in a transaction, which a
+		// failed EXPLAIN would abort.
+		_, inTransaction := db.Statement.ConnPool.(gorm.TxCommitter)
+		var plan string
+		if t.cfg.Explain && db.Error == nil && operation != "row" && !inTransaction && t.shouldExplain(statement) {
+			var err error
+			plan, err = t.explain(db, statement)
+			if err != nil {
+				log.Printf("WARNING: failed to explain slow query on %s: %v", table, err)
+			} else {
+				span.SetAttributes(attribute.String("db.plan", plan))
+			}
+		}
+
+		if plan == "" {
+			log.Printf("WARNING: slow query (%s) on %s: %s", duration.Round(time.Millisecond), table, statement)
+			return
+		}
+		log.Printf("WARNING: slow query (%s) on %s: %s\n%s", duration.Round(time.Millisecond), table, statement, plan)
+	}
+}
+
+// shouldExplain reports whether statement was not explained within the
+// explain interval, and if so, marks it explained
+func (t *QueryTracer) shouldExplain(statement string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if last, ok := t.explained[statement]; ok && now.Sub(last) < t.cfg.ExplainInterval {
+		return false
+	}
+	if len(t.explained) >= maxExplained {
+		for key, last := range t.explained {
+			if now.Sub(last) >= t.cfg.ExplainInterval {
+				delete(t.explained, key)
+			}
+		}
+		if len(t.explained) >= maxExplained {
+			return false
+		}
+	}
+	t.explained[statement] = now
+	return true
+}
+
+// explain returns the plan of statement. EXPLAIN without ANALYZE plans the
+// statement without running it, so writes are not repeated. It bypasses
+// GORM's callbacks so it is not traced itself.
+func (t *QueryTracer) explain(db *gorm.DB, statement string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.cfg.ExplainTimeout)
+	defer cancel()
+
+	rows, err := db.Statement.ConnPool.QueryContext(ctx, "EXPLAIN "+statement, db.Statement.Vars...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getBoolEnv(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,174 @@
+package instrumentation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"net/url"
+	rpprof "runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"microservices-platform/pkg/config"
+)
+
+// Profiler continuously collects CPU and heap profiles, either pushing them
+// to Pyroscope or serving them for Parca to scrape
+type Profiler struct {
+	service string
+	labels  map[string]string
+	cfg     config.ObservabilityConfig
+	client  *http.Client
+
+	server *http.Server
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// StartProfiling starts continuous profiling for service if it is enabled in
+// cfg. It returns nil when profiling is disabled; Stop is safe to call on nil.
+func StartProfiling(service string, cfg config.ObservabilityConfig) (*Profiler, error) {
+	if !cfg.ProfilingEnabled {
+		return nil, nil
+	}
+
+	p := &Profiler{
+		service: service,
+		labels:  map[string]string{"service_name": service, "version": cfg.ServiceVersion},
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	switch cfg.ProfilingBackend {
+	case config.ProfilingBackendPyroscope:
+		if cfg.ProfilingServerURL == "" {
+			return nil, fmt.Errorf("profiling server URL is required for %s", cfg.ProfilingBackend)
+		}
+		if cfg.ProfilingInterval <= 0 {
+			p.cfg.ProfilingInterval = 15 * time.Second
+		}
+		go p.push()
+		log.Printf("Continuous profiling pushing to %s every %s", cfg.ProfilingServerURL, p.cfg.ProfilingInterval)
+	case config.ProfilingBackendParca:
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.Handle("/debug/pprof/heap", pprof.Handler("heap"))
+		p.server = &http.Server{Addr: cfg.ProfilingAddr, Handler: mux}
+		go func() {
+			defer close(p.done)
+			log.Printf("Continuous profiling serving profiles on %s", cfg.ProfilingAddr)
+			if err := p.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Profiling server stopped: %v", err)
+			}
+		}()
+	default:
+		return nil, fmt.Errorf("unknown profiling backend %q", cfg.ProfilingBackend)
+	}
+	return p, nil
+}
+
+// Stop stops profiling, pushing the profiles collected so far
+func (p *Profiler) Stop() {
+	if p == nil {
+		return
+	}
+	if p.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		p.server.Shutdown(ctx)
+	} else {
+		close(p.stop)
+	}
+	<-p.done
+}
+
+// push profiles the CPU over each interval and pushes that profile and a
+// heap profile at its end
+func (p *Profiler) push() {
+	defer close(p.done)
+
+	for {
+		from := time.Now()
+		var cpu bytes.Buffer
+		// Fails while another CPU profile is running, e.g. from the pprof
+		// debug endpoint; the interval's heap profile is still pushed
+		cpuErr := rpprof.StartCPUProfile(&cpu)
+
+		stopped := false
+		select {
+		case <-p.stop:
+			stopped = true
+		case <-time.After(p.cfg.ProfilingInterval):
+		}
+		if cpuErr == nil {
+			rpprof.StopCPUProfile()
+		}
+		until := time.Now()
+
+		if cpuErr == nil {
+			p.upload("cpu", from, until, cpu.Bytes())
+		}
+		var heap bytes.Buffer
+		if err := rpprof.Lookup("heap").WriteTo(&heap, 0); err == nil {
+			p.upload("heap", from, until, heap.Bytes())
+		}
+
+		if stopped {
+			return
+		}
+	}
+}
+
+// upload pushes a pprof profile of kind to Pyroscope's ingest API, which
+// names it after its sample types. Failures are logged and the profile
+// dropped.
+func (p *Profiler) upload(kind string, from, until time.Time, profile []byte) {
+	query := url.Values{}
+	query.Set("name", p.service+p.labelSet())
+	query.Set("from", fmt.Sprint(from.Unix()))
+	query.Set("until", fmt.Sprint(until.Unix()))
+	query.Set("format", "pprof")
+	query.Set("spyName", "gospy")
+
+	endpoint := strings.TrimSuffix(p.cfg.ProfilingServerURL, "/") + "/ingest?" + query.Encode()
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(profile))
+	if err != nil {
+		log.Printf("WARNING: failed to push %s profile: %v", kind, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if p.cfg.ProfilingAuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.cfg.ProfilingAuthToken)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		log.Printf("WARNING: failed to push %s profile: %v", kind, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("WARNING: failed to push %s profile: unexpected status %d", kind, resp.StatusCode)
+	}
+}
+
+// labelSet renders the profile labels in Pyroscope's name{key=value} form
+func (p *Profiler) labelSet() string {
+	keys := make([]string, 0, len(p.labels))
+	for key := range p.labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+p.labels[key])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -7,10 +7,12 @@ import (
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/jaeger"
-	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"microservices-platform/pkg/buildinfo"
 )
 
 // TracerProvider holds the tracer provider
@@ -29,18 +31,18 @@ func NewTracerProvider(serviceName, jaegerURL string) (*TracerProvider, error) {
 	// Create tracer provider
 	tp := tracesdk.NewTracerProvider(
 		tracesdk.WithBatcher(exp),
+		tracesdk.WithSpanProcessor(NewBaggageSpanProcessor()),
 		tracesdk.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(serviceName),
-			semconv.ServiceVersionKey.String("1.0.0"),
+			buildinfo.ResourceAttributes(serviceName)...,
 		)),
 	)
 
 	// Register as global tracer provider
 	otel.SetTracerProvider(tp)
 	
-	// Set global propagator to tracecontext (the default is no-op)
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+	// Set global propagator to tracecontext and baggage (the default is no-op)
+	SetupPropagation()
 
 	return &TracerProvider{provider: tp}, nil
 }
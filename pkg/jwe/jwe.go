@@ -0,0 +1,234 @@
+// Package jwe encrypts payloads to a recipient's RSA public key as JSON Web
+// Encryption (RFC 7516) compact serializations, using RSA-OAEP-256 key
+// encryption and A256GCM content encryption.
+package jwe
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Algorithms used for every payload
+const (
+	KeyAlgorithm     = "RSA-OAEP-256"
+	ContentAlgorithm = "A256GCM"
+)
+
+// ContentType is the media type of a compact serialization
+const ContentType = "application/jose"
+
+// MinKeyBits is the smallest RSA modulus accepted
+const MinKeyBits = 2048
+
+// Header is the protected header of a JWE
+type Header struct {
+	Algorithm   string `json:"alg"`
+	Encryption  string `json:"enc"`
+	KeyID       string `json:"kid,omitempty"`
+	ContentType string `json:"cty,omitempty"`
+}
+
+// jwk is the part of a JSON Web Key that describes an RSA public key
+type jwk struct {
+	KeyType  string `json:"kty"`
+	Modulus  string `json:"n"`
+	Exponent string `json:"e"`
+	KeyID    string `json:"kid,omitempty"`
+}
+
+// ParsePublicKey parses an RSA public key given either as a PEM "PUBLIC KEY"
+// or "RSA PUBLIC KEY" block or as a JSON Web Key. keyID is the JWK's "kid",
+// if it has one.
+func ParsePublicKey(encoded string) (key *rsa.PublicKey, keyID string, err error) {
+	encoded = strings.TrimSpace(encoded)
+	if strings.HasPrefix(encoded, "{") {
+		key, keyID, err = parseJWK(encoded)
+	} else {
+		key, err = parsePEM(encoded)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if key.N.BitLen() < MinKeyBits {
+		return nil, "", fmt.Errorf("RSA keys must be at least %d bits", MinKeyBits)
+	}
+	return key, keyID, nil
+}
+
+func parsePEM(encoded string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, errors.New("public key must be PEM or a JSON Web Key")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %v", err)
+		}
+		key, ok := parsed.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("public key must be an RSA key")
+		}
+		return key, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %v", err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+}
+
+func parseJWK(encoded string) (*rsa.PublicKey, string, error) {
+	var key jwk
+	if err := json.Unmarshal([]byte(encoded), &key); err != nil {
+		return nil, "", fmt.Errorf("invalid JSON Web Key: %v", err)
+	}
+	if key.KeyType != "RSA" {
+		return nil, "", errors.New("JSON Web Key must be an RSA key")
+	}
+
+	n, err := base64.RawURLEncoding.DecodeString(key.Modulus)
+	if err != nil || len(n) == 0 {
+		return nil, "", errors.New("invalid JSON Web Key modulus")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(key.Exponent)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, "", errors.New("invalid JSON Web Key exponent")
+	}
+
+	exponent := new(big.Int).SetBytes(e).Int64()
+	if exponent < 3 {
+		return nil, "", errors.New("invalid JSON Web Key exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent)}, key.KeyID, nil
+}
+
+// Thumbprint returns the RFC 7638 SHA-256 thumbprint of a key, base64url
+// encoded, which serves as its key ID when the recipient does not name it
+func Thumbprint(key *rsa.PublicKey) string {
+	// The members are in lexicographic order with no whitespace, as RFC 7638
+	// requires
+	canonical := fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`,
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()))
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Encrypt encrypts plaintext to key. keyID is sent as the "kid" header, so
+// the recipient can pick the matching private key while rotating keys, and
+// contentType as "cty", the media type of the plaintext.
+func Encrypt(key *rsa.PublicKey, keyID, contentType string, plaintext []byte) (string, error) {
+	header, err := json.Marshal(Header{
+		Algorithm:   KeyAlgorithm,
+		Encryption:  ContentAlgorithm,
+		KeyID:       keyID,
+		ContentType: contentType,
+	})
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+
+	cek := make([]byte, 32)
+	if _, err := rand.Read(cek); err != nil {
+		return "", err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, cek, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt content key: %v", err)
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	// The protected header is the additional authenticated data
+	sealed := gcm.Seal(nil, iv, plaintext, []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return strings.Join([]string{
+		protected,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, "."), nil
+}
+
+// Decrypt decrypts a compact serialization produced by Encrypt, returning its
+// header and plaintext. It is what recipients run with their private key.
+func Decrypt(key *rsa.PrivateKey, compact string) (*Header, []byte, error) {
+	parts := strings.Split(compact, ".")
+	if len(parts) != 5 {
+		return nil, nil, errors.New("invalid JWE compact serialization")
+	}
+
+	decoded := make([][]byte, len(parts))
+	for i, part := range parts {
+		b, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid JWE compact serialization: %v", err)
+		}
+		decoded[i] = b
+	}
+
+	var header Header
+	if err := json.Unmarshal(decoded[0], &header); err != nil {
+		return nil, nil, fmt.Errorf("invalid JWE header: %v", err)
+	}
+	if header.Algorithm != KeyAlgorithm || header.Encryption != ContentAlgorithm {
+		return nil, nil, fmt.Errorf("unsupported JWE algorithms %s/%s", header.Algorithm, header.Encryption)
+	}
+
+	cek, err := key.Decrypt(rand.Reader, decoded[1], &rsa.OAEPOptions{Hash: crypto.SHA256})
+	if err != nil {
+		return nil, nil, errors.New("failed to decrypt content key")
+	}
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(decoded[2]) != gcm.NonceSize() {
+		return nil, nil, errors.New("invalid JWE initialization vector")
+	}
+
+	plaintext, err := gcm.Open(nil, decoded[2], append(decoded[3], decoded[4]...), []byte(parts[0]))
+	if err != nil {
+		return nil, nil, errors.New("failed to decrypt JWE content")
+	}
+	return &header, plaintext, nil
+}
+
+func newGCM(cek []byte) (cipher.AEAD, error) {
+	if len(cek) != 32 {
+		return nil, errors.New("invalid content encryption key")
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
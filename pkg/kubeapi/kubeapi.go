@@ -0,0 +1,192 @@
+// Package kubeapi is a minimal client of the Kubernetes API for reading and
+// watching objects from inside a pod, authenticated as the pod's service
+// account.
+package kubeapi
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// serviceAccountDir holds the credentials Kubernetes mounts into pods
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// WatchTimeout ends each watch request, after which the watch is resumed
+// from the last resource version seen
+const WatchTimeout = 5 * time.Minute
+
+// ErrGone is returned when a watched resource version is too old to resume
+// from, so the object has to be read again
+var ErrGone = errors.New("resource version too old")
+
+// Client sends requests to the Kubernetes API of the cluster the pod runs in
+type Client struct {
+	host      string
+	namespace string
+	http      *http.Client
+}
+
+// NewInCluster creates a client using the pod's service account. Namespace
+// defaults to the pod's own.
+func NewInCluster(namespace string) (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster")
+	}
+
+	if namespace == "" {
+		data, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the pod's namespace: %v", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cluster CA: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid cluster CA")
+	}
+
+	return &Client{
+		host:      "https://" + net.JoinHostPort(host, port),
+		namespace: namespace,
+		http: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12},
+			},
+		},
+	}, nil
+}
+
+// Namespace returns the namespace the client reads objects from
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// Get reads the object at path into v
+func (c *Client) Get(ctx context.Context, path string, v interface{}) error {
+	resp, err := c.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+	return nil
+}
+
+// Event is one event of a watch stream
+type Event struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// Stream is an open watch request
+type Stream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+// Watch watches the object named name in the collection at path, from
+// resourceVersion. The API server ends the stream after WatchTimeout.
+func (c *Client) Watch(ctx context.Context, path, name, resourceVersion string) (*Stream, error) {
+	query := url.Values{
+		"watch":           {"true"},
+		"fieldSelector":   {"metadata.name=" + name},
+		"resourceVersion": {resourceVersion},
+		"timeoutSeconds":  {fmt.Sprint(int(WatchTimeout.Seconds()))},
+	}
+	resp, err := c.get(ctx, path+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	return &Stream{body: resp.Body, scanner: scanner}, nil
+}
+
+// Next returns the next event, or io.EOF once the API server ended the
+// stream. An ERROR event is returned as an error, ErrGone if the resource
+// version is too old.
+func (s *Stream) Next() (Event, error) {
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return Event{}, err
+		}
+		return Event{}, io.EOF
+	}
+
+	var event Event
+	if err := json.Unmarshal(s.scanner.Bytes(), &event); err != nil {
+		return Event{}, fmt.Errorf("invalid watch event: %v", err)
+	}
+	if event.Type == "ERROR" {
+		var status struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(event.Object, &status)
+		if status.Code == http.StatusGone {
+			return Event{}, ErrGone
+		}
+		return Event{}, fmt.Errorf("watch error %d: %s", status.Code, status.Message)
+	}
+	return event, nil
+}
+
+// Close ends the watch
+func (s *Stream) Close() error {
+	return s.body.Close()
+}
+
+// get sends an authenticated GET to the Kubernetes API. The token is read
+// for every request since Kubernetes rotates it.
+func (c *Client) get(ctx context.Context, path string) (*http.Response, error) {
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the service account token: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.host+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var status struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&status)
+		if resp.StatusCode == http.StatusGone {
+			return nil, ErrGone
+		}
+		return nil, fmt.Errorf("failed to read %s: %s: %s", strings.SplitN(path, "?", 2)[0], resp.Status, status.Message)
+	}
+	return resp, nil
+}
@@ -0,0 +1,411 @@
+// Package ledger records money movements as double-entry bookkeeping. Every
+// movement is an entry of two or more postings to accounts whose debits and
+// credits balance, so money is never created or lost between accounts and
+// the ledger's accounts always sum to zero. Entries are immutable: a mistake
+// is corrected by posting a reversing entry, never by editing the original.
+//
+// Amounts are integer minor units (cents) so that sums are exact.
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Kinds of entry
+const (
+	KindCharge         = "charge"
+	KindRefund         = "refund"
+	KindGiftCardIssue  = "gift_card_issue"
+	KindGiftCardRedeem = "gift_card_redeem"
+	KindCreditGrant    = "credit_grant"
+	KindCreditRedeem   = "credit_redeem"
+	KindReversal       = "reversal"
+)
+
+// Platform accounts. Customer gift card and credit balances have an account
+// each; see GiftCardAccount and CreditAccount.
+const (
+	// AccountCash is money held by the payment processors (asset)
+	AccountCash = "assets:cash"
+	// AccountSales is revenue from orders (revenue)
+	AccountSales = "revenue:sales"
+	// AccountRefunds is money returned to customers (contra-revenue)
+	AccountRefunds = "revenue:refunds"
+	// AccountCreditsIssued is the cost of store credit granted (expense)
+	AccountCreditsIssued = "expenses:credits"
+)
+
+// GiftCardAccount is the outstanding balance of a gift card (liability)
+func GiftCardAccount(giftCardID string) string {
+	return "liabilities:gift_cards:" + giftCardID
+}
+
+// CreditAccount is a user's store credit (liability)
+func CreditAccount(userID string) string {
+	return "liabilities:credits:" + userID
+}
+
+// Errors returned when posting entries
+var (
+	// ErrUnbalanced is returned for an entry whose debits and credits differ
+	ErrUnbalanced = errors.New("ledger entry does not balance")
+	// ErrInvalidEntry is returned for an entry that is malformed
+	ErrInvalidEntry = errors.New("invalid ledger entry")
+	// ErrImmutable is returned when changing or deleting a posted entry
+	ErrImmutable = errors.New("ledger entries are immutable")
+	// ErrNotFound is returned for an unknown entry
+	ErrNotFound = errors.New("ledger entry not found")
+	// ErrInsufficientBalance is returned when redeeming more than a gift
+	// card or store credit holds
+	ErrInsufficientBalance = errors.New("insufficient balance")
+)
+
+// Entry is a movement of money between accounts
+type Entry struct {
+	ID   string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Kind string `gorm:"not null;index"`
+	// Reference is what the entry records, e.g. a payment or refund ID
+	Reference   string `gorm:"index"`
+	Description string
+	Currency    string `gorm:"not null"`
+	// IdempotencyKey makes posting an entry safe to retry: an entry posted
+	// with a key that was used before returns the earlier entry
+	IdempotencyKey *string `gorm:"uniqueIndex"`
+	// ReversesID is the entry a reversal undoes
+	ReversesID *string   `gorm:"type:uuid;uniqueIndex"`
+	Postings   []Posting `gorm:"foreignKey:EntryID"`
+	CreatedAt  time.Time `gorm:"not null;index"`
+}
+
+// Posting debits or credits one account. Debits are positive amounts and
+// credits negative.
+type Posting struct {
+	ID        uint      `gorm:"primaryKey"`
+	EntryID   string    `gorm:"type:uuid;not null;index"`
+	Account   string    `gorm:"not null;index:idx_postings_account_time"`
+	Amount    int64     `gorm:"not null"`
+	Currency  string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"not null;index:idx_postings_account_time"`
+}
+
+// TableName keeps the ledger's tables apart in a shared database
+func (Entry) TableName() string { return "ledger_entries" }
+
+// TableName keeps the ledger's tables apart in a shared database
+func (Posting) TableName() string { return "ledger_postings" }
+
+// Debit returns a posting that debits amount to account
+func Debit(account string, amount int64) Posting {
+	return Posting{Account: account, Amount: amount}
+}
+
+// Credit returns a posting that credits amount to account
+func Credit(account string, amount int64) Posting {
+	return Posting{Account: account, Amount: -amount}
+}
+
+// BeforeUpdate refuses to change a posted entry
+func (e *Entry) BeforeUpdate(tx *gorm.DB) error { return ErrImmutable }
+
+// BeforeDelete refuses to delete a posted entry
+func (e *Entry) BeforeDelete(tx *gorm.DB) error { return ErrImmutable }
+
+// BeforeUpdate refuses to change a posting
+func (p *Posting) BeforeUpdate(tx *gorm.DB) error { return ErrImmutable }
+
+// BeforeDelete refuses to delete a posting
+func (p *Posting) BeforeDelete(tx *gorm.DB) error { return ErrImmutable }
+
+// MinorUnits converts an amount such as 19.99 to minor units, e.g. 1999
+func MinorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// MajorUnits converts minor units back to an amount
+func MajorUnits(amount int64) float64 {
+	return float64(amount) / 100
+}
+
+// validate checks that an entry's postings balance
+func (e *Entry) validate() error {
+	if e.Kind == "" || e.Currency == "" {
+		return fmt.Errorf("%w: kind and currency are required", ErrInvalidEntry)
+	}
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("%w: an entry needs at least two postings", ErrInvalidEntry)
+	}
+	var sum int64
+	for _, posting := range e.Postings {
+		if posting.Account == "" || posting.Amount == 0 {
+			return fmt.Errorf("%w: postings need an account and a non-zero amount", ErrInvalidEntry)
+		}
+		sum += posting.Amount
+	}
+	if sum != 0 {
+		return fmt.Errorf("%w: postings sum to %d", ErrUnbalanced, sum)
+	}
+	return nil
+}
+
+// Ledger posts entries to, and reports balances from, a Postgres database
+type Ledger struct {
+	db *gorm.DB
+}
+
+// New creates a ledger on db
+func New(db *gorm.DB) *Ledger {
+	return &Ledger{db: db}
+}
+
+// Open connects to the ledger database at databaseURL, e.g. to read balances
+// from another service. It does not create the tables.
+func Open(databaseURL string) (*Ledger, error) {
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Warn),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return New(db), nil
+}
+
+// Migrate creates the ledger's tables
+func (l *Ledger) Migrate() error {
+	return l.db.AutoMigrate(&Entry{}, &Posting{})
+}
+
+// Post records an entry and its postings atomically. An entry whose
+// idempotency key was posted before is not posted again; the earlier entry
+// is returned instead.
+func (l *Ledger) Post(ctx context.Context, entry *Entry) (*Entry, error) {
+	return l.post(ctx, entry, nil)
+}
+
+// post records an entry after check, if given, passes inside the transaction
+func (l *Ledger) post(ctx context.Context, entry *Entry, check func(tx *gorm.DB) error) (*Entry, error) {
+	entry.Currency = strings.ToUpper(entry.Currency)
+	if err := entry.validate(); err != nil {
+		return nil, err
+	}
+	if entry.IdempotencyKey != nil {
+		if existing, err := l.byIdempotencyKey(ctx, *entry.IdempotencyKey); err != nil || existing != nil {
+			return existing, err
+		}
+	}
+
+	now := time.Now().UTC()
+	entry.CreatedAt = now
+	for i := range entry.Postings {
+		entry.Postings[i].Currency = entry.Currency
+		entry.Postings[i].CreatedAt = now
+	}
+	err := l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if check != nil {
+			if err := check(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		if entry.IdempotencyKey != nil && !errors.Is(err, ErrInsufficientBalance) {
+			// Lost a race with a retry of the same entry
+			if existing, lookupErr := l.byIdempotencyKey(ctx, *entry.IdempotencyKey); lookupErr == nil && existing != nil {
+				return existing, nil
+			}
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (l *Ledger) byIdempotencyKey(ctx context.Context, key string) (*Entry, error) {
+	var entry Entry
+	err := l.db.WithContext(ctx).Preload("Postings").Where("idempotency_key = ?", key).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Get returns an entry with its postings
+func (l *Ledger) Get(ctx context.Context, id string) (*Entry, error) {
+	var entry Entry
+	err := l.db.WithContext(ctx).Preload("Postings").First(&entry, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Reverse posts an entry that undoes another. An entry can only be reversed
+// once; reversing it again returns the earlier reversal.
+func (l *Ledger) Reverse(ctx context.Context, id, reason string) (*Entry, error) {
+	original, err := l.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if original.Kind == KindReversal {
+		return nil, fmt.Errorf("%w: a reversal cannot be reversed", ErrInvalidEntry)
+	}
+
+	key := "reversal:" + original.ID
+	reversal := &Entry{
+		Kind:           KindReversal,
+		Reference:      original.Reference,
+		Description:    reason,
+		Currency:       original.Currency,
+		IdempotencyKey: &key,
+		ReversesID:     &original.ID,
+	}
+	for _, posting := range original.Postings {
+		reversal.Postings = append(reversal.Postings, Posting{Account: posting.Account, Amount: -posting.Amount})
+	}
+	return l.Post(ctx, reversal)
+}
+
+// Balance is the sum of an account's postings in a currency
+type Balance struct {
+	Account  string `json:"account"`
+	Currency string `json:"currency"`
+	Debits   int64  `json:"debits"`
+	Credits  int64  `json:"credits"`
+	// Balance is debits less credits: positive for assets and expenses,
+	// negative for liabilities and revenue
+	Balance int64 `json:"balance"`
+}
+
+// AccountBalance returns an account's balance in a currency as of a time;
+// a zero asOf means now
+func (l *Ledger) AccountBalance(ctx context.Context, account, currency string, asOf time.Time) (*Balance, error) {
+	balances, err := l.balances(ctx, "account = ?", account, strings.ToUpper(currency), asOf)
+	if err != nil {
+		return nil, err
+	}
+	if len(balances) == 0 {
+		return &Balance{Account: account, Currency: strings.ToUpper(currency)}, nil
+	}
+	return &balances[0], nil
+}
+
+// Balances returns the balances of the accounts under prefix, e.g.
+// "liabilities:gift_cards:", as of a time. An empty prefix returns every
+// account, a trial balance whose balances sum to zero in each currency.
+func (l *Ledger) Balances(ctx context.Context, prefix, currency string, asOf time.Time) ([]Balance, error) {
+	return l.balances(ctx, "account LIKE ?", escapeLike(prefix)+"%", strings.ToUpper(currency), asOf)
+}
+
+func (l *Ledger) balances(ctx context.Context, accountQuery, accountArg, currency string, asOf time.Time) ([]Balance, error) {
+	query := l.db.WithContext(ctx).Model(&Posting{}).
+		Select("account, currency, "+
+			"COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0) AS debits, "+
+			"COALESCE(SUM(CASE WHEN amount < 0 THEN -amount ELSE 0 END), 0) AS credits, "+
+			"COALESCE(SUM(amount), 0) AS balance").
+		Where(accountQuery, accountArg).
+		Group("account, currency").
+		Order("account, currency")
+	if currency != "" {
+		query = query.Where("currency = ?", currency)
+	}
+	if !asOf.IsZero() {
+		query = query.Where("created_at <= ?", asOf)
+	}
+
+	var balances []Balance
+	if err := query.Scan(&balances).Error; err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// EntryFilter selects entries to list; empty fields match any entry
+type EntryFilter struct {
+	// Account matches entries with a posting to the account
+	Account   string
+	Kind      string
+	Reference string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
+
+// Entries lists entries, newest first, with their postings
+func (l *Ledger) Entries(ctx context.Context, filter EntryFilter) ([]Entry, error) {
+	query := l.db.WithContext(ctx).Model(&Entry{}).Preload("Postings").Order("created_at DESC, id")
+	if filter.Account != "" {
+		query = query.Where("id IN (?)", l.db.Model(&Posting{}).Select("entry_id").Where("account = ?", filter.Account))
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.Reference != "" {
+		query = query.Where("reference = ?", filter.Reference)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	var entries []Entry
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ReferenceTotals sums the postings to account by the reference of their
+// entries, for the given references. Reversals share the reference of the
+// entry they undo, so the totals are net of them.
+func (l *Ledger) ReferenceTotals(ctx context.Context, account string, references []string) (map[string]int64, error) {
+	totals := make(map[string]int64)
+	if len(references) == 0 {
+		return totals, nil
+	}
+
+	var rows []struct {
+		Reference string
+		Total     int64
+	}
+	err := l.db.WithContext(ctx).Model(&Posting{}).
+		Select("ledger_entries.reference, COALESCE(SUM(ledger_postings.amount), 0) AS total").
+		Joins("JOIN ledger_entries ON ledger_entries.id = ledger_postings.entry_id").
+		Where("ledger_postings.account = ? AND ledger_entries.reference IN ?", account, references).
+		Group("ledger_entries.reference").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		totals[row.Reference] = row.Total
+	}
+	return totals, nil
+}
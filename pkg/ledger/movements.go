@@ -0,0 +1,120 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Movement is money moved for a reference, such as a payment. Amount is in
+// minor units.
+type Movement struct {
+	Reference   string
+	Amount      int64
+	Currency    string
+	Description string
+}
+
+// entry builds an entry of kind for the movement, keyed by kind and
+// reference so that recording the same movement twice posts it once
+func (m Movement) entry(kind string, postings ...Posting) (*Entry, error) {
+	if m.Reference == "" || m.Amount <= 0 {
+		return nil, fmt.Errorf("%w: movements need a reference and a positive amount", ErrInvalidEntry)
+	}
+	key := kind + ":" + m.Reference
+	return &Entry{
+		Kind:           kind,
+		Reference:      m.Reference,
+		Description:    m.Description,
+		Currency:       m.Currency,
+		IdempotencyKey: &key,
+		Postings:       postings,
+	}, nil
+}
+
+// Charge records a customer paying for an order
+func (l *Ledger) Charge(ctx context.Context, m Movement) (*Entry, error) {
+	entry, err := m.entry(KindCharge, Debit(AccountCash, m.Amount), Credit(AccountSales, m.Amount))
+	if err != nil {
+		return nil, err
+	}
+	return l.Post(ctx, entry)
+}
+
+// Refund records money returned to a customer. The reference is the
+// refund's, so that a payment can be refunded more than once.
+func (l *Ledger) Refund(ctx context.Context, m Movement) (*Entry, error) {
+	entry, err := m.entry(KindRefund, Debit(AccountRefunds, m.Amount), Credit(AccountCash, m.Amount))
+	if err != nil {
+		return nil, err
+	}
+	return l.Post(ctx, entry)
+}
+
+// IssueGiftCard records the sale of a gift card, whose balance is owed to
+// its holder until redeemed
+func (l *Ledger) IssueGiftCard(ctx context.Context, giftCardID string, m Movement) (*Entry, error) {
+	entry, err := m.entry(KindGiftCardIssue, Debit(AccountCash, m.Amount), Credit(GiftCardAccount(giftCardID), m.Amount))
+	if err != nil {
+		return nil, err
+	}
+	return l.Post(ctx, entry)
+}
+
+// RedeemGiftCard records paying for an order with a gift card. It returns
+// ErrInsufficientBalance if the card holds less than the amount.
+func (l *Ledger) RedeemGiftCard(ctx context.Context, giftCardID string, m Movement) (*Entry, error) {
+	account := GiftCardAccount(giftCardID)
+	entry, err := m.entry(KindGiftCardRedeem, Debit(account, m.Amount), Credit(AccountSales, m.Amount))
+	if err != nil {
+		return nil, err
+	}
+	return l.post(ctx, entry, available(account, entry.Currency, m.Amount))
+}
+
+// GrantCredit records store credit given to a user, e.g. as a goodwill
+// gesture
+func (l *Ledger) GrantCredit(ctx context.Context, userID string, m Movement) (*Entry, error) {
+	entry, err := m.entry(KindCreditGrant, Debit(AccountCreditsIssued, m.Amount), Credit(CreditAccount(userID), m.Amount))
+	if err != nil {
+		return nil, err
+	}
+	return l.Post(ctx, entry)
+}
+
+// RedeemCredit records paying for an order with store credit. It returns
+// ErrInsufficientBalance if the user has less credit than the amount.
+func (l *Ledger) RedeemCredit(ctx context.Context, userID string, m Movement) (*Entry, error) {
+	account := CreditAccount(userID)
+	entry, err := m.entry(KindCreditRedeem, Debit(account, m.Amount), Credit(AccountSales, m.Amount))
+	if err != nil {
+		return nil, err
+	}
+	return l.post(ctx, entry, available(account, entry.Currency, m.Amount))
+}
+
+// available checks that a liability account owes at least amount. The
+// account is locked for the rest of the transaction so that two concurrent
+// redemptions cannot both spend the same balance.
+func available(account, currency string, amount int64) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", account).Error; err != nil {
+			return err
+		}
+		var balance int64
+		err := tx.Model(&Posting{}).
+			Select("COALESCE(SUM(amount), 0)").
+			Where("account = ? AND currency = ?", account, strings.ToUpper(currency)).
+			Scan(&balance).Error
+		if err != nil {
+			return err
+		}
+		// Liabilities have credit balances, i.e. negative sums
+		if -balance < amount {
+			return fmt.Errorf("%w: %s holds %d, %d requested", ErrInsufficientBalance, account, -balance, amount)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// AdminPath is where services serve Handler
+const AdminPath = "/admin/log-level"
+
+type levelBody struct {
+	Level string `json:"level"`
+}
+
+// Handler reads the level on GET and changes it on PUT, given a body like
+// {"level":"debug"}. If token is not empty, requests must carry it as a
+// bearer token.
+func Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && !validToken(r, token) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing token"})
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var body levelBody
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+				return
+			}
+			l, err := ParseLevel(body.Level)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			SetLevel(l)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, levelBody{Level: CurrentLevel().String()})
+	})
+}
+
+func validToken(r *http.Request, token string) bool {
+	presented := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// watchLevelFile applies the level in path whenever the file changes. A
+// missing file leaves the level as it is, so the file can be created later.
+func watchLevelFile(path string, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var applied string
+	for {
+		content, err := os.ReadFile(path)
+		if err == nil {
+			name := strings.TrimSpace(string(content))
+			if name != applied {
+				applied = name
+				if l, err := ParseLevel(name); err != nil {
+					log.Printf("WARNING: ignoring %s: %v", path, err)
+				} else {
+					SetLevel(l)
+				}
+			}
+		} else if !os.IsNotExist(err) {
+			log.Printf("WARNING: failed to read log level file %s: %v", path, err)
+		}
+		<-ticker.C
+	}
+}
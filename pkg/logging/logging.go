@@ -0,0 +1,311 @@
+// Package logging adds levels and error rate limiting to the standard
+// logger, which every service logs through. The level can be changed while a
+// service runs, through an admin endpoint or a watched file such as a
+// mounted ConfigMap.
+//
+// Existing log.Printf calls are classified by their message: lines starting
+// with DEBUG, WARN or WARNING, or ERROR have that level, lines mentioning a
+// failure or an error are errors, AUDIT lines are always written and
+// everything else is info. New code can use Debugf, Infof, Warnf and Errorf.
+//
+// Errors are rate limited so that a failing dependency cannot flood the log
+// pipeline: after ErrorBurst lines of the same kind within ErrorInterval,
+// further ones are dropped and counted, and the count is logged when the
+// interval ends. Lines are of the same kind when they match up to their
+// first ": ", which for "Failed to ...: %v" messages is everything but the
+// error.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// Level is the severity of a log line
+type Level int32
+
+// Levels, from the most to the least verbose
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// levelAudit marks audit lines, which are written at every level and never
+// rate limited
+const levelAudit Level = 100
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// String returns the level's name
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return strconv.Itoa(int(l))
+}
+
+// ParseLevel parses debug, info, warn (or warning) or error
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("invalid log level %q, must be debug, info, warn or error", name)
+}
+
+// Config holds logging configuration
+type Config struct {
+	Service string
+	Level   string
+	// ErrorBurst error lines of a kind are written per ErrorInterval; 0
+	// disables rate limiting
+	ErrorBurst    int
+	ErrorInterval time.Duration
+	// LevelFile, if set, is watched every WatchInterval and its content
+	// applied as the level whenever it changes
+	LevelFile     string
+	WatchInterval time.Duration
+	// AdminToken must be presented as a bearer token to change the level
+	// through a service's admin endpoint, which is disabled without it
+	AdminToken string
+}
+
+// LoadConfig loads logging configuration from environment variables
+func LoadConfig(service string) Config {
+	return Config{
+		Service:       getEnv("SERVICE_NAME", service),
+		Level:         getEnv("LOG_LEVEL", "info"),
+		ErrorBurst:    getIntEnv("LOG_ERROR_BURST", 10),
+		ErrorInterval: getDurationEnv("LOG_ERROR_INTERVAL", time.Minute),
+		LevelFile:     getEnv("LOG_LEVEL_FILE", ""),
+		WatchInterval: getDurationEnv("LOG_LEVEL_WATCH_INTERVAL", 10*time.Second),
+		AdminToken:    getEnv("LOG_ADMIN_TOKEN", ""),
+	}
+}
+
+// level is the current level, shared by the filter and the admin endpoint
+var level atomic.Int32
+
+func init() {
+	level.Store(int32(LevelInfo))
+}
+
+// SetLevel changes the level
+func SetLevel(l Level) {
+	previous := Level(level.Swap(int32(l)))
+	if previous != l {
+		log.Printf("AUDIT log level changed from %s to %s", previous, l)
+	}
+}
+
+// CurrentLevel returns the level
+func CurrentLevel() Level {
+	return Level(level.Load())
+}
+
+// Setup filters the standard logger by level and rate limits its errors,
+// and starts watching the level file if one is configured
+func Setup(cfg Config) error {
+	l, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	level.Store(int32(l))
+
+	// The filter writes the timestamp itself, once it has decided to
+	// write a line
+	log.SetFlags(0)
+	log.SetOutput(newFilter(os.Stderr, cfg))
+
+	if cfg.LevelFile != "" {
+		go watchLevelFile(cfg.LevelFile, cfg.WatchInterval)
+	}
+	return nil
+}
+
+// Debugf logs at debug level
+func Debugf(format string, args ...interface{}) {
+	log.Output(2, "DEBUG: "+fmt.Sprintf(format, args...))
+}
+
+// Infof logs at info level
+func Infof(format string, args ...interface{}) {
+	log.Output(2, fmt.Sprintf(format, args...))
+}
+
+// Warnf logs at warn level
+func Warnf(format string, args ...interface{}) {
+	log.Output(2, "WARNING: "+fmt.Sprintf(format, args...))
+}
+
+// Errorf logs at error level
+func Errorf(format string, args ...interface{}) {
+	log.Output(2, "ERROR: "+fmt.Sprintf(format, args...))
+}
+
+// classify returns the level of a log line and its message without a level
+// prefix
+func classify(line string) (Level, string) {
+	switch {
+	case strings.HasPrefix(line, "AUDIT"):
+		return levelAudit, line
+	case strings.HasPrefix(line, "DEBUG"):
+		return LevelDebug, trimLevelPrefix(line, "DEBUG")
+	case strings.HasPrefix(line, "WARNING"):
+		return LevelWarn, trimLevelPrefix(line, "WARNING")
+	case strings.HasPrefix(line, "WARN"):
+		return LevelWarn, trimLevelPrefix(line, "WARN")
+	case strings.HasPrefix(line, "ERROR"):
+		return LevelError, trimLevelPrefix(line, "ERROR")
+	}
+	lower := strings.ToLower(line)
+	if strings.Contains(lower, "failed") || strings.Contains(lower, "error") {
+		return LevelError, line
+	}
+	return LevelInfo, line
+}
+
+func trimLevelPrefix(line, prefix string) string {
+	return strings.TrimLeft(strings.TrimPrefix(line, prefix), ": ")
+}
+
+// maxErrorKinds bounds the kinds of error tracked per interval, so messages
+// that never repeat cannot grow the limiter without bound
+const maxErrorKinds = 1000
+
+// filter is the standard logger's output
+type filter struct {
+	out      io.Writer
+	service  string
+	burst    int
+	interval time.Duration
+
+	mu          sync.Mutex
+	windowStart time.Time
+	counts      map[string]int
+}
+
+func newFilter(out io.Writer, cfg Config) *filter {
+	return &filter{
+		out:      out,
+		service:  cfg.Service,
+		burst:    cfg.ErrorBurst,
+		interval: cfg.ErrorInterval,
+		counts:   make(map[string]int),
+	}
+}
+
+// Write writes a line from the standard logger if its level is enabled and
+// it is not rate limited
+func (f *filter) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+	lineLevel, message := classify(line)
+	if lineLevel < CurrentLevel() {
+		return len(p), nil
+	}
+
+	now := time.Now()
+	var suppressed map[string]int
+	if lineLevel == LevelError && f.burst > 0 {
+		var write bool
+		write, suppressed = f.allow(errorKind(message), now)
+		if !write {
+			metrics.RecordLogSuppressed(f.service)
+			return len(p), nil
+		}
+	}
+
+	var out strings.Builder
+	for kind, count := range suppressed {
+		fmt.Fprintf(&out, "%s Suppressed %d similar errors in the last %s: %s\n",
+			now.Format("2006/01/02 15:04:05"), count, f.interval, kind)
+	}
+	fmt.Fprintf(&out, "%s %s\n", now.Format("2006/01/02 15:04:05"), line)
+	if _, err := io.WriteString(f.out, out.String()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// allow counts an error of a kind and reports whether it is within the
+// burst. When a new interval starts, it returns how many errors of each kind
+// were dropped in the last one.
+func (f *filter) allow(kind string, now time.Time) (bool, map[string]int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var suppressed map[string]int
+	if now.Sub(f.windowStart) >= f.interval || len(f.counts) >= maxErrorKinds {
+		for previous, count := range f.counts {
+			if count > f.burst {
+				if suppressed == nil {
+					suppressed = make(map[string]int)
+				}
+				suppressed[previous] = count - f.burst
+			}
+		}
+		f.counts = make(map[string]int)
+		f.windowStart = now
+	}
+
+	f.counts[kind]++
+	return f.counts[kind] <= f.burst, suppressed
+}
+
+// errorKind is the part of an error message before its first ": "
+func errorKind(message string) string {
+	if i := strings.Index(message, ": "); i > 0 {
+		return message[:i]
+	}
+	if len(message) > 80 {
+		return message[:80]
+	}
+	return message
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
+
+func getIntEnv(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// grpcMethodKey is the context key under which the stats handler keeps the
+// method of an RPC
+type grpcMethodKey struct{}
+
+// grpcMessageStatsHandler records the uncompressed and compressed size of
+// every gRPC message sent and received
+type grpcMessageStatsHandler struct {
+	side string
+}
+
+// NewGRPCMessageStatsHandler creates a stats handler recording message sizes
+// for the given side, "client" or "server"
+func NewGRPCMessageStatsHandler(side string) stats.Handler {
+	return &grpcMessageStatsHandler{side: side}
+}
+
+// TagRPC keeps the method so payload events can be labelled with it
+func (h *grpcMessageStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, grpcMethodKey{}, info.FullMethodName)
+}
+
+// HandleRPC records the size of sent and received messages
+func (h *grpcMessageStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	method, _ := ctx.Value(grpcMethodKey{}).(string)
+	switch payload := s.(type) {
+	case *stats.OutPayload:
+		RecordGRPCMessage(h.side, method, "sent", payload.Length, payload.CompressedLength)
+	case *stats.InPayload:
+		RecordGRPCMessage(h.side, method, "received", payload.Length, payload.CompressedLength)
+	}
+}
+
+// TagConn is a no-op
+func (h *grpcMessageStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn is a no-op
+func (h *grpcMessageStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+// grpcDependencyStatsHandler records every RPC a client makes to a
+// dependency, unary or streaming, once it ends
+type grpcDependencyStatsHandler struct {
+	service    string
+	dependency string
+	isError    func(code codes.Code) bool
+}
+
+// NewGRPCDependencyStatsHandler creates a client stats handler recording
+// requests, errors and durations of the RPCs service makes to dependency.
+// RPCs failing with a code for which isError reports true count as errors.
+func NewGRPCDependencyStatsHandler(service, dependency string, isError func(code codes.Code) bool) stats.Handler {
+	return &grpcDependencyStatsHandler{service: service, dependency: dependency, isError: isError}
+}
+
+// TagRPC keeps the method so the end of the RPC can be labelled with it
+func (h *grpcDependencyStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, grpcMethodKey{}, info.FullMethodName)
+}
+
+// HandleRPC records an RPC when it ends
+func (h *grpcDependencyStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	end, ok := s.(*stats.End)
+	if !ok {
+		return
+	}
+	method, _ := ctx.Value(grpcMethodKey{}).(string)
+	operation := method[strings.LastIndex(method, "/")+1:]
+
+	errorCode := ""
+	if code := status.Code(end.Error); code != codes.OK && h.isError(code) {
+		errorCode = code.String()
+	}
+	RecordDependencyRequest(h.service, h.dependency, operation, errorCode, end.EndTime.Sub(end.BeginTime))
+}
+
+// TagConn is a no-op
+func (h *grpcDependencyStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn is a no-op
+func (h *grpcDependencyStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
@@ -45,6 +45,30 @@ var (
 		[]string{"service", "method"},
 	)
 
+	GRPCMessageBytesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_message_bytes_total",
+			Help: "Total uncompressed size of gRPC messages in bytes",
+		},
+		[]string{"side", "method", "direction"},
+	)
+
+	GRPCMessageCompressedBytesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_message_compressed_bytes_total",
+			Help: "Total size of gRPC messages after compression in bytes",
+		},
+		[]string{"side", "method", "direction"},
+	)
+
+	GRPCMessagesCompressedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_messages_compressed_total",
+			Help: "Total number of gRPC messages sent or received compressed",
+		},
+		[]string{"side", "method", "direction"},
+	)
+
 	// Database metrics
 	DatabaseConnectionsActive = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -54,6 +78,22 @@ var (
 		[]string{"service", "database"},
 	)
 
+	DatabaseUp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "database_up",
+			Help: "Whether the database connection is healthy (1) or broken (0)",
+		},
+		[]string{"service", "database"},
+	)
+
+	DatabaseReconnectsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "database_reconnects_total",
+			Help: "Total number of database reconnects after a broken connection",
+		},
+		[]string{"service", "database"},
+	)
+
 	DatabaseQueryDuration = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "database_query_duration_seconds",
@@ -71,6 +111,39 @@ var (
 		[]string{"service", "operation", "table", "status"},
 	)
 
+	DatabaseSlowQueriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "database_slow_queries_total",
+			Help: "Total number of queries slower than the slow query threshold",
+		},
+		[]string{"service", "table"},
+	)
+
+	DatabaseShardRoutesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "database_shard_routes_total",
+			Help: "Total number of tenant requests routed to a database shard, by how the shard was found",
+		},
+		[]string{"service", "shard", "source"},
+	)
+
+	DatabaseShardQueriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "database_shard_queries_total",
+			Help: "Total number of database queries per shard",
+		},
+		[]string{"service", "shard", "status"},
+	)
+
+	DatabaseShardQueryDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "database_shard_query_duration_seconds",
+			Help:    "Database query duration per shard in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"service", "shard"},
+	)
+
 	// Cache metrics
 	CacheHitsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -88,6 +161,165 @@ var (
 		[]string{"service", "cache_name"},
 	)
 
+	// Whether each cache is switched on (1) or off (0) through the cache
+	// admin API
+	CacheEnabled = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cache_enabled",
+			Help: "Whether the cache is switched on",
+		},
+		[]string{"service", "cache_name"},
+	)
+
+	// Keys deleted through the cache admin API
+	CacheInvalidationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_admin_invalidations_total",
+			Help: "Total number of cache keys invalidated through the admin API",
+		},
+		[]string{"service", "cache_name"},
+	)
+
+	// CDN purges of surrogate keys, by why they were purged (an event type
+	// or "api") and whether the CDN accepted them
+	CDNPurgesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cdn_purges_total",
+			Help: "Total number of CDN purge requests",
+		},
+		[]string{"reason", "status"},
+	)
+
+	CDNPurgedKeysTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cdn_purged_keys_total",
+			Help: "Total number of surrogate keys purged from the CDN",
+		},
+		[]string{"reason"},
+	)
+
+	// Downstream dependency metrics, from the caller's side: requests, errors
+	// and duration (RED) per dependency, e.g. another service or Redis
+	DependencyRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dependency_requests_total",
+			Help: "Total number of requests to downstream dependencies",
+		},
+		[]string{"service", "dependency", "operation"},
+	)
+
+	DependencyErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dependency_errors_total",
+			Help: "Total number of requests to downstream dependencies that failed because of the dependency",
+		},
+		[]string{"service", "dependency", "operation", "code"},
+	)
+
+	DependencyRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "dependency_request_duration_seconds",
+			Help:    "Duration of requests to downstream dependencies in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"service", "dependency", "operation"},
+	)
+
+	// Logging metrics
+	LogMessagesSuppressedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_messages_suppressed_total",
+			Help: "Total number of error log lines dropped by rate limiting",
+		},
+		[]string{"service"},
+	)
+
+	// Build metrics
+	BuildInfo = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "build_info",
+			Help: "Build of the running service, always 1",
+		},
+		[]string{"service", "version", "commit", "build_date", "go_version"},
+	)
+
+	// Cluster configuration reloads by the kind of object read and whether
+	// the configuration was applied or rejected
+	ClusterConfigReloadsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cluster_config_reloads_total",
+			Help: "Total number of configurations read from the cluster, by outcome",
+		},
+		[]string{"kind", "outcome"},
+	)
+
+	// Instances of each service found through service discovery, and failed
+	// lookups by discovery provider
+	DiscoveryInstances = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "discovery_instances",
+			Help: "Number of instances of a service found through service discovery",
+		},
+		[]string{"provider", "service"},
+	)
+
+	DiscoveryErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "discovery_errors_total",
+			Help: "Total number of failed service discovery lookups",
+		},
+		[]string{"provider", "service"},
+	)
+
+	// Watchdog metrics
+	WatchdogGoroutines = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "watchdog_goroutines",
+			Help: "Number of goroutines",
+		},
+		[]string{"service"},
+	)
+
+	WatchdogOpenFDs = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "watchdog_open_fds",
+			Help: "Number of open file descriptors",
+		},
+		[]string{"service"},
+	)
+
+	WatchdogFDLimit = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "watchdog_fd_limit",
+			Help: "Soft limit on open file descriptors",
+		},
+		[]string{"service"},
+	)
+
+	WatchdogThresholdExceeded = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "watchdog_threshold_exceeded",
+			Help: "Whether a watched resource is above its threshold (1) or not (0)",
+		},
+		[]string{"service", "resource"},
+	)
+
+	DatabasePoolSaturation = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "database_pool_saturation",
+			Help: "Share of the connection pool's maximum connections in use",
+		},
+		[]string{"service", "database"},
+	)
+
+	DatabasePoolWaitsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "database_pool_waits_total",
+			Help: "Total number of times a query waited for a pooled connection",
+		},
+		[]string{"service", "database"},
+	)
+
 	// Business metrics
 	UsersTotal = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -148,6 +380,62 @@ var (
 		[]string{"service", "event_type", "status"},
 	)
 
+	EventOutboxSize = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "event_outbox_size",
+			Help: "Number of events buffered in the local outbox",
+		},
+		[]string{"service"},
+	)
+
+	EventsDropped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "events_dropped_total",
+			Help: "Total number of events dropped because the outbox was full",
+		},
+		[]string{"service", "policy"},
+	)
+
+	EventQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "event_queue_depth",
+			Help: "Number of received events waiting for a handler worker",
+		},
+		[]string{"service"},
+	)
+
+	EventDispatchBlocked = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "event_dispatch_blocked_total",
+			Help: "Total number of events that waited for space in a full worker queue",
+		},
+		[]string{"service"},
+	)
+
+	EventPublishBufferDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "event_publish_buffer_depth",
+			Help: "Number of events waiting to be published in the background",
+		},
+		[]string{"service"},
+	)
+
+	EventPublishFallbacks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "event_publish_fallbacks_total",
+			Help: "Total number of background-published events written to the outbox instead, by reason",
+		},
+		[]string{"service", "reason"},
+	)
+
+	EventsReplicated = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "events_replicated_total",
+			Help: "Total number of events forwarded to another region, by status",
+		},
+		[]string{"event_type", "target_region", "status"},
+	)
+
 	EventProcessingDuration = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "event_processing_duration_seconds",
@@ -173,6 +461,216 @@ var (
 		},
 		[]string{"service", "circuit_name", "result"},
 	)
+
+	// Gateway route latency metrics
+	GatewayRouteLatencyP99 = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_route_latency_p99_seconds",
+			Help: "p99 upstream latency of a gateway route over the last check interval",
+		},
+		[]string{"service", "route"},
+	)
+
+	GatewayRouteTimeoutSeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_route_timeout_seconds",
+			Help: "Upstream timeout that applies to a gateway route",
+		},
+		[]string{"service", "route"},
+	)
+
+	GatewayRouteSlow = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_route_slow",
+			Help: "Whether a gateway route's p99 has persistently been near its timeout (1) or not (0)",
+		},
+		[]string{"service", "route"},
+	)
+
+	GatewayErrorBudgetBurnRate = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_error_budget_burn_rate",
+			Help: "Rate at which each service burns its error budget, relative to the sustainable rate, per alerting window",
+		},
+		[]string{"service", "window"},
+	)
+
+	GatewayRouteTimeoutsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_route_timeouts_total",
+			Help: "Total number of gateway requests that hit their upstream timeout",
+		},
+		[]string{"service", "route"},
+	)
+
+	GatewayDeduplicatedRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_deduplicated_requests_total",
+			Help: "Total number of gateway GET requests answered by an identical request's upstream call",
+		},
+		[]string{"service", "route"},
+	)
+
+	GatewayRejectedRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_rejected_requests_total",
+			Help: "Total number of requests the gateway rejected for breaking their route's body limits",
+		},
+		[]string{"service", "route", "reason"},
+	)
+
+	GatewayRetriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_retries_total",
+			Help: "Total number of gateway upstream retries, and of retries skipped because the service's retry budget ran out",
+		},
+		[]string{"service", "outcome"},
+	)
+
+	GatewayFailoverRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_failover_requests_total",
+			Help: "Total number of gateway requests sent to a secondary region while the local instances were down",
+		},
+		[]string{"service", "failover_region"},
+	)
+
+	// Gateway upstream outlier detection metrics
+	GatewayUpstreamEjected = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_upstream_ejected",
+			Help: "Whether an upstream instance is ejected from the balancer (1) or not (0)",
+		},
+		[]string{"service", "instance"},
+	)
+
+	GatewayUpstreamEjectionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_upstream_ejections_total",
+			Help: "Total number of outlier ejections of upstream instances",
+		},
+		[]string{"service", "instance", "reason"},
+	)
+
+	// Gateway upstream balancing metrics
+	GatewayUpstreamHealthy = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_upstream_healthy",
+			Help: "Whether an upstream instance passes its active health checks (1) or not (0)",
+		},
+		[]string{"service", "instance"},
+	)
+
+	GatewayUpstreamInstances = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_upstream_instances",
+			Help: "Number of upstream instances a service resolved to",
+		},
+		[]string{"service"},
+	)
+
+	// Gateway rate limiting metrics
+	GatewayRateLimitWarningsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_rate_limit_warnings_total",
+			Help: "Total number of requests answered with a rate limit warning",
+		},
+		[]string{"tier"},
+	)
+
+	GatewayRateLimitRejectionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_rate_limit_rejections_total",
+			Help: "Total number of requests rejected by rate limiting",
+		},
+		[]string{"tier"},
+	)
+
+	GatewayAnomaliesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_anomalies_total",
+			Help: "Total number of anomalous client traffic patterns detected",
+		},
+		[]string{"kind"},
+	)
+
+	GatewayClientsRestricted = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "gateway_clients_restricted",
+			Help: "Number of clients under a temporary stricter rate limit after an anomaly",
+		},
+	)
+
+	// Gateway soft launch metrics
+	GatewaySoftLaunchRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_soft_launch_requests_total",
+			Help: "Total number of requests to soft launched routes by cohort, including callers excluded from the launch",
+		},
+		[]string{"launch", "cohort", "status_code"},
+	)
+
+	GatewaySoftLaunchRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "gateway_soft_launch_request_duration_seconds",
+			Help:    "Duration of requests to soft launched routes by cohort",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"launch", "cohort"},
+	)
+
+	// Business metric anomaly metrics
+	BusinessAnomaliesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "business_anomalies_total",
+			Help: "Total number of business metric anomalies alerted, by kind",
+		},
+		[]string{"kind"},
+	)
+
+	// SMS metrics
+	SMSMessagesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sms_messages_total",
+			Help: "Total number of SMS messages by outcome: sent, failed, capped or rejected",
+		},
+		[]string{"service", "provider", "status"},
+	)
+
+	SMSSegmentsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sms_segments_total",
+			Help: "Total number of SMS segments sent",
+		},
+		[]string{"service", "provider"},
+	)
+
+	SMSCostDollarsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sms_cost_dollars_total",
+			Help: "Total cost of SMS messages sent, reported by the provider or estimated",
+		},
+		[]string{"service", "provider"},
+	)
+
+	// Notification delivery attempts by channel and outcome: sent, failed,
+	// batched into a digest or skipped by the user's preferences
+	NotificationDeliveriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "notification_deliveries_total",
+			Help: "Total number of notification delivery attempts by channel and outcome",
+		},
+		[]string{"channel", "type", "status"},
+	)
+
+	NotificationDeliveryDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "notification_delivery_duration_seconds",
+			Help:    "Duration of notification deliveries by channel",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"channel"},
+	)
 )
 
 // RecordHTTPRequest records an HTTP request metric
@@ -187,12 +685,87 @@ func RecordGRPCRequest(service, method, statusCode string, duration time.Duratio
 	GRPCRequestDuration.WithLabelValues(service, method).Observe(duration.Seconds())
 }
 
+// RecordGRPCMessage records the size of a gRPC message before and after
+// compression. Both sizes are equal for uncompressed messages.
+func RecordGRPCMessage(side, method, direction string, length, compressedLength int) {
+	GRPCMessageBytesTotal.WithLabelValues(side, method, direction).Add(float64(length))
+	GRPCMessageCompressedBytesTotal.WithLabelValues(side, method, direction).Add(float64(compressedLength))
+	if compressedLength != length {
+		GRPCMessagesCompressedTotal.WithLabelValues(side, method, direction).Inc()
+	}
+}
+
 // RecordDatabaseQuery records a database query metric
 func RecordDatabaseQuery(service, operation, table, status string, duration time.Duration) {
 	DatabaseQueriesTotal.WithLabelValues(service, operation, table, status).Inc()
 	DatabaseQueryDuration.WithLabelValues(service, operation, table).Observe(duration.Seconds())
 }
 
+// RecordSlowQuery records a slow query
+func RecordSlowQuery(service, table string) {
+	DatabaseSlowQueriesTotal.WithLabelValues(service, table).Inc()
+}
+
+// UpdateDatabaseUp updates the database health metric
+func UpdateDatabaseUp(service, database string, up bool) {
+	value := 0.0
+	if up {
+		value = 1
+	}
+	DatabaseUp.WithLabelValues(service, database).Set(value)
+}
+
+// RecordDatabaseReconnect records a database reconnect
+func RecordDatabaseReconnect(service, database string) {
+	DatabaseReconnectsTotal.WithLabelValues(service, database).Inc()
+}
+
+// RecordShardRoute records a tenant routed to a database shard. source is
+// "pinned", "lookup" or "default".
+func RecordShardRoute(service, shard, source string) {
+	DatabaseShardRoutesTotal.WithLabelValues(service, shard, source).Inc()
+}
+
+// RecordShardQuery records a query on a database shard
+func RecordShardQuery(service, shard, status string, duration time.Duration) {
+	DatabaseShardQueriesTotal.WithLabelValues(service, shard, status).Inc()
+	DatabaseShardQueryDuration.WithLabelValues(service, shard).Observe(duration.Seconds())
+}
+
+// SetBuildInfo records the build of the running service
+func SetBuildInfo(service, version, commit, buildDate, goVersion string) {
+	BuildInfo.WithLabelValues(service, version, commit, buildDate, goVersion).Set(1)
+}
+
+// UpdateDatabasePool updates the connection pool metrics. waits is the
+// number of waits since the last update.
+func UpdateDatabasePool(service, database string, inUse int, saturation float64, waits int64) {
+	DatabaseConnectionsActive.WithLabelValues(service, database).Set(float64(inUse))
+	DatabasePoolSaturation.WithLabelValues(service, database).Set(saturation)
+	DatabasePoolWaitsTotal.WithLabelValues(service, database).Add(float64(waits))
+}
+
+// UpdateWatchdog updates the goroutine and file descriptor metrics. fds and
+// fdLimit are negative where they cannot be read.
+func UpdateWatchdog(service string, goroutines, fds, fdLimit int) {
+	WatchdogGoroutines.WithLabelValues(service).Set(float64(goroutines))
+	if fds >= 0 {
+		WatchdogOpenFDs.WithLabelValues(service).Set(float64(fds))
+	}
+	if fdLimit >= 0 {
+		WatchdogFDLimit.WithLabelValues(service).Set(float64(fdLimit))
+	}
+}
+
+// UpdateWatchdogThreshold records whether a resource is above its threshold
+func UpdateWatchdogThreshold(service, resource string, exceeded bool) {
+	value := 0.0
+	if exceeded {
+		value = 1
+	}
+	WatchdogThresholdExceeded.WithLabelValues(service, resource).Set(value)
+}
+
 // RecordCacheHit records a cache hit
 func RecordCacheHit(service, cacheName string) {
 	CacheHitsTotal.WithLabelValues(service, cacheName).Inc()
@@ -203,6 +776,34 @@ func RecordCacheMiss(service, cacheName string) {
 	CacheMissesTotal.WithLabelValues(service, cacheName).Inc()
 }
 
+// RecordCDNPurge records a purge of surrogate keys from the CDN
+func RecordCDNPurge(reason string, keys int, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	CDNPurgesTotal.WithLabelValues(reason, status).Inc()
+	if err == nil {
+		CDNPurgedKeysTotal.WithLabelValues(reason).Add(float64(keys))
+	}
+}
+
+// RecordDependencyRequest records a request to a downstream dependency.
+// errorCode is empty for requests that succeeded, or failed through no fault
+// of the dependency, e.g. a lookup of an unknown ID.
+func RecordDependencyRequest(service, dependency, operation, errorCode string, duration time.Duration) {
+	DependencyRequestsTotal.WithLabelValues(service, dependency, operation).Inc()
+	DependencyRequestDuration.WithLabelValues(service, dependency, operation).Observe(duration.Seconds())
+	if errorCode != "" {
+		DependencyErrorsTotal.WithLabelValues(service, dependency, operation, errorCode).Inc()
+	}
+}
+
+// RecordLogSuppressed records an error log line dropped by rate limiting
+func RecordLogSuppressed(service string) {
+	LogMessagesSuppressedTotal.WithLabelValues(service).Inc()
+}
+
 // RecordOrder records an order metric
 func RecordOrder(status string, value float64) {
 	OrdersTotal.WithLabelValues(status).Inc()
@@ -225,6 +826,43 @@ func RecordEventProcessed(service, eventType, status string, duration time.Durat
 	EventProcessingDuration.WithLabelValues(service, eventType).Observe(duration.Seconds())
 }
 
+// UpdateEventOutboxSize updates the buffered event count
+func UpdateEventOutboxSize(service string, size int) {
+	EventOutboxSize.WithLabelValues(service).Set(float64(size))
+}
+
+// RecordEventDropped records an event dropped by the outbox overflow policy
+func RecordEventDropped(service, policy string) {
+	EventsDropped.WithLabelValues(service, policy).Inc()
+}
+
+// UpdateEventQueueDepth updates the number of events waiting for a worker
+func UpdateEventQueueDepth(service string, depth int64) {
+	EventQueueDepth.WithLabelValues(service).Set(float64(depth))
+}
+
+// RecordEventDispatchBlocked records an event that waited for a full queue
+func RecordEventDispatchBlocked(service string) {
+	EventDispatchBlocked.WithLabelValues(service).Inc()
+}
+
+// UpdateEventPublishBufferDepth updates the number of events waiting to be
+// published in the background
+func UpdateEventPublishBufferDepth(service string, depth int) {
+	EventPublishBufferDepth.WithLabelValues(service).Set(float64(depth))
+}
+
+// RecordEventPublishFallback records an event written to the outbox instead
+// of being published in the background
+func RecordEventPublishFallback(service, reason string) {
+	EventPublishFallbacks.WithLabelValues(service, reason).Inc()
+}
+
+// RecordEventReplicated records an event forwarded to another region
+func RecordEventReplicated(eventType, targetRegion, status string) {
+	EventsReplicated.WithLabelValues(eventType, targetRegion, status).Inc()
+}
+
 // UpdateCircuitBreakerState updates circuit breaker state metric
 func UpdateCircuitBreakerState(service, circuitName string, state int) {
 	CircuitBreakerState.WithLabelValues(service, circuitName).Set(float64(state))
@@ -233,4 +871,34 @@ func UpdateCircuitBreakerState(service, circuitName string, state int) {
 // RecordCircuitBreakerRequest records a circuit breaker request
 func RecordCircuitBreakerRequest(service, circuitName, result string) {
 	CircuitBreakerRequests.WithLabelValues(service, circuitName, result).Inc()
-}
\ No newline at end of file
+}
+
+// RecordSMSMessage records the outcome of sending an SMS message
+func RecordSMSMessage(service, provider, status string) {
+	SMSMessagesTotal.WithLabelValues(service, provider, status).Inc()
+}
+
+// RecordSMSCost records the segments and cost of a sent SMS message
+func RecordSMSCost(service, provider string, segments int, cost float64) {
+	SMSSegmentsTotal.WithLabelValues(service, provider).Add(float64(segments))
+	SMSCostDollarsTotal.WithLabelValues(service, provider).Add(cost)
+}
+
+// RecordNotificationDelivery records a notification delivery attempt
+func RecordNotificationDelivery(channel, notificationType, status string, duration time.Duration) {
+	NotificationDeliveriesTotal.WithLabelValues(channel, notificationType, status).Inc()
+	if duration > 0 {
+		NotificationDeliveryDuration.WithLabelValues(channel).Observe(duration.Seconds())
+	}
+}
+
+// RecordBusinessAnomaly records an alerted business metric anomaly
+func RecordBusinessAnomaly(kind string) {
+	BusinessAnomaliesTotal.WithLabelValues(kind).Inc()
+}
+
+// RecordSoftLaunchRequest records a request to a soft launched route
+func RecordSoftLaunchRequest(launch, cohort, statusCode string, duration time.Duration) {
+	GatewaySoftLaunchRequestsTotal.WithLabelValues(launch, cohort, statusCode).Inc()
+	GatewaySoftLaunchRequestDuration.WithLabelValues(launch, cohort).Observe(duration.Seconds())
+}
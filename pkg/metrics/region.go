@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// regionLabel is the label carrying the region on every served metric
+const regionLabel = "region"
+
+var (
+	regionMu sync.RWMutex
+	region   string
+)
+
+// SetRegion makes Handler label every metric with the region the process
+// runs in, so dashboards of a multi-region deployment can tell the regions
+// apart without each metric declaring the label
+func SetRegion(name string) {
+	regionMu.Lock()
+	defer regionMu.Unlock()
+	region = name
+}
+
+// Handler serves the registered metrics to Prometheus, labelled with the
+// region set by SetRegion
+func Handler() http.Handler {
+	return promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(regionGatherer{prometheus.DefaultGatherer}, promhttp.HandlerOpts{}),
+	)
+}
+
+// regionGatherer adds the region label to the metrics of a gatherer
+type regionGatherer struct {
+	gatherer prometheus.Gatherer
+}
+
+// Gather implements prometheus.Gatherer
+func (g regionGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	regionMu.RLock()
+	value := region
+	regionMu.RUnlock()
+	if value == "" {
+		return families, err
+	}
+
+	name := regionLabel
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			if hasLabel(metric, regionLabel) {
+				continue
+			}
+			metric.Label = append(metric.Label, &dto.LabelPair{Name: &name, Value: &value})
+			sort.Slice(metric.Label, func(i, j int) bool {
+				return metric.Label[i].GetName() < metric.Label[j].GetName()
+			})
+		}
+	}
+	return families, err
+}
+
+// hasLabel reports whether a metric already has a label
+func hasLabel(metric *dto.Metric, label string) bool {
+	for _, pair := range metric.Label {
+		if pair.GetName() == label {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,270 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/metrics"
+)
+
+// Anomaly kinds
+const (
+	AnomalyRequestSpike = "request_spike"
+	AnomalyErrorRatio   = "error_ratio"
+)
+
+// HeaderRateLimitRestricted is set, to the Unix time the restriction ends,
+// while a caller is held to a stricter limit after an anomaly
+const HeaderRateLimitRestricted = "X-RateLimit-Restricted"
+
+// anomalyWarmupIntervals is how many intervals of a client's traffic are seen
+// before its volume is compared to its baseline
+const anomalyWarmupIntervals = 5
+
+// anomalyBaselineWeight is the weight of the latest interval in a client's
+// baseline, an exponentially weighted moving average
+const anomalyBaselineWeight = 0.2
+
+// anomalyIdleIntervals is how many intervals without requests a client is
+// remembered for. A client returning after that starts a new baseline.
+const anomalyIdleIntervals = 60
+
+// AnomalySettings controls per-client anomaly detection. Every Interval, a
+// client with at least MinRequests requests is anomalous if it sent
+// SpikeFactor times its usual volume or ErrorRatio of its requests failed.
+// Anomalous clients are held to RestrictFactor of their rate limit for
+// RestrictDuration; a RestrictFactor of 0 only reports anomalies.
+type AnomalySettings struct {
+	Interval         time.Duration
+	SpikeFactor      float64
+	ErrorRatio       float64
+	MinRequests      int64
+	RestrictFactor   float64
+	RestrictDuration time.Duration
+}
+
+// DefaultAnomalySettings returns default anomaly detection settings
+func DefaultAnomalySettings() AnomalySettings {
+	return AnomalySettings{
+		Interval:         time.Minute,
+		SpikeFactor:      10,
+		ErrorRatio:       0.5,
+		MinRequests:      50,
+		RestrictFactor:   0.1,
+		RestrictDuration: 15 * time.Minute,
+	}
+}
+
+// clientTraffic is one client's traffic in the current interval and its
+// history
+type clientTraffic struct {
+	tier     string
+	requests int64
+	errors   int64
+
+	baseline  float64
+	intervals int
+	idle      int
+
+	restrictedUntil time.Time
+}
+
+// AnomalyDetector watches the traffic of each client, keyed like the rate
+// limiter, for sudden request spikes and unusual error ratios. Anomalies are
+// published as security.anomaly events and can put the client under a
+// temporary stricter rate limit. Traffic is tracked per gateway instance.
+type AnomalyDetector struct {
+	settings AnomalySettings
+	bus      events.EventBus
+
+	mu      sync.Mutex
+	clients map[string]*clientTraffic
+}
+
+// NewAnomalyDetector creates a new anomaly detector. bus may be nil, in which
+// case anomalies are only logged.
+func NewAnomalyDetector(settings AnomalySettings, bus events.EventBus) *AnomalyDetector {
+	defaults := DefaultAnomalySettings()
+	if settings.Interval <= 0 {
+		settings.Interval = defaults.Interval
+	}
+	if settings.SpikeFactor <= 1 {
+		settings.SpikeFactor = defaults.SpikeFactor
+	}
+	if settings.ErrorRatio <= 0 || settings.ErrorRatio > 1 {
+		settings.ErrorRatio = defaults.ErrorRatio
+	}
+	if settings.MinRequests <= 0 {
+		settings.MinRequests = defaults.MinRequests
+	}
+	if settings.RestrictDuration <= 0 {
+		settings.RestrictDuration = defaults.RestrictDuration
+	}
+
+	return &AnomalyDetector{
+		settings: settings,
+		bus:      bus,
+		clients:  make(map[string]*clientTraffic),
+	}
+}
+
+// Observe records a client's request and its response status. Client errors
+// other than 429 count as failures: they are what credential stuffing and
+// endpoint scanning produce.
+func (d *AnomalyDetector) Observe(key, tier string, status int) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, ok := d.clients[key]
+	if !ok {
+		c = &clientTraffic{}
+		d.clients[key] = c
+	}
+	c.tier = tier
+	c.requests++
+	if status >= http.StatusBadRequest && status < http.StatusInternalServerError && status != http.StatusTooManyRequests {
+		c.errors++
+	}
+}
+
+// Limit returns the rate limit to apply to a client: limit, or a fraction of
+// it while the client is restricted, along with the end of the restriction
+func (d *AnomalyDetector) Limit(key string, limit int) (int, time.Time) {
+	if d == nil || d.settings.RestrictFactor <= 0 {
+		return limit, time.Time{}
+	}
+
+	d.mu.Lock()
+	c, ok := d.clients[key]
+	var until time.Time
+	if ok && time.Now().Before(c.restrictedUntil) {
+		until = c.restrictedUntil
+	}
+	d.mu.Unlock()
+
+	if until.IsZero() {
+		return limit, until
+	}
+	restricted := int(float64(limit) * d.settings.RestrictFactor)
+	if restricted < 1 {
+		restricted = 1
+	}
+	return restricted, until
+}
+
+// Start checks client traffic every interval until ctx is cancelled
+func (d *AnomalyDetector) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.settings.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.report(ctx, d.check())
+		}
+	}
+}
+
+// check closes the current interval: it compares each client's traffic with
+// its baseline, restricts anomalous clients and starts a new interval
+func (d *AnomalyDetector) check() []*events.SecurityAnomalyReport {
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var anomalies []*events.SecurityAnomalyReport
+	restricted := 0
+	for key, c := range d.clients {
+		if c.requests == 0 {
+			c.idle++
+			if c.idle >= anomalyIdleIntervals && !now.Before(c.restrictedUntil) {
+				delete(d.clients, key)
+				continue
+			}
+		} else {
+			c.idle = 0
+
+			var kinds []string
+			if c.requests >= d.settings.MinRequests {
+				if c.intervals >= anomalyWarmupIntervals && float64(c.requests) >= d.settings.SpikeFactor*c.baseline {
+					kinds = append(kinds, AnomalyRequestSpike)
+				}
+				if float64(c.errors) >= d.settings.ErrorRatio*float64(c.requests) {
+					kinds = append(kinds, AnomalyErrorRatio)
+				}
+			}
+
+			if len(kinds) > 0 && d.settings.RestrictFactor > 0 {
+				c.restrictedUntil = now.Add(d.settings.RestrictDuration)
+			}
+			for _, kind := range kinds {
+				report := &events.SecurityAnomalyReport{
+					Client:   key,
+					Tier:     c.tier,
+					Kind:     kind,
+					Requests: c.requests,
+					Errors:   c.errors,
+					Baseline: c.baseline,
+					Interval: d.settings.Interval,
+				}
+				if d.settings.RestrictFactor > 0 {
+					report.RestrictedUntil = c.restrictedUntil
+				}
+				anomalies = append(anomalies, report)
+			}
+
+			// A spike is left out of the baseline so a sustained one keeps
+			// being reported rather than becoming the norm
+			switch {
+			case c.intervals == 0:
+				c.baseline = float64(c.requests)
+			case len(kinds) == 0:
+				c.baseline += anomalyBaselineWeight * (float64(c.requests) - c.baseline)
+			}
+			c.intervals++
+			c.requests = 0
+			c.errors = 0
+		}
+
+		if now.Before(c.restrictedUntil) {
+			restricted++
+		}
+	}
+	metrics.GatewayClientsRestricted.Set(float64(restricted))
+
+	return anomalies
+}
+
+// report logs and publishes detected anomalies. Publishing is best effort;
+// the restriction applies either way.
+func (d *AnomalyDetector) report(ctx context.Context, anomalies []*events.SecurityAnomalyReport) {
+	for _, anomaly := range anomalies {
+		restriction := "none"
+		if !anomaly.RestrictedUntil.IsZero() {
+			restriction = anomaly.RestrictedUntil.Format(time.RFC3339)
+		}
+		log.Printf("SECURITY anomaly: client=%s tier=%s kind=%s requests=%d errors=%d baseline=%.1f restricted_until=%s",
+			anomaly.Client, anomaly.Tier, anomaly.Kind, anomaly.Requests, anomaly.Errors, anomaly.Baseline, restriction)
+		metrics.GatewayAnomaliesTotal.WithLabelValues(anomaly.Kind).Inc()
+
+		if d.bus == nil {
+			continue
+		}
+		publishCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := d.bus.Publish(publishCtx, events.NewSecurityAnomalyEvent("api-gateway", anomaly)); err != nil {
+			log.Printf("Failed to publish security anomaly for %s: %v", anomaly.Client, err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/approvals"
+)
+
+// maxApprovalBodySize is the largest request body that can be held for
+// approval
+const maxApprovalBodySize = 1 << 20
+
+// ApprovalRule decides whether an admin's request needs a second admin's
+// approval, given the request, the admin's user ID and the request body
+type ApprovalRule func(c *gin.Context, adminID string, body []byte) bool
+
+// RequireApproval holds admin requests that rule matches until a second admin
+// approves them. Instead of running, such a request is recorded as a pending
+// action, named by rule, and answered with 202 Accepted and a Location header
+// pointing at the action. Requests by other roles, and requests run for an
+// approved action, pass through. If store is nil, requests that need approval
+// are refused rather than run.
+func RequireApproval(jwtSecret string, store approvals.Store, ttl time.Duration, name string, rule ApprovalRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, approved := approvals.Approved(c.Request.Context()); approved {
+			c.Next()
+			return
+		}
+
+		claims, err := parseBearerClaims(c.GetHeader("Authorization"), jwtSecret)
+		if err != nil {
+			c.Next()
+			return
+		}
+		if !claims.HasRole("admin") {
+			c.Next()
+			return
+		}
+		adminID := claims.UserID
+
+		var body []byte
+		if c.Request.Body != nil {
+			body, err = io.ReadAll(io.LimitReader(c.Request.Body, maxApprovalBodySize+1))
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Failed to read request body"})
+				c.Abort()
+				return
+			}
+			if len(body) > maxApprovalBodySize {
+				c.JSON(413, gin.H{"error": "Request body too large"})
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		if !rule(c, adminID, body) {
+			c.Next()
+			return
+		}
+
+		if len(bytes.TrimSpace(body)) > 0 && !json.Valid(body) {
+			c.JSON(400, gin.H{"error": "Requests that need approval must have a JSON body"})
+			c.Abort()
+			return
+		}
+		if store == nil {
+			c.JSON(503, gin.H{"error": "This request needs a second admin's approval, which is unavailable"})
+			c.Abort()
+			return
+		}
+
+		action := approvals.New(name, c.Request.Method, c.Request.URL.RequestURI(), c.ContentType(), body, adminID, ttl)
+		if err := store.Create(c.Request.Context(), action); err != nil {
+			log.Printf("Failed to record action for approval: %v", err)
+			c.JSON(503, gin.H{"error": "This request needs a second admin's approval, which is unavailable"})
+			c.Abort()
+			return
+		}
+
+		log.Printf("AUDIT approval requested: action=%s rule=%s admin=%s method=%s path=%s expires=%s",
+			action.ID, name, adminID, action.Method, action.Path, action.ExpiresAt.Format(time.RFC3339))
+
+		c.Header("Location", "/api/v1/admin/actions/"+action.ID)
+		c.JSON(202, gin.H{
+			"message": "This request needs a second admin's approval before it runs",
+			"action":  action,
+		})
+		c.Abort()
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/instrumentation"
+)
+
+// BusinessBaggageMiddleware puts the request's business context, its tenant,
+// the order it is about and the user's language, in the trace baggage, so the
+// services it calls record them on their spans. Business baggage the client
+// sent is dropped. It must run after TenantMiddleware and LanguageMiddleware.
+func BusinessBaggageMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := instrumentation.WithoutBusinessContext(c.Request.Context())
+
+		var orderID string
+		if strings.Contains(c.FullPath(), "/orders/:id") {
+			orderID = c.Param("id")
+		}
+		ctx = instrumentation.WithBusinessContext(ctx,
+			instrumentation.BaggageTenantID, c.GetString("tenant_id"),
+			instrumentation.BaggageOrderID, orderID,
+			instrumentation.BaggageLanguage, c.GetString("language"),
+		)
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"encoding/json"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims are the claims of the tokens user-service issues. Claims added by
+// enrichers without a field of their own are kept in Custom.
+type Claims struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email,omitempty"`
+	Role   string `json:"role,omitempty"`
+	// Roles lists every role the user holds: Role, plus a role per
+	// organization as "org:<organization>:<role>"
+	Roles    []string `json:"roles,omitempty"`
+	TenantID string   `json:"tenant_id,omitempty"`
+	Plan     string   `json:"plan,omitempty"`
+	// Tier overrides Role as the rate limit tier
+	Tier     string   `json:"tier,omitempty"`
+	Features []string `json:"features,omitempty"`
+	Flags    []string `json:"flags,omitempty"`
+
+	// The user's preferences for formatting dates: a language tag such as
+	// "de-DE" and an IANA time zone such as "Europe/Berlin"
+	Locale   string `json:"locale,omitempty"`
+	TimeZone string `json:"zoneinfo,omitempty"`
+
+	// Impersonation tokens carry their session and the admin acting
+	Scope     string `json:"scope,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	Actor     *Actor `json:"act,omitempty"`
+
+	Custom map[string]interface{} `json:"-"`
+
+	jwt.RegisteredClaims
+}
+
+// Actor is the admin acting through an impersonation token
+type Actor struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+}
+
+// Tenant returns the tenant a token acts for: its tenant, or the user itself
+// when the token names no tenant
+func (c *Claims) Tenant() string {
+	if c.TenantID != "" {
+		return c.TenantID
+	}
+	return c.UserID
+}
+
+// HasRole reports whether the token's user holds role
+func (c *Claims) HasRole(role string) bool {
+	return c.Role == role || containsString(c.Roles, role)
+}
+
+// HasFeature reports whether the token's plan includes feature. Features are
+// as of when the token was issued; check the plan itself where that matters.
+func (c *Claims) HasFeature(feature string) bool {
+	return containsString(c.Features, feature)
+}
+
+// HasFlag reports whether a feature flag is on for the token's user
+func (c *Claims) HasFlag(flag string) bool {
+	return containsString(c.Flags, flag)
+}
+
+// Impersonating reports whether the token is an impersonation token
+func (c *Claims) Impersonating() bool {
+	return c.Actor != nil
+}
+
+// Set sets a custom claim
+func (c *Claims) Set(name string, value interface{}) {
+	if c.Custom == nil {
+		c.Custom = make(map[string]interface{})
+	}
+	c.Custom[name] = value
+}
+
+// claimsFields is Claims without its JSON methods
+type claimsFields Claims
+
+// MarshalJSON encodes the claims with the custom ones beside them. A custom
+// claim never replaces a claim with a field.
+func (c Claims) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(claimsFields(c))
+	if err != nil || len(c.Custom) == 0 {
+		return data, err
+	}
+
+	var all map[string]interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	for name, value := range c.Custom {
+		if _, ok := all[name]; !ok && !knownClaims[name] {
+			all[name] = value
+		}
+	}
+	return json.Marshal(all)
+}
+
+// UnmarshalJSON decodes the claims, keeping those without a field in Custom
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*claimsFields)(c)); err != nil {
+		return err
+	}
+
+	var all map[string]interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	for name := range all {
+		if knownClaims[name] {
+			delete(all, name)
+		}
+	}
+	c.Custom = nil
+	if len(all) > 0 {
+		c.Custom = all
+	}
+	return nil
+}
+
+// knownClaims are the claims with a field in Claims
+var knownClaims = map[string]bool{
+	"user_id": true, "email": true, "role": true, "roles": true, "tenant_id": true,
+	"plan": true, "tier": true, "features": true, "flags": true,
+	"locale": true, "zoneinfo": true,
+	"scope": true, "sid": true, "act": true,
+	"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true, "iat": true, "jti": true,
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
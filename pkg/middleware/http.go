@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -8,9 +9,11 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -19,7 +22,9 @@ func TracingMiddleware(serviceName string) gin.HandlerFunc {
 	tracer := otel.Tracer(serviceName)
 	
 	return func(c *gin.Context) {
-		ctx, span := tracer.Start(c.Request.Context(), c.FullPath(),
+		// Continue the client's trace, if it sent one, with its baggage
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracer.Start(ctx, c.FullPath(),
 			trace.WithAttributes(
 				attribute.String("http.method", c.Request.Method),
 				attribute.String("http.url", c.Request.URL.String()),
@@ -63,55 +68,52 @@ func recordHTTPMetrics(method, path string, statusCode int, duration time.Durati
 	log.Printf("HTTP %s %s - Status: %d - Duration: %v", method, path, statusCode, duration)
 }
 
-// RateLimitMiddleware implements rate limiting
-func RateLimitMiddleware(requestsPerMinute int) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Simple in-memory rate limiting
-		// In production, use Redis-based rate limiting
-		c.Next()
-	}
-}
-
-// AuthMiddleware validates JWT tokens
+// AuthMiddleware verifies the signature and expiry of the bearer token and
+// stores the caller's claims in the gin context: "user_id", "email", "role",
+// "roles" and the *Claims themselves as "claims". Proxies and services can
+// trust these, as requests with a missing, forged or expired token are
+// rejected here. jwtSecret is the HS256 secret, or a PEM-encoded RSA public
+// key to verify RS256 tokens.
 func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-		if token == "" {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
 			c.JSON(401, gin.H{"error": "Authorization header required"})
 			c.Abort()
 			return
 		}
 
-		// Remove "Bearer " prefix
-		if len(token) > 7 && token[:7] == "Bearer " {
-			token = token[7:]
-		}
-
-		// Validate JWT token (simplified)
-		if !validateJWT(token, jwtSecret) {
-			c.JSON(401, gin.H{"error": "Invalid token"})
+		claims, err := parseBearerClaims(authHeader, jwtSecret)
+		if err != nil {
+			message := "Invalid token"
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				message = "Token expired"
+			}
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.JSON(401, gin.H{"error": message})
 			c.Abort()
 			return
 		}
 
+		c.Set("user_id", claims.UserID)
+		c.Set("email", claims.Email)
+		c.Set("role", claims.Role)
+		c.Set("roles", claims.Roles)
+		c.Set("claims", claims)
+
 		c.Next()
 	}
 }
 
-// validateJWT validates a JWT token (simplified implementation)
-func validateJWT(token, secret string) bool {
-	// This should properly validate the JWT token
-	// For now, just check if it's not empty
-	return token != ""
-}
-
 // CORSMiddleware handles Cross-Origin Resource Sharing
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, If-Match")
 		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID, "+HeaderImpersonationActive+", "+HeaderImpersonatedBy+", "+HeaderImpersonationExpiry+
+			", "+HeaderRateLimitLimit+", "+HeaderRateLimitRemaining+", "+HeaderRateLimitReset+", "+HeaderRateLimitTier+", "+HeaderRateLimitWarning+", "+HeaderRateLimitBackoff+", "+HeaderRateLimitRestricted+", Retry-After, X-Operation-ID, Location, ETag")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
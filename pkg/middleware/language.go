@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/i18n"
+)
+
+// translatedFields are the fields of JSON error responses holding messages
+var translatedFields = []string{"error", "message"}
+
+// LanguageMiddleware picks the response language from the Accept-Language
+// header and stores it as "language", for BusinessBaggageMiddleware to pass
+// on to services. The gateway's own error messages are translated into it;
+// services localize theirs.
+func LanguageMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		language := i18n.Negotiate(c.GetHeader("Accept-Language"))
+		c.Set("language", language)
+		c.Header("Content-Language", language)
+		c.Writer.Header().Add("Vary", "Accept-Language")
+		if language == i18n.DefaultLanguage {
+			c.Next()
+			return
+		}
+
+		writer := &translatingWriter{ResponseWriter: c.Writer, language: language}
+		c.Writer = writer
+		c.Next()
+		writer.flush()
+	}
+}
+
+// translatingWriter holds back JSON error responses until the handler is
+// done, so their messages can be translated
+type translatingWriter struct {
+	gin.ResponseWriter
+	language string
+	buffer   *bytes.Buffer
+}
+
+// holding reports whether the response is a JSON error response
+func (w *translatingWriter) holding() bool {
+	if w.buffer != nil {
+		return true
+	}
+	if w.ResponseWriter.Written() || w.Status() < http.StatusBadRequest || w.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+func (w *translatingWriter) Write(data []byte) (int, error) {
+	if !w.holding() {
+		return w.ResponseWriter.Write(data)
+	}
+	if w.buffer == nil {
+		w.buffer = &bytes.Buffer{}
+	}
+	return w.buffer.Write(data)
+}
+
+func (w *translatingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *translatingWriter) WriteHeaderNow() {
+	if !w.holding() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *translatingWriter) Flush() {
+	if !w.holding() {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// flush writes a held back response with its messages translated
+func (w *translatingWriter) flush() {
+	if w.buffer == nil {
+		return
+	}
+	body := translateMessages(w.buffer.Bytes(), w.language)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.Write(body)
+}
+
+// translateMessages translates the messages of a JSON error response that
+// have a translation, leaving the rest of it as it is
+func translateMessages(body []byte, language string) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body
+	}
+
+	translated := false
+	for _, name := range translatedFields {
+		var message string
+		if err := json.Unmarshal(fields[name], &message); err != nil {
+			continue
+		}
+		if text, ok := i18n.Lookup(language, message); ok {
+			fields[name], _ = json.Marshal(text)
+			translated = true
+		}
+	}
+	if !translated {
+		return body
+	}
+	if data, err := json.Marshal(fields); err == nil {
+		return data
+	}
+	return body
+}
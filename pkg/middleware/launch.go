@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// Cohorts a soft launched route is open to, recorded in its metrics.
+// CohortExcluded counts the callers turned away.
+const (
+	CohortTenant   = "tenant"
+	CohortUser     = "user"
+	CohortFlag     = "flag"
+	CohortRollout  = "rollout"
+	CohortExcluded = "excluded"
+)
+
+// SoftLaunch is who a soft launched route is open to: the listed tenants and
+// users, callers whose token carries the launch's feature flag, and Percent
+// of all users. The same users stay in a rollout as Percent grows. Everyone
+// else gets 404, or 403 if the launch is Visible.
+type SoftLaunch struct {
+	Tenants []string
+	Users   []string
+	Percent int
+	Visible bool
+}
+
+// SoftLaunches gates routes that are not yet open to everyone. Launches are
+// named after the feature flag that opens them, so FEATURE_FLAGS in
+// user-service can open a launch by role; a launch without settings is open
+// only to flag holders.
+type SoftLaunches struct {
+	jwtSecret string
+
+	launches map[string]SoftLaunch
+
+	mu        sync.RWMutex
+	overrides map[string]SoftLaunch
+}
+
+// NewSoftLaunches creates soft launch gates from the settings of each launch
+func NewSoftLaunches(jwtSecret string, launches map[string]SoftLaunch) *SoftLaunches {
+	return &SoftLaunches{jwtSecret: jwtSecret, launches: launches}
+}
+
+// Override changes who launches are open to while the gateway runs,
+// replacing the overrides set before. Launches without an override keep the
+// settings they were created with.
+func (s *SoftLaunches) Override(launches map[string]SoftLaunch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides = launches
+}
+
+// settings returns who a launch is open to
+func (s *SoftLaunches) settings(launch string) SoftLaunch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if settings, ok := s.overrides[launch]; ok {
+		return settings
+	}
+	return s.launches[launch]
+}
+
+// Gate lets through only callers in a cohort of the launch, and records the
+// requests, their status and latency by cohort so the launch can be compared
+// with the routes it replaces. Gate runs before authentication, so a hidden
+// route stays hidden from callers without a token too.
+func (s *SoftLaunches) Gate(launch string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		settings := s.settings(launch)
+		cohort := CohortExcluded
+		if claims, err := parseBearerClaims(c.GetHeader("Authorization"), s.jwtSecret); err == nil {
+			cohort = settings.cohort(launch, claims)
+		}
+
+		if cohort == CohortExcluded {
+			if settings.Visible {
+				c.JSON(http.StatusForbidden, gin.H{"error": "This feature is not available to you yet", "feature": launch})
+			} else {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			}
+			c.Abort()
+		} else {
+			c.Set("launch_cohort", cohort)
+			c.Next()
+		}
+
+		metrics.RecordSoftLaunchRequest(launch, cohort, strconv.Itoa(c.Writer.Status()), time.Since(start))
+	}
+}
+
+// cohort returns the cohort of the launch the caller is in
+func (l SoftLaunch) cohort(launch string, claims *Claims) string {
+	switch {
+	case containsString(l.Tenants, claims.Tenant()):
+		return CohortTenant
+	case containsString(l.Users, claims.UserID):
+		return CohortUser
+	case claims.HasFlag(launch):
+		return CohortFlag
+	case claims.UserID != "" && rolloutBucket(launch, claims.UserID) < l.Percent:
+		return CohortRollout
+	}
+	return CohortExcluded
+}
+
+// rolloutBucket places a user in one of 100 buckets for a launch. Hashing the
+// launch name in gives each launch its own early users.
+func rolloutBucket(launch, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(launch + ":" + userID))
+	return int(h.Sum32() % 100)
+}
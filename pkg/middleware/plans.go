@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/plans"
+)
+
+// RequirePlanFeature allows a request through only if the caller's tenant is
+// subscribed to a plan that includes the feature. Plan lookup errors fail
+// closed, since the feature may be a paid one.
+func RequirePlanFeature(lookup plans.Lookup, jwtSecret, feature string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := parseBearerClaims(c.GetHeader("Authorization"), jwtSecret)
+		if err != nil {
+			c.JSON(401, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+
+		limits, err := lookup.Get(c.Request.Context(), claims.Tenant())
+		if err != nil {
+			log.Printf("Plan limits unavailable, denying feature %s: %v", feature, err)
+			c.JSON(503, gin.H{"error": "Plan information is unavailable"})
+			c.Abort()
+			return
+		}
+		if limits == nil || !limits.HasFeature(feature) {
+			c.JSON(403, gin.H{"error": "Your plan does not include this feature", "feature": feature})
+			c.Abort()
+			return
+		}
+
+		c.Set("plan", limits.Plan)
+		c.Next()
+	}
+}
+
+// TenantMiddleware stores the tenant a valid bearer token acts for as
+// "tenant_id", for tenant routing and identity propagation, along with the
+// user's "locale" and "time_zone" preferences. Requests without a valid token
+// pass through without a tenant.
+func TenantMiddleware(jwtSecret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if claims, err := parseBearerClaims(c.GetHeader("Authorization"), jwtSecret); err == nil {
+			if tenantID := claims.Tenant(); tenantID != "" {
+				c.Set("tenant_id", tenantID)
+			}
+			if claims.Locale != "" {
+				c.Set("locale", claims.Locale)
+			}
+			if claims.TimeZone != "" {
+				c.Set("time_zone", claims.TimeZone)
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,461 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/metrics"
+	"microservices-platform/pkg/plans"
+	"microservices-platform/pkg/redisclient"
+)
+
+// Rate limit tiers, from least to most generous
+const (
+	TierAnonymous = "anonymous"
+	TierUser      = "user"
+	TierPartner   = "partner"
+	TierAdmin     = "admin"
+)
+
+// Rate limit response headers
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+	HeaderRateLimitTier      = "X-RateLimit-Tier"
+	// HeaderRateLimitWarning and HeaderRateLimitBackoff are only set once a
+	// caller nears its limit. The backoff is the delay in seconds between
+	// requests that would spread the remaining quota over the window.
+	HeaderRateLimitWarning = "X-RateLimit-Warning"
+	HeaderRateLimitBackoff = "X-RateLimit-Backoff"
+)
+
+// RateLimitPolicy defines the requests allowed per window for each tier.
+// When Plans is set, a tenant's subscription plan can set its own limit.
+// Callers that have used WarningThreshold of their limit, e.g. 0.8, get
+// warning headers; 0 disables warnings. When Anomalies is set it watches each
+// caller's traffic and may lower the limit of a caller behaving unusually.
+// Overrides, when set, replace the window and tier limits while the gateway
+// runs.
+type RateLimitPolicy struct {
+	Window           time.Duration
+	Tiers            map[string]int
+	Plans            plans.Lookup
+	WarningThreshold float64
+	Anomalies        *AnomalyDetector
+	Overrides        *RateLimitOverrides
+}
+
+// RateLimitOverrides replaces a policy's window and tier limits at runtime,
+// e.g. from cluster configuration. Tiers without an override keep the
+// policy's limit.
+type RateLimitOverrides struct {
+	mu     sync.RWMutex
+	window time.Duration
+	tiers  map[string]int
+}
+
+// NewRateLimitOverrides creates overrides that override nothing yet
+func NewRateLimitOverrides() *RateLimitOverrides {
+	return &RateLimitOverrides{}
+}
+
+// Set replaces the overrides. A zero window keeps the policy's.
+func (o *RateLimitOverrides) Set(window time.Duration, tiers map[string]int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.window = window
+	o.tiers = tiers
+}
+
+// Window returns the overridden window, or base
+func (o *RateLimitOverrides) Window(base time.Duration) time.Duration {
+	if o == nil {
+		return base
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if o.window > 0 {
+		return o.window
+	}
+	return base
+}
+
+// Limit returns the limit of a tier, overridden or from base
+func (o *RateLimitOverrides) Limit(base map[string]int, tier string) (int, bool) {
+	if o != nil {
+		o.mu.RLock()
+		limit, ok := o.tiers[tier]
+		o.mu.RUnlock()
+		if ok {
+			return limit, true
+		}
+	}
+	limit, ok := base[tier]
+	return limit, ok
+}
+
+// Tiers returns the limit of every tier, with the overrides applied to base
+func (o *RateLimitOverrides) Tiers(base map[string]int) map[string]int {
+	if o == nil {
+		return base
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	tiers := make(map[string]int, len(base)+len(o.tiers))
+	for tier, limit := range base {
+		tiers[tier] = limit
+	}
+	for tier, limit := range o.tiers {
+		tiers[tier] = limit
+	}
+	return tiers
+}
+
+// RateLimitResult is the outcome of counting a request against a limit
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// UsageBucket is the number of requests a caller made in one hour
+type UsageBucket struct {
+	Hour     time.Time `json:"hour"`
+	Requests int64     `json:"requests"`
+}
+
+// RateLimiter counts requests per key in fixed windows and reports usage
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error)
+	// Current returns the requests counted for the key's current window
+	Current(ctx context.Context, key string, window time.Duration) (int64, error)
+	// History returns hourly request counts since the given time, oldest first
+	History(ctx context.Context, key string, since time.Time) ([]UsageBucket, error)
+}
+
+// UsageRetention is how long hourly usage history is kept
+const UsageRetention = 7 * 24 * time.Hour
+
+// RedisRateLimiter implements RateLimiter with Redis counters so limits are
+// shared by every gateway instance
+type RedisRateLimiter struct {
+	client redis.UniversalClient
+}
+
+// NewRedisRateLimiter creates a new Redis-backed rate limiter. Counter keys
+// use the caller key as a hash tag so a caller's counters share a cluster slot.
+func NewRedisRateLimiter(cfg config.RedisConfig) (*RedisRateLimiter, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisRateLimiter{client: client}, nil
+}
+
+// Allow increments the counter for the key's current window
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	windowStart := time.Now().Truncate(window)
+	reset := windowStart.Add(window)
+	redisKey := rateLimitRedisKey(key, windowStart)
+
+	hour := time.Now().Truncate(time.Hour)
+	usageKey := usageRedisKey(key, hour)
+
+	pipe := l.client.TxPipeline()
+	incr := pipe.Incr(ctx, redisKey)
+	pipe.ExpireAt(ctx, redisKey, reset)
+	pipe.Incr(ctx, usageKey)
+	pipe.ExpireAt(ctx, usageKey, hour.Add(UsageRetention))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return newRateLimitResult(int(incr.Val()), limit, reset), nil
+}
+
+// Current returns the requests counted for the key's current window
+func (l *RedisRateLimiter) Current(ctx context.Context, key string, window time.Duration) (int64, error) {
+	windowStart := time.Now().Truncate(window)
+	count, err := l.client.Get(ctx, rateLimitRedisKey(key, windowStart)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return count, err
+}
+
+// History returns hourly request counts since the given time, oldest first
+func (l *RedisRateLimiter) History(ctx context.Context, key string, since time.Time) ([]UsageBucket, error) {
+	var hours []time.Time
+	var keys []string
+	for hour := since.Truncate(time.Hour); !hour.After(time.Now()); hour = hour.Add(time.Hour) {
+		hours = append(hours, hour)
+		keys = append(keys, usageRedisKey(key, hour))
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values, err := l.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]UsageBucket, len(hours))
+	for i, hour := range hours {
+		buckets[i].Hour = hour.UTC()
+		if value, ok := values[i].(string); ok {
+			buckets[i].Requests, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+
+	return buckets, nil
+}
+
+func rateLimitRedisKey(key string, windowStart time.Time) string {
+	return fmt.Sprintf("ratelimit:{%s}:%d", key, windowStart.Unix())
+}
+
+func usageRedisKey(key string, hour time.Time) string {
+	return fmt.Sprintf("usage:{%s}:%d", key, hour.Unix())
+}
+
+// MemoryRateLimiter implements RateLimiter in process memory. Limits are per
+// gateway instance, so it is only a fallback when Redis is unavailable.
+// Counters of ended windows are dropped by Start.
+type MemoryRateLimiter struct {
+	mu       sync.Mutex
+	counters map[string]*memoryCounter
+}
+
+type memoryCounter struct {
+	count int
+	reset time.Time
+}
+
+// NewMemoryRateLimiter creates a new in-memory rate limiter
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		counters: make(map[string]*memoryCounter),
+	}
+}
+
+// Allow increments the counter for the key's current window
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	counter, exists := l.counters[key]
+	if !exists || !now.Before(counter.reset) {
+		counter = &memoryCounter{reset: now.Truncate(window).Add(window)}
+		l.counters[key] = counter
+	}
+	counter.count++
+
+	return newRateLimitResult(counter.count, limit, counter.reset), nil
+}
+
+// Start drops the counters of ended windows every interval until ctx is
+// cancelled, so memory stays bounded by the keys seen in a window
+func (l *MemoryRateLimiter) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.sweep(time.Now())
+		}
+	}
+}
+
+// sweep drops the counters whose window ended before now
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, counter := range l.counters {
+		if !now.Before(counter.reset) {
+			delete(l.counters, key)
+		}
+	}
+}
+
+// Current returns the requests counted for the key's current window
+func (l *MemoryRateLimiter) Current(ctx context.Context, key string, window time.Duration) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	counter, exists := l.counters[key]
+	if !exists || !time.Now().Before(counter.reset) {
+		return 0, nil
+	}
+	return int64(counter.count), nil
+}
+
+// History is not tracked in memory; only the Redis limiter keeps usage history
+func (l *MemoryRateLimiter) History(ctx context.Context, key string, since time.Time) ([]UsageBucket, error) {
+	return nil, nil
+}
+
+func newRateLimitResult(count, limit int, reset time.Time) *RateLimitResult {
+	remaining := limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return &RateLimitResult{
+		Allowed:   count <= limit,
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     reset,
+	}
+}
+
+// TieredRateLimitMiddleware limits requests per caller, with the limit chosen
+// by the caller's subscription tier or role. Callers without a valid token are
+// limited per IP address on the anonymous tier. Redis errors fail open.
+func TieredRateLimitMiddleware(limiter RateLimiter, policy RateLimitPolicy, jwtSecret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tier, key, tenantID := rateLimitIdentity(c, jwtSecret)
+
+		limit, ok := policy.Overrides.Limit(policy.Tiers, tier)
+		if !ok {
+			tier = TierUser
+			limit, _ = policy.Overrides.Limit(policy.Tiers, TierUser)
+		}
+		window := policy.Overrides.Window(policy.Window)
+
+		// A subscription plan with its own limit replaces the tier limit,
+		// except for admins. Plan lookup errors fall back to the tier.
+		if policy.Plans != nil && tenantID != "" && tier != TierAdmin {
+			limits, err := policy.Plans.Get(c.Request.Context(), tenantID)
+			if err != nil {
+				log.Printf("Plan limits unavailable, using tier limit: %v", err)
+			} else if limits != nil && limits.RequestsPerWindow > 0 {
+				tier = "plan:" + limits.Plan
+				limit = limits.RequestsPerWindow
+			}
+		}
+
+		// Anomalous callers are held to a stricter limit for a while
+		limit, restrictedUntil := policy.Anomalies.Limit(key, limit)
+		defer func() {
+			policy.Anomalies.Observe(key, tier, c.Writer.Status())
+		}()
+
+		result, err := limiter.Allow(c.Request.Context(), key, limit, window)
+		if err != nil {
+			log.Printf("Rate limiter unavailable, allowing request: %v", err)
+			c.Next()
+			return
+		}
+
+		c.Set("rate_limit_tier", tier)
+		c.Set("rate_limit_key", key)
+		c.Set("rate_limit_limit", limit)
+		c.Set("rate_limit_window", window)
+
+		c.Header(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+		c.Header(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+		c.Header(HeaderRateLimitReset, strconv.FormatInt(result.Reset.Unix(), 10))
+		c.Header(HeaderRateLimitTier, tier)
+		if !restrictedUntil.IsZero() {
+			c.Header(HeaderRateLimitRestricted, strconv.FormatInt(restrictedUntil.Unix(), 10))
+		}
+
+		if !result.Allowed {
+			retryAfter := int(time.Until(result.Reset).Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			metrics.GatewayRateLimitRejectionsTotal.WithLabelValues(tier).Inc()
+			c.JSON(429, gin.H{"error": "Rate limit exceeded"})
+			c.Abort()
+			return
+		}
+
+		if used, warn := rateLimitWarning(result, policy.WarningThreshold); warn {
+			c.Header(HeaderRateLimitWarning, fmt.Sprintf("%d%% of rate limit used", used))
+			c.Header(HeaderRateLimitBackoff, strconv.Itoa(rateLimitBackoff(result)))
+			metrics.GatewayRateLimitWarningsTotal.WithLabelValues(tier).Inc()
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitWarning reports the percentage of its limit a caller has used and
+// whether that reaches the warning threshold
+func rateLimitWarning(result *RateLimitResult, threshold float64) (int, bool) {
+	if threshold <= 0 || result.Limit <= 0 {
+		return 0, false
+	}
+	used := float64(result.Limit-result.Remaining) / float64(result.Limit)
+	return int(used * 100), used >= threshold
+}
+
+// rateLimitBackoff recommends the seconds to wait between requests so the
+// remaining quota lasts until the window resets
+func rateLimitBackoff(result *RateLimitResult) int {
+	untilReset := time.Until(result.Reset)
+	if result.Remaining > 0 {
+		untilReset /= time.Duration(result.Remaining)
+	}
+	backoff := int(math.Ceil(untilReset.Seconds()))
+	if backoff < 1 {
+		backoff = 1
+	}
+	return backoff
+}
+
+// rateLimitIdentity returns the tier, counter key and tenant for a request.
+// A subscription "tier" claim takes precedence over the caller's role.
+func rateLimitIdentity(c *gin.Context, jwtSecret string) (string, string, string) {
+	claims, err := parseBearerClaims(c.GetHeader("Authorization"), jwtSecret)
+	if err != nil {
+		return TierAnonymous, "ip:" + c.ClientIP(), ""
+	}
+
+	if claims.UserID == "" {
+		return TierAnonymous, "ip:" + c.ClientIP(), ""
+	}
+
+	tier := claims.Tier
+	if tier == "" {
+		tier = claims.Role
+	}
+	if tier == "" || tier == TierAnonymous {
+		tier = TierUser
+	}
+
+	return tier, "user:" + claims.UserID, claims.Tenant()
+}
+
+// RateLimitMiddleware implements single-tier, in-memory rate limiting. Use
+// TieredRateLimitMiddleware for per-tier limits shared across instances.
+func RateLimitMiddleware(requestsPerMinute int) gin.HandlerFunc {
+	limiter := NewMemoryRateLimiter()
+	go limiter.Start(context.Background(), time.Minute)
+
+	return TieredRateLimitMiddleware(limiter, RateLimitPolicy{
+		Window: time.Minute,
+		Tiers: map[string]int{
+			TierAnonymous: requestsPerMinute,
+			TierUser:      requestsPerMinute,
+		},
+	}, "")
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/reviews"
+)
+
+// ReviewRule returns why a request needs manual review, or nothing if it
+// does not, given the request and its body
+type ReviewRule func(c *gin.Context, body []byte) []string
+
+// RequireReview holds the requests that rule flags until an admin reviews
+// them. Such a request is queued as a review item of kind for the entity
+// named by the idParam route parameter, and answered with 202 Accepted; the
+// request runs if the item is approved. A request for an entity that is
+// already waiting for review joins its item. Admins' requests pass through,
+// as do requests when rule flags nothing. If store is nil, flagged requests
+// are refused rather than run.
+func RequireReview(jwtSecret string, store reviews.Store, kind, entityType, idParam string, rule ReviewRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := parseBearerClaims(c.GetHeader("Authorization"), jwtSecret)
+		if err != nil {
+			c.Next()
+			return
+		}
+		if claims.HasRole("admin") {
+			c.Next()
+			return
+		}
+		userID := claims.UserID
+
+		var body []byte
+		if c.Request.Body != nil {
+			body, err = io.ReadAll(io.LimitReader(c.Request.Body, maxApprovalBodySize+1))
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Failed to read request body"})
+				c.Abort()
+				return
+			}
+			if len(body) > maxApprovalBodySize {
+				c.JSON(413, gin.H{"error": "Request body too large"})
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		reasons := rule(c, body)
+		if len(reasons) == 0 {
+			c.Next()
+			return
+		}
+
+		if len(bytes.TrimSpace(body)) > 0 && !json.Valid(body) {
+			c.JSON(400, gin.H{"error": "Requests that need review must have a JSON body"})
+			c.Abort()
+			return
+		}
+		if store == nil {
+			c.JSON(503, gin.H{"error": "This request needs manual review, which is unavailable"})
+			c.Abort()
+			return
+		}
+
+		item := reviews.New(kind, entityType, c.Param(idParam), "api-gateway", reasons)
+		item.Request = &reviews.HeldRequest{
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.RequestURI(),
+			ContentType: c.ContentType(),
+			Body:        body,
+			RequestedBy: userID,
+		}
+		queued, err := store.Submit(c.Request.Context(), item)
+		if err != nil {
+			log.Printf("Failed to queue request for review: %v", err)
+			c.JSON(503, gin.H{"error": "This request needs manual review, which is unavailable"})
+			c.Abort()
+			return
+		}
+
+		log.Printf("AUDIT review requested: item=%s kind=%s entity=%s/%s user=%s reasons=%v",
+			queued.ID, kind, entityType, queued.EntityID, userID, reasons)
+
+		c.JSON(202, gin.H{
+			"message":   "This request is waiting for manual review",
+			"review_id": queued.ID,
+			"status":    queued.Status,
+		})
+		c.Abort()
+	}
+}
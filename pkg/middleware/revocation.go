@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/revocation"
+)
+
+// RejectRevokedTokens refuses tokens issued before their user's tokens were
+// revoked, such as when the user was suspended. It reads the claims stored by
+// AuthMiddleware, which must run first. If revocations cannot be looked up the
+// request is let through, so an outage of the store does not lock everyone
+// out; a nil lookup disables the check.
+func RejectRevokedTokens(lookup revocation.Lookup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("claims")
+		claims, ok := value.(*Claims)
+		if lookup == nil || !ok {
+			c.Next()
+			return
+		}
+
+		revokedAt, err := lookup.RevokedAt(c.Request.Context(), claims.UserID)
+		if err != nil {
+			log.Printf("Token revocations unavailable, accepting token of user %s: %v", claims.UserID, err)
+			c.Next()
+			return
+		}
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
+		if revocation.Revoked(revokedAt, issuedAt) {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.JSON(401, gin.H{"error": "Token revoked"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
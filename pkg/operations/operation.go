@@ -0,0 +1,234 @@
+// Package operations tracks long-running tasks started through the API. A
+// request that starts one returns 202 Accepted with the operation ID right
+// away; the work continues in the background and its progress is kept in
+// Redis, where the gateway serves it at /api/v1/operations/{id}.
+package operations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/redisclient"
+)
+
+// Operation statuses
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Operation types
+const (
+	TypeDataExport   = "user.data_export"
+	TypeUserDeletion = "user.deletion"
+)
+
+// MetadataOperationID is the gRPC response header naming the operation a call
+// started. The gateway turns such responses into 202 Accepted with a Location
+// header pointing at the operation.
+const MetadataOperationID = "x-operation-id"
+
+// Operation is the status of a long-running task
+type Operation struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Service string `json:"service"`
+	// UserID is the user the operation acts for; only they and admins may
+	// read it
+	UserID string `json:"user_id,omitempty"`
+	Status string `json:"status"`
+	// Progress is the share of the work done, from 0 to 100
+	Progress int    `json:"progress"`
+	Message  string `json:"message,omitempty"`
+	// Result is set once the operation has succeeded
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// New creates a pending operation
+func New(opType, service, userID string) *Operation {
+	now := time.Now().UTC()
+	return &Operation{
+		ID:        generateOperationID(),
+		Type:      opType,
+		Service:   service,
+		UserID:    userID,
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Done reports whether the operation has finished
+func (o *Operation) Done() bool {
+	return o.Status == StatusSucceeded || o.Status == StatusFailed
+}
+
+// SetProgress records progress on a running operation. It also resumes an
+// operation that failed and is being retried.
+func (o *Operation) SetProgress(percent int, message string) {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	o.Status = StatusRunning
+	o.Progress = percent
+	o.Message = message
+	o.Error = ""
+}
+
+// Succeed finishes the operation with a result, which is encoded as JSON
+func (o *Operation) Succeed(result interface{}) error {
+	if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("failed to marshal operation result: %v", err)
+		}
+		o.Result = data
+	}
+	o.Status = StatusSucceeded
+	o.Progress = 100
+	o.Message = ""
+	return nil
+}
+
+// Fail finishes the operation with an error
+func (o *Operation) Fail(err error) {
+	o.Status = StatusFailed
+	o.Error = err.Error()
+	o.Message = ""
+}
+
+// SetHeader names the operation in the response headers of a gRPC call
+func SetHeader(ctx context.Context, id string) error {
+	return grpc.SetHeader(ctx, metadata.Pairs(MetadataOperationID, id))
+}
+
+// generateOperationID generates a random operation ID
+func generateOperationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("op_%d", time.Now().UnixNano())
+	}
+	return "op_" + hex.EncodeToString(b)
+}
+
+// Store keeps operation statuses
+type Store interface {
+	// Save records an operation's current status and notifies its watchers
+	Save(ctx context.Context, op *Operation) error
+	// Get returns an operation, or nil if it is unknown or has expired
+	Get(ctx context.Context, id string) (*Operation, error)
+	// Watch delivers an operation's updates until ctx is done. Call Get after
+	// Watch to learn the status without missing an update in between.
+	Watch(ctx context.Context, id string) (<-chan *Operation, error)
+}
+
+// RedisStore implements Store in Redis so every service instance and the
+// gateway see the same operations
+type RedisStore struct {
+	client    redis.UniversalClient
+	retention time.Duration
+}
+
+// NewRedisStore creates a new Redis-backed operation store
+func NewRedisStore(cfg config.RedisConfig, opsCfg config.OperationsConfig) (*RedisStore, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisStore{client: client, retention: opsCfg.Retention}, nil
+}
+
+func operationKey(id string) string {
+	return fmt.Sprintf("operations:id:%s", id)
+}
+
+func updatesChannel(id string) string {
+	return fmt.Sprintf("operations:updates:%s", id)
+}
+
+// Save records an operation and publishes it to its watchers
+func (s *RedisStore) Save(ctx context.Context, op *Operation) error {
+	op.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("failed to marshal operation: %v", err)
+	}
+
+	if err := s.client.Set(ctx, operationKey(op.ID), data, s.retention).Err(); err != nil {
+		return err
+	}
+	return s.client.Publish(ctx, updatesChannel(op.ID), data).Err()
+}
+
+// Get returns an operation
+func (s *RedisStore) Get(ctx context.Context, id string) (*Operation, error) {
+	data, err := s.client.Get(ctx, operationKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var op Operation
+	if err := json.Unmarshal(data, &op); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal operation: %v", err)
+	}
+	return &op, nil
+}
+
+// Watch subscribes to an operation's updates
+func (s *RedisStore) Watch(ctx context.Context, id string) (<-chan *Operation, error) {
+	pubsub := s.client.Subscribe(ctx, updatesChannel(id))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	updates := make(chan *Operation)
+	go func() {
+		defer close(updates)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var op Operation
+				if err := json.Unmarshal([]byte(msg.Payload), &op); err != nil {
+					continue
+				}
+				select {
+				case updates <- &op:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return updates, nil
+}
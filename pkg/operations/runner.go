@@ -0,0 +1,145 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"microservices-platform/pkg/config"
+)
+
+// ErrUnavailable is returned when operations cannot be tracked, e.g. because
+// Redis is down. Callers may fall back to doing the work synchronously.
+var ErrUnavailable = errors.New("operations are unavailable")
+
+// Func does the work of an operation, reporting progress as it goes, and
+// returns the operation's result
+type Func func(ctx context.Context, progress *Progress) (interface{}, error)
+
+// Runner runs operations in the background and records their progress
+type Runner struct {
+	store   Store
+	service string
+	timeout time.Duration
+	wg      sync.WaitGroup
+}
+
+// NewRunner creates a runner for a service. store may be nil, in which case
+// Start returns ErrUnavailable.
+func NewRunner(store Store, service string, cfg config.OperationsConfig) *Runner {
+	return &Runner{
+		store:   store,
+		service: service,
+		timeout: cfg.Timeout,
+	}
+}
+
+// Store returns the runner's operation store, or nil if there is none
+func (r *Runner) Store() Store {
+	if r == nil {
+		return nil
+	}
+	return r.store
+}
+
+// New creates a pending operation of the runner's service, for callers that
+// need its ID before calling Run
+func (r *Runner) New(opType, userID string) *Operation {
+	return New(opType, r.service, userID)
+}
+
+// Start creates an operation and runs fn for it in the background
+func (r *Runner) Start(ctx context.Context, opType, userID string, fn Func) (*Operation, error) {
+	if r == nil {
+		return nil, ErrUnavailable
+	}
+	return r.Run(ctx, r.New(opType, userID), fn)
+}
+
+// Run records a pending operation and runs fn for it in the background. The
+// work keeps the request's trace but not its cancellation, so it outlives the
+// request that started it. The returned copy is the operation as recorded.
+func (r *Runner) Run(ctx context.Context, op *Operation, fn Func) (*Operation, error) {
+	if r == nil || r.store == nil {
+		return nil, ErrUnavailable
+	}
+
+	if err := r.store.Save(ctx, op); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	started := *op
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ctx := context.WithoutCancel(ctx)
+		if r.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+		}
+
+		progress := &Progress{store: r.store, op: op}
+		progress.Report(ctx, 0, "")
+
+		result, err := fn(ctx, progress)
+
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		if err == nil {
+			err = op.Succeed(result)
+		}
+		if err != nil {
+			op.Fail(err)
+			log.Printf("Operation %s (%s) failed: %v", op.ID, op.Type, err)
+		}
+		if err := r.store.Save(context.WithoutCancel(ctx), op); err != nil {
+			log.Printf("Failed to record result of operation %s: %v", op.ID, err)
+		}
+	}()
+
+	return &started, nil
+}
+
+// Shutdown waits for running operations to finish or ctx to be done
+func (r *Runner) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Progress reports the progress of a running operation. A nil Progress
+// ignores reports, so work can be shared with synchronous callers.
+type Progress struct {
+	store Store
+	mu    sync.Mutex
+	op    *Operation
+}
+
+// Report records how much of the work is done, from 0 to 100. Failing to
+// record progress does not fail the operation.
+func (p *Progress) Report(ctx context.Context, percent int, message string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.op.SetProgress(percent, message)
+	if err := p.store.Save(ctx, p.op); err != nil {
+		log.Printf("Failed to record progress of operation %s: %v", p.op.ID, err)
+	}
+}
@@ -0,0 +1,73 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBreachCheckURL is the Have I Been Pwned Pwned Passwords range API
+const DefaultBreachCheckURL = "https://api.pwnedpasswords.com/range/"
+
+// BreachChecker looks passwords up in a Pwned Passwords range API with
+// k-anonymity: only the first five hex digits of the password's SHA-1 are
+// sent, and the response lists the suffixes of every breached password that
+// shares them.
+type BreachChecker struct {
+	url    string
+	client *http.Client
+}
+
+// NewBreachChecker creates a checker querying the range API at url
+func NewBreachChecker(url string, timeout time.Duration) *BreachChecker {
+	return &BreachChecker{
+		url:    strings.TrimSuffix(url, "/") + "/",
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Count returns how many times a password appears in known breaches
+func (b *BreachChecker) Count(ctx context.Context, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url+prefix, nil)
+	if err != nil {
+		return 0, err
+	}
+	// Padded responses all have a similar size, so their length does not
+	// reveal the prefix
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("breach check returned %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		candidate, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || !strings.EqualFold(candidate, suffix) {
+			continue
+		}
+		// Padding entries have a count of 0
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, fmt.Errorf("invalid breach check response: %v", err)
+		}
+		return n, nil
+	}
+	return 0, scanner.Err()
+}
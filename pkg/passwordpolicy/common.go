@@ -0,0 +1,28 @@
+package passwordpolicy
+
+// commonPasswords are among the most used passwords, lowercased. They are
+// banned whatever else the policy allows; PASSWORD_BANNED_LIST_FILE extends
+// the list.
+var commonPasswords = []string{
+	"123456", "123456789", "12345678", "1234567890", "12345", "1234567",
+	"password", "password1", "password12", "password123", "password1234",
+	"passw0rd", "p@ssword", "p@ssw0rd", "p@ssw0rd1", "password!", "password1!",
+	"qwerty", "qwerty123", "qwerty1234", "qwertyuiop", "1q2w3e4r", "1q2w3e4r5t",
+	"1qaz2wsx", "zaq12wsx", "asdfghjkl", "asdf1234", "zxcvbnm", "zxcvbnm123",
+	"abc123", "abcd1234", "abc12345", "111111", "000000", "123123", "123321",
+	"654321", "666666", "7777777", "888888", "987654321", "11111111",
+	"iloveyou", "iloveyou1", "welcome", "welcome1", "welcome123", "welcome1!",
+	"admin", "admin123", "admin1234", "administrator", "root", "letmein",
+	"letmein1", "letmein123", "changeme", "changeme123", "default", "secret",
+	"secret123", "trustno1", "monkey", "monkey123", "dragon", "dragon123",
+	"football", "football1", "baseball", "baseball1", "basketball", "soccer",
+	"superman", "batman", "starwars", "pokemon", "princess", "princess1",
+	"sunshine", "sunshine1", "shadow", "master", "master123", "michael",
+	"jennifer", "jordan23", "hello123", "whatever", "freedom", "computer",
+	"internet", "samsung", "google", "mustang", "charlie", "summer2024",
+	"winter2024", "spring2024", "autumn2024", "summer2025", "winter2025",
+	"spring2025", "autumn2025", "summer2026", "winter2026", "spring2026",
+	"autumn2026", "qwerty123!", "qwerty1!", "abcd1234!", "aa123456",
+	"a1b2c3d4", "test1234", "test123", "testtest", "guest", "login",
+	"access", "passpass", "mypassword", "newpassword", "temp1234",
+}
@@ -0,0 +1,281 @@
+// Package passwordpolicy checks new passwords against a configurable policy:
+// length, character classes, a list of banned common passwords, the user's
+// own details and, optionally, the Have I Been Pwned breach corpus.
+package passwordpolicy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"microservices-platform/pkg/i18n"
+)
+
+// Rules a password can violate
+const (
+	RuleTooShort      = "too_short"
+	RuleTooLong       = "too_long"
+	RuleMissingUpper  = "missing_uppercase"
+	RuleMissingLower  = "missing_lowercase"
+	RuleMissingDigit  = "missing_digit"
+	RuleMissingSymbol = "missing_symbol"
+	RuleCommon        = "common_password"
+	RulePersonalInfo  = "contains_personal_info"
+	RuleBreached      = "breached"
+)
+
+// violationReason and violationDomain identify policy violations in the
+// ErrorInfo of their gRPC status
+const (
+	violationReason = "PASSWORD_POLICY_VIOLATION"
+	violationDomain = "microservices-platform"
+)
+
+// minPersonalInfoSize is the shortest email or username that a password may
+// not contain; shorter ones match too many passwords by chance
+const minPersonalInfoSize = 4
+
+// Config holds the password policy
+type Config struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// BannedListFile adds banned passwords, one per line, to the built-in
+	// list of common passwords
+	BannedListFile string
+
+	// BreachCheck looks passwords up in the Have I Been Pwned range API,
+	// which is only sent the first five hex digits of the password's SHA-1
+	BreachCheck        bool
+	BreachCheckURL     string
+	BreachCheckTimeout time.Duration
+}
+
+// LoadConfig loads the password policy from environment variables
+func LoadConfig() Config {
+	return Config{
+		MinLength:          getIntEnv("PASSWORD_MIN_LENGTH", 10),
+		MaxLength:          getIntEnv("PASSWORD_MAX_LENGTH", 128),
+		RequireUpper:       getBoolEnv("PASSWORD_REQUIRE_UPPERCASE", true),
+		RequireLower:       getBoolEnv("PASSWORD_REQUIRE_LOWERCASE", true),
+		RequireDigit:       getBoolEnv("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol:      getBoolEnv("PASSWORD_REQUIRE_SYMBOL", false),
+		BannedListFile:     os.Getenv("PASSWORD_BANNED_LIST_FILE"),
+		BreachCheck:        getBoolEnv("PASSWORD_BREACH_CHECK", false),
+		BreachCheckURL:     getEnv("PASSWORD_BREACH_CHECK_URL", DefaultBreachCheckURL),
+		BreachCheckTimeout: getDurationEnv("PASSWORD_BREACH_CHECK_TIMEOUT", 2*time.Second),
+	}
+}
+
+// Violation is a rule a password breaks
+type Violation struct {
+	Rule string
+	// Message is in the language of the request the password came with
+	Message string
+}
+
+// ViolationError is returned for a password that breaks the policy. It lists
+// every rule broken, so the user can fix them all at once.
+type ViolationError struct {
+	Violations []Violation
+	Language   string
+}
+
+func (e *ViolationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return i18n.Translate(e.Language, "password does not meet the policy: %s", strings.Join(messages, "; "))
+}
+
+// GRPCStatus returns the violation as an InvalidArgument status. Its details
+// are a BadRequest with a field violation of "password" per rule broken, an
+// ErrorInfo whose "rules" metadata lists the rules, comma-separated, and the
+// message as a LocalizedMessage.
+func (e *ViolationError) GRPCStatus() *status.Status {
+	rules := make([]string, len(e.Violations))
+	badRequest := &errdetails.BadRequest{}
+	for i, v := range e.Violations {
+		rules[i] = v.Rule
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       "password",
+			Description: v.Message,
+		})
+	}
+
+	st := status.New(codes.InvalidArgument, e.Error())
+	detailed, err := st.WithDetails(badRequest, &errdetails.ErrorInfo{
+		Reason:   violationReason,
+		Domain:   violationDomain,
+		Metadata: map[string]string{"rules": strings.Join(rules, ",")},
+	}, &errdetails.LocalizedMessage{
+		Locale:  e.Language,
+		Message: e.Error(),
+	})
+	if err != nil {
+		return st
+	}
+	return detailed
+}
+
+// Policy checks passwords against a Config
+type Policy struct {
+	cfg      Config
+	banned   map[string]bool
+	breaches *BreachChecker
+}
+
+// New creates a policy, loading the banned list file if one is configured
+func New(cfg Config) (*Policy, error) {
+	banned := make(map[string]bool, len(commonPasswords))
+	for _, password := range commonPasswords {
+		banned[password] = true
+	}
+	if cfg.BannedListFile != "" {
+		if err := loadBannedList(cfg.BannedListFile, banned); err != nil {
+			return nil, fmt.Errorf("failed to load banned password list: %v", err)
+		}
+	}
+
+	p := &Policy{cfg: cfg, banned: banned}
+	if cfg.BreachCheck {
+		p.breaches = NewBreachChecker(cfg.BreachCheckURL, cfg.BreachCheckTimeout)
+	}
+	return p, nil
+}
+
+// Check returns a *ViolationError if password breaks the policy. personal
+// are the user's own details, such as their email and username, which the
+// password must not contain. The breach check only runs for passwords that
+// pass the other rules, and is skipped if the breach API cannot be reached.
+func (p *Policy) Check(ctx context.Context, password string, personal ...string) error {
+	var violations []Violation
+	language := i18n.FromContext(ctx)
+
+	length := utf8.RuneCountInString(password)
+	if length < p.cfg.MinLength {
+		violations = append(violations, Violation{RuleTooShort, i18n.Translate(language, "must be at least %d characters long", p.cfg.MinLength)})
+	}
+	if p.cfg.MaxLength > 0 && length > p.cfg.MaxLength {
+		violations = append(violations, Violation{RuleTooLong, i18n.Translate(language, "must be at most %d characters long", p.cfg.MaxLength)})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.cfg.RequireUpper && !upper {
+		violations = append(violations, Violation{RuleMissingUpper, i18n.Translate(language, "must contain an uppercase letter")})
+	}
+	if p.cfg.RequireLower && !lower {
+		violations = append(violations, Violation{RuleMissingLower, i18n.Translate(language, "must contain a lowercase letter")})
+	}
+	if p.cfg.RequireDigit && !digit {
+		violations = append(violations, Violation{RuleMissingDigit, i18n.Translate(language, "must contain a digit")})
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		violations = append(violations, Violation{RuleMissingSymbol, i18n.Translate(language, "must contain a symbol")})
+	}
+
+	lowered := strings.ToLower(password)
+	if p.banned[lowered] {
+		violations = append(violations, Violation{RuleCommon, i18n.Translate(language, "is too common")})
+	}
+	for _, detail := range personal {
+		// Only the local part of an email address is meaningful to guess
+		detail = strings.ToLower(strings.SplitN(detail, "@", 2)[0])
+		if utf8.RuneCountInString(detail) >= minPersonalInfoSize && strings.Contains(lowered, detail) {
+			violations = append(violations, Violation{RulePersonalInfo, i18n.Translate(language, "must not contain your email address or username")})
+			break
+		}
+	}
+
+	if len(violations) == 0 && p.breaches != nil {
+		count, err := p.breaches.Count(ctx, password)
+		if err != nil {
+			log.Printf("Password breach check unavailable, skipping: %v", err)
+		} else if count > 0 {
+			violations = append(violations, Violation{RuleBreached, i18n.Translate(language, "has appeared in a data breach and must not be used")})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ViolationError{Violations: violations, Language: language}
+	}
+	return nil
+}
+
+// loadBannedList adds the passwords in a file, one per line, to banned
+func loadBannedList(path string, banned map[string]bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			banned[strings.ToLower(password)] = true
+		}
+	}
+	return scanner.Err()
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
+
+func getIntEnv(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getBoolEnv(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
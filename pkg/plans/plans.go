@@ -0,0 +1,157 @@
+package plans
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/redisclient"
+)
+
+// Limits are the plan limits that apply to a tenant. The subscription service
+// publishes them whenever a subscription changes; the gateway and services
+// look them up for rate limiting and feature checks.
+type Limits struct {
+	TenantID string `json:"tenant_id"`
+	Plan     string `json:"plan"`
+	Status   string `json:"status"`
+	// RequestsPerWindow overrides the caller's rate limit tier when positive
+	RequestsPerWindow int      `json:"requests_per_window"`
+	Features          []string `json:"features"`
+}
+
+// HasFeature reports whether the plan includes a feature
+func (l *Limits) HasFeature(feature string) bool {
+	for _, f := range l.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns a tenant's plan limits, or nil if the tenant has no
+// subscription
+type Lookup interface {
+	Get(ctx context.Context, tenantID string) (*Limits, error)
+}
+
+// Store publishes and looks up plan limits
+type Store interface {
+	Lookup
+	Set(ctx context.Context, limits *Limits) error
+	Delete(ctx context.Context, tenantID string) error
+}
+
+// RedisStore implements Store in Redis so every gateway instance and service
+// sees the same limits
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisStore creates a new Redis-backed plan limits store
+func NewRedisStore(cfg config.RedisConfig) (*RedisStore, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisStore{client: client}, nil
+}
+
+func limitsKey(tenantID string) string {
+	return fmt.Sprintf("plans:tenant:%s", tenantID)
+}
+
+// Get returns a tenant's plan limits
+func (s *RedisStore) Get(ctx context.Context, tenantID string) (*Limits, error) {
+	data, err := s.client.Get(ctx, limitsKey(tenantID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var limits Limits
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal plan limits: %v", err)
+	}
+	return &limits, nil
+}
+
+// Set publishes a tenant's plan limits
+func (s *RedisStore) Set(ctx context.Context, limits *Limits) error {
+	data, err := json.Marshal(limits)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan limits: %v", err)
+	}
+	return s.client.Set(ctx, limitsKey(limits.TenantID), data, 0).Err()
+}
+
+// Delete removes a tenant's plan limits
+func (s *RedisStore) Delete(ctx context.Context, tenantID string) error {
+	return s.client.Del(ctx, limitsKey(tenantID)).Err()
+}
+
+// CachedLookup keeps looked up limits in memory for a short time so hot paths
+// such as rate limiting do not read Redis on every request. Plan changes take
+// up to the TTL to apply.
+type CachedLookup struct {
+	lookup Lookup
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedLimits
+}
+
+type cachedLimits struct {
+	limits  *Limits
+	expires time.Time
+}
+
+// NewCachedLookup wraps a lookup with an in-memory cache
+func NewCachedLookup(lookup Lookup, ttl time.Duration) *CachedLookup {
+	return &CachedLookup{
+		lookup:  lookup,
+		ttl:     ttl,
+		entries: make(map[string]cachedLimits),
+	}
+}
+
+// Get returns a tenant's plan limits, from memory if they were looked up
+// within the TTL. Tenants without a subscription are cached too.
+func (c *CachedLookup) Get(ctx context.Context, tenantID string) (*Limits, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[tenantID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.limits, nil
+	}
+
+	limits, err := c.lookup.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Drop expired entries as we go so memory stays bounded
+	if !ok {
+		for id, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, id)
+			}
+		}
+	}
+	c.entries[tenantID] = cachedLimits{limits: limits, expires: now.Add(c.ttl)}
+
+	return limits, nil
+}
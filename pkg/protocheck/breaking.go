@@ -0,0 +1,175 @@
+package protocheck
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Breaking compares files against a previous version of the same files and
+// reports changes that break generated clients or persisted data: deleted
+// files, packages, messages, enums, services and RPCs, deleted fields and enum
+// values whose number was not reserved, and fields, enum values and RPCs whose
+// name, number, type, cardinality or streaming changed. Files are matched by
+// path.
+func Breaking(previous, current []*File) []Finding {
+	currentByPath := make(map[string]*File, len(current))
+	for _, file := range current {
+		currentByPath[file.Path] = file
+	}
+
+	var findings []Finding
+	for _, before := range previous {
+		after, ok := currentByPath[before.Path]
+		if !ok {
+			findings = append(findings, Finding{
+				Path:    before.Path,
+				Pos:     Position{Line: 1, Column: 1},
+				Rule:    "FILE_NO_DELETE",
+				Message: fmt.Sprintf("file %s was deleted; restore it and deprecate its definitions instead", before.Path),
+			})
+			continue
+		}
+		findings = append(findings, breakingFile(before, after)...)
+	}
+	sortFindings(findings)
+	return findings
+}
+
+func breakingFile(before, after *File) []Finding {
+	var findings []Finding
+	report := func(pos Position, rule, format string, args ...interface{}) {
+		findings = append(findings, Finding{Path: after.Path, Pos: pos, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+	top := Position{Line: 1, Column: 1}
+
+	if before.Package != after.Package {
+		report(top, "FILE_SAME_PACKAGE", "package changed from %q to %q; add a new file for the new package instead",
+			before.Package, after.Package)
+		return findings
+	}
+
+	for _, name := range sortedKeys(before.Messages) {
+		old := before.Messages[name]
+		message, ok := after.Messages[name]
+		if !ok {
+			report(top, "MESSAGE_NO_DELETE", "message %s was deleted; keep it and mark it deprecated", name)
+			continue
+		}
+
+		for _, oldField := range old.Fields {
+			field := message.FieldByNumber(oldField.Number)
+			if field == nil {
+				if !message.Reserved.HasNumber(oldField.Number) {
+					report(message.Pos, "FIELD_NO_DELETE_UNLESS_NUMBER_RESERVED",
+						"field %d (%q) of message %s was deleted; add \"reserved %d;\" and \"reserved %q;\" so the number is never reused",
+						oldField.Number, oldField.Name, name, oldField.Number, oldField.Name)
+				}
+				continue
+			}
+			if field.Name != oldField.Name {
+				report(field.Pos, "FIELD_SAME_NAME",
+					"field %d of message %s was renamed from %q to %q, which breaks JSON clients; keep the old name",
+					field.Number, name, oldField.Name, field.Name)
+			}
+			if normalizeType(field.Type, after.Package) != normalizeType(oldField.Type, before.Package) {
+				report(field.Pos, "FIELD_SAME_TYPE",
+					"field %s.%s changed type from %s to %s; add a new field and reserve %d instead",
+					name, field.Name, oldField.Type, field.Type, field.Number)
+			}
+			if cardinality(field.Label) != cardinality(oldField.Label) {
+				report(field.Pos, "FIELD_SAME_CARDINALITY",
+					"field %s.%s changed from %s to %s; add a new field and reserve %d instead",
+					name, field.Name, describeLabel(oldField.Label), describeLabel(field.Label), field.Number)
+			}
+			if field.OneOf != oldField.OneOf {
+				report(field.Pos, "FIELD_SAME_ONEOF",
+					"field %s.%s moved from oneof %q to %q; add a new field instead",
+					name, field.Name, oldField.OneOf, field.OneOf)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(before.Enums) {
+		old := before.Enums[name]
+		enum, ok := after.Enums[name]
+		if !ok {
+			report(top, "ENUM_NO_DELETE", "enum %s was deleted; keep it and mark it deprecated", name)
+			continue
+		}
+
+		for _, oldValue := range old.Values {
+			value := enum.ValueByNumber(oldValue.Number)
+			if value == nil {
+				if !enum.Reserved.HasNumber(oldValue.Number) {
+					report(enum.Pos, "ENUM_VALUE_NO_DELETE_UNLESS_NUMBER_RESERVED",
+						"value %s = %d of enum %s was deleted; add \"reserved %d;\" so the number is never reused",
+						oldValue.Name, oldValue.Number, name, oldValue.Number)
+				}
+				continue
+			}
+			if value.Name != oldValue.Name {
+				report(value.Pos, "ENUM_VALUE_SAME_NAME",
+					"value %d of enum %s was renamed from %s to %s, which breaks JSON clients; keep the old name",
+					value.Number, name, oldValue.Name, value.Name)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(before.Services) {
+		old := before.Services[name]
+		service, ok := after.Services[name]
+		if !ok {
+			report(top, "SERVICE_NO_DELETE", "service %s was deleted; keep it and mark it deprecated", name)
+			continue
+		}
+
+		for _, oldMethod := range old.Methods {
+			method := service.MethodByName(oldMethod.Name)
+			if method == nil {
+				report(service.Pos, "RPC_NO_DELETE", "RPC %s.%s was deleted; keep it and mark it deprecated", name, oldMethod.Name)
+				continue
+			}
+			if normalizeType(method.Request, after.Package) != normalizeType(oldMethod.Request, before.Package) {
+				report(method.Pos, "RPC_SAME_REQUEST_TYPE", "RPC %s.%s changed its request from %s to %s; add a new RPC instead",
+					name, method.Name, oldMethod.Request, method.Request)
+			}
+			if normalizeType(method.Response, after.Package) != normalizeType(oldMethod.Response, before.Package) {
+				report(method.Pos, "RPC_SAME_RESPONSE_TYPE", "RPC %s.%s changed its response from %s to %s; add a new RPC instead",
+					name, method.Name, oldMethod.Response, method.Response)
+			}
+			if method.ClientStreaming != oldMethod.ClientStreaming {
+				report(method.Pos, "RPC_SAME_CLIENT_STREAMING", "RPC %s.%s changed client streaming; add a new RPC instead",
+					name, method.Name)
+			}
+			if method.ServerStreaming != oldMethod.ServerStreaming {
+				report(method.Pos, "RPC_SAME_SERVER_STREAMING", "RPC %s.%s changed server streaming; add a new RPC instead",
+					name, method.Name)
+			}
+		}
+	}
+	return findings
+}
+
+// normalizeType strips the leading dot and the file's own package from a
+// type reference, so "Order", "order.v1.Order" and ".order.v1.Order" compare
+// equal
+func normalizeType(typ, pkg string) string {
+	typ = strings.TrimPrefix(typ, ".")
+	return strings.TrimPrefix(typ, pkg+".")
+}
+
+// cardinality groups labels with the same wire encoding. Adding or removing
+// "optional" on a proto3 field keeps its encoding.
+func cardinality(label string) string {
+	if label == "optional" {
+		return ""
+	}
+	return label
+}
+
+func describeLabel(label string) string {
+	if cardinality(label) == "" {
+		return "singular"
+	}
+	return label
+}
@@ -0,0 +1,188 @@
+package protocheck
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Finding is a lint or breaking-change violation
+type Finding struct {
+	Path    string
+	Pos     Position
+	Rule    string
+	Message string
+}
+
+// String formats the finding as "path:line:column: message (RULE)"
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", f.Path, f.Pos.Line, f.Pos.Column, f.Message, f.Rule)
+}
+
+var (
+	pascalCase     = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	lowerSnakeCase = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+	upperSnakeCase = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+	versionSuffix  = regexp.MustCompile(`\.v[0-9]+((alpha|beta)[0-9]*)?$`)
+)
+
+// Lint checks files against the style rules of the platform's APIs, which
+// follow buf's DEFAULT lint category: versioned packages, PascalCase types and
+// RPCs, lower_snake_case fields, prefixed UPPER_SNAKE_CASE enum values with an
+// _UNSPECIFIED zero value, and RPCs taking <Rpc>Request and returning
+// <Rpc>Response. Server-streaming RPCs may stream any message. It also
+// reports duplicate and reserved field numbers, which protoc would reject.
+//
+// A rule is ignored for one definition by a "protocheck:ignore RULE" comment
+// right above it.
+func Lint(files []*File) []Finding {
+	var findings []Finding
+	for _, file := range files {
+		findings = append(findings, lintFile(file)...)
+	}
+	sortFindings(findings)
+	return findings
+}
+
+func lintFile(file *File) []Finding {
+	var findings []Finding
+	report := func(pos Position, rule, format string, args ...interface{}) {
+		if file.Ignored(rule, pos) {
+			return
+		}
+		findings = append(findings, Finding{Path: file.Path, Pos: pos, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case file.Package == "":
+		report(Position{Line: 1, Column: 1}, "PACKAGE_DEFINED", "file has no package; declare one like \"order.v1\"")
+	case !versionSuffix.MatchString(file.Package):
+		report(Position{Line: 1, Column: 1}, "PACKAGE_VERSION_SUFFIX",
+			"package %q has no version suffix; rename it to %q", file.Package, file.Package+".v1")
+	}
+
+	for _, name := range sortedKeys(file.Messages) {
+		message := file.Messages[name]
+		if short := shortName(name); !pascalCase.MatchString(short) {
+			report(message.Pos, "MESSAGE_PASCAL_CASE", "message %q should be PascalCase", name)
+		}
+
+		numbers := make(map[int]string)
+		for _, field := range message.Fields {
+			if !lowerSnakeCase.MatchString(field.Name) {
+				report(field.Pos, "FIELD_LOWER_SNAKE_CASE", "field %s.%s should be lower_snake_case, e.g. %q",
+					name, field.Name, toLowerSnake(field.Name))
+			}
+			if other, ok := numbers[field.Number]; ok {
+				report(field.Pos, "FIELD_NUMBER_UNIQUE", "field %s.%s reuses number %d of field %q",
+					name, field.Name, field.Number, other)
+			}
+			numbers[field.Number] = field.Name
+			if message.Reserved.HasNumber(field.Number) {
+				report(field.Pos, "FIELD_NOT_RESERVED", "field %s.%s uses reserved number %d; pick an unused number",
+					name, field.Name, field.Number)
+			}
+			if message.Reserved.HasName(field.Name) {
+				report(field.Pos, "FIELD_NOT_RESERVED", "field %s.%s uses reserved name %q; pick another name",
+					name, field.Name, field.Name)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(file.Enums) {
+		enum := file.Enums[name]
+		short := shortName(name)
+		if !pascalCase.MatchString(short) {
+			report(enum.Pos, "ENUM_PASCAL_CASE", "enum %q should be PascalCase", name)
+		}
+
+		prefix := toUpperSnake(short) + "_"
+		for i, value := range enum.Values {
+			if !upperSnakeCase.MatchString(value.Name) {
+				report(value.Pos, "ENUM_VALUE_UPPER_SNAKE_CASE", "enum value %s.%s should be UPPER_SNAKE_CASE", name, value.Name)
+			}
+			if !strings.HasPrefix(value.Name, prefix) {
+				report(value.Pos, "ENUM_VALUE_PREFIX", "enum value %s.%s should be prefixed with %q", name, value.Name, prefix)
+			}
+			if i == 0 && (value.Number != 0 || !strings.HasSuffix(value.Name, "_UNSPECIFIED")) {
+				report(value.Pos, "ENUM_ZERO_VALUE_SUFFIX", "the first value of enum %s should be %s = 0", name, prefix+"UNSPECIFIED")
+			}
+			if enum.Reserved.HasNumber(value.Number) || enum.Reserved.HasName(value.Name) {
+				report(value.Pos, "ENUM_VALUE_NOT_RESERVED", "enum value %s.%s uses a reserved number or name", name, value.Name)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(file.Services) {
+		service := file.Services[name]
+		if !pascalCase.MatchString(name) {
+			report(service.Pos, "SERVICE_PASCAL_CASE", "service %q should be PascalCase", name)
+		}
+		if !strings.HasSuffix(name, "Service") {
+			report(service.Pos, "SERVICE_SUFFIX", "service %q should be suffixed with \"Service\"", name)
+		}
+
+		for _, method := range service.Methods {
+			if !pascalCase.MatchString(method.Name) {
+				report(method.Pos, "RPC_PASCAL_CASE", "RPC %s.%s should be PascalCase", name, method.Name)
+			}
+			if want := method.Name + "Request"; shortName(method.Request) != want {
+				report(method.Pos, "RPC_REQUEST_STANDARD_NAME", "RPC %s.%s should take %s, not %s",
+					name, method.Name, want, method.Request)
+			}
+			if want := method.Name + "Response"; !method.ServerStreaming && shortName(method.Response) != want {
+				report(method.Pos, "RPC_RESPONSE_STANDARD_NAME", "RPC %s.%s should return %s, not %s",
+					name, method.Name, want, method.Response)
+			}
+		}
+	}
+	return findings
+}
+
+// shortName returns the last component of a dotted name
+func shortName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// toUpperSnake converts PascalCase to UPPER_SNAKE_CASE
+func toUpperSnake(name string) string {
+	return strings.ToUpper(toLowerSnake(name))
+}
+
+// toLowerSnake converts camelCase or PascalCase to lower_snake_case
+func toLowerSnake(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 && name[i-1] != '_' && !(name[i-1] >= 'A' && name[i-1] <= 'Z') {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Pos.Line != b.Pos.Line {
+			return a.Pos.Line < b.Pos.Line
+		}
+		return a.Pos.Column < b.Pos.Column
+	})
+}
@@ -0,0 +1,90 @@
+package protocheck
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LoadDir parses every .proto file under dir. File paths keep dir as their
+// prefix, so files loaded from the same dir at another revision match.
+func LoadDir(dir string) ([]*File, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && strings.HasSuffix(path, ".proto") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	files := make([]*File, 0, len(paths))
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		file, err := Parse(filepath.ToSlash(path), string(source))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// LoadGitRef parses every .proto file under dir as of a git revision, e.g.
+// "origin/main" or "HEAD". dir is relative to the working directory, as for
+// LoadDir.
+func LoadGitRef(ref, dir string) ([]*File, error) {
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	listing, err := git("ls-tree", "-r", "--name-only", ref, "--", dir+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*File
+	for _, path := range strings.Split(strings.TrimSpace(listing), "\n") {
+		if !strings.HasSuffix(path, ".proto") {
+			continue
+		}
+		// ls-tree prints paths relative to the working directory, which
+		// "git show" only resolves with an explicit "./" or "../" prefix
+		object := path
+		if !strings.HasPrefix(object, "../") {
+			object = "./" + object
+		}
+		source, err := git("show", ref+":"+object)
+		if err != nil {
+			return nil, err
+		}
+		file, err := Parse(path, source)
+		if err != nil {
+			return nil, fmt.Errorf("%s (at %s)", err, ref)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
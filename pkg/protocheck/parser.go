@@ -0,0 +1,721 @@
+package protocheck
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Position is a location in a proto file
+type Position struct {
+	Line   int
+	Column int
+}
+
+// File is the API surface of a parsed proto file
+type File struct {
+	Path     string
+	Package  string
+	Messages map[string]*Message
+	Enums    map[string]*Enum
+	Services map[string]*Service
+
+	// ignores maps lines to the lint rules ignored on them
+	ignores map[int][]string
+}
+
+// ignoreDirective is the comment prefix that suppresses lint rules for the
+// definition that follows, e.g. "// protocheck:ignore RPC_RESPONSE_STANDARD_NAME"
+const ignoreDirective = "protocheck:ignore"
+
+// Ignored reports whether a lint rule is ignored at a position
+func (f *File) Ignored(rule string, pos Position) bool {
+	for _, ignored := range f.ignores[pos.Line] {
+		if ignored == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// Message is a message definition. Nested messages and enums are stored in
+// the file under their dotted name, e.g. "Order.Item".
+type Message struct {
+	Name     string
+	Pos      Position
+	Fields   []*Field
+	Reserved Reserved
+}
+
+// Field is a message field. Label is "repeated", "optional", "map" or empty.
+type Field struct {
+	Name   string
+	Number int
+	Type   string
+	Label  string
+	OneOf  string
+	Pos    Position
+}
+
+// Enum is an enum definition
+type Enum struct {
+	Name     string
+	Pos      Position
+	Values   []*EnumValue
+	Reserved Reserved
+}
+
+// EnumValue is an enum value
+type EnumValue struct {
+	Name   string
+	Number int
+	Pos    Position
+}
+
+// Service is a service definition
+type Service struct {
+	Name    string
+	Pos     Position
+	Methods []*Method
+}
+
+// Method is an RPC of a service
+type Method struct {
+	Name            string
+	Request         string
+	Response        string
+	ClientStreaming bool
+	ServerStreaming bool
+	Pos             Position
+}
+
+// Reserved holds the reserved numbers and names of a message or enum
+type Reserved struct {
+	Ranges [][2]int
+	Names  []string
+}
+
+// HasNumber reports whether a number is reserved
+func (r Reserved) HasNumber(number int) bool {
+	for _, rng := range r.Ranges {
+		if number >= rng[0] && number <= rng[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// HasName reports whether a name is reserved
+func (r Reserved) HasName(name string) bool {
+	for _, reserved := range r.Names {
+		if reserved == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldByNumber returns the field with a number, or nil
+func (m *Message) FieldByNumber(number int) *Field {
+	for _, field := range m.Fields {
+		if field.Number == number {
+			return field
+		}
+	}
+	return nil
+}
+
+// ValueByNumber returns the first value with a number, or nil
+func (e *Enum) ValueByNumber(number int) *EnumValue {
+	for _, value := range e.Values {
+		if value.Number == number {
+			return value
+		}
+	}
+	return nil
+}
+
+// MethodByName returns the RPC with a name, or nil
+func (s *Service) MethodByName(name string) *Method {
+	for _, method := range s.Methods {
+		if method.Name == name {
+			return method
+		}
+	}
+	return nil
+}
+
+// maxFieldNumber is the largest field number, used for "reserved 10 to max"
+const maxFieldNumber = 536870911
+
+// token is a lexical token of a proto file
+type token struct {
+	text string
+	pos  Position
+	str  bool
+}
+
+// parser parses the definitions of a proto file. It understands the subset of
+// the language needed to compare APIs and skips options and extensions.
+type parser struct {
+	path   string
+	tokens []token
+	next   int
+	file   *File
+}
+
+// Parse parses a proto file's source
+func Parse(path, source string) (*File, error) {
+	tokens, ignores, err := tokenize(path, source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		path:   path,
+		tokens: tokens,
+		file: &File{
+			Path:     path,
+			Messages: make(map[string]*Message),
+			Enums:    make(map[string]*Enum),
+			Services: make(map[string]*Service),
+			ignores:  ignores,
+		},
+	}
+	if err := p.parseFile(); err != nil {
+		return nil, err
+	}
+	return p.file, nil
+}
+
+func (p *parser) parseFile() error {
+	for !p.done() {
+		tok := p.take()
+		switch tok.text {
+		case ";":
+		case "syntax", "edition", "import", "option":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		case "package":
+			name, err := p.ident()
+			if err != nil {
+				return err
+			}
+			p.file.Package = name.text
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case "message":
+			if err := p.parseMessage(""); err != nil {
+				return err
+			}
+		case "enum":
+			if err := p.parseEnum(""); err != nil {
+				return err
+			}
+		case "service":
+			if err := p.parseService(); err != nil {
+				return err
+			}
+		case "extend":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		default:
+			return p.errorf(tok, "unexpected %q", tok.text)
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseMessage(scope string) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	message := &Message{Name: scope + name.text, Pos: name.pos}
+	p.file.Messages[message.Name] = message
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	return p.parseMessageBody(message, "")
+}
+
+// parseMessageBody parses fields up to the closing brace. oneOf is set while
+// parsing the body of a oneof.
+func (p *parser) parseMessageBody(message *Message, oneOf string) error {
+	for {
+		if p.done() {
+			return fmt.Errorf("%s: unexpected end of file in message %s", p.path, message.Name)
+		}
+		tok := p.take()
+		switch tok.text {
+		case "}":
+			return nil
+		case ";":
+		case "option", "extensions", "extend":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		case "reserved":
+			if err := p.parseReserved(&message.Reserved); err != nil {
+				return err
+			}
+		case "message":
+			if err := p.parseMessage(message.Name + "."); err != nil {
+				return err
+			}
+		case "enum":
+			if err := p.parseEnum(message.Name + "."); err != nil {
+				return err
+			}
+		case "oneof":
+			name, err := p.ident()
+			if err != nil {
+				return err
+			}
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			if err := p.parseMessageBody(message, name.text); err != nil {
+				return err
+			}
+		case "map":
+			if err := p.expect("<"); err != nil {
+				return err
+			}
+			key, err := p.ident()
+			if err != nil {
+				return err
+			}
+			if err := p.expect(","); err != nil {
+				return err
+			}
+			value, err := p.ident()
+			if err != nil {
+				return err
+			}
+			if err := p.expect(">"); err != nil {
+				return err
+			}
+			if err := p.parseField(message, "map", "map<"+key.text+","+value.text+">", oneOf); err != nil {
+				return err
+			}
+		case "repeated", "optional", "required":
+			typ, err := p.ident()
+			if err != nil {
+				return err
+			}
+			if err := p.parseField(message, tok.text, typ.text, oneOf); err != nil {
+				return err
+			}
+		default:
+			if tok.str || !isIdent(tok.text) {
+				return p.errorf(tok, "unexpected %q in message %s", tok.text, message.Name)
+			}
+			if err := p.parseField(message, "", tok.text, oneOf); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parseField parses "name = number [options];" after the field type
+func (p *parser) parseField(message *Message, label, typ, oneOf string) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	number, err := p.number()
+	if err != nil {
+		return err
+	}
+	message.Fields = append(message.Fields, &Field{
+		Name:   name.text,
+		Number: number,
+		Type:   typ,
+		Label:  label,
+		OneOf:  oneOf,
+		Pos:    name.pos,
+	})
+	return p.skipStatement()
+}
+
+func (p *parser) parseEnum(scope string) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	enum := &Enum{Name: scope + name.text, Pos: name.pos}
+	p.file.Enums[enum.Name] = enum
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	for {
+		if p.done() {
+			return fmt.Errorf("%s: unexpected end of file in enum %s", p.path, enum.Name)
+		}
+		tok := p.take()
+		switch tok.text {
+		case "}":
+			return nil
+		case ";":
+		case "option":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		case "reserved":
+			if err := p.parseReserved(&enum.Reserved); err != nil {
+				return err
+			}
+		default:
+			if tok.str || !isIdent(tok.text) {
+				return p.errorf(tok, "unexpected %q in enum %s", tok.text, enum.Name)
+			}
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			number, err := p.number()
+			if err != nil {
+				return err
+			}
+			enum.Values = append(enum.Values, &EnumValue{Name: tok.text, Number: number, Pos: tok.pos})
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *parser) parseService() error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	service := &Service{Name: name.text, Pos: name.pos}
+	p.file.Services[service.Name] = service
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	for {
+		if p.done() {
+			return fmt.Errorf("%s: unexpected end of file in service %s", p.path, service.Name)
+		}
+		tok := p.take()
+		switch tok.text {
+		case "}":
+			return nil
+		case ";":
+		case "option":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		case "rpc":
+			method, err := p.parseMethod()
+			if err != nil {
+				return err
+			}
+			service.Methods = append(service.Methods, method)
+		default:
+			return p.errorf(tok, "unexpected %q in service %s", tok.text, service.Name)
+		}
+	}
+}
+
+// parseMethod parses "Name (Request) returns (Response)" and skips the
+// method's options
+func (p *parser) parseMethod() (*Method, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	method := &Method{Name: name.text, Pos: name.pos}
+
+	method.ClientStreaming, method.Request, err = p.parseMethodType()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("returns"); err != nil {
+		return nil, err
+	}
+	method.ServerStreaming, method.Response, err = p.parseMethodType()
+	if err != nil {
+		return nil, err
+	}
+	return method, p.skipStatement()
+}
+
+// parseMethodType parses "([stream] Type)"
+func (p *parser) parseMethodType() (bool, string, error) {
+	if err := p.expect("("); err != nil {
+		return false, "", err
+	}
+	typ, err := p.ident()
+	if err != nil {
+		return false, "", err
+	}
+	streaming := false
+	if typ.text == "stream" && !p.peekIs(")") {
+		streaming = true
+		if typ, err = p.ident(); err != nil {
+			return false, "", err
+		}
+	}
+	return streaming, typ.text, p.expect(")")
+}
+
+// parseReserved parses "reserved 1, 4 to 6, 10 to max;" or
+// "reserved "foo", "bar";"
+func (p *parser) parseReserved(reserved *Reserved) error {
+	for {
+		tok := p.take()
+		if tok.str {
+			reserved.Names = append(reserved.Names, tok.text)
+		} else {
+			start, err := strconv.Atoi(tok.text)
+			if err != nil {
+				return p.errorf(tok, "invalid reserved number %q", tok.text)
+			}
+			end := start
+			if p.peekIs("to") {
+				p.take()
+				limit := p.take()
+				if limit.text == "max" {
+					end = maxFieldNumber
+				} else if end, err = strconv.Atoi(limit.text); err != nil {
+					return p.errorf(limit, "invalid reserved number %q", limit.text)
+				}
+			}
+			reserved.Ranges = append(reserved.Ranges, [2]int{start, end})
+		}
+
+		sep := p.take()
+		switch sep.text {
+		case ",":
+		case ";":
+			return nil
+		default:
+			return p.errorf(sep, "expected \",\" or \";\", found %q", sep.text)
+		}
+	}
+}
+
+// skipStatement skips to the end of the current statement: a ";" or a
+// balanced "{...}" block, whichever comes first outside brackets
+func (p *parser) skipStatement() error {
+	depth := 0
+	for !p.done() {
+		tok := p.take()
+		if tok.str {
+			continue
+		}
+		switch tok.text {
+		case "[", "(":
+			depth++
+		case "]", ")":
+			depth--
+		case "{":
+			if depth == 0 {
+				return p.skipBlock()
+			}
+			depth++
+		case "}":
+			depth--
+		case ";":
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s: unexpected end of file", p.path)
+}
+
+// skipBlock skips to the brace closing an opened block
+func (p *parser) skipBlock() error {
+	depth := 1
+	for !p.done() {
+		tok := p.take()
+		if tok.str {
+			continue
+		}
+		switch tok.text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s: unexpected end of file", p.path)
+}
+
+func (p *parser) done() bool {
+	return p.next >= len(p.tokens)
+}
+
+func (p *parser) take() token {
+	if p.done() {
+		return token{pos: p.endPos()}
+	}
+	tok := p.tokens[p.next]
+	p.next++
+	return tok
+}
+
+func (p *parser) peekIs(text string) bool {
+	return !p.done() && !p.tokens[p.next].str && p.tokens[p.next].text == text
+}
+
+func (p *parser) expect(text string) error {
+	tok := p.take()
+	if tok.str || tok.text != text {
+		return p.errorf(tok, "expected %q, found %q", text, tok.text)
+	}
+	return nil
+}
+
+func (p *parser) ident() (token, error) {
+	tok := p.take()
+	if tok.str || !isIdent(tok.text) {
+		return tok, p.errorf(tok, "expected identifier, found %q", tok.text)
+	}
+	return tok, nil
+}
+
+func (p *parser) number() (int, error) {
+	tok := p.take()
+	text := tok.text
+	negative := false
+	if text == "-" {
+		negative = true
+		tok = p.take()
+		text = tok.text
+	}
+	number, err := strconv.ParseInt(text, 0, 32)
+	if err != nil {
+		return 0, p.errorf(tok, "expected number, found %q", text)
+	}
+	if negative {
+		number = -number
+	}
+	return int(number), nil
+}
+
+func (p *parser) endPos() Position {
+	if len(p.tokens) == 0 {
+		return Position{Line: 1, Column: 1}
+	}
+	return p.tokens[len(p.tokens)-1].pos
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d:%d: %s", p.path, tok.pos.Line, tok.pos.Column, fmt.Sprintf(format, args...))
+}
+
+// isIdent reports whether text is a possibly dotted identifier
+func isIdent(text string) bool {
+	if text == "" {
+		return false
+	}
+	for i, r := range strings.TrimPrefix(text, ".") {
+		if r == '_' || r == '.' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// tokenize splits proto source into tokens, dropping comments. Rules named
+// by ignore directives are returned under the line of the next token.
+func tokenize(path, source string) ([]token, map[int][]string, error) {
+	var tokens []token
+	ignores := make(map[int][]string)
+	var pending []string
+	runes := []rune(source)
+	line, column := 1, 1
+
+	emit := func(tok token) {
+		tokens = append(tokens, tok)
+		if len(pending) > 0 {
+			ignores[tok.pos.Line] = append(ignores[tok.pos.Line], pending...)
+			pending = nil
+		}
+	}
+	directive := func(comment string) {
+		if i := strings.Index(comment, ignoreDirective); i >= 0 {
+			pending = append(pending, strings.Fields(comment[i+len(ignoreDirective):])...)
+		}
+	}
+
+	advance := func(n int) {
+		for ; n > 0; n-- {
+			if runes[0] == '\n' {
+				line++
+				column = 1
+			} else {
+				column++
+			}
+			runes = runes[1:]
+		}
+	}
+
+	for len(runes) > 0 {
+		r := runes[0]
+		pos := Position{Line: line, Column: column}
+		switch {
+		case unicode.IsSpace(r):
+			advance(1)
+		case r == '/' && len(runes) > 1 && runes[1] == '/':
+			end := 0
+			for end < len(runes) && runes[end] != '\n' {
+				end++
+			}
+			directive(string(runes[2:end]))
+			advance(end)
+		case r == '/' && len(runes) > 1 && runes[1] == '*':
+			end := strings.Index(string(runes[2:]), "*/")
+			if end < 0 {
+				return nil, nil, fmt.Errorf("%s:%d:%d: unterminated comment", path, pos.Line, pos.Column)
+			}
+			directive(string(runes[2:])[:end])
+			advance(2 + len([]rune(string(runes[2:])[:end])) + 2)
+		case r == '"' || r == '\'':
+			var text strings.Builder
+			i := 1
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				if runes[i] == '\n' {
+					return nil, nil, fmt.Errorf("%s:%d:%d: unterminated string", path, pos.Line, pos.Column)
+				}
+				text.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, nil, fmt.Errorf("%s:%d:%d: unterminated string", path, pos.Line, pos.Column)
+			}
+			emit(token{text: text.String(), pos: pos, str: true})
+			advance(i + 1)
+		case r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			i := 0
+			for i < len(runes) && (runes[i] == '_' || runes[i] == '.' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			emit(token{text: string(runes[:i]), pos: pos})
+			advance(i)
+		default:
+			emit(token{text: string(r), pos: pos})
+			advance(1)
+		}
+	}
+	return tokens, ignores, nil
+}
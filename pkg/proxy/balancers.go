@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Balancing strategies accepted by NewBalancer
+const (
+	StrategyRoundRobin       = "round_robin"
+	StrategyWeighted         = "weighted"
+	StrategyLeastConnections = "least_connections"
+	StrategyConsistentHash   = "consistent_hash"
+)
+
+// NewBalancer creates a balancer for the named strategy
+func NewBalancer(strategy string) (LoadBalancer, error) {
+	switch strategy {
+	case "", StrategyRoundRobin:
+		return &RoundRobinBalancer{}, nil
+	case StrategyWeighted:
+		return NewWeightedBalancer(), nil
+	case StrategyLeastConnections:
+		return &LeastConnectionsBalancer{}, nil
+	case StrategyConsistentHash:
+		return NewConsistentHashBalancer(0), nil
+	default:
+		return nil, fmt.Errorf("unknown balancing strategy %q", strategy)
+	}
+}
+
+// parseInstance splits an instance's weight off its URL, as in
+// "http://10.0.0.7:8080;weight=3". Instances without one weigh 1.
+func parseInstance(raw string) (string, int, error) {
+	address, options, ok := strings.Cut(raw, ";")
+	if !ok {
+		return raw, 1, nil
+	}
+	value, ok := strings.CutPrefix(strings.TrimSpace(options), "weight=")
+	if !ok {
+		return "", 0, fmt.Errorf("invalid instance option %q", options)
+	}
+	weight, err := strconv.Atoi(value)
+	if err != nil || weight < 1 {
+		return "", 0, fmt.Errorf("invalid instance weight %q", value)
+	}
+	return address, weight, nil
+}
+
+// WeightedBalancer spreads requests over instances in proportion to their
+// weights, interleaving them smoothly rather than sending each instance its
+// share in a burst
+type WeightedBalancer struct {
+	mu      sync.Mutex
+	current map[string]int
+}
+
+// NewWeightedBalancer creates a smooth weighted round-robin balancer
+func NewWeightedBalancer() *WeightedBalancer {
+	return &WeightedBalancer{current: make(map[string]int)}
+}
+
+// Select selects the instance furthest behind its share, ignoring the key
+func (b *WeightedBalancer) Select(key string, instances []*Instance) *Instance {
+	if len(instances) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Instances removed from the pool leave their state behind
+	if len(b.current) > 2*len(instances) {
+		b.current = make(map[string]int)
+	}
+
+	var best *Instance
+	total := 0
+	for _, instance := range instances {
+		b.current[instance.URL] += instance.Weight
+		total += instance.Weight
+		if best == nil || b.current[instance.URL] > b.current[best.URL] {
+			best = instance
+		}
+	}
+	b.current[best.URL] -= total
+	return best
+}
+
+// LeastConnectionsBalancer sends each request to the instance with the
+// fewest requests in flight relative to its weight, so slow instances get
+// fewer requests. Ties are broken in turn.
+type LeastConnectionsBalancer struct {
+	next uint64
+}
+
+// Select selects the least loaded instance, ignoring the key
+func (b *LeastConnectionsBalancer) Select(key string, instances []*Instance) *Instance {
+	if len(instances) == 0 {
+		return nil
+	}
+
+	offset := int(atomic.AddUint64(&b.next, 1) % uint64(len(instances)))
+	var best *Instance
+	var bestLoad float64
+	for i := range instances {
+		instance := instances[(offset+i)%len(instances)]
+		load := float64(atomic.LoadInt64(&instance.inflight)+1) / float64(instance.Weight)
+		if best == nil || load < bestLoad {
+			best, bestLoad = instance, load
+		}
+	}
+	return best
+}
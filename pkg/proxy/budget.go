@@ -0,0 +1,242 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"microservices-platform/pkg/incidents"
+	"microservices-platform/pkg/metrics"
+	"microservices-platform/pkg/resilience"
+)
+
+// ErrorBudgetSettings controls error budget burn alerting. A service burns
+// its budget at 1 when its share of server errors is exactly 1 - Objective.
+// It is burning too fast when the burn rate is at least BurnRateThreshold
+// over both the short and the long window: the long window shows the burn
+// matters, the short one that it is still going on.
+type ErrorBudgetSettings struct {
+	Objective         float64
+	BurnRateThreshold float64
+	ShortWindow       time.Duration
+	LongWindow        time.Duration
+	CheckInterval     time.Duration
+	MinRequests       int64
+}
+
+// DefaultErrorBudgetSettings returns default error budget settings
+func DefaultErrorBudgetSettings() ErrorBudgetSettings {
+	return ErrorBudgetSettings{
+		Objective:         0.999,
+		BurnRateThreshold: 14.4,
+		ShortWindow:       5 * time.Minute,
+		LongWindow:        time.Hour,
+		CheckInterval:     time.Minute,
+		MinRequests:       100,
+	}
+}
+
+// budgetBucket counts one check interval's requests
+type budgetBucket struct {
+	requests int64
+	errors   int64
+}
+
+// serviceBudget holds a service's buckets over the long window, oldest
+// first, the last being the current interval
+type serviceBudget struct {
+	buckets []budgetBucket
+	burning bool
+}
+
+// ErrorBudgetTracker tracks how fast each service burns its error budget
+// and reports services burning it too fast as incidents
+type ErrorBudgetTracker struct {
+	settings ErrorBudgetSettings
+	reporter *incidents.Reporter
+
+	mu       sync.Mutex
+	services map[string]*serviceBudget
+}
+
+// NewErrorBudgetTracker creates a new error budget tracker
+func NewErrorBudgetTracker(settings ErrorBudgetSettings, reporter *incidents.Reporter) *ErrorBudgetTracker {
+	defaults := DefaultErrorBudgetSettings()
+	if settings.Objective <= 0 || settings.Objective >= 1 {
+		settings.Objective = defaults.Objective
+	}
+	if settings.BurnRateThreshold <= 0 {
+		settings.BurnRateThreshold = defaults.BurnRateThreshold
+	}
+	if settings.CheckInterval <= 0 {
+		settings.CheckInterval = defaults.CheckInterval
+	}
+	if settings.ShortWindow < settings.CheckInterval {
+		settings.ShortWindow = settings.CheckInterval
+	}
+	if settings.LongWindow < settings.ShortWindow {
+		settings.LongWindow = settings.ShortWindow
+	}
+
+	return &ErrorBudgetTracker{
+		settings: settings,
+		reporter: reporter,
+		services: make(map[string]*serviceBudget),
+	}
+}
+
+// Observe records a proxied request's outcome
+func (t *ErrorBudgetTracker) Observe(service string, serverError bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	budget, ok := t.services[service]
+	if !ok {
+		budget = &serviceBudget{buckets: make([]budgetBucket, 1)}
+		t.services[service] = budget
+	}
+	current := &budget.buckets[len(budget.buckets)-1]
+	current.requests++
+	if serverError {
+		current.errors++
+	}
+}
+
+// Start checks burn rates every check interval until ctx is cancelled
+func (t *ErrorBudgetTracker) Start(ctx context.Context) {
+	ticker := time.NewTicker(t.settings.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.check(ctx)
+		}
+	}
+}
+
+// check closes the current interval, reports services that started or
+// stopped burning their budget too fast and starts a new interval
+func (t *ErrorBudgetTracker) check(ctx context.Context) {
+	shortBuckets := int(t.settings.ShortWindow / t.settings.CheckInterval)
+	longBuckets := int(t.settings.LongWindow / t.settings.CheckInterval)
+
+	type change struct {
+		service string
+		burning bool
+		short   float64
+		long    float64
+	}
+	var changes []change
+
+	t.mu.Lock()
+	for service, budget := range t.services {
+		short := t.burnRate(budget.buckets, shortBuckets)
+		long, requests := t.burnRate(budget.buckets, longBuckets), sumRequests(budget.buckets, longBuckets)
+		metrics.GatewayErrorBudgetBurnRate.WithLabelValues(service, "short").Set(short)
+		metrics.GatewayErrorBudgetBurnRate.WithLabelValues(service, "long").Set(long)
+
+		burning := requests >= t.settings.MinRequests &&
+			short >= t.settings.BurnRateThreshold && long >= t.settings.BurnRateThreshold
+		if burning != budget.burning {
+			budget.burning = burning
+			changes = append(changes, change{service: service, burning: burning, short: short, long: long})
+		}
+
+		budget.buckets = append(budget.buckets, budgetBucket{})
+		if len(budget.buckets) > longBuckets {
+			budget.buckets = budget.buckets[len(budget.buckets)-longBuckets:]
+		}
+	}
+	t.mu.Unlock()
+
+	if t.reporter == nil {
+		return
+	}
+	for _, c := range changes {
+		if !c.burning {
+			t.reporter.Resolve(ctx, c.service, incidents.KindErrorBudgetBurn,
+				fmt.Sprintf("error budget burn rate back to %.1fx", c.short))
+			continue
+		}
+		t.reporter.Fire(ctx, incidents.Signal{
+			Service:  c.service,
+			Kind:     incidents.KindErrorBudgetBurn,
+			Severity: incidents.SeverityCritical,
+			Summary: fmt.Sprintf("burning its %.2f%% error budget at %.1fx over %s and %.1fx over %s",
+				t.settings.Objective*100, c.short, t.settings.ShortWindow, c.long, t.settings.LongWindow),
+			Details: map[string]interface{}{
+				"objective":         t.settings.Objective,
+				"burn_rate_short":   c.short,
+				"burn_rate_long":    c.long,
+				"short_window":      t.settings.ShortWindow.String(),
+				"long_window":       t.settings.LongWindow.String(),
+				"burn_rate_trigger": t.settings.BurnRateThreshold,
+			},
+		})
+	}
+}
+
+// burnRate is the error ratio of the last n buckets relative to the budget
+func (t *ErrorBudgetTracker) burnRate(buckets []budgetBucket, n int) float64 {
+	var requests, errors int64
+	for _, bucket := range lastBuckets(buckets, n) {
+		requests += bucket.requests
+		errors += bucket.errors
+	}
+	if requests == 0 {
+		return 0
+	}
+	return float64(errors) / float64(requests) / (1 - t.settings.Objective)
+}
+
+func sumRequests(buckets []budgetBucket, n int) int64 {
+	var requests int64
+	for _, bucket := range lastBuckets(buckets, n) {
+		requests += bucket.requests
+	}
+	return requests
+}
+
+func lastBuckets(buckets []budgetBucket, n int) []budgetBucket {
+	if n < len(buckets) {
+		return buckets[len(buckets)-n:]
+	}
+	return buckets
+}
+
+// TrackErrorBudgets makes the gateway track each service's error budget and
+// report services burning it too fast to reporter. The caller runs the
+// returned tracker's Start.
+func (g *Gateway) TrackErrorBudgets(settings ErrorBudgetSettings, reporter *incidents.Reporter) *ErrorBudgetTracker {
+	g.budgets = NewErrorBudgetTracker(settings, reporter)
+	return g.budgets
+}
+
+// ReportCircuitBreakers reports each service's circuit breaker opening as an
+// incident, resolved once the circuit closes again
+func (g *Gateway) ReportCircuitBreakers(reporter *incidents.Reporter) {
+	for name, service := range g.services {
+		name := name
+		// The breaker changes state on a request's goroutine, which should
+		// not wait for the event to be published
+		service.CircuitBreaker.OnStateChange(func(from, to resilience.CircuitBreakerState) {
+			ctx := context.Background()
+			switch to {
+			case resilience.StateOpen:
+				go reporter.Fire(ctx, incidents.Signal{
+					Service:  name,
+					Kind:     incidents.KindCircuitOpen,
+					Severity: incidents.SeverityCritical,
+					Summary:  "circuit breaker opened, requests are failing fast",
+					Details:  map[string]interface{}{"previous_state": from.String()},
+				})
+			case resilience.StateClosed:
+				go reporter.Resolve(ctx, name, incidents.KindCircuitOpen, "circuit breaker closed")
+			}
+		})
+	}
+}
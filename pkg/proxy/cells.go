@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cellDrainRetryAfter is how long clients are told to wait before retrying
+// a write held while their tenant moves between cells
+const cellDrainRetryAfter = 30 * time.Second
+
+// CellPlacer tells the gateway which cell serves a tenant, and whether the
+// tenant's writes are held while it moves to another cell
+type CellPlacer interface {
+	Placement(tenant string) (cell string, draining bool)
+}
+
+// PlaceTenantsInCells routes each tenant to the cell placer puts it in. A
+// cell is the pool of that name of each service; services without one serve
+// the tenant from its usual pool. Writes of a tenant that is moving are
+// refused with 503 Service Unavailable until the move completes.
+func (g *Gateway) PlaceTenantsInCells(placer CellPlacer) {
+	g.cells = placer
+}
+
+// cellPool returns the pool of the cell serving a tenant, if the service has
+// one
+func (g *Gateway) cellPool(service *ServiceConfig, tenant string) (*instancePool, bool) {
+	if g.cells == nil || tenant == "" {
+		return nil, false
+	}
+	cell, _ := g.cells.Placement(tenant)
+	pool, ok := service.pools[cell]
+	return pool, ok
+}
+
+// holdDrainingWrite refuses a write of a tenant that is moving between cells
+// and reports whether it did
+func (g *Gateway) holdDrainingWrite(c *gin.Context) bool {
+	if g.cells == nil {
+		return false
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	tenant := g.requestTenant(c)
+	if tenant == "" {
+		return false
+	}
+	if _, draining := g.cells.Placement(tenant); !draining {
+		return false
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(cellDrainRetryAfter.Seconds())))
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error": "Tenant is being migrated, retry later",
+	})
+	return true
+}
@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"net/http"
+	"net/textproto"
+
+	"microservices-platform/pkg/versioning"
+)
+
+// Headers the services' HTTP transcoding uses for gRPC response metadata
+var (
+	etagMetadataHeader               = textproto.CanonicalMIMEHeaderKey("Grpc-Metadata-" + versioning.MetadataETag)
+	preconditionFailedMetadataHeader = textproto.CanonicalMIMEHeaderKey("Grpc-Metadata-" + versioning.MetadataPreconditionFailed)
+)
+
+// conditionalResponse returns a resource version set as gRPC metadata as the
+// ETag header, and turns a write rejected by If-Match into 412 Precondition
+// Failed
+func conditionalResponse(resp *http.Response) {
+	if etag := resp.Header.Get(etagMetadataHeader); etag != "" {
+		resp.Header.Del(etagMetadataHeader)
+		resp.Header.Set("ETag", etag)
+	}
+
+	if resp.Header.Get(preconditionFailedMetadataHeader) == "" {
+		return
+	}
+	resp.Header.Del(preconditionFailedMetadataHeader)
+	if resp.StatusCode < http.StatusBadRequest {
+		return
+	}
+	resp.StatusCode = http.StatusPreconditionFailed
+	resp.Status = "412 Precondition Failed"
+}
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultSessionCookie is the cookie HashByUserOrSession falls back to for
+// anonymous requests
+const DefaultSessionCookie = "session_id"
+
+// defaultHashReplicas is the number of points each instance gets on the ring
+const defaultHashReplicas = 160
+
+// maxCachedRings caps the rings kept for distinct instance sets. Ejections and
+// slow-starting instances make the candidate set alternate between a few.
+const maxCachedRings = 8
+
+// HashKeyFunc derives the key a ConsistentHashBalancer routes a request by
+type HashKeyFunc func(c *gin.Context) string
+
+// HashByUserOrSession keys requests by the authenticated user, then by the
+// given session cookie, then by client IP
+func HashByUserOrSession(cookie string) HashKeyFunc {
+	return func(c *gin.Context) string {
+		if userID := c.GetString("user_id"); userID != "" {
+			return "user:" + userID
+		}
+		if session, err := c.Cookie(cookie); err == nil && session != "" {
+			return "session:" + session
+		}
+		return "ip:" + c.ClientIP()
+	}
+}
+
+// ConsistentHashBalancer sends requests with the same key to the same
+// instance, so instance-local caches stay warm. When an instance is ejected
+// or added, only the keys it owns move.
+type ConsistentHashBalancer struct {
+	replicas int
+
+	mu    sync.Mutex
+	rings map[string][]ringPoint
+}
+
+// ringPoint is one point on the hash ring
+type ringPoint struct {
+	hash     uint64
+	instance *Instance
+}
+
+// NewConsistentHashBalancer creates a consistent-hash balancer placing each
+// instance at replicas points on the ring. replicas <= 0 uses the default.
+func NewConsistentHashBalancer(replicas int) *ConsistentHashBalancer {
+	if replicas <= 0 {
+		replicas = defaultHashReplicas
+	}
+	return &ConsistentHashBalancer{
+		replicas: replicas,
+		rings:    make(map[string][]ringPoint),
+	}
+}
+
+// Select selects the instance owning the key's point on the ring
+func (b *ConsistentHashBalancer) Select(key string, instances []*Instance) *Instance {
+	if len(instances) == 0 {
+		return nil
+	}
+
+	ring := b.ringFor(instances)
+	hash := hashString(key)
+	i := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= hash })
+	if i == len(ring) {
+		i = 0
+	}
+	return ring[i].instance
+}
+
+// ringFor returns the ring for a set of instances, building it if needed.
+// Points are derived from instance URLs so every gateway replica builds the
+// same ring.
+func (b *ConsistentHashBalancer) ringFor(instances []*Instance) []ringPoint {
+	urls := make([]string, len(instances))
+	for i, instance := range instances {
+		urls[i] = instance.URL
+	}
+	setKey := strings.Join(urls, ",")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ring, ok := b.rings[setKey]; ok {
+		return ring
+	}
+
+	ring := make([]ringPoint, 0, len(instances)*b.replicas)
+	for _, instance := range instances {
+		for replica := 0; replica < b.replicas; replica++ {
+			ring = append(ring, ringPoint{
+				hash:     hashString(instance.URL + "#" + strconv.Itoa(replica)),
+				instance: instance,
+			})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	if len(b.rings) >= maxCachedRings {
+		b.rings = make(map[string][]ringPoint)
+	}
+	b.rings[setKey] = ring
+	return ring
+}
+
+// hashString hashes a string onto the ring. FNV-1a is finalized with a
+// 64-bit mixer since similar keys otherwise land close together.
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
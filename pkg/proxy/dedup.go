@@ -0,0 +1,222 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// dedupVaryHeaders are the client headers that select which response a
+// service returns, so requests only share a response if they agree on them
+var dedupVaryHeaders = []string{
+	"Accept",
+	"Accept-Encoding",
+	"Accept-Language",
+	"If-Modified-Since",
+	"If-None-Match",
+}
+
+// errNotShareable is returned to requests waiting on a response that must
+// not be shared, which then make their own upstream call
+var errNotShareable = errors.New("response is not shareable")
+
+// DeduplicateRequests makes identical concurrent GET requests share one
+// upstream call, so a burst of requests for a hot key reaches the service
+// once. Requests are identical when they go to the same service and URL on
+// behalf of the same caller. Routes in exclude, keyed like route timeouts
+// ("GET /api/v1/route/:param"), always make their own call.
+func (g *Gateway) DeduplicateRequests(exclude []string) {
+	g.inflight = &requestGroup{calls: make(map[string]*sharedCall)}
+	g.dedupExclude = make(map[string]bool, len(exclude))
+	for _, route := range exclude {
+		g.dedupExclude[strings.TrimSpace(route)] = true
+	}
+}
+
+// dedupKey returns the key a request shares its upstream call under, or false
+// if the request makes a call of its own
+func (g *Gateway) dedupKey(c *gin.Context, service *ServiceConfig) (string, bool) {
+	if g.inflight == nil || c.Request.Method != http.MethodGet || c.Request.ContentLength > 0 {
+		return "", false
+	}
+	if g.dedupExclude[routeKey(c)] {
+		return "", false
+	}
+
+	// The caller fields cover everything setIdentityHeaders forwards, except
+	// the client address and request ID, which don't change the response
+	parts := []string{
+		service.Name,
+		c.Request.URL.RequestURI(),
+		c.GetString("user_id"),
+		c.GetString("email"),
+		c.GetString("role"),
+		strings.Join(c.GetStringSlice("roles"), ","),
+		c.GetString("tenant_id"),
+		c.GetString("impersonator_id"),
+		c.GetString("locale"),
+		c.GetString("time_zone"),
+	}
+	if g.tenantHeader != "" {
+		parts = append(parts, c.GetHeader(g.tenantHeader))
+	}
+	for _, name := range dedupVaryHeaders {
+		parts = append(parts, strings.Join(c.Request.Header.Values(name), ","))
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+// requestGroup tracks the upstream calls in flight by key
+type requestGroup struct {
+	mu    sync.Mutex
+	calls map[string]*sharedCall
+}
+
+// sharedCall is an upstream call shared by identical requests. resp and err
+// are set before done is closed.
+type sharedCall struct {
+	done chan struct{}
+	resp *sharedResponse
+	err  error
+}
+
+// sharedResponse is an upstream response read in full, so every request
+// sharing it gets its own copy
+type sharedResponse struct {
+	status     string
+	statusCode int
+	proto      string
+	protoMajor int
+	protoMinor int
+	header     http.Header
+	body       []byte
+}
+
+// join returns the call in flight for key, starting one if there is none.
+// The caller that started the call is its leader and must make it.
+func (g *requestGroup) join(key string) (*sharedCall, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if call, ok := g.calls[key]; ok {
+		return call, false
+	}
+	call := &sharedCall{done: make(chan struct{})}
+	g.calls[key] = call
+	return call, true
+}
+
+// finish completes a call. Requests arriving after it start a new one.
+func (g *requestGroup) finish(key string, call *sharedCall) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(call.done)
+}
+
+// sharedTransport sends a request through the call in flight for its key.
+// shared is set when the request was answered by another request's call.
+type sharedTransport struct {
+	group     *requestGroup
+	key       string
+	service   string
+	route     string
+	transport http.RoundTripper
+	shared    *bool
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *sharedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	call, leader := t.group.join(t.key)
+	if leader {
+		// The call outlives the leader's client going away, so that does not
+		// fail the requests waiting on it, but keeps the leader's deadline
+		ctx := context.WithoutCancel(req.Context())
+		cancel := context.CancelFunc(func() {})
+		if deadline, ok := req.Context().Deadline(); ok {
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+		}
+		outreq := req.Clone(ctx)
+		go func() {
+			defer cancel()
+			call.resp, call.err = readResponse(t.transport.RoundTrip(outreq))
+			t.group.finish(t.key, call)
+		}()
+	}
+
+	select {
+	case <-call.done:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	if leader {
+		if call.resp == nil {
+			return nil, call.err
+		}
+		return call.resp.response(req), nil
+	}
+
+	// The leader's request records the outcome against the instance
+	switch {
+	case errors.Is(call.err, errNotShareable):
+		return t.transport.RoundTrip(req)
+	case call.err != nil:
+		*t.shared = true
+		return nil, call.err
+	}
+	*t.shared = true
+	metrics.GatewayDeduplicatedRequestsTotal.WithLabelValues(t.service, t.route).Inc()
+	return call.resp.response(req), nil
+}
+
+// readResponse reads an upstream response in full. A response setting
+// cookies is returned with errNotShareable, since the cookies belong to the
+// leader's caller alone.
+func readResponse(resp *http.Response, err error) (*sharedResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	shared := &sharedResponse{
+		status:     resp.Status,
+		statusCode: resp.StatusCode,
+		proto:      resp.Proto,
+		protoMajor: resp.ProtoMajor,
+		protoMinor: resp.ProtoMinor,
+		header:     resp.Header,
+		body:       body,
+	}
+	if len(resp.Header.Values("Set-Cookie")) > 0 {
+		return shared, errNotShareable
+	}
+	return shared, nil
+}
+
+// response returns a copy of the response for req
+func (r *sharedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        r.status,
+		StatusCode:    r.statusCode,
+		Proto:         r.proto,
+		ProtoMajor:    r.protoMajor,
+		ProtoMinor:    r.protoMinor,
+		Header:        r.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,216 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListEnvelope is the shape of every list response the gateway returns,
+// whichever service the list comes from
+type ListEnvelope struct {
+	Data       interface{} `json:"data"`
+	Pagination Pagination  `json:"pagination"`
+	// Meta holds the other fields of a service's list response, such as a
+	// count of unread notifications
+	Meta      map[string]json.RawMessage `json:"meta,omitempty"`
+	RequestID string                     `json:"request_id,omitempty"`
+}
+
+// Pagination locates a page in its list. NextCursor is empty on the last
+// page; passing it as the cursor query parameter, with the same filters,
+// returns the next page.
+type Pagination struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int64  `json:"total"`
+}
+
+// Pagination fields of the services' list responses
+const (
+	pageField      = "page"
+	pageSizeField  = "page_size"
+	totalField     = "total_count"
+	cursorParam    = "cursor"
+	listItemsKey   = "proxy_list_items"
+	maxCursorBytes = 256
+)
+
+// errInvalidCursor is returned for a cursor the gateway did not issue
+var errInvalidCursor = errors.New("invalid cursor")
+
+// WriteList writes items as a list response of total items without further
+// pages
+func WriteList(c *gin.Context, items interface{}, total int) {
+	c.JSON(http.StatusOK, ListEnvelope{
+		Data:       items,
+		Pagination: Pagination{Total: int64(total)},
+		RequestID:  c.GetString("request_id"),
+	})
+}
+
+// ListProxyHandler proxies a list endpoint of a service, returning the items
+// in itemsField of the service's response in the list envelope. A cursor
+// query parameter is turned back into the page it points at.
+func (g *Gateway) ListProxyHandler(serviceName, itemsField string) gin.HandlerFunc {
+	proxy := g.ProxyHandler(serviceName)
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		if cursor := query.Get(cursorParam); cursor != "" {
+			page, pageSize, err := decodeCursor(cursor)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
+			query.Del(cursorParam)
+			query.Set(pageField, strconv.Itoa(page))
+			query.Set(pageSizeField, strconv.Itoa(pageSize))
+			c.Request.URL.RawQuery = query.Encode()
+		}
+		c.Set(listItemsKey, itemsField)
+		proxy(c)
+	}
+}
+
+// envelopeList rewrites a successful JSON list response into the list
+// envelope. Services paginate by page number, so the next cursor is the next
+// page of the same size when the total runs past this one.
+func envelopeList(resp *http.Response, itemsField, requestID string) error {
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "application/json" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		// Not a list after all; pass it on as it came
+		setBody(resp, body)
+		return nil
+	}
+
+	items, ok := fields[itemsField]
+	if !ok || bytes.Equal(items, []byte("null")) {
+		items = json.RawMessage("[]")
+	}
+	var count []json.RawMessage
+	if err := json.Unmarshal(items, &count); err != nil {
+		setBody(resp, body)
+		return nil
+	}
+
+	query := resp.Request.URL.Query()
+	page := intField(fields, pageField, intParam(query, pageField))
+	pageSize := intField(fields, pageSizeField, intParam(query, pageSizeField))
+	paginated := pageSize > 0 || fields[totalField] != nil
+	total := intField(fields, totalField, 0)
+
+	envelope := ListEnvelope{Data: items, RequestID: requestID}
+	if paginated {
+		if page < 1 {
+			page = 1
+		}
+		// A service applying its default page size returns a full page
+		if pageSize <= 0 {
+			pageSize = len(count)
+		}
+		if seen := (page-1)*pageSize + len(count); total < seen {
+			total = seen
+		}
+		envelope.Pagination.Total = int64(total)
+		if pageSize > 0 && len(count) > 0 && (page-1)*pageSize+len(count) < total {
+			envelope.Pagination.NextCursor = encodeCursor(page+1, pageSize)
+		}
+	} else {
+		envelope.Pagination.Total = int64(len(count))
+	}
+
+	for _, name := range []string{itemsField, pageField, pageSizeField, totalField} {
+		delete(fields, name)
+	}
+	if len(fields) > 0 {
+		envelope.Meta = fields
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	setBody(resp, data)
+	return nil
+}
+
+// setBody replaces the body of resp
+func setBody(resp *http.Response, body []byte) {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
+
+// intField reads a number from a list response. proto3 JSON may encode it
+// as a string and leaves it out when it is zero.
+func intField(fields map[string]json.RawMessage, name string, fallback int) int {
+	raw, ok := fields[name]
+	if !ok {
+		return fallback
+	}
+	var value json.Number
+	if err := json.Unmarshal(raw, &value); err != nil {
+		var text string
+		if json.Unmarshal(raw, &text) != nil {
+			return fallback
+		}
+		value = json.Number(text)
+	}
+	n, err := value.Int64()
+	if err != nil {
+		return fallback
+	}
+	return int(n)
+}
+
+func intParam(query url.Values, name string) int {
+	n, _ := strconv.Atoi(query.Get(name))
+	return n
+}
+
+// encodeCursor encodes the page a cursor points at. Cursors are opaque to
+// clients, so services can move to keyset pagination behind them.
+func encodeCursor(page, pageSize int) string {
+	values := url.Values{}
+	values.Set(pageField, strconv.Itoa(page))
+	values.Set(pageSizeField, strconv.Itoa(pageSize))
+	return base64.RawURLEncoding.EncodeToString([]byte(values.Encode()))
+}
+
+func decodeCursor(cursor string) (page, pageSize int, err error) {
+	if len(cursor) > maxCursorBytes {
+		return 0, 0, errInvalidCursor
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, errInvalidCursor
+	}
+	values, err := url.ParseQuery(string(decoded))
+	if err != nil {
+		return 0, 0, errInvalidCursor
+	}
+	page, pageSize = intParam(values, pageField), intParam(values, pageSizeField)
+	if page < 1 || pageSize < 1 {
+		return 0, 0, errInvalidCursor
+	}
+	return page, pageSize, nil
+}
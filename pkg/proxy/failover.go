@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"microservices-platform/pkg/resilience"
+)
+
+// failoverTarget is a service's deployment in a secondary region. It has its
+// own circuit breaker, so failures there don't keep the local one open.
+type failoverTarget struct {
+	region  string
+	pool    *instancePool
+	breaker *resilience.CircuitBreaker
+
+	mu sync.Mutex
+	// unhealthy is set while the local health check fails
+	unhealthy bool
+}
+
+// setUnhealthy records the outcome of the local health check and reports
+// whether it changed
+func (f *failoverTarget) setUnhealthy(unhealthy bool) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	changed := f.unhealthy != unhealthy
+	f.unhealthy = unhealthy
+	return changed
+}
+
+// localUnhealthy reports whether the last local health check failed
+func (f *failoverTarget) localUnhealthy() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.unhealthy
+}
+
+// localDown reports whether the service can't take requests in this region:
+// its circuit breaker is open, every instance of the pool is ejected or
+// failing its health checks, or its health check fails
+func (s *ServiceConfig) localDown(pool *instancePool) bool {
+	if s.CircuitBreaker.IsOpen() || pool.allExcluded() {
+		return true
+	}
+	return s.failover != nil && s.failover.localUnhealthy()
+}
+
+// shedToFailover reports whether a request goes to the secondary region
+// while the local circuit breaker ramps traffic back up after closing. The
+// requests it does not admit yet stay in the secondary region, unless the
+// breaker there is open.
+func (s *ServiceConfig) shedToFailover() bool {
+	return !s.failover.breaker.IsOpen() && !s.CircuitBreaker.AdmitSlowStart()
+}
+
+// FailoverWatcher health checks the local instances of services with a
+// failover region, so their requests move to the secondary region while the
+// checks fail and come back once they pass
+type FailoverWatcher struct {
+	gateway  *Gateway
+	interval time.Duration
+}
+
+// WatchFailover returns a watcher checking services with a failover region
+// every interval. The caller runs the returned watcher's Start.
+func (g *Gateway) WatchFailover(interval time.Duration) *FailoverWatcher {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &FailoverWatcher{gateway: g, interval: interval}
+}
+
+// Start runs the health checks until ctx is cancelled
+func (w *FailoverWatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.check()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check runs the health check of every service with a failover region
+func (w *FailoverWatcher) check() {
+	for _, service := range w.gateway.services {
+		if service.failover == nil {
+			continue
+		}
+		healthy, details := w.gateway.checkServiceHealth(service)
+		if !service.failover.setUnhealthy(!healthy) {
+			continue
+		}
+		if healthy {
+			log.Printf("Service %s is healthy again, routing requests to local instances", service.Name)
+		} else {
+			log.Printf("WARNING: service %s is unhealthy (%s), failing over to region %s", service.Name, details, service.failover.region)
+		}
+	}
+}
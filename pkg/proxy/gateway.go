@@ -1,15 +1,15 @@
 package proxy
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,23 +17,89 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
+	"microservices-platform/pkg/metrics"
 	"microservices-platform/pkg/resilience"
 )
 
 // ServiceConfig defines configuration for a service
 type ServiceConfig struct {
 	Name        string
+	// URL defaults to the first instance and is used for health checks
 	URL         string
+	// Instances lists the service's upstream instance URLs, each optionally
+	// weighted as in "http://10.0.0.7:8080;weight=3". Defaults to URL.
+	Instances   []string
+	// Resolver, when set, looks up the instances instead, at registration
+	// and then periodically, see ResolveInstances
+	Resolver    Resolver
+	// Balancer selects an instance per request. Defaults to round-robin.
+	Balancer    LoadBalancer
+	// HashKey derives the balancer key of a request, used by key-based
+	// balancers. Defaults to HashByUserOrSession(DefaultSessionCookie).
+	HashKey     HashKeyFunc
+	// Pools lists dedicated instance pools by name, and TenantPools maps
+	// tenants to the pool serving them. Other tenants use Instances.
+	Pools       map[string][]string
+	TenantPools map[string]string
 	HealthPath  string
+	// Timeout applies to routes without a route timeout override
 	Timeout     time.Duration
-	Retries     int
+	// MaxBodySize bounds request bodies in bytes. Defaults to
+	// DefaultMaxBodySize.
+	MaxBodySize int64
+	// Routes overrides the timeout and body limits of individual routes,
+	// keyed by "METHOD /route/:param"
+	Routes      map[string]RouteLimits
+	// Retries is how many times a failed GET or HEAD is retried, waiting
+	// RetryBackoff between attempts. RetryBudget caps the service's retries
+	// at a share of its requests, DefaultRetryBudget by default.
+	Retries      int
+	RetryBackoff resilience.Retry
+	RetryBudget  float64
 	CircuitBreaker *resilience.CircuitBreaker
+	// Headers controls which client headers are forwarded to the service
+	Headers     HeaderPolicy
+	// Transport tunes the connections kept to the service's instances
+	Transport   TransportSettings
+	// Failover lists the service's instances in FailoverRegion. Requests go
+	// there while the service is down locally, see localDown.
+	Failover       []string
+	FailoverRegion string
+
+	pool        *instancePool
+	pools       map[string]*instancePool
+	failover    *failoverTarget
+	transport   *http.Transport
+	retryBudget *retryBudget
 }
 
 // Gateway represents the API Gateway with reverse proxy capabilities
 type Gateway struct {
 	services map[string]*ServiceConfig
 	tracer   trace.Tracer
+
+	identitySecret string
+	identityTTL    time.Duration
+
+	// routeTimeouts overrides service timeouts, keyed by "METHOD /route/:param"
+	routeTimeouts map[string]time.Duration
+	// routeOverrides overrides route limits at runtime, see SetRouteLimits
+	overridesMu    sync.RWMutex
+	routeOverrides map[string]RouteLimits
+	latency        *RouteLatencyTracker
+	budgets        *ErrorBudgetTracker
+
+	outliers *OutlierDetectionSettings
+
+	// tenantHeader names a header carrying the tenant for tenant pool routing
+	// when the request has no verified tenant
+	tenantHeader string
+	cells        CellPlacer
+
+	// inflight holds the GET requests in flight for deduplication, except on
+	// routes in dedupExclude
+	inflight     *requestGroup
+	dedupExclude map[string]bool
 }
 
 // NewGateway creates a new API Gateway
@@ -45,15 +111,217 @@ func NewGateway() *Gateway {
 }
 
 // RegisterService registers a service with the gateway
-func (g *Gateway) RegisterService(service *ServiceConfig) {
+func (g *Gateway) RegisterService(service *ServiceConfig) error {
 	if service.CircuitBreaker == nil {
 		service.CircuitBreaker = resilience.NewCircuitBreaker(resilience.DefaultSettings())
 	}
 	if service.Timeout == 0 {
 		service.Timeout = 30 * time.Second
 	}
+	if service.MaxBodySize <= 0 {
+		service.MaxBodySize = DefaultMaxBodySize
+	}
+	if len(service.Instances) == 0 {
+		service.Instances = []string{service.URL}
+	}
+	if service.URL == "" {
+		service.URL = service.Instances[0]
+	}
+	if service.Balancer == nil {
+		service.Balancer = &RoundRobinBalancer{}
+	}
+	if service.HashKey == nil {
+		service.HashKey = HashByUserOrSession(DefaultSessionCookie)
+	}
+	if service.RetryBackoff.BaseDelay <= 0 {
+		service.RetryBackoff = DefaultRetryBackoff()
+	}
+	if service.RetryBudget <= 0 {
+		service.RetryBudget = DefaultRetryBudget
+	}
+	service.retryBudget = newRetryBudget(service.RetryBudget)
+
+	if service.Resolver != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		urls, err := service.Resolver.Resolve(ctx)
+		cancel()
+		if err == nil && len(urls) > 0 {
+			service.Instances = urls
+		} else {
+			log.Printf("Failed to resolve instances of %s, starting with %s: %v", service.Name, strings.Join(service.Instances, ", "), err)
+		}
+	}
+
+	pool, err := newInstancePool(service.Name, service.Instances, service.Balancer)
+	if err != nil {
+		return fmt.Errorf("failed to register service %s: %v", service.Name, err)
+	}
+	pool.outliers = g.outliers
+	service.pool = pool
+
+	service.pools = make(map[string]*instancePool, len(service.Pools))
+	for name, instances := range service.Pools {
+		dedicated, err := newInstancePool(service.Name+"/"+name, instances, newBalancerLike(service.Balancer))
+		if err != nil {
+			return fmt.Errorf("failed to register service %s pool %s: %v", service.Name, name, err)
+		}
+		dedicated.outliers = g.outliers
+		service.pools[name] = dedicated
+	}
+	for tenant, name := range service.TenantPools {
+		if _, ok := service.pools[name]; !ok {
+			return fmt.Errorf("failed to register service %s: tenant %s maps to unknown pool %s", service.Name, tenant, name)
+		}
+	}
+
+	if len(service.Failover) > 0 {
+		region := service.FailoverRegion
+		if region == "" {
+			region = "secondary"
+		}
+		failover, err := newInstancePool(service.Name+"@"+region, service.Failover, newBalancerLike(service.Balancer))
+		if err != nil {
+			return fmt.Errorf("failed to register service %s failover: %v", service.Name, err)
+		}
+		failover.outliers = g.outliers
+		service.failover = &failoverTarget{
+			region:  region,
+			pool:    failover,
+			breaker: resilience.NewCircuitBreaker(resilience.DefaultSettings()),
+		}
+	}
+
+	// Proxies are built once, so requests reuse the transport's connections
+	service.transport = newTransport(service.Transport)
+	for _, pool := range service.allPools() {
+		for _, instance := range pool.instances {
+			instance.proxy = g.newReverseProxy(service, instance)
+		}
+	}
+
 	g.services[service.Name] = service
-	log.Printf("Registered service: %s -> %s", service.Name, service.URL)
+	log.Printf("Registered service: %s -> %s", service.Name, strings.Join(service.Instances, ", "))
+	return nil
+}
+
+// DetectOutliers makes the gateway eject outlier instances of every
+// registered service from the balancer. The caller runs the returned
+// detector's Start.
+func (g *Gateway) DetectOutliers(settings OutlierDetectionSettings) *OutlierDetector {
+	if settings.Interval <= 0 {
+		settings.Interval = DefaultOutlierDetectionSettings().Interval
+	}
+	g.outliers = &settings
+	detector := &OutlierDetector{settings: g.outliers}
+	for _, service := range g.services {
+		for _, pool := range service.allPools() {
+			pool.mu.Lock()
+			pool.outliers = g.outliers
+			pool.mu.Unlock()
+			detector.pools = append(detector.pools, pool)
+		}
+	}
+	return detector
+}
+
+// RouteTenantsByHeader makes requests without a verified tenant use the
+// tenant named in header for tenant pool routing. Only use it behind an
+// edge proxy that sets the header, since clients can send any value.
+func (g *Gateway) RouteTenantsByHeader(header string) {
+	g.tenantHeader = header
+}
+
+// tenantPool returns the pool serving the request's tenant: that of its
+// cell, else its dedicated pool, else the shared one
+func (g *Gateway) tenantPool(c *gin.Context, service *ServiceConfig) *instancePool {
+	if len(service.TenantPools) == 0 && g.cells == nil {
+		return service.pool
+	}
+
+	tenant := g.requestTenant(c)
+	if pool, ok := g.cellPool(service, tenant); ok {
+		return pool
+	}
+	if name, ok := service.TenantPools[tenant]; ok && tenant != "" {
+		return service.pools[name]
+	}
+	return service.pool
+}
+
+// requestTenant returns the request's verified tenant, or the tenant named
+// in the tenant header
+func (g *Gateway) requestTenant(c *gin.Context) string {
+	tenant := c.GetString("tenant_id")
+	if tenant == "" && g.tenantHeader != "" {
+		tenant = c.GetHeader(g.tenantHeader)
+	}
+	return tenant
+}
+
+// allPools returns the service's shared pool followed by its dedicated pools
+// and its failover pool
+func (s *ServiceConfig) allPools() []*instancePool {
+	pools := []*instancePool{s.pool}
+	for _, pool := range s.pools {
+		pools = append(pools, pool)
+	}
+	if s.failover != nil {
+		pools = append(pools, s.failover.pool)
+	}
+	return pools
+}
+
+// SignIdentities makes the gateway forward the verified caller identity as a
+// signed X-Internal-Identity token valid for ttl
+func (g *Gateway) SignIdentities(secret string, ttl time.Duration) {
+	g.identitySecret = secret
+	g.identityTTL = ttl
+}
+
+// SetRouteTimeouts overrides the service timeout of individual routes. Keys
+// are the method and route pattern, e.g. "POST /api/v1/users/:id/export".
+func (g *Gateway) SetRouteTimeouts(timeouts map[string]time.Duration) {
+	g.routeTimeouts = timeouts
+}
+
+// TrackRouteLatency makes the gateway track each route's p99 latency against
+// its timeout. The caller runs the returned tracker's Start.
+func (g *Gateway) TrackRouteLatency(settings SlowRouteSettings) *RouteLatencyTracker {
+	g.latency = NewRouteLatencyTracker(settings)
+	return g.latency
+}
+
+// RouteLatencyHandler reports each route's latency over the last check interval
+func (g *Gateway) RouteLatencyHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if g.latency == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Route latency tracking is disabled"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"routes": g.latency.Snapshot()})
+	}
+}
+
+// routeKey identifies the route a request matched
+func routeKey(c *gin.Context) string {
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	return c.Request.Method + " " + route
+}
+
+// routeTimeout returns the upstream timeout for a route. Timeouts set with
+// SetRouteLimits take precedence over those set with SetRouteTimeouts, which
+// take precedence over the route's limits.
+func (g *Gateway) routeTimeout(route string, limits RouteLimits) time.Duration {
+	if override := g.routeOverride(route); override.Timeout > 0 {
+		return override.Timeout
+	}
+	if timeout, ok := g.routeTimeouts[route]; ok && timeout > 0 {
+		return timeout
+	}
+	return limits.Timeout
 }
 
 // ProxyHandler creates a gin handler that proxies requests to the specified service
@@ -65,99 +333,213 @@ func (g *Gateway) ProxyHandler(serviceName string) gin.HandlerFunc {
 			return
 		}
 
+		if g.holdDrainingWrite(c) {
+			return
+		}
+
+		route := routeKey(c)
+		limits := g.limitsFor(service, route)
+		if rejectBody(c, service, route, limits) {
+			return
+		}
+		timeout := g.routeTimeout(route, limits)
+
 		ctx, span := g.tracer.Start(c.Request.Context(), "gateway.proxy",
 			trace.WithAttributes(
 				attribute.String("service.name", serviceName),
 				attribute.String("service.url", service.URL),
 				attribute.String("http.method", c.Request.Method),
 				attribute.String("http.path", c.Request.URL.Path),
+				attribute.String("http.route", route),
+				attribute.String("gateway.timeout", timeout.String()),
 			),
 		)
 		defer span.End()
 
+		// Requests fail over to the secondary region while the local
+		// instances are down. Once the breaker's reset timeout passes it
+		// turns half-open and requests probe the local instances again,
+		// which take a growing share of requests once it closes.
+		breaker, pool := service.CircuitBreaker, g.tenantPool(c, service)
+		if service.failover != nil && (service.localDown(pool) || service.shedToFailover()) {
+			breaker, pool = service.failover.breaker, service.failover.pool
+			span.SetAttributes(attribute.String("gateway.failover_region", service.failover.region))
+			metrics.GatewayFailoverRequestsTotal.WithLabelValues(serviceName, service.failover.region).Inc()
+		}
+
 		// Execute request with circuit breaker
-		err := service.CircuitBreaker.Execute(ctx, func() error {
-			return g.proxyRequest(c, service)
+		start := time.Now()
+		err := breaker.ExecuteWithTimeout(ctx, timeout, func() error {
+			return g.proxyRequest(c, service, pool, timeout)
 		})
+		timedOut := errors.Is(err, context.DeadlineExceeded)
+		if g.latency != nil {
+			g.latency.Observe(route, serviceName, timeout, time.Since(start), timedOut)
+		}
 
 		if err != nil {
 			span.RecordError(err)
 			log.Printf("Proxy error for service %s: %v", serviceName, err)
 			
-			if err.Error() == "circuit breaker is open" {
+			switch {
+			case errors.Is(err, errUpstreamFailed):
+				// The upstream's failure was already written to the client
+			case err.Error() == "circuit breaker is open":
 				c.JSON(http.StatusServiceUnavailable, gin.H{
 					"error": "Service temporarily unavailable",
 					"service": serviceName,
 				})
-			} else {
+			case timedOut:
+				c.JSON(http.StatusGatewayTimeout, gin.H{
+					"error": "Gateway timeout",
+					"service": serviceName,
+				})
+			default:
 				c.JSON(http.StatusBadGateway, gin.H{
 					"error": "Bad gateway",
 					"service": serviceName,
 				})
 			}
 		}
-	}
-}
 
-// proxyRequest proxies the request to the target service
-func (g *Gateway) proxyRequest(c *gin.Context, service *ServiceConfig) error {
-	// Parse target URL
-	targetURL, err := url.Parse(service.URL)
-	if err != nil {
-		return fmt.Errorf("invalid target URL: %v", err)
+		if g.budgets != nil {
+			g.budgets.Observe(serviceName, c.Writer.Status() >= http.StatusInternalServerError)
+		}
 	}
+}
 
-	// Create reverse proxy
-	proxy := httputil.NewSingleHostReverseProxy(targetURL)
-	
-	// Custom director to modify the request
-	originalDirector := proxy.Director
-	proxy.Director = func(req *http.Request) {
-		originalDirector(req)
-		
-		// Add tracing headers
-		if span := trace.SpanFromContext(req.Context()); span.SpanContext().IsValid() {
-			req.Header.Set("X-Trace-ID", span.SpanContext().TraceID().String())
-			req.Header.Set("X-Span-ID", span.SpanContext().SpanID().String())
-		}
-		
-		// Add gateway headers
-		req.Header.Set("X-Forwarded-By", "api-gateway")
-		req.Header.Set("X-Gateway-Service", service.Name)
-	}
-
-	// Custom error handler
-	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
-		log.Printf("Proxy error: %v", err)
-		w.WriteHeader(http.StatusBadGateway)
-		w.Write([]byte(`{"error": "Bad gateway"}`))
-	}
-
-	// Set timeout
-	ctx, cancel := context.WithTimeout(c.Request.Context(), service.Timeout)
+// proxyRequest proxies the request to an instance of the target service in
+// the given pool. Failed GET and HEAD requests are retried with backoff
+// within the timeout, each attempt picking an instance anew, so the circuit
+// breaker only sees the outcome of the last attempt.
+func (g *Gateway) proxyRequest(c *gin.Context, service *ServiceConfig, pool *instancePool, timeout time.Duration) error {
+	// Set timeout, shared by all attempts
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
 	defer cancel()
 	c.Request = c.Request.WithContext(ctx)
 
-	// Execute proxy
-	proxy.ServeHTTP(c.Writer, c.Request)
+	if !retryable(c, service) {
+		return g.proxyAttempt(c, service, pool, false)
+	}
+
+	service.retryBudget.deposit()
+	retry := service.RetryBackoff
+	retry.MaxRetries = service.Retries
+	retry.Retryable = func(err error) bool {
+		return errors.Is(err, errRetryableUpstream)
+	}
+	attempt := 0
+	return retry.Execute(ctx, func() error {
+		if attempt > 0 {
+			metrics.GatewayRetriesTotal.WithLabelValues(service.Name, "retried").Inc()
+		}
+		attempt++
+
+		// Only an attempt with a retry in reserve keeps its failure from
+		// the client
+		canRetry := attempt <= service.Retries && reserveRetry(service)
+		err := g.proxyAttempt(c, service, pool, canRetry)
+		if canRetry && !errors.Is(err, errRetryableUpstream) {
+			service.retryBudget.refund()
+		}
+		return err
+	})
+}
+
+// proxyAttempt sends the request to an instance picked from pool. An attempt
+// that can be retried returns errRetryableUpstream instead of writing its
+// failure to the client. Other failed attempts write their failure and
+// return errUpstreamFailed, so the circuit breaker counts every failure.
+func (g *Gateway) proxyAttempt(c *gin.Context, service *ServiceConfig, pool *instancePool, canRetry bool) error {
+	instance := pool.pick(service.HashKey(c))
+	if instance == nil {
+		return errors.New("no upstream instance available")
+	}
+
+	call := &proxyCall{
+		c:          c,
+		itemsField: c.GetString(listItemsKey),
+		requestID:  c.GetString("request_id"),
+		retry:      canRetry,
+	}
+
+	// Identical concurrent GETs share the call of the first one
+	shared := false
+	if key, ok := g.dedupKey(c, service); ok {
+		call.dedup = &sharedTransport{
+			group:     g.inflight,
+			key:       key,
+			service:   service.Name,
+			route:     routeKey(c),
+			transport: service.transport,
+			shared:    &shared,
+		}
+	}
+
+	// Execute proxy. Requests answered by another request's call leave the
+	// instance they picked out of outlier detection.
+	start := time.Now()
+	atomic.AddInt64(&instance.inflight, 1)
+	instance.proxy.ServeHTTP(c.Writer, c.Request.WithContext(withProxyCall(c.Request.Context(), call)))
+	atomic.AddInt64(&instance.inflight, -1)
+	if !shared {
+		pool.record(instance, time.Since(start), call.failed)
+	}
+	switch {
+	case call.withheld:
+		return fmt.Errorf("%w: %s: %v", errRetryableUpstream, instance.URL, call.err)
+	case call.err != nil:
+		return fmt.Errorf("%w: %s: %v", errUpstreamFailed, instance.URL, call.err)
+	}
 	return nil
 }
 
+// ErrUnknownService is returned for a service that is not registered
+var ErrUnknownService = errors.New("unknown service")
+
+// ServiceHealth is the outcome of a service's health check, with the state
+// of its circuit breaker and instances
+type ServiceHealth struct {
+	Service        string                     `json:"service"`
+	Healthy        bool                       `json:"healthy"`
+	Details        string                     `json:"details"`
+	CheckedAt      time.Time                  `json:"checked_at"`
+	Latency        time.Duration              `json:"latency"`
+	CircuitBreaker map[string]interface{}     `json:"circuit_breaker"`
+	Instances      []InstanceStats            `json:"instances"`
+	Pools          map[string][]InstanceStats `json:"pools,omitempty"`
+	Failover       *FailoverHealth            `json:"failover,omitempty"`
+}
+
+// FailoverHealth describes a service's secondary region deployment. Active
+// is set while the service's requests go there.
+type FailoverHealth struct {
+	Region         string                 `json:"region"`
+	Active         bool                   `json:"active"`
+	CircuitBreaker map[string]interface{} `json:"circuit_breaker"`
+	Instances      []InstanceStats        `json:"instances"`
+}
+
+// CheckService runs the health check of the service registered as name
+func (g *Gateway) CheckService(name string) (*ServiceHealth, error) {
+	service, ok := g.services[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownService, name)
+	}
+	return g.checkService(service), nil
+}
+
 // HealthCheckHandler checks the health of all registered services
 func (g *Gateway) HealthCheckHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		results := make(map[string]interface{})
+		results := make(map[string]*ServiceHealth)
 		overallHealthy := true
 
 		for name, service := range g.services {
-			healthy, details := g.checkServiceHealth(service)
-			results[name] = map[string]interface{}{
-				"healthy": healthy,
-				"details": details,
-				"circuit_breaker": service.CircuitBreaker.GetStats(),
-			}
+			health := g.checkService(service)
+			results[name] = health
 			
-			if !healthy {
+			if !health.Healthy {
 				overallHealthy = false
 			}
 		}
@@ -175,6 +557,37 @@ func (g *Gateway) HealthCheckHandler() gin.HandlerFunc {
 	}
 }
 
+// checkService checks a service's health and reports it with the state of
+// its circuit breaker and instances
+func (g *Gateway) checkService(service *ServiceConfig) *ServiceHealth {
+	checkedAt := time.Now().UTC()
+	healthy, details := g.checkServiceHealth(service)
+	health := &ServiceHealth{
+		Service:        service.Name,
+		Healthy:        healthy,
+		Details:        details,
+		CheckedAt:      checkedAt,
+		Latency:        time.Since(checkedAt),
+		CircuitBreaker: service.CircuitBreaker.GetStats(),
+		Instances:      service.pool.stats(),
+	}
+	if len(service.pools) > 0 {
+		health.Pools = make(map[string][]InstanceStats, len(service.pools))
+		for poolName, pool := range service.pools {
+			health.Pools[poolName] = pool.stats()
+		}
+	}
+	if service.failover != nil {
+		health.Failover = &FailoverHealth{
+			Region:         service.failover.region,
+			Active:         service.localDown(service.pool),
+			CircuitBreaker: service.failover.breaker.GetStats(),
+			Instances:      service.failover.pool.stats(),
+		}
+	}
+	return health
+}
+
 // checkServiceHealth checks if a service is healthy
 func (g *Gateway) checkServiceHealth(service *ServiceConfig) (bool, string) {
 	if service.HealthPath == "" {
@@ -191,7 +604,7 @@ func (g *Gateway) checkServiceHealth(service *ServiceConfig) (bool, string) {
 		return false, fmt.Sprintf("Failed to create health check request: %v", err)
 	}
 
-	client := &http.Client{Timeout: 5 * time.Second}
+	client := &http.Client{Timeout: 5 * time.Second, Transport: service.transport}
 	resp, err := client.Do(req)
 	if err != nil {
 		return false, fmt.Sprintf("Health check failed: %v", err)
@@ -206,25 +619,46 @@ func (g *Gateway) checkServiceHealth(service *ServiceConfig) (bool, string) {
 	return false, fmt.Sprintf("Health check failed with status %d: %s", resp.StatusCode, string(body))
 }
 
-// LoadBalancer interface for different load balancing strategies
+// LoadBalancer interface for different load balancing strategies. key
+// identifies the request for balancers that route by key.
 type LoadBalancer interface {
-	SelectService(services []*ServiceConfig) *ServiceConfig
+	Select(key string, instances []*Instance) *Instance
 }
 
 // RoundRobinBalancer implements round-robin load balancing
 type RoundRobinBalancer struct {
+	mu      sync.Mutex
 	current int
 }
 
-// SelectService selects the next service using round-robin
-func (rb *RoundRobinBalancer) SelectService(services []*ServiceConfig) *ServiceConfig {
-	if len(services) == 0 {
+// Select selects the next instance using round-robin, ignoring the key
+func (rb *RoundRobinBalancer) Select(key string, instances []*Instance) *Instance {
+	if len(instances) == 0 {
 		return nil
 	}
-	
-	service := services[rb.current%len(services)]
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	instance := instances[rb.current%len(instances)]
 	rb.current++
-	return service
+	return instance
+}
+
+// newBalancerLike returns a fresh balancer of the same kind for another
+// pool, since balancers keep per-pool state
+func newBalancerLike(balancer LoadBalancer) LoadBalancer {
+	switch b := balancer.(type) {
+	case *RoundRobinBalancer:
+		return &RoundRobinBalancer{}
+	case *WeightedBalancer:
+		return NewWeightedBalancer()
+	case *LeastConnectionsBalancer:
+		return &LeastConnectionsBalancer{}
+	case *ConsistentHashBalancer:
+		return NewConsistentHashBalancer(b.replicas)
+	default:
+		return balancer
+	}
 }
 
 // RequestLoggingHandler logs all requests
@@ -0,0 +1,187 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/middleware"
+)
+
+// Identity headers set by the gateway from the verified token. Backend
+// services can trust them because client-supplied copies are always removed.
+const (
+	HeaderUserID         = "X-User-ID"
+	HeaderUserEmail      = "X-User-Email"
+	HeaderUserRole       = "X-User-Role"
+	HeaderImpersonatorID = "X-Impersonator-ID"
+	HeaderRequestID      = "X-Request-ID"
+)
+
+// DefaultAllowedHeaders are the client headers forwarded to a service whose
+// HeaderPolicy does not list its own
+var DefaultAllowedHeaders = []string{
+	"Accept",
+	"Accept-Encoding",
+	"Accept-Language",
+	"Authorization",
+	"Cache-Control",
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Length",
+	"Content-Type",
+	"Idempotency-Key",
+	"If-Match",
+	"If-Modified-Since",
+	"If-None-Match",
+	"If-Unmodified-Since",
+	"User-Agent",
+	"Traceparent",
+	"Tracestate",
+	"Baggage",
+}
+
+// hopByHopHeaders apply to a single connection and are never forwarded
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// spoofableHeaderPrefixes are headers the gateway or its infrastructure set.
+// A client copy would let it forge its identity or origin, so they are
+// removed whatever the policy allows.
+var spoofableHeaderPrefixes = []string{
+	"X-Forwarded-",
+	"Forwarded",
+	"X-Real-Ip",
+	"X-User-",
+	"X-Impersonator-",
+	"X-Internal-",
+	"X-Gateway-",
+	"X-Trace-Id",
+	"X-Span-Id",
+	"X-Request-Id",
+}
+
+// HeaderPolicy controls which client headers reach a service. Allow lists
+// the headers forwarded (nil means DefaultAllowedHeaders, and a trailing "*"
+// matches a prefix); Deny removes headers even if they are allowed.
+type HeaderPolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+// AllowHeaders returns a policy forwarding DefaultAllowedHeaders and extra
+func AllowHeaders(extra ...string) HeaderPolicy {
+	allow := make([]string, 0, len(DefaultAllowedHeaders)+len(extra))
+	allow = append(allow, DefaultAllowedHeaders...)
+	return HeaderPolicy{Allow: append(allow, extra...)}
+}
+
+// sanitizeHeaders removes every header the policy does not forward from an
+// outgoing proxy request
+func (p HeaderPolicy) sanitizeHeaders(header http.Header) {
+	allow := p.Allow
+	if allow == nil {
+		allow = DefaultAllowedHeaders
+	}
+
+	// Headers named in Connection are hop-by-hop for this request
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+
+	for name := range header {
+		if matchesHeader(name, spoofableHeaderPrefixes, true) ||
+			matchesHeader(name, p.Deny, false) ||
+			!matchesHeader(name, allow, false) {
+			delete(header, name)
+		}
+	}
+}
+
+// setIdentityHeaders sets the forwarding and identity headers derived from
+// the request and the claims verified by the auth middleware
+func (g *Gateway) setIdentityHeaders(c *gin.Context, header http.Header) {
+	header.Set("X-Forwarded-Host", c.Request.Host)
+	// The client address as the gateway determined it, which services use
+	// e.g. to locate sign-ins; the reverse proxy appends its own peer after it
+	header.Set("X-Forwarded-For", c.ClientIP())
+	if c.Request.TLS != nil {
+		header.Set("X-Forwarded-Proto", "https")
+	} else {
+		header.Set("X-Forwarded-Proto", "http")
+	}
+
+	if requestID := c.GetString("request_id"); requestID != "" {
+		header.Set(HeaderRequestID, requestID)
+	}
+	if userID := c.GetString("user_id"); userID != "" {
+		header.Set(HeaderUserID, userID)
+	}
+	if email := c.GetString("email"); email != "" {
+		header.Set(HeaderUserEmail, email)
+	}
+	if role := c.GetString("role"); role != "" {
+		header.Set(HeaderUserRole, role)
+	}
+	if impersonatorID := c.GetString("impersonator_id"); impersonatorID != "" {
+		header.Set(HeaderImpersonatorID, impersonatorID)
+	}
+
+	userID := c.GetString("user_id")
+	if g.identitySecret == "" || userID == "" {
+		return
+	}
+
+	identity := &middleware.Identity{
+		UserID:         userID,
+		TenantID:       c.GetString("tenant_id"),
+		ImpersonatorID: c.GetString("impersonator_id"),
+		Locale:         c.GetString("locale"),
+		TimeZone:       c.GetString("time_zone"),
+	}
+	if roles := c.GetStringSlice("roles"); len(roles) > 0 {
+		identity.Roles = roles
+	} else if role := c.GetString("role"); role != "" {
+		identity.Roles = []string{role}
+	}
+
+	token, err := middleware.SignIdentity(identity, g.identitySecret, g.identityTTL)
+	if err != nil {
+		log.Printf("Failed to sign caller identity: %v", err)
+		return
+	}
+	header.Set(middleware.HeaderInternalIdentity, token)
+}
+
+// matchesHeader reports whether a header name matches any of the patterns,
+// case-insensitively. Patterns ending in "*" match a prefix; with prefixes
+// set every pattern is treated as a prefix.
+func matchesHeader(name string, patterns []string, prefixes bool) bool {
+	name = http.CanonicalHeaderKey(name)
+	for _, pattern := range patterns {
+		prefix := prefixes || strings.HasSuffix(pattern, "*")
+		pattern = http.CanonicalHeaderKey(strings.TrimSuffix(pattern, "*"))
+		if name == pattern || (prefix && strings.HasPrefix(name, pattern)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// InstanceHealthSettings controls the active health checks of upstream
+// instances. An instance is taken out of the balancer after
+// UnhealthyThreshold failed checks in a row and returned after
+// HealthyThreshold passed ones, ramping up over the outlier detection slow
+// start window.
+type InstanceHealthSettings struct {
+	Interval           time.Duration
+	Timeout            time.Duration
+	UnhealthyThreshold int
+	HealthyThreshold   int
+}
+
+// DefaultInstanceHealthSettings returns default instance health check settings
+func DefaultInstanceHealthSettings() InstanceHealthSettings {
+	return InstanceHealthSettings{
+		Interval:           10 * time.Second,
+		Timeout:            2 * time.Second,
+		UnhealthyThreshold: 2,
+		HealthyThreshold:   2,
+	}
+}
+
+// InstanceHealthChecker periodically checks every instance of services with
+// a health path
+type InstanceHealthChecker struct {
+	gateway  *Gateway
+	settings InstanceHealthSettings
+}
+
+// CheckInstances returns a checker probing the health path of every instance
+// of every registered service. The caller runs its Start.
+func (g *Gateway) CheckInstances(settings InstanceHealthSettings) *InstanceHealthChecker {
+	defaults := DefaultInstanceHealthSettings()
+	if settings.Interval <= 0 {
+		settings.Interval = defaults.Interval
+	}
+	if settings.Timeout <= 0 {
+		settings.Timeout = defaults.Timeout
+	}
+	if settings.UnhealthyThreshold <= 0 {
+		settings.UnhealthyThreshold = defaults.UnhealthyThreshold
+	}
+	if settings.HealthyThreshold <= 0 {
+		settings.HealthyThreshold = defaults.HealthyThreshold
+	}
+	return &InstanceHealthChecker{gateway: g, settings: settings}
+}
+
+// Start runs the health checks until ctx is cancelled
+func (h *InstanceHealthChecker) Start(ctx context.Context) {
+	ticker := time.NewTicker(h.settings.Interval)
+	defer ticker.Stop()
+
+	for {
+		h.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check probes every instance concurrently and records the outcomes
+func (h *InstanceHealthChecker) check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, service := range h.gateway.services {
+		if service.HealthPath == "" {
+			continue
+		}
+		for _, pool := range service.allPools() {
+			pool.mu.Lock()
+			instances := append([]*Instance(nil), pool.instances...)
+			pool.mu.Unlock()
+
+			for _, instance := range instances {
+				wg.Add(1)
+				go func(service *ServiceConfig, pool *instancePool, instance *Instance) {
+					defer wg.Done()
+					err := h.probe(ctx, service, instance)
+					pool.recordHealth(instance, err, h.settings)
+				}(service, pool, instance)
+			}
+		}
+	}
+	wg.Wait()
+}
+
+// probe runs one health check of an instance
+func (h *InstanceHealthChecker) probe(ctx context.Context, service *ServiceConfig, instance *Instance) error {
+	ctx, cancel := context.WithTimeout(ctx, h.settings.Timeout)
+	defer cancel()
+
+	healthURL := strings.TrimSuffix(instance.URL, "/") + "/" + strings.TrimPrefix(service.HealthPath, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := service.transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
+
+// recordHealth records the outcome of an instance's health check, taking it
+// out of or returning it to the balancer once a threshold is reached
+func (p *instancePool) recordHealth(instance *Instance, err error, settings InstanceHealthSettings) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	failing := err != nil
+	if failing != instance.healthFailing {
+		instance.healthFailing = failing
+		instance.healthChecks = 0
+	}
+	instance.healthChecks++
+
+	switch {
+	case failing && !instance.down && instance.healthChecks >= settings.UnhealthyThreshold:
+		instance.down = true
+		log.Printf("WARNING: instance %s of %s failed %d health checks, removed from the balancer: %v",
+			instance.URL, p.service, instance.healthChecks, err)
+	case !failing && instance.down && instance.healthChecks >= settings.HealthyThreshold:
+		instance.down = false
+		instance.warmingSince = time.Now()
+		log.Printf("Instance %s of %s passed %d health checks, returned to the balancer",
+			instance.URL, p.service, instance.healthChecks)
+	}
+
+	healthy := 1.0
+	if instance.down {
+		healthy = 0
+	}
+	metrics.GatewayUpstreamHealthy.WithLabelValues(p.service, instance.URL).Set(healthy)
+}
@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Instance is one upstream instance of a service
+type Instance struct {
+	URL    string
+	Weight int
+	target *url.URL
+	// proxy forwards requests to the instance over the service's transport
+	proxy *httputil.ReverseProxy
+
+	// Outcomes in the current outlier detection interval
+	requests          int64
+	failures          int64
+	latency           time.Duration
+	consecutiveErrors int
+
+	// Ejection state
+	ejectedUntil time.Time
+	ejections    int
+	// warmingSince is when a returned instance started its slow start
+	warmingSince time.Time
+
+	// inflight counts the requests sent to the instance and not yet answered
+	inflight int64
+
+	// Active health check state. A down instance is out of the balancer
+	// until it passes its health checks again.
+	down          bool
+	healthChecks  int
+	healthFailing bool
+}
+
+// instancePool holds a service's instances and the outcome of requests sent
+// to them
+type instancePool struct {
+	service  string
+	balancer LoadBalancer
+
+	mu        sync.Mutex
+	instances []*Instance
+	outliers  *OutlierDetectionSettings
+}
+
+// newInstancePool parses a service's instance URLs
+func newInstancePool(service string, urls []string, balancer LoadBalancer) (*instancePool, error) {
+	pool := &instancePool{
+		service:  service,
+		balancer: balancer,
+	}
+	for _, raw := range urls {
+		instance, err := newInstance(raw)
+		if err != nil {
+			return nil, err
+		}
+		pool.instances = append(pool.instances, instance)
+	}
+	return pool, nil
+}
+
+// newInstance parses an instance URL with an optional weight
+func newInstance(raw string) (*Instance, error) {
+	address, weight, err := parseInstance(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid instance %q: %v", raw, err)
+	}
+	target, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid instance URL %q: %v", address, err)
+	}
+	return &Instance{URL: address, Weight: weight, target: target}, nil
+}
+
+// pick selects the instance for a request with the given balancer key,
+// skipping ejected instances and instances failing their health checks. If
+// every instance is excluded, all of them are candidates again.
+//
+// Instances in slow start are only candidates for a share of requests that
+// grows over the slow-start window, so they are not hit with full load while
+// their caches are cold.
+func (p *instancePool) pick(key string) *Instance {
+	p.mu.Lock()
+	now := time.Now()
+	candidates := make([]*Instance, 0, len(p.instances))
+	var warming []*Instance
+	for _, instance := range p.instances {
+		if !instance.ejectedUntil.IsZero() && !now.Before(instance.ejectedUntil) {
+			p.uneject(instance)
+		}
+		if !instance.ejectedUntil.IsZero() || instance.down {
+			continue
+		}
+		if share := p.slowStartShare(instance, now); share < 1 && rand.Float64() >= share {
+			warming = append(warming, instance)
+			continue
+		}
+		candidates = append(candidates, instance)
+	}
+	if len(candidates) == 0 {
+		candidates = warming
+	}
+	if len(candidates) == 0 {
+		candidates = p.instances
+	}
+	p.mu.Unlock()
+
+	return p.balancer.Select(key, candidates)
+}
+
+// slowStartShare returns the share of its normal traffic an instance gets,
+// ending its slow start once the window has passed. Callers hold p.mu.
+func (p *instancePool) slowStartShare(instance *Instance, now time.Time) float64 {
+	if instance.warmingSince.IsZero() {
+		return 1
+	}
+	if p.outliers == nil || p.outliers.SlowStartWindow <= 0 {
+		instance.warmingSince = time.Time{}
+		return 1
+	}
+
+	elapsed := now.Sub(instance.warmingSince)
+	if elapsed >= p.outliers.SlowStartWindow {
+		instance.warmingSince = time.Time{}
+		return 1
+	}
+	share := float64(elapsed) / float64(p.outliers.SlowStartWindow)
+	if share < minSlowStartShare {
+		share = minSlowStartShare
+	}
+	return share
+}
+
+// record records the outcome of a request sent to an instance
+func (p *instancePool) record(instance *Instance, latency time.Duration, failed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	instance.requests++
+	instance.latency += latency
+	if !failed {
+		instance.consecutiveErrors = 0
+		return
+	}
+
+	instance.failures++
+	instance.consecutiveErrors++
+	if p.outliers != nil && p.outliers.ConsecutiveErrors > 0 &&
+		instance.consecutiveErrors >= p.outliers.ConsecutiveErrors {
+		p.eject(instance, "consecutive_errors")
+	}
+}
+
+// allExcluded reports whether every instance is currently ejected or
+// failing its health checks
+func (p *instancePool) allExcluded() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for _, instance := range p.instances {
+		if !instance.down && (instance.ejectedUntil.IsZero() || !now.Before(instance.ejectedUntil)) {
+			return false
+		}
+	}
+	return len(p.instances) > 0
+}
+
+// InstanceStats describes an instance's state for health reporting
+type InstanceStats struct {
+	URL          string    `json:"url"`
+	Weight       int       `json:"weight"`
+	InFlight     int64     `json:"in_flight"`
+	Healthy      bool      `json:"healthy"`
+	Ejected      bool      `json:"ejected"`
+	EjectedUntil time.Time `json:"ejected_until,omitempty"`
+	Ejections    int       `json:"ejections"`
+	SlowStart    bool      `json:"slow_start"`
+}
+
+// stats returns the state of every instance
+func (p *instancePool) stats() []InstanceStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]InstanceStats, 0, len(p.instances))
+	for _, instance := range p.instances {
+		stats = append(stats, InstanceStats{
+			URL:          instance.URL,
+			Weight:       instance.Weight,
+			InFlight:     atomic.LoadInt64(&instance.inflight),
+			Healthy:      !instance.down,
+			Ejected:      !instance.ejectedUntil.IsZero(),
+			EjectedUntil: instance.ejectedUntil,
+			Ejections:    instance.ejections,
+			SlowStart:    !instance.warmingSince.IsZero(),
+		})
+	}
+	return stats
+}
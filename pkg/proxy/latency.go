@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// routeLatencySamples caps the latencies kept per route and check interval.
+// Busier routes are reservoir-sampled down to this many.
+const routeLatencySamples = 2048
+
+// minRouteLatencySamples is the fewest requests in an interval for its p99 to
+// count towards slow route detection
+const minRouteLatencySamples = 20
+
+// SlowRouteSettings controls slow route detection. A route is slow when its
+// p99 is at least Threshold of its timeout for Checks consecutive intervals.
+type SlowRouteSettings struct {
+	Threshold     float64
+	CheckInterval time.Duration
+	Checks        int
+}
+
+// DefaultSlowRouteSettings returns default slow route detection settings
+func DefaultSlowRouteSettings() SlowRouteSettings {
+	return SlowRouteSettings{
+		Threshold:     0.8,
+		CheckInterval: time.Minute,
+		Checks:        5,
+	}
+}
+
+// RouteLatency summarizes a route's latency over the last check interval
+type RouteLatency struct {
+	Route    string        `json:"route"`
+	Service  string        `json:"service"`
+	Timeout  time.Duration `json:"timeout"`
+	P99      time.Duration `json:"p99"`
+	Requests int64         `json:"requests"`
+	Timeouts int64         `json:"timeouts"`
+	Slow     bool          `json:"slow"`
+}
+
+// routeLatency collects one route's latencies for the current interval
+type routeLatency struct {
+	service string
+	timeout time.Duration
+
+	samples  []time.Duration
+	requests int64
+	timeouts int64
+
+	slowChecks int
+	last       RouteLatency
+}
+
+// RouteLatencyTracker tracks the p99 latency of each gateway route and flags
+// routes that persistently run close to their timeout
+type RouteLatencyTracker struct {
+	settings SlowRouteSettings
+
+	mu     sync.Mutex
+	routes map[string]*routeLatency
+}
+
+// NewRouteLatencyTracker creates a new route latency tracker
+func NewRouteLatencyTracker(settings SlowRouteSettings) *RouteLatencyTracker {
+	defaults := DefaultSlowRouteSettings()
+	if settings.Threshold <= 0 {
+		settings.Threshold = defaults.Threshold
+	}
+	if settings.CheckInterval <= 0 {
+		settings.CheckInterval = defaults.CheckInterval
+	}
+	if settings.Checks <= 0 {
+		settings.Checks = defaults.Checks
+	}
+
+	return &RouteLatencyTracker{
+		settings: settings,
+		routes:   make(map[string]*routeLatency),
+	}
+}
+
+// Observe records the latency of a proxied request
+func (t *RouteLatencyTracker) Observe(route, service string, timeout, latency time.Duration, timedOut bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.routes[route]
+	if !ok {
+		r = &routeLatency{}
+		t.routes[route] = r
+	}
+	r.service = service
+	r.timeout = timeout
+
+	r.requests++
+	if len(r.samples) < routeLatencySamples {
+		r.samples = append(r.samples, latency)
+	} else if i := rand.Int63n(r.requests); i < routeLatencySamples {
+		r.samples[i] = latency
+	}
+
+	if timedOut {
+		r.timeouts++
+		metrics.GatewayRouteTimeoutsTotal.WithLabelValues(service, route).Inc()
+	}
+}
+
+// Start checks route latencies every check interval until ctx is cancelled
+func (t *RouteLatencyTracker) Start(ctx context.Context) {
+	ticker := time.NewTicker(t.settings.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.check()
+		}
+	}
+}
+
+// check closes the current interval: it computes each route's p99, updates
+// the slow route state and starts a new interval
+func (t *RouteLatencyTracker) check() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for route, r := range t.routes {
+		p99 := percentile(r.samples, 0.99)
+
+		if len(r.samples) >= minRouteLatencySamples {
+			if float64(p99) >= t.settings.Threshold*float64(r.timeout) {
+				r.slowChecks++
+			} else {
+				r.slowChecks = 0
+			}
+		}
+		slow := r.slowChecks >= t.settings.Checks
+
+		if slow && !r.last.Slow {
+			log.Printf("WARNING: route %q (%s) p99 %s has been within %.0f%% of its %s timeout for %d checks",
+				route, r.service, p99, t.settings.Threshold*100, r.timeout, r.slowChecks)
+		} else if !slow && r.last.Slow {
+			log.Printf("Route %q (%s) p99 %s is no longer near its %s timeout", route, r.service, p99, r.timeout)
+		}
+
+		r.last = RouteLatency{
+			Route:    route,
+			Service:  r.service,
+			Timeout:  r.timeout,
+			P99:      p99,
+			Requests: r.requests,
+			Timeouts: r.timeouts,
+			Slow:     slow,
+		}
+
+		metrics.GatewayRouteLatencyP99.WithLabelValues(r.service, route).Set(p99.Seconds())
+		metrics.GatewayRouteTimeoutSeconds.WithLabelValues(r.service, route).Set(r.timeout.Seconds())
+		slowValue := 0.0
+		if slow {
+			slowValue = 1
+		}
+		metrics.GatewayRouteSlow.WithLabelValues(r.service, route).Set(slowValue)
+
+		r.samples = r.samples[:0]
+		r.requests = 0
+		r.timeouts = 0
+	}
+}
+
+// Snapshot returns the latency of every route over the last check interval,
+// slowest first
+func (t *RouteLatencyTracker) Snapshot() []RouteLatency {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snapshot := make([]RouteLatency, 0, len(t.routes))
+	for _, r := range t.routes {
+		if r.last.Route != "" {
+			snapshot = append(snapshot, r.last)
+		}
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].P99 > snapshot[j].P99
+	})
+	return snapshot
+}
+
+// percentile returns the q-th quantile of samples, sorting them in place
+func percentile(samples []time.Duration, q float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	index := int(q*float64(len(samples))+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(samples) {
+		index = len(samples) - 1
+	}
+	return samples[index]
+}
@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"net/http"
+	"net/textproto"
+
+	"microservices-platform/pkg/operations"
+)
+
+// HeaderOperationID names the operation a 202 Accepted response started
+const HeaderOperationID = "X-Operation-ID"
+
+// operationMetadataHeader is how the services' HTTP transcoding forwards the
+// operation ID set as gRPC response metadata
+var operationMetadataHeader = textproto.CanonicalMIMEHeaderKey("Grpc-Metadata-" + operations.MetadataOperationID)
+
+// acceptOperation turns a successful response that started a long-running
+// operation into 202 Accepted pointing at the operation's status
+func acceptOperation(resp *http.Response) {
+	id := resp.Header.Get(operationMetadataHeader)
+	if id == "" {
+		return
+	}
+	resp.Header.Del(operationMetadataHeader)
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	resp.StatusCode = http.StatusAccepted
+	resp.Status = "202 Accepted"
+	resp.Header.Set(HeaderOperationID, id)
+	resp.Header.Set("Location", "/api/v1/operations/"+id)
+}
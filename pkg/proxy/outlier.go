@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// OutlierDetectionSettings controls when upstream instances are ejected from
+// the balancer. An instance is ejected after ConsecutiveErrors failed requests
+// in a row, or at the end of an interval in which at least MinRequests
+// requests had a failure rate of FailurePercentage or more, or a mean latency
+// of LatencyFactor times the median of its service's instances.
+//
+// Ejections last BaseEjectionTime multiplied by the number of times the
+// instance was ejected, up to MaxEjectionTime. At most MaxEjectionPercent of
+// a service's instances are ejected at once, but always at least one.
+// Returning instances ramp up to their full share of traffic over
+// SlowStartWindow.
+type OutlierDetectionSettings struct {
+	Interval           time.Duration
+	BaseEjectionTime   time.Duration
+	MaxEjectionTime    time.Duration
+	MaxEjectionPercent int
+	ConsecutiveErrors  int
+	FailurePercentage  int
+	LatencyFactor      float64
+	MinRequests        int64
+	SlowStartWindow    time.Duration
+}
+
+// DefaultOutlierDetectionSettings returns default outlier detection settings
+func DefaultOutlierDetectionSettings() OutlierDetectionSettings {
+	return OutlierDetectionSettings{
+		Interval:           10 * time.Second,
+		BaseEjectionTime:   30 * time.Second,
+		MaxEjectionTime:    5 * time.Minute,
+		MaxEjectionPercent: 10,
+		ConsecutiveErrors:  5,
+		FailurePercentage:  50,
+		LatencyFactor:      3,
+		MinRequests:        20,
+		SlowStartWindow:    30 * time.Second,
+	}
+}
+
+// minSlowStartShare is the share of traffic an instance gets right after
+// returning from an ejection
+const minSlowStartShare = 0.1
+
+// minLatencyOutlierInstances is the fewest instances with enough requests for
+// their latencies to be compared
+const minLatencyOutlierInstances = 3
+
+// OutlierDetector periodically ejects outlier instances of every service
+type OutlierDetector struct {
+	settings *OutlierDetectionSettings
+	pools    []*instancePool
+}
+
+// Start runs outlier detection every interval until ctx is cancelled
+func (d *OutlierDetector) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.settings.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, pool := range d.pools {
+				pool.detectOutliers()
+			}
+		}
+	}
+}
+
+// detectOutliers closes the current interval: it ejects instances whose
+// failure rate or latency stood out and starts a new interval
+func (p *instancePool) detectOutliers() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.outliers == nil {
+		return
+	}
+	settings := p.outliers
+
+	var meanLatencies []time.Duration
+	for _, instance := range p.instances {
+		if instance.ejectedUntil.IsZero() && instance.requests >= settings.MinRequests {
+			meanLatencies = append(meanLatencies, instance.meanLatency())
+		}
+	}
+	var medianLatency time.Duration
+	if len(meanLatencies) >= minLatencyOutlierInstances {
+		sort.Slice(meanLatencies, func(i, j int) bool { return meanLatencies[i] < meanLatencies[j] })
+		medianLatency = meanLatencies[len(meanLatencies)/2]
+	}
+
+	for _, instance := range p.instances {
+		if !instance.ejectedUntil.IsZero() || instance.requests < settings.MinRequests {
+			instance.resetInterval()
+			continue
+		}
+
+		switch {
+		case settings.FailurePercentage > 0 && instance.failures*100 >= int64(settings.FailurePercentage)*instance.requests:
+			p.eject(instance, "failure_rate")
+		case medianLatency > 0 && settings.LatencyFactor > 0 &&
+			float64(instance.meanLatency()) >= settings.LatencyFactor*float64(medianLatency):
+			p.eject(instance, "latency")
+		case instance.ejections > 0:
+			// A healthy interval shortens the instance's next ejection
+			instance.ejections--
+		}
+		instance.resetInterval()
+	}
+}
+
+// eject removes an instance from the balancer unless that would exceed the
+// ejection cap. Callers hold p.mu.
+func (p *instancePool) eject(instance *Instance, reason string) {
+	if len(p.instances) < 2 || !instance.ejectedUntil.IsZero() {
+		return
+	}
+
+	ejected := 0
+	for _, other := range p.instances {
+		if !other.ejectedUntil.IsZero() {
+			ejected++
+		}
+	}
+	maxEjected := len(p.instances) * p.outliers.MaxEjectionPercent / 100
+	if maxEjected < 1 {
+		maxEjected = 1
+	}
+	if ejected >= maxEjected {
+		log.Printf("Outlier instance %s of %s not ejected (%s): %d of %d instances already ejected",
+			instance.URL, p.service, reason, ejected, len(p.instances))
+		return
+	}
+
+	instance.ejections++
+	duration := p.outliers.BaseEjectionTime * time.Duration(instance.ejections)
+	if p.outliers.MaxEjectionTime > 0 && duration > p.outliers.MaxEjectionTime {
+		duration = p.outliers.MaxEjectionTime
+	}
+	instance.ejectedUntil = time.Now().Add(duration)
+	instance.consecutiveErrors = 0
+
+	log.Printf("WARNING: ejected outlier instance %s of %s for %s (%s)", instance.URL, p.service, duration, reason)
+	metrics.GatewayUpstreamEjectionsTotal.WithLabelValues(p.service, instance.URL, reason).Inc()
+	metrics.GatewayUpstreamEjected.WithLabelValues(p.service, instance.URL).Set(1)
+}
+
+// uneject returns an ejected instance to the balancer. Callers hold p.mu.
+func (p *instancePool) uneject(instance *Instance) {
+	instance.ejectedUntil = time.Time{}
+	instance.warmingSince = time.Now()
+	instance.resetInterval()
+
+	log.Printf("Instance %s of %s returned to the balancer, slow start over %s",
+		instance.URL, p.service, p.outliers.SlowStartWindow)
+	metrics.GatewayUpstreamEjected.WithLabelValues(p.service, instance.URL).Set(0)
+}
+
+// meanLatency returns the instance's mean latency in the current interval
+func (i *Instance) meanLatency() time.Duration {
+	if i.requests == 0 {
+		return 0
+	}
+	return i.latency / time.Duration(i.requests)
+}
+
+// resetInterval clears the instance's outcomes for a new interval
+func (i *Instance) resetInterval() {
+	i.requests = 0
+	i.failures = 0
+	i.latency = 0
+}
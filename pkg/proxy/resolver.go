@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"time"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// Resolver looks up the instance URLs of a service
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// DNSResolver resolves a host name to one instance per address, such as the
+// pods behind a Kubernetes headless service
+type DNSResolver struct {
+	scheme string
+	host   string
+	port   string
+}
+
+// NewDNSResolver creates a resolver for the host of rawURL, e.g.
+// "http://user-service-headless:8080"
+func NewDNSResolver(rawURL string) (*DNSResolver, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %v", rawURL, err)
+	}
+	if target.Hostname() == "" {
+		return nil, fmt.Errorf("URL %q has no host", rawURL)
+	}
+	return &DNSResolver{scheme: target.Scheme, host: target.Hostname(), port: target.Port()}, nil
+}
+
+// Resolve returns an instance URL for every address of the host, sorted so
+// every gateway replica lists them in the same order
+func (r *DNSResolver) Resolve(ctx context.Context) ([]string, error) {
+	addresses, err := net.DefaultResolver.LookupHost(ctx, r.host)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(addresses)
+
+	urls := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		host := address
+		if r.port != "" {
+			host = net.JoinHostPort(address, r.port)
+		} else if net.ParseIP(address).To4() == nil {
+			host = "[" + address + "]"
+		}
+		urls = append(urls, r.scheme+"://"+host)
+	}
+	return urls, nil
+}
+
+// InstanceResolver periodically resolves the instances of services with a
+// resolver and updates their shared pools
+type InstanceResolver struct {
+	gateway  *Gateway
+	interval time.Duration
+}
+
+// ResolveInstances returns a resolver refreshing the instances of services
+// with a Resolver every interval. The caller runs its Start.
+func (g *Gateway) ResolveInstances(interval time.Duration) *InstanceResolver {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &InstanceResolver{gateway: g, interval: interval}
+}
+
+// Start refreshes the instances until ctx is cancelled
+func (r *InstanceResolver) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, service := range r.gateway.services {
+				if service.Resolver != nil {
+					r.gateway.refreshInstances(ctx, service)
+				}
+			}
+		}
+	}
+}
+
+// refreshInstances resolves a service's instances and replaces those of its
+// shared pool. A failed or empty lookup keeps the current instances.
+func (g *Gateway) refreshInstances(ctx context.Context, service *ServiceConfig) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	urls, err := service.Resolver.Resolve(ctx)
+	if err != nil || len(urls) == 0 {
+		log.Printf("WARNING: failed to resolve instances of %s, keeping the current ones: %v", service.Name, err)
+		return
+	}
+	g.replaceInstances(service, urls)
+}
+
+// SetInstances replaces the instances of a registered service's shared pool,
+// e.g. with those found through service discovery. Instances that are still
+// listed keep their state.
+func (g *Gateway) SetInstances(name string, urls []string) error {
+	service, ok := g.services[name]
+	if !ok {
+		return fmt.Errorf("service %s not found", name)
+	}
+	g.replaceInstances(service, urls)
+	return nil
+}
+
+// replaceInstances replaces the instances of a service's shared pool with
+// urls. Instances that are still listed keep their state. A list without
+// valid instances keeps the current ones.
+func (g *Gateway) replaceInstances(service *ServiceConfig, urls []string) {
+	pool := service.pool
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	current := make(map[string]*Instance, len(pool.instances))
+	for _, instance := range pool.instances {
+		current[instance.URL] = instance
+	}
+
+	instances := make([]*Instance, 0, len(urls))
+	for _, raw := range urls {
+		instance, err := newInstance(raw)
+		if err != nil {
+			log.Printf("Skipping resolved instance of %s: %v", service.Name, err)
+			continue
+		}
+		if existing, ok := current[instance.URL]; ok {
+			instances = append(instances, existing)
+			delete(current, instance.URL)
+			continue
+		}
+		instance.proxy = g.newReverseProxy(service, instance)
+		instances = append(instances, instance)
+		log.Printf("Added instance %s of %s", instance.URL, service.Name)
+	}
+	if len(instances) == 0 {
+		return
+	}
+
+	for removed := range current {
+		log.Printf("Removed instance %s of %s", removed, service.Name)
+		metrics.GatewayUpstreamEjected.DeleteLabelValues(service.Name, removed)
+		metrics.GatewayUpstreamHealthy.DeleteLabelValues(service.Name, removed)
+	}
+	pool.instances = instances
+	metrics.GatewayUpstreamInstances.WithLabelValues(service.Name).Set(float64(len(instances)))
+}
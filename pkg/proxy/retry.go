@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/metrics"
+	"microservices-platform/pkg/resilience"
+)
+
+// Retry budget defaults. A service's retries are capped at DefaultRetryBudget
+// of its requests, with a reserve of retryBudgetReserve retries so services
+// with little traffic can retry too.
+const (
+	DefaultRetryBudget = 0.2
+	retryBudgetReserve = 10
+)
+
+// errRetryableUpstream is returned for an attempt that failed in a way worth
+// retrying, whose response was not written to the client
+var errRetryableUpstream = errors.New("upstream attempt failed")
+
+// errUpstreamFailed is returned for an attempt that failed, with a server
+// error or no response, whose failure was written to the client
+var errUpstreamFailed = errors.New("upstream failed")
+
+// DefaultRetryBackoff returns the backoff between retries of a proxied
+// request. Delays stay short, since the client is waiting.
+func DefaultRetryBackoff() resilience.Retry {
+	return resilience.Retry{
+		BaseDelay:  50 * time.Millisecond,
+		MaxDelay:   time.Second,
+		Multiplier: 2.0,
+		Jitter:     true,
+	}
+}
+
+// retryBudget caps a service's retries at a share of its requests, so
+// retries cannot multiply the load on a service that is already failing.
+// Each request adds ratio tokens, up to the reserve, and each retry takes
+// one.
+type retryBudget struct {
+	mu     sync.Mutex
+	ratio  float64
+	tokens float64
+}
+
+// newRetryBudget creates a full retry budget
+func newRetryBudget(ratio float64) *retryBudget {
+	return &retryBudget{ratio: ratio, tokens: retryBudgetReserve}
+}
+
+// deposit adds a request's share of a retry
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += b.ratio
+	if b.tokens > retryBudgetReserve {
+		b.tokens = retryBudgetReserve
+	}
+}
+
+// withdraw takes a retry and reports whether the budget had one
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refund returns a retry that was not needed
+func (b *retryBudget) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+	if b.tokens > retryBudgetReserve {
+		b.tokens = retryBudgetReserve
+	}
+}
+
+// retryable reports whether a request may be retried: it is a GET or HEAD,
+// without a body, to a service that retries
+func retryable(c *gin.Context, service *ServiceConfig) bool {
+	if service.Retries <= 0 {
+		return false
+	}
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+	return c.Request.ContentLength == 0
+}
+
+// retryableStatus reports whether an upstream status is worth retrying on
+// another attempt
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// reserveRetry takes a retry from the service's budget for an attempt that
+// may fail, so that the attempt's failure is only hidden from the client
+// when it can be retried
+func reserveRetry(service *ServiceConfig) bool {
+	if service.retryBudget.withdraw() {
+		return true
+	}
+	metrics.GatewayRetriesTotal.WithLabelValues(service.Name, "budget_exhausted").Inc()
+	return false
+}
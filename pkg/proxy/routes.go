@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// DefaultMaxBodySize bounds request bodies of services without a limit
+const DefaultMaxBodySize = 1 << 20
+
+// RouteLimits overrides a service's limits for one route. Zero fields keep
+// the service's settings.
+type RouteLimits struct {
+	Timeout time.Duration
+	// MaxBodySize bounds the request body in bytes
+	MaxBodySize int64
+	// ContentTypes lists the media types a request body may have, e.g.
+	// "application/json". Empty allows any.
+	ContentTypes []string
+}
+
+// routeLimits returns the limits of a route of service
+func (s *ServiceConfig) routeLimits(route string) RouteLimits {
+	limits := s.Routes[route]
+	if limits.Timeout <= 0 {
+		limits.Timeout = s.Timeout
+	}
+	if limits.MaxBodySize <= 0 {
+		limits.MaxBodySize = s.MaxBodySize
+	}
+	return limits
+}
+
+// SetRouteLimits overrides the limits of routes while the gateway runs,
+// replacing the overrides set before. Keys are "METHOD /route/:param", and
+// zero fields keep the route's own limits. Timeouts longer than the server's
+// write timeout are cut short by it.
+func (g *Gateway) SetRouteLimits(limits map[string]RouteLimits) {
+	g.overridesMu.Lock()
+	defer g.overridesMu.Unlock()
+	g.routeOverrides = limits
+}
+
+// routeOverride returns the override set with SetRouteLimits for a route
+func (g *Gateway) routeOverride(route string) RouteLimits {
+	g.overridesMu.RLock()
+	defer g.overridesMu.RUnlock()
+	return g.routeOverrides[route]
+}
+
+// limitsFor returns the limits of a route of service, overridden by those
+// set with SetRouteLimits
+func (g *Gateway) limitsFor(service *ServiceConfig, route string) RouteLimits {
+	limits := service.routeLimits(route)
+	override := g.routeOverride(route)
+	if override.Timeout > 0 {
+		limits.Timeout = override.Timeout
+	}
+	if override.MaxBodySize > 0 {
+		limits.MaxBodySize = override.MaxBodySize
+	}
+	if len(override.ContentTypes) > 0 {
+		limits.ContentTypes = override.ContentTypes
+	}
+	return limits
+}
+
+// rejectBody answers requests whose body breaks the route's limits before
+// they reach the service: 415 for a content type the route does not accept,
+// and 413 for a body declared larger than the limit. Bodies of unknown size
+// are cut off at the limit while they are proxied.
+func rejectBody(c *gin.Context, service *ServiceConfig, route string, limits RouteLimits) bool {
+	if c.Request.ContentLength == 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return false
+	}
+
+	if len(limits.ContentTypes) > 0 && !acceptsContentType(limits.ContentTypes, c.GetHeader("Content-Type")) {
+		metrics.GatewayRejectedRequestsTotal.WithLabelValues(service.Name, route, "unsupported_content_type").Inc()
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":   "Unsupported content type",
+			"allowed": limits.ContentTypes,
+		})
+		return true
+	}
+
+	if c.Request.ContentLength > limits.MaxBodySize {
+		metrics.GatewayRejectedRequestsTotal.WithLabelValues(service.Name, route, "body_too_large").Inc()
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":     "Request body too large",
+			"max_bytes": limits.MaxBodySize,
+		})
+		return true
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limits.MaxBodySize)
+	return false
+}
+
+// acceptsContentType reports whether the media type of contentType is one
+// of allowed
+func acceptsContentType(allowed []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, accepted := range allowed {
+		if strings.EqualFold(mediaType, accepted) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxTimeout returns the longest upstream timeout of any registered route,
+// which the server's write timeout has to cover
+func (g *Gateway) MaxTimeout() time.Duration {
+	var longest time.Duration
+	for _, service := range g.services {
+		if service.Timeout > longest {
+			longest = service.Timeout
+		}
+		for _, limits := range service.Routes {
+			if limits.Timeout > longest {
+				longest = limits.Timeout
+			}
+		}
+	}
+	for _, timeout := range g.routeTimeouts {
+		if timeout > longest {
+			longest = timeout
+		}
+	}
+	return longest
+}
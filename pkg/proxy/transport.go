@@ -0,0 +1,222 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TransportSettings tunes the connections the gateway keeps to a service's
+// instances. Zero values take the defaults of DefaultTransportSettings.
+type TransportSettings struct {
+	// MaxIdleConns caps idle connections across all instances, and
+	// MaxIdleConnsPerHost those kept to each instance
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost caps connections to each instance, 0 for no limit
+	MaxConnsPerHost int
+	IdleConnTimeout time.Duration
+	DialTimeout     time.Duration
+	// KeepAlive is the TCP keep-alive period of connections
+	KeepAlive time.Duration
+	// DisableKeepAlives makes every request use a new connection
+	DisableKeepAlives   bool
+	TLSHandshakeTimeout time.Duration
+	// TLSConfig is used for https instances. Defaults to the system roots.
+	TLSConfig *tls.Config
+}
+
+// DefaultTransportSettings returns transport settings that keep enough idle
+// connections per instance for a busy gateway to reuse them
+func DefaultTransportSettings() TransportSettings {
+	return TransportSettings{
+		MaxIdleConns:        1000,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+		DialTimeout:         5 * time.Second,
+		KeepAlive:           30 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// newTransport creates the transport shared by a service's proxies
+func newTransport(settings TransportSettings) *http.Transport {
+	defaults := DefaultTransportSettings()
+	if settings.MaxIdleConns <= 0 {
+		settings.MaxIdleConns = defaults.MaxIdleConns
+	}
+	if settings.MaxIdleConnsPerHost <= 0 {
+		settings.MaxIdleConnsPerHost = defaults.MaxIdleConnsPerHost
+	}
+	if settings.IdleConnTimeout <= 0 {
+		settings.IdleConnTimeout = defaults.IdleConnTimeout
+	}
+	if settings.DialTimeout <= 0 {
+		settings.DialTimeout = defaults.DialTimeout
+	}
+	if settings.KeepAlive == 0 {
+		settings.KeepAlive = defaults.KeepAlive
+	}
+	if settings.TLSHandshakeTimeout <= 0 {
+		settings.TLSHandshakeTimeout = defaults.TLSHandshakeTimeout
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   settings.DialTimeout,
+		KeepAlive: settings.KeepAlive,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          settings.MaxIdleConns,
+		MaxIdleConnsPerHost:   settings.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       settings.MaxConnsPerHost,
+		IdleConnTimeout:       settings.IdleConnTimeout,
+		DisableKeepAlives:     settings.DisableKeepAlives,
+		TLSHandshakeTimeout:   settings.TLSHandshakeTimeout,
+		TLSClientConfig:       settings.TLSConfig,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// proxyCall is the state of one proxied request. The proxies are shared by
+// all requests, so they find it in the request context.
+type proxyCall struct {
+	c          *gin.Context
+	itemsField string
+	requestID  string
+	// dedup is set when the request shares its upstream call with identical
+	// requests
+	dedup *sharedTransport
+	// failed is set when the instance failed the request, and err to why
+	failed bool
+	err    error
+	// retry is set when the request is retried if this attempt fails with
+	// a retryable failure, in which case withheld is set instead of the
+	// failure being written
+	retry    bool
+	withheld bool
+}
+
+type proxyCallKey struct{}
+
+// withProxyCall returns ctx carrying a request's proxy state
+func withProxyCall(ctx context.Context, call *proxyCall) context.Context {
+	return context.WithValue(ctx, proxyCallKey{}, call)
+}
+
+// proxyCallFrom returns the proxy state of a request
+func proxyCallFrom(ctx context.Context) *proxyCall {
+	call, _ := ctx.Value(proxyCallKey{}).(*proxyCall)
+	return call
+}
+
+// callTransport sends requests through their shared call when they are
+// deduplicated, and through the service's transport otherwise
+type callTransport struct {
+	transport http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *callTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if call := proxyCallFrom(req.Context()); call != nil && call.dedup != nil {
+		return call.dedup.RoundTrip(req)
+	}
+	return t.transport.RoundTrip(req)
+}
+
+// newReverseProxy creates the proxy for an instance of a service. It is built
+// once and reused, so requests share the service's pooled connections.
+func (g *Gateway) newReverseProxy(service *ServiceConfig, instance *Instance) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(instance.target)
+	proxy.Transport = &callTransport{transport: service.transport}
+
+	// Custom director to modify the request
+	originalDirector := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		originalDirector(req)
+
+		// Forward only allowed client headers, then add the identity the
+		// gateway verified
+		service.Headers.sanitizeHeaders(req.Header)
+		if call := proxyCallFrom(req.Context()); call != nil {
+			g.setIdentityHeaders(call.c, req.Header)
+		}
+
+		// Add tracing headers. The propagator replaces the client's trace
+		// context and baggage with the gateway's, which carries the verified
+		// business context.
+		req.Header.Del("Baggage")
+		otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+		if span := trace.SpanFromContext(req.Context()); span.SpanContext().IsValid() {
+			req.Header.Set("X-Trace-ID", span.SpanContext().TraceID().String())
+			req.Header.Set("X-Span-ID", span.SpanContext().SpanID().String())
+		}
+
+		// Add gateway headers
+		req.Header.Set("X-Forwarded-By", "api-gateway")
+		req.Header.Set("X-Gateway-Service", service.Name)
+	}
+
+	// Server errors count against the instance for outlier detection,
+	// responses that started an operation become 202 Accepted, versioned
+	// resources get their ETag and lists are put in the list envelope
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		var call *proxyCall
+		if resp.Request != nil {
+			call = proxyCallFrom(resp.Request.Context())
+		}
+		if call != nil && resp.StatusCode >= http.StatusInternalServerError {
+			call.failed = true
+			call.err = fmt.Errorf("upstream responded %s", resp.Status)
+			if call.retry && retryableStatus(resp.StatusCode) {
+				return call.err
+			}
+		}
+		acceptOperation(resp)
+		conditionalResponse(resp)
+		if call != nil && call.itemsField != "" {
+			return envelopeList(resp, call.itemsField, call.requestID)
+		}
+		return nil
+	}
+
+	// Custom error handler
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		log.Printf("Proxy error from %s: %v", instance.URL, err)
+		// A body over the route's limit is the client's fault
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(`{"error": "Request body too large"}`))
+			return
+		}
+
+		// A client that went away says nothing about the instance
+		if call := proxyCallFrom(req.Context()); call != nil && !errors.Is(err, context.Canceled) {
+			call.failed = true
+			call.err = err
+			if call.retry {
+				call.withheld = true
+				return
+			}
+		}
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`{"error": "Bad gateway"}`))
+	}
+
+	return proxy
+}
@@ -0,0 +1,176 @@
+package redisclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/config"
+)
+
+// New creates a Redis client for the configured topology (standalone, cluster
+// or sentinel) and verifies the connection
+func New(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	mode, opts, err := Options(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var client redis.UniversalClient
+	switch mode {
+	case config.RedisModeCluster:
+		client = redis.NewClusterClient(opts.Cluster())
+	case config.RedisModeSentinel:
+		client = redis.NewFailoverClient(opts.Failover())
+	default:
+		client = redis.NewClient(opts.Simple())
+	}
+
+	// Test connection
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if _, err := client.Ping(ctx).Result(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis (%s): %v", mode, err)
+	}
+
+	return client, nil
+}
+
+// Options resolves the topology and client options from the Redis URL and
+// explicit config fields. Supported URLs:
+//
+//	host:port
+//	redis://[user:password@]host:port[/db]
+//	redis+cluster://[user:password@]host1:port,host2:port
+//	redis+sentinel://[user:password@]host1:port,host2:port/master[/db]
+//
+// The rediss scheme variants enable TLS. Explicit fields take precedence.
+func Options(cfg config.RedisConfig) (string, *redis.UniversalOptions, error) {
+	mode := config.RedisModeStandalone
+	opts := &redis.UniversalOptions{
+		DB:           cfg.DB,
+		PoolSize:     cfg.PoolSize,
+		DialTimeout:  cfg.Timeout,
+		ReadTimeout:  cfg.Timeout,
+		WriteTimeout: cfg.Timeout,
+	}
+	useTLS := false
+
+	if cfg.URL != "" {
+		if !strings.Contains(cfg.URL, "://") {
+			opts.Addrs = []string{cfg.URL}
+		} else {
+			u, err := url.Parse(cfg.URL)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid Redis URL: %v", err)
+			}
+
+			scheme := u.Scheme
+			if strings.HasPrefix(scheme, "rediss") {
+				useTLS = true
+				scheme = "redis" + strings.TrimPrefix(scheme, "rediss")
+			}
+			switch scheme {
+			case "redis":
+			case "redis+cluster":
+				mode = config.RedisModeCluster
+			case "redis+sentinel":
+				mode = config.RedisModeSentinel
+			default:
+				return "", nil, fmt.Errorf("unsupported Redis URL scheme: %s", u.Scheme)
+			}
+
+			opts.Addrs = strings.Split(u.Host, ",")
+			if u.User != nil {
+				opts.Username = u.User.Username()
+				opts.Password, _ = u.User.Password()
+			}
+
+			path := strings.Split(strings.Trim(u.Path, "/"), "/")
+			if mode == config.RedisModeSentinel && path[0] != "" {
+				opts.MasterName = path[0]
+				path = path[1:]
+			}
+			if len(path) > 0 && path[0] != "" {
+				db, err := strconv.Atoi(path[0])
+				if err != nil {
+					return "", nil, fmt.Errorf("invalid Redis database in URL: %s", path[0])
+				}
+				opts.DB = db
+			}
+		}
+	}
+
+	if cfg.Mode != "" {
+		mode = cfg.Mode
+	}
+	if len(cfg.Addrs) > 0 {
+		opts.Addrs = cfg.Addrs
+	}
+	if cfg.MasterName != "" {
+		opts.MasterName = cfg.MasterName
+	}
+	if cfg.Username != "" {
+		opts.Username = cfg.Username
+	}
+	if cfg.Password != "" {
+		opts.Password = cfg.Password
+	}
+	opts.SentinelPassword = cfg.SentinelPassword
+
+	switch mode {
+	case config.RedisModeStandalone, config.RedisModeCluster:
+	case config.RedisModeSentinel:
+		if opts.MasterName == "" {
+			return "", nil, fmt.Errorf("Redis sentinel mode requires a master name")
+		}
+	default:
+		return "", nil, fmt.Errorf("unsupported Redis mode: %s", mode)
+	}
+
+	if useTLS || cfg.TLSEnabled {
+		tlsConfig, err := tlsConfig(cfg)
+		if err != nil {
+			return "", nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	return mode, opts, nil
+}
+
+// tlsConfig builds the TLS settings for Redis connections
+func tlsConfig(cfg config.RedisConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.TLSSkipVerify,
+	}
+
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Redis CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in Redis CA file %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
@@ -3,6 +3,7 @@ package resilience
 import (
 	"context"
 	"errors"
+	"math/rand"
 	"sync"
 	"time"
 )
@@ -16,6 +17,10 @@ const (
 	StateOpen
 )
 
+// minSlowStartShare is the share of calls admitted right after the circuit
+// closes
+const minSlowStartShare = 0.1
+
 // CircuitBreaker implements the circuit breaker pattern
 type CircuitBreaker struct {
 	mu              sync.RWMutex
@@ -23,7 +28,9 @@ type CircuitBreaker struct {
 	failureCount    int
 	successCount    int
 	lastFailureTime time.Time
+	closedAt        time.Time
 	settings        CircuitBreakerSettings
+	onStateChange   func(from, to CircuitBreakerState)
 }
 
 // CircuitBreakerSettings defines circuit breaker configuration
@@ -32,6 +39,7 @@ type CircuitBreakerSettings struct {
 	ResetTimeout    time.Duration // Time to wait before attempting reset
 	SuccessThreshold int          // Successful calls needed to close circuit
 	Timeout         time.Duration // Request timeout
+	SlowStartWindow time.Duration // Time to ramp up to full load after closing, 0 disables
 }
 
 // DefaultSettings returns default circuit breaker settings
@@ -41,6 +49,7 @@ func DefaultSettings() CircuitBreakerSettings {
 		ResetTimeout:     60 * time.Second,
 		SuccessThreshold: 3,
 		Timeout:          30 * time.Second,
+		SlowStartWindow:  30 * time.Second,
 	}
 }
 
@@ -52,44 +61,108 @@ func NewCircuitBreaker(settings CircuitBreakerSettings) *CircuitBreaker {
 	}
 }
 
+// OnStateChange sets a function called after the circuit changes state, e.g.
+// to report that it opened. It is called without the breaker's lock held.
+func (cb *CircuitBreaker) OnStateChange(fn func(from, to CircuitBreakerState)) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.onStateChange = fn
+}
+
+// setState changes the state with the lock held and returns a function
+// reporting the change, for the caller to run once it has released the lock
+func (cb *CircuitBreaker) setState(state CircuitBreakerState) func() {
+	from := cb.state
+	cb.state = state
+	if from == state || cb.onStateChange == nil {
+		return func() {}
+	}
+	fn := cb.onStateChange
+	return func() { fn(from, state) }
+}
+
 // Execute executes a function with circuit breaker protection
 func (cb *CircuitBreaker) Execute(ctx context.Context, fn func() error) error {
+	return cb.ExecuteWithTimeout(ctx, cb.settings.Timeout, fn)
+}
+
+// ExecuteWithTimeout is Execute with a request timeout other than the
+// breaker's, for calls known to take more or less time than usual
+func (cb *CircuitBreaker) ExecuteWithTimeout(ctx context.Context, timeout time.Duration, fn func() error) error {
 	state := cb.getState()
 
 	switch state {
 	case StateOpen:
 		return errors.New("circuit breaker is open")
 	case StateHalfOpen:
-		return cb.executeHalfOpen(ctx, fn)
+		return cb.executeHalfOpen(ctx, timeout, fn)
 	default:
-		return cb.executeClosed(ctx, fn)
+		return cb.executeClosed(ctx, timeout, fn)
+	}
+}
+
+// AdmitSlowStart reports whether a call should go to the protected service
+// while the circuit ramps back up after closing. The admitted share grows
+// linearly over the slow-start window, so a recovering service is not hit
+// with full load at once. The breaker itself never refuses calls for slow
+// start: callers with somewhere else to send a call, such as another region,
+// send it there when it is not admitted.
+func (cb *CircuitBreaker) AdmitSlowStart() bool {
+	cb.mu.RLock()
+	closedAt := cb.closedAt
+	cb.mu.RUnlock()
+
+	if closedAt.IsZero() || cb.settings.SlowStartWindow <= 0 {
+		return true
+	}
+
+	elapsed := time.Since(closedAt)
+	if elapsed >= cb.settings.SlowStartWindow {
+		return true
 	}
+	share := float64(elapsed) / float64(cb.settings.SlowStartWindow)
+	if share < minSlowStartShare {
+		share = minSlowStartShare
+	}
+	return rand.Float64() < share
+}
+
+// IsOpen reports whether the circuit breaker rejects calls. An open breaker
+// turns half-open once its reset timeout has passed.
+func (cb *CircuitBreaker) IsOpen() bool {
+	return cb.getState() == StateOpen
 }
 
 // getState returns the current state of the circuit breaker
 func (cb *CircuitBreaker) getState() CircuitBreakerState {
 	cb.mu.RLock()
-	defer cb.mu.RUnlock()
-
-	if cb.state == StateOpen {
-		if time.Since(cb.lastFailureTime) > cb.settings.ResetTimeout {
-			cb.mu.RUnlock()
+	state := cb.state
+	cb.mu.RUnlock()
+
+	if state == StateOpen {
+		cb.mu.RLock()
+		expired := time.Since(cb.lastFailureTime) > cb.settings.ResetTimeout
+		cb.mu.RUnlock()
+		if expired {
+			notify := func() {}
 			cb.mu.Lock()
 			if cb.state == StateOpen && time.Since(cb.lastFailureTime) > cb.settings.ResetTimeout {
-				cb.state = StateHalfOpen
+				notify = cb.setState(StateHalfOpen)
 				cb.successCount = 0
 			}
+			state = cb.state
 			cb.mu.Unlock()
-			cb.mu.RLock()
+			notify()
 		}
 	}
 
-	return cb.state
+	return state
 }
 
 // executeClosed executes function when circuit is closed
-func (cb *CircuitBreaker) executeClosed(ctx context.Context, fn func() error) error {
-	err := cb.executeWithTimeout(ctx, fn)
+func (cb *CircuitBreaker) executeClosed(ctx context.Context, timeout time.Duration, fn func() error) error {
+	err := cb.executeWithTimeout(ctx, timeout, fn)
 	
 	if err != nil {
 		cb.onFailure()
@@ -101,8 +174,8 @@ func (cb *CircuitBreaker) executeClosed(ctx context.Context, fn func() error) er
 }
 
 // executeHalfOpen executes function when circuit is half-open
-func (cb *CircuitBreaker) executeHalfOpen(ctx context.Context, fn func() error) error {
-	err := cb.executeWithTimeout(ctx, fn)
+func (cb *CircuitBreaker) executeHalfOpen(ctx context.Context, timeout time.Duration, fn func() error) error {
+	err := cb.executeWithTimeout(ctx, timeout, fn)
 	
 	if err != nil {
 		cb.onFailure()
@@ -114,8 +187,8 @@ func (cb *CircuitBreaker) executeHalfOpen(ctx context.Context, fn func() error)
 }
 
 // executeWithTimeout executes function with timeout
-func (cb *CircuitBreaker) executeWithTimeout(ctx context.Context, fn func() error) error {
-	ctx, cancel := context.WithTimeout(ctx, cb.settings.Timeout)
+func (cb *CircuitBreaker) executeWithTimeout(ctx context.Context, timeout time.Duration, fn func() error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	done := make(chan error, 1)
@@ -133,15 +206,18 @@ func (cb *CircuitBreaker) executeWithTimeout(ctx context.Context, fn func() erro
 
 // onSuccess handles successful execution
 func (cb *CircuitBreaker) onSuccess() {
+	notify := func() {}
+	defer func() { notify() }()
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
 	cb.successCount++
 
 	if cb.state == StateHalfOpen && cb.successCount >= cb.settings.SuccessThreshold {
-		cb.state = StateClosed
+		notify = cb.setState(StateClosed)
 		cb.failureCount = 0
 		cb.successCount = 0
+		cb.closedAt = time.Now()
 	} else if cb.state == StateClosed {
 		cb.failureCount = 0
 	}
@@ -149,6 +225,8 @@ func (cb *CircuitBreaker) onSuccess() {
 
 // onFailure handles failed execution
 func (cb *CircuitBreaker) onFailure() {
+	notify := func() {}
+	defer func() { notify() }()
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
@@ -156,7 +234,7 @@ func (cb *CircuitBreaker) onFailure() {
 	cb.lastFailureTime = time.Now()
 
 	if cb.failureCount >= cb.settings.MaxFailures {
-		cb.state = StateOpen
+		notify = cb.setState(StateOpen)
 	}
 }
 
@@ -170,6 +248,7 @@ func (cb *CircuitBreaker) GetStats() map[string]interface{} {
 		"failure_count": cb.failureCount,
 		"success_count": cb.successCount,
 		"last_failure":  cb.lastFailureTime,
+		"slow_start":    cb.settings.SlowStartWindow > 0 && !cb.closedAt.IsZero() && time.Since(cb.closedAt) < cb.settings.SlowStartWindow,
 	}
 }
 
@@ -194,6 +273,9 @@ type Retry struct {
 	MaxDelay    time.Duration
 	Multiplier  float64
 	Jitter      bool
+	// Retryable reports whether an error is worth retrying. Defaults to
+	// retrying every error.
+	Retryable   func(err error) bool
 }
 
 // DefaultRetry returns default retry configuration
@@ -223,6 +305,9 @@ func (r *Retry) Execute(ctx context.Context, fn func() error) error {
 
 		if err := fn(); err != nil {
 			lastErr = err
+			if r.Retryable != nil && !r.Retryable(err) {
+				return err
+			}
 			continue
 		}
 
@@ -0,0 +1,394 @@
+// Package reviews is a queue of flagged entities waiting for an admin's
+// manual review: payments a fraud check flagged, refund requests over the
+// review threshold and content held by moderation. Services submit items to
+// the shared queue in Redis; admins assign and resolve them through the
+// gateway, which publishes review.resolved for the submitting service to act
+// on. An item may hold the request that raised it, which the gateway runs
+// when the item is approved.
+package reviews
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/redisclient"
+)
+
+// Kinds of review
+const (
+	KindFraudCheck        = "fraud_check"
+	KindRefundRequest     = "refund_request"
+	KindContentModeration = "content_moderation"
+)
+
+// Item statuses. Items are open until an admin assigns them to themselves,
+// and resolved once approved or rejected.
+const (
+	StatusOpen     = "open"
+	StatusAssigned = "assigned"
+	StatusResolved = "resolved"
+)
+
+// Resolutions of an item
+const (
+	ResolutionApprove = "approve"
+	ResolutionReject  = "reject"
+)
+
+// Errors returned when updating items
+var (
+	ErrNotFound = errors.New("review item not found")
+	// ErrResolved is returned when changing an item that was already resolved
+	ErrResolved = errors.New("review item is already resolved")
+	// ErrAssignedElsewhere is returned when resolving an item assigned to
+	// another admin
+	ErrAssignedElsewhere = errors.New("review item is assigned to another admin")
+	// ErrInvalidResolution is returned for a resolution other than approve or
+	// reject
+	ErrInvalidResolution = errors.New("resolution must be approve or reject")
+	// ErrConflict is returned when an item changed while it was updated
+	ErrConflict = errors.New("review item changed concurrently")
+)
+
+// HeldRequest is a gateway request held until its review item is approved
+type HeldRequest struct {
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	RequestedBy string          `json:"requested_by"`
+}
+
+// Item is an entity flagged for review
+type Item struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// EntityType and EntityID name what is reviewed, e.g. a payment
+	EntityType string `json:"entity_type"`
+	EntityID   string `json:"entity_id"`
+	// Reasons are why the entity was flagged; flagging an entity again while
+	// its item is unresolved adds to them
+	Reasons []string `json:"reasons"`
+	// Source is the service that flagged the entity
+	Source  string                 `json:"source"`
+	Details map[string]interface{} `json:"details,omitempty"`
+	Request *HeldRequest           `json:"request,omitempty"`
+
+	Status     string     `json:"status"`
+	Assignee   string     `json:"assignee,omitempty"`
+	Resolution string     `json:"resolution,omitempty"`
+	Note       string     `json:"note,omitempty"`
+	ResolvedBy string     `json:"resolved_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+
+	// ResultStatus and Result are the response to the held request once run
+	ResultStatus int             `json:"result_status,omitempty"`
+	Result       json.RawMessage `json:"result,omitempty"`
+}
+
+// New creates an open item
+func New(kind, entityType, entityID, source string, reasons []string) *Item {
+	now := time.Now().UTC()
+	return &Item{
+		ID:         generateItemID(),
+		Kind:       kind,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Reasons:    reasons,
+		Source:     source,
+		Status:     StatusOpen,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// Assign assigns an unresolved item to an admin, or returns it to the open
+// queue if adminID is empty
+func (i *Item) Assign(adminID string) error {
+	if i.Status == StatusResolved {
+		return ErrResolved
+	}
+	i.Assignee = adminID
+	i.Status = StatusAssigned
+	if adminID == "" {
+		i.Status = StatusOpen
+	}
+	i.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// Resolve records an admin's resolution of an item. Items assigned to another
+// admin cannot be resolved until they are reassigned.
+func (i *Item) Resolve(adminID, resolution, note string) error {
+	if resolution != ResolutionApprove && resolution != ResolutionReject {
+		return ErrInvalidResolution
+	}
+	if i.Status == StatusResolved {
+		return ErrResolved
+	}
+	if i.Assignee != "" && i.Assignee != adminID {
+		return ErrAssignedElsewhere
+	}
+	now := time.Now().UTC()
+	i.Status = StatusResolved
+	i.Resolution = resolution
+	i.Note = note
+	i.ResolvedBy = adminID
+	i.ResolvedAt = &now
+	i.UpdatedAt = now
+	return nil
+}
+
+// addReasons adds the reasons the item does not have yet
+func (i *Item) addReasons(reasons []string) {
+	for _, reason := range reasons {
+		known := false
+		for _, existing := range i.Reasons {
+			if existing == reason {
+				known = true
+				break
+			}
+		}
+		if !known {
+			i.Reasons = append(i.Reasons, reason)
+		}
+	}
+	i.UpdatedAt = time.Now().UTC()
+}
+
+// generateItemID generates a random item ID
+func generateItemID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("rev_%d", time.Now().UnixNano())
+	}
+	return "rev_" + hex.EncodeToString(b)
+}
+
+// Filter selects items to list; empty fields match any item
+type Filter struct {
+	Status   string
+	Kind     string
+	Assignee string
+}
+
+// Matches reports whether an item passes the filter
+func (f Filter) Matches(item *Item) bool {
+	return (f.Status == "" || item.Status == f.Status) &&
+		(f.Kind == "" || item.Kind == f.Kind) &&
+		(f.Assignee == "" || item.Assignee == f.Assignee)
+}
+
+// Store keeps review items
+type Store interface {
+	// Submit queues an item. If the entity already has an unresolved item of
+	// the same kind, the new reasons are added to it and it is returned
+	// instead.
+	Submit(ctx context.Context, item *Item) (*Item, error)
+	// Get returns an item, or nil if it is unknown or past retention
+	Get(ctx context.Context, id string) (*Item, error)
+	// List returns the items within retention that match filter, oldest
+	// first so the queue is worked in order
+	List(ctx context.Context, filter Filter) ([]*Item, error)
+	// Update applies fn to the current item and saves it, atomically, so
+	// that two admins cannot both resolve an item. An error from fn is
+	// returned without saving.
+	Update(ctx context.Context, id string, fn func(*Item) error) (*Item, error)
+}
+
+// RedisStore implements Store in Redis
+type RedisStore struct {
+	client    redis.UniversalClient
+	retention time.Duration
+}
+
+// NewRedisStore creates a new Redis-backed review store
+func NewRedisStore(cfg config.RedisConfig, reviewCfg config.ReviewConfig) (*RedisStore, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisStore{client: client, retention: reviewCfg.Retention}, nil
+}
+
+// itemIndexKey is a sorted set of item IDs scored by creation time
+const itemIndexKey = "reviews:index"
+
+func itemKey(id string) string {
+	return fmt.Sprintf("reviews:id:%s", id)
+}
+
+// entityKey points at the unresolved item of a kind for an entity
+func entityKey(item *Item) string {
+	return fmt.Sprintf("reviews:entity:%s:%s:%s", item.Kind, item.EntityType, item.EntityID)
+}
+
+// Submit queues an item unless its entity already has an unresolved one
+func (s *RedisStore) Submit(ctx context.Context, item *Item) (*Item, error) {
+	claimed, err := s.client.SetNX(ctx, entityKey(item), item.ID, s.retention).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		existingID, err := s.client.Get(ctx, entityKey(item)).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		existing, err := s.Update(ctx, existingID, func(current *Item) error {
+			if current.Status == StatusResolved {
+				return ErrResolved
+			}
+			current.addReasons(item.Reasons)
+			return nil
+		})
+		if err == nil {
+			return existing, nil
+		}
+		if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrResolved) {
+			return nil, err
+		}
+		// The earlier item is gone or resolved; this one takes its place
+		if err := s.client.Set(ctx, entityKey(item), item.ID, s.retention).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal review item: %v", err)
+	}
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, itemKey(item.ID), data, s.retention)
+		pipe.ZAdd(ctx, itemIndexKey, &redis.Z{Score: float64(item.CreatedAt.Unix()), Member: item.ID})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Get returns an item
+func (s *RedisStore) Get(ctx context.Context, id string) (*Item, error) {
+	data, err := s.client.Get(ctx, itemKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalItem(data)
+}
+
+// List returns the items within retention that match filter, pruning the
+// index of expired ones
+func (s *RedisStore) List(ctx context.Context, filter Filter) ([]*Item, error) {
+	cutoff := time.Now().Add(-s.retention).Unix()
+	if err := s.client.ZRemRangeByScore(ctx, itemIndexKey, "-inf", fmt.Sprintf("(%d", cutoff)).Err(); err != nil {
+		return nil, err
+	}
+
+	ids, err := s.client.ZRange(ctx, itemIndexKey, 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	// Individual GETs rather than MGET, since the keys may live on different
+	// cluster nodes
+	cmds := make([]*redis.StringCmd, len(ids))
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.Get(ctx, itemKey(id))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	items := make([]*Item, 0, len(cmds))
+	for _, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		item, err := unmarshalItem(data)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Matches(item) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// Update applies fn to an item inside a WATCH transaction. Resolving an item
+// frees its entity for new items.
+func (s *RedisStore) Update(ctx context.Context, id string, fn func(*Item) error) (*Item, error) {
+	var updated *Item
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, itemKey(id)).Bytes()
+		if err == redis.Nil {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		item, err := unmarshalItem(data)
+		if err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+
+		data, err = json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("failed to marshal review item: %v", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, itemKey(id), data, s.retention)
+			return nil
+		})
+		updated = item
+		return err
+	}, itemKey(id))
+	if err == redis.TxFailedErr {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if updated.Status == StatusResolved {
+		// Only if the entity still points at this item
+		if current, err := s.client.Get(ctx, entityKey(updated)).Result(); err == nil && current == updated.ID {
+			s.client.Del(ctx, entityKey(updated))
+		}
+	}
+	return updated, nil
+}
+
+func unmarshalItem(data []byte) (*Item, error) {
+	var item Item
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal review item: %v", err)
+	}
+	return &item, nil
+}
@@ -0,0 +1,81 @@
+// Package revocation records when a user's tokens were revoked, such as when
+// the user is suspended, so the gateway refuses the tokens issued before then
+// even though they have not expired.
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/redisclient"
+)
+
+// Lookup returns when a user's tokens were last revoked, or the zero time if
+// they were not
+type Lookup interface {
+	RevokedAt(ctx context.Context, userID string) (time.Time, error)
+}
+
+// Store revokes and looks up users' tokens
+type Store interface {
+	Lookup
+	// Revoke revokes the tokens issued to a user until now. The record is
+	// kept for ttl, which must be at least the lifetime of the longest lived
+	// token.
+	Revoke(ctx context.Context, userID string, ttl time.Duration) error
+}
+
+// Revoked reports whether a token issued at issuedAt is revoked by a
+// revocation at revokedAt. Token times are in whole seconds, so a token
+// issued in the second of the revocation is revoked too, as is a token
+// without an issue time.
+func Revoked(revokedAt, issuedAt time.Time) bool {
+	return !revokedAt.IsZero() && !issuedAt.After(revokedAt)
+}
+
+// RedisStore implements Store in Redis so every gateway instance sees the
+// revocations user-service records
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisStore creates a new Redis-backed revocation store
+func NewRedisStore(cfg config.RedisConfig) (*RedisStore, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisStore{client: client}, nil
+}
+
+func revocationKey(userID string) string {
+	return fmt.Sprintf("revocation:user:%s", userID)
+}
+
+// RevokedAt returns when the user's tokens were last revoked
+func (s *RedisStore) RevokedAt(ctx context.Context, userID string) (time.Time, error) {
+	value, err := s.client.Get(ctx, revocationKey(userID)).Result()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid revocation time %q: %v", value, err)
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// Revoke revokes the tokens issued to a user until now
+func (s *RedisStore) Revoke(ctx context.Context, userID string, ttl time.Duration) error {
+	return s.client.Set(ctx, revocationKey(userID), strconv.FormatInt(time.Now().Unix(), 10), ttl).Err()
+}
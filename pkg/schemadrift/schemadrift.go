@@ -0,0 +1,438 @@
+// Package schemadrift compares a service's database schema against what its
+// GORM models expect, and against the schema history its migrations record.
+//
+// Services migrate with AutoMigrate, which adds tables, columns and indexes
+// but never drops or renames them, and which only runs when the service
+// starts. A schema changed by hand, a column left behind by a removed field,
+// or a build deployed against a database it never migrated all go unnoticed
+// until a query fails. Check reports them as drift:
+//
+//   - tables, columns and indexes the models expect but the database lacks,
+//     and columns whose type or nullability differ, are errors
+//   - columns the models no longer have are warnings, errors when strict
+//   - models that changed since the last recorded migration are errors
+//
+// Services run the check after migrating at startup, and as the
+// "check-schema" command in CI.
+package schemadrift
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"microservices-platform/pkg/buildinfo"
+)
+
+// Command is the argument that makes a service check its schema and exit
+const Command = "check-schema"
+
+// Startup check modes
+const (
+	ModeOff  = "off"
+	ModeWarn = "warn"
+	ModeFail = "fail"
+)
+
+// ErrDrift is returned when the schema has drifted from the models
+var ErrDrift = errors.New("database schema has drifted from the models")
+
+// Config holds schema drift check settings
+type Config struct {
+	// Mode is what a drifted schema does at startup: ModeWarn logs the
+	// report, ModeFail stops the service and ModeOff skips the check
+	Mode string
+	// Strict treats warnings, such as columns the models no longer have,
+	// as drift
+	Strict bool
+}
+
+// LoadConfig loads schema drift check settings from environment variables
+func LoadConfig() Config {
+	return Config{
+		Mode:   getEnv("SCHEMA_DRIFT_CHECK", ModeWarn),
+		Strict: getEnv("SCHEMA_DRIFT_STRICT", "false") == "true",
+	}
+}
+
+// Migration is a recorded migration of a service's schema
+type Migration struct {
+	ID      uint   `gorm:"primaryKey"`
+	Service string `gorm:"size:100;not null;index"`
+	// Version identifies the schema the models expected
+	Version   string    `gorm:"size:64;not null"`
+	Build     string    `gorm:"size:100"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName places the history in its own table
+func (Migration) TableName() string { return "schema_history" }
+
+// Problem is a difference between the database and the models
+type Problem struct {
+	// Table and Column locate the problem; Column is empty for tables,
+	// indexes and the schema history
+	Table   string
+	Column  string
+	Message string
+	// Warning is set for differences that do not break the models
+	Warning bool
+}
+
+func (p Problem) String() string {
+	mark := "-"
+	if p.Warning {
+		mark = "+"
+	}
+	location := p.Table
+	if p.Column != "" {
+		location += "." + p.Column
+	}
+	return fmt.Sprintf("%s %s: %s", mark, location, p.Message)
+}
+
+// Report is the outcome of a check
+type Report struct {
+	Service  string
+	Version  string
+	Problems []Problem
+}
+
+// Drifted reports whether the schema drifted, counting warnings if strict
+func (r *Report) Drifted(strict bool) bool {
+	for _, problem := range r.Problems {
+		if !problem.Warning || strict {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the report as a diff of the database against the models:
+// "-" marks what the models expect and the database lacks or has otherwise,
+// "+" what the database has beyond the models
+func (r *Report) String() string {
+	if len(r.Problems) == 0 {
+		return fmt.Sprintf("%s: schema matches the models (version %s)", r.Service, r.Version)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: schema differs from the models (version %s)", r.Service, r.Version)
+	for _, problem := range r.Problems {
+		b.WriteString("\n  " + problem.String())
+	}
+	return b.String()
+}
+
+// expectedColumn is a column a model expects
+type expectedColumn struct {
+	name     string
+	dataType string
+	notNull  bool
+}
+
+// expectedTable is a table a model expects
+type expectedTable struct {
+	model   interface{}
+	name    string
+	columns []expectedColumn
+	indexes []string
+}
+
+// Record appends a migration of service to the schema history, unless the
+// last one recorded the same models. Services call it after migrating.
+func Record(db *gorm.DB, service string, models ...interface{}) error {
+	tables, err := expect(db, models)
+	if err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(&Migration{}); err != nil {
+		return fmt.Errorf("failed to migrate the schema history: %v", err)
+	}
+
+	version := schemaVersion(tables)
+	last, err := lastMigration(db, service)
+	if err != nil {
+		return err
+	}
+	if last != nil && last.Version == version {
+		return nil
+	}
+	return db.Create(&Migration{
+		Service:   service,
+		Version:   version,
+		Build:     buildinfo.Get(service).Version,
+		AppliedAt: time.Now().UTC(),
+	}).Error
+}
+
+// Check compares the database schema against models and the schema history
+// of service
+func Check(ctx context.Context, db *gorm.DB, service string, models ...interface{}) (*Report, error) {
+	db = db.WithContext(ctx)
+	tables, err := expect(db, models)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Service: service, Version: schemaVersion(tables)}
+
+	for _, table := range tables {
+		problems, err := compareTable(db, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read table %s: %v", table.name, err)
+		}
+		report.Problems = append(report.Problems, problems...)
+	}
+
+	if !db.Migrator().HasTable(&Migration{}) {
+		report.Problems = append(report.Problems, Problem{Table: "schema_history", Message: "no migration recorded", Warning: true})
+		return report, nil
+	}
+	last, err := lastMigration(db, service)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case last == nil:
+		report.Problems = append(report.Problems, Problem{Table: "schema_history", Message: "no migration recorded", Warning: true})
+	case last.Version != report.Version:
+		report.Problems = append(report.Problems, Problem{
+			Table: "schema_history",
+			Message: fmt.Sprintf("models changed since the last migration (version %s, applied %s by build %s)",
+				last.Version, last.AppliedAt.Format(time.RFC3339), last.Build),
+		})
+	}
+	return report, nil
+}
+
+// Verify checks the schema after migrating at startup, as cfg.Mode says
+func Verify(ctx context.Context, db *gorm.DB, service string, cfg Config, models ...interface{}) error {
+	if cfg.Mode == ModeOff {
+		return nil
+	}
+	report, err := Check(ctx, db, service, models...)
+	if err != nil {
+		log.Printf("Failed to check the schema for drift: %v", err)
+		return nil
+	}
+	if !report.Drifted(cfg.Strict) {
+		return nil
+	}
+	if cfg.Mode == ModeFail {
+		return fmt.Errorf("%w:\n%s", ErrDrift, report)
+	}
+	log.Printf("WARNING: %s", report)
+	return nil
+}
+
+// Main runs the check-schema command: it checks the database at databaseURL,
+// prints the report and returns the exit code, 1 if the schema drifted
+func Main(service, databaseURL string, cfg Config, models ...interface{}) int {
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to connect to the database: %v\n", service, err)
+		return 2
+	}
+	report, err := Check(context.Background(), db, service, models...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", service, err)
+		return 2
+	}
+	fmt.Println(report)
+	if report.Drifted(cfg.Strict) {
+		return 1
+	}
+	return 0
+}
+
+// expect describes the tables models expect
+func expect(db *gorm.DB, models []interface{}) ([]expectedTable, error) {
+	tables := make([]expectedTable, 0, len(models))
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("failed to parse model %T: %v", model, err)
+		}
+
+		table := expectedTable{model: model, name: stmt.Table}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			table.columns = append(table.columns, expectedColumn{
+				name:     field.DBName,
+				dataType: db.Dialector.DataTypeOf(field),
+				notNull:  field.NotNull || field.PrimaryKey,
+			})
+		}
+		for name := range stmt.Schema.ParseIndexes() {
+			table.indexes = append(table.indexes, name)
+		}
+		sort.Strings(table.indexes)
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// compareTable compares a table with what its model expects
+func compareTable(db *gorm.DB, table expectedTable) ([]Problem, error) {
+	migrator := db.Migrator()
+	if !migrator.HasTable(table.model) {
+		return []Problem{{Table: table.name, Message: "table missing"}}, nil
+	}
+	columnTypes, err := migrator.ColumnTypes(table.model)
+	if err != nil {
+		return nil, err
+	}
+	actual := make(map[string]gorm.ColumnType, len(columnTypes))
+	for _, columnType := range columnTypes {
+		actual[columnType.Name()] = columnType
+	}
+
+	var problems []Problem
+	expected := make(map[string]bool, len(table.columns))
+	for _, column := range table.columns {
+		expected[column.name] = true
+		columnType, ok := actual[column.name]
+		if !ok {
+			problems = append(problems, Problem{Table: table.name, Column: column.name,
+				Message: fmt.Sprintf("column missing, the model expects %s", describe(column.dataType, column.notNull))})
+			continue
+		}
+
+		dataType, size := normalizeType(column.dataType)
+		actualType, _ := normalizeType(columnType.DatabaseTypeName())
+		if dataType != actualType {
+			problems = append(problems, Problem{Table: table.name, Column: column.name,
+				Message: fmt.Sprintf("column is %s, the model expects %s", actualType, column.dataType)})
+		} else if length, ok := columnType.Length(); ok && size > 0 && dataType == "varchar" && length != size {
+			problems = append(problems, Problem{Table: table.name, Column: column.name,
+				Message: fmt.Sprintf("column is varchar(%d), the model expects %s", length, column.dataType)})
+		}
+		if nullable, ok := columnType.Nullable(); ok && nullable == column.notNull {
+			problems = append(problems, Problem{Table: table.name, Column: column.name,
+				Message: fmt.Sprintf("column is %s, the model expects %s", nullability(!nullable), nullability(column.notNull))})
+		}
+	}
+
+	var extra []string
+	for name := range actual {
+		if !expected[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		problems = append(problems, Problem{Table: table.name, Column: name, Message: "column not in the model", Warning: true})
+	}
+
+	for _, index := range table.indexes {
+		if !migrator.HasIndex(table.model, index) {
+			problems = append(problems, Problem{Table: table.name, Message: fmt.Sprintf("index %s missing", index)})
+		}
+	}
+	return problems, nil
+}
+
+func lastMigration(db *gorm.DB, service string) (*Migration, error) {
+	var migrations []Migration
+	err := db.Where("service = ?", service).Order("id DESC").Limit(1).Find(&migrations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the schema history: %v", err)
+	}
+	if len(migrations) == 0 {
+		return nil, nil
+	}
+	return &migrations[0], nil
+}
+
+// schemaVersion hashes the tables, columns and indexes models expect
+func schemaVersion(tables []expectedTable) string {
+	var lines []string
+	for _, table := range tables {
+		for _, column := range table.columns {
+			lines = append(lines, fmt.Sprintf("%s.%s %s", table.name, column.name, describe(column.dataType, column.notNull)))
+		}
+		for _, index := range table.indexes {
+			lines = append(lines, fmt.Sprintf("%s index %s", table.name, index))
+		}
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:6])
+}
+
+// typeAliases maps SQL type names to the names Postgres reports
+var typeAliases = map[string]string{
+	"boolean":                     "bool",
+	"smallint":                    "int2",
+	"smallserial":                 "int2",
+	"integer":                     "int4",
+	"int":                         "int4",
+	"serial":                      "int4",
+	"bigint":                      "int8",
+	"bigserial":                   "int8",
+	"decimal":                     "numeric",
+	"real":                        "float4",
+	"double precision":            "float8",
+	"character varying":           "varchar",
+	"character":                   "bpchar",
+	"char":                        "bpchar",
+	"timestamp with time zone":    "timestamptz",
+	"timestamp without time zone": "timestamp",
+}
+
+var typeSize = regexp.MustCompile(`\(\s*(\d+)\s*(,\s*\d+\s*)?\)`)
+
+// normalizeType returns the name Postgres reports for a SQL type, and its
+// size if it has one, e.g. "varchar" and 255 for "varchar(255)"
+func normalizeType(dataType string) (string, int64) {
+	dataType = strings.ToLower(strings.TrimSpace(dataType))
+	var size int64
+	if match := typeSize.FindStringSubmatch(dataType); match != nil {
+		fmt.Sscan(match[1], &size)
+		dataType = strings.TrimSpace(typeSize.ReplaceAllString(dataType, ""))
+	}
+	if strings.HasSuffix(dataType, "[]") {
+		element, _ := normalizeType(strings.TrimSuffix(dataType, "[]"))
+		return "_" + element, size
+	}
+	if alias, ok := typeAliases[dataType]; ok {
+		dataType = alias
+	}
+	return dataType, size
+}
+
+func describe(dataType string, notNull bool) string {
+	if notNull {
+		return dataType + " NOT NULL"
+	}
+	return dataType
+}
+
+func nullability(notNull bool) string {
+	if notNull {
+		return "NOT NULL"
+	}
+	return "nullable"
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,370 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"microservices-platform/pkg/client"
+)
+
+// importBatchSize is the number of users imported per request
+const importBatchSize = 1000
+
+// deviceID identifies the seeder's sign-ins, so they don't alert the seeded
+// users to a new device each run
+const deviceID = "platform-seed"
+
+// LoadConfig holds the settings of loading a dataset
+type LoadConfig struct {
+	// BaseURL is the gateway address, e.g. "http://localhost:8080"
+	BaseURL string
+	// AdminEmail and AdminPassword sign in an existing admin, who imports
+	// the users, creates the products and ships the orders
+	AdminEmail    string
+	AdminPassword string
+	// Workers is the number of users whose orders are placed in parallel
+	Workers int
+	// PaymentWait is how long to wait for an order to record that its
+	// payment was authorized
+	PaymentWait time.Duration
+}
+
+// Report counts what loading a dataset did
+type Report struct {
+	UsersCreated     int
+	UsersExisting    int
+	ProductsCreated  int
+	ProductsExisting int
+	// Orders counts the orders placed by the state they were taken to
+	Orders map[string]int
+	// OrdersExisting counts the orders not placed because their user
+	// already had orders from an earlier run
+	OrdersExisting int
+	Failures       int
+}
+
+// loader loads one dataset
+type loader struct {
+	cfg     LoadConfig
+	ds      *Dataset
+	admin   *client.Client
+	adminID string
+
+	userIDs    map[int]string
+	productIDs map[int]string
+
+	mu     sync.Mutex
+	report Report
+}
+
+// Load loads a dataset through the API gateway. Users and products that
+// already exist are reused, and users who already have orders get no more,
+// so loading the same dataset again only fills in what is missing. Failures
+// of single users, products or orders are logged and counted; an error is
+// returned only if the dataset cannot be loaded at all.
+func Load(ctx context.Context, cfg LoadConfig, ds *Dataset) (*Report, error) {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+
+	l := &loader{
+		cfg: cfg,
+		ds:  ds,
+		admin: client.New(client.Config{
+			BaseURL:  cfg.BaseURL,
+			Email:    cfg.AdminEmail,
+			Password: cfg.AdminPassword,
+			DeviceID: deviceID,
+		}),
+		userIDs:    make(map[int]string),
+		productIDs: make(map[int]string),
+		report:     Report{Orders: make(map[string]int)},
+	}
+
+	login, err := l.admin.Login(ctx, cfg.AdminEmail, cfg.AdminPassword)
+	if err != nil {
+		return nil, fmt.Errorf("sign in as admin: %w", err)
+	}
+	if login.User == nil || login.User.Role != "admin" {
+		return nil, errors.New("sign in as admin: the account is not an admin")
+	}
+	l.adminID = login.User.ID
+
+	if err := l.loadUsers(ctx); err != nil {
+		return nil, err
+	}
+	if err := l.loadProducts(ctx); err != nil {
+		return nil, err
+	}
+	l.loadOrders(ctx)
+
+	return &l.report, nil
+}
+
+// loadUsers imports the users, skipping the ones already registered
+func (l *loader) loadUsers(ctx context.Context) error {
+	for start := 0; start < len(l.ds.Users); start += importBatchSize {
+		end := min(start+importBatchSize, len(l.ds.Users))
+
+		var data bytes.Buffer
+		encoder := json.NewEncoder(&data)
+		for _, user := range l.ds.Users[start:end] {
+			if err := encoder.Encode(user); err != nil {
+				return err
+			}
+		}
+
+		result, err := l.admin.Users.Import(ctx, client.ImportUsersRequest{
+			AdminUserID: l.adminID,
+			Data:        data.Bytes(),
+			Format:      "ndjson",
+			OnDuplicate: "skip",
+		})
+		if err != nil {
+			return fmt.Errorf("import users: %w", err)
+		}
+
+		for _, row := range result.Results {
+			switch row.Status {
+			case "created":
+				l.report.UsersCreated++
+			case "skipped":
+				l.report.UsersExisting++
+			default:
+				l.report.Failures++
+				log.Printf("seed: user %s: %s", row.Email, row.Error)
+				continue
+			}
+			l.userIDs[start+row.Row-1] = row.UserID
+		}
+	}
+	return nil
+}
+
+// loadProducts creates the products whose SKU is not in the catalog yet
+func (l *loader) loadProducts(ctx context.Context) error {
+	existing := make(map[string]string)
+	products := l.admin.Products.List(client.ListProductsOptions{PageSize: 100})
+	for products.Next(ctx) {
+		existing[products.Item().SKU] = products.Item().ID
+	}
+	if err := products.Err(); err != nil {
+		return fmt.Errorf("list products: %w", err)
+	}
+
+	for i, product := range l.ds.Products {
+		if id, ok := existing[product.SKU]; ok {
+			l.productIDs[i] = id
+			l.report.ProductsExisting++
+			continue
+		}
+
+		created, err := l.admin.Products.Create(ctx, product)
+		if err != nil {
+			l.report.Failures++
+			log.Printf("seed: product %s: %v", product.SKU, err)
+			continue
+		}
+		l.productIDs[i] = created.ID
+		l.report.ProductsCreated++
+	}
+	return nil
+}
+
+// loadOrders places the orders of each user, Workers users at a time
+func (l *loader) loadOrders(ctx context.Context) {
+	byUser := make(map[int][]int)
+	var users []int
+	for i, order := range l.ds.Orders {
+		if _, ok := byUser[order.User]; !ok {
+			users = append(users, order.User)
+		}
+		byUser[order.User] = append(byUser[order.User], i)
+	}
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < l.cfg.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for user := range queue {
+				l.loadUserOrders(ctx, user, byUser[user])
+			}
+		}()
+	}
+	for _, user := range users {
+		queue <- user
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// loadUserOrders places a user's orders, signed in as the user, unless the
+// user already has orders
+func (l *loader) loadUserOrders(ctx context.Context, user int, orders []int) {
+	userID, ok := l.userIDs[user]
+	if !ok {
+		l.fail(len(orders), "seed: orders of user %s: the user was not imported", l.ds.Users[user].Email)
+		return
+	}
+
+	c := client.New(client.Config{
+		BaseURL:  l.cfg.BaseURL,
+		Email:    l.ds.Users[user].Email,
+		Password: l.ds.Users[user].Password,
+		DeviceID: deviceID,
+	})
+
+	placed, err := c.Orders.List(client.ListOrdersOptions{UserID: userID, Limit: 1}).All(ctx)
+	if err != nil {
+		l.fail(len(orders), "seed: orders of user %s: %v", l.ds.Users[user].Email, err)
+		return
+	}
+	if len(placed) > 0 {
+		l.mu.Lock()
+		l.report.OrdersExisting += len(orders)
+		l.mu.Unlock()
+		return
+	}
+
+	for _, i := range orders {
+		if err := l.placeOrder(ctx, c, userID, i); err != nil {
+			l.fail(1, "seed: order %d (%s) of user %s: %v", i+1, l.ds.Orders[i].State, l.ds.Users[user].Email, err)
+			continue
+		}
+		l.mu.Lock()
+		l.report.Orders[l.ds.Orders[i].State]++
+		l.mu.Unlock()
+	}
+}
+
+// placeOrder places an order as its user and takes it to its state
+func (l *loader) placeOrder(ctx context.Context, c *client.Client, userID string, i int) error {
+	spec := l.ds.Orders[i]
+
+	req := client.CreateOrderRequest{
+		UserID:          userID,
+		ShippingAddress: spec.ShippingAddress,
+		BillingAddress:  spec.ShippingAddress,
+	}
+	for _, item := range spec.Items {
+		productID, ok := l.productIDs[item.Product]
+		if !ok {
+			return fmt.Errorf("product %s was not created", l.ds.Products[item.Product].SKU)
+		}
+		req.Items = append(req.Items, client.CreateOrderItem{ProductID: productID, Quantity: item.Quantity})
+	}
+
+	order, err := c.Orders.Create(ctx, req)
+	if err != nil {
+		return fmt.Errorf("place: %w", err)
+	}
+	if spec.State == StatePending {
+		return nil
+	}
+
+	if order, err = l.pay(ctx, c, order, spec, i); err != nil {
+		return err
+	}
+
+	switch spec.State {
+	case StateConfirmed:
+		_, err = l.admin.Orders.UpdateStatus(ctx, order.ID, "confirmed")
+	case StateCancelled:
+		_, err = c.Orders.Cancel(ctx, order.ID, "Changed my mind")
+	case StatePartiallyShipped:
+		first := order.Items[0]
+		quantity := first.Quantity
+		if len(order.Items) == 1 {
+			quantity--
+		}
+		err = l.ship(ctx, order, spec, []client.ShipmentItem{{ItemID: first.ID, Quantity: quantity}})
+	case StateShipped:
+		err = l.ship(ctx, order, spec, nil)
+	case StateDelivered, StateRefunded:
+		if err = l.ship(ctx, order, spec, nil); err != nil {
+			break
+		}
+		if _, err = l.admin.Orders.UpdateStatus(ctx, order.ID, "delivered"); err != nil || spec.State != StateRefunded {
+			break
+		}
+		_, err = l.admin.Payments.Refund(ctx, order.PaymentID, order.Items[0].UnitPrice, "Returned by customer")
+	}
+	if err != nil {
+		return fmt.Errorf("take to %s: %w", spec.State, err)
+	}
+	return nil
+}
+
+// pay authorizes payment of an order with a test card and waits for the
+// order to record the authorization, which it learns of by event
+func (l *loader) pay(ctx context.Context, c *client.Client, order *client.Order, spec Order, i int) (*client.Order, error) {
+	user := l.ds.Users[spec.User]
+	result, err := c.Payments.Create(ctx, client.CreatePaymentRequest{
+		OrderID:  order.ID,
+		UserID:   order.UserID,
+		Amount:   order.TotalAmount,
+		Currency: "USD",
+		Method:   spec.PaymentMethod,
+		Card: &client.PaymentCard{
+			Number:      spec.CardNumber,
+			ExpiryMonth: "12",
+			ExpiryYear:  strconv.Itoa(time.Now().Year() + 3),
+			CVV:         "123",
+			HolderName:  user.FirstName + " " + user.LastName,
+		},
+		CaptureMethod:  "CAPTURE_METHOD_MANUAL",
+		IdempotencyKey: fmt.Sprintf("seed-%d-order-%d", l.ds.Seed, i+1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pay: %w", err)
+	}
+	if result.RedirectURL != "" {
+		return nil, fmt.Errorf("pay: the payment needs the payer at %s", result.RedirectURL)
+	}
+
+	deadline := time.Now().Add(l.cfg.PaymentWait)
+	for {
+		order, err = l.admin.Orders.Get(ctx, order.ID)
+		if err != nil {
+			return nil, fmt.Errorf("pay: %w", err)
+		}
+		if order.PaymentStatus == "authorized" {
+			return order, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("pay: the order did not record the authorization within %s", l.cfg.PaymentWait)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// ship ships items of an order, or everything if items is empty
+func (l *loader) ship(ctx context.Context, order *client.Order, spec Order, items []client.ShipmentItem) error {
+	_, _, err := l.admin.Orders.CreateShipment(ctx, order.ID, client.CreateShipmentRequest{
+		Items:          items,
+		Carrier:        spec.Carrier,
+		TrackingNumber: spec.TrackingNumber,
+	})
+	return err
+}
+
+// fail logs a failure affecting count orders
+func (l *loader) fail(count int, format string, args ...interface{}) {
+	log.Printf(format, args...)
+	l.mu.Lock()
+	l.report.Failures += count
+	l.mu.Unlock()
+}
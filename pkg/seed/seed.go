@@ -0,0 +1,309 @@
+// Package seed generates demo data for the platform (users, products with
+// images, and orders in every state with their payments) and loads it
+// through the API gateway. The same seed always generates the same data, so
+// integration tests, load tests and demo environments can rely on it.
+package seed
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"microservices-platform/pkg/client"
+)
+
+// Order states the generator spreads orders across
+const (
+	// StatePending is an order that was never paid
+	StatePending = "pending"
+	// StateConfirmed is an order whose payment is authorized
+	StateConfirmed = "confirmed"
+	// StatePartiallyShipped is an order whose first item has shipped
+	StatePartiallyShipped = "partially_shipped"
+	StateShipped          = "shipped"
+	StateDelivered        = "delivered"
+	// StateCancelled is an order cancelled after payment, which voids the
+	// authorization
+	StateCancelled = "cancelled"
+	// StateRefunded is a delivered order whose first item was refunded
+	StateRefunded = "refunded"
+)
+
+// stateWeights is how often each state is generated, out of their sum
+var stateWeights = []struct {
+	state  string
+	weight int
+}{
+	{StatePending, 15},
+	{StateConfirmed, 20},
+	{StatePartiallyShipped, 10},
+	{StateShipped, 20},
+	{StateDelivered, 20},
+	{StateCancelled, 10},
+	{StateRefunded, 5},
+}
+
+// States lists the order states in the order they progress
+func States() []string {
+	states := make([]string, len(stateWeights))
+	for i, w := range stateWeights {
+		states[i] = w.state
+	}
+	return states
+}
+
+// Config sizes the generated data
+type Config struct {
+	Seed     int64
+	Users    int
+	Products int
+	Orders   int
+	// Password is the password of every generated user
+	Password string
+	// EmailDomain is the domain of generated email addresses
+	EmailDomain string
+	// ImageBaseURL serves product images; an image is
+	// <ImageBaseURL>/<sku>-<n>/800/800, the URL scheme of picsum.photos
+	ImageBaseURL string
+}
+
+// DefaultConfig returns a small demo environment
+func DefaultConfig() Config {
+	return Config{
+		Seed:         1,
+		Users:        50,
+		Products:     100,
+		Orders:       200,
+		Password:     "Seeded-Demo-Passw0rd",
+		EmailDomain:  "demo.example.com",
+		ImageBaseURL: "https://picsum.photos/seed",
+	}
+}
+
+// Dataset is the data generated for a seed
+type Dataset struct {
+	Seed     int64                   `json:"seed"`
+	Users    []User                  `json:"users"`
+	Products []client.ProductRequest `json:"products"`
+	Orders   []Order                 `json:"orders"`
+}
+
+// User is a generated user. Password is included so load tests can sign in
+// as the user.
+type User struct {
+	Email     string `json:"email"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Locale    string `json:"locale"`
+	TimeZone  string `json:"time_zone"`
+}
+
+// Order is a generated order of Users[User], to be taken to State
+type Order struct {
+	User            int         `json:"user"`
+	Items           []OrderItem `json:"items"`
+	ShippingAddress string      `json:"shipping_address"`
+	State           string      `json:"state"`
+	// Payment, unless the order is pending
+	PaymentMethod string `json:"payment_method,omitempty"`
+	CardNumber    string `json:"card_number,omitempty"`
+	// Shipment, if the order ships
+	Carrier        string `json:"carrier,omitempty"`
+	TrackingNumber string `json:"tracking_number,omitempty"`
+}
+
+// OrderItem is Quantity of Products[Product]
+type OrderItem struct {
+	Product  int   `json:"product"`
+	Quantity int32 `json:"quantity"`
+}
+
+var firstNames = []string{
+	"Ada", "Alan", "Amara", "Ana", "Ben", "Carla", "Chen", "Diego", "Elena", "Emil",
+	"Fatima", "Felix", "Grace", "Hana", "Ivan", "Jonas", "Julia", "Kofi", "Lea", "Liam",
+	"Lucia", "Maya", "Mateo", "Nina", "Omar", "Paul", "Priya", "Sofia", "Tom", "Yuki",
+}
+
+var lastNames = []string{
+	"Adams", "Bauer", "Becker", "Costa", "Dubois", "Fischer", "Garcia", "Hoffmann", "Ito", "Jensen",
+	"Khan", "Kowalski", "Laurent", "Lopez", "Martin", "Meyer", "Moreau", "Novak", "Okafor", "Petit",
+	"Rossi", "Schmidt", "Silva", "Smith", "Tanaka", "Taylor", "Weber", "Wilson", "Young", "Zimmer",
+}
+
+// regions pairs locales with a time zone and cities where they are spoken
+var regions = []struct {
+	locale   string
+	timeZone string
+	cities   []string
+}{
+	{"en-US", "America/New_York", []string{"New York, NY 10001", "Boston, MA 02108", "Atlanta, GA 30303"}},
+	{"en-US", "America/Los_Angeles", []string{"Seattle, WA 98101", "San Diego, CA 92101", "Portland, OR 97201"}},
+	{"en-GB", "Europe/London", []string{"London EC1A 1BB", "Manchester M1 1AE", "Leeds LS1 1UR"}},
+	{"de-DE", "Europe/Berlin", []string{"10115 Berlin", "80331 München", "20095 Hamburg"}},
+	{"fr-FR", "Europe/Paris", []string{"75001 Paris", "69001 Lyon", "13001 Marseille"}},
+	{"es-ES", "Europe/Madrid", []string{"28001 Madrid", "08001 Barcelona", "46001 Valencia"}},
+}
+
+var streets = []string{"Main Street", "Oak Avenue", "Station Road", "Hauptstraße", "Rue de la Paix", "Calle Mayor", "Park Lane", "Mill Road"}
+
+// categories holds each category's brands, items and price range
+var categories = []struct {
+	name     string
+	brands   []string
+	items    []string
+	minPrice float64
+	maxPrice float64
+}{
+	{"Electronics", []string{"Voltix", "Nordsound", "Pixelcraft"}, []string{"Headphones", "Keyboard", "Monitor", "Speaker", "Webcam", "Charger"}, 19, 899},
+	{"Home", []string{"Hearth & Co", "Lumen", "Casa Verde"}, []string{"Lamp", "Throw Blanket", "Coffee Grinder", "Vase", "Cutting Board"}, 9, 249},
+	{"Books", []string{"Inkwell Press", "Paperlane"}, []string{"Cookbook", "Travel Guide", "Novel", "Field Journal"}, 7, 59},
+	{"Sports", []string{"Trailmark", "Peakform", "Swiftline"}, []string{"Yoga Mat", "Water Bottle", "Running Shoes", "Backpack", "Dumbbell Set"}, 12, 329},
+	{"Clothing", []string{"Threadwell", "Northloom"}, []string{"Hoodie", "Rain Jacket", "Wool Socks", "Linen Shirt", "Beanie"}, 9, 199},
+}
+
+var adjectives = []string{"Classic", "Compact", "Deluxe", "Essential", "Lightweight", "Pro", "Recycled", "Smart", "Travel", "Ultra"}
+
+// Test card numbers, approved by payment providers' sandboxes
+var testCards = []struct {
+	method string
+	number string
+}{
+	{"PAYMENT_METHOD_CREDIT_CARD", "4242424242424242"},
+	{"PAYMENT_METHOD_CREDIT_CARD", "5555555555554444"},
+	{"PAYMENT_METHOD_DEBIT_CARD", "4000056655665556"},
+}
+
+var carriers = []string{"UPS", "DHL", "FedEx"}
+
+// Generate generates the dataset of cfg. It only depends on cfg, so the same
+// configuration always yields the same dataset.
+func Generate(cfg Config) *Dataset {
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	ds := &Dataset{Seed: cfg.Seed}
+
+	for i := 0; i < cfg.Users; i++ {
+		first, last := pick(rng, firstNames), pick(rng, lastNames)
+		region := regions[rng.Intn(len(regions))]
+		username := fmt.Sprintf("%s.%s%d", strings.ToLower(first), strings.ToLower(last), i+1)
+		ds.Users = append(ds.Users, User{
+			Email:     username + "@" + cfg.EmailDomain,
+			Username:  username,
+			Password:  cfg.Password,
+			FirstName: first,
+			LastName:  last,
+			Locale:    region.locale,
+			TimeZone:  region.timeZone,
+		})
+	}
+
+	for i := 0; i < cfg.Products; i++ {
+		category := categories[rng.Intn(len(categories))]
+		brand, item := pick(rng, category.brands), pick(rng, category.items)
+		name := pick(rng, adjectives) + " " + item
+		sku := fmt.Sprintf("DEMO-%d-%04d", cfg.Seed, i+1)
+
+		// Most products are in stock; some have run out
+		inventory := int32(5 + rng.Intn(496))
+		if rng.Intn(20) == 0 {
+			inventory = 0
+		}
+
+		var images []string
+		for n, count := 1, 1+rng.Intn(3); n <= count; n++ {
+			images = append(images, fmt.Sprintf("%s/%s-%d/800/800", strings.TrimSuffix(cfg.ImageBaseURL, "/"), strings.ToLower(sku), n))
+		}
+
+		ds.Products = append(ds.Products, client.ProductRequest{
+			Name:              name,
+			Description:       fmt.Sprintf("%s from the %s range of %s.", name, strings.ToLower(category.name), brand),
+			Price:             price(rng, category.minPrice, category.maxPrice),
+			Category:          category.name,
+			Brand:             brand,
+			SKU:               sku,
+			InventoryQuantity: inventory,
+			Images:            images,
+		})
+	}
+
+	inStock := make([]int, 0, len(ds.Products))
+	for i, product := range ds.Products {
+		if product.InventoryQuantity > 0 {
+			inStock = append(inStock, i)
+		}
+	}
+	if len(ds.Users) == 0 || len(inStock) == 0 {
+		return ds
+	}
+
+	for i := 0; i < cfg.Orders; i++ {
+		user := rng.Intn(len(ds.Users))
+		order := Order{
+			User:            user,
+			ShippingAddress: address(rng, ds.Users[user]),
+			State:           state(rng),
+		}
+
+		// Up to four different products
+		for _, p := range rng.Perm(len(inStock))[:1+rng.Intn(min(4, len(inStock)))] {
+			order.Items = append(order.Items, OrderItem{Product: inStock[p], Quantity: int32(1 + rng.Intn(3))})
+		}
+
+		// A partial shipment needs something left to ship
+		if order.State == StatePartiallyShipped && len(order.Items) == 1 && order.Items[0].Quantity == 1 {
+			order.Items[0].Quantity = 2
+		}
+
+		if order.State != StatePending {
+			card := testCards[rng.Intn(len(testCards))]
+			order.PaymentMethod, order.CardNumber = card.method, card.number
+		}
+		switch order.State {
+		case StatePartiallyShipped, StateShipped, StateDelivered, StateRefunded:
+			order.Carrier = pick(rng, carriers)
+			order.TrackingNumber = fmt.Sprintf("%s%010d", strings.ToUpper(order.Carrier[:2]), rng.Int63n(1e10))
+		}
+		ds.Orders = append(ds.Orders, order)
+	}
+	return ds
+}
+
+// pick returns a random element of values
+func pick(rng *rand.Rand, values []string) string {
+	return values[rng.Intn(len(values))]
+}
+
+// price returns a price between low and high ending in .99
+func price(rng *rand.Rand, low, high float64) float64 {
+	whole := math.Floor(low + rng.Float64()*(high-low))
+	return whole + 0.99
+}
+
+// state returns an order state, weighted by stateWeights
+func state(rng *rand.Rand) string {
+	total := 0
+	for _, w := range stateWeights {
+		total += w.weight
+	}
+	n := rng.Intn(total)
+	for _, w := range stateWeights {
+		if n < w.weight {
+			return w.state
+		}
+		n -= w.weight
+	}
+	return StatePending
+}
+
+// address returns a shipping address in a city of the user's region
+func address(rng *rand.Rand, user User) string {
+	var cities []string
+	for _, region := range regions {
+		if region.locale == user.Locale && region.timeZone == user.TimeZone {
+			cities = region.cities
+		}
+	}
+	return fmt.Sprintf("%s %s, %d %s, %s", user.FirstName, user.LastName, 1+rng.Intn(200), pick(rng, streets), pick(rng, cities))
+}
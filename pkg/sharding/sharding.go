@@ -0,0 +1,331 @@
+// Package sharding routes a service's tenants to database shards. Large
+// tenants get a database of their own, while everyone else stays on the
+// service's own database, the default shard. A tenant's shard is pinned in
+// configuration or looked up in the tenant_shards table of the default shard.
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// DefaultShard names the service's own database
+const DefaultShard = "default"
+
+// How a tenant's shard was found, the source label of
+// database_shard_routes_total
+const (
+	SourcePinned  = "pinned"
+	SourceLookup  = "lookup"
+	SourceDefault = "default"
+)
+
+// Config holds the shards and the tenants routed to them
+type Config struct {
+	// Shards maps shard names to their DSN. The default shard is not listed.
+	Shards map[string]string
+	// Tenants pins tenants to shards
+	Tenants map[string]string
+	// Lookup looks up tenants that are not pinned in the tenant_shards table
+	Lookup bool
+	// LookupTTL is how long a looked up shard is cached
+	LookupTTL time.Duration
+}
+
+// LoadConfig loads sharding configuration from environment variables.
+// DB_SHARDS lists shards as "name=dsn" separated by ";", and DB_TENANT_SHARDS
+// pins tenants as "tenant=shard" separated by ",".
+func LoadConfig() Config {
+	return Config{
+		Shards:    parsePairs(os.Getenv("DB_SHARDS"), ";"),
+		Tenants:   parsePairs(os.Getenv("DB_TENANT_SHARDS"), ","),
+		Lookup:    getBoolEnv("DB_SHARD_LOOKUP", false),
+		LookupTTL: getDurationEnv("DB_SHARD_LOOKUP_TTL", time.Minute),
+	}
+}
+
+// TenantShard assigns a tenant to a shard. Rows live in the default shard;
+// moving a tenant means copying its data before changing its row.
+type TenantShard struct {
+	TenantID  string    `gorm:"primaryKey"`
+	Shard     string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// Opener opens the database of a shard
+type Opener func(dsn string) (*gorm.DB, error)
+
+// Router routes tenants to the database of their shard. Each shard has its
+// own connection pool.
+type Router struct {
+	service string
+	cfg     Config
+	// names lists the shards, the default shard first
+	names  []string
+	shards map[string]*gorm.DB
+
+	mu      sync.Mutex
+	lookups map[string]lookup
+}
+
+// lookup is a cached tenant_shards lookup
+type lookup struct {
+	shard   string
+	source  string
+	expires time.Time
+}
+
+// New creates a router over the default shard, primary, and the shards in
+// cfg, which it opens with open
+func New(service string, primary *gorm.DB, cfg Config, open Opener) (*Router, error) {
+	r := &Router{
+		service: service,
+		cfg:     cfg,
+		names:   []string{DefaultShard},
+		shards:  map[string]*gorm.DB{DefaultShard: primary},
+		lookups: make(map[string]lookup),
+	}
+
+	for tenant, shard := range cfg.Tenants {
+		if _, ok := cfg.Shards[shard]; !ok && shard != DefaultShard {
+			return nil, fmt.Errorf("tenant %s is pinned to unknown shard %q", tenant, shard)
+		}
+	}
+
+	names := make([]string, 0, len(cfg.Shards))
+	for name := range cfg.Shards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == DefaultShard {
+			r.Close()
+			return nil, fmt.Errorf("shard name %q is reserved for the service's own database", name)
+		}
+		db, err := open(cfg.Shards[name])
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("open shard %s: %w", name, err)
+		}
+		r.names = append(r.names, name)
+		r.shards[name] = db
+	}
+
+	for _, name := range r.names {
+		if err := r.shards[name].Use(&queryMetrics{service: service, shard: name}); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// For returns the database of the tenant's shard. Requests without a tenant
+// go to the default shard.
+func (r *Router) For(ctx context.Context, tenantID string) (*gorm.DB, error) {
+	shard, err := r.ShardOf(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return r.shards[shard], nil
+}
+
+// ShardOf returns the name of the tenant's shard
+func (r *Router) ShardOf(ctx context.Context, tenantID string) (string, error) {
+	shard, source, err := r.resolve(ctx, tenantID)
+	if err != nil {
+		return "", err
+	}
+	metrics.RecordShardRoute(r.service, shard, source)
+	return shard, nil
+}
+
+// resolve finds the tenant's shard and how it was found
+func (r *Router) resolve(ctx context.Context, tenantID string) (string, string, error) {
+	if tenantID == "" {
+		return DefaultShard, SourceDefault, nil
+	}
+	if shard, ok := r.cfg.Tenants[tenantID]; ok {
+		return shard, SourcePinned, nil
+	}
+	if !r.cfg.Lookup {
+		return DefaultShard, SourceDefault, nil
+	}
+
+	r.mu.Lock()
+	cached, ok := r.lookups[tenantID]
+	r.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.shard, cached.source, nil
+	}
+
+	// Find rather than First, so tenants without a row are not logged as
+	// errors
+	var assignments []TenantShard
+	err := r.shards[DefaultShard].WithContext(ctx).
+		Where("tenant_id = ?", tenantID).
+		Limit(1).
+		Find(&assignments).Error
+	if err != nil {
+		// Guessing would split the tenant's data across shards
+		return "", "", fmt.Errorf("look up shard of tenant %s: %w", tenantID, err)
+	}
+
+	found := lookup{shard: DefaultShard, source: SourceDefault, expires: time.Now().Add(r.cfg.LookupTTL)}
+	if len(assignments) > 0 {
+		if _, ok := r.shards[assignments[0].Shard]; !ok {
+			return "", "", fmt.Errorf("tenant %s is assigned to unknown shard %q", tenantID, assignments[0].Shard)
+		}
+		found.shard, found.source = assignments[0].Shard, SourceLookup
+	}
+
+	r.mu.Lock()
+	r.lookups[tenantID] = found
+	r.mu.Unlock()
+	return found.shard, found.source, nil
+}
+
+// Names lists the shards, the default shard first
+func (r *Router) Names() []string {
+	return append([]string(nil), r.names...)
+}
+
+// Each calls fn with every shard in turn, the default shard first, stopping
+// at the first error
+func (r *Router) Each(fn func(name string, db *gorm.DB) error) error {
+	for _, name := range r.names {
+		if err := fn(name, r.shards[name]); err != nil {
+			return fmt.Errorf("shard %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Migrate brings every shard's schema up to date with migrate, and the
+// tenant_shards table of the default shard if tenants are looked up. Shards
+// are migrated one after another, so a failure leaves the ones before it
+// migrated and the rest untouched.
+func (r *Router) Migrate(ctx context.Context, migrate func(db *gorm.DB) error) error {
+	if r.cfg.Lookup {
+		if err := r.shards[DefaultShard].WithContext(ctx).AutoMigrate(&TenantShard{}); err != nil {
+			return fmt.Errorf("migrate tenant shards: %w", err)
+		}
+	}
+	return r.Each(func(name string, db *gorm.DB) error {
+		if err := migrate(db.WithContext(ctx)); err != nil {
+			return err
+		}
+		if len(r.names) > 1 {
+			log.Printf("Migrated shard %s", name)
+		}
+		return nil
+	})
+}
+
+// Close closes the connection pools of the shards it opened. The default
+// shard is left to its owner.
+func (r *Router) Close() error {
+	var errs []error
+	for _, name := range r.names[1:] {
+		sqlDB, err := r.shards[name].DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("close shard %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// queryMetrics counts and times a shard's queries
+type queryMetrics struct {
+	service string
+	shard   string
+}
+
+const queryStartKey = "sharding:query_start"
+
+// Name implements gorm.Plugin
+func (m *queryMetrics) Name() string {
+	return "sharding:query_metrics"
+}
+
+// Initialize implements gorm.Plugin
+func (m *queryMetrics) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("sharding:before_create", m.before),
+		callbacks.Create().After("gorm:create").Register("sharding:after_create", m.after),
+		callbacks.Query().Before("gorm:query").Register("sharding:before_query", m.before),
+		callbacks.Query().After("gorm:query").Register("sharding:after_query", m.after),
+		callbacks.Update().Before("gorm:update").Register("sharding:before_update", m.before),
+		callbacks.Update().After("gorm:update").Register("sharding:after_update", m.after),
+		callbacks.Delete().Before("gorm:delete").Register("sharding:before_delete", m.before),
+		callbacks.Delete().After("gorm:delete").Register("sharding:after_delete", m.after),
+		callbacks.Row().Before("gorm:row").Register("sharding:before_row", m.before),
+		callbacks.Row().After("gorm:row").Register("sharding:after_row", m.after),
+		callbacks.Raw().Before("gorm:raw").Register("sharding:before_raw", m.before),
+		callbacks.Raw().After("gorm:raw").Register("sharding:after_raw", m.after),
+	)
+}
+
+func (m *queryMetrics) before(db *gorm.DB) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+func (m *queryMetrics) after(db *gorm.DB) {
+	value, ok := db.InstanceGet(queryStartKey)
+	if !ok {
+		return
+	}
+	status := "success"
+	if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		status = "error"
+	}
+	metrics.RecordShardQuery(m.service, m.shard, status, time.Since(value.(time.Time)))
+}
+
+// parsePairs parses "key=value" pairs separated by sep. Values may contain
+// "=", as DSNs do.
+func parsePairs(value, sep string) map[string]string {
+	pairs := make(map[string]string)
+	for _, pair := range strings.Split(value, sep) {
+		key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		pairs[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return pairs
+}
+
+func getBoolEnv(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,179 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/metrics"
+	"microservices-platform/pkg/redisclient"
+)
+
+// Cap scopes
+const (
+	CapUser   = "user"
+	CapGlobal = "global"
+	CapCost   = "cost"
+)
+
+// counterTTL keeps a day's counters until the day is over everywhere
+const counterTTL = 48 * time.Hour
+
+// microdollars converts dollars to the integer unit cost is counted in
+const microdollars = 1e6
+
+// CapError is returned for a message that would exceed a daily cap
+type CapError struct {
+	Scope string
+	Limit float64
+}
+
+func (e *CapError) Error() string {
+	return fmt.Sprintf("daily SMS %s cap of %g reached", e.Scope, e.Limit)
+}
+
+// Counter keeps daily counters shared by every instance
+type Counter interface {
+	// Add adds n to a counter and returns its new value
+	Add(ctx context.Context, key string, n int64) (int64, error)
+}
+
+// RedisCounter implements Counter in Redis
+type RedisCounter struct {
+	client redis.UniversalClient
+}
+
+// NewRedisCounter creates a new Redis-backed counter
+func NewRedisCounter(cfg config.RedisConfig) (*RedisCounter, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisCounter{client: client}, nil
+}
+
+// Add increments a counter, setting its expiry when it is created
+func (c *RedisCounter) Add(ctx context.Context, key string, n int64) (int64, error) {
+	value, err := c.client.IncrBy(ctx, key, n).Result()
+	if err != nil {
+		return 0, err
+	}
+	if value == n {
+		c.client.Expire(ctx, key, counterTTL)
+	}
+	return value, nil
+}
+
+// CappedSender checks message length and enforces daily caps before handing
+// messages to a provider, and records what was sent and what it cost.
+// Messages are counted when sent and uncounted if the provider rejects them.
+// If the counters cannot be reached, messages are refused rather than sent
+// uncapped.
+type CappedSender struct {
+	next    Sender
+	counter Counter
+	service string
+	cfg     Config
+	now     func() time.Time
+}
+
+// NewCappedSender wraps a provider with the caps in cfg. service labels the
+// metrics.
+func NewCappedSender(next Sender, counter Counter, service string, cfg Config) *CappedSender {
+	return &CappedSender{
+		next:    next,
+		counter: counter,
+		service: service,
+		cfg:     cfg,
+		now:     time.Now,
+	}
+}
+
+// Provider names the wrapped provider
+func (s *CappedSender) Provider() string {
+	return s.next.Provider()
+}
+
+// reservation is an amount added to a counter, to be taken back if the
+// message is not sent
+type reservation struct {
+	key string
+	n   int64
+}
+
+// Send sends a message if it fits in MaxSegments and within the caps. It
+// returns a *LengthError or *CapError for messages that do not.
+func (s *CappedSender) Send(ctx context.Context, msg Message) (*Receipt, error) {
+	provider := s.next.Provider()
+
+	info, err := CheckLength(msg.Body, s.cfg.MaxSegments)
+	if err != nil {
+		metrics.RecordSMSMessage(s.service, provider, "rejected")
+		return nil, err
+	}
+	estimate := float64(info.Segments) * s.cfg.CostPerSegment
+
+	day := s.now().UTC().Format("20060102")
+	caps := []struct {
+		scope string
+		key   string
+		n     int64
+		limit float64
+		scale float64
+	}{
+		{CapUser, fmt.Sprintf("sms:cap:user:%s:%s", msg.UserID, day), 1, float64(s.cfg.UserDailyCap), 1},
+		{CapGlobal, fmt.Sprintf("sms:cap:global:%s", day), 1, float64(s.cfg.GlobalDailyCap), 1},
+		{CapCost, fmt.Sprintf("sms:cap:cost:%s", day), int64(math.Round(estimate * microdollars)), s.cfg.GlobalDailyCostCap, microdollars},
+	}
+
+	var reserved []reservation
+	for _, c := range caps {
+		if c.limit <= 0 || (c.scope == CapUser && msg.UserID == "") {
+			continue
+		}
+		value, err := s.counter.Add(ctx, c.key, c.n)
+		if err != nil {
+			s.release(ctx, reserved)
+			metrics.RecordSMSMessage(s.service, provider, "failed")
+			return nil, fmt.Errorf("failed to check SMS caps: %v", err)
+		}
+		reserved = append(reserved, reservation{c.key, c.n})
+		if float64(value) > c.limit*c.scale {
+			s.release(ctx, reserved)
+			metrics.RecordSMSMessage(s.service, provider, "capped")
+			log.Printf("SMS %s cap reached: user=%s limit=%g", c.scope, msg.UserID, c.limit)
+			return nil, &CapError{Scope: c.scope, Limit: c.limit}
+		}
+	}
+
+	receipt, err := s.next.Send(ctx, msg)
+	if err != nil {
+		s.release(ctx, reserved)
+		metrics.RecordSMSMessage(s.service, provider, "failed")
+		return nil, err
+	}
+
+	if receipt.Segments == 0 {
+		receipt.Segments = info.Segments
+	}
+	if !receipt.CostKnown {
+		receipt.Cost = float64(receipt.Segments) * s.cfg.CostPerSegment
+	}
+	metrics.RecordSMSMessage(s.service, provider, "sent")
+	metrics.RecordSMSCost(s.service, provider, receipt.Segments, receipt.Cost)
+	return receipt, nil
+}
+
+// release takes back the counts of a message that was not sent
+func (s *CappedSender) release(ctx context.Context, reserved []reservation) {
+	for _, r := range reserved {
+		if _, err := s.counter.Add(ctx, r.key, -r.n); err != nil {
+			log.Printf("Failed to release SMS cap %s: %v", r.key, err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package sms
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode/utf16"
+)
+
+// Encodings an SMS body is sent in
+const (
+	EncodingGSM7 = "GSM-7"
+	EncodingUCS2 = "UCS-2"
+)
+
+// Characters per segment. Multi-segment messages lose room in each segment to
+// the header that joins them back together.
+const (
+	gsm7SingleSegment = 160
+	gsm7MultiSegment  = 153
+	ucs2SingleSegment = 70
+	ucs2MultiSegment  = 67
+)
+
+// gsm7Basic is the GSM 03.38 default alphabet, one septet per character
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension are the characters of the GSM 03.38 extension table, which
+// take an escape septet and so count twice
+const gsm7Extension = "^{}\\[~]|€\f"
+
+// SegmentInfo describes how a body is split into SMS segments
+type SegmentInfo struct {
+	Encoding string
+	// Units is the length in septets (GSM-7) or UTF-16 code units (UCS-2)
+	Units    int
+	Segments int
+}
+
+// Segments works out the encoding of a body and how many segments it is sent
+// as. Bodies with any character outside the GSM-7 alphabet are sent as UCS-2,
+// which fits fewer than half as many characters per segment.
+func Segments(body string) SegmentInfo {
+	septets := 0
+	gsm7 := true
+	for _, r := range body {
+		if strings.ContainsRune(gsm7Basic, r) {
+			septets++
+			continue
+		}
+		if strings.ContainsRune(gsm7Extension, r) {
+			septets += 2
+			continue
+		}
+		gsm7 = false
+		break
+	}
+
+	if gsm7 {
+		return SegmentInfo{Encoding: EncodingGSM7, Units: septets, Segments: segmentCount(septets, gsm7SingleSegment, gsm7MultiSegment)}
+	}
+	units := len(utf16.Encode([]rune(body)))
+	return SegmentInfo{Encoding: EncodingUCS2, Units: units, Segments: segmentCount(units, ucs2SingleSegment, ucs2MultiSegment)}
+}
+
+func segmentCount(units, single, multi int) int {
+	switch {
+	case units == 0:
+		return 0
+	case units <= single:
+		return 1
+	default:
+		return (units + multi - 1) / multi
+	}
+}
+
+// LengthError is returned for a body, or template, that takes more segments
+// than allowed
+type LengthError struct {
+	SegmentInfo
+	MaxSegments int
+}
+
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("SMS body takes %d %s segments (%d units), more than the %d allowed",
+		e.Segments, e.Encoding, e.Units, e.MaxSegments)
+}
+
+// CheckLength returns a *LengthError if body takes more than maxSegments
+// segments. A maxSegments of 0 allows any length.
+func CheckLength(body string, maxSegments int) (SegmentInfo, error) {
+	info := Segments(body)
+	if maxSegments > 0 && info.Segments > maxSegments {
+		return info, &LengthError{SegmentInfo: info, MaxSegments: maxSegments}
+	}
+	return info, nil
+}
+
+// ValidateTemplate checks an SMS template when it is loaded, rather than when
+// a message is first rendered from it. The template is rendered with empty
+// data, so its fixed text alone must fit in maxSegments; each rendered
+// message is checked again before it is sent.
+func ValidateTemplate(name, text string, maxSegments int) error {
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid SMS template %q: %v", name, err)
+	}
+
+	var fixed bytes.Buffer
+	if err := tmpl.Execute(&fixed, map[string]interface{}{}); err != nil {
+		return fmt.Errorf("invalid SMS template %q: %v", name, err)
+	}
+	if _, err := CheckLength(fixed.String(), maxSegments); err != nil {
+		return fmt.Errorf("SMS template %q: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package sms sends text messages through a Twilio-style provider, with daily
+// per-user, global and cost caps so a bug or an abusive caller cannot run up
+// the bill, and checks message length against the segments it is billed as.
+package sms
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Message is a text message to a phone number
+type Message struct {
+	// UserID is the user the message is for, whose daily cap it counts
+	// against
+	UserID string
+	// To is an E.164 phone number
+	To   string
+	Body string
+}
+
+// Receipt is a provider's acknowledgement of a message
+type Receipt struct {
+	ID       string
+	Status   string
+	Segments int
+	// Cost is the provider's price if it reported one (CostKnown), or else
+	// an estimate from the segments
+	Cost      float64
+	CostKnown bool
+}
+
+// Sender sends text messages
+type Sender interface {
+	Send(ctx context.Context, msg Message) (*Receipt, error)
+	// Provider names the sender in metrics
+	Provider() string
+}
+
+// Config holds SMS provider settings and caps. A cap of 0 is unlimited.
+type Config struct {
+	ProviderURL string
+	AccountSID  string
+	AuthToken   string
+	// From is a sender phone number or a messaging service SID
+	From    string
+	Timeout time.Duration
+
+	// MaxSegments is the most segments a message may take
+	MaxSegments int
+	// CostPerSegment estimates the price of a segment, in dollars, until
+	// the provider reports the actual price
+	CostPerSegment float64
+
+	UserDailyCap   int
+	GlobalDailyCap int
+	// GlobalDailyCostCap caps the estimated spend per day, in dollars
+	GlobalDailyCostCap float64
+}
+
+// LoadConfig loads SMS settings from environment variables
+func LoadConfig() Config {
+	return Config{
+		ProviderURL:        getEnv("SMS_PROVIDER_URL", DefaultTwilioURL),
+		AccountSID:         os.Getenv("SMS_ACCOUNT_SID"),
+		AuthToken:          os.Getenv("SMS_AUTH_TOKEN"),
+		From:               os.Getenv("SMS_FROM"),
+		Timeout:            getDurationEnv("SMS_TIMEOUT", 10*time.Second),
+		MaxSegments:        getIntEnv("SMS_MAX_SEGMENTS", 3),
+		CostPerSegment:     getFloatEnv("SMS_COST_PER_SEGMENT", 0.0079),
+		UserDailyCap:       getIntEnv("SMS_USER_DAILY_CAP", 10),
+		GlobalDailyCap:     getIntEnv("SMS_GLOBAL_DAILY_CAP", 10000),
+		GlobalDailyCostCap: getFloatEnv("SMS_GLOBAL_DAILY_COST_CAP", 100),
+	}
+}
+
+// Enabled reports whether a provider account is configured
+func (c Config) Enabled() bool {
+	return c.AccountSID != "" && c.AuthToken != "" && c.From != ""
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
+
+func getIntEnv(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getFloatEnv(key string, fallback float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
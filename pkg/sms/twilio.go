@@ -0,0 +1,117 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTwilioURL is the base URL of the Twilio REST API
+const DefaultTwilioURL = "https://api.twilio.com/2010-04-01"
+
+// TwilioSender sends messages with the Twilio Programmable Messaging API, or
+// any provider that implements the same Messages resource
+type TwilioSender struct {
+	url        string
+	accountSID string
+	authToken  string
+	from       string
+	client     *http.Client
+}
+
+// NewTwilioSender creates a sender for the account. from is a sender phone
+// number, or a messaging service SID ("MG...").
+func NewTwilioSender(baseURL, accountSID, authToken, from string, timeout time.Duration) *TwilioSender {
+	return &TwilioSender{
+		url:        strings.TrimSuffix(baseURL, "/"),
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		client:     &http.Client{Timeout: timeout},
+	}
+}
+
+// Provider names the sender in metrics
+func (s *TwilioSender) Provider() string {
+	return "twilio"
+}
+
+// twilioMessage is the part of a Messages resource that is used
+type twilioMessage struct {
+	SID         string  `json:"sid"`
+	Status      string  `json:"status"`
+	NumSegments string  `json:"num_segments"`
+	Price       *string `json:"price"`
+	PriceUnit   string  `json:"price_unit"`
+}
+
+// twilioError is a Twilio API error response
+type twilioError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Send queues a message with the provider
+func (s *TwilioSender) Send(ctx context.Context, msg Message) (*Receipt, error) {
+	form := url.Values{}
+	form.Set("To", msg.To)
+	form.Set("Body", msg.Body)
+	if strings.HasPrefix(s.from, "MG") {
+		form.Set("MessagingServiceSid", s.from)
+	} else {
+		form.Set("From", s.from)
+	}
+
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", s.url, url.PathEscape(s.accountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(s.accountSID, s.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr twilioError
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+			return nil, fmt.Errorf("SMS provider returned %s: %s (code %d)", resp.Status, apiErr.Message, apiErr.Code)
+		}
+		return nil, fmt.Errorf("SMS provider returned %s", resp.Status)
+	}
+
+	var message twilioMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, fmt.Errorf("invalid SMS provider response: %v", err)
+	}
+
+	receipt := &Receipt{ID: message.SID, Status: message.Status}
+	if segments, err := strconv.Atoi(message.NumSegments); err == nil {
+		receipt.Segments = segments
+	}
+	// The price is usually only known once the message has been delivered,
+	// and is reported as a negative amount
+	if message.Price != nil {
+		if price, err := strconv.ParseFloat(*message.Price, 64); err == nil {
+			receipt.Cost = math.Abs(price)
+			receipt.CostKnown = true
+		}
+	}
+	return receipt, nil
+}
@@ -0,0 +1,273 @@
+package startup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// State is a service's readiness state
+type State string
+
+// Readiness states
+const (
+	StateStarting State = "starting"
+	StateReady    State = "ready"
+	StateFailed   State = "failed"
+	// StateUnavailable means the service started but a required dependency
+	// has since become unavailable
+	StateUnavailable State = "unavailable"
+	// StateDegraded means the service is serving without an optional
+	// dependency, e.g. with caching disabled and events buffered locally
+	StateDegraded State = "degraded"
+	// StateDraining means the service is shutting down and finishing
+	// in-flight requests
+	StateDraining State = "draining"
+)
+
+// Dependency statuses reported by the readiness endpoint
+const (
+	DependencyPending     = "pending"
+	DependencyUp          = "up"
+	DependencyUnavailable = "unavailable"
+)
+
+// Config holds startup retry configuration
+type Config struct {
+	InitialBackoff   time.Duration
+	MaxBackoff       time.Duration
+	Timeout          time.Duration
+	OptionalAttempts int
+}
+
+// LoadConfig loads startup configuration from environment variables
+func LoadConfig() Config {
+	return Config{
+		InitialBackoff:   getDurationEnv("STARTUP_INITIAL_BACKOFF", time.Second),
+		MaxBackoff:       getDurationEnv("STARTUP_MAX_BACKOFF", 30*time.Second),
+		Timeout:          getDurationEnv("STARTUP_TIMEOUT", 5*time.Minute),
+		OptionalAttempts: getIntEnv("STARTUP_OPTIONAL_ATTEMPTS", 3),
+	}
+}
+
+// dependency is an external system a service connects to at startup
+type dependency struct {
+	name     string
+	required bool
+	connect  func(ctx context.Context) error
+}
+
+// Manager connects a service's dependencies in order, retrying with
+// exponential backoff, and reports readiness while it does so. Services
+// should only start serving once Run has returned successfully.
+type Manager struct {
+	cfg  Config
+	deps []dependency
+
+	mu       sync.RWMutex
+	state    State
+	statuses map[string]string
+}
+
+// NewManager creates a new startup manager in the starting state
+func NewManager(cfg Config) *Manager {
+	return &Manager{
+		cfg:      cfg,
+		state:    StateStarting,
+		statuses: make(map[string]string),
+	}
+}
+
+// Require adds a dependency the service cannot run without. Dependencies are
+// connected in the order they are added.
+func (m *Manager) Require(name string, connect func(ctx context.Context) error) {
+	m.add(dependency{name: name, required: true, connect: connect})
+}
+
+// Optional adds a dependency the service can run without. It is retried
+// OptionalAttempts times and then skipped so the service starts degraded.
+func (m *Manager) Optional(name string, connect func(ctx context.Context) error) {
+	m.add(dependency{name: name, connect: connect})
+}
+
+func (m *Manager) add(dep dependency) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deps = append(m.deps, dep)
+	m.statuses[dep.name] = DependencyPending
+}
+
+// Run connects every dependency in order. It returns an error if a required
+// dependency is still unavailable after the startup timeout.
+func (m *Manager) Run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+	defer cancel()
+
+	for _, dep := range m.deps {
+		err := m.connect(ctx, dep)
+		if err == nil {
+			m.setStatus(dep.name, DependencyUp)
+			log.Printf("Dependency %s is up", dep.name)
+			continue
+		}
+
+		m.setStatus(dep.name, DependencyUnavailable)
+		if dep.required {
+			m.setState(StateFailed)
+			return fmt.Errorf("required dependency %s unavailable: %v", dep.name, err)
+		}
+		log.Printf("Optional dependency %s unavailable, continuing without it: %v", dep.name, err)
+	}
+
+	return nil
+}
+
+// connect retries a dependency's connect function with exponential backoff
+// and jitter until it succeeds, the context ends or, for optional
+// dependencies, the attempts run out
+func (m *Manager) connect(ctx context.Context, dep dependency) error {
+	backoff := m.cfg.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := dep.connect(ctx)
+		if err == nil {
+			return nil
+		}
+		if !dep.required && attempt >= m.cfg.OptionalAttempts {
+			return err
+		}
+
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		log.Printf("Dependency %s not ready (attempt %d), retrying in %v: %v", dep.name, attempt, wait, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > m.cfg.MaxBackoff {
+			backoff = m.cfg.MaxBackoff
+		}
+	}
+}
+
+// Report updates a dependency's status after startup, e.g. when a connection
+// monitor sees it break or recover. The service reports not ready while a
+// required dependency is unavailable.
+func (m *Manager) Report(name string, err error) {
+	if err != nil {
+		m.setStatus(name, DependencyUnavailable)
+		return
+	}
+	m.setStatus(name, DependencyUp)
+}
+
+// MarkReady marks the service ready to receive traffic. Call it once the
+// server is listening.
+func (m *Manager) MarkReady() {
+	m.setState(StateReady)
+}
+
+// MarkDraining marks the service not ready because it is shutting down, so
+// traffic moves elsewhere before the server stops
+func (m *Manager) MarkDraining() {
+	m.setState(StateDraining)
+}
+
+// State returns the current readiness state
+func (m *Manager) State() State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state
+}
+
+func (m *Manager) setState(state State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = state
+}
+
+func (m *Manager) setStatus(name, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.statuses[name] = status
+}
+
+// Handler returns the liveness and readiness endpoints. /health/live succeeds
+// as soon as the process is up; /health/ready returns 503 until the service is
+// ready, along with the status of each dependency. A service missing only
+// optional dependencies reports "degraded" but stays ready.
+func (m *Manager) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/health/live", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "alive"})
+	})
+
+	mux.HandleFunc("/health/ready", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.RLock()
+		state := m.state
+		dependencies := make(map[string]string, len(m.statuses))
+		for name, status := range m.statuses {
+			dependencies[name] = status
+		}
+		if state == StateReady {
+			for _, dep := range m.deps {
+				if m.statuses[dep.name] == DependencyUp {
+					continue
+				}
+				if dep.required {
+					state = StateUnavailable
+					break
+				}
+				state = StateDegraded
+			}
+		}
+		m.mu.RUnlock()
+
+		code := http.StatusOK
+		if state != StateReady && state != StateDegraded {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, map[string]interface{}{
+			"status":       state,
+			"dependencies": dependencies,
+		})
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+func getIntEnv(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
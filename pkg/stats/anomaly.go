@@ -0,0 +1,190 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/incidents"
+	"microservices-platform/pkg/metrics"
+)
+
+// Business anomaly kinds
+const (
+	AnomalyOrdersDrop          = "orders_drop"
+	AnomalyPaymentFailureSpike = "payment_failure_spike"
+)
+
+// anomalyService names the business in anomaly reports, which the ops
+// channels show as the affected service
+const anomalyService = "business"
+
+// AnomalySettings configures the business anomaly detectors.
+//
+// Orders drop when an hour has OrderDropRatio fewer orders than the same
+// hour a week before, which had at least MinBaselineOrders. Payment failures
+// spike when at least MinPayments payments in an hour fail at a rate of at
+// least PaymentFailureRate and PaymentFailureFactor times the rate over the
+// day before. After an alert, alerts of its kind are suppressed for
+// SuppressionWindow.
+type AnomalySettings struct {
+	OrderDropRatio       float64
+	MinBaselineOrders    int64
+	PaymentFailureRate   float64
+	PaymentFailureFactor float64
+	MinPayments          int64
+	SuppressionWindow    time.Duration
+}
+
+// AnomalyDetector checks each hour's snapshots for business anomalies and
+// publishes them as platform.business_anomaly events for the ops channels
+type AnomalyDetector struct {
+	source   string
+	store    *RollupStore
+	counters *Counters
+	bus      events.EventBus
+	settings AnomalySettings
+}
+
+// NewAnomalyDetector creates a detector publishing as source on bus. The
+// counters hold the suppression windows, shared by every instance.
+func NewAnomalyDetector(source string, store *RollupStore, counters *Counters, bus events.EventBus, settings AnomalySettings) *AnomalyDetector {
+	return &AnomalyDetector{
+		source:   source,
+		store:    store,
+		counters: counters,
+		bus:      bus,
+		settings: settings,
+	}
+}
+
+// anomaly is an anomaly found in an hour
+type anomaly struct {
+	kind     string
+	summary  string
+	details  map[string]interface{}
+	hour     time.Time
+	severity string
+}
+
+// Check runs the detectors on the hour starting at hour
+func (d *AnomalyDetector) Check(ctx context.Context, hour time.Time) error {
+	current, err := d.store.Totals(ctx, hour, hour.Add(time.Hour))
+	if err != nil {
+		return err
+	}
+
+	var found []anomaly
+	lastWeek := hour.AddDate(0, 0, -7)
+	baseline, err := d.store.Totals(ctx, lastWeek, lastWeek.Add(time.Hour))
+	if err != nil {
+		return err
+	}
+	if a, ok := d.ordersDrop(hour, current, baseline); ok {
+		found = append(found, a)
+	}
+
+	previousDay, err := d.store.Totals(ctx, hour.Add(-24*time.Hour), hour)
+	if err != nil {
+		return err
+	}
+	if a, ok := d.paymentFailureSpike(hour, current, previousDay); ok {
+		found = append(found, a)
+	}
+
+	for _, a := range found {
+		d.alert(ctx, a)
+	}
+	return nil
+}
+
+// ordersDrop compares an hour's orders with the same hour a week before
+func (d *AnomalyDetector) ordersDrop(hour time.Time, current, lastWeek map[string]int64) (anomaly, bool) {
+	orders, baseline := current[MetricOrders], lastWeek[MetricOrders]
+	if d.settings.OrderDropRatio <= 0 || baseline < d.settings.MinBaselineOrders {
+		return anomaly{}, false
+	}
+	if float64(orders) > float64(baseline)*(1-d.settings.OrderDropRatio) {
+		return anomaly{}, false
+	}
+
+	drop := 1 - float64(orders)/float64(baseline)
+	return anomaly{
+		kind:     AnomalyOrdersDrop,
+		summary:  fmt.Sprintf("Orders dropped %.0f%% against the same hour last week (%d vs %d)", drop*100, orders, baseline),
+		hour:     hour,
+		severity: incidents.SeverityWarning,
+		details: map[string]interface{}{
+			"hour":     hour.Format(time.RFC3339),
+			"orders":   orders,
+			"baseline": baseline,
+			"drop":     drop,
+		},
+	}, true
+}
+
+// paymentFailureSpike compares an hour's payment failure rate with the rate
+// over the day before
+func (d *AnomalyDetector) paymentFailureSpike(hour time.Time, current, previousDay map[string]int64) (anomaly, bool) {
+	failed := current[MetricPaymentsFailed]
+	total := current[MetricPaymentsProcessed] + failed
+	if d.settings.PaymentFailureRate <= 0 || total == 0 || total < d.settings.MinPayments {
+		return anomaly{}, false
+	}
+	rate := float64(failed) / float64(total)
+
+	var baseline float64
+	if baselineTotal := previousDay[MetricPaymentsProcessed] + previousDay[MetricPaymentsFailed]; baselineTotal > 0 {
+		baseline = float64(previousDay[MetricPaymentsFailed]) / float64(baselineTotal)
+	}
+	if rate < d.settings.PaymentFailureRate || rate < baseline*d.settings.PaymentFailureFactor {
+		return anomaly{}, false
+	}
+
+	return anomaly{
+		kind:     AnomalyPaymentFailureSpike,
+		summary:  fmt.Sprintf("%.0f%% of payments failed, against %.0f%% over the day before (%d of %d)", rate*100, baseline*100, failed, total),
+		hour:     hour,
+		severity: incidents.SeverityCritical,
+		details: map[string]interface{}{
+			"hour":          hour.Format(time.RFC3339),
+			"failed":        failed,
+			"payments":      total,
+			"failure_rate":  rate,
+			"baseline_rate": baseline,
+		},
+	}, true
+}
+
+// alert publishes an anomaly unless its kind is suppressed. If the
+// suppression window cannot be checked the anomaly is published anyway, since
+// a duplicate alert beats a missed one.
+func (d *AnomalyDetector) alert(ctx context.Context, a anomaly) {
+	if claimed, err := d.counters.claimAlert(ctx, a.kind, d.settings.SuppressionWindow); err != nil {
+		log.Printf("Failed to check suppression of %s anomaly: %v", a.kind, err)
+	} else if !claimed {
+		return
+	}
+
+	log.Printf("Business anomaly %s: %s", a.kind, a.summary)
+	metrics.RecordBusinessAnomaly(a.kind)
+	report := &events.IncidentReport{
+		ID:        fmt.Sprintf("anomaly_%s_%s", a.kind, a.hour.UTC().Format("2006010215")),
+		Status:    events.IncidentFiring,
+		Service:   anomalyService,
+		Kind:      a.kind,
+		Severity:  a.severity,
+		Summary:   a.summary,
+		Signals:   []string{a.kind},
+		Details:   a.details,
+		StartedAt: a.hour,
+	}
+	if d.bus == nil {
+		return
+	}
+	if err := d.bus.Publish(ctx, events.NewBusinessAnomalyEvent(d.source, report)); err != nil {
+		log.Printf("Failed to publish %s anomaly: %v", a.kind, err)
+	}
+}
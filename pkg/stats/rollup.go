@@ -0,0 +1,273 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// Trend intervals
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+)
+
+// Trend metrics. Payment success rate is derived from the processed and
+// failed payment counts, so it is correct over any interval.
+const (
+	TrendOrders             = "orders"
+	TrendRevenue            = "revenue"
+	TrendPayments           = "payments"
+	TrendPaymentSuccessRate = "payment_success_rate"
+)
+
+// Snapshot is an hourly metric's value over one hour
+type Snapshot struct {
+	Metric    string    `gorm:"primaryKey"`
+	Hour      time.Time `gorm:"primaryKey"`
+	Value     int64     `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName keeps the snapshots apart in a shared database
+func (Snapshot) TableName() string { return "metric_snapshots" }
+
+// Point is a trend metric's value over one interval. Value is nil when the
+// metric is undefined, e.g. a success rate without payments.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value *float64  `json:"value"`
+}
+
+// RollupStore keeps hourly snapshots in Postgres, beyond the retention of
+// the counters and of Prometheus
+type RollupStore struct {
+	db *gorm.DB
+}
+
+// OpenRollupStore connects to the snapshot database at databaseURL
+func OpenRollupStore(databaseURL string) (*RollupStore, error) {
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Warn),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RollupStore{db: db}, nil
+}
+
+// Migrate creates the snapshot table
+func (s *RollupStore) Migrate() error {
+	return s.db.AutoMigrate(&Snapshot{})
+}
+
+// Save stores snapshots, replacing those of the same metric and hour
+func (s *RollupStore) Save(ctx context.Context, snapshots []Snapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "metric"}, {Name: "hour"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&snapshots).Error
+}
+
+// LatestHour returns the most recent hour snapshotted, or the zero time if
+// there is none
+func (s *RollupStore) LatestHour(ctx context.Context) (time.Time, error) {
+	var latest sql.NullTime
+	err := s.db.WithContext(ctx).Model(&Snapshot{}).Select("MAX(hour)").Row().Scan(&latest)
+	if err != nil || !latest.Valid {
+		return time.Time{}, err
+	}
+	return latest.Time.UTC(), nil
+}
+
+// Trend returns a trend metric per interval from from up to to, oldest
+// first. Intervals without snapshots are left out.
+func (s *RollupStore) Trend(ctx context.Context, metric, interval string, from, to time.Time) ([]Point, error) {
+	var metrics []string
+	switch metric {
+	case TrendOrders:
+		metrics = []string{MetricOrders}
+	case TrendRevenue:
+		metrics = []string{MetricRevenueCents}
+	case TrendPayments, TrendPaymentSuccessRate:
+		metrics = []string{MetricPaymentsProcessed, MetricPaymentsFailed}
+	default:
+		return nil, fmt.Errorf("unknown metric %q", metric)
+	}
+	if interval != IntervalHour && interval != IntervalDay {
+		return nil, fmt.Errorf("unknown interval %q", interval)
+	}
+
+	var rows []struct {
+		Bucket time.Time
+		Metric string
+		Value  int64
+	}
+	err := s.db.WithContext(ctx).Model(&Snapshot{}).
+		Select("date_trunc(?, hour AT TIME ZONE 'UTC') AS bucket, metric, SUM(value) AS value", interval).
+		Where("metric IN ? AND hour >= ? AND hour < ?", metrics, from.UTC(), to.UTC()).
+		Group("bucket, metric").
+		Order("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var points []Point
+	values := make(map[string]int64)
+	flush := func(bucket time.Time) {
+		points = append(points, Point{Time: bucket.UTC(), Value: trendValue(metric, values)})
+		values = make(map[string]int64)
+	}
+	for i, row := range rows {
+		if i > 0 && !row.Bucket.Equal(rows[i-1].Bucket) {
+			flush(rows[i-1].Bucket)
+		}
+		values[row.Metric] = row.Value
+	}
+	if len(rows) > 0 {
+		flush(rows[len(rows)-1].Bucket)
+	}
+	return points, nil
+}
+
+// Totals sums each hourly metric over the hours from from up to to
+func (s *RollupStore) Totals(ctx context.Context, from, to time.Time) (map[string]int64, error) {
+	var rows []struct {
+		Metric string
+		Value  int64
+	}
+	err := s.db.WithContext(ctx).Model(&Snapshot{}).
+		Select("metric, SUM(value) AS value").
+		Where("hour >= ? AND hour < ?", from.UTC(), to.UTC()).
+		Group("metric").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		totals[row.Metric] = row.Value
+	}
+	return totals, nil
+}
+
+// trendValue derives a trend metric from the sums of its hourly metrics
+func trendValue(metric string, values map[string]int64) *float64 {
+	var value float64
+	switch metric {
+	case TrendOrders:
+		value = float64(values[MetricOrders])
+	case TrendRevenue:
+		value = float64(values[MetricRevenueCents]) / 100
+	case TrendPayments:
+		value = float64(values[MetricPaymentsProcessed] + values[MetricPaymentsFailed])
+	case TrendPaymentSuccessRate:
+		total := values[MetricPaymentsProcessed] + values[MetricPaymentsFailed]
+		if total == 0 {
+			return nil
+		}
+		value = float64(values[MetricPaymentsProcessed]) / float64(total)
+	}
+	return &value
+}
+
+// RollupSettings configures the rollup job. Each run snapshots every hour
+// that has ended, from Lookback before the latest hour snapshotted, so
+// events arriving late are picked up on the next runs.
+type RollupSettings struct {
+	Interval time.Duration
+	Lookback time.Duration
+}
+
+// Roller snapshots the hourly counters into the rollup store. Any number of
+// instances can run it: snapshots of the same hour replace each other.
+type Roller struct {
+	counters *Counters
+	store    *RollupStore
+	settings RollupSettings
+	detector *AnomalyDetector
+	now      func() time.Time
+}
+
+// NewRoller creates a rollup job snapshotting counters into store
+func NewRoller(counters *Counters, store *RollupStore, settings RollupSettings) *Roller {
+	return &Roller{
+		counters: counters,
+		store:    store,
+		settings: settings,
+		now:      time.Now,
+	}
+}
+
+// DetectAnomalies makes each run check the last hour for business anomalies
+func (r *Roller) DetectAnomalies(detector *AnomalyDetector) {
+	r.detector = detector
+}
+
+// Start runs the rollup job every interval until ctx is cancelled
+func (r *Roller) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.settings.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Run(ctx); err != nil {
+			log.Printf("Failed to roll up business metrics: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run snapshots the hours ended since the last run. The first run only
+// snapshots the last hour, rather than the hours before counting started.
+func (r *Roller) Run(ctx context.Context) error {
+	now := r.now().UTC()
+	last := now.Truncate(time.Hour).Add(-time.Hour)
+
+	latest, err := r.store.LatestHour(ctx)
+	if err != nil {
+		return err
+	}
+	first := last
+	if !latest.IsZero() {
+		first = latest.Add(-r.settings.Lookback).Truncate(time.Hour)
+	}
+	// Older counters have expired
+	if oldest := now.Add(-HourlyRetention).Truncate(time.Hour).Add(time.Hour); first.Before(oldest) {
+		first = oldest
+	}
+
+	var snapshots []Snapshot
+	for hour := first; !hour.After(last); hour = hour.Add(time.Hour) {
+		values, err := r.counters.Hour(ctx, hour)
+		if err != nil {
+			return err
+		}
+		for _, metric := range HourlyMetrics {
+			snapshots = append(snapshots, Snapshot{Metric: metric, Hour: hour, Value: values[metric]})
+		}
+	}
+	if err := r.store.Save(ctx, snapshots); err != nil {
+		return err
+	}
+
+	if r.detector != nil {
+		return r.detector.Check(ctx, last)
+	}
+	return nil
+}
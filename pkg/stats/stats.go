@@ -0,0 +1,250 @@
+// Package stats keeps precomputed counters for the admin dashboard in Redis.
+// Event consumers update them as orders and users come in, so the dashboard
+// reads a few keys instead of counting rows in the services' databases.
+package stats
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"microservices-platform/pkg/config"
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/redisclient"
+)
+
+// Retention is how long daily counters are kept
+const Retention = 90 * 24 * time.Hour
+
+// HourlyRetention is how long hourly counters are kept for rollups to
+// snapshot, and so how long rollups can catch up after an outage
+const HourlyRetention = 7 * 24 * time.Hour
+
+// Layouts of the UTC day and hour of a counter
+const (
+	dayLayout  = "2006-01-02"
+	hourLayout = "2006-01-02T15"
+)
+
+// Hourly metrics, snapshotted by rollups
+const (
+	MetricOrders            = "orders"
+	MetricRevenueCents      = "revenue_cents"
+	MetricPaymentsProcessed = "payments_processed"
+	MetricPaymentsFailed    = "payments_failed"
+)
+
+// HourlyMetrics lists the metrics counted per hour
+var HourlyMetrics = []string{MetricOrders, MetricRevenueCents, MetricPaymentsProcessed, MetricPaymentsFailed}
+
+// Day holds the counters of one UTC day. ActiveUsers is an estimate, within
+// about 1%, of the distinct users who signed up, ordered or paid that day.
+type Day struct {
+	Date        string  `json:"date"`
+	Orders      int64   `json:"orders"`
+	Revenue     float64 `json:"revenue"`
+	ActiveUsers int64   `json:"active_users"`
+}
+
+// Counters keeps the daily counters in Redis, shared by every instance
+type Counters struct {
+	client redis.UniversalClient
+}
+
+// NewRedisCounters creates counters stored in Redis
+func NewRedisCounters(cfg config.RedisConfig) (*Counters, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Counters{client: client}, nil
+}
+
+// Keys of a day's counters. Revenue is kept in cents so increments add up
+// exactly, and active users in a HyperLogLog.
+func ordersKey(day string) string      { return "stats:" + day + ":orders" }
+func revenueKey(day string) string     { return "stats:" + day + ":revenue_cents" }
+func activeUsersKey(day string) string { return "stats:" + day + ":active_users" }
+
+// hourKey is the key of an hourly metric's counter
+func hourKey(hour time.Time, metric string) string {
+	return "stats:hour:" + hour.UTC().Format(hourLayout) + ":" + metric
+}
+
+// RecordOrder counts an order of amount placed by userID at the given time
+func (c *Counters) RecordOrder(ctx context.Context, at time.Time, userID string, amount float64) error {
+	day := at.UTC().Format(dayLayout)
+	pipe := c.client.TxPipeline()
+	pipe.Incr(ctx, ordersKey(day))
+	pipe.IncrBy(ctx, revenueKey(day), int64(math.Round(amount*100)))
+	pipe.Expire(ctx, ordersKey(day), Retention)
+	pipe.Expire(ctx, revenueKey(day), Retention)
+	incrHour(ctx, pipe, at, MetricOrders, 1)
+	incrHour(ctx, pipe, at, MetricRevenueCents, int64(math.Round(amount*100)))
+	if userID != "" {
+		pipe.PFAdd(ctx, activeUsersKey(day), userID)
+		pipe.Expire(ctx, activeUsersKey(day), Retention)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RecordPayment counts a processed or failed payment of userID at the given
+// time. Users count as active once their payment went through.
+func (c *Counters) RecordPayment(ctx context.Context, at time.Time, userID string, processed bool) error {
+	pipe := c.client.TxPipeline()
+	if processed {
+		incrHour(ctx, pipe, at, MetricPaymentsProcessed, 1)
+		if userID != "" {
+			day := at.UTC().Format(dayLayout)
+			pipe.PFAdd(ctx, activeUsersKey(day), userID)
+			pipe.Expire(ctx, activeUsersKey(day), Retention)
+		}
+	} else {
+		incrHour(ctx, pipe, at, MetricPaymentsFailed, 1)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// incrHour adds n to an hourly metric's counter in pipe
+func incrHour(ctx context.Context, pipe redis.Pipeliner, at time.Time, metric string, n int64) {
+	key := hourKey(at, metric)
+	pipe.IncrBy(ctx, key, n)
+	pipe.Expire(ctx, key, HourlyRetention)
+}
+
+// RecordActiveUser counts userID as active at the given time
+func (c *Counters) RecordActiveUser(ctx context.Context, at time.Time, userID string) error {
+	day := at.UTC().Format(dayLayout)
+	pipe := c.client.TxPipeline()
+	pipe.PFAdd(ctx, activeUsersKey(day), userID)
+	pipe.Expire(ctx, activeUsersKey(day), Retention)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Days returns the counters of the given number of days up to and including
+// the day of until, most recent first
+func (c *Counters) Days(ctx context.Context, until time.Time, days int) ([]Day, error) {
+	until = until.UTC()
+	pipe := c.client.Pipeline()
+	orders := make([]*redis.StringCmd, days)
+	revenue := make([]*redis.StringCmd, days)
+	activeUsers := make([]*redis.IntCmd, days)
+	dates := make([]string, days)
+	for i := range dates {
+		dates[i] = until.AddDate(0, 0, -i).Format(dayLayout)
+		orders[i] = pipe.Get(ctx, ordersKey(dates[i]))
+		revenue[i] = pipe.Get(ctx, revenueKey(dates[i]))
+		activeUsers[i] = pipe.PFCount(ctx, activeUsersKey(dates[i]))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	result := make([]Day, days)
+	for i, date := range dates {
+		orderCount, err := intValue(orders[i])
+		if err != nil {
+			return nil, err
+		}
+		cents, err := intValue(revenue[i])
+		if err != nil {
+			return nil, err
+		}
+		if err := activeUsers[i].Err(); err != nil {
+			return nil, err
+		}
+		result[i] = Day{
+			Date:        date,
+			Orders:      orderCount,
+			Revenue:     float64(cents) / 100,
+			ActiveUsers: activeUsers[i].Val(),
+		}
+	}
+	return result, nil
+}
+
+// claimAlert reports whether an alert of kind may fire, and if so holds
+// further alerts of the kind off for window across all instances
+func (c *Counters) claimAlert(ctx context.Context, kind string, window time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, "stats:anomaly:"+kind, time.Now().UTC().Format(time.RFC3339), window).Result()
+}
+
+// Hour returns the hourly metrics of the hour starting at hour
+func (c *Counters) Hour(ctx context.Context, hour time.Time) (map[string]int64, error) {
+	pipe := c.client.Pipeline()
+	cmds := make(map[string]*redis.StringCmd, len(HourlyMetrics))
+	for _, metric := range HourlyMetrics {
+		cmds[metric] = pipe.Get(ctx, hourKey(hour, metric))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	values := make(map[string]int64, len(cmds))
+	for metric, cmd := range cmds {
+		value, err := intValue(cmd)
+		if err != nil {
+			return nil, err
+		}
+		values[metric] = value
+	}
+	return values, nil
+}
+
+// intValue reads a counter, which is zero until first incremented
+func intValue(cmd *redis.StringCmd) (int64, error) {
+	value, err := cmd.Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return value, err
+}
+
+// HandleOrderCreated counts an order.created event's order, its total
+// amount and its user
+func HandleOrderCreated(counters *Counters) events.EventHandler {
+	return func(ctx context.Context, event *events.Event) error {
+		amount, ok := event.Data["total_amount"].(float64)
+		if !ok {
+			return fmt.Errorf("%s event %s has no total amount", event.Type, event.ID)
+		}
+		userID, _ := event.Data["user_id"].(string)
+		return counters.RecordOrder(ctx, eventTime(event), userID, amount)
+	}
+}
+
+// HandlePayment counts a payment.processed or payment.failed event's
+// payment
+func HandlePayment(counters *Counters) events.EventHandler {
+	return func(ctx context.Context, event *events.Event) error {
+		userID, _ := event.Data["user_id"].(string)
+		return counters.RecordPayment(ctx, eventTime(event), userID, event.Type == events.PaymentProcessed)
+	}
+}
+
+// HandleUserActivity counts the user_id of an event as active. Users created
+// by a bulk import have not done anything yet and are not counted.
+func HandleUserActivity(counters *Counters) events.EventHandler {
+	return func(ctx context.Context, event *events.Event) error {
+		userID, _ := event.Data["user_id"].(string)
+		if imported, _ := event.Data["imported"].(bool); userID == "" || imported {
+			return nil
+		}
+		return counters.RecordActiveUser(ctx, eventTime(event), userID)
+	}
+}
+
+// eventTime returns when an event happened, counting events without a
+// timestamp when they arrive
+func eventTime(event *events.Event) time.Time {
+	if event.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return event.Timestamp
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // register PNG decoder
+)
+
+// MaxImageUploadBytes is the largest source image accepted for resizing
+const MaxImageUploadBytes = 5 << 20 // 5MB
+
+// MaxImagePixels is the largest source image accepted, in pixels. A small,
+// highly compressed file can declare huge dimensions, so they are checked
+// before the image is decoded into memory.
+const MaxImagePixels = 4096 * 4096
+
+// ImageSize describes a standard square rendition of an uploaded image
+type ImageSize struct {
+	Name   string
+	Pixels int
+}
+
+// Standard image sizes generated for every upload
+var (
+	ImageSizeSmall  = ImageSize{Name: "small", Pixels: 64}
+	ImageSizeMedium = ImageSize{Name: "medium", Pixels: 256}
+	ImageSizeLarge  = ImageSize{Name: "large", Pixels: 512}
+
+	StandardImageSizes = []ImageSize{ImageSizeSmall, ImageSizeMedium, ImageSizeLarge}
+)
+
+// allowedImageTypes maps accepted upload content types to the format the
+// image must decode as
+var allowedImageTypes = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+}
+
+// DecodeImage validates and decodes an uploaded image. The image must be of
+// the declared content type and within MaxImagePixels.
+func DecodeImage(data []byte, contentType string) (image.Image, error) {
+	if len(data) == 0 {
+		return nil, errors.New("image is empty")
+	}
+	if len(data) > MaxImageUploadBytes {
+		return nil, fmt.Errorf("image exceeds maximum size of %d bytes", MaxImageUploadBytes)
+	}
+	format, ok := allowedImageTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported image type: %s", contentType)
+	}
+
+	config, decoded, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	if decoded != format {
+		return nil, fmt.Errorf("image is %s, not %s", decoded, contentType)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > MaxImagePixels/config.Height {
+		return nil, fmt.Errorf("image of %dx%d exceeds maximum of %d pixels", config.Width, config.Height, MaxImagePixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	return img, nil
+}
+
+// ResizeSquare center-crops an image to a square and scales it to the given
+// size, returning the result encoded as JPEG
+func ResizeSquare(src image.Image, size ImageSize) ([]byte, error) {
+	bounds := src.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	crop := image.Rect(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+		bounds.Min.X+(bounds.Dx()-side)/2+side,
+		bounds.Min.Y+(bounds.Dy()-side)/2+side,
+	)
+
+	dst := scaleAreaAverage(src, crop, size.Pixels)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// scaleAreaAverage scales the src rectangle to an n x n image, averaging all
+// source pixels covered by each destination pixel. Upscaling falls back to
+// nearest-neighbour since each destination pixel covers under one source pixel.
+func scaleAreaAverage(src image.Image, rect image.Rectangle, n int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, n, n))
+	side := rect.Dx()
+
+	for dy := 0; dy < n; dy++ {
+		y0 := rect.Min.Y + dy*side/n
+		y1 := rect.Min.Y + (dy+1)*side/n
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for dx := 0; dx < n; dx++ {
+			x0 := rect.Min.X + dx*side/n
+			x1 := rect.Min.X + (dx+1)*side/n
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, count uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					pr, pg, pb, pa := src.At(x, y).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+					count++
+				}
+			}
+
+			dst.SetRGBA64(dx, dy, color.RGBA64{
+				R: uint16(r / count),
+				G: uint16(g / count),
+				B: uint16(b / count),
+				A: uint16(a / count),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,298 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ObjectStore defines operations on an object storage backend. It is shared by
+// services that store user-facing media such as avatars and product images.
+type ObjectStore interface {
+	Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) (string, error)
+	// Get downloads an object
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+	SignedURL(key string, expiry time.Duration) (string, error)
+}
+
+// Config holds object storage configuration
+type Config struct {
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Region    string
+	UseSSL    bool
+	// PublicURL is the base URL clients use to fetch objects (e.g. a CDN in
+	// front of the bucket). Defaults to the endpoint/bucket path.
+	PublicURL string
+}
+
+// S3Store implements ObjectStore for S3-compatible backends such as MinIO
+// using path-style requests signed with AWS Signature Version 4
+type S3Store struct {
+	cfg       Config
+	baseURL   string
+	publicURL string
+	client    *http.Client
+}
+
+// NewS3Store creates a new S3-compatible object store and ensures the bucket exists
+func NewS3Store(ctx context.Context, cfg Config) (*S3Store, error) {
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	scheme := "http"
+	if cfg.UseSSL {
+		scheme = "https"
+	}
+	baseURL := fmt.Sprintf("%s://%s/%s", scheme, cfg.Endpoint, cfg.Bucket)
+
+	publicURL := cfg.PublicURL
+	if publicURL == "" {
+		publicURL = baseURL
+	}
+
+	s := &S3Store{
+		cfg:       cfg,
+		baseURL:   baseURL,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+
+	// Test connection and create the bucket on first use
+	resp, err := s.do(ctx, http.MethodHead, "", nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to object storage: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp, err = s.do(ctx, http.MethodPut, "", nil, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create bucket %s: %v", cfg.Bucket, err)
+		}
+		resp.Body.Close()
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("object storage returned status %d for bucket %s", resp.StatusCode, cfg.Bucket)
+	}
+
+	return s, nil
+}
+
+// Put uploads an object and returns its public URL
+func (s *S3Store) Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(reader, size))
+	if err != nil {
+		return "", fmt.Errorf("failed to read object %s: %v", key, err)
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload object %s: %v", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to upload object %s: status %d: %s", key, resp.StatusCode, string(body))
+	}
+
+	return s.URL(key), nil
+}
+
+// Get downloads an object
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download object %s: %v", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to download object %s: status %d", key, resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download object %s: %v", key, err)
+	}
+	return data, nil
+}
+
+// Delete removes an object
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to delete object %s: %v", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete object %s: status %d", key, resp.StatusCode)
+	}
+	return nil
+}
+
+// URL returns the public URL for an object key
+func (s *S3Store) URL(key string) string {
+	return s.publicURL + "/" + strings.TrimPrefix(key, "/")
+}
+
+// SignedURL returns a time-limited download link for a private object using
+// a SigV4 presigned query string
+func (s *S3Store) SignedURL(key string, expiry time.Duration) (string, error) {
+	if expiry <= 0 || expiry > 7*24*time.Hour {
+		return "", fmt.Errorf("invalid signed URL expiry: %v", expiry)
+	}
+
+	target, err := url.Parse(s.baseURL + "/" + strings.TrimPrefix(key, "/"))
+	if err != nil {
+		return "", err
+	}
+
+	return s.presign(target, expiry, time.Now().UTC()), nil
+}
+
+// presign returns target as a GET link signed at now and valid for expiry
+func (s *S3Store) presign(target *url.URL, expiry time.Duration, now time.Time) string {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.cfg.Region)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.cfg.AccessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", fmt.Sprintf("%d", int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	canonicalQuery := strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		target.EscapedPath(),
+		canonicalQuery,
+		"host:" + target.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signed := *target
+	signed.RawQuery = canonicalQuery + "&X-Amz-Signature=" + hex.EncodeToString(hmacSHA256(s.signingKey(date), stringToSign))
+	return signed.String()
+}
+
+// do sends a signed request for the bucket (empty key) or an object
+func (s *S3Store) do(ctx context.Context, method, key string, body []byte, contentType string) (*http.Response, error) {
+	target := s.baseURL
+	if key != "" {
+		target += "/" + strings.TrimPrefix(key, "/")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if method == http.MethodPut && key != "" {
+		req.Header.Set("Cache-Control", "public, max-age=86400")
+	}
+
+	s.sign(req, body, time.Now().UTC())
+	return s.client.Do(req)
+}
+
+// sign adds AWS Signature Version 4 headers to a request
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", req.URL.Host, payloadHash, amzDate)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		(&url.URL{Path: req.URL.Path}).EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.cfg.Region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signature := hex.EncodeToString(hmacSHA256(s.signingKey(date), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+// signingKey derives the SigV4 signing key for a date
+func (s *S3Store) signingKey(date string) []byte {
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// AvatarKey returns the object key for a user's avatar at the given size
+func AvatarKey(userID string, size ImageSize) string {
+	return fmt.Sprintf("avatars/%s/%s.jpg", userID, size.Name)
+}
+
+// ExportKey returns the object key for a user data export archive
+func ExportKey(userID, exportID, extension string) string {
+	return fmt.Sprintf("exports/%s/%s.%s", userID, exportID, extension)
+}
+
+// OrderArchiveKey returns the object key for a batch of archived orders
+func OrderArchiveKey(day time.Time, batchID string) string {
+	return fmt.Sprintf("archives/orders/%s/%s.ndjson.gz", day.UTC().Format("2006/01/02"), batchID)
+}
+
+// ProductImageKey returns the object key for a product image at the given size
+func ProductImageKey(productID, imageID string, size ImageSize) string {
+	return fmt.Sprintf("products/%s/%s_%s.jpg", productID, imageID, size.Name)
+}
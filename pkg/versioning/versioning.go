@@ -0,0 +1,119 @@
+// Package versioning implements optimistic concurrency control for API
+// resources. Each resource row carries a version that every write increments;
+// reads return it as a strong ETag and writes honor If-Match, so a client
+// editing a stale copy gets 412 Precondition Failed instead of silently
+// overwriting someone else's change.
+package versioning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrConflict is returned when a write is based on a version of a resource
+// that is no longer current
+var ErrConflict = errors.New("resource has been modified")
+
+// gRPC metadata keys. The services' HTTP transcoding forwards the If-Match
+// request header as grpcgateway-if-match and returns response metadata as
+// Grpc-Metadata-* headers, which the gateway turns back into ETag and 412.
+const (
+	MetadataIfMatch            = "if-match"
+	MetadataGatewayIfMatch     = "grpcgateway-if-match"
+	MetadataETag               = "etag"
+	MetadataPreconditionFailed = "x-precondition-failed"
+)
+
+// ETag formats a resource version as a strong entity tag
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// Precondition is a parsed If-Match header. A nil Precondition matches every
+// version, so writes without If-Match are unconditional.
+type Precondition struct {
+	any      bool
+	versions []int64
+}
+
+// ParseIfMatch parses an If-Match header value. Weak tags and tags that are
+// not versions are accepted but never match, as RFC 9110 requires strong
+// comparison for If-Match.
+func ParseIfMatch(header string) (*Precondition, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, nil
+	}
+	if header == "*" {
+		return &Precondition{any: true}, nil
+	}
+
+	p := &Precondition{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		weak := strings.HasPrefix(tag, "W/")
+		opaque := strings.TrimPrefix(tag, "W/")
+		if len(opaque) < 2 || opaque[0] != '"' || opaque[len(opaque)-1] != '"' {
+			return nil, fmt.Errorf("invalid entity tag %q", tag)
+		}
+		if weak {
+			continue
+		}
+		if version, err := strconv.ParseInt(opaque[1:len(opaque)-1], 10, 64); err == nil {
+			p.versions = append(p.versions, version)
+		}
+	}
+	return p, nil
+}
+
+// Matches reports whether a write to the given version of a resource may
+// proceed
+func (p *Precondition) Matches(version int64) bool {
+	if p == nil || p.any {
+		return true
+	}
+	for _, v := range p.versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatch returns the precondition of an incoming gRPC call, or nil if the
+// call has none
+func IfMatch(ctx context.Context) (*Precondition, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	values := md.Get(MetadataGatewayIfMatch)
+	if len(values) == 0 {
+		values = md.Get(MetadataIfMatch)
+	}
+	return ParseIfMatch(strings.Join(values, ","))
+}
+
+// SetETag returns a resource version as the ETag of a gRPC call
+func SetETag(ctx context.Context, version int64) error {
+	return grpc.SetHeader(ctx, metadata.Pairs(MetadataETag, ETag(version)))
+}
+
+// PreconditionFailed is the error of a write that lost to a concurrent one.
+// The response metadata marks it so the gateway answers 412 rather than the
+// 400 that FailedPrecondition otherwise maps to.
+func PreconditionFailed(ctx context.Context, resource string) error {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataPreconditionFailed, "true"))
+	return status.Errorf(codes.FailedPrecondition, "%s has been modified; fetch it again and retry", resource)
+}
@@ -0,0 +1,306 @@
+// Package watchdog watches a service for leaked goroutines, file descriptors
+// and database connections. It exports the counts as metrics and, when one
+// crosses its threshold, logs a dump showing where they are held.
+package watchdog
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"microservices-platform/pkg/metrics"
+)
+
+// Config holds the watchdog thresholds
+type Config struct {
+	Enabled  bool
+	Interval time.Duration
+	// MaxGoroutines is the goroutine count considered a leak
+	MaxGoroutines int
+	// FDRatio is the share of the open file limit considered a leak
+	FDRatio float64
+	// DBPoolRatio is the share of a pool's maximum connections in use
+	// considered saturated
+	DBPoolRatio float64
+	// DumpCooldown is the least time between two dumps of a resource
+	DumpCooldown time.Duration
+	// MaxDumpBytes truncates dumps
+	MaxDumpBytes int
+}
+
+// LoadConfig loads watchdog configuration from environment variables
+func LoadConfig() Config {
+	return Config{
+		Enabled:       getBoolEnv("WATCHDOG_ENABLED", true),
+		Interval:      getDurationEnv("WATCHDOG_INTERVAL", 30*time.Second),
+		MaxGoroutines: getIntEnv("WATCHDOG_MAX_GOROUTINES", 10000),
+		FDRatio:       getFloatEnv("WATCHDOG_FD_RATIO", 0.8),
+		DBPoolRatio:   getFloatEnv("WATCHDOG_DB_POOL_RATIO", 0.9),
+		DumpCooldown:  getDurationEnv("WATCHDOG_DUMP_COOLDOWN", 10*time.Minute),
+		MaxDumpBytes:  getIntEnv("WATCHDOG_MAX_DUMP_BYTES", 64*1024),
+	}
+}
+
+// Resources watched besides database pools, which are named
+// "db_pool_<database>"
+const (
+	ResourceGoroutines = "goroutines"
+	ResourceFDs        = "fds"
+)
+
+// pool is a watched database connection pool
+type pool struct {
+	name  string
+	db    *sql.DB
+	waits int64
+}
+
+// Watchdog periodically checks a service's goroutines, file descriptors and
+// database connection pools
+type Watchdog struct {
+	service string
+	cfg     Config
+
+	mu       sync.Mutex
+	pools    []*pool
+	exceeded map[string]bool
+	dumpedAt map[string]time.Time
+}
+
+// New creates a new watchdog for service
+func New(service string, cfg Config) *Watchdog {
+	return &Watchdog{
+		service:  service,
+		cfg:      cfg,
+		exceeded: make(map[string]bool),
+		dumpedAt: make(map[string]time.Time),
+	}
+}
+
+// WatchDB adds a database connection pool to watch
+func (w *Watchdog) WatchDB(name string, db *sql.DB) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pools = append(w.pools, &pool{name: name, db: db, waits: db.Stats().WaitCount})
+}
+
+// Start runs checks until the context is cancelled. It does nothing if the
+// watchdog is disabled.
+func (w *Watchdog) Start(ctx context.Context) {
+	if !w.cfg.Enabled {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(w.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.check()
+			}
+		}
+	}()
+}
+
+// check updates the metrics and reports resources crossing their threshold
+func (w *Watchdog) check() {
+	goroutines := runtime.NumGoroutine()
+	fds, fdLimit := openFDs(), fdLimit()
+	metrics.UpdateWatchdog(w.service, goroutines, fds, fdLimit)
+
+	w.report(ResourceGoroutines, w.cfg.MaxGoroutines > 0 && goroutines >= w.cfg.MaxGoroutines,
+		fmt.Sprintf("%d goroutines (threshold %d)", goroutines, w.cfg.MaxGoroutines), goroutineDump)
+
+	if fds >= 0 && fdLimit > 0 {
+		threshold := int(float64(fdLimit) * w.cfg.FDRatio)
+		w.report(ResourceFDs, w.cfg.FDRatio > 0 && fds >= threshold,
+			fmt.Sprintf("%d open file descriptors (threshold %d, limit %d)", fds, threshold, fdLimit), fdDump)
+	}
+
+	w.mu.Lock()
+	pools := w.pools
+	w.mu.Unlock()
+	for _, p := range pools {
+		stats := p.db.Stats()
+		waits := stats.WaitCount - p.waits
+		p.waits = stats.WaitCount
+
+		var saturation float64
+		if stats.MaxOpenConnections > 0 {
+			saturation = float64(stats.InUse) / float64(stats.MaxOpenConnections)
+		}
+		metrics.UpdateDatabasePool(w.service, p.name, stats.InUse, saturation, waits)
+
+		summary := fmt.Sprintf("database %s pool %d/%d connections in use, %d idle, %d waits (%s) since last check",
+			p.name, stats.InUse, stats.MaxOpenConnections, stats.Idle, waits, stats.WaitDuration.Round(time.Millisecond))
+		// Connections held by leaked rows or transactions show up as the
+		// goroutines holding them
+		w.report("db_pool_"+p.name, w.cfg.DBPoolRatio > 0 && stats.MaxOpenConnections > 0 && saturation >= w.cfg.DBPoolRatio,
+			summary, goroutineDump)
+	}
+}
+
+// report logs a resource crossing its threshold, with a dump unless one was
+// logged within the cooldown, and it falling back below
+func (w *Watchdog) report(resource string, exceeded bool, summary string, dump func() string) {
+	w.mu.Lock()
+	was := w.exceeded[resource]
+	w.exceeded[resource] = exceeded
+	dumpDue := exceeded && !was && time.Since(w.dumpedAt[resource]) >= w.cfg.DumpCooldown
+	if dumpDue {
+		w.dumpedAt[resource] = time.Now()
+	}
+	w.mu.Unlock()
+
+	metrics.UpdateWatchdogThreshold(w.service, resource, exceeded)
+
+	switch {
+	case exceeded && !was && dumpDue:
+		log.Printf("WARNING: watchdog: %s over threshold: %s\n%s", resource, summary, w.truncate(dump()))
+	case exceeded && !was:
+		log.Printf("WARNING: watchdog: %s over threshold: %s", resource, summary)
+	case !exceeded && was:
+		log.Printf("watchdog: %s back under threshold: %s", resource, summary)
+	}
+}
+
+func (w *Watchdog) truncate(dump string) string {
+	if w.cfg.MaxDumpBytes > 0 && len(dump) > w.cfg.MaxDumpBytes {
+		return dump[:w.cfg.MaxDumpBytes] + "\n... (truncated)"
+	}
+	return dump
+}
+
+// goroutineDump lists the goroutines' stacks, grouped by stack with counts
+func goroutineDump() string {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return "goroutine dump failed: " + err.Error()
+	}
+	return buf.String()
+}
+
+// fdDump counts the open file descriptors by what they refer to, sockets
+// and pipes together and files by directory, most first
+func fdDump() string {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return "file descriptor dump unavailable: " + err.Error()
+	}
+
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", entry.Name()))
+		if err != nil {
+			continue
+		}
+		if i := strings.Index(target, ":["); i >= 0 {
+			// socket:[1234], pipe:[5678]
+			target = target[:i]
+		} else if strings.HasPrefix(target, "/") {
+			target = filepath.Dir(target) + "/"
+		}
+		counts[target]++
+	}
+
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if counts[kinds[i]] != counts[kinds[j]] {
+			return counts[kinds[i]] > counts[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+
+	var b strings.Builder
+	for _, kind := range kinds {
+		fmt.Fprintf(&b, "%6d %s\n", counts[kind], kind)
+	}
+	return b.String()
+}
+
+// openFDs returns the number of open file descriptors, or -1 where
+// /proc is unavailable
+func openFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(entries)
+}
+
+// fdLimit returns the soft limit on open file descriptors, or -1 if it is
+// unknown or unlimited
+func fdLimit() int {
+	content, err := os.ReadFile("/proc/self/limits")
+	if err != nil {
+		return -1
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+		if len(fields) == 0 {
+			return -1
+		}
+		limit, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return -1
+		}
+		return limit
+	}
+	return -1
+}
+
+func getBoolEnv(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getIntEnv(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getFloatEnv(key string, fallback float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
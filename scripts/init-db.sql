@@ -4,10 +4,14 @@ CREATE DATABASE orderdb;
 CREATE DATABASE productdb;
 CREATE DATABASE paymentdb;
 CREATE DATABASE notificationdb;
+CREATE DATABASE subscriptiondb;
+CREATE DATABASE statsdb;
 
 -- Grant privileges
 GRANT ALL PRIVILEGES ON DATABASE userdb TO postgres;
 GRANT ALL PRIVILEGES ON DATABASE orderdb TO postgres;
 GRANT ALL PRIVILEGES ON DATABASE productdb TO postgres;
 GRANT ALL PRIVILEGES ON DATABASE paymentdb TO postgres;
-GRANT ALL PRIVILEGES ON DATABASE notificationdb TO postgres;
\ No newline at end of file
+GRANT ALL PRIVILEGES ON DATABASE notificationdb TO postgres;
+GRANT ALL PRIVILEGES ON DATABASE subscriptiondb TO postgres;
+GRANT ALL PRIVILEGES ON DATABASE statsdb TO postgres;
\ No newline at end of file
@@ -0,0 +1,99 @@
+// Package channels delivers notifications to users. Each channel is a plugin
+// behind the Channel interface; the service delivers through whichever
+// channels are registered, and records notifications for the others as
+// failed.
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Channel names, as stored on notifications
+const (
+	Email   = "email"
+	SMS     = "sms"
+	Push    = "push"
+	InApp   = "in_app"
+	Webhook = "webhook"
+)
+
+// Recipient settings keys, set with Subscribe
+const (
+	SettingEmail      = "email"
+	SettingPhone      = "phone"
+	SettingWebhookURL = "webhook_url"
+)
+
+// ErrUndeliverable wraps failures that retrying will not fix, such as a
+// recipient without an address for the channel
+var ErrUndeliverable = errors.New("undeliverable")
+
+// Undeliverable returns an error that is not retried
+func Undeliverable(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrUndeliverable, fmt.Sprintf(format, args...))
+}
+
+// Recipient is the user a notification is for, with their addresses
+type Recipient struct {
+	UserID string
+	// Settings holds the user's addresses by setting key
+	Settings map[string]string
+}
+
+// Message is a notification as a channel delivers it
+type Message struct {
+	ID        string
+	Type      string
+	Title     string
+	Body      string
+	Metadata  map[string]string
+	CreatedAt time.Time
+}
+
+// Channel delivers notifications
+type Channel interface {
+	// Name is the channel name stored on notifications
+	Name() string
+	// Send delivers a message. It returns an error wrapping
+	// ErrUndeliverable if retrying cannot help.
+	Send(ctx context.Context, to Recipient, msg Message) error
+}
+
+// Registry holds the channels notifications can be delivered through
+type Registry struct {
+	channels map[string]Channel
+}
+
+// NewRegistry creates a registry of channels
+func NewRegistry(channels ...Channel) *Registry {
+	r := &Registry{channels: make(map[string]Channel)}
+	for _, channel := range channels {
+		r.Register(channel)
+	}
+	return r
+}
+
+// Register adds a channel, replacing one of the same name
+func (r *Registry) Register(channel Channel) {
+	r.channels[channel.Name()] = channel
+}
+
+// Get returns the channel of a name
+func (r *Registry) Get(name string) (Channel, bool) {
+	channel, ok := r.channels[name]
+	return channel, ok
+}
+
+// Names lists the registered channels
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.channels))
+	for name := range r.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
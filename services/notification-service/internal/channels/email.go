@@ -0,0 +1,73 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// EmailChannel mails notifications through an SMTP server
+type EmailChannel struct {
+	Addr string
+	From string
+	// Auth is nil for servers that do not need it
+	Auth    smtp.Auth
+	Timeout time.Duration
+}
+
+// NewEmailChannel creates an email channel for an SMTP server at addr
+// ("host:port"). Without a username the server is used unauthenticated.
+func NewEmailChannel(addr, username, password, from string, timeout time.Duration) *EmailChannel {
+	channel := &EmailChannel{Addr: addr, From: from, Timeout: timeout}
+	if username != "" {
+		host := addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		channel.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return channel
+}
+
+// Name implements Channel
+func (c *EmailChannel) Name() string {
+	return Email
+}
+
+// Send implements Channel
+func (c *EmailChannel) Send(ctx context.Context, to Recipient, msg Message) error {
+	address := to.Settings[SettingEmail]
+	if address == "" {
+		return Undeliverable("user %s has no email address", to.UserID)
+	}
+	if strings.ContainsAny(address, "\r\n") {
+		return Undeliverable("invalid email address %q", address)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", c.From)
+	fmt.Fprintf(&body, "To: %s\r\n", address)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Title))
+	fmt.Fprintf(&body, "Message-ID: <%s@notifications>\r\n", msg.ID)
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(c.Addr, c.Auth, c.From, []string{address}, []byte(body.String()))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,23 @@
+package channels
+
+import "context"
+
+// InAppChannel delivers notifications to the user's inbox, which is the
+// stored notification itself, listed by ListNotifications
+type InAppChannel struct{}
+
+// NewInAppChannel creates an in-app channel
+func NewInAppChannel() *InAppChannel {
+	return &InAppChannel{}
+}
+
+// Name implements Channel
+func (c *InAppChannel) Name() string {
+	return InApp
+}
+
+// Send implements Channel. The notification is in the inbox once it is
+// stored, so there is nothing left to do.
+func (c *InAppChannel) Send(ctx context.Context, to Recipient, msg Message) error {
+	return nil
+}
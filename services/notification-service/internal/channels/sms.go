@@ -0,0 +1,48 @@
+package channels
+
+import (
+	"context"
+	"errors"
+
+	"microservices-platform/pkg/sms"
+)
+
+// SMSChannel texts notifications through an SMS provider, normally a
+// sms.CappedSender so that daily caps apply
+type SMSChannel struct {
+	sender sms.Sender
+}
+
+// NewSMSChannel creates an SMS channel sending through sender
+func NewSMSChannel(sender sms.Sender) *SMSChannel {
+	return &SMSChannel{sender: sender}
+}
+
+// Name implements Channel
+func (c *SMSChannel) Name() string {
+	return SMS
+}
+
+// Send implements Channel. Messages over the length limit or a daily cap
+// are not retried.
+func (c *SMSChannel) Send(ctx context.Context, to Recipient, msg Message) error {
+	phone := to.Settings[SettingPhone]
+	if phone == "" {
+		return Undeliverable("user %s has no phone number", to.UserID)
+	}
+
+	text := msg.Body
+	if msg.Title != "" && text != "" {
+		text = msg.Title + "\n" + text
+	} else if text == "" {
+		text = msg.Title
+	}
+
+	_, err := c.sender.Send(ctx, sms.Message{UserID: to.UserID, To: phone, Body: text})
+	var lengthErr *sms.LengthError
+	var capErr *sms.CapError
+	if errors.As(err, &lengthErr) || errors.As(err, &capErr) {
+		return Undeliverable("%v", err)
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Webhook request headers. The signature is "t=<unix>,v1=<hex>", the
+// HMAC-SHA256 of "<unix>.<body>", as for partner webhooks.
+const (
+	HeaderNotificationID = "X-Notification-Id"
+	HeaderSignature      = "X-Notification-Signature"
+)
+
+// WebhookChannel POSTs notifications as JSON to the user's webhook URL
+type WebhookChannel struct {
+	client *http.Client
+	// secret signs bodies; unsigned if empty
+	secret string
+}
+
+// webhookPayload is the body of a webhook notification
+type webhookPayload struct {
+	ID        string            `json:"notification_id"`
+	UserID    string            `json:"user_id"`
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Message   string            `json:"message"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// NewWebhookChannel creates a webhook channel
+func NewWebhookChannel(secret string, timeout time.Duration) *WebhookChannel {
+	return &WebhookChannel{
+		client: &http.Client{Timeout: timeout},
+		secret: secret,
+	}
+}
+
+// Name implements Channel
+func (c *WebhookChannel) Name() string {
+	return Webhook
+}
+
+// Send implements Channel. Client errors other than 408 and 429 are not
+// retried.
+func (c *WebhookChannel) Send(ctx context.Context, to Recipient, msg Message) error {
+	target := to.Settings[SettingWebhookURL]
+	if target == "" {
+		return Undeliverable("user %s has no webhook URL", to.UserID)
+	}
+	if parsed, err := url.Parse(target); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return Undeliverable("invalid webhook URL %q", target)
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		ID:        msg.ID,
+		UserID:    to.UserID,
+		Type:      msg.Type,
+		Title:     msg.Title,
+		Message:   msg.Body,
+		Metadata:  msg.Metadata,
+		CreatedAt: msg.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return Undeliverable("invalid webhook URL %q: %v", target, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderNotificationID, msg.ID)
+	if c.secret != "" {
+		req.Header.Set(HeaderSignature, sign(c.secret, strconv.FormatInt(time.Now().Unix(), 10), body))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode <= 499 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return Undeliverable("webhook responded with status %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+}
+
+// sign returns the signature header value for a webhook body
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
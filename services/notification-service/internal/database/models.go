@@ -0,0 +1,125 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// NewConnection creates a new database connection. verbose logs every SQL
+// statement; otherwise only slow queries and errors are logged.
+func NewConnection(databaseURL string, verbose bool) (*gorm.DB, error) {
+	logLevel := logger.Warn
+	if verbose {
+		logLevel = logger.Info
+	}
+
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Migrate brings the schema up to date with the models
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(Models()...)
+}
+
+// Models lists the models migrated into the database, which the schema
+// drift check compares it against
+func Models() []interface{} {
+	return []interface{}{&Notification{}, &Preference{}}
+}
+
+// Notification statuses
+const (
+	StatusPending = "pending"
+	// StatusSent means the channel accepted the notification
+	StatusSent = "sent"
+	// StatusDelivered means the notification reached the user, as in-app
+	// notifications do once stored
+	StatusDelivered = "delivered"
+	// StatusFailed notifications are retried until NextAttemptAt is cleared
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	// StatusBatched notifications wait for the user's next digest
+	StatusBatched = "batched"
+)
+
+// Notification priorities
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+// Digest frequencies. An empty frequency sends every notification on its
+// own.
+const (
+	DigestHourly = "hourly"
+	DigestDaily  = "daily"
+)
+
+// Notification is one notification to a user on one channel, with the
+// status of its delivery
+type Notification struct {
+	ID       string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID   string `gorm:"not null;index:idx_notifications_user_created"`
+	Type     string `gorm:"not null"`
+	Channel  string `gorm:"not null"`
+	Priority string `gorm:"not null;default:'normal'"`
+	Title    string `gorm:"not null"`
+	Message  string `gorm:"type:text"`
+	Metadata string `gorm:"type:text"` // JSON object
+	Status   string `gorm:"not null;index"`
+	Error    string `gorm:"type:text"`
+	Attempts int    `gorm:"not null;default:0"`
+	// NextAttemptAt is when a failed delivery is retried; nil once it
+	// succeeded or gave up
+	NextAttemptAt *time.Time `gorm:"index"`
+	DigestID      string     `gorm:"index"`
+	Read          bool       `gorm:"not null;default:false"`
+	ReadAt        *time.Time
+	SentAt        *time.Time
+	CreatedAt     time.Time `gorm:"autoCreateTime;index:idx_notifications_user_created"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
+
+// Preference is a user's subscription to notifications: which types and
+// channels they want, where to deliver them and how to batch low-priority
+// ones
+type Preference struct {
+	ID       string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID   string `gorm:"not null;uniqueIndex"`
+	Types    string `gorm:"type:text"` // comma-separated; empty is every type
+	Channels string `gorm:"type:text"` // comma-separated; empty is the default channels
+	Settings string `gorm:"type:text"` // JSON object, e.g. email, phone, webhook_url
+	// DigestFrequency is hourly, daily or empty
+	DigestFrequency string
+	// DigestHour is the hour of the day, UTC, daily digests are sent at
+	DigestHour int `gorm:"not null;default:0"`
+	// NextDigestAt is when the user's batched notifications are next sent;
+	// nil while none are waiting
+	NextDigestAt *time.Time `gorm:"index"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime"`
+}
+
+// Notification types
+const (
+	TypeOrderConfirmation = "order_confirmation"
+	TypeOrderShipped      = "order_shipped"
+	TypeOrderDelivered    = "order_delivered"
+	TypePaymentSuccess    = "payment_success"
+	TypePaymentFailed     = "payment_failed"
+	TypeAccountUpdate     = "account_update"
+	TypePromotional       = "promotional"
+	TypeSystemAlert       = "system_alert"
+	TypeCartReminder      = "cart_reminder"
+)
@@ -0,0 +1,414 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"microservices-platform/pkg/middleware"
+	pb "microservices-platform/pkg/proto/notification/v1"
+	"microservices-platform/services/notification-service/internal/channels"
+	"microservices-platform/services/notification-service/internal/database"
+	"microservices-platform/services/notification-service/internal/repository"
+	"microservices-platform/services/notification-service/internal/service"
+)
+
+// NotificationHandler implements the gRPC NotificationService
+type NotificationHandler struct {
+	pb.UnimplementedNotificationServiceServer
+	notificationService service.NotificationService
+	tracer              trace.Tracer
+}
+
+// NewNotificationHandler creates a new NotificationHandler
+func NewNotificationHandler(notificationService service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+		tracer:              otel.Tracer("notification-service"),
+	}
+}
+
+// SendNotification sends a notification to a user. Only admins and other
+// services may send notifications.
+func (h *NotificationHandler) SendNotification(ctx context.Context, req *pb.SendNotificationRequest) (*pb.SendNotificationResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "NotificationHandler.SendNotification")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("notification.user_id", req.UserId),
+		attribute.String("notification.type", req.Type.String()),
+	)
+
+	if identity, ok := middleware.IdentityFromContext(ctx); ok && !identity.HasRole("admin") {
+		return nil, status.Errorf(codes.PermissionDenied, "only admins can send notifications")
+	}
+
+	var channelNames []string
+	for _, channel := range req.Channels {
+		channelNames = append(channelNames, h.convertChannelToString(channel))
+	}
+
+	notifications, err := h.notificationService.Send(ctx, service.SendRequest{
+		UserID:    req.UserId,
+		Type:      h.convertTypeToString(req.Type),
+		Title:     req.Title,
+		Message:   req.Message,
+		Channels:  channelNames,
+		Metadata:  req.Metadata,
+		Priority:  h.convertPriorityToString(req.Priority),
+		Immediate: req.Immediate,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to send notification: %v", err)
+	}
+
+	var protoNotifications []*pb.Notification
+	for _, notification := range notifications {
+		protoNotifications = append(protoNotifications, h.convertToProtoNotification(notification))
+	}
+
+	return &pb.SendNotificationResponse{Notifications: protoNotifications}, nil
+}
+
+// GetNotification retrieves a notification
+func (h *NotificationHandler) GetNotification(ctx context.Context, req *pb.GetNotificationRequest) (*pb.GetNotificationResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "NotificationHandler.GetNotification")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("notification.id", req.NotificationId))
+
+	notification, err := h.ownNotification(ctx, req.NotificationId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return &pb.GetNotificationResponse{Notification: h.convertToProtoNotification(notification)}, nil
+}
+
+// ListNotifications lists a user's notifications
+func (h *NotificationHandler) ListNotifications(ctx context.Context, req *pb.ListNotificationsRequest) (*pb.ListNotificationsResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "NotificationHandler.ListNotifications")
+	defer span.End()
+
+	userID := req.UserId
+	if identity, ok := middleware.IdentityFromContext(ctx); ok && userID == "" {
+		userID = identity.UserID
+	}
+	span.SetAttributes(attribute.String("notification.user_id", userID))
+
+	if err := authorizeUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	filter := repository.NotificationFilter{UserID: userID, UnreadOnly: req.UnreadOnly}
+	if req.Type != pb.NotificationType_NOTIFICATION_TYPE_UNSPECIFIED {
+		filter.Type = h.convertTypeToString(req.Type)
+	}
+	if req.Channel != pb.NotificationChannel_NOTIFICATION_CHANNEL_UNSPECIFIED {
+		filter.Channel = h.convertChannelToString(req.Channel)
+	}
+
+	notifications, total, unread, err := h.notificationService.ListNotifications(ctx, filter, int(req.Page), int(req.PageSize))
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to list notifications: %v", err)
+	}
+
+	var protoNotifications []*pb.Notification
+	for _, notification := range notifications {
+		protoNotifications = append(protoNotifications, h.convertToProtoNotification(notification))
+	}
+
+	return &pb.ListNotificationsResponse{
+		Notifications: protoNotifications,
+		TotalCount:    int32(total),
+		UnreadCount:   int32(unread),
+		Page:          req.Page,
+		PageSize:      req.PageSize,
+	}, nil
+}
+
+// MarkAsRead marks a notification as read
+func (h *NotificationHandler) MarkAsRead(ctx context.Context, req *pb.MarkAsReadRequest) (*pb.MarkAsReadResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "NotificationHandler.MarkAsRead")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("notification.id", req.NotificationId))
+
+	if _, err := h.ownNotification(ctx, req.NotificationId); err != nil {
+		return nil, err
+	}
+
+	notification, err := h.notificationService.MarkAsRead(ctx, req.NotificationId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, h.notificationError(err, "failed to mark notification as read")
+	}
+
+	return &pb.MarkAsReadResponse{Notification: h.convertToProtoNotification(notification)}, nil
+}
+
+// DeleteNotification deletes a notification
+func (h *NotificationHandler) DeleteNotification(ctx context.Context, req *pb.DeleteNotificationRequest) (*pb.DeleteNotificationResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "NotificationHandler.DeleteNotification")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("notification.id", req.NotificationId))
+
+	if _, err := h.ownNotification(ctx, req.NotificationId); err != nil {
+		return nil, err
+	}
+
+	if err := h.notificationService.DeleteNotification(ctx, req.NotificationId); err != nil {
+		span.RecordError(err)
+		return nil, h.notificationError(err, "failed to delete notification")
+	}
+
+	return &pb.DeleteNotificationResponse{Success: true}, nil
+}
+
+// Subscribe sets which notifications a user receives, where and how often
+func (h *NotificationHandler) Subscribe(ctx context.Context, req *pb.SubscribeRequest) (*pb.SubscribeResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "NotificationHandler.Subscribe")
+	defer span.End()
+
+	userID := req.UserId
+	if identity, ok := middleware.IdentityFromContext(ctx); ok && userID == "" {
+		userID = identity.UserID
+	}
+	span.SetAttributes(attribute.String("notification.user_id", userID))
+
+	if err := authorizeUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	subscribe := service.SubscribeRequest{
+		UserID:          userID,
+		Settings:        req.Preferences,
+		DigestFrequency: h.convertDigestFrequencyToString(req.DigestFrequency),
+		DigestHour:      int(req.DigestHour),
+	}
+	for _, notificationType := range req.Types {
+		subscribe.Types = append(subscribe.Types, h.convertTypeToString(notificationType))
+	}
+	for _, channel := range req.Channels {
+		subscribe.Channels = append(subscribe.Channels, h.convertChannelToString(channel))
+	}
+
+	preference, err := h.notificationService.Subscribe(ctx, subscribe)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to subscribe: %v", err)
+	}
+
+	return &pb.SubscribeResponse{Success: true, SubscriptionId: preference.ID}, nil
+}
+
+// ownNotification returns a notification the caller may access
+func (h *NotificationHandler) ownNotification(ctx context.Context, id string) (*database.Notification, error) {
+	notification, err := h.notificationService.GetNotification(ctx, id)
+	if err != nil {
+		return nil, h.notificationError(err, "failed to get notification")
+	}
+	if err := authorizeUser(ctx, notification.UserID); err != nil {
+		// Other users' notifications are not found, so IDs cannot be probed
+		return nil, status.Errorf(codes.NotFound, "notification not found")
+	}
+	return notification, nil
+}
+
+// notificationError converts a service error to a gRPC status
+func (h *NotificationHandler) notificationError(err error, message string) error {
+	if errors.Is(err, service.ErrNotificationNotFound) {
+		return status.Errorf(codes.NotFound, "notification not found")
+	}
+	return status.Errorf(codes.Internal, "%s: %v", message, err)
+}
+
+// authorizeUser checks that the caller identity propagated from the gateway
+// may act for a user. Callers act for themselves; admins act for anyone.
+// Calls without an identity come from other services.
+func authorizeUser(ctx context.Context, userID string) error {
+	identity, ok := middleware.IdentityFromContext(ctx)
+	if !ok || identity.HasRole("admin") || userID == identity.UserID {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "not allowed to access this user's notifications")
+}
+
+// Helper methods for conversion
+func (h *NotificationHandler) convertToProtoNotification(notification *database.Notification) *pb.Notification {
+	var metadata map[string]string
+	if notification.Metadata != "" {
+		json.Unmarshal([]byte(notification.Metadata), &metadata)
+	}
+
+	protoNotification := &pb.Notification{
+		NotificationId: notification.ID,
+		UserId:         notification.UserID,
+		Title:          notification.Title,
+		Message:        notification.Message,
+		Type:           h.convertTypeToProto(notification.Type),
+		Channel:        h.convertChannelToProto(notification.Channel),
+		Status:         h.convertStatusToProto(notification.Status),
+		Metadata:       metadata,
+		Read:           notification.Read,
+		CreatedAt:      timestamppb.New(notification.CreatedAt),
+		Priority:       h.convertPriorityToProto(notification.Priority),
+		DigestId:       notification.DigestID,
+		Error:          notification.Error,
+		Attempts:       int32(notification.Attempts),
+	}
+	if notification.ReadAt != nil {
+		protoNotification.ReadAt = timestamppb.New(*notification.ReadAt)
+	}
+	if notification.SentAt != nil {
+		protoNotification.SentAt = timestamppb.New(*notification.SentAt)
+	}
+	return protoNotification
+}
+
+func (h *NotificationHandler) convertTypeToProto(notificationType string) pb.NotificationType {
+	switch notificationType {
+	case database.TypeOrderConfirmation:
+		return pb.NotificationType_NOTIFICATION_TYPE_ORDER_CONFIRMATION
+	case database.TypeOrderShipped:
+		return pb.NotificationType_NOTIFICATION_TYPE_ORDER_SHIPPED
+	case database.TypeOrderDelivered:
+		return pb.NotificationType_NOTIFICATION_TYPE_ORDER_DELIVERED
+	case database.TypePaymentSuccess:
+		return pb.NotificationType_NOTIFICATION_TYPE_PAYMENT_SUCCESS
+	case database.TypePaymentFailed:
+		return pb.NotificationType_NOTIFICATION_TYPE_PAYMENT_FAILED
+	case database.TypeAccountUpdate:
+		return pb.NotificationType_NOTIFICATION_TYPE_ACCOUNT_UPDATE
+	case database.TypePromotional:
+		return pb.NotificationType_NOTIFICATION_TYPE_PROMOTIONAL
+	case database.TypeSystemAlert:
+		return pb.NotificationType_NOTIFICATION_TYPE_SYSTEM_ALERT
+	case database.TypeCartReminder:
+		return pb.NotificationType_NOTIFICATION_TYPE_CART_REMINDER
+	default:
+		return pb.NotificationType_NOTIFICATION_TYPE_UNSPECIFIED
+	}
+}
+
+func (h *NotificationHandler) convertTypeToString(notificationType pb.NotificationType) string {
+	switch notificationType {
+	case pb.NotificationType_NOTIFICATION_TYPE_ORDER_CONFIRMATION:
+		return database.TypeOrderConfirmation
+	case pb.NotificationType_NOTIFICATION_TYPE_ORDER_SHIPPED:
+		return database.TypeOrderShipped
+	case pb.NotificationType_NOTIFICATION_TYPE_ORDER_DELIVERED:
+		return database.TypeOrderDelivered
+	case pb.NotificationType_NOTIFICATION_TYPE_PAYMENT_SUCCESS:
+		return database.TypePaymentSuccess
+	case pb.NotificationType_NOTIFICATION_TYPE_PAYMENT_FAILED:
+		return database.TypePaymentFailed
+	case pb.NotificationType_NOTIFICATION_TYPE_ACCOUNT_UPDATE:
+		return database.TypeAccountUpdate
+	case pb.NotificationType_NOTIFICATION_TYPE_PROMOTIONAL:
+		return database.TypePromotional
+	case pb.NotificationType_NOTIFICATION_TYPE_SYSTEM_ALERT:
+		return database.TypeSystemAlert
+	case pb.NotificationType_NOTIFICATION_TYPE_CART_REMINDER:
+		return database.TypeCartReminder
+	default:
+		return ""
+	}
+}
+
+func (h *NotificationHandler) convertChannelToProto(channel string) pb.NotificationChannel {
+	switch channel {
+	case channels.Email:
+		return pb.NotificationChannel_NOTIFICATION_CHANNEL_EMAIL
+	case channels.SMS:
+		return pb.NotificationChannel_NOTIFICATION_CHANNEL_SMS
+	case channels.Push:
+		return pb.NotificationChannel_NOTIFICATION_CHANNEL_PUSH
+	case channels.InApp:
+		return pb.NotificationChannel_NOTIFICATION_CHANNEL_IN_APP
+	case channels.Webhook:
+		return pb.NotificationChannel_NOTIFICATION_CHANNEL_WEBHOOK
+	default:
+		return pb.NotificationChannel_NOTIFICATION_CHANNEL_UNSPECIFIED
+	}
+}
+
+func (h *NotificationHandler) convertChannelToString(channel pb.NotificationChannel) string {
+	switch channel {
+	case pb.NotificationChannel_NOTIFICATION_CHANNEL_EMAIL:
+		return channels.Email
+	case pb.NotificationChannel_NOTIFICATION_CHANNEL_SMS:
+		return channels.SMS
+	case pb.NotificationChannel_NOTIFICATION_CHANNEL_PUSH:
+		return channels.Push
+	case pb.NotificationChannel_NOTIFICATION_CHANNEL_IN_APP:
+		return channels.InApp
+	case pb.NotificationChannel_NOTIFICATION_CHANNEL_WEBHOOK:
+		return channels.Webhook
+	default:
+		return ""
+	}
+}
+
+func (h *NotificationHandler) convertStatusToProto(notificationStatus string) pb.NotificationStatus {
+	switch notificationStatus {
+	case database.StatusPending:
+		return pb.NotificationStatus_NOTIFICATION_STATUS_PENDING
+	case database.StatusSent:
+		return pb.NotificationStatus_NOTIFICATION_STATUS_SENT
+	case database.StatusDelivered:
+		return pb.NotificationStatus_NOTIFICATION_STATUS_DELIVERED
+	case database.StatusFailed:
+		return pb.NotificationStatus_NOTIFICATION_STATUS_FAILED
+	case database.StatusCancelled:
+		return pb.NotificationStatus_NOTIFICATION_STATUS_CANCELLED
+	case database.StatusBatched:
+		return pb.NotificationStatus_NOTIFICATION_STATUS_BATCHED
+	default:
+		return pb.NotificationStatus_NOTIFICATION_STATUS_UNSPECIFIED
+	}
+}
+
+func (h *NotificationHandler) convertPriorityToProto(priority string) pb.NotificationPriority {
+	switch priority {
+	case database.PriorityLow:
+		return pb.NotificationPriority_NOTIFICATION_PRIORITY_LOW
+	case database.PriorityHigh:
+		return pb.NotificationPriority_NOTIFICATION_PRIORITY_HIGH
+	default:
+		return pb.NotificationPriority_NOTIFICATION_PRIORITY_NORMAL
+	}
+}
+
+func (h *NotificationHandler) convertPriorityToString(priority pb.NotificationPriority) string {
+	switch priority {
+	case pb.NotificationPriority_NOTIFICATION_PRIORITY_LOW:
+		return database.PriorityLow
+	case pb.NotificationPriority_NOTIFICATION_PRIORITY_HIGH:
+		return database.PriorityHigh
+	default:
+		return database.PriorityNormal
+	}
+}
+
+func (h *NotificationHandler) convertDigestFrequencyToString(frequency pb.DigestFrequency) string {
+	switch frequency {
+	case pb.DigestFrequency_DIGEST_FREQUENCY_HOURLY:
+		return database.DigestHourly
+	case pb.DigestFrequency_DIGEST_FREQUENCY_DAILY:
+		return database.DigestDaily
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"microservices-platform/services/notification-service/internal/database"
+)
+
+// NotificationFilter narrows a listing of a user's notifications
+type NotificationFilter struct {
+	UserID     string
+	Type       string
+	Channel    string
+	UnreadOnly bool
+}
+
+// NotificationRepository interface defines notification data operations
+type NotificationRepository interface {
+	Create(ctx context.Context, notifications []*database.Notification) error
+	GetByID(ctx context.Context, id string) (*database.Notification, error)
+	// List returns a page of a user's notifications, newest first, and how
+	// many match the filter
+	List(ctx context.Context, filter NotificationFilter, offset, limit int) ([]*database.Notification, int64, error)
+	// CountUnread counts a user's unread notifications
+	CountUnread(ctx context.Context, userID string) (int64, error)
+	Update(ctx context.Context, notification *database.Notification) error
+	Delete(ctx context.Context, id string) error
+	// DeleteByUserID deletes all of a user's notifications
+	DeleteByUserID(ctx context.Context, userID string) error
+	// ClaimRetries claims up to limit failed notifications that are due for
+	// another attempt, pushing their next attempt to leaseUntil so other
+	// instances skip them
+	ClaimRetries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*database.Notification, error)
+	// ListBatched returns a user's notifications waiting for a digest,
+	// oldest first
+	ListBatched(ctx context.Context, userID string) ([]*database.Notification, error)
+}
+
+// notificationRepository implements NotificationRepository interface
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{
+		db: db,
+	}
+}
+
+// Create creates notifications
+func (r *notificationRepository) Create(ctx context.Context, notifications []*database.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(&notifications).Error
+}
+
+// GetByID retrieves a notification by ID
+func (r *notificationRepository) GetByID(ctx context.Context, id string) (*database.Notification, error) {
+	var notification database.Notification
+	err := r.db.WithContext(ctx).First(&notification, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// List retrieves a user's notifications with filtering and pagination
+func (r *notificationRepository) List(ctx context.Context, filter NotificationFilter, offset, limit int) ([]*database.Notification, int64, error) {
+	var notifications []*database.Notification
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&database.Notification{}).Where("user_id = ?", filter.UserID)
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Channel != "" {
+		query = query.Where("channel = ?", filter.Channel)
+	}
+	if filter.UnreadOnly {
+		query = query.Where("read = ?", false)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&notifications).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return notifications, total, nil
+}
+
+// CountUnread counts a user's unread notifications
+func (r *notificationRepository) CountUnread(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&database.Notification{}).
+		Where("user_id = ? AND read = ?", userID, false).
+		Count(&count).Error
+	return count, err
+}
+
+// Update updates a notification
+func (r *notificationRepository) Update(ctx context.Context, notification *database.Notification) error {
+	return r.db.WithContext(ctx).Save(notification).Error
+}
+
+// Delete deletes a notification
+func (r *notificationRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&database.Notification{}, "id = ?", id).Error
+}
+
+// DeleteByUserID deletes all of a user's notifications
+func (r *notificationRepository) DeleteByUserID(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Delete(&database.Notification{}, "user_id = ?", userID).Error
+}
+
+// ClaimRetries claims due retries with row locks, skipping rows another
+// instance holds
+func (r *notificationRepository) ClaimRetries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*database.Notification, error) {
+	var notifications []*database.Notification
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", database.StatusFailed, now).
+			Order("next_attempt_at ASC").
+			Limit(limit).
+			Find(&notifications).Error
+		if err != nil || len(notifications) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(notifications))
+		for i, notification := range notifications {
+			ids[i] = notification.ID
+		}
+		return tx.Model(&database.Notification{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", leaseUntil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// ListBatched retrieves a user's notifications waiting for a digest
+func (r *notificationRepository) ListBatched(ctx context.Context, userID string) ([]*database.Notification, error) {
+	var notifications []*database.Notification
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND status = ?", userID, database.StatusBatched).
+		Order("created_at ASC").
+		Find(&notifications).Error
+	return notifications, err
+}
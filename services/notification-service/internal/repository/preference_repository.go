@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"microservices-platform/services/notification-service/internal/database"
+)
+
+// PreferenceRepository interface defines notification preference data
+// operations
+type PreferenceRepository interface {
+	// GetByUserID returns a user's preferences, or nil if they have none
+	GetByUserID(ctx context.Context, userID string) (*database.Preference, error)
+	// Upsert creates or replaces a user's preferences. A digest already
+	// scheduled stays scheduled.
+	Upsert(ctx context.Context, preference *database.Preference) error
+	// ScheduleDigest schedules the user's next digest at at, unless one is
+	// already scheduled
+	ScheduleDigest(ctx context.Context, userID string, at time.Time) error
+	// ClaimDueDigests claims up to limit users whose digest is due, pushing
+	// it to leaseUntil so other instances skip them
+	ClaimDueDigests(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*database.Preference, error)
+	// SetNextDigest sets or, with nil, clears when the user's next digest is
+	// sent
+	SetNextDigest(ctx context.Context, userID string, at *time.Time) error
+	DeleteByUserID(ctx context.Context, userID string) error
+}
+
+// preferenceRepository implements PreferenceRepository interface
+type preferenceRepository struct {
+	db *gorm.DB
+}
+
+// NewPreferenceRepository creates a new preference repository
+func NewPreferenceRepository(db *gorm.DB) PreferenceRepository {
+	return &preferenceRepository{
+		db: db,
+	}
+}
+
+// GetByUserID retrieves a user's preferences
+func (r *preferenceRepository) GetByUserID(ctx context.Context, userID string) (*database.Preference, error) {
+	var preference database.Preference
+	err := r.db.WithContext(ctx).First(&preference, "user_id = ?", userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// Upsert inserts a user's preferences or replaces the existing ones
+func (r *preferenceRepository) Upsert(ctx context.Context, preference *database.Preference) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"types", "channels", "settings", "digest_frequency", "digest_hour", "updated_at"}),
+		}).
+		Create(preference).Error
+}
+
+// ScheduleDigest sets the next digest if none is set
+func (r *preferenceRepository) ScheduleDigest(ctx context.Context, userID string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&database.Preference{}).
+		Where("user_id = ? AND next_digest_at IS NULL", userID).
+		Update("next_digest_at", at).Error
+}
+
+// ClaimDueDigests claims due digests with row locks, skipping rows another
+// instance holds
+func (r *preferenceRepository) ClaimDueDigests(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*database.Preference, error) {
+	var preferences []*database.Preference
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("next_digest_at <= ?", now).
+			Order("next_digest_at ASC").
+			Limit(limit).
+			Find(&preferences).Error
+		if err != nil || len(preferences) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(preferences))
+		for i, preference := range preferences {
+			ids[i] = preference.ID
+		}
+		return tx.Model(&database.Preference{}).
+			Where("id IN ?", ids).
+			Update("next_digest_at", leaseUntil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+// SetNextDigest sets when the user's next digest is sent
+func (r *preferenceRepository) SetNextDigest(ctx context.Context, userID string, at *time.Time) error {
+	return r.db.WithContext(ctx).Model(&database.Preference{}).
+		Where("user_id = ?", userID).
+		Update("next_digest_at", at).Error
+}
+
+// DeleteByUserID deletes a user's preferences
+func (r *preferenceRepository) DeleteByUserID(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Delete(&database.Preference{}, "user_id = ?", userID).Error
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"microservices-platform/pkg/i18n"
+	"microservices-platform/services/notification-service/internal/channels"
+	"microservices-platform/services/notification-service/internal/database"
+)
+
+// StartDelivery polls for failed deliveries and due digests. Any number of
+// instances can run it; work is claimed with row locks.
+func (s *notificationService) StartDelivery(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.retryDue(ctx); err != nil {
+				log.Printf("Failed to retry notifications: %v", err)
+			}
+			if err := s.sendDigests(ctx); err != nil {
+				log.Printf("Failed to send digests: %v", err)
+			}
+		}
+	}
+}
+
+// retryDue retries every failed delivery that is due
+func (s *notificationService) retryDue(ctx context.Context) error {
+	now := time.Now().UTC()
+	notifications, err := s.notificationRepo.ClaimRetries(ctx, now, now.Add(deliveryLease), deliveryBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, notification := range notifications {
+		preference, err := s.preferenceRepo.GetByUserID(ctx, notification.UserID)
+		if err != nil {
+			// The lease runs out and the retry is claimed again
+			log.Printf("Failed to load preferences of user %s: %v", notification.UserID, err)
+			continue
+		}
+		recipient, lookupErr := s.recipient(ctx, notification.UserID, preference, notification.Channel == channels.Email)
+		s.deliver(ctx, notification, recipient.Recipient, lookupErr)
+	}
+	return nil
+}
+
+// sendDigests sends the digests of every user whose digest is due
+func (s *notificationService) sendDigests(ctx context.Context) error {
+	now := time.Now().UTC()
+	preferences, err := s.preferenceRepo.ClaimDueDigests(ctx, now, now.Add(deliveryLease), deliveryBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, preference := range preferences {
+		if err := s.sendDigest(ctx, preference); err != nil {
+			log.Printf("Failed to send digest of user %s: %v", preference.UserID, err)
+		}
+	}
+	return nil
+}
+
+// sendDigest sends a user's batched notifications as one message per
+// channel. Failed digests leave their notifications failed, to be retried
+// one by one.
+func (s *notificationService) sendDigest(ctx context.Context, preference *database.Preference) error {
+	batched, err := s.notificationRepo.ListBatched(ctx, preference.UserID)
+	if err != nil {
+		return err
+	}
+
+	var order []string
+	byChannel := make(map[string][]*database.Notification)
+	for _, notification := range batched {
+		if _, ok := byChannel[notification.Channel]; !ok {
+			order = append(order, notification.Channel)
+		}
+		byChannel[notification.Channel] = append(byChannel[notification.Channel], notification)
+	}
+
+	recipient, lookupErr := s.recipient(ctx, preference.UserID, preference, byChannel[channels.Email] != nil)
+	for _, name := range order {
+		group := byChannel[name]
+		digestID := newDigestID()
+
+		start := time.Now()
+		channel, ok := s.channels.Get(name)
+		switch {
+		case !ok:
+			err = channels.Undeliverable("channel %s is not configured", name)
+		case lookupErr != nil && name == channels.Email:
+			err = lookupErr
+		default:
+			err = channel.Send(ctx, recipient.Recipient, digestMessage(ctx, digestID, group))
+		}
+		for _, notification := range group {
+			notification.DigestID = digestID
+			s.record(ctx, notification, err, start)
+		}
+	}
+
+	// Notifications batched while this digest was sent wait for the next one
+	if err := s.preferenceRepo.SetNextDigest(ctx, preference.UserID, nil); err != nil {
+		return err
+	}
+	remaining, err := s.notificationRepo.ListBatched(ctx, preference.UserID)
+	if err != nil {
+		return err
+	}
+	if len(remaining) > 0 {
+		return s.preferenceRepo.ScheduleDigest(ctx, preference.UserID, nextDigest(preference, time.Now().UTC()))
+	}
+	return nil
+}
+
+// digestMessage combines notifications into one message
+func digestMessage(ctx context.Context, digestID string, notifications []*database.Notification) channels.Message {
+	var body strings.Builder
+	for _, notification := range notifications {
+		fmt.Fprintf(&body, "- %s", notification.Title)
+		if notification.Message != "" {
+			fmt.Fprintf(&body, ": %s", notification.Message)
+		}
+		body.WriteString("\n")
+	}
+	return channels.Message{
+		ID:        digestID,
+		Type:      "digest",
+		Title:     i18n.T(ctx, "You have %d new notifications", len(notifications)),
+		Body:      body.String(),
+		Metadata:  map[string]string{"digest_id": digestID},
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// newDigestID generates a random digest ID
+func newDigestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("dig_%d", time.Now().UnixNano())
+	}
+	return "dig_" + hex.EncodeToString(b)
+}
+
+// nextDigest returns when a user's next digest is due after now. Users
+// without a digest frequency get theirs right away.
+func nextDigest(preference *database.Preference, now time.Time) time.Time {
+	switch preference.DigestFrequency {
+	case database.DigestHourly:
+		return now.Truncate(time.Hour).Add(time.Hour)
+	case database.DigestDaily:
+		next := time.Date(now.Year(), now.Month(), now.Day(), preference.DigestHour, 0, 0, 0, time.UTC)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	default:
+		return now
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/i18n"
+	"microservices-platform/services/notification-service/internal/database"
+)
+
+// HandleOrderCreated sends an order confirmation on the user's channels
+func (s *notificationService) HandleOrderCreated(ctx context.Context, event *events.Event) error {
+	userID, _ := event.Data["user_id"].(string)
+	orderID, _ := event.Data["order_id"].(string)
+	if userID == "" || orderID == "" {
+		return fmt.Errorf("malformed %s event %s", event.Type, event.ID)
+	}
+	total, _ := event.Data["total_amount"].(float64)
+
+	_, err := s.Send(ctx, SendRequest{
+		UserID:   userID,
+		Type:     database.TypeOrderConfirmation,
+		Title:    i18n.T(ctx, "Order confirmed"),
+		Message:  i18n.T(ctx, "Your order %s for %.2f has been placed.", orderID, total),
+		Metadata: map[string]string{"order_id": orderID},
+		Priority: database.PriorityHigh,
+	})
+	return err
+}
+
+// HandlePaymentProcessed sends a payment receipt on the user's channels
+func (s *notificationService) HandlePaymentProcessed(ctx context.Context, event *events.Event) error {
+	userID, _ := event.Data["user_id"].(string)
+	paymentID, _ := event.Data["payment_id"].(string)
+	if paymentID == "" {
+		paymentID = event.Subject
+	}
+	if userID == "" || paymentID == "" {
+		return fmt.Errorf("malformed %s event %s", event.Type, event.ID)
+	}
+	orderID, _ := event.Data["order_id"].(string)
+	amount, _ := event.Data["amount"].(float64)
+	currency, _ := event.Data["currency"].(string)
+
+	metadata := map[string]string{"payment_id": paymentID}
+	message := i18n.T(ctx, "We received your payment of %.2f %s.", amount, currency)
+	if orderID != "" {
+		metadata["order_id"] = orderID
+		message = i18n.T(ctx, "We received your payment of %.2f %s for order %s.", amount, currency, orderID)
+	}
+
+	_, err := s.Send(ctx, SendRequest{
+		UserID:   userID,
+		Type:     database.TypePaymentSuccess,
+		Title:    i18n.T(ctx, "Payment received"),
+		Message:  message,
+		Metadata: metadata,
+	})
+	return err
+}
@@ -0,0 +1,545 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/grpcclient"
+	"microservices-platform/pkg/metrics"
+	userpb "microservices-platform/pkg/proto/user/v1"
+	"microservices-platform/services/notification-service/internal/channels"
+	"microservices-platform/services/notification-service/internal/config"
+	"microservices-platform/services/notification-service/internal/database"
+	"microservices-platform/services/notification-service/internal/repository"
+)
+
+// deliveryBatchSize is how many retries or digests are claimed per poll
+const deliveryBatchSize = 100
+
+// deliveryLease is how long a claimed retry or digest is held by one instance
+const deliveryLease = 5 * time.Minute
+
+// maxRetryBackoff caps the wait between retries of a delivery
+const maxRetryBackoff = 6 * time.Hour
+
+// DefaultChannels are used for users who have not chosen channels
+var DefaultChannels = []string{channels.InApp, channels.Email}
+
+// essentialTypes are sent whatever types and channels the user subscribed
+// to, as they concern the account itself
+var essentialTypes = map[string]bool{
+	database.TypeAccountUpdate: true,
+	database.TypeSystemAlert:   true,
+}
+
+// marketingTypes are not sent to users who opted out of marketing
+var marketingTypes = map[string]bool{
+	database.TypePromotional:  true,
+	database.TypeCartReminder: true,
+}
+
+// ErrNotificationNotFound is returned for a notification that does not exist
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// SendRequest is a notification to send to a user
+type SendRequest struct {
+	UserID  string
+	Type    string
+	Title   string
+	Message string
+	// Channels to send on; empty uses the user's subscribed channels
+	Channels []string
+	Metadata map[string]string
+	Priority string
+	// Immediate skips the user's digest
+	Immediate bool
+}
+
+// SubscribeRequest sets a user's notification preferences
+type SubscribeRequest struct {
+	UserID string
+	// Types the user wants; empty is every type
+	Types []string
+	// Channels the user wants; empty is DefaultChannels
+	Channels []string
+	// Settings holds the user's addresses, see the channels.Setting* keys
+	Settings        map[string]string
+	DigestFrequency string
+	DigestHour      int
+}
+
+// NotificationService interface defines notification operations
+type NotificationService interface {
+	// Send stores a notification for each channel and delivers it, or
+	// batches it for the user's digest. Channels that fail are retried in
+	// the background; the returned notifications carry their status.
+	Send(ctx context.Context, req SendRequest) ([]*database.Notification, error)
+	GetNotification(ctx context.Context, id string) (*database.Notification, error)
+	// ListNotifications returns a page of a user's notifications, how many
+	// match the filter and how many of the user's are unread
+	ListNotifications(ctx context.Context, filter repository.NotificationFilter, page, pageSize int) ([]*database.Notification, int64, int64, error)
+	MarkAsRead(ctx context.Context, id string) (*database.Notification, error)
+	DeleteNotification(ctx context.Context, id string) error
+	Subscribe(ctx context.Context, req SubscribeRequest) (*database.Preference, error)
+	// DeleteUserData deletes a user's notifications and preferences
+	DeleteUserData(ctx context.Context, userID string) error
+	// HandleOrderCreated confirms a new order to its user
+	HandleOrderCreated(ctx context.Context, event *events.Event) error
+	// HandlePaymentProcessed confirms a payment to its user
+	HandlePaymentProcessed(ctx context.Context, event *events.Event) error
+	// StartDelivery retries failed deliveries and sends due digests until
+	// ctx is cancelled
+	StartDelivery(ctx context.Context)
+}
+
+// notificationService implements NotificationService interface
+type notificationService struct {
+	notificationRepo repository.NotificationRepository
+	preferenceRepo   repository.PreferenceRepository
+	channels         *channels.Registry
+	eventBus         events.EventBus
+	userClient       userpb.UserServiceClient
+	cfg              config.DeliveryConfig
+}
+
+// NewNotificationService creates a new notification service delivering
+// through the channels in registry
+func NewNotificationService(notificationRepo repository.NotificationRepository, preferenceRepo repository.PreferenceRepository, registry *channels.Registry, eventBus events.EventBus, cfg *config.Config) NotificationService {
+	userConn, err := grpcclient.Dial(cfg.UserServiceURL, cfg.GRPCClient)
+	if err != nil {
+		log.Printf("Failed to connect to user service: %v", err)
+	}
+
+	return &notificationService{
+		notificationRepo: notificationRepo,
+		preferenceRepo:   preferenceRepo,
+		channels:         registry,
+		eventBus:         eventBus,
+		userClient:       userpb.NewUserServiceClient(userConn),
+		cfg:              cfg.Delivery,
+	}
+}
+
+// Send sends a notification on each of its channels
+func (s *notificationService) Send(ctx context.Context, req SendRequest) ([]*database.Notification, error) {
+	if req.UserID == "" {
+		return nil, errors.New("user is required")
+	}
+	if req.Title == "" && req.Message == "" {
+		return nil, errors.New("title or message is required")
+	}
+	if req.Type == "" {
+		return nil, errors.New("notification type is required")
+	}
+	if !validType(req.Type) {
+		return nil, fmt.Errorf("invalid notification type %q", req.Type)
+	}
+	for _, channel := range req.Channels {
+		if !validChannel(channel) {
+			return nil, fmt.Errorf("invalid channel %q", channel)
+		}
+	}
+	if req.Priority == "" {
+		req.Priority = database.PriorityNormal
+	}
+
+	preference, err := s.preferenceRepo.GetByUserID(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	essential := essentialTypes[req.Type]
+	if preference != nil && preference.Types != "" && !essential && !contains(splitList(preference.Types), req.Type) {
+		metrics.RecordNotificationDelivery("", req.Type, "skipped", 0)
+		return nil, nil
+	}
+	targets := s.targetChannels(req, preference, essential)
+
+	recipient, lookupErr := s.recipient(ctx, req.UserID, preference, contains(targets, channels.Email) || marketingTypes[req.Type])
+	if lookupErr == nil && recipient.optedOut && marketingTypes[req.Type] {
+		metrics.RecordNotificationDelivery("", req.Type, "skipped", 0)
+		return nil, nil
+	}
+
+	metadata, err := json.Marshal(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	batch := !req.Immediate && req.Priority == database.PriorityLow &&
+		preference != nil && preference.DigestFrequency != ""
+
+	notifications := make([]*database.Notification, len(targets))
+	for i, channel := range targets {
+		notifications[i] = &database.Notification{
+			UserID:   req.UserID,
+			Type:     req.Type,
+			Channel:  channel,
+			Priority: req.Priority,
+			Title:    req.Title,
+			Message:  req.Message,
+			Metadata: string(metadata),
+			Status:   database.StatusPending,
+		}
+		// The inbox is not batched; the digest replaces the other channels'
+		// messages
+		if batch && channel != channels.InApp {
+			notifications[i].Status = database.StatusBatched
+		}
+	}
+	if err := s.notificationRepo.Create(ctx, notifications); err != nil {
+		return nil, err
+	}
+
+	for _, notification := range notifications {
+		if notification.Status == database.StatusBatched {
+			metrics.RecordNotificationDelivery(notification.Channel, notification.Type, database.StatusBatched, 0)
+			continue
+		}
+		s.deliver(ctx, notification, recipient.Recipient, lookupErr)
+	}
+	if batch {
+		if err := s.preferenceRepo.ScheduleDigest(ctx, req.UserID, nextDigest(preference, time.Now().UTC())); err != nil {
+			log.Printf("Failed to schedule digest for user %s: %v", req.UserID, err)
+		}
+	}
+
+	return notifications, nil
+}
+
+// targetChannels returns the channels a notification goes out on. Requested
+// channels the user did not subscribe to are dropped unless the type is
+// essential; the inbox is used if nothing is left.
+func (s *notificationService) targetChannels(req SendRequest, preference *database.Preference, essential bool) []string {
+	var subscribed []string
+	if preference != nil {
+		subscribed = splitList(preference.Channels)
+	}
+
+	var targets []string
+	switch {
+	case len(req.Channels) == 0 && len(subscribed) > 0:
+		targets = subscribed
+	case len(req.Channels) == 0:
+		targets = DefaultChannels
+	case essential || len(subscribed) == 0:
+		targets = req.Channels
+	default:
+		for _, channel := range req.Channels {
+			if contains(subscribed, channel) {
+				targets = append(targets, channel)
+			}
+		}
+	}
+
+	var unique []string
+	for _, channel := range targets {
+		if !contains(unique, channel) {
+			unique = append(unique, channel)
+		}
+	}
+	if len(unique) == 0 {
+		unique = []string{channels.InApp}
+	}
+	return unique
+}
+
+// resolvedRecipient is a recipient with what the user service said about
+// them
+type resolvedRecipient struct {
+	channels.Recipient
+	optedOut bool
+}
+
+// recipient returns the addresses of a user from their settings. With
+// lookup, the user service is asked for their email address, unless they
+// set one, and whether they opted out of marketing.
+func (s *notificationService) recipient(ctx context.Context, userID string, preference *database.Preference, lookup bool) (resolvedRecipient, error) {
+	recipient := resolvedRecipient{Recipient: channels.Recipient{UserID: userID, Settings: map[string]string{}}}
+	if preference != nil && preference.Settings != "" {
+		if err := json.Unmarshal([]byte(preference.Settings), &recipient.Settings); err != nil {
+			log.Printf("Invalid notification settings of user %s: %v", userID, err)
+		}
+	}
+	if !lookup {
+		return recipient, nil
+	}
+
+	resp, err := s.userClient.GetUser(ctx, &userpb.GetUserRequest{UserId: userID})
+	if err != nil {
+		return recipient, fmt.Errorf("failed to look up user: %v", err)
+	}
+	if recipient.Settings[channels.SettingEmail] == "" {
+		recipient.Settings[channels.SettingEmail] = resp.User.GetEmail()
+	}
+	recipient.optedOut = resp.User.GetMarketingOptOut()
+	return recipient, nil
+}
+
+// deliver sends a notification on its channel and records the outcome.
+// lookupErr is why the user's email address could not be looked up.
+func (s *notificationService) deliver(ctx context.Context, notification *database.Notification, to channels.Recipient, lookupErr error) {
+	start := time.Now()
+	var err error
+	channel, ok := s.channels.Get(notification.Channel)
+	switch {
+	case !ok:
+		err = channels.Undeliverable("channel %s is not configured", notification.Channel)
+	case lookupErr != nil && notification.Channel == channels.Email:
+		err = lookupErr
+	default:
+		err = channel.Send(ctx, to, message(notification))
+	}
+	s.record(ctx, notification, err, start)
+}
+
+// record saves the outcome of a delivery attempt, scheduling a retry with
+// exponential backoff if the attempt failed and may succeed later
+func (s *notificationService) record(ctx context.Context, notification *database.Notification, err error, start time.Time) {
+	now := time.Now().UTC()
+	notification.Attempts++
+	notification.NextAttemptAt = nil
+	if err == nil {
+		notification.Status = database.StatusSent
+		if notification.Channel == channels.InApp {
+			notification.Status = database.StatusDelivered
+		}
+		notification.Error = ""
+		notification.SentAt = &now
+	} else {
+		notification.Status = database.StatusFailed
+		notification.Error = err.Error()
+		if !errors.Is(err, channels.ErrUndeliverable) && notification.Attempts < s.cfg.MaxAttempts {
+			next := now.Add(s.backoff(notification.Attempts))
+			notification.NextAttemptAt = &next
+		}
+		log.Printf("Failed to deliver notification %s on %s (attempt %d): %v", notification.ID, notification.Channel, notification.Attempts, err)
+	}
+	metrics.RecordNotificationDelivery(notification.Channel, notification.Type, notification.Status, time.Since(start))
+
+	if err := s.notificationRepo.Update(ctx, notification); err != nil {
+		log.Printf("Failed to record delivery of notification %s: %v", notification.ID, err)
+		return
+	}
+	if notification.Status != database.StatusFailed {
+		s.publishSent(ctx, notification)
+	}
+}
+
+// backoff returns the wait before the retry after attempts
+func (s *notificationService) backoff(attempts int) time.Duration {
+	wait := s.cfg.RetryBackoff
+	for i := 1; i < attempts && wait < maxRetryBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxRetryBackoff)
+}
+
+// publishSent publishes notification.sent. The delivery is already
+// recorded, so a failure is only logged.
+func (s *notificationService) publishSent(ctx context.Context, notification *database.Notification) {
+	err := s.eventBus.Publish(ctx, &events.Event{
+		Type:    events.NotificationSent,
+		Source:  "notification-service",
+		Subject: notification.ID,
+		Data: map[string]interface{}{
+			"notification_id": notification.ID,
+			"user_id":         notification.UserID,
+			"type":            notification.Type,
+			"channel":         notification.Channel,
+			"digest_id":       notification.DigestID,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to publish notification.sent for %s: %v", notification.ID, err)
+	}
+}
+
+// GetNotification retrieves a notification
+func (s *notificationService) GetNotification(ctx context.Context, id string) (*database.Notification, error) {
+	notification, err := s.notificationRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if notification == nil {
+		return nil, ErrNotificationNotFound
+	}
+	return notification, nil
+}
+
+// ListNotifications lists a user's notifications
+func (s *notificationService) ListNotifications(ctx context.Context, filter repository.NotificationFilter, page, pageSize int) ([]*database.Notification, int64, int64, error) {
+	if filter.UserID == "" {
+		return nil, 0, 0, errors.New("user is required")
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	notifications, total, err := s.notificationRepo.List(ctx, filter, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	unread, err := s.notificationRepo.CountUnread(ctx, filter.UserID)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return notifications, total, unread, nil
+}
+
+// MarkAsRead marks a notification as read
+func (s *notificationService) MarkAsRead(ctx context.Context, id string) (*database.Notification, error) {
+	notification, err := s.GetNotification(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if notification.Read {
+		return notification, nil
+	}
+
+	now := time.Now().UTC()
+	notification.Read = true
+	notification.ReadAt = &now
+	if err := s.notificationRepo.Update(ctx, notification); err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
+
+// DeleteNotification deletes a notification
+func (s *notificationService) DeleteNotification(ctx context.Context, id string) error {
+	if _, err := s.GetNotification(ctx, id); err != nil {
+		return err
+	}
+	return s.notificationRepo.Delete(ctx, id)
+}
+
+// Subscribe replaces a user's notification preferences
+func (s *notificationService) Subscribe(ctx context.Context, req SubscribeRequest) (*database.Preference, error) {
+	if req.UserID == "" {
+		return nil, errors.New("user is required")
+	}
+	for _, notificationType := range req.Types {
+		if !validType(notificationType) {
+			return nil, fmt.Errorf("invalid notification type %q", notificationType)
+		}
+	}
+	for _, channel := range req.Channels {
+		if !validChannel(channel) {
+			return nil, fmt.Errorf("invalid channel %q", channel)
+		}
+	}
+	switch req.DigestFrequency {
+	case "", database.DigestHourly, database.DigestDaily:
+	default:
+		return nil, fmt.Errorf("invalid digest frequency %q", req.DigestFrequency)
+	}
+	if req.DigestHour < 0 || req.DigestHour > 23 {
+		return nil, errors.New("digest hour must be between 0 and 23")
+	}
+	if phone := req.Settings[channels.SettingPhone]; phone != "" && !strings.HasPrefix(phone, "+") {
+		return nil, errors.New("phone must be in E.164 format, e.g. +15550000000")
+	}
+
+	settings, err := json.Marshal(req.Settings)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.preferenceRepo.GetByUserID(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	preference := &database.Preference{
+		UserID:          req.UserID,
+		Types:           strings.Join(req.Types, ","),
+		Channels:        strings.Join(req.Channels, ","),
+		Settings:        string(settings),
+		DigestFrequency: req.DigestFrequency,
+		DigestHour:      req.DigestHour,
+	}
+	if err := s.preferenceRepo.Upsert(ctx, preference); err != nil {
+		return nil, err
+	}
+
+	// Notifications waiting for a digest the user turned off are sent now
+	if existing != nil && existing.NextDigestAt != nil && req.DigestFrequency == "" {
+		now := time.Now().UTC()
+		if err := s.preferenceRepo.SetNextDigest(ctx, req.UserID, &now); err != nil {
+			log.Printf("Failed to flush digest of user %s: %v", req.UserID, err)
+		}
+	}
+
+	return s.preferenceRepo.GetByUserID(ctx, req.UserID)
+}
+
+// DeleteUserData deletes a user's notifications and preferences
+func (s *notificationService) DeleteUserData(ctx context.Context, userID string) error {
+	if err := s.notificationRepo.DeleteByUserID(ctx, userID); err != nil {
+		return err
+	}
+	return s.preferenceRepo.DeleteByUserID(ctx, userID)
+}
+
+// message converts a notification to the message channels deliver
+func message(notification *database.Notification) channels.Message {
+	var metadata map[string]string
+	if notification.Metadata != "" {
+		json.Unmarshal([]byte(notification.Metadata), &metadata)
+	}
+	return channels.Message{
+		ID:        notification.ID,
+		Type:      notification.Type,
+		Title:     notification.Title,
+		Body:      notification.Message,
+		Metadata:  metadata,
+		CreatedAt: notification.CreatedAt,
+	}
+}
+
+func validType(notificationType string) bool {
+	switch notificationType {
+	case database.TypeOrderConfirmation, database.TypeOrderShipped, database.TypeOrderDelivered,
+		database.TypePaymentSuccess, database.TypePaymentFailed, database.TypeAccountUpdate,
+		database.TypePromotional, database.TypeSystemAlert, database.TypeCartReminder:
+		return true
+	}
+	return false
+}
+
+func validChannel(channel string) bool {
+	switch channel {
+	case channels.Email, channels.SMS, channels.Push, channels.InApp, channels.Webhook:
+		return true
+	}
+	return false
+}
+
+// splitList splits a comma-separated column
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func contains(items []string, item string) bool {
+	for _, candidate := range items {
+		if candidate == item {
+			return true
+		}
+	}
+	return false
+}
@@ -6,11 +6,10 @@ import (
 	"log"
 	"net"
 	"os"
-	"os/signal"
-	"syscall"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
+	"gorm.io/gorm"
 	
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
@@ -19,6 +18,22 @@ import (
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 
+	"microservices-platform/pkg/app"
+	"microservices-platform/pkg/buildinfo"
+	"microservices-platform/pkg/cache"
+	"microservices-platform/pkg/dbhealth"
+	"microservices-platform/pkg/debug"
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/grpcclient"
+	"microservices-platform/pkg/grpcserver"
+	"microservices-platform/pkg/instrumentation"
+	"microservices-platform/pkg/ledger"
+	"microservices-platform/pkg/logging"
+	"microservices-platform/pkg/middleware"
+	"microservices-platform/pkg/schemadrift"
+	"microservices-platform/pkg/startup"
+	"microservices-platform/pkg/storage"
+	"microservices-platform/pkg/watchdog"
 	"microservices-platform/services/order-service/internal/config"
 	"microservices-platform/services/order-service/internal/database"
 	"microservices-platform/services/order-service/internal/handler"
@@ -31,66 +46,325 @@ func main() {
 	// Initialize configuration
 	cfg := config.Load()
 
+	// Compare the database schema with the models and exit, e.g. in CI
+	if len(os.Args) > 1 && os.Args[1] == schemadrift.Command {
+		os.Exit(schemadrift.Main(cfg.ServiceName, cfg.DatabaseURL, cfg.SchemaDrift, database.Models()...))
+	}
+
+	// Filter logs by level and rate limit repeated errors
+	if err := logging.Setup(cfg.Logging); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+
+	// Announce the build and report it in build_info
+	buildinfo.LogStartup(cfg.ServiceName)
+
+	// Refuse debug facilities in production unless explicitly allowed
+	if err := cfg.Debug.Check(cfg.Environment); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+
+	// Run the service through init, migrate, serve, drain and shutdown.
+	// Resources are released in the reverse order they are registered.
+	application := app.New(cfg.ServiceName, cfg.App)
+
 	// Initialize OpenTelemetry
 	tp, err := initTracer(cfg.ServiceName)
 	if err != nil {
 		log.Fatalf("Failed to initialize tracer: %v", err)
 	}
-	defer func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
+	application.OnShutdown("tracer", tp.Shutdown)
+
+	// Continuously profile CPU and heap when enabled
+	profiler, err := instrumentation.StartProfiling(cfg.ServiceName, cfg.Observability)
+	if err != nil {
+		log.Fatalf("Failed to start profiling: %v", err)
+	}
+	application.OnShutdown("profiler", func(ctx context.Context) error {
+		profiler.Stop()
+		return nil
+	})
+
+	// Connect to dependencies in order, retrying until they are available.
+	// Readiness reports "starting" until the gRPC server is listening.
+	starter := startup.NewManager(cfg.Startup)
+
+	// Serve metrics, health, build info and the admin and debug endpoints on
+	// the internal observability port
+	application.Handle("/health/", starter.Handler())
+	application.Handle(buildinfo.Path, buildinfo.Handler(cfg.ServiceName))
+	if cfg.Logging.AdminToken != "" {
+		application.Handle(logging.AdminPath, logging.Handler(cfg.Logging.AdminToken))
+	}
+	if cfg.Debug.Pprof {
+		application.Handle(debug.PprofPath, cfg.Debug.PprofHandler())
+	}
+	if cfg.CacheAdmin.Token != "" {
+		cacheAdmin := cache.NewAdmin(cfg.ServiceName, cfg.CacheAdmin)
+		application.Handle(cache.AdminPath, cacheAdmin.Handler())
+		application.OnServe("cache-admin", func(ctx context.Context) error {
+			cacheAdmin.Start(ctx)
+			return nil
+		})
+	}
+	if err := application.ServeObservability(); err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+
+	var db *gorm.DB
+	starter.Require("postgres", func(ctx context.Context) error {
+		conn, err := database.NewConnection(cfg.DatabaseURL, cfg.Debug.VerboseSQL)
+		if err != nil {
+			return err
+		}
+		// Trace every query and explain slow ones
+		if err := conn.Use(instrumentation.NewQueryTracer(cfg.ServiceName, cfg.QueryTracing)); err != nil {
+			return err
 		}
-	}()
+		db = conn
+		return nil
+	})
 
-	// Initialize database
-	db, err := database.NewConnection(cfg.DatabaseURL)
+	// Object storage for order archives when a bucket is configured. Without
+	// it, orders are archived to the database, and archives already in
+	// object storage cannot be restored until it is back.
+	var archiveStore storage.ObjectStore
+	if cfg.Archive.StorageBucket != "" {
+		starter.Optional("object-storage", func(ctx context.Context) error {
+			store, err := storage.NewS3Store(ctx, storage.Config{
+				Endpoint:  cfg.Archive.StorageEndpoint,
+				AccessKey: cfg.Archive.StorageAccessKey,
+				SecretKey: cfg.Archive.StorageSecretKey,
+				Bucket:    cfg.Archive.StorageBucket,
+				UseSSL:    cfg.Archive.StorageUseSSL,
+			})
+			if err != nil {
+				return err
+			}
+			archiveStore = store
+			return nil
+		})
+	}
+
+	// Event bus for taking part in the user deletion saga. Redis is optional
+	// while degradation is enabled.
+	dialEventBus := events.RedisDialer(cfg.Redis, cfg.EventBus)
+	var redisBus events.EventBus
+	connectEventBus := func(ctx context.Context) error {
+		bus, err := dialEventBus()
+		if err != nil {
+			return err
+		}
+		redisBus = bus
+		return nil
+	}
+	if cfg.Degradation.Enabled {
+		starter.Optional("redis", connectEventBus)
+	} else {
+		starter.Require("redis", connectEventBus)
+	}
+
+	application.OnInit("dependencies", starter.Run)
+	application.OnMigrate("postgres", func(ctx context.Context) error {
+		if err := database.Migrate(db); err != nil {
+			return err
+		}
+		if err := schemadrift.Record(db, cfg.ServiceName, database.Models()...); err != nil {
+			return err
+		}
+		return schemadrift.Verify(ctx, db, cfg.ServiceName, cfg.SchemaDrift, database.Models()...)
+	})
+	if err := application.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+
+	// While Redis is unreachable, events are buffered in the local outbox and
+	// published once it recovers; readiness reports "degraded" meanwhile
+	outbox, err := events.NewOutbox(events.OutboxConfig{
+		Service: cfg.ServiceName,
+		MaxSize: cfg.Degradation.OutboxSize,
+		Policy:  events.OverflowPolicy(cfg.Degradation.OutboxOverflowPolicy),
+		Path:    cfg.Degradation.OutboxPath,
+	})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to open event outbox: %v", err)
 	}
+	eventBus := events.NewDegradingEventBus(redisBus, dialEventBus, outbox, cfg.Degradation.RetryInterval)
+	eventBus.OnChange(func(err error) {
+		starter.Report("redis", err)
+	})
+
+	// Publish events from request paths in the background, so Redis latency
+	// does not add to theirs; events it cannot keep up with go to the outbox
+	publisher := events.NewAsyncEventBus(eventBus, outbox, cfg.EventBus)
 
-	// Initialize repository
+	// Watch the database connection and re-dial after a restart or failover
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to get database handle: %v", err)
+	}
+	application.OnShutdown("postgres", func(ctx context.Context) error {
+		return sqlDB.Close()
+	})
+	dbMonitor := dbhealth.NewMonitor(cfg.ServiceName, "postgres", sqlDB, cfg.Database, eventBus)
+	dbMonitor.OnChange(func(err error) {
+		starter.Report("postgres", err)
+	})
+	dbMonitor.Start(context.Background())
+
+	// Watch for leaked goroutines, file descriptors and pooled connections
+	leaks := watchdog.New(cfg.ServiceName, cfg.Watchdog)
+	leaks.WatchDB("postgres", sqlDB)
+	leaks.Start(context.Background())
+
+	// Initialize repositories
 	orderRepo := repository.NewOrderRepository(db)
+	cartRepo := repository.NewCartRepository(db)
 
-	// Initialize service
-	orderService := service.NewOrderService(orderRepo, cfg)
+	// Initialize services
+	etaEstimator, err := service.NewETAEstimator(cfg.ETA)
+	if err != nil {
+		log.Fatalf("Invalid delivery estimate configuration: %v", err)
+	}
+	// Archive old finished orders and restore them when they are accessed
+	archiveService := service.NewArchiveService(repository.NewArchiveRepository(db), orderRepo, archiveStore, cfg.Archive)
+	if cfg.Archive.Enabled {
+		application.OnServe("order-archival", func(ctx context.Context) error {
+			archiveService.Start(ctx)
+			return nil
+		})
+	}
+	// Cache the first pages of users' order lists, invalidated by order
+	// events. Redis errors are misses while degradation is enabled.
+	var listCache *service.OrderListCache
+	if cfg.ListCache.Enabled {
+		if listStore, err := cache.New(cfg.Redis, cfg.ServiceName, service.ListCacheName, cfg.Degradation.Enabled); err != nil {
+			log.Printf("Order list cache unavailable, listing from the database: %v", err)
+		} else {
+			listCache = service.NewOrderListCache(listStore, cfg.ListCache)
+		}
+	}
+	orderService := service.NewOrderService(orderRepo, archiveService, listCache, publisher, etaEstimator, cfg)
+	cartService := service.NewCartService(cartRepo, publisher, cfg)
+
+	// Recompute order totals nightly, checking captured amounts against
+	// payment-service's ledger when it is configured
+	var ledgerDB *ledger.Ledger
+	if cfg.TotalsAudit.LedgerDatabaseURL != "" {
+		if ledgerDB, err = ledger.Open(cfg.TotalsAudit.LedgerDatabaseURL); err != nil {
+			log.Printf("Ledger database unavailable, captured amounts are not audited: %v", err)
+		}
+	}
+	auditService := service.NewTotalsAuditService(repository.NewAuditRepository(db), ledgerDB, eventBus, cfg.TotalsAudit)
+	if cfg.TotalsAudit.Enabled {
+		application.OnServe("totals-audit", func(ctx context.Context) error {
+			auditService.Start(ctx)
+			return nil
+		})
+	}
+
+	// Deduplicate redelivered events so each consumer handles an event once
+	var idempotency events.IdempotencyStore
+	if store, err := events.NewRedisIdempotencyStore(cfg.Redis); err != nil {
+		log.Printf("Idempotency store unavailable, redelivered events may be handled twice: %v", err)
+	} else {
+		idempotency = store
+	}
+
+	// Take part in the user deletion saga
+	eventBus.Subscribe(events.UserDeletionRequested, events.Idempotent(idempotency, cfg.ServiceName, events.DefaultIdempotencyTTL,
+		events.DeletionParticipant(eventBus, cfg.ServiceName, orderService.AnonymizeUserOrders)))
+
+	// Detect abandoned carts from scheduled idle checks and send reminders
+	eventBus.Subscribe(events.CartIdleCheck, events.Idempotent(idempotency, cfg.ServiceName+":cart-idle-check", events.DefaultIdempotencyTTL,
+		cartService.HandleIdleCheck))
+	eventBus.Subscribe(events.CartAbandoned, events.Idempotent(idempotency, cfg.ServiceName+":cart-reminders", events.DefaultIdempotencyTTL,
+		cartService.HandleAbandoned))
+
+	// Drop users' cached order lists when their orders change. Every
+	// instance receives the events; the cache is shared, so deleting twice
+	// is harmless.
+	if listCache != nil {
+		eventBus.Subscribe(events.OrderCreated, listCache.HandleOrderChanged)
+		eventBus.Subscribe(events.OrderStatusChanged, listCache.HandleOrderChanged)
+	}
+
+	// Revise delivery estimates from carrier tracking updates
+	eventBus.Subscribe(events.ShipmentTrackingUpdated, events.Idempotent(idempotency, cfg.ServiceName+":shipment-tracking", events.DefaultIdempotencyTTL,
+		orderService.HandleShipmentTracking))
+
+	// Confirm orders when their payment is authorized, and record lapsed
+	// authorizations
+	eventBus.Subscribe(events.PaymentAuthorized, events.Idempotent(idempotency, cfg.ServiceName+":payment-authorized", events.DefaultIdempotencyTTL,
+		orderService.HandlePaymentAuthorized))
+	eventBus.Subscribe(events.PaymentAuthorizationExpired, events.Idempotent(idempotency, cfg.ServiceName+":authorization-expired", events.DefaultIdempotencyTTL,
+		orderService.HandleAuthorizationExpired))
+	if err := eventBus.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start event bus: %v", err)
+	}
+	application.OnShutdown("event-bus", func(ctx context.Context) error {
+		return eventBus.Stop()
+	})
+	// Shutdown hooks run in reverse, so queued events are published before
+	// the bus stops
+	application.OnShutdown("event-publisher", publisher.Close)
 
 	// Initialize gRPC handler
-	orderHandler := handler.NewOrderHandler(orderService)
+	orderHandler := handler.NewOrderHandler(orderService, cartService, auditService, archiveService)
 
-	// Create gRPC server with OpenTelemetry interceptors
-	server := grpc.NewServer(
-		grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
-		grpc.StreamInterceptor(otelgrpc.StreamServerInterceptor()),
-	)
+	// Create gRPC server with OpenTelemetry interceptors, verifying the
+	// caller identity signed by the gateway when a secret is configured
+	unaryInterceptors := []grpc.UnaryServerInterceptor{otelgrpc.UnaryServerInterceptor()}
+	streamInterceptors := []grpc.StreamServerInterceptor{otelgrpc.StreamServerInterceptor()}
+	if cfg.Identity.Secret != "" {
+		unaryInterceptors = append(unaryInterceptors, middleware.IdentityUnaryInterceptor(cfg.Identity.Secret))
+		streamInterceptors = append(streamInterceptors, middleware.IdentityStreamInterceptor(cfg.Identity.Secret))
+	}
+	serverOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+		// Accept the keepalive pings of other services' clients
+		grpcclient.KeepaliveEnforcementPolicy(cfg.GRPCClient),
+		// Recycle connections so clients spread over new instances
+		grpcserver.KeepaliveParams(cfg.GRPCServer),
+	}
+	// Limit message sizes, compress large responses and record their sizes
+	serverOptions = append(serverOptions, grpcserver.MessageOptions(cfg.GRPCServer)...)
+	server := grpc.NewServer(serverOptions...)
 
 	// Register service
 	pb.RegisterOrderServiceServer(server, orderHandler)
+	// Report serving status to health-checking clients
+	healthServer := grpcserver.RegisterHealth(server)
+	// Serve the build to gRPC clients, as /version does over HTTP
+	grpcserver.RegisterBuildInfo(server, cfg.ServiceName)
 
 	// Enable reflection for debugging
-	reflection.Register(server)
+	if cfg.Debug.GRPCReflection {
+		reflection.Register(server)
+	}
 
 	// Create listener
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", cfg.Port))
 	if err != nil {
 		log.Fatalf("Failed to listen on port %s: %v", cfg.Port, err)
 	}
+	starter.MarkReady()
 
-	// Graceful shutdown
-	go func() {
+	application.OnServe("grpc", func(ctx context.Context) error {
 		log.Printf("Order service starting on port %s", cfg.Port)
-		if err := server.Serve(lis); err != nil {
-			log.Fatalf("Failed to serve: %v", err)
-		}
-	}()
-
-	// Wait for interrupt signal to gracefully shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+		return server.Serve(lis)
+	})
+	application.OnDrain("grpc", func(ctx context.Context) error {
+		starter.MarkDraining()
+		healthServer.Shutdown()
+		grpcserver.Drain(server, cfg.GRPCServer)
+		return nil
+	})
 
-	log.Println("Shutting down order service...")
-	server.GracefulStop()
-	log.Println("Order service stopped")
+	if err := application.Run(); err != nil {
+		log.Fatalf("Failed to serve: %v", err)
+	}
 }
 
 // initTracer creates and configures OpenTelemetry tracer
@@ -103,12 +377,15 @@ func initTracer(serviceName string) (*tracesdk.TracerProvider, error) {
 
 	tp := tracesdk.NewTracerProvider(
 		tracesdk.WithBatcher(exp),
+		// Record business baggage, e.g. the tenant, on every span
+		tracesdk.WithSpanProcessor(instrumentation.NewBaggageSpanProcessor()),
 		tracesdk.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(serviceName),
+			buildinfo.ResourceAttributes(serviceName)...,
 		)),
 	)
 
 	otel.SetTracerProvider(tp)
+	instrumentation.SetupPropagation()
 	return tp, nil
 }
\ No newline at end of file
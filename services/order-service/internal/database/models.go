@@ -8,17 +8,17 @@ import (
 	"gorm.io/gorm/logger"
 )
 
-// NewConnection creates a new database connection
-func NewConnection(databaseURL string) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
-	if err != nil {
-		return nil, err
+// NewConnection creates a new database connection. verbose logs every SQL
+// statement; otherwise only slow queries and errors are logged.
+func NewConnection(databaseURL string, verbose bool) (*gorm.DB, error) {
+	logLevel := logger.Warn
+	if verbose {
+		logLevel = logger.Info
 	}
 
-	// Auto-migrate models
-	err = db.AutoMigrate(&Order{}, &OrderItem{})
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -26,6 +26,17 @@ func NewConnection(databaseURL string) (*gorm.DB, error) {
 	return db, nil
 }
 
+// Migrate brings the schema up to date with the models
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(Models()...)
+}
+
+// Models lists the models migrated into the database, which the schema
+// drift check compares it against
+func Models() []interface{} {
+	return []interface{}{&Order{}, &OrderItem{}, &Cart{}, &CartItem{}, &Shipment{}, &ShipmentItem{}, &TotalsAuditRun{}, &TotalsDivergence{}, &OrderArchive{}}
+}
+
 // Order model
 type Order struct {
 	ID              string      `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
@@ -37,6 +48,122 @@ type Order struct {
 	BillingAddress  string      `gorm:"not null"`
 	CreatedAt       time.Time   `gorm:"autoCreateTime"`
 	UpdatedAt       time.Time   `gorm:"autoUpdateTime"`
+
+	// Saved address references; ShippingAddress/BillingAddress hold a snapshot
+	// of the referenced address as it was when the order was placed
+	ShippingAddressID string
+	BillingAddressID  string
+
+	// Organization the order was placed for, if any; its members can all see it
+	OrganizationID string `gorm:"index"`
+
+	// Version is incremented by every update and served as the order's ETag
+	Version int64 `gorm:"not null;default:1"`
+
+	// Delivery estimate, made when the order is placed and revised by
+	// shipment tracking events. EstimateSource is "rules", "carrier" or
+	// "tracking".
+	ShippingMethod            string
+	EstimatedDeliveryEarliest *time.Time
+	EstimatedDeliveryLatest   *time.Time
+	EstimateSource            string
+	Carrier                   string
+	TrackingNumber            string
+	DeliveredAt               *time.Time
+	// TrackingUpdatedAt is when the latest applied tracking event happened;
+	// older events arriving late are ignored
+	TrackingUpdatedAt *time.Time
+
+	// Payment authorized for the order and captured as its items ship
+	PaymentID              string
+	PaymentStatus          string
+	AuthorizedAmount       float64
+	CapturedAmount         float64
+	AuthorizationExpiresAt *time.Time
+
+	// Archival. Old orders are moved to cold storage, leaving this row as a
+	// stub without items, shipments or addresses; ArchiveLocation points at
+	// the archived copy, which is restored when the order is accessed.
+	ArchivedAt      *time.Time `gorm:"index"`
+	ArchiveLocation string
+	// ArchiveAnonymized is set when the user was deleted while the order was
+	// archived; its addresses are dropped when it is restored
+	ArchiveAnonymized bool `gorm:"default:false"`
+	// RehydratedAt is when the order was last restored; it is not archived
+	// again for the archive grace period
+	RehydratedAt *time.Time
+}
+
+// OrderArchive is an archived order kept in the database when no object
+// storage is configured for archives. Payload is the order, its items and
+// its shipments as JSON.
+type OrderArchive struct {
+	OrderID    string    `gorm:"primaryKey"`
+	Payload    string    `gorm:"type:text;not null"`
+	ArchivedAt time.Time `gorm:"not null"`
+}
+
+// Payment statuses of an order paid by authorization
+const (
+	PaymentStatusAuthorized        = "authorized"
+	PaymentStatusPartiallyCaptured = "partially_captured"
+	PaymentStatusCaptured          = "captured"
+	PaymentStatusVoided            = "voided"
+	PaymentStatusExpired           = "expired"
+)
+
+// Shipment is a parcel of some of an order's items. For orders paid by
+// authorization, the price of its items is captured when it is created.
+type Shipment struct {
+	ID             string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	OrderID        string         `gorm:"not null;index"`
+	Items          []ShipmentItem `gorm:"foreignKey:ShipmentID"`
+	Amount         float64        `gorm:"not null"`
+	CaptureID      string
+	Carrier        string
+	TrackingNumber string
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
+
+// ShipmentItem is the quantity of an order item in a shipment
+type ShipmentItem struct {
+	ID          string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	ShipmentID  string `gorm:"not null;index"`
+	OrderItemID string `gorm:"not null;index"`
+	Quantity    int32  `gorm:"not null"`
+}
+
+// Totals audit run statuses
+const (
+	TotalsAuditRunning   = "running"
+	TotalsAuditCompleted = "completed"
+	TotalsAuditFailed    = "failed"
+)
+
+// TotalsAuditRun is one nightly recomputation of order totals. RunDate is
+// unique, so only one instance audits each day.
+type TotalsAuditRun struct {
+	ID              string             `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	RunDate         string             `gorm:"not null;uniqueIndex"`
+	Status          string             `gorm:"not null"`
+	OrdersChecked   int64              `gorm:"not null;default:0"`
+	DivergenceCount int64              `gorm:"not null;default:0"`
+	Error           string
+	Divergences     []TotalsDivergence `gorm:"foreignKey:RunID"`
+	StartedAt       time.Time          `gorm:"not null"`
+	FinishedAt      *time.Time
+}
+
+// TotalsDivergence is a stored amount that does not match its recomputation.
+// Amounts are in cents.
+type TotalsDivergence struct {
+	ID            string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	RunID         string `gorm:"type:uuid;not null;index"`
+	OrderID       string `gorm:"not null;index"`
+	CheckType     string `gorm:"not null"`
+	ExpectedCents int64  `gorm:"not null"`
+	ActualCents   int64  `gorm:"not null"`
+	Detail        string
 }
 
 // OrderItem model
@@ -50,4 +177,37 @@ type OrderItem struct {
 	TotalPrice  float64 `gorm:"not null"`
 	CreatedAt   time.Time `gorm:"autoCreateTime"`
 	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
-}
\ No newline at end of file
+}
+
+// Cart statuses
+const (
+	CartStatusActive    = "active"
+	CartStatusAbandoned = "abandoned"
+	CartStatusConverted = "converted"
+)
+
+// Cart model. Each user has at most one cart; it is reused after checkout.
+type Cart struct {
+	ID             string     `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID         string     `gorm:"not null;uniqueIndex"`
+	Items          []CartItem `gorm:"foreignKey:CartID"`
+	Status         string     `gorm:"default:active;index"`
+	LastActivityAt time.Time  `gorm:"not null"`
+	AbandonedAt    *time.Time
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+
+	// Abandoned cart reminders sent since the cart was last active
+	RemindersSent  int `gorm:"default:0"`
+	LastReminderAt *time.Time
+}
+
+// CartItem model
+type CartItem struct {
+	ID        string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CartID    string    `gorm:"not null;uniqueIndex:idx_cart_product"`
+	ProductID string    `gorm:"not null;uniqueIndex:idx_cart_product"`
+	Quantity  int32     `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
@@ -2,6 +2,8 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"log"
 	"strconv"
 
 	"go.opentelemetry.io/otel"
@@ -11,6 +13,8 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"microservices-platform/pkg/versioning"
+	"microservices-platform/services/order-service/internal/database"
 	"microservices-platform/services/order-service/internal/service"
 	pb "microservices-platform/pkg/proto/order/v1"
 )
@@ -19,13 +23,19 @@ import (
 type OrderHandler struct {
 	pb.UnimplementedOrderServiceServer
 	orderService service.OrderService
+	cartService  service.CartService
+	auditService service.TotalsAuditService
+	archive      service.ArchiveService
 	tracer       trace.Tracer
 }
 
 // NewOrderHandler creates a new OrderHandler
-func NewOrderHandler(orderService service.OrderService) *OrderHandler {
+func NewOrderHandler(orderService service.OrderService, cartService service.CartService, auditService service.TotalsAuditService, archive service.ArchiveService) *OrderHandler {
 	return &OrderHandler{
 		orderService: orderService,
+		cartService:  cartService,
+		auditService: auditService,
+		archive:      archive,
 		tracer:       otel.Tracer("order-service"),
 	}
 }
@@ -37,7 +47,9 @@ func (h *OrderHandler) CreateOrder(ctx context.Context, req *pb.CreateOrderReque
 
 	span.SetAttributes(
 		attribute.String("order.user_id", req.UserId),
+		attribute.String("order.organization_id", req.OrganizationId),
 		attribute.Int("order.items_count", len(req.Items)),
+		attribute.String("order.shipping_method", req.ShippingMethod),
 	)
 
 	// Convert request items to service items
@@ -49,12 +61,24 @@ func (h *OrderHandler) CreateOrder(ctx context.Context, req *pb.CreateOrderReque
 		})
 	}
 
-	order, err := h.orderService.CreateOrder(ctx, req.UserId, items, req.ShippingAddress, req.BillingAddress)
+	shippingAddress := service.OrderAddress{AddressID: req.ShippingAddressId, Text: req.ShippingAddress}
+	billingAddress := service.OrderAddress{AddressID: req.BillingAddressId, Text: req.BillingAddress}
+
+	order, err := h.orderService.CreateOrder(ctx, req.UserId, req.OrganizationId, items, shippingAddress, billingAddress, req.ShippingMethod)
+	if errors.Is(err, service.ErrInsufficientInventory) {
+		span.RecordError(err)
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
 	if err != nil {
 		span.RecordError(err)
 		return nil, status.Errorf(codes.Internal, "failed to create order: %v", err)
 	}
 
+	// The cart has been checked out, so it must not be reported as abandoned
+	if err := h.cartService.MarkConverted(ctx, req.UserId); err != nil {
+		log.Printf("Failed to mark cart converted for user %s: %v", req.UserId, err)
+	}
+
 	return &pb.CreateOrderResponse{
 		Order: h.convertToProtoOrder(order),
 	}, nil
@@ -72,12 +96,38 @@ func (h *OrderHandler) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*
 		span.RecordError(err)
 		return nil, status.Errorf(codes.NotFound, "order not found: %v", err)
 	}
+	setETag(ctx, order.Version)
 
 	return &pb.GetOrderResponse{
 		Order: h.convertToProtoOrder(order),
 	}, nil
 }
 
+// RetrieveArchivedOrder retrieves an order, restoring it from the archive if
+// it was archived
+func (h *OrderHandler) RetrieveArchivedOrder(ctx context.Context, req *pb.RetrieveArchivedOrderRequest) (*pb.RetrieveArchivedOrderResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "OrderHandler.RetrieveArchivedOrder")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("order.id", req.OrderId))
+
+	order, rehydrated, err := h.archive.RetrieveArchivedOrder(ctx, req.OrderId)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, service.ErrOrderNotFound) {
+			return nil, status.Errorf(codes.NotFound, "order not found: %v", err)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to retrieve archived order: %v", err)
+	}
+	span.SetAttributes(attribute.Bool("order.rehydrated", rehydrated))
+	setETag(ctx, order.Version)
+
+	return &pb.RetrieveArchivedOrderResponse{
+		Order:      h.convertToProtoOrder(order),
+		Rehydrated: rehydrated,
+	}, nil
+}
+
 // UpdateOrderStatus updates an order status
 func (h *OrderHandler) UpdateOrderStatus(ctx context.Context, req *pb.UpdateOrderStatusRequest) (*pb.UpdateOrderStatusResponse, error) {
 	ctx, span := h.tracer.Start(ctx, "OrderHandler.UpdateOrderStatus")
@@ -88,12 +138,21 @@ func (h *OrderHandler) UpdateOrderStatus(ctx context.Context, req *pb.UpdateOrde
 		attribute.String("order.status", req.Status.String()),
 	)
 
+	match, err := versioning.IfMatch(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid If-Match header: %v", err)
+	}
+
 	statusStr := h.convertOrderStatusToString(req.Status)
-	order, err := h.orderService.UpdateOrderStatus(ctx, req.OrderId, statusStr)
+	order, err := h.orderService.UpdateOrderStatus(ctx, req.OrderId, statusStr, match)
+	if errors.Is(err, versioning.ErrConflict) {
+		return nil, versioning.PreconditionFailed(ctx, "order")
+	}
 	if err != nil {
 		span.RecordError(err)
 		return nil, status.Errorf(codes.Internal, "failed to update order status: %v", err)
 	}
+	setETag(ctx, order.Version)
 
 	return &pb.UpdateOrderStatusResponse{
 		Order: h.convertToProtoOrder(order),
@@ -107,6 +166,7 @@ func (h *OrderHandler) ListOrders(ctx context.Context, req *pb.ListOrdersRequest
 
 	span.SetAttributes(
 		attribute.String("orders.user_id", req.UserId),
+		attribute.String("orders.organization_id", req.OrganizationId),
 		attribute.Int64("pagination.page", int64(req.Page)),
 		attribute.Int64("pagination.page_size", int64(req.PageSize)),
 	)
@@ -116,7 +176,7 @@ func (h *OrderHandler) ListOrders(ctx context.Context, req *pb.ListOrdersRequest
 		statusFilter = h.convertOrderStatusToString(req.StatusFilter)
 	}
 
-	orders, total, err := h.orderService.ListOrders(ctx, req.UserId, int(req.Page), int(req.PageSize), statusFilter)
+	orders, total, err := h.orderService.ListOrders(ctx, req.UserId, req.OrganizationId, int(req.Page), int(req.PageSize), statusFilter)
 	if err != nil {
 		span.RecordError(err)
 		return nil, status.Errorf(codes.Internal, "failed to list orders: %v", err)
@@ -135,6 +195,41 @@ func (h *OrderHandler) ListOrders(ctx context.Context, req *pb.ListOrdersRequest
 	}, nil
 }
 
+// StreamOrders streams a user's or organization's orders without buffering
+// them in one response
+func (h *OrderHandler) StreamOrders(req *pb.StreamOrdersRequest, stream pb.OrderService_StreamOrdersServer) error {
+	ctx, span := h.tracer.Start(stream.Context(), "OrderHandler.StreamOrders")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("orders.user_id", req.UserId),
+		attribute.String("orders.organization_id", req.OrganizationId),
+		attribute.Int64("stream.batch_size", int64(req.BatchSize)),
+	)
+
+	statusFilter := ""
+	if req.StatusFilter != pb.OrderStatus_ORDER_STATUS_UNSPECIFIED {
+		statusFilter = h.convertOrderStatusToString(req.StatusFilter)
+	}
+
+	sent := 0
+	err := h.orderService.StreamOrders(ctx, req.UserId, req.OrganizationId, statusFilter, int(req.BatchSize), func(orders []*database.Order) error {
+		for _, order := range orders {
+			if err := stream.Send(h.convertToProtoOrder(order)); err != nil {
+				return err
+			}
+		}
+		sent += len(orders)
+		return nil
+	})
+	span.SetAttributes(attribute.Int("orders.sent", sent))
+	if err != nil {
+		span.RecordError(err)
+		return status.Errorf(codes.Internal, "failed to stream orders: %v", err)
+	}
+	return nil
+}
+
 // CancelOrder cancels an order
 func (h *OrderHandler) CancelOrder(ctx context.Context, req *pb.CancelOrderRequest) (*pb.CancelOrderResponse, error) {
 	ctx, span := h.tracer.Start(ctx, "OrderHandler.CancelOrder")
@@ -146,16 +241,254 @@ func (h *OrderHandler) CancelOrder(ctx context.Context, req *pb.CancelOrderReque
 	)
 
 	order, err := h.orderService.CancelOrder(ctx, req.OrderId, req.Reason)
+	if errors.Is(err, versioning.ErrConflict) {
+		return nil, versioning.PreconditionFailed(ctx, "order")
+	}
 	if err != nil {
 		span.RecordError(err)
 		return nil, status.Errorf(codes.Internal, "failed to cancel order: %v", err)
 	}
+	setETag(ctx, order.Version)
 
 	return &pb.CancelOrderResponse{
 		Order: h.convertToProtoOrder(order),
 	}, nil
 }
 
+// CreateShipment ships items of an order
+func (h *OrderHandler) CreateShipment(ctx context.Context, req *pb.CreateShipmentRequest) (*pb.CreateShipmentResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "OrderHandler.CreateShipment")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("order.id", req.OrderId),
+		attribute.Int("shipment.items_count", len(req.Items)),
+	)
+
+	var items []service.ShipmentItem
+	for _, item := range req.Items {
+		items = append(items, service.ShipmentItem{
+			ItemID:   item.ItemId,
+			Quantity: item.Quantity,
+		})
+	}
+
+	shipment, order, err := h.orderService.CreateShipment(ctx, req.OrderId, items, req.Carrier, req.TrackingNumber)
+	if errors.Is(err, versioning.ErrConflict) {
+		return nil, status.Error(codes.Aborted, "order changed while it was shipped, try again")
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to create shipment: %v", err)
+	}
+	setETag(ctx, order.Version)
+
+	return &pb.CreateShipmentResponse{
+		Shipment: convertToProtoShipment(shipment),
+		Order:    h.convertToProtoOrder(order),
+	}, nil
+}
+
+// ListShipments lists an order's shipments
+func (h *OrderHandler) ListShipments(ctx context.Context, req *pb.ListShipmentsRequest) (*pb.ListShipmentsResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "OrderHandler.ListShipments")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("order.id", req.OrderId))
+
+	shipments, err := h.orderService.ListShipments(ctx, req.OrderId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to list shipments: %v", err)
+	}
+
+	var protoShipments []*pb.Shipment
+	for _, shipment := range shipments {
+		protoShipments = append(protoShipments, convertToProtoShipment(shipment))
+	}
+
+	return &pb.ListShipmentsResponse{
+		Shipments: protoShipments,
+	}, nil
+}
+
+// GetTotalsAudit retrieves a totals audit run with its divergences
+func (h *OrderHandler) GetTotalsAudit(ctx context.Context, req *pb.GetTotalsAuditRequest) (*pb.GetTotalsAuditResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "OrderHandler.GetTotalsAudit")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("audit.run_id", req.RunId))
+
+	runID := req.RunId
+	if runID == "latest" {
+		runID = ""
+	}
+	run, err := h.auditService.GetRun(ctx, runID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to get totals audit: %v", err)
+	}
+	if run == nil {
+		return nil, status.Errorf(codes.NotFound, "totals audit not found")
+	}
+
+	audit := convertToProtoTotalsAudit(run)
+	for _, divergence := range run.Divergences {
+		audit.Divergences = append(audit.Divergences, &pb.TotalsDivergence{
+			OrderId:       divergence.OrderID,
+			Check:         divergence.CheckType,
+			ExpectedCents: divergence.ExpectedCents,
+			ActualCents:   divergence.ActualCents,
+			Detail:        divergence.Detail,
+		})
+	}
+
+	return &pb.GetTotalsAuditResponse{
+		Audit: audit,
+	}, nil
+}
+
+// ListTotalsAudits lists totals audit runs, newest first
+func (h *OrderHandler) ListTotalsAudits(ctx context.Context, req *pb.ListTotalsAuditsRequest) (*pb.ListTotalsAuditsResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "OrderHandler.ListTotalsAudits")
+	defer span.End()
+
+	runs, total, err := h.auditService.ListRuns(ctx, int(req.Page), int(req.PageSize))
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to list totals audits: %v", err)
+	}
+
+	var audits []*pb.TotalsAudit
+	for _, run := range runs {
+		audits = append(audits, convertToProtoTotalsAudit(run))
+	}
+
+	return &pb.ListTotalsAuditsResponse{
+		Audits:     audits,
+		TotalCount: int32(total),
+		Page:       req.Page,
+		PageSize:   req.PageSize,
+	}, nil
+}
+
+// GetCart retrieves a user's cart
+func (h *OrderHandler) GetCart(ctx context.Context, req *pb.GetCartRequest) (*pb.GetCartResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "OrderHandler.GetCart")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("cart.user_id", req.UserId))
+
+	cart, err := h.cartService.GetCart(ctx, req.UserId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to get cart: %v", err)
+	}
+
+	return &pb.GetCartResponse{
+		Cart: h.convertToProtoCart(cart),
+	}, nil
+}
+
+// AddCartItem adds a product to a user's cart
+func (h *OrderHandler) AddCartItem(ctx context.Context, req *pb.AddCartItemRequest) (*pb.AddCartItemResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "OrderHandler.AddCartItem")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("cart.user_id", req.UserId),
+		attribute.String("cart.product_id", req.ProductId),
+		attribute.Int64("cart.quantity", int64(req.Quantity)),
+	)
+
+	if req.Quantity <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "quantity must be positive")
+	}
+
+	cart, err := h.cartService.AddItem(ctx, req.UserId, req.ProductId, req.Quantity)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to add cart item: %v", err)
+	}
+
+	return &pb.AddCartItemResponse{
+		Cart: h.convertToProtoCart(cart),
+	}, nil
+}
+
+// RemoveCartItem removes a product from a user's cart
+func (h *OrderHandler) RemoveCartItem(ctx context.Context, req *pb.RemoveCartItemRequest) (*pb.RemoveCartItemResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "OrderHandler.RemoveCartItem")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("cart.user_id", req.UserId),
+		attribute.String("cart.product_id", req.ProductId),
+	)
+
+	cart, err := h.cartService.RemoveItem(ctx, req.UserId, req.ProductId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to remove cart item: %v", err)
+	}
+
+	return &pb.RemoveCartItemResponse{
+		Cart: h.convertToProtoCart(cart),
+	}, nil
+}
+
+// ClearCart empties a user's cart
+func (h *OrderHandler) ClearCart(ctx context.Context, req *pb.ClearCartRequest) (*pb.ClearCartResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "OrderHandler.ClearCart")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("cart.user_id", req.UserId))
+
+	cart, err := h.cartService.ClearCart(ctx, req.UserId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to clear cart: %v", err)
+	}
+
+	return &pb.ClearCartResponse{
+		Cart: h.convertToProtoCart(cart),
+	}, nil
+}
+
+// convertToProtoCart converts database cart to protobuf cart
+func (h *OrderHandler) convertToProtoCart(cart *database.Cart) *pb.Cart {
+	var items []*pb.CartItem
+	for _, item := range cart.Items {
+		items = append(items, &pb.CartItem{
+			ProductId: item.ProductID,
+			Quantity:  item.Quantity,
+		})
+	}
+
+	var cartStatus pb.CartStatus
+	switch cart.Status {
+	case database.CartStatusActive:
+		cartStatus = pb.CartStatus_CART_STATUS_ACTIVE
+	case database.CartStatusAbandoned:
+		cartStatus = pb.CartStatus_CART_STATUS_ABANDONED
+	case database.CartStatusConverted:
+		cartStatus = pb.CartStatus_CART_STATUS_CONVERTED
+	}
+
+	protoCart := &pb.Cart{
+		CartId:         cart.ID,
+		UserId:         cart.UserID,
+		Items:          items,
+		Status:         cartStatus,
+		LastActivityAt: timestamppb.New(cart.LastActivityAt),
+	}
+	if cart.AbandonedAt != nil {
+		protoCart.AbandonedAt = timestamppb.New(*cart.AbandonedAt)
+	}
+
+	return protoCart
+}
+
 // convertToProtoOrder converts database order to protobuf order
 func (h *OrderHandler) convertToProtoOrder(order *service.Order) *pb.Order {
 	var items []*pb.OrderItem
@@ -170,16 +503,92 @@ func (h *OrderHandler) convertToProtoOrder(order *service.Order) *pb.Order {
 		})
 	}
 
-	return &pb.Order{
-		OrderId:         order.ID,
-		UserId:          order.UserID,
-		Items:           items,
-		TotalAmount:     order.TotalAmount,
-		Status:          h.convertStringToOrderStatus(order.Status),
-		ShippingAddress: order.ShippingAddress,
-		BillingAddress:  order.BillingAddress,
-		CreatedAt:       timestamppb.New(order.CreatedAt),
-		UpdatedAt:       timestamppb.New(order.UpdatedAt),
+	protoOrder := &pb.Order{
+		OrderId:           order.ID,
+		UserId:            order.UserID,
+		Items:             items,
+		TotalAmount:       order.TotalAmount,
+		Status:            h.convertStringToOrderStatus(order.Status),
+		ShippingAddress:   order.ShippingAddress,
+		BillingAddress:    order.BillingAddress,
+		ShippingAddressId: order.ShippingAddressID,
+		BillingAddressId:  order.BillingAddressID,
+		OrganizationId:    order.OrganizationID,
+		CreatedAt:         timestamppb.New(order.CreatedAt),
+		UpdatedAt:         timestamppb.New(order.UpdatedAt),
+		Version:           order.Version,
+		ShippingMethod:    order.ShippingMethod,
+		EstimateSource:    order.EstimateSource,
+		Carrier:           order.Carrier,
+		TrackingNumber:    order.TrackingNumber,
+	}
+	if order.EstimatedDeliveryEarliest != nil {
+		protoOrder.EstimatedDeliveryEarliest = timestamppb.New(*order.EstimatedDeliveryEarliest)
+	}
+	if order.EstimatedDeliveryLatest != nil {
+		protoOrder.EstimatedDeliveryLatest = timestamppb.New(*order.EstimatedDeliveryLatest)
+	}
+	if order.DeliveredAt != nil {
+		protoOrder.DeliveredAt = timestamppb.New(*order.DeliveredAt)
+	}
+	protoOrder.PaymentId = order.PaymentID
+	protoOrder.PaymentStatus = order.PaymentStatus
+	protoOrder.AuthorizedAmount = order.AuthorizedAmount
+	protoOrder.CapturedAmount = order.CapturedAmount
+	if order.AuthorizationExpiresAt != nil {
+		protoOrder.AuthorizationExpiresAt = timestamppb.New(*order.AuthorizationExpiresAt)
+	}
+	if order.ArchivedAt != nil {
+		protoOrder.ArchivedAt = timestamppb.New(*order.ArchivedAt)
+	}
+	return protoOrder
+}
+
+// convertToProtoTotalsAudit converts a totals audit run, without its
+// divergences, to protobuf
+func convertToProtoTotalsAudit(run *database.TotalsAuditRun) *pb.TotalsAudit {
+	audit := &pb.TotalsAudit{
+		RunId:           run.ID,
+		RunDate:         run.RunDate,
+		Status:          pb.TotalsAuditStatus_TOTALS_AUDIT_STATUS_UNSPECIFIED,
+		OrdersChecked:   run.OrdersChecked,
+		DivergenceCount: run.DivergenceCount,
+		Error:           run.Error,
+		StartedAt:       timestamppb.New(run.StartedAt),
+	}
+	switch run.Status {
+	case database.TotalsAuditRunning:
+		audit.Status = pb.TotalsAuditStatus_TOTALS_AUDIT_STATUS_RUNNING
+	case database.TotalsAuditCompleted:
+		audit.Status = pb.TotalsAuditStatus_TOTALS_AUDIT_STATUS_COMPLETED
+	case database.TotalsAuditFailed:
+		audit.Status = pb.TotalsAuditStatus_TOTALS_AUDIT_STATUS_FAILED
+	}
+	if run.FinishedAt != nil {
+		audit.FinishedAt = timestamppb.New(*run.FinishedAt)
+	}
+	return audit
+}
+
+// convertToProtoShipment converts database shipment to protobuf shipment
+func convertToProtoShipment(shipment *database.Shipment) *pb.Shipment {
+	var items []*pb.ShipmentItem
+	for _, item := range shipment.Items {
+		items = append(items, &pb.ShipmentItem{
+			ItemId:   item.OrderItemID,
+			Quantity: item.Quantity,
+		})
+	}
+
+	return &pb.Shipment{
+		ShipmentId:     shipment.ID,
+		OrderId:        shipment.OrderID,
+		Items:          items,
+		Amount:         shipment.Amount,
+		CaptureId:      shipment.CaptureID,
+		Carrier:        shipment.Carrier,
+		TrackingNumber: shipment.TrackingNumber,
+		CreatedAt:      timestamppb.New(shipment.CreatedAt),
 	}
 }
 
@@ -200,6 +609,8 @@ func (h *OrderHandler) convertOrderStatusToString(status pb.OrderStatus) string
 		return "cancelled"
 	case pb.OrderStatus_ORDER_STATUS_REFUNDED:
 		return "refunded"
+	case pb.OrderStatus_ORDER_STATUS_PARTIALLY_SHIPPED:
+		return "partially_shipped"
 	default:
 		return "pending"
 	}
@@ -222,7 +633,17 @@ func (h *OrderHandler) convertStringToOrderStatus(status string) pb.OrderStatus
 		return pb.OrderStatus_ORDER_STATUS_CANCELLED
 	case "refunded":
 		return pb.OrderStatus_ORDER_STATUS_REFUNDED
+	case "partially_shipped":
+		return pb.OrderStatus_ORDER_STATUS_PARTIALLY_SHIPPED
 	default:
 		return pb.OrderStatus_ORDER_STATUS_UNSPECIFIED
 	}
-}
\ No newline at end of file
+}
+
+// setETag returns an order's version as the ETag of the response. Clients
+// without it can only make unconditional writes, so a failure is only logged.
+func setETag(ctx context.Context, version int64) {
+	if err := versioning.SetETag(ctx, version); err != nil {
+		log.Printf("Failed to set ETag header: %v", err)
+	}
+}
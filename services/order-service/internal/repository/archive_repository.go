@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"microservices-platform/pkg/versioning"
+	"microservices-platform/services/order-service/internal/database"
+)
+
+// ArchivableStatuses are the statuses of orders that are done with and may
+// be archived
+var ArchivableStatuses = []string{"delivered", "cancelled", "refunded"}
+
+// ArchiveRepository interface defines order archival data operations
+type ArchiveRepository interface {
+	// ArchiveBatch locks up to limit finished orders placed before cutoff,
+	// and not rehydrated since rehydratedBefore, and passes them with their
+	// items and shipments to store, which copies them to cold storage and
+	// returns each order's archive location. The orders are then reduced to
+	// stubs pointing at their location, all in one transaction. It returns
+	// the number of orders archived.
+	ArchiveBatch(ctx context.Context, cutoff, rehydratedBefore time.Time, limit int, store func([]*database.Order, map[string][]*database.Shipment) (map[string]string, error)) (int, error)
+	// Rehydrate restores an archived order with its items and shipments. It
+	// returns versioning.ErrConflict if the stub was changed or restored
+	// since it was read at version.
+	Rehydrate(ctx context.Context, order *database.Order, shipments []*database.Shipment, version int64) error
+	// SaveArchives stores archived orders in the archive table
+	SaveArchives(ctx context.Context, archives []*database.OrderArchive) error
+	// GetArchive returns an order's entry in the archive table, or nil
+	GetArchive(ctx context.Context, orderID string) (*database.OrderArchive, error)
+}
+
+// archiveRepository implements ArchiveRepository interface
+type archiveRepository struct {
+	db *gorm.DB
+}
+
+// NewArchiveRepository creates a new order archive repository
+func NewArchiveRepository(db *gorm.DB) ArchiveRepository {
+	return &archiveRepository{
+		db: db,
+	}
+}
+
+// ArchiveBatch locks the orders with SKIP LOCKED, so instances archiving at
+// the same time take different orders and requests are not held up by it
+func (r *archiveRepository) ArchiveBatch(ctx context.Context, cutoff, rehydratedBefore time.Time, limit int, store func([]*database.Order, map[string][]*database.Shipment) (map[string]string, error)) (int, error) {
+	archived := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var orders []*database.Order
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("archived_at IS NULL AND created_at < ? AND status IN ?", cutoff, ArchivableStatuses).
+			Where("rehydrated_at IS NULL OR rehydrated_at < ?", rehydratedBefore).
+			Order("created_at ASC").
+			Limit(limit).
+			Find(&orders).Error
+		if err != nil || len(orders) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(orders))
+		byID := make(map[string]*database.Order, len(orders))
+		for i, order := range orders {
+			ids[i] = order.ID
+			byID[order.ID] = order
+		}
+
+		var items []database.OrderItem
+		if err := tx.Where("order_id IN ?", ids).Find(&items).Error; err != nil {
+			return err
+		}
+		for _, item := range items {
+			byID[item.OrderID].Items = append(byID[item.OrderID].Items, item)
+		}
+
+		var shipments []*database.Shipment
+		if err := tx.Preload("Items").Where("order_id IN ?", ids).Order("created_at ASC").Find(&shipments).Error; err != nil {
+			return err
+		}
+		shipmentsByOrder := make(map[string][]*database.Shipment)
+		for _, shipment := range shipments {
+			shipmentsByOrder[shipment.OrderID] = append(shipmentsByOrder[shipment.OrderID], shipment)
+		}
+
+		locations, err := store(orders, shipmentsByOrder)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		for _, order := range orders {
+			location, ok := locations[order.ID]
+			if !ok {
+				continue
+			}
+			// The version is kept, as the order itself does not change
+			err := tx.Model(&database.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+				"archived_at":         now,
+				"archive_location":    location,
+				"archive_anonymized":  false,
+				"shipping_address":    "",
+				"billing_address":     "",
+				"shipping_address_id": "",
+				"billing_address_id":  "",
+			}).Error
+			if err != nil {
+				return err
+			}
+			shipmentIDs := tx.Model(&database.Shipment{}).Select("id").Where("order_id = ?", order.ID)
+			if err := tx.Where("shipment_id IN (?)", shipmentIDs).Delete(&database.ShipmentItem{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("order_id = ?", order.ID).Delete(&database.Shipment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("order_id = ?", order.ID).Delete(&database.OrderItem{}).Error; err != nil {
+				return err
+			}
+			archived++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return archived, nil
+}
+
+// Rehydrate writes the order back over its stub and recreates its items and
+// shipments in one transaction
+func (r *archiveRepository) Rehydrate(ctx context.Context, order *database.Order, shipments []*database.Shipment, version int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(order).
+			Where("archived_at IS NOT NULL AND version = ?", version).
+			Select("*").Omit("Items").
+			Updates(order)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return versioning.ErrConflict
+		}
+
+		if len(order.Items) > 0 {
+			if err := tx.Create(&order.Items).Error; err != nil {
+				return err
+			}
+		}
+		if len(shipments) > 0 {
+			if err := tx.Create(&shipments).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveArchives inserts archived orders, replacing earlier copies of orders
+// that were rehydrated and archived again
+func (r *archiveRepository) SaveArchives(ctx context.Context, archives []*database.OrderArchive) error {
+	if len(archives) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "order_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"payload", "archived_at"}),
+		}).
+		Create(&archives).Error
+}
+
+// GetArchive retrieves an order's entry in the archive table
+func (r *archiveRepository) GetArchive(ctx context.Context, orderID string) (*database.OrderArchive, error) {
+	var archive database.OrderArchive
+	err := r.db.WithContext(ctx).First(&archive, "order_id = ?", orderID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &archive, nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"microservices-platform/services/order-service/internal/database"
+)
+
+// AuditRepository interface defines totals audit data operations
+type AuditRepository interface {
+	// ClaimRun starts the audit of a day, returning nil if another instance
+	// already started it
+	ClaimRun(ctx context.Context, runDate string, startedAt time.Time) (*database.TotalsAuditRun, error)
+	// StreamOrders passes all orders that are not archived, with their
+	// items, to fn in batches
+	StreamOrders(ctx context.Context, batchSize int, fn func([]*database.Order) error) error
+	// CaptureIDs returns the capture IDs of the given orders' shipments
+	CaptureIDs(ctx context.Context, orderIDs []string) (map[string][]string, error)
+	AddDivergences(ctx context.Context, divergences []database.TotalsDivergence) error
+	FinishRun(ctx context.Context, run *database.TotalsAuditRun) error
+	// GetRun returns a run with its divergences, or the latest run if id is
+	// empty; nil if there is none
+	GetRun(ctx context.Context, id string) (*database.TotalsAuditRun, error)
+	ListRuns(ctx context.Context, offset, limit int) ([]*database.TotalsAuditRun, int64, error)
+}
+
+// auditRepository implements AuditRepository interface
+type auditRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository creates a new totals audit repository
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+// ClaimRun inserts the day's run; the unique run date makes a second insert
+// do nothing
+func (r *auditRepository) ClaimRun(ctx context.Context, runDate string, startedAt time.Time) (*database.TotalsAuditRun, error) {
+	run := &database.TotalsAuditRun{
+		RunDate:   runDate,
+		Status:    database.TotalsAuditRunning,
+		StartedAt: startedAt,
+	}
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "run_date"}}, DoNothing: true}).
+		Create(run)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return run, nil
+}
+
+// StreamOrders reads all orders in batches ordered by ID. Archived orders
+// were audited before they were archived and have no items left to check.
+func (r *auditRepository) StreamOrders(ctx context.Context, batchSize int, fn func([]*database.Order) error) error {
+	var orders []*database.Order
+	return r.db.WithContext(ctx).Model(&database.Order{}).Where("archived_at IS NULL").Preload("Items").
+		FindInBatches(&orders, batchSize, func(tx *gorm.DB, batch int) error {
+			return fn(orders)
+		}).Error
+}
+
+// CaptureIDs returns the capture IDs of the orders' shipments by order
+func (r *auditRepository) CaptureIDs(ctx context.Context, orderIDs []string) (map[string][]string, error) {
+	captures := make(map[string][]string)
+	if len(orderIDs) == 0 {
+		return captures, nil
+	}
+
+	var shipments []database.Shipment
+	err := r.db.WithContext(ctx).Select("order_id", "capture_id").
+		Where("order_id IN ? AND capture_id <> ''", orderIDs).
+		Find(&shipments).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, shipment := range shipments {
+		captures[shipment.OrderID] = append(captures[shipment.OrderID], shipment.CaptureID)
+	}
+	return captures, nil
+}
+
+// AddDivergences records divergences found by a run
+func (r *auditRepository) AddDivergences(ctx context.Context, divergences []database.TotalsDivergence) error {
+	if len(divergences) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(&divergences).Error
+}
+
+// FinishRun saves a run's outcome
+func (r *auditRepository) FinishRun(ctx context.Context, run *database.TotalsAuditRun) error {
+	return r.db.WithContext(ctx).Model(run).Updates(map[string]interface{}{
+		"status":           run.Status,
+		"orders_checked":   run.OrdersChecked,
+		"divergence_count": run.DivergenceCount,
+		"error":            run.Error,
+		"finished_at":      run.FinishedAt,
+	}).Error
+}
+
+// GetRun retrieves a run by ID, or the latest run
+func (r *auditRepository) GetRun(ctx context.Context, id string) (*database.TotalsAuditRun, error) {
+	query := r.db.WithContext(ctx).Preload("Divergences", func(db *gorm.DB) *gorm.DB {
+		return db.Order("order_id, check_type")
+	})
+	if id != "" {
+		query = query.Where("id = ?", id)
+	} else {
+		query = query.Order("run_date DESC")
+	}
+
+	var run database.TotalsAuditRun
+	err := query.First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListRuns lists runs, newest first, without their divergences
+func (r *auditRepository) ListRuns(ctx context.Context, offset, limit int) ([]*database.TotalsAuditRun, int64, error) {
+	var runs []*database.TotalsAuditRun
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&database.TotalsAuditRun{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("run_date DESC").Offset(offset).Limit(limit).Find(&runs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return runs, total, nil
+}
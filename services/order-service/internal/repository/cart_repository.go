@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"microservices-platform/services/order-service/internal/database"
+)
+
+// CartRepository interface defines cart data operations
+type CartRepository interface {
+	GetOrCreate(ctx context.Context, userID string) (*database.Cart, error)
+	GetByUserID(ctx context.Context, userID string) (*database.Cart, error)
+	GetByID(ctx context.Context, id string) (*database.Cart, error)
+	AddItem(ctx context.Context, cartID, productID string, quantity int32) error
+	RemoveItem(ctx context.Context, cartID, productID string) error
+	ClearItems(ctx context.Context, cartID string) error
+	Touch(ctx context.Context, cartID string, at time.Time) error
+	MarkAbandoned(ctx context.Context, cartID string, lastActivityAt, abandonedAt time.Time) (bool, error)
+	MarkConverted(ctx context.Context, userID string) error
+	RecordReminder(ctx context.Context, cartID string, at time.Time) error
+}
+
+// cartRepository implements CartRepository interface
+type cartRepository struct {
+	db *gorm.DB
+}
+
+// NewCartRepository creates a new cart repository
+func NewCartRepository(db *gorm.DB) CartRepository {
+	return &cartRepository{
+		db: db,
+	}
+}
+
+// GetOrCreate retrieves a user's cart, creating an empty one if needed
+func (r *cartRepository) GetOrCreate(ctx context.Context, userID string) (*database.Cart, error) {
+	cart := database.Cart{
+		UserID:         userID,
+		Status:         database.CartStatusActive,
+		LastActivityAt: time.Now().UTC(),
+	}
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoNothing: true}).
+		Create(&cart).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByUserID(ctx, userID)
+}
+
+// GetByUserID retrieves a user's cart
+func (r *cartRepository) GetByUserID(ctx context.Context, userID string) (*database.Cart, error) {
+	return r.first(ctx, "user_id = ?", userID)
+}
+
+// GetByID retrieves a cart by ID
+func (r *cartRepository) GetByID(ctx context.Context, id string) (*database.Cart, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+func (r *cartRepository) first(ctx context.Context, query string, arg interface{}) (*database.Cart, error) {
+	var cart database.Cart
+	err := r.db.WithContext(ctx).Preload("Items").First(&cart, query, arg).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// AddItem adds quantity of a product to a cart
+func (r *cartRepository) AddItem(ctx context.Context, cartID, productID string, quantity int32) error {
+	item := database.CartItem{CartID: cartID, ProductID: productID, Quantity: quantity}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "cart_id"}, {Name: "product_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"quantity":   gorm.Expr("cart_items.quantity + EXCLUDED.quantity"),
+				"updated_at": time.Now(),
+			}),
+		}).
+		Create(&item).Error
+}
+
+// RemoveItem removes a product from a cart
+func (r *cartRepository) RemoveItem(ctx context.Context, cartID, productID string) error {
+	return r.db.WithContext(ctx).
+		Where("cart_id = ? AND product_id = ?", cartID, productID).
+		Delete(&database.CartItem{}).Error
+}
+
+// ClearItems removes every item from a cart
+func (r *cartRepository) ClearItems(ctx context.Context, cartID string) error {
+	return r.db.WithContext(ctx).Where("cart_id = ?", cartID).Delete(&database.CartItem{}).Error
+}
+
+// Touch records activity on a cart, making it active again and resetting
+// its reminder count. The last reminder time is kept so the reminder
+// throttle spans abandonments.
+func (r *cartRepository) Touch(ctx context.Context, cartID string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&database.Cart{}).
+		Where("id = ?", cartID).
+		Updates(map[string]interface{}{
+			"status":           database.CartStatusActive,
+			"last_activity_at": at,
+			"abandoned_at":     nil,
+			"reminders_sent":   0,
+		}).Error
+}
+
+// MarkAbandoned marks an active cart as abandoned, but only if it has seen no
+// activity since lastActivityAt. It reports whether the cart was marked.
+func (r *cartRepository) MarkAbandoned(ctx context.Context, cartID string, lastActivityAt, abandonedAt time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&database.Cart{}).
+		Where("id = ? AND status = ? AND last_activity_at = ?", cartID, database.CartStatusActive, lastActivityAt).
+		Updates(map[string]interface{}{
+			"status":       database.CartStatusAbandoned,
+			"abandoned_at": abandonedAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// MarkConverted empties a user's cart after checkout
+func (r *cartRepository) MarkConverted(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var cart database.Cart
+		if err := tx.First(&cart, "user_id = ?", userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		if err := tx.Where("cart_id = ?", cart.ID).Delete(&database.CartItem{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&cart).Updates(map[string]interface{}{
+			"status":       database.CartStatusConverted,
+			"abandoned_at": nil,
+		}).Error
+	})
+}
+
+// RecordReminder counts an abandoned cart reminder sent at the given time
+func (r *cartRepository) RecordReminder(ctx context.Context, cartID string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&database.Cart{}).
+		Where("id = ?", cartID).
+		Updates(map[string]interface{}{
+			"reminders_sent":   gorm.Expr("reminders_sent + 1"),
+			"last_reminder_at": at,
+		}).Error
+}
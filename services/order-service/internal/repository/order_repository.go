@@ -5,6 +5,7 @@ import (
 	"errors"
 
 	"gorm.io/gorm"
+	"microservices-platform/pkg/versioning"
 	"microservices-platform/services/order-service/internal/database"
 )
 
@@ -15,7 +16,18 @@ type OrderRepository interface {
 	Update(ctx context.Context, order *database.Order) error
 	Delete(ctx context.Context, id string) error
 	ListByUserID(ctx context.Context, userID string, offset, limit int, statusFilter string) ([]*database.Order, int64, error)
-	UpdateStatus(ctx context.Context, id, status string) error
+	ListByOrganizationID(ctx context.Context, organizationID string, offset, limit int, statusFilter string) ([]*database.Order, int64, error)
+	StreamByUserID(ctx context.Context, userID, statusFilter string, batchSize int, fn func([]*database.Order) error) error
+	StreamByOrganizationID(ctx context.Context, organizationID, statusFilter string, batchSize int, fn func([]*database.Order) error) error
+	UpdateStatus(ctx context.Context, id, status string, version int64) error
+	// UpdateFields sets some of an order's fields at the given version
+	UpdateFields(ctx context.Context, id string, fields map[string]interface{}, version int64) error
+	// CreateShipment records a shipment and sets fields of its order at the
+	// given version, together
+	CreateShipment(ctx context.Context, shipment *database.Shipment, orderFields map[string]interface{}, version int64) error
+	// ListShipments lists an order's shipments, oldest first
+	ListShipments(ctx context.Context, orderID string) ([]*database.Shipment, error)
+	AnonymizeByUserID(ctx context.Context, userID string) error
 }
 
 // orderRepository implements OrderRepository interface
@@ -48,9 +60,19 @@ func (r *orderRepository) GetByID(ctx context.Context, id string) (*database.Ord
 	return &order, nil
 }
 
-// Update updates an order
+// Update updates an order and increments its version. It returns
+// versioning.ErrConflict if the order was updated since it was read.
 func (r *orderRepository) Update(ctx context.Context, order *database.Order) error {
-	return r.db.WithContext(ctx).Save(order).Error
+	version := order.Version
+	order.Version++
+	result := r.db.WithContext(ctx).Model(order).Where("version = ?", version).Select("*").Omit("Items").Updates(order)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = versioning.ErrConflict
+	}
+	if result.Error != nil {
+		order.Version = version
+	}
+	return result.Error
 }
 
 // Delete deletes an order
@@ -60,11 +82,20 @@ func (r *orderRepository) Delete(ctx context.Context, id string) error {
 
 // ListByUserID lists orders for a specific user with pagination and filtering
 func (r *orderRepository) ListByUserID(ctx context.Context, userID string, offset, limit int, statusFilter string) ([]*database.Order, int64, error) {
+	return r.list(r.db.WithContext(ctx).Model(&database.Order{}).Where("user_id = ?", userID), offset, limit, statusFilter)
+}
+
+// ListByOrganizationID lists the orders placed for an organization by any of
+// its members
+func (r *orderRepository) ListByOrganizationID(ctx context.Context, organizationID string, offset, limit int, statusFilter string) ([]*database.Order, int64, error) {
+	return r.list(r.db.WithContext(ctx).Model(&database.Order{}).Where("organization_id = ?", organizationID), offset, limit, statusFilter)
+}
+
+// list paginates and filters an order query
+func (r *orderRepository) list(query *gorm.DB, offset, limit int, statusFilter string) ([]*database.Order, int64, error) {
 	var orders []*database.Order
 	var total int64
 
-	query := r.db.WithContext(ctx).Model(&database.Order{}).Where("user_id = ?", userID)
-	
 	if statusFilter != "" {
 		query = query.Where("status = ?", statusFilter)
 	}
@@ -82,7 +113,99 @@ func (r *orderRepository) ListByUserID(ctx context.Context, userID string, offse
 	return orders, total, nil
 }
 
-// UpdateStatus updates the status of an order
-func (r *orderRepository) UpdateStatus(ctx context.Context, id, status string) error {
-	return r.db.WithContext(ctx).Model(&database.Order{}).Where("id = ?", id).Update("status", status).Error
-}
\ No newline at end of file
+// StreamByUserID passes all of a user's orders to fn in batches of batchSize
+func (r *orderRepository) StreamByUserID(ctx context.Context, userID, statusFilter string, batchSize int, fn func([]*database.Order) error) error {
+	return r.stream(r.db.WithContext(ctx).Model(&database.Order{}).Where("user_id = ?", userID), statusFilter, batchSize, fn)
+}
+
+// StreamByOrganizationID passes all of an organization's orders to fn in
+// batches of batchSize
+func (r *orderRepository) StreamByOrganizationID(ctx context.Context, organizationID, statusFilter string, batchSize int, fn func([]*database.Order) error) error {
+	return r.stream(r.db.WithContext(ctx).Model(&database.Order{}).Where("organization_id = ?", organizationID), statusFilter, batchSize, fn)
+}
+
+// stream reads an order query in batches ordered by ID, so only one batch is
+// held in memory and rows added meanwhile don't shift later batches. The
+// batch slice is reused, fn must not keep it.
+func (r *orderRepository) stream(query *gorm.DB, statusFilter string, batchSize int, fn func([]*database.Order) error) error {
+	if statusFilter != "" {
+		query = query.Where("status = ?", statusFilter)
+	}
+
+	var orders []*database.Order
+	return query.Preload("Items").FindInBatches(&orders, batchSize, func(tx *gorm.DB, batch int) error {
+		return fn(orders)
+	}).Error
+}
+
+// UpdateStatus updates the status of an order at the given version. It
+// returns versioning.ErrConflict if the order was updated since.
+func (r *orderRepository) UpdateStatus(ctx context.Context, id, status string, version int64) error {
+	result := r.db.WithContext(ctx).Model(&database.Order{}).
+		Where("id = ? AND version = ?", id, version).
+		Updates(map[string]interface{}{
+			"status":  status,
+			"version": gorm.Expr("version + 1"),
+		})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return versioning.ErrConflict
+	}
+	return result.Error
+}
+
+// UpdateFields updates some fields of an order at the given version. It
+// returns versioning.ErrConflict if the order was updated since.
+func (r *orderRepository) UpdateFields(ctx context.Context, id string, fields map[string]interface{}, version int64) error {
+	return updateFields(r.db.WithContext(ctx), id, fields, version)
+}
+
+func updateFields(db *gorm.DB, id string, fields map[string]interface{}, version int64) error {
+	updates := map[string]interface{}{"version": gorm.Expr("version + 1")}
+	for column, value := range fields {
+		updates[column] = value
+	}
+	result := db.Model(&database.Order{}).
+		Where("id = ? AND version = ?", id, version).
+		Updates(updates)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return versioning.ErrConflict
+	}
+	return result.Error
+}
+
+// CreateShipment creates a shipment with its items and updates its order in
+// one transaction, so items cannot be shipped twice by concurrent requests
+func (r *orderRepository) CreateShipment(ctx context.Context, shipment *database.Shipment, orderFields map[string]interface{}, version int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := updateFields(tx, shipment.OrderID, orderFields, version); err != nil {
+			return err
+		}
+		return tx.Create(shipment).Error
+	})
+}
+
+// ListShipments lists an order's shipments with their items
+func (r *orderRepository) ListShipments(ctx context.Context, orderID string) ([]*database.Shipment, error) {
+	var shipments []*database.Shipment
+	err := r.db.WithContext(ctx).Preload("Items").
+		Where("order_id = ?", orderID).
+		Order("created_at ASC").
+		Find(&shipments).Error
+	return shipments, err
+}
+
+// AnonymizeByUserID strips personal data from all of a user's orders. Orders
+// themselves are retained for accounting. Archived orders are flagged, so
+// their addresses are dropped when they are restored.
+func (r *orderRepository) AnonymizeByUserID(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Model(&database.Order{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{
+			"shipping_address":    "",
+			"billing_address":     "",
+			"shipping_address_id": "",
+			"billing_address_id":  "",
+			"archive_anonymized":  gorm.Expr("archived_at IS NOT NULL"),
+			"version":             gorm.Expr("version + 1"),
+		}).Error
+}
@@ -0,0 +1,133 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"microservices-platform/services/order-service/internal/repository"
+)
+
+// itemsPerOrder is how many items each listed order has
+const itemsPerOrder = 3
+
+// TestListingOrdersRunsAFixedNumberOfQueries checks listing a page of orders
+// with their items takes one count query, one order query and one item query,
+// however many orders the page holds
+func TestListingOrdersRunsAFixedNumberOfQueries(t *testing.T) {
+	for _, orders := range []int{1, 10, 50} {
+		t.Run(fmt.Sprintf("%d orders", orders), func(t *testing.T) {
+			conn := &countingConn{orders: orders}
+			repo := repository.NewOrderRepository(openDB(t, conn))
+
+			listed, total, err := repo.ListByUserID(context.Background(), "user-1", 0, orders, "")
+			if err != nil {
+				t.Fatalf("ListByUserID: %v", err)
+			}
+			if len(listed) != orders || total != int64(orders) {
+				t.Fatalf("listed %d of %d orders, want %d", len(listed), total, orders)
+			}
+			for _, order := range listed {
+				if len(order.Items) != itemsPerOrder {
+					t.Fatalf("order %s has %d items, want %d", order.ID, len(order.Items), itemsPerOrder)
+				}
+			}
+			if queries := conn.Queries(); len(queries) != 3 {
+				t.Fatalf("%d queries, want 3:\n%s", len(queries), strings.Join(queries, "\n"))
+			}
+		})
+	}
+}
+
+// openDB opens gorm over conn
+func openDB(t *testing.T, conn *countingConn) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(conn)}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open gorm: %v", err)
+	}
+	return db
+}
+
+// countingConn is a database connection that records the queries run on it
+// and answers them with a page of orders, each with itemsPerOrder items
+type countingConn struct {
+	orders int
+
+	mu      sync.Mutex
+	queries []string
+}
+
+// Queries returns the queries run so far
+func (c *countingConn) Queries() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.queries...)
+}
+
+// Connect and Driver implement driver.Connector
+func (c *countingConn) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *countingConn) Driver() driver.Driver                        { return nil }
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("unexpected prepared statement: %s", query)
+}
+func (c *countingConn) Close() error              { return nil }
+func (c *countingConn) Begin() (driver.Tx, error) { return nil, fmt.Errorf("unexpected transaction") }
+
+// QueryContext answers count, order and item queries
+func (c *countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.mu.Lock()
+	c.queries = append(c.queries, query)
+	c.mu.Unlock()
+
+	switch {
+	case strings.Contains(query, "count("):
+		return &rows{columns: []string{"count"}, values: [][]driver.Value{{int64(c.orders)}}}, nil
+	case strings.Contains(query, `"order_items"`):
+		result := &rows{columns: []string{"id", "order_id", "product_id", "quantity"}}
+		for _, arg := range args {
+			for i := 0; i < itemsPerOrder; i++ {
+				orderID := arg.Value.(string)
+				result.values = append(result.values, []driver.Value{fmt.Sprintf("%s-item-%d", orderID, i), orderID, "product-1", int64(1)})
+			}
+		}
+		return result, nil
+	case strings.Contains(query, `"orders"`):
+		result := &rows{columns: []string{"id", "user_id"}}
+		for i := 0; i < c.orders; i++ {
+			result.values = append(result.values, []driver.Value{fmt.Sprintf("order-%d", i), "user-1"})
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unexpected query: %s", query)
+}
+
+// rows are canned query results
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
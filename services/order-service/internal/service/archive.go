@@ -0,0 +1,297 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"microservices-platform/pkg/storage"
+	"microservices-platform/pkg/versioning"
+	"microservices-platform/services/order-service/internal/config"
+	"microservices-platform/services/order-service/internal/database"
+	"microservices-platform/services/order-service/internal/repository"
+)
+
+// archiveFormat is the version of ArchivedOrder written by this build
+const archiveFormat = 1
+
+// Archive location schemes. A table location is "table:<order id>"; an
+// object location is "object:<key>#<line>", the line of the order in a
+// gzipped NDJSON batch.
+const (
+	archiveSchemeTable  = "table:"
+	archiveSchemeObject = "object:"
+)
+
+// ErrOrderNotFound is returned when retrieving an order that does not exist
+var ErrOrderNotFound = errors.New("order not found")
+
+// ArchivedOrder is the archived copy of an order, one line of NDJSON
+type ArchivedOrder struct {
+	Format     int                  `json:"format"`
+	Order      *database.Order      `json:"order"`
+	Shipments  []*database.Shipment `json:"shipments,omitempty"`
+	ArchivedAt time.Time            `json:"archived_at"`
+}
+
+// ArchiveService moves finished orders to cold storage once they are old and
+// restores them when they are accessed again
+type ArchiveService interface {
+	// Start archives old orders every configured interval until ctx is
+	// cancelled. Any number of instances can run it; each order is archived
+	// by one of them.
+	Start(ctx context.Context)
+	// Run archives all orders that are due now, returning how many it
+	// archived
+	Run(ctx context.Context) (int, error)
+	// Rehydrate restores an archived order with its items and shipments and
+	// returns it as it is now. Orders that are not archived are returned as
+	// they are.
+	Rehydrate(ctx context.Context, order *database.Order) (*database.Order, error)
+	// RetrieveArchivedOrder returns an order, restoring it first if it is
+	// archived, and whether it was restored
+	RetrieveArchivedOrder(ctx context.Context, id string) (*database.Order, bool, error)
+}
+
+// archiveService implements ArchiveService
+type archiveService struct {
+	archiveRepo repository.ArchiveRepository
+	orderRepo   repository.OrderRepository
+	objects     storage.ObjectStore
+	cfg         config.ArchiveConfig
+}
+
+// NewArchiveService creates a new order archive service. Archives are written
+// to objects as gzipped NDJSON, or to the order_archives table if objects is
+// nil; archives already written are read from wherever they were written.
+func NewArchiveService(archiveRepo repository.ArchiveRepository, orderRepo repository.OrderRepository, objects storage.ObjectStore, cfg config.ArchiveConfig) ArchiveService {
+	return &archiveService{
+		archiveRepo: archiveRepo,
+		orderRepo:   orderRepo,
+		objects:     objects,
+		cfg:         cfg,
+	}
+}
+
+// Start polls for orders to archive
+func (s *archiveService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Run(ctx); err != nil {
+				log.Printf("Order archival failed: %v", err)
+			}
+		}
+	}
+}
+
+// Run archives batches of orders until a batch comes back short
+func (s *archiveService) Run(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	cutoff := now.AddDate(0, -s.cfg.AfterMonths, 0)
+	rehydratedBefore := now.Add(-s.cfg.GracePeriod)
+
+	total := 0
+	for ctx.Err() == nil {
+		archived, err := s.archiveRepo.ArchiveBatch(ctx, cutoff, rehydratedBefore, s.cfg.BatchSize, s.store)
+		total += archived
+		if err != nil {
+			return total, err
+		}
+		if archived < s.cfg.BatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("Order archival archived %d orders placed before %s", total, cutoff.Format("2006-01-02"))
+	}
+	return total, ctx.Err()
+}
+
+// store writes a batch of orders to cold storage and returns their locations
+func (s *archiveService) store(orders []*database.Order, shipments map[string][]*database.Shipment) (map[string]string, error) {
+	// The batch is written from inside the archiving transaction, which has
+	// its own context; this one only bounds the upload
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	archivedAt := time.Now().UTC()
+	if s.objects == nil {
+		archives := make([]*database.OrderArchive, len(orders))
+		locations := make(map[string]string, len(orders))
+		for i, order := range orders {
+			payload, err := json.Marshal(ArchivedOrder{Format: archiveFormat, Order: order, Shipments: shipments[order.ID], ArchivedAt: archivedAt})
+			if err != nil {
+				return nil, err
+			}
+			archives[i] = &database.OrderArchive{OrderID: order.ID, Payload: string(payload), ArchivedAt: archivedAt}
+			locations[order.ID] = archiveSchemeTable + order.ID
+		}
+		if err := s.archiveRepo.SaveArchives(ctx, archives); err != nil {
+			return nil, err
+		}
+		return locations, nil
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gz)
+	for _, order := range orders {
+		if err := encoder.Encode(ArchivedOrder{Format: archiveFormat, Order: order, Shipments: shipments[order.ID], ArchivedAt: archivedAt}); err != nil {
+			return nil, err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	key := storage.OrderArchiveKey(archivedAt, fmt.Sprintf("%d-%s", archivedAt.UnixNano(), orders[0].ID))
+	if _, err := s.objects.Put(ctx, key, &buf, int64(buf.Len()), "application/x-ndjson"); err != nil {
+		return nil, fmt.Errorf("failed to upload archive batch: %v", err)
+	}
+	locations := make(map[string]string, len(orders))
+	for i, order := range orders {
+		locations[order.ID] = fmt.Sprintf("%s%s#%d", archiveSchemeObject, key, i)
+	}
+	return locations, nil
+}
+
+// load reads the archived copy of an order from its location
+func (s *archiveService) load(ctx context.Context, location string) (*ArchivedOrder, error) {
+	var payload []byte
+	switch {
+	case strings.HasPrefix(location, archiveSchemeTable):
+		archive, err := s.archiveRepo.GetArchive(ctx, strings.TrimPrefix(location, archiveSchemeTable))
+		if err != nil {
+			return nil, err
+		}
+		if archive == nil {
+			return nil, fmt.Errorf("archive %s not found", location)
+		}
+		payload = []byte(archive.Payload)
+
+	case strings.HasPrefix(location, archiveSchemeObject):
+		if s.objects == nil {
+			return nil, fmt.Errorf("archive %s is in object storage, which is not configured", location)
+		}
+		key, lineText, ok := strings.Cut(strings.TrimPrefix(location, archiveSchemeObject), "#")
+		line, err := strconv.Atoi(lineText)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid archive location %s", location)
+		}
+		data, err := s.objects.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download archive batch: %v", err)
+		}
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(gz)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for i := 0; scanner.Scan(); i++ {
+			if i == line {
+				payload = scanner.Bytes()
+				break
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		if payload == nil {
+			return nil, fmt.Errorf("archive %s not found", location)
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid archive location %s", location)
+	}
+
+	var archived ArchivedOrder
+	if err := json.Unmarshal(payload, &archived); err != nil {
+		return nil, fmt.Errorf("failed to decode archive %s: %v", location, err)
+	}
+	if archived.Order == nil {
+		return nil, fmt.Errorf("archive %s holds no order", location)
+	}
+	return &archived, nil
+}
+
+// Rehydrate restores what archiving removed from the stub: items, shipments
+// and addresses. Everything else is taken from the stub, which stays the
+// record of the order while it is archived.
+func (s *archiveService) Rehydrate(ctx context.Context, order *database.Order) (*database.Order, error) {
+	if order == nil || order.ArchivedAt == nil {
+		return order, nil
+	}
+
+	archived, err := s.load(ctx, order.ArchiveLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := *order
+	restored.Items = archived.Order.Items
+	if !order.ArchiveAnonymized {
+		restored.ShippingAddress = archived.Order.ShippingAddress
+		restored.BillingAddress = archived.Order.BillingAddress
+		restored.ShippingAddressID = archived.Order.ShippingAddressID
+		restored.BillingAddressID = archived.Order.BillingAddressID
+	}
+	now := time.Now().UTC()
+	restored.ArchivedAt = nil
+	restored.ArchiveLocation = ""
+	restored.ArchiveAnonymized = false
+	restored.RehydratedAt = &now
+
+	// A conflict means another request restored the order first, or it was
+	// archived again with a new location; either way it is read again
+	err = s.archiveRepo.Rehydrate(ctx, &restored, archived.Shipments, order.Version)
+	if err != nil && !errors.Is(err, versioning.ErrConflict) {
+		return nil, err
+	}
+	if err == nil {
+		log.Printf("Restored archived order %s from %s", order.ID, order.ArchiveLocation)
+	}
+
+	current, err := s.orderRepo.GetByID(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrOrderNotFound
+	}
+	return current, nil
+}
+
+// RetrieveArchivedOrder retrieves an order, restoring it if it is archived
+func (s *archiveService) RetrieveArchivedOrder(ctx context.Context, id string) (*database.Order, bool, error) {
+	order, err := s.orderRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if order == nil {
+		return nil, false, ErrOrderNotFound
+	}
+	if order.ArchivedAt == nil {
+		return order, false, nil
+	}
+
+	order, err = s.Rehydrate(ctx, order)
+	if err != nil {
+		return nil, false, err
+	}
+	return order, true, nil
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/grpcclient"
+	"microservices-platform/pkg/i18n"
+	notificationpb "microservices-platform/pkg/proto/notification/v1"
+	productpb "microservices-platform/pkg/proto/product/v1"
+	userpb "microservices-platform/pkg/proto/user/v1"
+	"microservices-platform/services/order-service/internal/config"
+	"microservices-platform/services/order-service/internal/database"
+	"microservices-platform/services/order-service/internal/repository"
+)
+
+// CartService interface defines cart business logic operations
+type CartService interface {
+	GetCart(ctx context.Context, userID string) (*database.Cart, error)
+	AddItem(ctx context.Context, userID, productID string, quantity int32) (*database.Cart, error)
+	RemoveItem(ctx context.Context, userID, productID string) (*database.Cart, error)
+	ClearCart(ctx context.Context, userID string) (*database.Cart, error)
+	MarkConverted(ctx context.Context, userID string) error
+	// HandleIdleCheck marks a cart abandoned if it has been idle since the
+	// check was scheduled
+	HandleIdleCheck(ctx context.Context, event *events.Event) error
+	// HandleAbandoned sends a reminder for an abandoned cart, respecting the
+	// user's opt-out and the reminder throttle
+	HandleAbandoned(ctx context.Context, event *events.Event) error
+}
+
+// cartService implements CartService interface
+type cartService struct {
+	cartRepo           repository.CartRepository
+	eventBus           events.EventBus
+	userClient         userpb.UserServiceClient
+	productClient      productpb.ProductServiceClient
+	notificationClient notificationpb.NotificationServiceClient
+
+	abandonAfter     time.Duration
+	reminderThrottle time.Duration
+	maxReminders     int
+	// reminderStartHour and reminderEndHour bound the local hours reminders
+	// are sent in
+	reminderStartHour int
+	reminderEndHour   int
+}
+
+// NewCartService creates a new cart service
+func NewCartService(cartRepo repository.CartRepository, eventBus events.EventBus, cfg *config.Config) CartService {
+	userConn, err := grpcclient.Dial(cfg.UserServiceURL, cfg.GRPCClient)
+	if err != nil {
+		log.Printf("Failed to connect to user service: %v", err)
+	}
+
+	productConn, err := grpcclient.Dial(cfg.ProductServiceURL, cfg.GRPCClient)
+	if err != nil {
+		log.Printf("Failed to connect to product service: %v", err)
+	}
+
+	notificationConn, err := grpcclient.Dial(cfg.NotificationServiceURL, cfg.GRPCClient)
+	if err != nil {
+		log.Printf("Failed to connect to notification service: %v", err)
+	}
+
+	return &cartService{
+		cartRepo:           cartRepo,
+		eventBus:           eventBus,
+		userClient:         userpb.NewUserServiceClient(userConn),
+		productClient:      productpb.NewProductServiceClient(productConn),
+		notificationClient: notificationpb.NewNotificationServiceClient(notificationConn),
+		abandonAfter:       cfg.CartAbandonAfter,
+		reminderThrottle:   cfg.CartReminderThrottle,
+		maxReminders:       cfg.CartMaxReminders,
+		reminderStartHour:  cfg.CartReminderStartHour,
+		reminderEndHour:    cfg.CartReminderEndHour,
+	}
+}
+
+// GetCart retrieves a user's cart
+func (s *cartService) GetCart(ctx context.Context, userID string) (*database.Cart, error) {
+	return s.cartRepo.GetOrCreate(ctx, userID)
+}
+
+// AddItem adds quantity of a product to a user's cart
+func (s *cartService) AddItem(ctx context.Context, userID, productID string, quantity int32) (*database.Cart, error) {
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+
+	if _, err := s.productClient.GetProduct(ctx, &productpb.GetProductRequest{ProductId: productID}); err != nil {
+		return nil, fmt.Errorf("failed to get product %s: %v", productID, err)
+	}
+
+	cart, err := s.cartRepo.GetOrCreate(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.AddItem(ctx, cart.ID, productID, quantity); err != nil {
+		return nil, err
+	}
+
+	return s.touch(ctx, cart)
+}
+
+// RemoveItem removes a product from a user's cart
+func (s *cartService) RemoveItem(ctx context.Context, userID, productID string) (*database.Cart, error) {
+	cart, err := s.cartRepo.GetOrCreate(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.RemoveItem(ctx, cart.ID, productID); err != nil {
+		return nil, err
+	}
+
+	return s.touch(ctx, cart)
+}
+
+// ClearCart removes every item from a user's cart
+func (s *cartService) ClearCart(ctx context.Context, userID string) (*database.Cart, error) {
+	cart, err := s.cartRepo.GetOrCreate(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.ClearItems(ctx, cart.ID); err != nil {
+		return nil, err
+	}
+
+	return s.touch(ctx, cart)
+}
+
+// MarkConverted empties a user's cart once it has been checked out
+func (s *cartService) MarkConverted(ctx context.Context, userID string) error {
+	return s.cartRepo.MarkConverted(ctx, userID)
+}
+
+// touch records activity on a cart and schedules an idle check for when the
+// abandonment window would run out. Each change schedules its own check;
+// checks made stale by later activity find the cart's activity time changed
+// and do nothing.
+func (s *cartService) touch(ctx context.Context, cart *database.Cart) (*database.Cart, error) {
+	// Postgres stores microseconds, so truncate for the idle check to match
+	activityAt := time.Now().UTC().Truncate(time.Microsecond)
+	if err := s.cartRepo.Touch(ctx, cart.ID, activityAt); err != nil {
+		return nil, err
+	}
+
+	err := s.eventBus.PublishAfter(ctx, &events.Event{
+		Type:    events.CartIdleCheck,
+		Source:  "order-service",
+		Subject: cart.ID,
+		Data: map[string]interface{}{
+			"cart_id":          cart.ID,
+			"user_id":          cart.UserID,
+			"last_activity_at": activityAt.Format(time.RFC3339Nano),
+		},
+	}, s.abandonAfter)
+	if err != nil {
+		// The cart change itself succeeded; only abandonment tracking is lost
+		log.Printf("Failed to schedule idle check for cart %s: %v", cart.ID, err)
+	}
+
+	return s.cartRepo.GetByID(ctx, cart.ID)
+}
+
+// HandleIdleCheck marks a cart abandoned if it still has items and has not
+// changed since the check was scheduled, then publishes cart.abandoned
+func (s *cartService) HandleIdleCheck(ctx context.Context, event *events.Event) error {
+	cartID, _ := event.Data["cart_id"].(string)
+	rawActivityAt, _ := event.Data["last_activity_at"].(string)
+	activityAt, err := time.Parse(time.RFC3339Nano, rawActivityAt)
+	if cartID == "" || err != nil {
+		return fmt.Errorf("malformed cart idle check event %s", event.ID)
+	}
+
+	cart, err := s.cartRepo.GetByID(ctx, cartID)
+	if err != nil {
+		return err
+	}
+	if cart == nil || len(cart.Items) == 0 {
+		return nil
+	}
+
+	abandonedAt := time.Now().UTC()
+	marked, err := s.cartRepo.MarkAbandoned(ctx, cartID, activityAt, abandonedAt)
+	if err != nil || !marked {
+		return err
+	}
+
+	itemCount := 0
+	for _, item := range cart.Items {
+		itemCount += int(item.Quantity)
+	}
+
+	return s.eventBus.Publish(ctx, &events.Event{
+		Type:    events.CartAbandoned,
+		Source:  "order-service",
+		Subject: cart.ID,
+		Data: map[string]interface{}{
+			"cart_id":      cart.ID,
+			"user_id":      cart.UserID,
+			"item_count":   itemCount,
+			"idle_since":   activityAt.Unix(),
+			"abandoned_at": abandonedAt.Unix(),
+		},
+	})
+}
+
+// HandleAbandoned sends an abandoned cart reminder. No reminder is sent if
+// the cart has moved on, the user opted out of promotional notifications,
+// the cart already had its maximum reminders, or the user was reminded
+// within the throttle window. Outside the reminder hours of the user's time
+// zone the reminder is put off until they next start.
+func (s *cartService) HandleAbandoned(ctx context.Context, event *events.Event) error {
+	cartID, _ := event.Data["cart_id"].(string)
+	if cartID == "" {
+		return fmt.Errorf("malformed cart abandoned event %s", event.ID)
+	}
+
+	cart, err := s.cartRepo.GetByID(ctx, cartID)
+	if err != nil {
+		return err
+	}
+	if cart == nil || cart.Status != database.CartStatusAbandoned || len(cart.Items) == 0 {
+		return nil
+	}
+
+	if cart.RemindersSent >= s.maxReminders {
+		return nil
+	}
+	if cart.LastReminderAt != nil && time.Since(*cart.LastReminderAt) < s.reminderThrottle {
+		log.Printf("Skipping cart reminder for user %s, reminded at %s", cart.UserID, cart.LastReminderAt.Format(time.RFC3339))
+		return nil
+	}
+
+	userResp, err := s.userClient.GetUser(ctx, &userpb.GetUserRequest{UserId: cart.UserID})
+	if err != nil {
+		return fmt.Errorf("failed to get user %s: %v", cart.UserID, err)
+	}
+	if userResp.User.MarketingOptOut || userResp.User.Status != userpb.UserStatus_USER_STATUS_ACTIVE {
+		return nil
+	}
+
+	loc := i18n.Location(userResp.User.TimeZone)
+	now := time.Now()
+	if !i18n.WithinLocalHours(now, loc, s.reminderStartHour, s.reminderEndHour) {
+		return s.deferReminder(ctx, event, i18n.NextLocalTime(now, loc, s.reminderStartHour, 0))
+	}
+
+	language := i18n.Negotiate(userResp.User.Locale)
+	idleSince := i18n.FormatTime(cart.LastActivityAt, language, userResp.User.TimeZone)
+	_, err = s.notificationClient.SendNotification(ctx, &notificationpb.SendNotificationRequest{
+		UserId:  cart.UserID,
+		Title:   i18n.Translate(language, "You left something in your cart"),
+		Message: i18n.Translate(language, "You have %d item(s) waiting in your cart since %s.", len(cart.Items), idleSince),
+		Type:    notificationpb.NotificationType_NOTIFICATION_TYPE_CART_REMINDER,
+		Channels: []notificationpb.NotificationChannel{
+			notificationpb.NotificationChannel_NOTIFICATION_CHANNEL_EMAIL,
+			notificationpb.NotificationChannel_NOTIFICATION_CHANNEL_PUSH,
+		},
+		Metadata: map[string]string{"cart_id": cart.ID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send cart reminder: %v", err)
+	}
+
+	return s.cartRepo.RecordReminder(ctx, cart.ID, time.Now().UTC())
+}
+
+// deferReminder publishes the cart.abandoned event again at at. It goes out
+// as a new event, so consumers that drop events they have seen take it.
+func (s *cartService) deferReminder(ctx context.Context, event *events.Event, at time.Time) error {
+	deferred := *event
+	deferred.ID = ""
+	deferred.Timestamp = time.Time{}
+	if err := s.eventBus.PublishAt(ctx, &deferred, at); err != nil {
+		return fmt.Errorf("failed to defer cart reminder: %v", err)
+	}
+	return nil
+}
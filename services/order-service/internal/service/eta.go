@@ -0,0 +1,292 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"microservices-platform/services/order-service/internal/config"
+)
+
+// Sources of a delivery estimate
+const (
+	EstimateSourceRules    = "rules"
+	EstimateSourceCarrier  = "carrier"
+	EstimateSourceTracking = "tracking"
+)
+
+// Destination is where an order ships to. Country is an ISO 3166-1 alpha-2
+// code; it is empty for free-text addresses, which are estimated as domestic.
+type Destination struct {
+	Country    string
+	PostalCode string
+}
+
+// DeliveryEstimate is the window of dates an order is expected to arrive in
+type DeliveryEstimate struct {
+	Earliest time.Time
+	Latest   time.Time
+	Source   string
+}
+
+// ETAEstimator estimates delivery dates for new orders
+type ETAEstimator interface {
+	// Estimate estimates when an order placed at placedAt arrives. It
+	// returns an error for a shipping method there are no rules for.
+	Estimate(ctx context.Context, method string, destination Destination, placedAt time.Time) (*DeliveryEstimate, error)
+	// Method returns the shipping method to use for a request, or an error
+	// if it is not offered
+	Method(requested string) (string, error)
+}
+
+// transitDays is a range of business days in transit
+type transitDays struct {
+	Min int
+	Max int
+}
+
+// ruleEstimator estimates delivery from configured transit times. Dates are
+// in UTC and count business days only.
+type ruleEstimator struct {
+	rules         map[string]transitDays
+	defaultMethod string
+	origin        string
+	extraDays     int
+	handlingDays  int
+	cutoffHour    int
+}
+
+// NewETAEstimator creates an estimator from cfg. If a carrier API is
+// configured it is asked first, and the rules are used when it fails.
+func NewETAEstimator(cfg config.ETAConfig) (ETAEstimator, error) {
+	rules, err := parseETARules(cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+	defaultMethod := strings.ToLower(cfg.DefaultMethod)
+	if _, ok := rules[defaultMethod]; !ok {
+		return nil, fmt.Errorf("no ETA rule for default shipping method %q", cfg.DefaultMethod)
+	}
+
+	estimator := &ruleEstimator{
+		rules:         rules,
+		defaultMethod: defaultMethod,
+		origin:        strings.ToUpper(cfg.OriginCountry),
+		extraDays:     cfg.InternationalExtraDays,
+		handlingDays:  cfg.HandlingDays,
+		cutoffHour:    cfg.CutoffHour,
+	}
+	if cfg.CarrierAPIURL == "" {
+		return estimator, nil
+	}
+	return &carrierEstimator{
+		url:      cfg.CarrierAPIURL,
+		apiKey:   cfg.CarrierAPIKey,
+		client:   &http.Client{Timeout: cfg.CarrierAPITimeout},
+		origin:   estimator.origin,
+		fallback: estimator,
+	}, nil
+}
+
+// parseETARules parses rules such as "standard=3-5,express=1-2,standard:CA=5-8".
+// Keys are a shipping method, optionally with a destination country.
+func parseETARules(raw string) (map[string]transitDays, error) {
+	rules := make(map[string]transitDays)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, days, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid ETA rule %q", entry)
+		}
+		method, country, _ := strings.Cut(strings.TrimSpace(key), ":")
+		key = strings.ToLower(method)
+		if country != "" {
+			key += ":" + strings.ToUpper(country)
+		}
+
+		minDays, maxDays, isRange := strings.Cut(strings.TrimSpace(days), "-")
+		if !isRange {
+			maxDays = minDays
+		}
+		min, err := strconv.Atoi(minDays)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ETA rule %q", entry)
+		}
+		max, err := strconv.Atoi(maxDays)
+		if err != nil || min < 0 || max < min {
+			return nil, fmt.Errorf("invalid ETA rule %q", entry)
+		}
+		rules[key] = transitDays{Min: min, Max: max}
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no ETA rules configured")
+	}
+	return rules, nil
+}
+
+// Method returns the requested shipping method, or the default if none was
+// requested
+func (e *ruleEstimator) Method(requested string) (string, error) {
+	if requested == "" {
+		return e.defaultMethod, nil
+	}
+	method := strings.ToLower(requested)
+	if _, ok := e.rules[method]; !ok {
+		return "", fmt.Errorf("unsupported shipping method %q", requested)
+	}
+	return method, nil
+}
+
+// Estimate adds handling and transit business days to the date the order is
+// placed. A rule for the destination country takes precedence over the
+// method's rule; without one, destinations abroad take extra days.
+func (e *ruleEstimator) Estimate(ctx context.Context, method string, destination Destination, placedAt time.Time) (*DeliveryEstimate, error) {
+	country := strings.ToUpper(destination.Country)
+	days, ok := e.rules[method+":"+country]
+	if !ok {
+		days, ok = e.rules[method]
+		if !ok {
+			return nil, fmt.Errorf("unsupported shipping method %q", method)
+		}
+		if country != "" && country != e.origin {
+			days.Min += e.extraDays
+			days.Max += e.extraDays
+		}
+	}
+
+	shipDate := e.shipDate(placedAt)
+	return &DeliveryEstimate{
+		Earliest: addBusinessDays(shipDate, days.Min),
+		Latest:   addBusinessDays(shipDate, days.Max),
+		Source:   EstimateSourceRules,
+	}, nil
+}
+
+// shipDate is the business day an order placed at placedAt leaves the
+// warehouse
+func (e *ruleEstimator) shipDate(placedAt time.Time) time.Time {
+	placedAt = placedAt.UTC()
+	day := time.Date(placedAt.Year(), placedAt.Month(), placedAt.Day(), 0, 0, 0, 0, time.UTC)
+	handling := e.handlingDays
+	if isWeekend(day) {
+		// Weekend orders are handled from Monday morning
+		day = addBusinessDays(day, 1)
+	} else if placedAt.Hour() >= e.cutoffHour {
+		handling++
+	}
+	return addBusinessDays(day, handling)
+}
+
+// addBusinessDays adds n weekdays to day
+func addBusinessDays(day time.Time, n int) time.Time {
+	for n > 0 {
+		day = day.AddDate(0, 0, 1)
+		if !isWeekend(day) {
+			n--
+		}
+	}
+	return day
+}
+
+func isWeekend(day time.Time) bool {
+	return day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+}
+
+// carrierEstimator asks a carrier rate API for estimates, falling back to the
+// configured rules if the API fails or has no estimate for the destination
+type carrierEstimator struct {
+	url      string
+	apiKey   string
+	client   *http.Client
+	origin   string
+	fallback *ruleEstimator
+}
+
+// carrierETARequest is the body posted to the carrier API
+type carrierETARequest struct {
+	ShippingMethod        string `json:"shipping_method"`
+	OriginCountry         string `json:"origin_country"`
+	DestinationCountry    string `json:"destination_country"`
+	DestinationPostalCode string `json:"destination_postal_code,omitempty"`
+	ShipDate              string `json:"ship_date"`
+}
+
+// carrierETAResponse is the carrier API's estimate; dates are YYYY-MM-DD
+type carrierETAResponse struct {
+	EarliestDelivery string `json:"earliest_delivery"`
+	LatestDelivery   string `json:"latest_delivery"`
+}
+
+// Method accepts the shipping methods the rules define
+func (e *carrierEstimator) Method(requested string) (string, error) {
+	return e.fallback.Method(requested)
+}
+
+// Estimate asks the carrier API, and uses the rules if it cannot answer
+func (e *carrierEstimator) Estimate(ctx context.Context, method string, destination Destination, placedAt time.Time) (*DeliveryEstimate, error) {
+	estimate, err := e.fromCarrier(ctx, method, destination, placedAt)
+	if err != nil {
+		log.Printf("Carrier ETA unavailable, using rules: %v", err)
+		return e.fallback.Estimate(ctx, method, destination, placedAt)
+	}
+	return estimate, nil
+}
+
+func (e *carrierEstimator) fromCarrier(ctx context.Context, method string, destination Destination, placedAt time.Time) (*DeliveryEstimate, error) {
+	country := strings.ToUpper(destination.Country)
+	if country == "" {
+		country = e.origin
+	}
+	body, err := json.Marshal(carrierETARequest{
+		ShippingMethod:        method,
+		OriginCountry:         e.origin,
+		DestinationCountry:    country,
+		DestinationPostalCode: destination.PostalCode,
+		ShipDate:              e.fallback.shipDate(placedAt).Format("2006-01-02"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("carrier API returned %s", resp.Status)
+	}
+
+	var result carrierETAResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid carrier API response: %v", err)
+	}
+	earliest, err := time.Parse("2006-01-02", result.EarliestDelivery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid carrier API earliest_delivery: %v", err)
+	}
+	latest, err := time.Parse("2006-01-02", result.LatestDelivery)
+	if err != nil || latest.Before(earliest) {
+		latest = earliest
+	}
+	return &DeliveryEstimate{Earliest: earliest, Latest: latest, Source: EstimateSourceCarrier}, nil
+}
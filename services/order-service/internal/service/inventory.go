@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	productpb "microservices-platform/pkg/proto/product/v1"
+	"microservices-platform/services/order-service/internal/database"
+)
+
+// ErrInsufficientInventory is returned when an order's items are not in
+// stock
+var ErrInsufficientInventory = errors.New("insufficient inventory")
+
+// Stock is reserved in product-service when an order is placed, committed
+// when its payment is authorized, and released when it is cancelled.
+// Reservations of orders that are neither paid nor cancelled lapse on their
+// own after a while.
+
+// reserveInventory reserves stock for an order's items
+func (s *orderService) reserveInventory(ctx context.Context, order *database.Order) error {
+	req := &productpb.ReserveInventoryRequest{OrderId: order.ID}
+	for _, item := range order.Items {
+		req.Items = append(req.Items, &productpb.InventoryItem{
+			ProductId: item.ProductID,
+			Quantity:  item.Quantity,
+		})
+	}
+
+	_, err := s.inventoryClient.ReserveInventory(ctx, req)
+	if status.Code(err) == codes.FailedPrecondition {
+		return fmt.Errorf("%w: %s", ErrInsufficientInventory, status.Convert(err).Message())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reserve inventory: %v", err)
+	}
+	return nil
+}
+
+// commitInventory takes a paid order's stock off the shelf. A reservation
+// that lapsed and whose stock was sold meanwhile can't be committed; that
+// is logged for the order to be followed up, as retrying won't help.
+func (s *orderService) commitInventory(ctx context.Context, orderID string) error {
+	_, err := s.inventoryClient.CommitInventory(ctx, &productpb.CommitInventoryRequest{OrderId: orderID})
+	switch status.Code(err) {
+	case codes.OK:
+		return nil
+	case codes.FailedPrecondition:
+		log.Printf("Failed to commit inventory of paid order %s: %v", orderID, err)
+		return nil
+	case codes.NotFound:
+		// Orders placed before reservations have none
+		return nil
+	default:
+		return fmt.Errorf("failed to commit inventory of order %s: %v", orderID, err)
+	}
+}
+
+// releaseInventory returns an order's stock. If that fails, reserved stock
+// lapses on its own but committed stock stays taken until released again.
+func (s *orderService) releaseInventory(ctx context.Context, orderID, reason string) {
+	_, err := s.inventoryClient.ReleaseInventory(ctx, &productpb.ReleaseInventoryRequest{
+		OrderId: orderID,
+		Reason:  reason,
+	})
+	if err != nil && status.Code(err) != codes.NotFound {
+		log.Printf("Failed to release inventory of order %s: %v", orderID, err)
+	}
+}
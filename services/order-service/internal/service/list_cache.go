@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"microservices-platform/pkg/cache"
+	"microservices-platform/pkg/events"
+	"microservices-platform/services/order-service/internal/config"
+	"microservices-platform/services/order-service/internal/database"
+)
+
+// ListCacheName labels the order list cache in the cache metrics
+const ListCacheName = "order-list"
+
+// OrderListCache caches the first pages of users' order lists, the hottest
+// read after the catalog. Lists are invalidated by the user's order.created
+// and order.status_changed events, and expire after the configured TTL in
+// case an event is lost.
+type OrderListCache struct {
+	cache cache.Cache
+	cfg   config.ListCacheConfig
+}
+
+// cachedOrderList is a cached page of a user's orders
+type cachedOrderList struct {
+	Orders []*database.Order `json:"orders"`
+	Total  int64             `json:"total"`
+}
+
+// NewOrderListCache creates a new order list cache
+func NewOrderListCache(c cache.Cache, cfg config.ListCacheConfig) *OrderListCache {
+	return &OrderListCache{
+		cache: c,
+		cfg:   cfg,
+	}
+}
+
+// cacheable reports whether a page of a user's list is cached
+func (c *OrderListCache) cacheable(page, pageSize int, statusFilter string) bool {
+	return statusFilter == "" && pageSize == c.cfg.PageSize && page >= 1 && page <= c.cfg.Pages
+}
+
+// key returns the cache key of a page of a user's list
+func (c *OrderListCache) key(userID string, page int) string {
+	return fmt.Sprintf("order-list:%s:%d:%d", userID, c.cfg.PageSize, page)
+}
+
+// Get returns a cached page of a user's orders, if it is cached
+func (c *OrderListCache) Get(ctx context.Context, userID string, page, pageSize int, statusFilter string) ([]*database.Order, int64, bool) {
+	if !c.cacheable(page, pageSize, statusFilter) {
+		return nil, 0, false
+	}
+	var list cachedOrderList
+	if err := c.cache.Get(ctx, c.key(userID, page), &list); err != nil {
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			log.Printf("Failed to read cached orders of user %s: %v", userID, err)
+		}
+		return nil, 0, false
+	}
+	return list.Orders, list.Total, true
+}
+
+// Set caches a page of a user's orders, if it is one that is cached
+func (c *OrderListCache) Set(ctx context.Context, userID string, page, pageSize int, statusFilter string, orders []*database.Order, total int64) {
+	if !c.cacheable(page, pageSize, statusFilter) {
+		return
+	}
+	if err := c.cache.Set(ctx, c.key(userID, page), cachedOrderList{Orders: orders, Total: total}, c.cfg.TTL); err != nil {
+		log.Printf("Failed to cache orders of user %s: %v", userID, err)
+	}
+}
+
+// Invalidate drops the cached pages of a user's orders
+func (c *OrderListCache) Invalidate(ctx context.Context, userID string) error {
+	for page := 1; page <= c.cfg.Pages; page++ {
+		if err := c.cache.Delete(ctx, c.key(userID, page)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleOrderChanged drops the cached lists of the user of an order.created
+// or order.status_changed event
+func (c *OrderListCache) HandleOrderChanged(ctx context.Context, event *events.Event) error {
+	userID, _ := event.Data["user_id"].(string)
+	if userID == "" {
+		return fmt.Errorf("malformed %s event %s", event.Type, event.ID)
+	}
+	return c.Invalidate(ctx, userID)
+}
@@ -5,24 +5,54 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/grpcclient"
+	"microservices-platform/pkg/instrumentation"
+	"microservices-platform/pkg/versioning"
 	"microservices-platform/services/order-service/internal/config"
 	"microservices-platform/services/order-service/internal/database"
 	"microservices-platform/services/order-service/internal/repository"
+	paymentpb "microservices-platform/pkg/proto/payment/v1"
 	productpb "microservices-platform/pkg/proto/product/v1"
 	userpb "microservices-platform/pkg/proto/user/v1"
 )
 
 // OrderService interface defines order business logic operations
 type OrderService interface {
-	CreateOrder(ctx context.Context, userID string, items []CreateOrderItem, shippingAddress, billingAddress string) (*database.Order, error)
+	// CreateOrder places an order for a user. When organizationID is set the
+	// user must be a member and the order is shared with the organization.
+	// The order's delivery dates are estimated from shippingMethod, or the
+	// default method if it is empty, and the shipping address.
+	CreateOrder(ctx context.Context, userID, organizationID string, items []CreateOrderItem, shippingAddress, billingAddress OrderAddress, shippingMethod string) (*database.Order, error)
 	GetOrder(ctx context.Context, id string) (*database.Order, error)
-	UpdateOrderStatus(ctx context.Context, id, status string) (*database.Order, error)
-	ListOrders(ctx context.Context, userID string, page, pageSize int, statusFilter string) ([]*database.Order, int64, error)
+	// UpdateOrderStatus updates an order's status if its version satisfies
+	// match
+	UpdateOrderStatus(ctx context.Context, id, status string, match *versioning.Precondition) (*database.Order, error)
+	// ListOrders lists a user's orders, or an organization's orders when
+	// organizationID is set and the user is a member
+	ListOrders(ctx context.Context, userID, organizationID string, page, pageSize int, statusFilter string) ([]*database.Order, int64, error)
+	// StreamOrders passes all orders ListOrders would list to fn, batchSize
+	// at a time
+	StreamOrders(ctx context.Context, userID, organizationID, statusFilter string, batchSize int, fn func([]*database.Order) error) error
 	CancelOrder(ctx context.Context, id, reason string) (*database.Order, error)
+	AnonymizeUserOrders(ctx context.Context, userID string) error
+	// CreateShipment ships items of an order, capturing their price from an
+	// authorized payment
+	CreateShipment(ctx context.Context, orderID string, items []ShipmentItem, carrier, trackingNumber string) (*database.Shipment, *database.Order, error)
+	ListShipments(ctx context.Context, orderID string) ([]*database.Shipment, error)
+	// HandlePaymentAuthorized records a payment authorized for an order
+	HandlePaymentAuthorized(ctx context.Context, event *events.Event) error
+	// HandleAuthorizationExpired records that an order's payment
+	// authorization lapsed
+	HandleAuthorizationExpired(ctx context.Context, event *events.Event) error
+	// HandleShipmentTracking revises an order's delivery estimate from a
+	// carrier tracking event
+	HandleShipmentTracking(ctx context.Context, event *events.Event) error
 }
 
 // CreateOrderItem represents an item to be added to an order
@@ -31,6 +61,13 @@ type CreateOrderItem struct {
 	Quantity  int32
 }
 
+// OrderAddress identifies an order address either by a saved address ID in
+// user-service or, for backwards compatibility, by free text
+type OrderAddress struct {
+	AddressID string
+	Text      string
+}
+
 // orderService implements OrderService interface
 type orderService struct {
 	orderRepo         repository.OrderRepository
@@ -38,39 +75,88 @@ type orderService struct {
 	productServiceConn *grpc.ClientConn
 	userClient        userpb.UserServiceClient
 	productClient     productpb.ProductServiceClient
+	inventoryClient   productpb.InventoryServiceClient
+	paymentClient     paymentpb.PaymentServiceClient
+	eta               ETAEstimator
+	archive           ArchiveService
+	listCache         *OrderListCache
+	eventBus          events.EventBus
 }
 
-// NewOrderService creates a new order service
-func NewOrderService(orderRepo repository.OrderRepository, cfg *config.Config) OrderService {
+// NewOrderService creates a new order service. Archived orders are restored
+// through archive when they are accessed. Users' order lists are cached in
+// listCache unless it is nil. Order events are published to eventBus.
+func NewOrderService(orderRepo repository.OrderRepository, archive ArchiveService, listCache *OrderListCache, eventBus events.EventBus, eta ETAEstimator, cfg *config.Config) OrderService {
 	// Initialize gRPC connections
-	userConn, err := grpc.Dial(cfg.UserServiceURL, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	userConn, err := grpcclient.Dial(cfg.UserServiceURL, cfg.GRPCClient)
 	if err != nil {
 		log.Printf("Failed to connect to user service: %v", err)
 		// In production, you might want to handle this more gracefully
 	}
 
-	productConn, err := grpc.Dial(cfg.ProductServiceURL, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	productConn, err := grpcclient.Dial(cfg.ProductServiceURL, cfg.GRPCClient)
 	if err != nil {
 		log.Printf("Failed to connect to product service: %v", err)
 	}
 
+	paymentConn, err := grpcclient.Dial(cfg.PaymentServiceURL, cfg.GRPCClient)
+	if err != nil {
+		log.Printf("Failed to connect to payment service: %v", err)
+	}
+
 	return &orderService{
 		orderRepo:          orderRepo,
 		userServiceConn:    userConn,
 		productServiceConn: productConn,
 		userClient:         userpb.NewUserServiceClient(userConn),
 		productClient:      productpb.NewProductServiceClient(productConn),
+		inventoryClient:    productpb.NewInventoryServiceClient(productConn),
+		paymentClient:      paymentpb.NewPaymentServiceClient(paymentConn),
+		eta:                eta,
+		archive:            archive,
+		listCache:          listCache,
+		eventBus:           eventBus,
+	}
+}
+
+// loadOrder retrieves an order by ID, restoring it first if it is archived
+func (s *orderService) loadOrder(ctx context.Context, id string) (*database.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, id)
+	if err != nil || order == nil {
+		return order, err
 	}
+	return s.archive.Rehydrate(ctx, order)
 }
 
 // CreateOrder creates a new order
-func (s *orderService) CreateOrder(ctx context.Context, userID string, items []CreateOrderItem, shippingAddress, billingAddress string) (*database.Order, error) {
+func (s *orderService) CreateOrder(ctx context.Context, userID, organizationID string, items []CreateOrderItem, shippingAddress, billingAddress OrderAddress, shippingMethod string) (*database.Order, error) {
 	// Verify user exists
 	_, err := s.userClient.GetUser(ctx, &userpb.GetUserRequest{UserId: userID})
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify user: %v", err)
 	}
 
+	if organizationID != "" {
+		if err := s.requireMembership(ctx, organizationID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	method, err := s.eta.Method(shippingMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve saved addresses into snapshots stored on the order
+	shippingSnapshot, destination, err := s.resolveAddress(ctx, userID, shippingAddress, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shipping address: %v", err)
+	}
+	billingSnapshot, _, err := s.resolveAddress(ctx, userID, billingAddress, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid billing address: %v", err)
+	}
+
 	// Create order items and calculate total
 	var orderItems []database.OrderItem
 	var totalAmount float64
@@ -97,28 +183,117 @@ func (s *orderService) CreateOrder(ctx context.Context, userID string, items []C
 		orderItems = append(orderItems, orderItem)
 		totalAmount += totalPrice
 	}
+	ctx = instrumentation.WithBusinessContext(ctx, instrumentation.BaggageCartValueBucket, instrumentation.CartValueBucket(totalAmount))
 
-	// Create order
+	// An order is still placed if its delivery can't be estimated
+	estimate, err := s.eta.Estimate(ctx, method, destination, time.Now())
+	if err != nil {
+		log.Printf("Failed to estimate delivery for user %s: %v", userID, err)
+		estimate = &DeliveryEstimate{}
+	}
+
+	// Create order. Its ID is chosen here so its stock can be reserved
+	// before it is saved.
 	order := &database.Order{
+		ID:              newID(),
 		UserID:          userID,
 		Items:           orderItems,
 		TotalAmount:     totalAmount,
 		Status:          "pending",
-		ShippingAddress: shippingAddress,
-		BillingAddress:  billingAddress,
+		ShippingAddress:   shippingSnapshot,
+		BillingAddress:    billingSnapshot,
+		ShippingAddressID: shippingAddress.AddressID,
+		BillingAddressID:  billingAddress.AddressID,
+		OrganizationID:    organizationID,
+		ShippingMethod:    method,
+		EstimateSource:    estimate.Source,
+	}
+	if !estimate.Earliest.IsZero() {
+		order.EstimatedDeliveryEarliest = &estimate.Earliest
+		order.EstimatedDeliveryLatest = &estimate.Latest
 	}
 
+	if err := s.reserveInventory(ctx, order); err != nil {
+		return nil, err
+	}
 	err = s.orderRepo.Create(ctx, order)
 	if err != nil {
+		s.releaseInventory(ctx, order.ID, "order could not be saved")
 		return nil, err
 	}
+	instrumentation.WithBusinessContext(ctx, instrumentation.BaggageOrderID, order.ID)
+
+	// Users usually list their orders right after placing one, so their
+	// cached lists are dropped now rather than when the event arrives
+	if s.listCache != nil {
+		if err := s.listCache.Invalidate(ctx, userID); err != nil {
+			log.Printf("Failed to invalidate cached orders of user %s: %v", userID, err)
+		}
+	}
+	s.publishOrderEvent(ctx, events.OrderCreated, order, map[string]interface{}{
+		"total_amount": order.TotalAmount,
+		"item_count":   len(order.Items),
+	})
 
 	return order, nil
 }
 
+// resolveAddress returns the address text to store on the order and where it
+// is. Saved addresses are looked up in user-service and must belong to the
+// user; when nothing is supplied the user's default address for the purpose
+// is used. Free-text addresses have no known destination.
+func (s *orderService) resolveAddress(ctx context.Context, userID string, address OrderAddress, shipping bool) (string, Destination, error) {
+	if address.AddressID == "" {
+		if address.Text != "" {
+			return address.Text, Destination{}, nil
+		}
+
+		resp, err := s.userClient.ListAddresses(ctx, &userpb.ListAddressesRequest{UserId: userID})
+		if err != nil {
+			return "", Destination{}, fmt.Errorf("failed to load saved addresses: %v", err)
+		}
+		for _, saved := range resp.Addresses {
+			if (shipping && saved.IsDefaultShipping) || (!shipping && saved.IsDefaultBilling) {
+				return formatAddress(saved), addressDestination(saved), nil
+			}
+		}
+		return "", Destination{}, errors.New("no address provided and no default address on file")
+	}
+
+	resp, err := s.userClient.GetAddress(ctx, &userpb.GetAddressRequest{
+		UserId:    userID,
+		AddressId: address.AddressID,
+	})
+	if err != nil {
+		return "", Destination{}, fmt.Errorf("failed to get address %s: %v", address.AddressID, err)
+	}
+
+	return formatAddress(resp.Address), addressDestination(resp.Address), nil
+}
+
+// addressDestination returns where a saved address is
+func addressDestination(address *userpb.Address) Destination {
+	return Destination{Country: address.Country, PostalCode: address.PostalCode}
+}
+
+// formatAddress renders a saved address as the single-line snapshot stored on orders
+func formatAddress(address *userpb.Address) string {
+	parts := []string{address.RecipientName, address.Line1}
+	if address.Line2 != "" {
+		parts = append(parts, address.Line2)
+	}
+	cityLine := address.City
+	if address.State != "" {
+		cityLine += ", " + address.State
+	}
+	cityLine += " " + address.PostalCode
+	parts = append(parts, cityLine, address.Country)
+	return strings.Join(parts, ", ")
+}
+
 // GetOrder retrieves an order by ID
 func (s *orderService) GetOrder(ctx context.Context, id string) (*database.Order, error) {
-	order, err := s.orderRepo.GetByID(ctx, id)
+	order, err := s.loadOrder(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -130,36 +305,102 @@ func (s *orderService) GetOrder(ctx context.Context, id string) (*database.Order
 }
 
 // UpdateOrderStatus updates the status of an order
-func (s *orderService) UpdateOrderStatus(ctx context.Context, id, status string) (*database.Order, error) {
+func (s *orderService) UpdateOrderStatus(ctx context.Context, id, status string, match *versioning.Precondition) (*database.Order, error) {
 	// Verify order exists
-	order, err := s.orderRepo.GetByID(ctx, id)
+	order, err := s.loadOrder(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	if order == nil {
 		return nil, errors.New("order not found")
 	}
+	if !match.Matches(order.Version) {
+		return nil, versioning.ErrConflict
+	}
 
 	// Update status
-	err = s.orderRepo.UpdateStatus(ctx, id, status)
+	err = s.orderRepo.UpdateStatus(ctx, id, status, order.Version)
 	if err != nil {
 		return nil, err
 	}
+	s.publishStatusChanged(ctx, order, status)
 
 	// Return updated order
 	return s.orderRepo.GetByID(ctx, id)
 }
 
-// ListOrders lists orders for a user with pagination and filtering
-func (s *orderService) ListOrders(ctx context.Context, userID string, page, pageSize int, statusFilter string) ([]*database.Order, int64, error) {
+// ListOrders lists orders for a user or organization with pagination and filtering
+func (s *orderService) ListOrders(ctx context.Context, userID, organizationID string, page, pageSize int, statusFilter string) ([]*database.Order, int64, error) {
 	offset := (page - 1) * pageSize
-	return s.orderRepo.ListByUserID(ctx, userID, offset, pageSize, statusFilter)
+	if organizationID != "" {
+		if err := s.requireMembership(ctx, organizationID, userID); err != nil {
+			return nil, 0, err
+		}
+		return s.orderRepo.ListByOrganizationID(ctx, organizationID, offset, pageSize, statusFilter)
+	}
+
+	if s.listCache == nil {
+		return s.orderRepo.ListByUserID(ctx, userID, offset, pageSize, statusFilter)
+	}
+	if orders, total, ok := s.listCache.Get(ctx, userID, page, pageSize, statusFilter); ok {
+		return orders, total, nil
+	}
+	orders, total, err := s.orderRepo.ListByUserID(ctx, userID, offset, pageSize, statusFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.listCache.Set(ctx, userID, page, pageSize, statusFilter, orders, total)
+	return orders, total, nil
+}
+
+// StreamOrders passes a user's or an organization's orders to fn in batches
+func (s *orderService) StreamOrders(ctx context.Context, userID, organizationID, statusFilter string, batchSize int, fn func([]*database.Order) error) error {
+	batchSize = streamBatchSize(batchSize)
+	if organizationID != "" {
+		if err := s.requireMembership(ctx, organizationID, userID); err != nil {
+			return err
+		}
+		return s.orderRepo.StreamByOrganizationID(ctx, organizationID, statusFilter, batchSize, fn)
+	}
+	return s.orderRepo.StreamByUserID(ctx, userID, statusFilter, batchSize, fn)
+}
+
+// Batch sizes for streamed order lists
+const (
+	defaultStreamBatchSize = 500
+	maxStreamBatchSize     = 1000
+)
+
+// streamBatchSize bounds a requested stream batch size
+func streamBatchSize(requested int) int {
+	if requested <= 0 {
+		return defaultStreamBatchSize
+	}
+	if requested > maxStreamBatchSize {
+		return maxStreamBatchSize
+	}
+	return requested
+}
+
+// requireMembership checks in user-service that a user belongs to an organization
+func (s *orderService) requireMembership(ctx context.Context, organizationID, userID string) error {
+	resp, err := s.userClient.GetOrganizationMembership(ctx, &userpb.GetOrganizationMembershipRequest{
+		OrganizationId: organizationID,
+		UserId:         userID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify organization membership: %v", err)
+	}
+	if resp.Membership == nil {
+		return errors.New("user is not a member of the organization")
+	}
+	return nil
 }
 
 // CancelOrder cancels an order
 func (s *orderService) CancelOrder(ctx context.Context, id, reason string) (*database.Order, error) {
 	// Get current order
-	order, err := s.orderRepo.GetByID(ctx, id)
+	order, err := s.loadOrder(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -168,16 +409,126 @@ func (s *orderService) CancelOrder(ctx context.Context, id, reason string) (*dat
 	}
 
 	// Check if order can be cancelled
-	if order.Status == "shipped" || order.Status == "delivered" || order.Status == "cancelled" {
+	if order.Status == "shipped" || order.Status == "partially_shipped" || order.Status == "delivered" || order.Status == "cancelled" {
 		return nil, fmt.Errorf("cannot cancel order with status: %s", order.Status)
 	}
 
 	// Update status to cancelled
-	err = s.orderRepo.UpdateStatus(ctx, id, "cancelled")
+	err = s.orderRepo.UpdateStatus(ctx, id, "cancelled", order.Version)
 	if err != nil {
 		return nil, err
 	}
+	s.publishStatusChanged(ctx, order, "cancelled")
+	s.releaseInventory(ctx, order.ID, reason)
+
+	// Release the payment authorization; if that fails it lapses on its own
+	if order.PaymentID != "" && order.PaymentStatus == database.PaymentStatusAuthorized {
+		s.voidAuthorization(ctx, order, reason)
+	}
 
 	// Return updated order
 	return s.orderRepo.GetByID(ctx, id)
-}
\ No newline at end of file
+}
+
+// AnonymizeUserOrders is order-service's step in a user deletion. Saved and
+// snapshotted addresses are removed; order lines and totals are kept.
+func (s *orderService) AnonymizeUserOrders(ctx context.Context, userID string) error {
+	if err := s.orderRepo.AnonymizeByUserID(ctx, userID); err != nil {
+		return err
+	}
+	if s.listCache != nil {
+		return s.listCache.Invalidate(ctx, userID)
+	}
+	return nil
+}
+
+// publishOrderEvent publishes an event about an order. The order is already
+// saved, so a failure is only logged.
+func (s *orderService) publishOrderEvent(ctx context.Context, eventType events.EventType, order *database.Order, data map[string]interface{}) {
+	data["order_id"] = order.ID
+	data["user_id"] = order.UserID
+	if order.OrganizationID != "" {
+		data["organization_id"] = order.OrganizationID
+	}
+	err := s.eventBus.Publish(ctx, &events.Event{
+		Type:    eventType,
+		Source:  "order-service",
+		Subject: order.ID,
+		Data:    data,
+	})
+	if err != nil {
+		log.Printf("Failed to publish %s for order %s: %v", eventType, order.ID, err)
+	}
+}
+
+// publishStatusChanged publishes order.status_changed if an order's status
+// changed from the one it was read with
+func (s *orderService) publishStatusChanged(ctx context.Context, order *database.Order, status string) {
+	if status == order.Status {
+		return
+	}
+	s.publishOrderEvent(ctx, events.OrderStatusChanged, order, map[string]interface{}{
+		"previous_status": order.Status,
+		"status":          status,
+	})
+}
+
+// Shipment tracking statuses that settle the delivery date
+const (
+	trackingOutForDelivery = "out_for_delivery"
+	trackingDelivered      = "delivered"
+)
+
+// HandleShipmentTracking applies a shipment.tracking_updated event to its
+// order. Out for delivery and delivered settle the delivery date; other
+// statuses revise it when the carrier reports a new estimate. Events older
+// than the last one applied, and events for delivered orders, are ignored.
+func (s *orderService) HandleShipmentTracking(ctx context.Context, event *events.Event) error {
+	orderID, _ := event.Data["order_id"].(string)
+	trackingStatus, _ := event.Data["status"].(string)
+	rawOccurredAt, _ := event.Data["occurred_at"].(string)
+	occurredAt, err := time.Parse(time.RFC3339, rawOccurredAt)
+	if orderID == "" || err != nil {
+		return fmt.Errorf("malformed shipment tracking event %s", event.ID)
+	}
+	var carrierEstimate time.Time
+	if raw, _ := event.Data["estimated_delivery"].(string); raw != "" {
+		if carrierEstimate, err = time.Parse("2006-01-02", raw); err != nil {
+			return fmt.Errorf("malformed estimated_delivery in shipment tracking event %s", event.ID)
+		}
+	}
+	occurredAt = occurredAt.UTC()
+	occurredDay := time.Date(occurredAt.Year(), occurredAt.Month(), occurredAt.Day(), 0, 0, 0, 0, time.UTC)
+
+	return s.updateOrder(ctx, orderID, func(order *database.Order) map[string]interface{} {
+		if order.DeliveredAt != nil {
+			return nil
+		}
+		if order.TrackingUpdatedAt != nil && occurredAt.Before(*order.TrackingUpdatedAt) {
+			return nil
+		}
+
+		fields := map[string]interface{}{"tracking_updated_at": occurredAt}
+		if carrier, _ := event.Data["carrier"].(string); carrier != "" {
+			fields["carrier"] = carrier
+		}
+		if trackingNumber, _ := event.Data["tracking_number"].(string); trackingNumber != "" {
+			fields["tracking_number"] = trackingNumber
+		}
+
+		estimate := carrierEstimate
+		switch trackingStatus {
+		case trackingDelivered:
+			fields["delivered_at"] = occurredAt
+			estimate = occurredDay
+		case trackingOutForDelivery:
+			estimate = occurredDay
+		}
+		if !estimate.IsZero() {
+			fields["estimated_delivery_earliest"] = estimate
+			fields["estimated_delivery_latest"] = estimate
+			fields["estimate_source"] = EstimateSourceTracking
+		}
+		return fields
+	})
+}
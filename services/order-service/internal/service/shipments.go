@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"microservices-platform/pkg/events"
+	paymentpb "microservices-platform/pkg/proto/payment/v1"
+	"microservices-platform/pkg/versioning"
+	"microservices-platform/services/order-service/internal/database"
+)
+
+// ShipmentItem is the quantity of an order item to ship
+type ShipmentItem struct {
+	ItemID   string
+	Quantity int32
+}
+
+// Order statuses set by shipments
+const (
+	orderStatusPartiallyShipped = "partially_shipped"
+	orderStatusShipped          = "shipped"
+)
+
+// CreateShipment ships items of an order, or all of its unshipped items if
+// none are given. If the order is paid by an authorization, the shipped
+// items' price is captured from it first, and the last shipment captures
+// whatever is left of the authorization. Captures are keyed by the order and
+// the shipment's number, so retrying a shipment after a failure does not
+// capture twice.
+func (s *orderService) CreateShipment(ctx context.Context, orderID string, items []ShipmentItem, carrier, trackingNumber string) (*database.Shipment, *database.Order, error) {
+	order, err := s.loadOrder(ctx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if order == nil {
+		return nil, nil, errors.New("order not found")
+	}
+	switch order.Status {
+	case "pending", "confirmed", "processing", orderStatusPartiallyShipped:
+	default:
+		return nil, nil, fmt.Errorf("cannot ship order with status: %s", order.Status)
+	}
+
+	shipments, err := s.orderRepo.ListShipments(ctx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	shippedQuantities := make(map[string]int32)
+	for _, shipment := range shipments {
+		for _, item := range shipment.Items {
+			shippedQuantities[item.OrderItemID] += item.Quantity
+		}
+	}
+
+	remaining := make(map[string]int32)
+	prices := make(map[string]float64)
+	for _, item := range order.Items {
+		remaining[item.ID] = item.Quantity - shippedQuantities[item.ID]
+		prices[item.ID] = item.UnitPrice
+	}
+	if len(items) == 0 {
+		for _, item := range order.Items {
+			if remaining[item.ID] > 0 {
+				items = append(items, ShipmentItem{ItemID: item.ID, Quantity: remaining[item.ID]})
+			}
+		}
+		if len(items) == 0 {
+			return nil, nil, errors.New("all items of the order have already shipped")
+		}
+	}
+
+	shipment := &database.Shipment{
+		ID:             newID(),
+		OrderID:        orderID,
+		Carrier:        carrier,
+		TrackingNumber: trackingNumber,
+	}
+	var amount float64
+	for _, item := range items {
+		left, ok := remaining[item.ItemID]
+		if !ok {
+			return nil, nil, fmt.Errorf("order has no item %s", item.ItemID)
+		}
+		if item.Quantity <= 0 || item.Quantity > left {
+			return nil, nil, fmt.Errorf("cannot ship %d of item %s, %d left to ship", item.Quantity, item.ItemID, left)
+		}
+		remaining[item.ItemID] = left - item.Quantity
+		amount += prices[item.ItemID] * float64(item.Quantity)
+		shipment.Items = append(shipment.Items, database.ShipmentItem{OrderItemID: item.ItemID, Quantity: item.Quantity})
+	}
+	complete := true
+	for _, left := range remaining {
+		if left > 0 {
+			complete = false
+			break
+		}
+	}
+	shipment.Amount = roundCents(amount)
+
+	fields := map[string]interface{}{"status": orderStatusPartiallyShipped}
+	if complete {
+		fields["status"] = orderStatusShipped
+	}
+	if carrier != "" {
+		fields["carrier"] = carrier
+	}
+	if trackingNumber != "" {
+		fields["tracking_number"] = trackingNumber
+	}
+
+	if order.PaymentID != "" {
+		switch order.PaymentStatus {
+		case database.PaymentStatusAuthorized, database.PaymentStatusPartiallyCaptured:
+		default:
+			return nil, nil, fmt.Errorf("cannot capture payment with status %s for the shipment", order.PaymentStatus)
+		}
+
+		captureAmount := shipment.Amount
+		if complete {
+			// Settle the authorization, whatever rounding left on it
+			captureAmount = roundCents(order.AuthorizedAmount - order.CapturedAmount)
+		}
+		resp, err := s.paymentClient.CapturePayment(ctx, &paymentpb.CapturePaymentRequest{
+			PaymentId:      order.PaymentID,
+			Amount:         captureAmount,
+			ShipmentId:     shipment.ID,
+			FinalCapture:   complete,
+			IdempotencyKey: fmt.Sprintf("order:%s:shipment:%d", orderID, len(shipments)+1),
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to capture payment for shipment: %v", err)
+		}
+		shipment.CaptureID = resp.Capture.GetCaptureId()
+
+		fields["captured_amount"] = roundCents(order.CapturedAmount + captureAmount)
+		fields["payment_status"] = database.PaymentStatusPartiallyCaptured
+		if complete {
+			fields["payment_status"] = database.PaymentStatusCaptured
+		}
+	}
+
+	if err := s.orderRepo.CreateShipment(ctx, shipment, fields, order.Version); err != nil {
+		if shipment.CaptureID != "" {
+			log.Printf("Captured %s for order %s but failed to record shipment: %v", shipment.CaptureID, orderID, err)
+		}
+		return nil, nil, err
+	}
+	s.publishStatusChanged(ctx, order, fields["status"].(string))
+
+	updated, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return shipment, updated, nil
+}
+
+// ListShipments lists an order's shipments, restoring them first if the
+// order is archived
+func (s *orderService) ListShipments(ctx context.Context, orderID string) ([]*database.Shipment, error) {
+	if _, err := s.loadOrder(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.ListShipments(ctx, orderID)
+}
+
+// HandlePaymentAuthorized records a payment.authorized event on its order,
+// confirms the order if it is still pending and commits its stock
+func (s *orderService) HandlePaymentAuthorized(ctx context.Context, event *events.Event) error {
+	orderID, _ := event.Data["order_id"].(string)
+	paymentID, _ := event.Data["payment_id"].(string)
+	amount, _ := event.Data["amount"].(float64)
+	rawExpiresAt, _ := event.Data["expires_at"].(string)
+	expiresAt, err := time.Parse(time.RFC3339, rawExpiresAt)
+	if orderID == "" || paymentID == "" || err != nil {
+		return fmt.Errorf("malformed payment authorized event %s", event.ID)
+	}
+
+	err = s.updateOrder(ctx, orderID, func(order *database.Order) map[string]interface{} {
+		if order.PaymentID == paymentID {
+			return nil
+		}
+		fields := map[string]interface{}{
+			"payment_id":               paymentID,
+			"payment_status":           database.PaymentStatusAuthorized,
+			"authorized_amount":        amount,
+			"captured_amount":          0,
+			"authorization_expires_at": expiresAt.UTC(),
+		}
+		if order.Status == "pending" {
+			fields["status"] = "confirmed"
+		}
+		return fields
+	})
+	if err != nil {
+		return err
+	}
+	// Committing again on a redelivered event is a no-op
+	return s.commitInventory(ctx, orderID)
+}
+
+// HandleAuthorizationExpired records that an order's payment authorization
+// lapsed. Orders none of which was captured are cancelled and their stock
+// released; partially shipped orders keep their status, as the rest of their
+// items can no longer be paid for by the authorization.
+func (s *orderService) HandleAuthorizationExpired(ctx context.Context, event *events.Event) error {
+	orderID, _ := event.Data["order_id"].(string)
+	paymentID, _ := event.Data["payment_id"].(string)
+	if orderID == "" || paymentID == "" {
+		return fmt.Errorf("malformed payment authorization expired event %s", event.ID)
+	}
+
+	var cancelled bool
+	err := s.updateOrder(ctx, orderID, func(order *database.Order) map[string]interface{} {
+		cancelled = false
+		if order.PaymentID != paymentID {
+			return nil
+		}
+		switch order.PaymentStatus {
+		case database.PaymentStatusAuthorized, database.PaymentStatusPartiallyCaptured:
+		default:
+			return nil
+		}
+
+		fields := map[string]interface{}{"payment_status": database.PaymentStatusExpired}
+		switch order.Status {
+		case "pending", "confirmed", "processing":
+			if order.CapturedAmount == 0 {
+				fields["status"] = "cancelled"
+				cancelled = true
+			}
+		}
+		log.Printf("Payment authorization %s for order %s expired with %.2f of %.2f captured",
+			paymentID, orderID, order.CapturedAmount, order.AuthorizedAmount)
+		return fields
+	})
+	if err == nil && cancelled {
+		s.releaseInventory(ctx, orderID, "payment authorization expired")
+	}
+	return err
+}
+
+// voidAuthorization releases the payment authorized for a cancelled order
+func (s *orderService) voidAuthorization(ctx context.Context, order *database.Order, reason string) {
+	_, err := s.paymentClient.VoidPayment(ctx, &paymentpb.VoidPaymentRequest{
+		PaymentId: order.PaymentID,
+		Reason:    reason,
+	})
+	if err != nil {
+		log.Printf("Failed to void payment %s of cancelled order %s: %v", order.PaymentID, order.ID, err)
+		return
+	}
+
+	err = s.updateOrder(ctx, order.ID, func(current *database.Order) map[string]interface{} {
+		if current.PaymentID != order.PaymentID {
+			return nil
+		}
+		return map[string]interface{}{"payment_status": database.PaymentStatusVoided}
+	})
+	if err != nil {
+		log.Printf("Voided payment %s but failed to record it on order %s: %v", order.PaymentID, order.ID, err)
+	}
+}
+
+// maxOrderUpdateAttempts bounds retries when an order changes while an event
+// is applied to it
+const maxOrderUpdateAttempts = 3
+
+// updateOrder sets the fields fn returns for the current order, retrying if
+// the order changes meanwhile. Nothing is updated if the order does not exist
+// or fn returns no fields.
+func (s *orderService) updateOrder(ctx context.Context, orderID string, fn func(*database.Order) map[string]interface{}) error {
+	for attempt := 0; attempt < maxOrderUpdateAttempts; attempt++ {
+		order, err := s.loadOrder(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		if order == nil {
+			return nil
+		}
+		fields := fn(order)
+		if len(fields) == 0 {
+			return nil
+		}
+
+		err = s.orderRepo.UpdateFields(ctx, orderID, fields, order.Version)
+		if err == nil {
+			if status, ok := fields["status"].(string); ok {
+				s.publishStatusChanged(ctx, order, status)
+			}
+			return nil
+		}
+		if !errors.Is(err, versioning.ErrConflict) {
+			return err
+		}
+	}
+	return fmt.Errorf("order %s kept changing while it was updated", orderID)
+}
+
+// roundCents rounds an amount to whole cents
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// newID generates a random UUID, so a shipment's ID is known before its
+// capture is requested and an order's before its stock is reserved
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("00000000-0000-4000-8000-%012x", time.Now().UnixNano()&0xffffffffffff)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/ledger"
+	"microservices-platform/services/order-service/internal/config"
+	"microservices-platform/services/order-service/internal/database"
+	"microservices-platform/services/order-service/internal/repository"
+)
+
+// Checks made by the totals audit
+const (
+	// CheckItemTotal compares an item's total with its unit price times its
+	// quantity
+	CheckItemTotal = "item_total"
+	// CheckOrderTotal compares an order's total with the sum of its items
+	CheckOrderTotal = "order_total"
+	// CheckCapturedAmount compares an order's captured amount with the sales
+	// the ledger recorded for its payment and captures
+	CheckCapturedAmount = "captured_amount"
+)
+
+// totalsAuditPollInterval is how often instances check whether the day's
+// audit is due
+const totalsAuditPollInterval = 5 * time.Minute
+
+// TotalsAuditService recomputes stored order amounts in cents and reports
+// those that diverge
+type TotalsAuditService interface {
+	// Start runs each day's audit after the configured hour until ctx is
+	// cancelled. Any number of instances can run it; each day is audited once.
+	Start(ctx context.Context)
+	// Run audits all orders now as the run of the given day, returning nil if
+	// the day was already audited
+	Run(ctx context.Context, day time.Time) (*database.TotalsAuditRun, error)
+	// GetRun returns a run and its divergences, or the latest run if id is
+	// empty
+	GetRun(ctx context.Context, id string) (*database.TotalsAuditRun, error)
+	ListRuns(ctx context.Context, page, pageSize int) ([]*database.TotalsAuditRun, int64, error)
+}
+
+// totalsAuditService implements TotalsAuditService
+type totalsAuditService struct {
+	auditRepo repository.AuditRepository
+	ledger    *ledger.Ledger
+	eventBus  events.EventBus
+	cfg       config.TotalsAuditConfig
+}
+
+// NewTotalsAuditService creates a new totals audit service. Captured amounts
+// are only checked if ledgerDB is not nil.
+func NewTotalsAuditService(auditRepo repository.AuditRepository, ledgerDB *ledger.Ledger, eventBus events.EventBus, cfg config.TotalsAuditConfig) TotalsAuditService {
+	return &totalsAuditService{
+		auditRepo: auditRepo,
+		ledger:    ledgerDB,
+		eventBus:  eventBus,
+		cfg:       cfg,
+	}
+}
+
+// Start polls for the day's audit
+func (s *totalsAuditService) Start(ctx context.Context) {
+	ticker := time.NewTicker(totalsAuditPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now().UTC()
+			if now.Hour() < s.cfg.Hour {
+				continue
+			}
+			if _, err := s.Run(ctx, now); err != nil {
+				log.Printf("Totals audit failed: %v", err)
+			}
+		}
+	}
+}
+
+// Run claims the day's run and checks every order
+func (s *totalsAuditService) Run(ctx context.Context, day time.Time) (*database.TotalsAuditRun, error) {
+	run, err := s.auditRepo.ClaimRun(ctx, day.UTC().Format("2006-01-02"), time.Now().UTC())
+	if err != nil || run == nil {
+		return nil, err
+	}
+	log.Printf("Totals audit %s started", run.ID)
+
+	err = s.auditRepo.StreamOrders(ctx, s.cfg.BatchSize, func(orders []*database.Order) error {
+		divergences, err := s.audit(ctx, orders)
+		if err != nil {
+			return err
+		}
+		for i := range divergences {
+			divergences[i].RunID = run.ID
+		}
+		if err := s.auditRepo.AddDivergences(ctx, divergences); err != nil {
+			return err
+		}
+		for _, divergence := range divergences {
+			s.publishDivergence(ctx, divergence)
+		}
+		run.OrdersChecked += int64(len(orders))
+		run.DivergenceCount += int64(len(divergences))
+		return nil
+	})
+
+	finishedAt := time.Now().UTC()
+	run.FinishedAt = &finishedAt
+	run.Status = database.TotalsAuditCompleted
+	if err != nil {
+		run.Status = database.TotalsAuditFailed
+		run.Error = err.Error()
+	}
+	if finishErr := s.auditRepo.FinishRun(ctx, run); finishErr != nil {
+		log.Printf("Failed to record outcome of totals audit %s: %v", run.ID, finishErr)
+	}
+	log.Printf("Totals audit %s %s: %d orders checked, %d divergences",
+		run.ID, run.Status, run.OrdersChecked, run.DivergenceCount)
+	return run, err
+}
+
+// audit recomputes a batch of orders' amounts in cents, so that the float
+// arithmetic they were stored with cannot hide or cause a difference
+func (s *totalsAuditService) audit(ctx context.Context, orders []*database.Order) ([]database.TotalsDivergence, error) {
+	var divergences []database.TotalsDivergence
+	diverged := func(order *database.Order, check string, expected, actual int64, detail string) {
+		divergences = append(divergences, database.TotalsDivergence{
+			OrderID:       order.ID,
+			CheckType:     check,
+			ExpectedCents: expected,
+			ActualCents:   actual,
+			Detail:        detail,
+		})
+	}
+
+	for _, order := range orders {
+		var itemsTotal int64
+		for _, item := range order.Items {
+			itemTotal := ledger.MinorUnits(item.UnitPrice) * int64(item.Quantity)
+			if stored := ledger.MinorUnits(item.TotalPrice); stored != itemTotal {
+				diverged(order, CheckItemTotal, itemTotal, stored, "item "+item.ID)
+			}
+			itemsTotal += itemTotal
+		}
+		if stored := ledger.MinorUnits(order.TotalAmount); stored != itemsTotal {
+			diverged(order, CheckOrderTotal, itemsTotal, stored, "")
+		}
+	}
+
+	if s.ledger == nil {
+		return divergences, nil
+	}
+	captured, err := s.ledgerCaptures(ctx, orders)
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		if order.PaymentID == "" {
+			continue
+		}
+		if stored := ledger.MinorUnits(order.CapturedAmount); stored != captured[order.ID] {
+			diverged(order, CheckCapturedAmount, captured[order.ID], stored, "payment "+order.PaymentID)
+		}
+	}
+	return divergences, nil
+}
+
+// ledgerCaptures returns the sales the ledger recorded for each order's
+// payment and the captures of its shipments, in cents
+func (s *totalsAuditService) ledgerCaptures(ctx context.Context, orders []*database.Order) (map[string]int64, error) {
+	var orderIDs []string
+	for _, order := range orders {
+		if order.PaymentID != "" {
+			orderIDs = append(orderIDs, order.ID)
+		}
+	}
+	captureIDs, err := s.auditRepo.CaptureIDs(ctx, orderIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	referenceOrder := make(map[string]string)
+	var references []string
+	for _, order := range orders {
+		if order.PaymentID == "" {
+			continue
+		}
+		for _, reference := range append([]string{order.PaymentID}, captureIDs[order.ID]...) {
+			referenceOrder[reference] = order.ID
+			references = append(references, reference)
+		}
+	}
+
+	totals, err := s.ledger.ReferenceTotals(ctx, ledger.AccountSales, references)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %v", err)
+	}
+	captured := make(map[string]int64)
+	for reference, total := range totals {
+		// Sales are credited, so they sum negative
+		captured[referenceOrder[reference]] -= total
+	}
+	return captured, nil
+}
+
+// publishDivergence reports a divergence as a data integrity event
+func (s *totalsAuditService) publishDivergence(ctx context.Context, divergence database.TotalsDivergence) {
+	if s.eventBus == nil {
+		return
+	}
+	err := s.eventBus.Publish(ctx, &events.Event{
+		Type:    events.DataIntegrityDivergence,
+		Source:  "order-service",
+		Subject: divergence.OrderID,
+		Data: map[string]interface{}{
+			"run_id":         divergence.RunID,
+			"order_id":       divergence.OrderID,
+			"check":          divergence.CheckType,
+			"expected_cents": divergence.ExpectedCents,
+			"actual_cents":   divergence.ActualCents,
+			"detail":         divergence.Detail,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to publish divergence of order %s: %v", divergence.OrderID, err)
+	}
+}
+
+// GetRun returns a run, or the latest run
+func (s *totalsAuditService) GetRun(ctx context.Context, id string) (*database.TotalsAuditRun, error) {
+	return s.auditRepo.GetRun(ctx, id)
+}
+
+// ListRuns lists runs, newest first
+func (s *totalsAuditService) ListRuns(ctx context.Context, page, pageSize int) ([]*database.TotalsAuditRun, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return s.auditRepo.ListRuns(ctx, (page-1)*pageSize, pageSize)
+}
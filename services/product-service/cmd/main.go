@@ -5,14 +5,11 @@ import (
 	"fmt"
 	"log"
 	"net"
-	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
+	"gorm.io/gorm"
 	
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
@@ -21,6 +18,18 @@ import (
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 
+	"microservices-platform/pkg/app"
+	"microservices-platform/pkg/buildinfo"
+	"microservices-platform/pkg/dbhealth"
+	"microservices-platform/pkg/debug"
+	"microservices-platform/pkg/grpcclient"
+	"microservices-platform/pkg/grpcserver"
+	"microservices-platform/pkg/instrumentation"
+	"microservices-platform/pkg/logging"
+	"microservices-platform/pkg/middleware"
+	"microservices-platform/pkg/schemadrift"
+	"microservices-platform/pkg/startup"
+	"microservices-platform/pkg/watchdog"
 	"microservices-platform/services/product-service/internal/config"
 	"microservices-platform/services/product-service/internal/database"
 	"microservices-platform/services/product-service/internal/handler"
@@ -33,22 +42,109 @@ func main() {
 	// Initialize configuration
 	cfg := config.Load()
 
+	// Compare the database schema with the models and exit, e.g. in CI
+	if len(os.Args) > 1 && os.Args[1] == schemadrift.Command {
+		os.Exit(schemadrift.Main(cfg.ServiceName, cfg.DatabaseURL, cfg.SchemaDrift, database.Models()...))
+	}
+
+	// Filter logs by level and rate limit repeated errors
+	if err := logging.Setup(cfg.Logging); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+
+	// Announce the build and report it in build_info
+	buildinfo.LogStartup(cfg.ServiceName)
+
+	// Refuse debug facilities in production unless explicitly allowed
+	if err := cfg.Debug.Check(cfg.Environment); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+
+	// Run the service through init, migrate, serve, drain and shutdown.
+	// Resources are released in the reverse order they are registered.
+	application := app.New(cfg.ServiceName, cfg.App)
+
 	// Initialize OpenTelemetry
 	tp, err := initTracer(cfg.ServiceName)
 	if err != nil {
 		log.Fatalf("Failed to initialize tracer: %v", err)
 	}
-	defer func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
+	application.OnShutdown("tracer", tp.Shutdown)
+
+	// Continuously profile CPU and heap when enabled
+	profiler, err := instrumentation.StartProfiling(cfg.ServiceName, cfg.Observability)
+	if err != nil {
+		log.Fatalf("Failed to start profiling: %v", err)
+	}
+	application.OnShutdown("profiler", func(ctx context.Context) error {
+		profiler.Stop()
+		return nil
+	})
+
+	// Connect to dependencies in order, retrying until they are available.
+	// Readiness reports "starting" until the gRPC server is listening.
+	starter := startup.NewManager(cfg.Startup)
+
+	// Serve metrics, health, build info and the admin and debug endpoints on
+	// the internal observability port
+	application.Handle("/health/", starter.Handler())
+	application.Handle(buildinfo.Path, buildinfo.Handler(cfg.ServiceName))
+	if cfg.Logging.AdminToken != "" {
+		application.Handle(logging.AdminPath, logging.Handler(cfg.Logging.AdminToken))
+	}
+	if cfg.Debug.Pprof {
+		application.Handle(debug.PprofPath, cfg.Debug.PprofHandler())
+	}
+	if err := application.ServeObservability(); err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+
+	var db *gorm.DB
+	starter.Require("postgres", func(ctx context.Context) error {
+		conn, err := database.NewConnection(cfg.DatabaseURL, cfg.Debug.VerboseSQL)
+		if err != nil {
+			return err
 		}
-	}()
+		// Trace every query and explain slow ones
+		if err := conn.Use(instrumentation.NewQueryTracer(cfg.ServiceName, cfg.QueryTracing)); err != nil {
+			return err
+		}
+		db = conn
+		return nil
+	})
+
+	application.OnInit("dependencies", starter.Run)
+	application.OnMigrate("postgres", func(ctx context.Context) error {
+		if err := database.Migrate(db); err != nil {
+			return err
+		}
+		if err := schemadrift.Record(db, cfg.ServiceName, database.Models()...); err != nil {
+			return err
+		}
+		return schemadrift.Verify(ctx, db, cfg.ServiceName, cfg.SchemaDrift, database.Models()...)
+	})
+	if err := application.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
 
-	// Initialize database
-	db, err := database.NewConnection(cfg.DatabaseURL)
+	// Watch the database connection and re-dial after a restart or failover
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to get database handle: %v", err)
 	}
+	application.OnShutdown("postgres", func(ctx context.Context) error {
+		return sqlDB.Close()
+	})
+	dbMonitor := dbhealth.NewMonitor(cfg.ServiceName, "postgres", sqlDB, cfg.Database, nil)
+	dbMonitor.OnChange(func(err error) {
+		starter.Report("postgres", err)
+	})
+	dbMonitor.Start(context.Background())
+
+	// Watch for leaked goroutines, file descriptors and pooled connections
+	leaks := watchdog.New(cfg.ServiceName, cfg.Watchdog)
+	leaks.WatchDB("postgres", sqlDB)
+	leaks.Start(context.Background())
 
 	// Initialize repository
 	productRepo := repository.NewProductRepository(db)
@@ -56,53 +152,76 @@ func main() {
 	// Initialize service
 	productService := service.NewProductService(productRepo, cfg)
 
-	// Initialize gRPC handler
+	// Hold stock for orders and release reservations that lapse
+	inventoryService := service.NewInventoryService(repository.NewInventoryRepository(db), cfg)
+	expiryCtx, stopExpiry := context.WithCancel(context.Background())
+	application.OnServe("inventory-expiry", func(ctx context.Context) error {
+		inventoryService.StartExpiry(expiryCtx)
+		return nil
+	})
+	application.OnDrain("inventory-expiry", func(ctx context.Context) error {
+		stopExpiry()
+		return nil
+	})
+
+	// Initialize gRPC handlers
 	productHandler := handler.NewProductHandler(productService)
-
-	// Create gRPC server with OpenTelemetry interceptors
-	server := grpc.NewServer(
-		grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
-		grpc.StreamInterceptor(otelgrpc.StreamServerInterceptor()),
-	)
+	inventoryHandler := handler.NewInventoryHandler(inventoryService)
+
+	// Create gRPC server with OpenTelemetry interceptors, verifying the
+	// caller identity signed by the gateway when a secret is configured
+	unaryInterceptors := []grpc.UnaryServerInterceptor{otelgrpc.UnaryServerInterceptor()}
+	streamInterceptors := []grpc.StreamServerInterceptor{otelgrpc.StreamServerInterceptor()}
+	if cfg.Identity.Secret != "" {
+		unaryInterceptors = append(unaryInterceptors, middleware.IdentityUnaryInterceptor(cfg.Identity.Secret))
+		streamInterceptors = append(streamInterceptors, middleware.IdentityStreamInterceptor(cfg.Identity.Secret))
+	}
+	serverOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+		// Accept the keepalive pings of other services' clients
+		grpcclient.KeepaliveEnforcementPolicy(cfg.GRPCClient),
+		// Recycle connections so clients spread over new instances
+		grpcserver.KeepaliveParams(cfg.GRPCServer),
+	}
+	// Limit message sizes, compress large responses and record their sizes
+	serverOptions = append(serverOptions, grpcserver.MessageOptions(cfg.GRPCServer)...)
+	server := grpc.NewServer(serverOptions...)
 
 	// Register service
 	pb.RegisterProductServiceServer(server, productHandler)
+	pb.RegisterInventoryServiceServer(server, inventoryHandler)
+	// Report serving status to health-checking clients
+	healthServer := grpcserver.RegisterHealth(server)
+	// Serve the build to gRPC clients, as /version does over HTTP
+	grpcserver.RegisterBuildInfo(server, cfg.ServiceName)
 
 	// Enable reflection for debugging
-	reflection.Register(server)
+	if cfg.Debug.GRPCReflection {
+		reflection.Register(server)
+	}
 
 	// Create listener
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", cfg.Port))
 	if err != nil {
 		log.Fatalf("Failed to listen on port %s: %v", cfg.Port, err)
 	}
+	starter.MarkReady()
 
-	// Start metrics server
-	go func() {
-		metricsPort := "9090"
-		http.Handle("/metrics", promhttp.Handler())
-		log.Printf("Metrics server starting on port %s", metricsPort)
-		if err := http.ListenAndServe(":"+metricsPort, nil); err != nil {
-			log.Printf("Failed to start metrics server: %v", err)
-		}
-	}()
-
-	// Graceful shutdown
-	go func() {
+	application.OnServe("grpc", func(ctx context.Context) error {
 		log.Printf("Product service starting on port %s", cfg.Port)
-		if err := server.Serve(lis); err != nil {
-			log.Fatalf("Failed to serve: %v", err)
-		}
-	}()
-
-	// Wait for interrupt signal to gracefully shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	log.Println("Shutting down product service...")
-	server.GracefulStop()
-	log.Println("Product service stopped")
+		return server.Serve(lis)
+	})
+	application.OnDrain("grpc", func(ctx context.Context) error {
+		starter.MarkDraining()
+		healthServer.Shutdown()
+		grpcserver.Drain(server, cfg.GRPCServer)
+		return nil
+	})
+
+	if err := application.Run(); err != nil {
+		log.Fatalf("Failed to serve: %v", err)
+	}
 }
 
 // initTracer creates and configures OpenTelemetry tracer
@@ -115,12 +234,15 @@ func initTracer(serviceName string) (*tracesdk.TracerProvider, error) {
 
 	tp := tracesdk.NewTracerProvider(
 		tracesdk.WithBatcher(exp),
+		// Record business baggage, e.g. the tenant, on every span
+		tracesdk.WithSpanProcessor(instrumentation.NewBaggageSpanProcessor()),
 		tracesdk.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(serviceName),
+			buildinfo.ResourceAttributes(serviceName)...,
 		)),
 	)
 
 	otel.SetTracerProvider(tp)
+	instrumentation.SetupPropagation()
 	return tp, nil
 }
\ No newline at end of file
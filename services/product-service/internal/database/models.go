@@ -8,17 +8,17 @@ import (
 	"gorm.io/gorm/logger"
 )
 
-// NewConnection creates a new database connection
-func NewConnection(databaseURL string) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
-	if err != nil {
-		return nil, err
+// NewConnection creates a new database connection. verbose logs every SQL
+// statement; otherwise only slow queries and errors are logged.
+func NewConnection(databaseURL string, verbose bool) (*gorm.DB, error) {
+	logLevel := logger.Warn
+	if verbose {
+		logLevel = logger.Info
 	}
 
-	// Auto-migrate models
-	err = db.AutoMigrate(&Product{})
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -26,6 +26,17 @@ func NewConnection(databaseURL string) (*gorm.DB, error) {
 	return db, nil
 }
 
+// Migrate brings the schema up to date with the models
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(Models()...)
+}
+
+// Models lists the models migrated into the database, which the schema
+// drift check compares it against
+func Models() []interface{} {
+	return []interface{}{&Product{}, &InventoryLog{}, &InventoryReservation{}}
+}
+
 // Product model
 type Product struct {
 	ID                string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
@@ -40,6 +51,21 @@ type Product struct {
 	Status            string         `gorm:"default:active;index"`
 	CreatedAt         time.Time      `gorm:"autoCreateTime"`
 	UpdatedAt         time.Time      `gorm:"autoUpdateTime"`
+
+	// Organization owning a private catalog entry; empty for the public catalog
+	OrganizationID string `gorm:"index"`
+
+	// Version is incremented by every update and served as the product's ETag
+	Version int64 `gorm:"not null;default:1"`
+
+	// ReservedQuantity is the part of InventoryQuantity held for orders that
+	// are not committed yet, and so not available to other orders
+	ReservedQuantity int32 `gorm:"not null;default:0"`
+}
+
+// Available returns the quantity other orders can reserve
+func (p *Product) Available() int32 {
+	return p.InventoryQuantity - p.ReservedQuantity
 }
 
 // InventoryLog model for tracking inventory changes
@@ -49,4 +75,26 @@ type InventoryLog struct {
 	QuantityChange int32    `gorm:"not null"`
 	Reason        string    `gorm:"not null"`
 	CreatedAt     time.Time `gorm:"autoCreateTime"`
-}
\ No newline at end of file
+}
+
+// Reservation statuses
+const (
+	ReservationStatusReserved  = "reserved"
+	ReservationStatusCommitted = "committed"
+	ReservationStatusReleased  = "released"
+	ReservationStatusExpired   = "expired"
+)
+
+// InventoryReservation is the quantity of a product held for an order. A
+// reserved quantity counts towards the product's ReservedQuantity until it is
+// committed, which takes it off InventoryQuantity, or released or expired,
+// which makes it available again.
+type InventoryReservation struct {
+	OrderID   string     `gorm:"primaryKey;type:uuid"`
+	ProductID string     `gorm:"primaryKey;type:uuid"`
+	Quantity  int32      `gorm:"not null"`
+	Status    string     `gorm:"not null;default:reserved;index"`
+	ExpiresAt *time.Time `gorm:"index"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "microservices-platform/pkg/proto/product/v1"
+	"microservices-platform/services/product-service/internal/database"
+	"microservices-platform/services/product-service/internal/repository"
+	"microservices-platform/services/product-service/internal/service"
+)
+
+// InventoryHandler implements the gRPC InventoryService
+type InventoryHandler struct {
+	pb.UnimplementedInventoryServiceServer
+	inventoryService service.InventoryService
+	tracer           trace.Tracer
+}
+
+// NewInventoryHandler creates a new InventoryHandler
+func NewInventoryHandler(inventoryService service.InventoryService) *InventoryHandler {
+	return &InventoryHandler{
+		inventoryService: inventoryService,
+		tracer:           otel.Tracer("product-service"),
+	}
+}
+
+// ReserveInventory reserves stock for an order
+func (h *InventoryHandler) ReserveInventory(ctx context.Context, req *pb.ReserveInventoryRequest) (*pb.ReserveInventoryResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "InventoryHandler.ReserveInventory")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("order.id", req.OrderId),
+		attribute.Int("reservation.items_count", len(req.Items)),
+	)
+
+	var items []service.ReservationItem
+	for _, item := range req.Items {
+		items = append(items, service.ReservationItem{
+			ProductID: item.ProductId,
+			Quantity:  item.Quantity,
+		})
+	}
+
+	reservations, err := h.inventoryService.ReserveInventory(ctx, req.OrderId, items)
+	if err != nil {
+		span.RecordError(err)
+		return nil, inventoryError("reserve", err)
+	}
+
+	return &pb.ReserveInventoryResponse{Reservation: h.convertToProtoReservation(req.OrderId, reservations)}, nil
+}
+
+// ReleaseInventory releases an order's reservation
+func (h *InventoryHandler) ReleaseInventory(ctx context.Context, req *pb.ReleaseInventoryRequest) (*pb.ReleaseInventoryResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "InventoryHandler.ReleaseInventory")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("order.id", req.OrderId),
+		attribute.String("release.reason", req.Reason),
+	)
+
+	reservations, err := h.inventoryService.ReleaseInventory(ctx, req.OrderId, req.Reason)
+	if err != nil {
+		span.RecordError(err)
+		return nil, inventoryError("release", err)
+	}
+
+	return &pb.ReleaseInventoryResponse{Reservation: h.convertToProtoReservation(req.OrderId, reservations)}, nil
+}
+
+// CommitInventory commits an order's reservation
+func (h *InventoryHandler) CommitInventory(ctx context.Context, req *pb.CommitInventoryRequest) (*pb.CommitInventoryResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "InventoryHandler.CommitInventory")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("order.id", req.OrderId))
+
+	reservations, err := h.inventoryService.CommitInventory(ctx, req.OrderId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, inventoryError("commit", err)
+	}
+
+	return &pb.CommitInventoryResponse{Reservation: h.convertToProtoReservation(req.OrderId, reservations)}, nil
+}
+
+// inventoryError maps a failed reservation change to a gRPC status
+func inventoryError(action string, err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidReservation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, repository.ErrInsufficientInventory), errors.Is(err, repository.ErrReservationReleased):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, repository.ErrProductNotFound), errors.Is(err, repository.ErrReservationNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	default:
+		return status.Errorf(codes.Internal, "failed to %s inventory: %v", action, err)
+	}
+}
+
+// convertToProtoReservation converts an order's reservation rows, one per
+// product, to a reservation. Rows normally share a status; while some are
+// still being expired, the reservation counts as reserved.
+func (h *InventoryHandler) convertToProtoReservation(orderID string, reservations []*database.InventoryReservation) *pb.Reservation {
+	reservation := &pb.Reservation{OrderId: orderID}
+	statuses := make(map[string]bool, len(reservations))
+	for _, row := range reservations {
+		reservation.Items = append(reservation.Items, &pb.InventoryItem{
+			ProductId: row.ProductID,
+			Quantity:  row.Quantity,
+		})
+		statuses[row.Status] = true
+		if row.ExpiresAt != nil && (reservation.ExpiresAt == nil || row.ExpiresAt.Before(reservation.ExpiresAt.AsTime())) {
+			reservation.ExpiresAt = timestamppb.New(*row.ExpiresAt)
+		}
+	}
+
+	switch {
+	case statuses[database.ReservationStatusReserved]:
+		reservation.Status = pb.ReservationStatus_RESERVATION_STATUS_RESERVED
+	case statuses[database.ReservationStatusCommitted]:
+		reservation.Status = pb.ReservationStatus_RESERVATION_STATUS_COMMITTED
+	case statuses[database.ReservationStatusExpired]:
+		reservation.Status = pb.ReservationStatus_RESERVATION_STATUS_EXPIRED
+	case statuses[database.ReservationStatusReleased]:
+		reservation.Status = pb.ReservationStatus_RESERVATION_STATUS_RELEASED
+	}
+	return reservation
+}
@@ -0,0 +1,321 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"microservices-platform/services/product-service/internal/database"
+)
+
+// Inventory reservation errors
+var (
+	ErrInsufficientInventory = errors.New("insufficient inventory")
+	ErrProductNotFound       = errors.New("product not found")
+	ErrReservationNotFound   = errors.New("reservation not found")
+	ErrReservationReleased   = errors.New("reservation was released")
+)
+
+// InventoryRepository holds stock for orders. Product rows are locked for
+// the length of each change, always in ID order so concurrent changes
+// touching the same products cannot deadlock.
+type InventoryRepository interface {
+	// Reserve holds quantities of products, keyed by product ID, for an
+	// order until expiresAt, or fails with ErrInsufficientInventory without
+	// holding any. An order's existing reservation is returned as is.
+	Reserve(ctx context.Context, orderID string, quantities map[string]int32, expiresAt time.Time) ([]*database.InventoryReservation, error)
+	// Commit takes an order's reserved stock off the products' inventory.
+	// Stock whose reservation expired is taken again if it is still
+	// available.
+	Commit(ctx context.Context, orderID string) ([]*database.InventoryReservation, error)
+	// Release makes an order's reserved stock available again and restocks
+	// its committed stock
+	Release(ctx context.Context, orderID, reason string) ([]*database.InventoryReservation, error)
+	// ExpireDue releases up to limit reservations that expired by now and
+	// returns how many it released
+	ExpireDue(ctx context.Context, now time.Time, limit int) (int, error)
+}
+
+// inventoryRepository implements InventoryRepository interface
+type inventoryRepository struct {
+	db *gorm.DB
+}
+
+// NewInventoryRepository creates a new inventory repository
+func NewInventoryRepository(db *gorm.DB) InventoryRepository {
+	return &inventoryRepository{
+		db: db,
+	}
+}
+
+// Reserve holds stock for an order
+func (r *inventoryRepository) Reserve(ctx context.Context, orderID string, quantities map[string]int32, expiresAt time.Time) ([]*database.InventoryReservation, error) {
+	ids := make([]string, 0, len(quantities))
+	for id := range quantities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var reservations []*database.InventoryReservation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Locking the products first makes a concurrent reservation for the
+		// same order wait, then find this one
+		products, err := lockProducts(tx, ids)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("order_id = ?", orderID).Order("product_id").Find(&reservations).Error; err != nil || len(reservations) > 0 {
+			return err
+		}
+
+		for _, id := range ids {
+			product, ok := products[id]
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrProductNotFound, id)
+			}
+			if available := product.Available(); available < quantities[id] {
+				return fmt.Errorf("%w: product %s has %d available, %d requested", ErrInsufficientInventory, id, available, quantities[id])
+			}
+		}
+
+		for _, id := range ids {
+			err := tx.Model(&database.Product{}).Where("id = ?", id).
+				Update("reserved_quantity", gorm.Expr("reserved_quantity + ?", quantities[id])).Error
+			if err != nil {
+				return err
+			}
+			reservations = append(reservations, &database.InventoryReservation{
+				OrderID:   orderID,
+				ProductID: id,
+				Quantity:  quantities[id],
+				Status:    database.ReservationStatusReserved,
+				ExpiresAt: &expiresAt,
+			})
+		}
+		return tx.Create(&reservations).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
+
+// Commit takes an order's stock off the shelf
+func (r *inventoryRepository) Commit(ctx context.Context, orderID string) ([]*database.InventoryReservation, error) {
+	var reservations []*database.InventoryReservation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		reservations, err = lockReservations(tx, orderID)
+		if err != nil {
+			return err
+		}
+		products, err := lockProducts(tx, reservedProductIDs(reservations))
+		if err != nil {
+			return err
+		}
+
+		for _, reservation := range reservations {
+			product, ok := products[reservation.ProductID]
+			var reserved int32
+			switch reservation.Status {
+			case database.ReservationStatusCommitted:
+				continue
+			case database.ReservationStatusReleased:
+				return fmt.Errorf("%w: order %s", ErrReservationReleased, orderID)
+			case database.ReservationStatusReserved:
+				reserved = reservation.Quantity
+			case database.ReservationStatusExpired:
+				if !ok || product.Available() < reservation.Quantity {
+					return fmt.Errorf("%w: reservation of product %s for order %s expired", ErrInsufficientInventory, reservation.ProductID, orderID)
+				}
+			}
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrProductNotFound, reservation.ProductID)
+			}
+
+			err := tx.Model(&database.Product{}).Where("id = ?", product.ID).Updates(map[string]interface{}{
+				"inventory_quantity": gorm.Expr("inventory_quantity - ?", reservation.Quantity),
+				"reserved_quantity":  gorm.Expr("reserved_quantity - ?", reserved),
+				"version":            gorm.Expr("version + 1"),
+			}).Error
+			if err != nil {
+				return err
+			}
+			if err := logInventoryChange(tx, product.ID, -reservation.Quantity, "order "+orderID); err != nil {
+				return err
+			}
+			reservation.Status = database.ReservationStatusCommitted
+			reservation.ExpiresAt = nil
+		}
+		return saveReservations(tx, reservations)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
+
+// Release returns an order's stock
+func (r *inventoryRepository) Release(ctx context.Context, orderID, reason string) ([]*database.InventoryReservation, error) {
+	var reservations []*database.InventoryReservation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		reservations, err = lockReservations(tx, orderID)
+		if err != nil {
+			return err
+		}
+		if _, err := lockProducts(tx, reservedProductIDs(reservations)); err != nil {
+			return err
+		}
+
+		for _, reservation := range reservations {
+			var err error
+			switch reservation.Status {
+			case database.ReservationStatusReserved:
+				err = tx.Model(&database.Product{}).Where("id = ?", reservation.ProductID).
+					Update("reserved_quantity", gorm.Expr("reserved_quantity - ?", reservation.Quantity)).Error
+			case database.ReservationStatusCommitted:
+				err = tx.Model(&database.Product{}).Where("id = ?", reservation.ProductID).Updates(map[string]interface{}{
+					"inventory_quantity": gorm.Expr("inventory_quantity + ?", reservation.Quantity),
+					"version":            gorm.Expr("version + 1"),
+				}).Error
+				if err == nil {
+					err = logInventoryChange(tx, reservation.ProductID, reservation.Quantity, releaseReason(orderID, reason))
+				}
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			reservation.Status = database.ReservationStatusReleased
+			reservation.ExpiresAt = nil
+		}
+		return saveReservations(tx, reservations)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
+
+// ExpireDue releases expired reservations. They are locked with SKIP
+// LOCKED, so instances expiring at the same time split them.
+func (r *inventoryRepository) ExpireDue(ctx context.Context, now time.Time, limit int) (int, error) {
+	var reservations []*database.InventoryReservation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND expires_at <= ?", database.ReservationStatusReserved, now).
+			Order("expires_at ASC").
+			Limit(limit).
+			Find(&reservations).Error
+		if err != nil || len(reservations) == 0 {
+			return err
+		}
+		if _, err := lockProducts(tx, reservedProductIDs(reservations)); err != nil {
+			return err
+		}
+
+		for _, reservation := range reservations {
+			err := tx.Model(&database.Product{}).Where("id = ?", reservation.ProductID).
+				Update("reserved_quantity", gorm.Expr("reserved_quantity - ?", reservation.Quantity)).Error
+			if err != nil {
+				return err
+			}
+			reservation.Status = database.ReservationStatusExpired
+		}
+		return saveReservations(tx, reservations)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(reservations), nil
+}
+
+// lockProducts locks the products with the given IDs, in ID order, and
+// returns those that exist by ID
+func lockProducts(tx *gorm.DB, ids []string) (map[string]*database.Product, error) {
+	products := make(map[string]*database.Product, len(ids))
+	if len(ids) == 0 {
+		return products, nil
+	}
+
+	var locked []*database.Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&locked).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, product := range locked {
+		products[product.ID] = product
+	}
+	return products, nil
+}
+
+// lockReservations locks an order's reservations
+func lockReservations(tx *gorm.DB, orderID string) ([]*database.InventoryReservation, error) {
+	var reservations []*database.InventoryReservation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ?", orderID).
+		Order("product_id").
+		Find(&reservations).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(reservations) == 0 {
+		return nil, fmt.Errorf("%w: order %s", ErrReservationNotFound, orderID)
+	}
+	return reservations, nil
+}
+
+// saveReservations stores the status of reservations
+func saveReservations(tx *gorm.DB, reservations []*database.InventoryReservation) error {
+	for _, reservation := range reservations {
+		err := tx.Model(reservation).Updates(map[string]interface{}{
+			"status":     reservation.Status,
+			"expires_at": reservation.ExpiresAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reservedProductIDs returns the sorted IDs of the reservations' products
+func reservedProductIDs(reservations []*database.InventoryReservation) []string {
+	ids := make([]string, 0, len(reservations))
+	for _, reservation := range reservations {
+		ids = append(ids, reservation.ProductID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// logInventoryChange records a change to a product's inventory
+func logInventoryChange(tx *gorm.DB, productID string, change int32, reason string) error {
+	return tx.Create(&database.InventoryLog{
+		ProductID:      productID,
+		QuantityChange: change,
+		Reason:         reason,
+	}).Error
+}
+
+// releaseReason describes a release in the inventory log
+func releaseReason(orderID, reason string) string {
+	if reason == "" {
+		return "order " + orderID + " released"
+	}
+	return "order " + orderID + " released: " + reason
+}
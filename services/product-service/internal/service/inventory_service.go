@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"microservices-platform/services/product-service/internal/config"
+	"microservices-platform/services/product-service/internal/database"
+	"microservices-platform/services/product-service/internal/repository"
+)
+
+// ErrInvalidReservation is returned for a reservation without an order or
+// items, or with a quantity that is not positive
+var ErrInvalidReservation = errors.New("invalid reservation")
+
+// expiryBatchSize bounds how many expired reservations are released in one
+// transaction
+const expiryBatchSize = 100
+
+// ReservationItem is a quantity of a product to reserve
+type ReservationItem struct {
+	ProductID string
+	Quantity  int32
+}
+
+// InventoryService holds stock for orders between their creation and their
+// payment, so two orders cannot both be sold the last item
+type InventoryService interface {
+	// ReserveInventory holds stock for an order's items, all or nothing
+	ReserveInventory(ctx context.Context, orderID string, items []ReservationItem) ([]*database.InventoryReservation, error)
+	// ReleaseInventory returns an order's stock
+	ReleaseInventory(ctx context.Context, orderID, reason string) ([]*database.InventoryReservation, error)
+	// CommitInventory takes an order's stock off the shelf for good
+	CommitInventory(ctx context.Context, orderID string) ([]*database.InventoryReservation, error)
+	// StartExpiry releases reservations that were neither committed nor
+	// released in time, until ctx is cancelled
+	StartExpiry(ctx context.Context)
+}
+
+// inventoryService implements InventoryService interface
+type inventoryService struct {
+	inventoryRepo  repository.InventoryRepository
+	ttl            time.Duration
+	expiryInterval time.Duration
+}
+
+// NewInventoryService creates a new inventory service
+func NewInventoryService(inventoryRepo repository.InventoryRepository, cfg *config.Config) InventoryService {
+	return &inventoryService{
+		inventoryRepo:  inventoryRepo,
+		ttl:            cfg.ReservationTTL,
+		expiryInterval: cfg.ReservationExpiryInterval,
+	}
+}
+
+// ReserveInventory reserves stock for an order. Items of the same product
+// are reserved together.
+func (s *inventoryService) ReserveInventory(ctx context.Context, orderID string, items []ReservationItem) ([]*database.InventoryReservation, error) {
+	if orderID == "" || len(items) == 0 {
+		return nil, fmt.Errorf("%w: an order and items are required", ErrInvalidReservation)
+	}
+	quantities := make(map[string]int32, len(items))
+	for _, item := range items {
+		if item.ProductID == "" || item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: product %q has quantity %d", ErrInvalidReservation, item.ProductID, item.Quantity)
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+
+	return s.inventoryRepo.Reserve(ctx, orderID, quantities, time.Now().UTC().Add(s.ttl))
+}
+
+// ReleaseInventory releases an order's reservation
+func (s *inventoryService) ReleaseInventory(ctx context.Context, orderID, reason string) ([]*database.InventoryReservation, error) {
+	if orderID == "" {
+		return nil, fmt.Errorf("%w: an order is required", ErrInvalidReservation)
+	}
+	return s.inventoryRepo.Release(ctx, orderID, reason)
+}
+
+// CommitInventory commits an order's reservation
+func (s *inventoryService) CommitInventory(ctx context.Context, orderID string) ([]*database.InventoryReservation, error) {
+	if orderID == "" {
+		return nil, fmt.Errorf("%w: an order is required", ErrInvalidReservation)
+	}
+	return s.inventoryRepo.Commit(ctx, orderID)
+}
+
+// StartExpiry releases expired reservations every expiry interval
+func (s *inventoryService) StartExpiry(ctx context.Context) {
+	ticker := time.NewTicker(s.expiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.expireDue(ctx); err != nil {
+				log.Printf("Failed to expire inventory reservations: %v", err)
+			}
+		}
+	}
+}
+
+// expireDue releases every reservation that has expired, a batch at a time
+func (s *inventoryService) expireDue(ctx context.Context) error {
+	for {
+		expired, err := s.inventoryRepo.ExpireDue(ctx, time.Now().UTC(), expiryBatchSize)
+		if err != nil {
+			return err
+		}
+		if expired > 0 {
+			log.Printf("Released %d expired inventory reservations", expired)
+		}
+		if expired < expiryBatchSize {
+			return nil
+		}
+	}
+}
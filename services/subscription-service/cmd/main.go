@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	"gorm.io/gorm"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/sdk/resource"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+
+	"microservices-platform/pkg/app"
+	"microservices-platform/pkg/buildinfo"
+	"microservices-platform/pkg/dbhealth"
+	"microservices-platform/pkg/debug"
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/grpcclient"
+	"microservices-platform/pkg/grpcserver"
+	"microservices-platform/pkg/instrumentation"
+	"microservices-platform/pkg/logging"
+	"microservices-platform/pkg/middleware"
+	"microservices-platform/pkg/plans"
+	pb "microservices-platform/pkg/proto/subscription/v1"
+	"microservices-platform/pkg/schemadrift"
+	"microservices-platform/pkg/sharding"
+	"microservices-platform/pkg/startup"
+	"microservices-platform/pkg/watchdog"
+	"microservices-platform/services/subscription-service/internal/config"
+	"microservices-platform/services/subscription-service/internal/database"
+	"microservices-platform/services/subscription-service/internal/handler"
+	"microservices-platform/services/subscription-service/internal/repository"
+	"microservices-platform/services/subscription-service/internal/service"
+)
+
+func main() {
+	// Initialize configuration
+	cfg := config.Load()
+
+	// Compare the database schema with the models and exit, e.g. in CI
+	if len(os.Args) > 1 && os.Args[1] == schemadrift.Command {
+		os.Exit(schemadrift.Main(cfg.ServiceName, cfg.DatabaseURL, cfg.SchemaDrift, database.Models()...))
+	}
+
+	// Filter logs by level and rate limit repeated errors
+	if err := logging.Setup(cfg.Logging); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+
+	// Announce the build and report it in build_info
+	buildinfo.LogStartup(cfg.ServiceName)
+
+	// Refuse debug facilities in production unless explicitly allowed
+	if err := cfg.Debug.Check(cfg.Environment); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+
+	// Run the service through init, migrate, serve, drain and shutdown.
+	// Resources are released in the reverse order they are registered.
+	application := app.New(cfg.ServiceName, cfg.App)
+
+	// Initialize OpenTelemetry
+	tp, err := initTracer(cfg.ServiceName)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracer: %v", err)
+	}
+	application.OnShutdown("tracer", tp.Shutdown)
+
+	// Continuously profile CPU and heap when enabled
+	profiler, err := instrumentation.StartProfiling(cfg.ServiceName, cfg.Observability)
+	if err != nil {
+		log.Fatalf("Failed to start profiling: %v", err)
+	}
+	application.OnShutdown("profiler", func(ctx context.Context) error {
+		profiler.Stop()
+		return nil
+	})
+
+	// Connect to dependencies in order, retrying until they are available.
+	// Readiness reports "starting" until the gRPC server is listening.
+	starter := startup.NewManager(cfg.Startup)
+
+	// Serve metrics, health, build info and the admin and debug endpoints on
+	// the internal observability port
+	application.Handle("/health/", starter.Handler())
+	application.Handle(buildinfo.Path, buildinfo.Handler(cfg.ServiceName))
+	if cfg.Logging.AdminToken != "" {
+		application.Handle(logging.AdminPath, logging.Handler(cfg.Logging.AdminToken))
+	}
+	if cfg.Debug.Pprof {
+		application.Handle(debug.PprofPath, cfg.Debug.PprofHandler())
+	}
+	if err := application.ServeObservability(); err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+
+	openDB := func(dsn string) (*gorm.DB, error) {
+		conn, err := database.NewConnection(dsn, cfg.Debug.VerboseSQL)
+		if err != nil {
+			return nil, err
+		}
+		// Trace every query and explain slow ones
+		if err := conn.Use(instrumentation.NewQueryTracer(cfg.ServiceName, cfg.QueryTracing)); err != nil {
+			return nil, err
+		}
+		return conn, nil
+	}
+	var db *gorm.DB
+	var shards *sharding.Router
+	starter.Require("postgres", func(ctx context.Context) error {
+		conn, err := openDB(cfg.DatabaseURL)
+		if err != nil {
+			return err
+		}
+		// Large tenants' subscriptions live on shards of their own
+		router, err := sharding.New(cfg.ServiceName, conn, cfg.Sharding, openDB)
+		if err != nil {
+			if sqlDB, dbErr := conn.DB(); dbErr == nil {
+				sqlDB.Close()
+			}
+			return err
+		}
+		db, shards = conn, router
+		return nil
+	})
+
+	// Event bus for publishing subscription events. Redis is optional while
+	// degradation is enabled.
+	dialEventBus := events.RedisDialer(cfg.Redis, cfg.EventBus)
+	var redisBus events.EventBus
+	connectEventBus := func(ctx context.Context) error {
+		bus, err := dialEventBus()
+		if err != nil {
+			return err
+		}
+		redisBus = bus
+		return nil
+	}
+	if cfg.Degradation.Enabled {
+		starter.Optional("redis", connectEventBus)
+	} else {
+		starter.Require("redis", connectEventBus)
+	}
+
+	application.OnInit("dependencies", starter.Run)
+	application.OnMigrate("postgres", func(ctx context.Context) error {
+		return shards.Migrate(ctx, func(db *gorm.DB) error {
+			if err := database.Migrate(db); err != nil {
+				return err
+			}
+			if err := schemadrift.Record(db, cfg.ServiceName, database.Models()...); err != nil {
+				return err
+			}
+			return schemadrift.Verify(ctx, db, cfg.ServiceName, cfg.SchemaDrift, database.Models()...)
+		})
+	})
+	if err := application.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+
+	// While Redis is unreachable, events are buffered in the local outbox and
+	// published once it recovers; readiness reports "degraded" meanwhile
+	outbox, err := events.NewOutbox(events.OutboxConfig{
+		Service: cfg.ServiceName,
+		MaxSize: cfg.Degradation.OutboxSize,
+		Policy:  events.OverflowPolicy(cfg.Degradation.OutboxOverflowPolicy),
+		Path:    cfg.Degradation.OutboxPath,
+	})
+	if err != nil {
+		log.Fatalf("Failed to open event outbox: %v", err)
+	}
+	eventBus := events.NewDegradingEventBus(redisBus, dialEventBus, outbox, cfg.Degradation.RetryInterval)
+	eventBus.OnChange(func(err error) {
+		starter.Report("redis", err)
+	})
+
+	// Publish events from request paths in the background, so Redis latency
+	// does not add to theirs; events it cannot keep up with go to the outbox
+	publisher := events.NewAsyncEventBus(eventBus, outbox, cfg.EventBus)
+
+	// Watch the database connection and re-dial after a restart or failover
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to get database handle: %v", err)
+	}
+	application.OnShutdown("postgres", func(ctx context.Context) error {
+		return sqlDB.Close()
+	})
+	dbMonitor := dbhealth.NewMonitor(cfg.ServiceName, "postgres", sqlDB, cfg.Database, eventBus)
+	dbMonitor.OnChange(func(err error) {
+		starter.Report("postgres", err)
+	})
+	dbMonitor.Start(context.Background())
+
+	// Watch for leaked goroutines, file descriptors and pooled connections
+	leaks := watchdog.New(cfg.ServiceName, cfg.Watchdog)
+	leaks.WatchDB("postgres", sqlDB)
+
+	// Each shard has a pool of its own, watched as "postgres/<shard>". A
+	// broken shard only fails its tenants, so readiness lists it without
+	// taking the service out of rotation.
+	application.OnShutdown("postgres-shards", func(ctx context.Context) error {
+		return shards.Close()
+	})
+	err = shards.Each(func(name string, shardDB *gorm.DB) error {
+		if name == sharding.DefaultShard {
+			return nil
+		}
+		shardSQL, err := shardDB.DB()
+		if err != nil {
+			return err
+		}
+		shardName := "postgres/" + name
+		monitor := dbhealth.NewMonitor(cfg.ServiceName, shardName, shardSQL, cfg.Database, eventBus)
+		monitor.OnChange(func(err error) {
+			starter.Report(shardName, err)
+		})
+		monitor.Start(context.Background())
+		starter.Report(shardName, nil)
+		leaks.WatchDB(shardName, shardSQL)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to get database handle: %v", err)
+	}
+	leaks.Start(context.Background())
+
+	// Plan limits are published to Redis for the gateway's rate limiter and
+	// feature checks. Without Redis, subscriptions still work but their limits
+	// are not enforced until they change again.
+	var limitsStore plans.Store
+	if store, err := plans.NewRedisStore(cfg.Redis); err != nil {
+		log.Printf("Plan limits store unavailable, plan limits will not be published: %v", err)
+	} else {
+		limitsStore = store
+	}
+
+	if err := eventBus.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start event bus: %v", err)
+	}
+	application.OnShutdown("event-bus", func(ctx context.Context) error {
+		return eventBus.Stop()
+	})
+	// Shutdown hooks run in reverse, so queued events are published before
+	// the bus stops
+	application.OnShutdown("event-publisher", publisher.Close)
+
+	// Initialize repositories
+	planRepo := repository.NewPlanRepository(db)
+	subscriptionRepo := repository.NewSubscriptionRepository(shards)
+
+	// Initialize services
+	planService := service.NewPlanService(planRepo, subscriptionRepo, limitsStore)
+	subscriptionService := service.NewSubscriptionService(planRepo, subscriptionRepo, limitsStore, publisher, cfg)
+
+	// Renew, retry and cancel subscriptions as their periods end
+	billingCtx, stopBilling := context.WithCancel(context.Background())
+	application.OnServe("billing", func(ctx context.Context) error {
+		subscriptionService.StartBilling(billingCtx)
+		return nil
+	})
+	// Stop billing before draining, so no renewal starts during shutdown
+	application.OnDrain("billing", func(ctx context.Context) error {
+		stopBilling()
+		return nil
+	})
+
+	// Initialize gRPC handler
+	subscriptionHandler := handler.NewSubscriptionHandler(planService, subscriptionService)
+
+	// Create gRPC server with OpenTelemetry interceptors, verifying the
+	// caller identity signed by the gateway when a secret is configured
+	unaryInterceptors := []grpc.UnaryServerInterceptor{otelgrpc.UnaryServerInterceptor()}
+	streamInterceptors := []grpc.StreamServerInterceptor{otelgrpc.StreamServerInterceptor()}
+	if cfg.Identity.Secret != "" {
+		unaryInterceptors = append(unaryInterceptors, middleware.IdentityUnaryInterceptor(cfg.Identity.Secret))
+		streamInterceptors = append(streamInterceptors, middleware.IdentityStreamInterceptor(cfg.Identity.Secret))
+	}
+	serverOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+		// Accept the keepalive pings of other services' clients
+		grpcclient.KeepaliveEnforcementPolicy(cfg.GRPCClient),
+		// Recycle connections so clients spread over new instances
+		grpcserver.KeepaliveParams(cfg.GRPCServer),
+	}
+	// Limit message sizes, compress large responses and record their sizes
+	serverOptions = append(serverOptions, grpcserver.MessageOptions(cfg.GRPCServer)...)
+	server := grpc.NewServer(serverOptions...)
+
+	// Register service
+	pb.RegisterSubscriptionServiceServer(server, subscriptionHandler)
+	// Report serving status to health-checking clients
+	healthServer := grpcserver.RegisterHealth(server)
+	// Serve the build to gRPC clients, as /version does over HTTP
+	grpcserver.RegisterBuildInfo(server, cfg.ServiceName)
+
+	// Enable reflection for debugging
+	if cfg.Debug.GRPCReflection {
+		reflection.Register(server)
+	}
+
+	// Create listener
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", cfg.Port))
+	if err != nil {
+		log.Fatalf("Failed to listen on port %s: %v", cfg.Port, err)
+	}
+	starter.MarkReady()
+
+	application.OnServe("grpc", func(ctx context.Context) error {
+		log.Printf("Subscription service starting on port %s", cfg.Port)
+		return server.Serve(lis)
+	})
+	application.OnDrain("grpc", func(ctx context.Context) error {
+		starter.MarkDraining()
+		healthServer.Shutdown()
+		grpcserver.Drain(server, cfg.GRPCServer)
+		return nil
+	})
+
+	if err := application.Run(); err != nil {
+		log.Fatalf("Failed to serve: %v", err)
+	}
+}
+
+// initTracer creates and configures OpenTelemetry tracer
+func initTracer(serviceName string) (*tracesdk.TracerProvider, error) {
+	// Create Jaeger exporter
+	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint("http://jaeger:14268/api/traces")))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := tracesdk.NewTracerProvider(
+		tracesdk.WithBatcher(exp),
+		// Record business baggage, e.g. the tenant, on every span
+		tracesdk.WithSpanProcessor(instrumentation.NewBaggageSpanProcessor()),
+		tracesdk.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			buildinfo.ResourceAttributes(serviceName)...,
+		)),
+	)
+
+	otel.SetTracerProvider(tp)
+	instrumentation.SetupPropagation()
+	return tp, nil
+}
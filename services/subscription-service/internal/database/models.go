@@ -0,0 +1,108 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// NewConnection creates a new database connection. verbose logs every SQL
+// statement; otherwise only slow queries and errors are logged.
+func NewConnection(databaseURL string, verbose bool) (*gorm.DB, error) {
+	logLevel := logger.Warn
+	if verbose {
+		logLevel = logger.Info
+	}
+
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Migrate brings the schema up to date with the models
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(Models()...)
+}
+
+// Models lists the models migrated into the database, which the schema
+// drift check compares it against
+func Models() []interface{} {
+	return []interface{}{&Plan{}, &Subscription{}, &SubscriptionCharge{}}
+}
+
+// Billing intervals
+const (
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+// Plan model. Price changes apply from a subscription's next renewal.
+type Plan struct {
+	ID                string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Code              string    `gorm:"not null;uniqueIndex"`
+	Name              string    `gorm:"not null"`
+	Price             float64   `gorm:"not null"`
+	Currency          string    `gorm:"not null"`
+	Interval          string    `gorm:"not null"`
+	TrialDays         int       `gorm:"not null;default:0"`
+	RequestsPerWindow int       `gorm:"not null;default:0"`
+	Features          string    `gorm:"type:text"` // comma-separated
+	Active            bool      `gorm:"default:true"`
+	CreatedAt         time.Time `gorm:"autoCreateTime"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime"`
+}
+
+// Subscription statuses
+const (
+	SubscriptionStatusTrialing  = "trialing"
+	SubscriptionStatusActive    = "active"
+	SubscriptionStatusPastDue   = "past_due"
+	SubscriptionStatusCancelled = "cancelled"
+)
+
+// Subscription model. A tenant has at most one subscription that is not
+// cancelled; NextChargeAt is when the billing worker next renews it.
+type Subscription struct {
+	ID                 string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID           string `gorm:"not null;uniqueIndex:idx_subscriptions_open_tenant,where:status <> 'cancelled'"`
+	UserID             string `gorm:"not null"`
+	PlanCode           string `gorm:"not null"`
+	Status             string `gorm:"not null;index"`
+	PaymentMethodToken string
+	TrialEndsAt        *time.Time
+	CurrentPeriodStart time.Time `gorm:"not null"`
+	CurrentPeriodEnd   time.Time `gorm:"not null"`
+	CancelAtPeriodEnd  bool      `gorm:"default:false"`
+	CancelledAt        *time.Time
+	NextChargeAt       *time.Time `gorm:"index"`
+	FailedCharges      int        `gorm:"not null;default:0"`
+	CreatedAt          time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt          time.Time  `gorm:"autoUpdateTime"`
+}
+
+// Subscription charge statuses
+const (
+	ChargeStatusSucceeded = "succeeded"
+	ChargeStatusFailed    = "failed"
+)
+
+// SubscriptionCharge records one attempt to collect a subscription period
+type SubscriptionCharge struct {
+	ID             string  `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	SubscriptionID string  `gorm:"not null;index"`
+	Amount         float64 `gorm:"not null"`
+	Currency       string  `gorm:"not null"`
+	Status         string  `gorm:"not null"`
+	PaymentID      string
+	Error          string
+	PeriodStart    time.Time `gorm:"not null"`
+	PeriodEnd      time.Time `gorm:"not null"`
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
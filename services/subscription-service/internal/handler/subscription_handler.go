@@ -0,0 +1,365 @@
+package handler
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"microservices-platform/pkg/middleware"
+	pb "microservices-platform/pkg/proto/subscription/v1"
+	"microservices-platform/services/subscription-service/internal/database"
+	"microservices-platform/services/subscription-service/internal/service"
+)
+
+// SubscriptionHandler implements the gRPC SubscriptionService
+type SubscriptionHandler struct {
+	pb.UnimplementedSubscriptionServiceServer
+	planService         service.PlanService
+	subscriptionService service.SubscriptionService
+	tracer              trace.Tracer
+}
+
+// NewSubscriptionHandler creates a new SubscriptionHandler
+func NewSubscriptionHandler(planService service.PlanService, subscriptionService service.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		planService:         planService,
+		subscriptionService: subscriptionService,
+		tracer:              otel.Tracer("subscription-service"),
+	}
+}
+
+// ListPlans lists available plans
+func (h *SubscriptionHandler) ListPlans(ctx context.Context, req *pb.ListPlansRequest) (*pb.ListPlansResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "SubscriptionHandler.ListPlans")
+	defer span.End()
+
+	plans, err := h.planService.ListPlans(ctx, req.IncludeInactive)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to list plans: %v", err)
+	}
+
+	var protoPlans []*pb.Plan
+	for _, plan := range plans {
+		protoPlans = append(protoPlans, h.convertToProtoPlan(plan))
+	}
+
+	return &pb.ListPlansResponse{Plans: protoPlans}, nil
+}
+
+// CreatePlan creates a plan
+func (h *SubscriptionHandler) CreatePlan(ctx context.Context, req *pb.CreatePlanRequest) (*pb.CreatePlanResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "SubscriptionHandler.CreatePlan")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("plan.code", req.Code))
+
+	plan, err := h.planService.CreatePlan(ctx, &database.Plan{
+		Code:              req.Code,
+		Name:              req.Name,
+		Price:             req.Price,
+		Currency:          req.Currency,
+		Interval:          h.convertIntervalToString(req.Interval),
+		TrialDays:         int(req.TrialDays),
+		RequestsPerWindow: int(req.RequestsPerWindow),
+		Features:          strings.Join(req.Features, ","),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to create plan: %v", err)
+	}
+
+	return &pb.CreatePlanResponse{Plan: h.convertToProtoPlan(plan)}, nil
+}
+
+// UpdatePlan updates a plan
+func (h *SubscriptionHandler) UpdatePlan(ctx context.Context, req *pb.UpdatePlanRequest) (*pb.UpdatePlanResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "SubscriptionHandler.UpdatePlan")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("plan.code", req.Code))
+
+	update := service.PlanUpdate{
+		Name:            req.Name,
+		Price:           req.Price,
+		Features:        req.Features,
+		ReplaceFeatures: req.ReplaceFeatures,
+		Active:          req.Active,
+	}
+	if req.TrialDays != nil {
+		trialDays := int(*req.TrialDays)
+		update.TrialDays = &trialDays
+	}
+	if req.RequestsPerWindow != nil {
+		requestsPerWindow := int(*req.RequestsPerWindow)
+		update.RequestsPerWindow = &requestsPerWindow
+	}
+
+	plan, err := h.planService.UpdatePlan(ctx, req.Code, update)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to update plan: %v", err)
+	}
+
+	return &pb.UpdatePlanResponse{Plan: h.convertToProtoPlan(plan)}, nil
+}
+
+// CreateSubscription subscribes a tenant to a plan
+func (h *SubscriptionHandler) CreateSubscription(ctx context.Context, req *pb.CreateSubscriptionRequest) (*pb.CreateSubscriptionResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "SubscriptionHandler.CreateSubscription")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("subscription.tenant_id", req.TenantId),
+		attribute.String("subscription.plan", req.PlanCode),
+	)
+
+	if err := authorizeTenant(ctx, req.TenantId); err != nil {
+		return nil, err
+	}
+
+	subscription, err := h.subscriptionService.Subscribe(ctx, req.TenantId, req.UserId, req.PlanCode, req.PaymentMethodToken)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to create subscription: %v", err)
+	}
+
+	return &pb.CreateSubscriptionResponse{Subscription: h.convertToProtoSubscription(subscription)}, nil
+}
+
+// GetSubscription retrieves a tenant's subscription
+func (h *SubscriptionHandler) GetSubscription(ctx context.Context, req *pb.GetSubscriptionRequest) (*pb.GetSubscriptionResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "SubscriptionHandler.GetSubscription")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("subscription.tenant_id", req.TenantId))
+
+	if err := authorizeTenant(ctx, req.TenantId); err != nil {
+		return nil, err
+	}
+
+	subscription, err := h.subscriptionService.GetSubscription(ctx, req.TenantId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.NotFound, "subscription not found: %v", err)
+	}
+
+	return &pb.GetSubscriptionResponse{Subscription: h.convertToProtoSubscription(subscription)}, nil
+}
+
+// ChangeSubscriptionPlan moves a tenant to another plan
+func (h *SubscriptionHandler) ChangeSubscriptionPlan(ctx context.Context, req *pb.ChangeSubscriptionPlanRequest) (*pb.ChangeSubscriptionPlanResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "SubscriptionHandler.ChangeSubscriptionPlan")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("subscription.tenant_id", req.TenantId),
+		attribute.String("subscription.plan", req.PlanCode),
+	)
+
+	if err := authorizeTenant(ctx, req.TenantId); err != nil {
+		return nil, err
+	}
+
+	subscription, err := h.subscriptionService.ChangePlan(ctx, req.TenantId, req.PlanCode)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to change plan: %v", err)
+	}
+
+	return &pb.ChangeSubscriptionPlanResponse{Subscription: h.convertToProtoSubscription(subscription)}, nil
+}
+
+// CancelSubscription cancels a tenant's subscription
+func (h *SubscriptionHandler) CancelSubscription(ctx context.Context, req *pb.CancelSubscriptionRequest) (*pb.CancelSubscriptionResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "SubscriptionHandler.CancelSubscription")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("subscription.tenant_id", req.TenantId),
+		attribute.Bool("subscription.cancel_immediately", req.Immediately),
+	)
+
+	if err := authorizeTenant(ctx, req.TenantId); err != nil {
+		return nil, err
+	}
+
+	subscription, err := h.subscriptionService.Cancel(ctx, req.TenantId, req.Immediately)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to cancel subscription: %v", err)
+	}
+
+	return &pb.CancelSubscriptionResponse{Subscription: h.convertToProtoSubscription(subscription)}, nil
+}
+
+// ListSubscriptionCharges lists the recurring charges of a tenant
+func (h *SubscriptionHandler) ListSubscriptionCharges(ctx context.Context, req *pb.ListSubscriptionChargesRequest) (*pb.ListSubscriptionChargesResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "SubscriptionHandler.ListSubscriptionCharges")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("subscription.tenant_id", req.TenantId),
+		attribute.Int64("pagination.page", int64(req.Page)),
+		attribute.Int64("pagination.page_size", int64(req.PageSize)),
+	)
+
+	if err := authorizeTenant(ctx, req.TenantId); err != nil {
+		return nil, err
+	}
+
+	charges, total, err := h.subscriptionService.ListCharges(ctx, req.TenantId, int(req.Page), int(req.PageSize))
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to list charges: %v", err)
+	}
+
+	var protoCharges []*pb.SubscriptionCharge
+	for _, charge := range charges {
+		protoCharges = append(protoCharges, &pb.SubscriptionCharge{
+			ChargeId:       charge.ID,
+			SubscriptionId: charge.SubscriptionID,
+			Amount:         charge.Amount,
+			Currency:       charge.Currency,
+			Status:         charge.Status,
+			PaymentId:      charge.PaymentID,
+			Error:          charge.Error,
+			PeriodStart:    timestamppb.New(charge.PeriodStart),
+			PeriodEnd:      timestamppb.New(charge.PeriodEnd),
+			CreatedAt:      timestamppb.New(charge.CreatedAt),
+		})
+	}
+
+	return &pb.ListSubscriptionChargesResponse{
+		Charges:    protoCharges,
+		TotalCount: int32(total),
+		Page:       req.Page,
+		PageSize:   req.PageSize,
+	}, nil
+}
+
+// GetPlanLimits returns the plan limits that apply to a tenant
+func (h *SubscriptionHandler) GetPlanLimits(ctx context.Context, req *pb.GetPlanLimitsRequest) (*pb.GetPlanLimitsResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "SubscriptionHandler.GetPlanLimits")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("subscription.tenant_id", req.TenantId))
+
+	if err := authorizeTenant(ctx, req.TenantId); err != nil {
+		return nil, err
+	}
+
+	limits, err := h.subscriptionService.GetLimits(ctx, req.TenantId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to get plan limits: %v", err)
+	}
+	if limits == nil {
+		return nil, status.Errorf(codes.NotFound, "tenant has no subscription")
+	}
+
+	return &pb.GetPlanLimitsResponse{
+		PlanCode:          limits.Plan,
+		Status:            h.convertStatusToProto(limits.Status),
+		RequestsPerWindow: int32(limits.RequestsPerWindow),
+		Features:          limits.Features,
+	}, nil
+}
+
+// authorizeTenant checks that the caller identity propagated from the gateway
+// may act for a tenant. Callers act for their own user ID and tenant; admins
+// act for any tenant. Calls without an identity come from other services.
+func authorizeTenant(ctx context.Context, tenantID string) error {
+	identity, ok := middleware.IdentityFromContext(ctx)
+	if !ok || identity.HasRole("admin") {
+		return nil
+	}
+	if tenantID == identity.UserID || (identity.TenantID != "" && tenantID == identity.TenantID) {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "not allowed to manage this tenant's subscription")
+}
+
+// Helper methods for conversion
+func (h *SubscriptionHandler) convertToProtoPlan(plan *database.Plan) *pb.Plan {
+	var features []string
+	if plan.Features != "" {
+		features = strings.Split(plan.Features, ",")
+	}
+
+	return &pb.Plan{
+		Code:              plan.Code,
+		Name:              plan.Name,
+		Price:             plan.Price,
+		Currency:          plan.Currency,
+		Interval:          h.convertIntervalToProto(plan.Interval),
+		TrialDays:         int32(plan.TrialDays),
+		RequestsPerWindow: int32(plan.RequestsPerWindow),
+		Features:          features,
+		Active:            plan.Active,
+		CreatedAt:         timestamppb.New(plan.CreatedAt),
+	}
+}
+
+func (h *SubscriptionHandler) convertToProtoSubscription(subscription *database.Subscription) *pb.Subscription {
+	protoSubscription := &pb.Subscription{
+		SubscriptionId:     subscription.ID,
+		TenantId:           subscription.TenantID,
+		UserId:             subscription.UserID,
+		PlanCode:           subscription.PlanCode,
+		Status:             h.convertStatusToProto(subscription.Status),
+		CurrentPeriodStart: timestamppb.New(subscription.CurrentPeriodStart),
+		CurrentPeriodEnd:   timestamppb.New(subscription.CurrentPeriodEnd),
+		CancelAtPeriodEnd:  subscription.CancelAtPeriodEnd,
+		CreatedAt:          timestamppb.New(subscription.CreatedAt),
+	}
+	if subscription.TrialEndsAt != nil {
+		protoSubscription.TrialEndsAt = timestamppb.New(*subscription.TrialEndsAt)
+	}
+	if subscription.CancelledAt != nil {
+		protoSubscription.CancelledAt = timestamppb.New(*subscription.CancelledAt)
+	}
+
+	return protoSubscription
+}
+
+func (h *SubscriptionHandler) convertIntervalToProto(interval string) pb.BillingInterval {
+	switch interval {
+	case database.IntervalMonth:
+		return pb.BillingInterval_BILLING_INTERVAL_MONTH
+	case database.IntervalYear:
+		return pb.BillingInterval_BILLING_INTERVAL_YEAR
+	default:
+		return pb.BillingInterval_BILLING_INTERVAL_UNSPECIFIED
+	}
+}
+
+func (h *SubscriptionHandler) convertIntervalToString(interval pb.BillingInterval) string {
+	switch interval {
+	case pb.BillingInterval_BILLING_INTERVAL_YEAR:
+		return database.IntervalYear
+	default:
+		return database.IntervalMonth
+	}
+}
+
+func (h *SubscriptionHandler) convertStatusToProto(subscriptionStatus string) pb.SubscriptionStatus {
+	switch subscriptionStatus {
+	case database.SubscriptionStatusTrialing:
+		return pb.SubscriptionStatus_SUBSCRIPTION_STATUS_TRIALING
+	case database.SubscriptionStatusActive:
+		return pb.SubscriptionStatus_SUBSCRIPTION_STATUS_ACTIVE
+	case database.SubscriptionStatusPastDue:
+		return pb.SubscriptionStatus_SUBSCRIPTION_STATUS_PAST_DUE
+	case database.SubscriptionStatusCancelled:
+		return pb.SubscriptionStatus_SUBSCRIPTION_STATUS_CANCELLED
+	default:
+		return pb.SubscriptionStatus_SUBSCRIPTION_STATUS_UNSPECIFIED
+	}
+}
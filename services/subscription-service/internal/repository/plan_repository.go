@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"microservices-platform/services/subscription-service/internal/database"
+)
+
+// PlanRepository interface defines plan data operations
+type PlanRepository interface {
+	Create(ctx context.Context, plan *database.Plan) error
+	GetByCode(ctx context.Context, code string) (*database.Plan, error)
+	List(ctx context.Context, includeInactive bool) ([]*database.Plan, error)
+	Update(ctx context.Context, plan *database.Plan) error
+}
+
+// planRepository implements PlanRepository interface
+type planRepository struct {
+	db *gorm.DB
+}
+
+// NewPlanRepository creates a new plan repository
+func NewPlanRepository(db *gorm.DB) PlanRepository {
+	return &planRepository{
+		db: db,
+	}
+}
+
+// Create creates a new plan
+func (r *planRepository) Create(ctx context.Context, plan *database.Plan) error {
+	return r.db.WithContext(ctx).Create(plan).Error
+}
+
+// GetByCode retrieves a plan by its code
+func (r *planRepository) GetByCode(ctx context.Context, code string) (*database.Plan, error) {
+	var plan database.Plan
+	err := r.db.WithContext(ctx).First(&plan, "code = ?", code).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// List lists plans, cheapest first
+func (r *planRepository) List(ctx context.Context, includeInactive bool) ([]*database.Plan, error) {
+	var plans []*database.Plan
+	query := r.db.WithContext(ctx)
+	if !includeInactive {
+		query = query.Where("active = ?", true)
+	}
+	err := query.Order("price ASC").Find(&plans).Error
+	return plans, err
+}
+
+// Update saves a plan
+func (r *planRepository) Update(ctx context.Context, plan *database.Plan) error {
+	return r.db.WithContext(ctx).Save(plan).Error
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"microservices-platform/pkg/sharding"
+	"microservices-platform/services/subscription-service/internal/database"
+)
+
+// SubscriptionRepository interface defines subscription and charge data operations
+type SubscriptionRepository interface {
+	Create(ctx context.Context, subscription *database.Subscription) error
+	GetByID(ctx context.Context, id string) (*database.Subscription, error)
+	// GetOpenByTenantID returns the tenant's subscription that is not cancelled
+	GetOpenByTenantID(ctx context.Context, tenantID string) (*database.Subscription, error)
+	// GetLatestByTenantID returns the tenant's most recent subscription
+	GetLatestByTenantID(ctx context.Context, tenantID string) (*database.Subscription, error)
+	ListOpenByPlanCode(ctx context.Context, planCode string) ([]*database.Subscription, error)
+	Update(ctx context.Context, subscription *database.Subscription) error
+	// ClaimDue returns the subscriptions claimed along with an error if
+	// some shards could not be claimed from
+	ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*database.Subscription, error)
+	// CreateCharge records a charge of one of the tenant's subscriptions
+	CreateCharge(ctx context.Context, tenantID string, charge *database.SubscriptionCharge) error
+	ListChargesByTenantID(ctx context.Context, tenantID string, offset, limit int) ([]*database.SubscriptionCharge, int64, error)
+}
+
+// subscriptionRepository implements SubscriptionRepository interface.
+// Subscriptions and their charges live on their tenant's shard.
+type subscriptionRepository struct {
+	shards *sharding.Router
+}
+
+// NewSubscriptionRepository creates a new subscription repository
+func NewSubscriptionRepository(shards *sharding.Router) SubscriptionRepository {
+	return &subscriptionRepository{
+		shards: shards,
+	}
+}
+
+// Create creates a new subscription
+func (r *subscriptionRepository) Create(ctx context.Context, subscription *database.Subscription) error {
+	db, err := r.shards.For(ctx, subscription.TenantID)
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Create(subscription).Error
+}
+
+// GetByID retrieves a subscription by ID, searching every shard
+func (r *subscriptionRepository) GetByID(ctx context.Context, id string) (*database.Subscription, error) {
+	var found *database.Subscription
+	err := r.shards.Each(func(name string, db *gorm.DB) error {
+		if found != nil {
+			return nil
+		}
+		var subscriptions []*database.Subscription
+		if err := db.WithContext(ctx).Where("id = ?", id).Limit(1).Find(&subscriptions).Error; err != nil {
+			return err
+		}
+		if len(subscriptions) > 0 {
+			found = subscriptions[0]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// GetOpenByTenantID retrieves the tenant's subscription that is not cancelled
+func (r *subscriptionRepository) GetOpenByTenantID(ctx context.Context, tenantID string) (*database.Subscription, error) {
+	db, err := r.shards.For(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var subscription database.Subscription
+	err = db.WithContext(ctx).
+		Where("tenant_id = ? AND status <> ?", tenantID, database.SubscriptionStatusCancelled).
+		First(&subscription).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// GetLatestByTenantID retrieves the tenant's most recent subscription
+func (r *subscriptionRepository) GetLatestByTenantID(ctx context.Context, tenantID string) (*database.Subscription, error) {
+	db, err := r.shards.For(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var subscription database.Subscription
+	err = db.WithContext(ctx).
+		Where("tenant_id = ?", tenantID).
+		Order("created_at DESC").
+		First(&subscription).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// ListOpenByPlanCode lists the subscriptions to a plan that are not
+// cancelled, across every shard
+func (r *subscriptionRepository) ListOpenByPlanCode(ctx context.Context, planCode string) ([]*database.Subscription, error) {
+	var subscriptions []*database.Subscription
+	err := r.shards.Each(func(name string, db *gorm.DB) error {
+		var shardSubscriptions []*database.Subscription
+		err := db.WithContext(ctx).
+			Where("plan_code = ? AND status <> ?", planCode, database.SubscriptionStatusCancelled).
+			Find(&shardSubscriptions).Error
+		subscriptions = append(subscriptions, shardSubscriptions...)
+		return err
+	})
+	return subscriptions, err
+}
+
+// Update saves a subscription
+func (r *subscriptionRepository) Update(ctx context.Context, subscription *database.Subscription) error {
+	db, err := r.shards.For(ctx, subscription.TenantID)
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Save(subscription).Error
+}
+
+// ClaimDue claims up to limit open subscriptions whose next charge is due,
+// shard by shard. Their next charge is pushed to leaseUntil so that other
+// instances skip them while this one bills; locked rows are skipped rather
+// than waited on. A shard that fails does not keep the others from billing.
+func (r *subscriptionRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*database.Subscription, error) {
+	var subscriptions []*database.Subscription
+	var errs []error
+	r.shards.Each(func(name string, db *gorm.DB) error {
+		if len(subscriptions) >= limit {
+			return nil
+		}
+		claimed, err := claimDue(ctx, db, now, leaseUntil, limit-len(subscriptions))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", name, err))
+		}
+		subscriptions = append(subscriptions, claimed...)
+		return nil
+	})
+
+	return subscriptions, errors.Join(errs...)
+}
+
+// claimDue claims due subscriptions on one shard
+func claimDue(ctx context.Context, db *gorm.DB, now, leaseUntil time.Time, limit int) ([]*database.Subscription, error) {
+	var subscriptions []*database.Subscription
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status <> ? AND next_charge_at <= ?", database.SubscriptionStatusCancelled, now).
+			Order("next_charge_at ASC").
+			Limit(limit).
+			Find(&subscriptions).Error
+		if err != nil || len(subscriptions) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(subscriptions))
+		for i, subscription := range subscriptions {
+			ids[i] = subscription.ID
+		}
+		return tx.Model(&database.Subscription{}).
+			Where("id IN ?", ids).
+			Update("next_charge_at", leaseUntil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+// CreateCharge records a charge attempt on the tenant's shard
+func (r *subscriptionRepository) CreateCharge(ctx context.Context, tenantID string, charge *database.SubscriptionCharge) error {
+	db, err := r.shards.For(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Create(charge).Error
+}
+
+// ListChargesByTenantID lists the charges of all of a tenant's
+// subscriptions, newest first
+func (r *subscriptionRepository) ListChargesByTenantID(ctx context.Context, tenantID string, offset, limit int) ([]*database.SubscriptionCharge, int64, error) {
+	db, err := r.shards.For(ctx, tenantID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var charges []*database.SubscriptionCharge
+	var total int64
+
+	subscriptionIDs := db.Model(&database.Subscription{}).Select("id").Where("tenant_id = ?", tenantID)
+	query := db.WithContext(ctx).Model(&database.SubscriptionCharge{}).Where("subscription_id IN (?)", subscriptionIDs)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&charges).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return charges, total, nil
+}
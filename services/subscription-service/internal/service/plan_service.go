@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"microservices-platform/pkg/plans"
+	"microservices-platform/services/subscription-service/internal/database"
+	"microservices-platform/services/subscription-service/internal/repository"
+)
+
+// planCodePattern restricts plan codes to stable, URL-safe identifiers
+var planCodePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// PlanUpdate holds the plan fields to change; nil fields are left as they are
+type PlanUpdate struct {
+	Name              *string
+	Price             *float64
+	TrialDays         *int
+	RequestsPerWindow *int
+	Features          []string
+	ReplaceFeatures   bool
+	Active            *bool
+}
+
+// PlanService interface defines plan management operations
+type PlanService interface {
+	ListPlans(ctx context.Context, includeInactive bool) ([]*database.Plan, error)
+	CreatePlan(ctx context.Context, plan *database.Plan) (*database.Plan, error)
+	// UpdatePlan changes a plan. Limit changes apply to existing subscribers
+	// at once; price changes apply from their next renewal.
+	UpdatePlan(ctx context.Context, code string, update PlanUpdate) (*database.Plan, error)
+}
+
+// planService implements PlanService interface
+type planService struct {
+	planRepo         repository.PlanRepository
+	subscriptionRepo repository.SubscriptionRepository
+	limits           plans.Store
+}
+
+// NewPlanService creates a new plan service. limits may be nil if Redis is
+// unavailable, in which case plan limits are not published.
+func NewPlanService(planRepo repository.PlanRepository, subscriptionRepo repository.SubscriptionRepository, limits plans.Store) PlanService {
+	return &planService{
+		planRepo:         planRepo,
+		subscriptionRepo: subscriptionRepo,
+		limits:           limits,
+	}
+}
+
+// ListPlans lists plans, optionally including those no longer offered
+func (s *planService) ListPlans(ctx context.Context, includeInactive bool) ([]*database.Plan, error) {
+	return s.planRepo.List(ctx, includeInactive)
+}
+
+// CreatePlan validates and creates a plan
+func (s *planService) CreatePlan(ctx context.Context, plan *database.Plan) (*database.Plan, error) {
+	if !planCodePattern.MatchString(plan.Code) {
+		return nil, errors.New("plan code must be lowercase letters, digits, '-' or '_'")
+	}
+	if plan.Name == "" {
+		return nil, errors.New("plan name is required")
+	}
+	if plan.Interval != database.IntervalMonth && plan.Interval != database.IntervalYear {
+		return nil, errors.New("plan interval must be month or year")
+	}
+	if plan.Price > 0 && plan.Currency == "" {
+		return nil, errors.New("currency is required for paid plans")
+	}
+	if err := validatePlanValues(plan); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.planRepo.GetByCode(ctx, plan.Code)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("plan %q already exists", plan.Code)
+	}
+
+	plan.Currency = strings.ToUpper(plan.Currency)
+	plan.Active = true
+	if err := s.planRepo.Create(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// UpdatePlan changes a plan and republishes the limits of its subscribers
+func (s *planService) UpdatePlan(ctx context.Context, code string, update PlanUpdate) (*database.Plan, error) {
+	plan, err := s.planRepo.GetByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return nil, errors.New("plan not found")
+	}
+
+	if update.Name != nil {
+		plan.Name = *update.Name
+	}
+	if update.Price != nil {
+		plan.Price = *update.Price
+	}
+	if update.TrialDays != nil {
+		plan.TrialDays = *update.TrialDays
+	}
+	if update.RequestsPerWindow != nil {
+		plan.RequestsPerWindow = *update.RequestsPerWindow
+	}
+	if update.ReplaceFeatures {
+		plan.Features = strings.Join(update.Features, ",")
+	}
+	if update.Active != nil {
+		plan.Active = *update.Active
+	}
+	if err := validatePlanValues(plan); err != nil {
+		return nil, err
+	}
+
+	if err := s.planRepo.Update(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	subscriptions, err := s.subscriptionRepo.ListOpenByPlanCode(ctx, plan.Code)
+	if err != nil {
+		return nil, err
+	}
+	for _, subscription := range subscriptions {
+		publishLimits(ctx, s.limits, subscription, plan)
+	}
+
+	return plan, nil
+}
+
+// validatePlanValues checks the numeric plan fields
+func validatePlanValues(plan *database.Plan) error {
+	if plan.Price < 0 {
+		return errors.New("plan price cannot be negative")
+	}
+	if plan.TrialDays < 0 {
+		return errors.New("trial days cannot be negative")
+	}
+	if plan.RequestsPerWindow < 0 {
+		return errors.New("requests per window cannot be negative")
+	}
+	return nil
+}
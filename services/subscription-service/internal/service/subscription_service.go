@@ -0,0 +1,471 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/grpcclient"
+	"microservices-platform/pkg/plans"
+	paymentpb "microservices-platform/pkg/proto/payment/v1"
+	"microservices-platform/services/subscription-service/internal/config"
+	"microservices-platform/services/subscription-service/internal/database"
+	"microservices-platform/services/subscription-service/internal/repository"
+)
+
+// billingBatchSize is how many due subscriptions are claimed per poll
+const billingBatchSize = 50
+
+// billingLease is how long a claimed subscription is held by one instance
+const billingLease = 10 * time.Minute
+
+// SubscriptionService interface defines subscription lifecycle operations
+type SubscriptionService interface {
+	Subscribe(ctx context.Context, tenantID, userID, planCode, paymentMethodToken string) (*database.Subscription, error)
+	GetSubscription(ctx context.Context, tenantID string) (*database.Subscription, error)
+	ChangePlan(ctx context.Context, tenantID, planCode string) (*database.Subscription, error)
+	Cancel(ctx context.Context, tenantID string, immediately bool) (*database.Subscription, error)
+	ListCharges(ctx context.Context, tenantID string, page, pageSize int) ([]*database.SubscriptionCharge, int64, error)
+	// GetLimits returns the limits of the tenant's plan, or nil if the tenant
+	// has no open subscription
+	GetLimits(ctx context.Context, tenantID string) (*plans.Limits, error)
+	// StartBilling runs the renewal worker until ctx is cancelled
+	StartBilling(ctx context.Context)
+}
+
+// subscriptionService implements SubscriptionService interface
+type subscriptionService struct {
+	planRepo         repository.PlanRepository
+	subscriptionRepo repository.SubscriptionRepository
+	limits           plans.Store
+	eventBus         events.EventBus
+	paymentClient    paymentpb.PaymentServiceClient
+
+	pollInterval     time.Duration
+	retryInterval    time.Duration
+	maxFailedCharges int
+}
+
+// NewSubscriptionService creates a new subscription service. limits may be
+// nil if Redis is unavailable, in which case plan limits are not published.
+func NewSubscriptionService(planRepo repository.PlanRepository, subscriptionRepo repository.SubscriptionRepository, limits plans.Store, eventBus events.EventBus, cfg *config.Config) SubscriptionService {
+	paymentConn, err := grpcclient.Dial(cfg.PaymentServiceURL, cfg.GRPCClient)
+	if err != nil {
+		log.Printf("Failed to connect to payment service: %v", err)
+	}
+
+	return &subscriptionService{
+		planRepo:         planRepo,
+		subscriptionRepo: subscriptionRepo,
+		limits:           limits,
+		eventBus:         eventBus,
+		paymentClient:    paymentpb.NewPaymentServiceClient(paymentConn),
+		pollInterval:     cfg.BillingPollInterval,
+		retryInterval:    cfg.ChargeRetryInterval,
+		maxFailedCharges: cfg.MaxFailedCharges,
+	}
+}
+
+// Subscribe subscribes a tenant to a plan. Plans with a trial start trialing
+// and are first charged when the trial ends; other paid plans are charged
+// for the first period immediately.
+func (s *subscriptionService) Subscribe(ctx context.Context, tenantID, userID, planCode, paymentMethodToken string) (*database.Subscription, error) {
+	if tenantID == "" || userID == "" {
+		return nil, errors.New("tenant and user are required")
+	}
+
+	plan, err := s.activePlan(ctx, planCode)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Price > 0 && paymentMethodToken == "" {
+		return nil, errors.New("a payment method is required for paid plans")
+	}
+
+	existing, err := s.subscriptionRepo.GetOpenByTenantID(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errors.New("tenant already has a subscription")
+	}
+
+	now := time.Now().UTC()
+	subscription := &database.Subscription{
+		TenantID:           tenantID,
+		UserID:             userID,
+		PlanCode:           plan.Code,
+		PaymentMethodToken: paymentMethodToken,
+		CurrentPeriodStart: now,
+	}
+
+	if plan.TrialDays > 0 {
+		trialEnd := now.AddDate(0, 0, plan.TrialDays)
+		subscription.Status = database.SubscriptionStatusTrialing
+		subscription.TrialEndsAt = &trialEnd
+		subscription.CurrentPeriodEnd = trialEnd
+	} else {
+		subscription.Status = database.SubscriptionStatusActive
+		subscription.CurrentPeriodEnd = advancePeriod(now, plan.Interval)
+	}
+	nextCharge := subscription.CurrentPeriodEnd
+	subscription.NextChargeAt = &nextCharge
+
+	if err := s.subscriptionRepo.Create(ctx, subscription); err != nil {
+		return nil, err
+	}
+
+	if subscription.Status == database.SubscriptionStatusActive && plan.Price > 0 {
+		if _, err := s.charge(ctx, subscription, plan, subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd); err != nil {
+			// The failed signup is kept, cancelled, alongside its charge
+			subscription.Status = database.SubscriptionStatusCancelled
+			subscription.CancelledAt = &now
+			subscription.NextChargeAt = nil
+			if updateErr := s.subscriptionRepo.Update(ctx, subscription); updateErr != nil {
+				log.Printf("Failed to cancel subscription %s after failed charge: %v", subscription.ID, updateErr)
+			}
+			return nil, fmt.Errorf("payment failed: %v", err)
+		}
+	}
+
+	publishLimits(ctx, s.limits, subscription, plan)
+	s.publish(ctx, events.SubscriptionCreated, subscription, nil)
+
+	return subscription, nil
+}
+
+// GetSubscription returns the tenant's most recent subscription
+func (s *subscriptionService) GetSubscription(ctx context.Context, tenantID string) (*database.Subscription, error) {
+	subscription, err := s.subscriptionRepo.GetLatestByTenantID(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == nil {
+		return nil, errors.New("subscription not found")
+	}
+	return subscription, nil
+}
+
+// ChangePlan moves a tenant to another plan. The new limits apply at once
+// and the new price from the next renewal; there is no proration.
+func (s *subscriptionService) ChangePlan(ctx context.Context, tenantID, planCode string) (*database.Subscription, error) {
+	subscription, err := s.openSubscription(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.PlanCode == planCode {
+		return nil, errors.New("tenant is already on this plan")
+	}
+
+	plan, err := s.activePlan(ctx, planCode)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Price > 0 && subscription.PaymentMethodToken == "" {
+		return nil, errors.New("a payment method is required for paid plans")
+	}
+
+	previousPlan := subscription.PlanCode
+	subscription.PlanCode = plan.Code
+	if err := s.subscriptionRepo.Update(ctx, subscription); err != nil {
+		return nil, err
+	}
+
+	publishLimits(ctx, s.limits, subscription, plan)
+	s.publish(ctx, events.SubscriptionPlanChanged, subscription, map[string]interface{}{
+		"previous_plan": previousPlan,
+	})
+
+	return subscription, nil
+}
+
+// Cancel cancels a tenant's subscription, either now or when the current
+// period (or trial) ends
+func (s *subscriptionService) Cancel(ctx context.Context, tenantID string, immediately bool) (*database.Subscription, error) {
+	subscription, err := s.openSubscription(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !immediately {
+		subscription.CancelAtPeriodEnd = true
+		if err := s.subscriptionRepo.Update(ctx, subscription); err != nil {
+			return nil, err
+		}
+		return subscription, nil
+	}
+
+	if err := s.cancel(ctx, subscription, "requested"); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// ListCharges lists the charges of a tenant's subscriptions
+func (s *subscriptionService) ListCharges(ctx context.Context, tenantID string, page, pageSize int) ([]*database.SubscriptionCharge, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	return s.subscriptionRepo.ListChargesByTenantID(ctx, tenantID, (page-1)*pageSize, pageSize)
+}
+
+// GetLimits returns the limits of the tenant's current plan
+func (s *subscriptionService) GetLimits(ctx context.Context, tenantID string) (*plans.Limits, error) {
+	subscription, err := s.subscriptionRepo.GetOpenByTenantID(ctx, tenantID)
+	if err != nil || subscription == nil {
+		return nil, err
+	}
+
+	plan, err := s.planRepo.GetByCode(ctx, subscription.PlanCode)
+	if err != nil || plan == nil {
+		return nil, err
+	}
+
+	return limitsFor(subscription, plan), nil
+}
+
+// StartBilling runs the renewal worker until ctx is cancelled. Any number of
+// instances can run it; due subscriptions are claimed with row locks.
+func (s *subscriptionService) StartBilling(ctx context.Context) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.renewDue(ctx); err != nil {
+				log.Printf("Failed to renew subscriptions: %v", err)
+			}
+		}
+	}
+}
+
+// renewDue renews, retries or cancels every subscription that is due
+func (s *subscriptionService) renewDue(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	// Subscriptions claimed from the shards that answered are billed even
+	// if another failed; that one is retried on the next poll
+	subscriptions, err := s.subscriptionRepo.ClaimDue(ctx, now, now.Add(billingLease), billingBatchSize)
+
+	for _, subscription := range subscriptions {
+		if err := s.renew(ctx, subscription); err != nil {
+			log.Printf("Failed to renew subscription %s: %v", subscription.ID, err)
+		}
+	}
+
+	return err
+}
+
+// renew starts the next period of a due subscription. A failed charge is
+// retried every retry interval, keeping the plan's limits meanwhile, and the
+// subscription is cancelled once the charge has failed too often.
+func (s *subscriptionService) renew(ctx context.Context, subscription *database.Subscription) error {
+	if subscription.CancelAtPeriodEnd {
+		return s.cancel(ctx, subscription, "period_ended")
+	}
+
+	plan, err := s.planRepo.GetByCode(ctx, subscription.PlanCode)
+	if err != nil {
+		return err
+	}
+	if plan == nil {
+		return fmt.Errorf("plan %q not found", subscription.PlanCode)
+	}
+
+	periodStart := subscription.CurrentPeriodEnd
+	periodEnd := advancePeriod(periodStart, plan.Interval)
+
+	if plan.Price > 0 {
+		if _, err := s.charge(ctx, subscription, plan, periodStart, periodEnd); err != nil {
+			return s.chargeFailed(ctx, subscription, err)
+		}
+	}
+
+	subscription.Status = database.SubscriptionStatusActive
+	subscription.CurrentPeriodStart = periodStart
+	subscription.CurrentPeriodEnd = periodEnd
+	subscription.FailedCharges = 0
+	subscription.NextChargeAt = &periodEnd
+	if err := s.subscriptionRepo.Update(ctx, subscription); err != nil {
+		return err
+	}
+
+	publishLimits(ctx, s.limits, subscription, plan)
+	s.publish(ctx, events.SubscriptionRenewed, subscription, nil)
+	return nil
+}
+
+// chargeFailed schedules a retry of a failed renewal charge, or cancels the
+// subscription when no retries are left
+func (s *subscriptionService) chargeFailed(ctx context.Context, subscription *database.Subscription, chargeErr error) error {
+	subscription.FailedCharges++
+	if subscription.FailedCharges >= s.maxFailedCharges {
+		return s.cancel(ctx, subscription, "payment_failed")
+	}
+
+	retryAt := time.Now().UTC().Add(s.retryInterval)
+	subscription.Status = database.SubscriptionStatusPastDue
+	subscription.NextChargeAt = &retryAt
+	if err := s.subscriptionRepo.Update(ctx, subscription); err != nil {
+		return err
+	}
+
+	s.publish(ctx, events.SubscriptionPaymentFailed, subscription, map[string]interface{}{
+		"failed_charges": subscription.FailedCharges,
+		"retry_at":       retryAt.Unix(),
+		"error":          chargeErr.Error(),
+	})
+	return nil
+}
+
+// cancel ends a subscription now and withdraws its plan limits
+func (s *subscriptionService) cancel(ctx context.Context, subscription *database.Subscription, reason string) error {
+	now := time.Now().UTC()
+	subscription.Status = database.SubscriptionStatusCancelled
+	subscription.CancelledAt = &now
+	subscription.NextChargeAt = nil
+	if err := s.subscriptionRepo.Update(ctx, subscription); err != nil {
+		return err
+	}
+
+	if s.limits != nil {
+		if err := s.limits.Delete(ctx, subscription.TenantID); err != nil {
+			log.Printf("Failed to withdraw plan limits of tenant %s: %v", subscription.TenantID, err)
+		}
+	}
+	s.publish(ctx, events.SubscriptionCancelled, subscription, map[string]interface{}{
+		"reason": reason,
+	})
+	return nil
+}
+
+// charge collects one period of a plan from the subscription's saved payment
+// method and records the attempt. Retries of the same period use the same
+// idempotency key until the failure is recorded.
+func (s *subscriptionService) charge(ctx context.Context, subscription *database.Subscription, plan *database.Plan, periodStart, periodEnd time.Time) (*database.SubscriptionCharge, error) {
+	charge := &database.SubscriptionCharge{
+		SubscriptionID: subscription.ID,
+		Amount:         plan.Price,
+		Currency:       plan.Currency,
+		PeriodStart:    periodStart,
+		PeriodEnd:      periodEnd,
+	}
+
+	resp, err := s.paymentClient.ProcessPayment(ctx, &paymentpb.ProcessPaymentRequest{
+		UserId:             subscription.UserID,
+		Amount:             plan.Price,
+		Currency:           plan.Currency,
+		SubscriptionId:     subscription.ID,
+		PaymentMethodToken: subscription.PaymentMethodToken,
+		IdempotencyKey:     fmt.Sprintf("%s:%d:%d", subscription.ID, periodStart.Unix(), subscription.FailedCharges),
+	})
+	if err == nil && resp.Payment.Status != paymentpb.PaymentStatus_PAYMENT_STATUS_COMPLETED {
+		err = fmt.Errorf("payment %s", strings.ToLower(strings.TrimPrefix(resp.Payment.Status.String(), "PAYMENT_STATUS_")))
+	}
+
+	if err != nil {
+		charge.Status = database.ChargeStatusFailed
+		charge.Error = err.Error()
+	} else {
+		charge.Status = database.ChargeStatusSucceeded
+		charge.PaymentID = resp.Payment.PaymentId
+	}
+	if recordErr := s.subscriptionRepo.CreateCharge(ctx, subscription.TenantID, charge); recordErr != nil {
+		log.Printf("Failed to record charge for subscription %s: %v", subscription.ID, recordErr)
+	}
+
+	return charge, err
+}
+
+// activePlan returns a plan that is on offer
+func (s *subscriptionService) activePlan(ctx context.Context, code string) (*database.Plan, error) {
+	plan, err := s.planRepo.GetByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil || !plan.Active {
+		return nil, errors.New("plan not found")
+	}
+	return plan, nil
+}
+
+// openSubscription returns the tenant's subscription that is not cancelled
+func (s *subscriptionService) openSubscription(ctx context.Context, tenantID string) (*database.Subscription, error) {
+	subscription, err := s.subscriptionRepo.GetOpenByTenantID(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == nil {
+		return nil, errors.New("subscription not found")
+	}
+	return subscription, nil
+}
+
+// publish publishes a subscription event, logging failures
+func (s *subscriptionService) publish(ctx context.Context, eventType events.EventType, subscription *database.Subscription, extra map[string]interface{}) {
+	data := map[string]interface{}{
+		"subscription_id": subscription.ID,
+		"tenant_id":       subscription.TenantID,
+		"user_id":         subscription.UserID,
+		"plan":            subscription.PlanCode,
+		"status":          subscription.Status,
+	}
+	for key, value := range extra {
+		data[key] = value
+	}
+
+	err := s.eventBus.Publish(ctx, &events.Event{
+		Type:    eventType,
+		Source:  "subscription-service",
+		Subject: subscription.TenantID,
+		Data:    data,
+	})
+	if err != nil {
+		log.Printf("Failed to publish %s event: %v", eventType, err)
+	}
+}
+
+// publishLimits publishes the limits a subscription's plan gives its tenant
+func publishLimits(ctx context.Context, store plans.Store, subscription *database.Subscription, plan *database.Plan) {
+	if store == nil {
+		return
+	}
+	if err := store.Set(ctx, limitsFor(subscription, plan)); err != nil {
+		log.Printf("Failed to publish plan limits of tenant %s: %v", subscription.TenantID, err)
+	}
+}
+
+// limitsFor returns the limits a plan gives a subscription's tenant
+func limitsFor(subscription *database.Subscription, plan *database.Plan) *plans.Limits {
+	var features []string
+	if plan.Features != "" {
+		features = strings.Split(plan.Features, ",")
+	}
+
+	return &plans.Limits{
+		TenantID:          subscription.TenantID,
+		Plan:              plan.Code,
+		Status:            subscription.Status,
+		RequestsPerWindow: plan.RequestsPerWindow,
+		Features:          features,
+	}
+}
+
+// advancePeriod returns the end of a billing period starting at start
+func advancePeriod(start time.Time, interval string) time.Time {
+	if interval == database.IntervalYear {
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
@@ -6,11 +6,10 @@ import (
 	"log"
 	"net"
 	"os"
-	"os/signal"
-	"syscall"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
+	"gorm.io/gorm"
 	
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
@@ -19,6 +18,25 @@ import (
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 
+	"microservices-platform/pkg/app"
+	"microservices-platform/pkg/buildinfo"
+	"microservices-platform/pkg/dbhealth"
+	"microservices-platform/pkg/debug"
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/geoip"
+	"microservices-platform/pkg/grpcclient"
+	"microservices-platform/pkg/grpcserver"
+	"microservices-platform/pkg/instrumentation"
+	"microservices-platform/pkg/logging"
+	"microservices-platform/pkg/middleware"
+	"microservices-platform/pkg/operations"
+	"microservices-platform/pkg/passwordpolicy"
+	"microservices-platform/pkg/plans"
+	"microservices-platform/pkg/revocation"
+	"microservices-platform/pkg/schemadrift"
+	"microservices-platform/pkg/startup"
+	"microservices-platform/pkg/storage"
+	"microservices-platform/pkg/watchdog"
 	"microservices-platform/services/user-service/internal/config"
 	"microservices-platform/services/user-service/internal/database"
 	"microservices-platform/services/user-service/internal/handler"
@@ -31,66 +49,335 @@ func main() {
 	// Initialize configuration
 	cfg := config.Load()
 
+	// Compare the database schema with the models and exit, e.g. in CI
+	if len(os.Args) > 1 && os.Args[1] == schemadrift.Command {
+		os.Exit(schemadrift.Main(cfg.ServiceName, cfg.DatabaseURL, cfg.SchemaDrift, database.Models()...))
+	}
+
+	// Filter logs by level and rate limit repeated errors
+	if err := logging.Setup(cfg.Logging); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+
+	// Announce the build and report it in build_info
+	buildinfo.LogStartup(cfg.ServiceName)
+
+	// Refuse debug facilities in production unless explicitly allowed
+	if err := cfg.Debug.Check(cfg.Environment); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+
+	// Sign tokens as configured, refusing placeholder secrets in production
+	tokens, err := service.NewTokenSigner(cfg.Security, cfg.Environment)
+	if err != nil {
+		log.Fatalf("Invalid token signing configuration: %v", err)
+	}
+
+	// Run the service through init, migrate, serve, drain and shutdown.
+	// Resources are released in the reverse order they are registered.
+	application := app.New(cfg.ServiceName, cfg.App)
+
 	// Initialize OpenTelemetry
 	tp, err := initTracer(cfg.ServiceName)
 	if err != nil {
 		log.Fatalf("Failed to initialize tracer: %v", err)
 	}
-	defer func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
+	application.OnShutdown("tracer", tp.Shutdown)
+
+	// Continuously profile CPU and heap when enabled
+	profiler, err := instrumentation.StartProfiling(cfg.ServiceName, cfg.Observability)
+	if err != nil {
+		log.Fatalf("Failed to start profiling: %v", err)
+	}
+	application.OnShutdown("profiler", func(ctx context.Context) error {
+		profiler.Stop()
+		return nil
+	})
+
+	// Connect to dependencies in order, retrying until they are available.
+	// Readiness reports "starting" until the gRPC server is listening.
+	starter := startup.NewManager(cfg.Startup)
+
+	// Serve metrics, health, build info and the admin and debug endpoints on
+	// the internal observability port
+	application.Handle("/health/", starter.Handler())
+	application.Handle(buildinfo.Path, buildinfo.Handler(cfg.ServiceName))
+	if cfg.Logging.AdminToken != "" {
+		application.Handle(logging.AdminPath, logging.Handler(cfg.Logging.AdminToken))
+	}
+	if cfg.Debug.Pprof {
+		application.Handle(debug.PprofPath, cfg.Debug.PprofHandler())
+	}
+	if err := application.ServeObservability(); err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+
+	var db *gorm.DB
+	starter.Require("postgres", func(ctx context.Context) error {
+		conn, err := database.NewConnection(cfg.DatabaseURL, cfg.Debug.VerboseSQL)
+		if err != nil {
+			return err
 		}
-	}()
+		// Trace every query and explain slow ones
+		if err := conn.Use(instrumentation.NewQueryTracer(cfg.ServiceName, cfg.QueryTracing)); err != nil {
+			return err
+		}
+		db = conn
+		return nil
+	})
+
+	// Object storage for avatars and data exports; both are disabled if
+	// unavailable
+	var avatarStore storage.ObjectStore
+	starter.Optional("object-storage", func(ctx context.Context) error {
+		store, err := storage.NewS3Store(ctx, storage.Config{
+			Endpoint:  cfg.StorageEndpoint,
+			AccessKey: cfg.StorageAccessKey,
+			SecretKey: cfg.StorageSecretKey,
+			Bucket:    cfg.StorageBucket,
+			UseSSL:    cfg.StorageUseSSL,
+			PublicURL: cfg.StoragePublicURL,
+		})
+		if err != nil {
+			return err
+		}
+		avatarStore = store
+		return nil
+	})
+
+	// Event bus for the user deletion saga. Redis is optional while
+	// degradation is enabled.
+	dialEventBus := events.RedisDialer(cfg.Redis, cfg.EventBus)
+	var redisBus events.EventBus
+	connectEventBus := func(ctx context.Context) error {
+		bus, err := dialEventBus()
+		if err != nil {
+			return err
+		}
+		redisBus = bus
+		return nil
+	}
+	if cfg.Degradation.Enabled {
+		starter.Optional("redis", connectEventBus)
+	} else {
+		starter.Require("redis", connectEventBus)
+	}
+
+	application.OnInit("dependencies", starter.Run)
+	application.OnMigrate("postgres", func(ctx context.Context) error {
+		if err := database.Migrate(db); err != nil {
+			return err
+		}
+		if err := schemadrift.Record(db, cfg.ServiceName, database.Models()...); err != nil {
+			return err
+		}
+		return schemadrift.Verify(ctx, db, cfg.ServiceName, cfg.SchemaDrift, database.Models()...)
+	})
+	if err := application.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
 
-	// Initialize database
-	db, err := database.NewConnection(cfg.DatabaseURL)
+	if avatarStore == nil {
+		log.Printf("Object storage unavailable, avatar uploads and data exports disabled")
+	}
+
+	// While Redis is unreachable, events are buffered in the local outbox and
+	// published once it recovers; readiness reports "degraded" meanwhile
+	outbox, err := events.NewOutbox(events.OutboxConfig{
+		Service: cfg.ServiceName,
+		MaxSize: cfg.Degradation.OutboxSize,
+		Policy:  events.OverflowPolicy(cfg.Degradation.OutboxOverflowPolicy),
+		Path:    cfg.Degradation.OutboxPath,
+	})
+	if err != nil {
+		log.Fatalf("Failed to open event outbox: %v", err)
+	}
+	eventBus := events.NewDegradingEventBus(redisBus, dialEventBus, outbox, cfg.Degradation.RetryInterval)
+	eventBus.OnChange(func(err error) {
+		starter.Report("redis", err)
+	})
+
+	// Publish events from request paths in the background, so Redis latency
+	// does not add to theirs; events it cannot keep up with go to the outbox
+	publisher := events.NewAsyncEventBus(eventBus, outbox, cfg.EventBus)
+
+	// Watch the database connection and re-dial after a restart or failover
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to get database handle: %v", err)
 	}
+	application.OnShutdown("postgres", func(ctx context.Context) error {
+		return sqlDB.Close()
+	})
+	dbMonitor := dbhealth.NewMonitor(cfg.ServiceName, "postgres", sqlDB, cfg.Database, eventBus)
+	dbMonitor.OnChange(func(err error) {
+		starter.Report("postgres", err)
+	})
+	dbMonitor.Start(context.Background())
+
+	// Watch for leaked goroutines, file descriptors and pooled connections
+	leaks := watchdog.New(cfg.ServiceName, cfg.Watchdog)
+	leaks.WatchDB("postgres", sqlDB)
+	leaks.Start(context.Background())
 
 	// Initialize repository
 	userRepo := repository.NewUserRepository(db)
+	addressRepo := repository.NewAddressRepository(db)
+	exportRepo := repository.NewExportRepository(db)
+	deletionRepo := repository.NewDeletionRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	orgRepo := repository.NewOrganizationRepository(db)
+	loginRepo := repository.NewLoginRepository(db)
+
+	// Track data exports and deletions as long-running operations. Without
+	// Redis, exports are built synchronously and deletions are only reported
+	// by GetDeletionStatus.
+	var opStore operations.Store
+	if store, err := operations.NewRedisStore(cfg.Redis, cfg.Operations); err != nil {
+		log.Printf("Operation store unavailable, long-running operations are not tracked: %v", err)
+	} else {
+		opStore = store
+	}
+	runner := operations.NewRunner(opStore, cfg.ServiceName, cfg.Operations)
+
+	// Enrich issued tokens with organization roles and tenant, plan and
+	// feature flags. Without the plan store, tokens carry no plan claims.
+	tokens.Use(service.OrganizationClaims(orgRepo))
+	if store, err := plans.NewRedisStore(cfg.Redis); err != nil {
+		log.Printf("Plan store unavailable, tokens are issued without plan claims: %v", err)
+	} else {
+		tokens.Use(service.PlanClaims(store))
+	}
+	tokens.Use(service.FeatureFlagClaims(cfg.FeatureFlags))
+
+	passwords, err := passwordpolicy.New(cfg.Passwords)
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+	if err := cfg.Security.ValidateArgon2(); err != nil {
+		log.Fatalf("Invalid password hashing configuration: %v", err)
+	}
 
 	// Initialize service
-	userService := service.NewUserService(userRepo)
+	userService := service.NewUserService(userRepo, avatarStore, passwords, service.NewArgon2Params(cfg.Security), tokens)
+	addressService := service.NewAddressService(addressRepo, userRepo)
+	exportService := service.NewExportService(userRepo, addressRepo, exportRepo, avatarStore, runner, cfg)
+	deletionService := service.NewDeletionService(userRepo, addressRepo, exportRepo, deletionRepo, webhookRepo, orgRepo, loginRepo, avatarStore, publisher, opStore, cfg.DeletionParticipants)
+	var revocations revocation.Store
+	if store, err := revocation.NewRedisStore(cfg.Redis); err != nil {
+		log.Printf("Revocation store unavailable, tokens of suspended users work until they expire: %v", err)
+	} else {
+		revocations = store
+	}
+	adminService := service.NewAdminService(userRepo, publisher, revocations, tokens.Expiration())
+	importService := service.NewImportService(userRepo, publisher, passwords, service.NewArgon2Params(cfg.Security))
+	webhookService := service.NewWebhookService(webhookRepo, userRepo, cfg)
+	organizationService := service.NewOrganizationService(orgRepo, userRepo, cfg)
+
+	// Sign-in alerts detect impossible travel only with a geolocation API
+	var locator geoip.Locator
+	if cfg.GeoIPURL != "" {
+		locator = geoip.NewHTTPLocator(cfg.GeoIPURL, cfg.GeoIPTimeout)
+	} else {
+		log.Printf("GEOIP_URL not set, sign-in alerts cover new devices only")
+	}
+	loginService := service.NewLoginService(loginRepo, userRepo, locator, publisher, cfg)
+
+	// Deduplicate redelivered events so each consumer handles an event once
+	var idempotency events.IdempotencyStore
+	if store, err := events.NewRedisIdempotencyStore(cfg.Redis); err != nil {
+		log.Printf("Idempotency store unavailable, redelivered events may be handled twice: %v", err)
+	} else {
+		idempotency = store
+	}
+
+	// user-service both coordinates deletions and takes part in them
+	eventBus.Subscribe(events.UserDeletionRequested, events.Idempotent(idempotency, "user-service:deletion-participant", events.DefaultIdempotencyTTL,
+		events.DeletionParticipant(eventBus, service.DeletionServiceName, deletionService.AnonymizeUser)))
+	eventBus.Subscribe(events.UserDeletionStepCompleted, events.Idempotent(idempotency, "user-service:deletion-coordinator", events.DefaultIdempotencyTTL,
+		deletionService.HandleStepCompleted))
+
+	// Fan events out to partner webhooks; the worker delivers and retries them
+	for _, eventType := range cfg.WebhookEventTypes {
+		eventBus.Subscribe(events.EventType(eventType), events.Idempotent(idempotency, "user-service:webhooks", events.DefaultIdempotencyTTL,
+			webhookService.HandleEvent))
+	}
+	application.OnServe("webhooks", func(ctx context.Context) error {
+		webhookService.Start(ctx)
+		return nil
+	})
+	if err := eventBus.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start event bus: %v", err)
+	}
+	application.OnShutdown("event-bus", func(ctx context.Context) error {
+		return eventBus.Stop()
+	})
+	// Shutdown hooks run in reverse, so queued events are published before
+	// the bus stops
+	application.OnShutdown("event-publisher", publisher.Close)
 
 	// Initialize gRPC handler
-	userHandler := handler.NewUserHandler(userService)
+	userHandler := handler.NewUserHandler(userService, addressService, exportService, deletionService, adminService, webhookService, organizationService, loginService, importService)
 
-	// Create gRPC server with OpenTelemetry interceptors
-	server := grpc.NewServer(
-		grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
-		grpc.StreamInterceptor(otelgrpc.StreamServerInterceptor()),
-	)
+	// Create gRPC server with OpenTelemetry interceptors, verifying the
+	// caller identity signed by the gateway when a secret is configured
+	unaryInterceptors := []grpc.UnaryServerInterceptor{otelgrpc.UnaryServerInterceptor()}
+	streamInterceptors := []grpc.StreamServerInterceptor{otelgrpc.StreamServerInterceptor()}
+	if cfg.Identity.Secret != "" {
+		unaryInterceptors = append(unaryInterceptors, middleware.IdentityUnaryInterceptor(cfg.Identity.Secret))
+		streamInterceptors = append(streamInterceptors, middleware.IdentityStreamInterceptor(cfg.Identity.Secret))
+	}
+	serverOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+		// Accept the keepalive pings of other services' clients
+		grpcclient.KeepaliveEnforcementPolicy(cfg.GRPCClient),
+		// Recycle connections so clients spread over new instances
+		grpcserver.KeepaliveParams(cfg.GRPCServer),
+	}
+	// Limit message sizes, compress large responses and record their sizes
+	serverOptions = append(serverOptions, grpcserver.MessageOptions(cfg.GRPCServer)...)
+	server := grpc.NewServer(serverOptions...)
 
 	// Register service
 	pb.RegisterUserServiceServer(server, userHandler)
+	// Report serving status to health-checking clients
+	healthServer := grpcserver.RegisterHealth(server)
+	// Serve the build to gRPC clients, as /version does over HTTP
+	grpcserver.RegisterBuildInfo(server, cfg.ServiceName)
 
 	// Enable reflection for debugging
-	reflection.Register(server)
+	if cfg.Debug.GRPCReflection {
+		reflection.Register(server)
+	}
 
 	// Create listener
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", cfg.Port))
 	if err != nil {
 		log.Fatalf("Failed to listen on port %s: %v", cfg.Port, err)
 	}
+	starter.MarkReady()
 
-	// Graceful shutdown
-	go func() {
+	application.OnServe("grpc", func(ctx context.Context) error {
 		log.Printf("User service starting on port %s", cfg.Port)
-		if err := server.Serve(lis); err != nil {
-			log.Fatalf("Failed to serve: %v", err)
-		}
-	}()
-
-	// Wait for interrupt signal to gracefully shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+		return server.Serve(lis)
+	})
+	application.OnDrain("grpc", func(ctx context.Context) error {
+		starter.MarkDraining()
+		healthServer.Shutdown()
+		grpcserver.Drain(server, cfg.GRPCServer)
+		return nil
+	})
+	// Give background exports a chance to finish
+	application.OnDrain("operations", func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, cfg.GRPCServer.ShutdownTimeout)
+		defer cancel()
+		return runner.Shutdown(ctx)
+	})
 
-	log.Println("Shutting down user service...")
-	server.GracefulStop()
-	log.Println("User service stopped")
+	if err := application.Run(); err != nil {
+		log.Fatalf("Failed to serve: %v", err)
+	}
 }
 
 // initTracer creates and configures OpenTelemetry tracer
@@ -103,12 +390,15 @@ func initTracer(serviceName string) (*tracesdk.TracerProvider, error) {
 
 	tp := tracesdk.NewTracerProvider(
 		tracesdk.WithBatcher(exp),
+		// Record business baggage, e.g. the tenant, on every span
+		tracesdk.WithSpanProcessor(instrumentation.NewBaggageSpanProcessor()),
 		tracesdk.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(serviceName),
+			buildinfo.ResourceAttributes(serviceName)...,
 		)),
 	)
 
 	otel.SetTracerProvider(tp)
+	instrumentation.SetupPropagation()
 	return tp, nil
 }
\ No newline at end of file
@@ -1,22 +1,25 @@
 package database
 
 import (
+	"errors"
+	"strings"
+
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-// NewConnection creates a new database connection
-func NewConnection(databaseURL string) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
-	if err != nil {
-		return nil, err
+// NewConnection creates a new database connection. verbose logs every SQL
+// statement; otherwise only slow queries and errors are logged.
+func NewConnection(databaseURL string, verbose bool) (*gorm.DB, error) {
+	logLevel := logger.Warn
+	if verbose {
+		logLevel = logger.Info
 	}
 
-	// Auto-migrate models
-	err = db.AutoMigrate(&User{})
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -24,6 +27,17 @@ func NewConnection(databaseURL string) (*gorm.DB, error) {
 	return db, nil
 }
 
+// Migrate brings the schema up to date with the models
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(Models()...)
+}
+
+// Models lists the models migrated into the database, which the schema
+// drift check compares it against
+func Models() []interface{} {
+	return []interface{}{&User{}, &Address{}, &ImpersonationSession{}, &DataExport{}, &DeletionRequest{}, &DeletionStep{}, &WebhookEndpoint{}, &WebhookDelivery{}, &WebhookEncryptionKey{}, &Organization{}, &Membership{}, &Invitation{}, &KnownDevice{}, &LoginEvent{}}
+}
+
 // User model
 type User struct {
 	ID        string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
@@ -33,6 +47,240 @@ type User struct {
 	FirstName string
 	LastName  string
 	Status    string `gorm:"default:active"`
+	Role      string `gorm:"default:user"`
+	AvatarURL string
+	CreatedAt int64  `gorm:"autoCreateTime"`
+	UpdatedAt int64  `gorm:"autoUpdateTime"`
+
+	// Admin account controls
+	SuspensionReason       string
+	SuspendedAt            int64
+	PasswordResetRequired  bool   `gorm:"default:false"`
+	PasswordResetTokenHash string `gorm:"index"`
+	PasswordResetExpiresAt int64
+
+	// Notification preferences
+	MarketingOptOut bool `gorm:"default:false"`
+	// Locale is a language tag such as "de-DE" and TimeZone an IANA time zone
+	// such as "Europe/Berlin"; notifications format dates with them
+	Locale   string `gorm:"size:35"`
+	TimeZone string `gorm:"size:64"`
+
+	// Version is incremented by every update and served as the user's ETag
+	Version int64 `gorm:"not null;default:1"`
+}
+
+// Address model
+type Address struct {
+	ID                string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID            string `gorm:"not null;index"`
+	Label             string
+	RecipientName     string `gorm:"not null"`
+	Line1             string `gorm:"not null"`
+	Line2             string
+	City              string `gorm:"not null"`
+	State             string
+	PostalCode        string `gorm:"not null"`
+	Country           string `gorm:"not null;size:2"`
+	Phone             string
+	IsDefaultShipping bool  `gorm:"default:false"`
+	IsDefaultBilling  bool  `gorm:"default:false"`
+	CreatedAt         int64 `gorm:"autoCreateTime"`
+	UpdatedAt         int64 `gorm:"autoUpdateTime"`
+}
+
+// BeforeSave normalizes and validates an address before it is written
+func (a *Address) BeforeSave(tx *gorm.DB) error {
+	a.Country = strings.ToUpper(strings.TrimSpace(a.Country))
+	a.PostalCode = strings.TrimSpace(a.PostalCode)
+
+	if a.UserID == "" {
+		return errors.New("address user ID is required")
+	}
+	if strings.TrimSpace(a.RecipientName) == "" {
+		return errors.New("address recipient name is required")
+	}
+	if strings.TrimSpace(a.Line1) == "" {
+		return errors.New("address line 1 is required")
+	}
+	if strings.TrimSpace(a.City) == "" {
+		return errors.New("address city is required")
+	}
+	if a.PostalCode == "" {
+		return errors.New("address postal code is required")
+	}
+	if len(a.Country) != 2 {
+		return errors.New("address country must be an ISO 3166-1 alpha-2 code")
+	}
+
+	return nil
+}
+
+// ImpersonationSession records an admin acting as another user
+type ImpersonationSession struct {
+	ID          string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	AdminUserID string `gorm:"not null;index"`
+	UserID      string `gorm:"not null;index"`
+	Reason      string `gorm:"type:text;not null"`
+	ExpiresAt   int64  `gorm:"not null"`
+	CreatedAt   int64  `gorm:"autoCreateTime"`
+}
+
+// DataExport records a GDPR data export requested by a user
+type DataExport struct {
+	ID        string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID    string `gorm:"not null;index"`
+	Format    string `gorm:"not null"`
+	Status    string `gorm:"default:pending"`
+	ObjectKey string
+	Error     string `gorm:"type:text"`
+	ExpiresAt int64
+	CreatedAt int64 `gorm:"autoCreateTime"`
+	UpdatedAt int64 `gorm:"autoUpdateTime"`
+
+	// OperationID is the operation that builds the export in the background
+	OperationID string `gorm:"index"`
+}
+
+// DeletionRequest tracks a right-to-be-forgotten deletion across services
+type DeletionRequest struct {
+	ID          string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID      string `gorm:"not null;index"`
+	RequestedBy string
+	Status      string         `gorm:"default:in_progress"`
+	Steps       []DeletionStep `gorm:"foreignKey:DeletionRequestID"`
+	// OperationID is the operation reporting the deletion's progress
+	OperationID string `gorm:"index"`
+	CompletedAt int64
+	CreatedAt   int64 `gorm:"autoCreateTime"`
+	UpdatedAt   int64 `gorm:"autoUpdateTime"`
+}
+
+// DeletionStep records the progress of one service within a deletion request
+type DeletionStep struct {
+	ID                string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	DeletionRequestID string `gorm:"type:uuid;not null;uniqueIndex:idx_deletion_step_service"`
+	Service           string `gorm:"not null;uniqueIndex:idx_deletion_step_service"`
+	Status            string `gorm:"default:pending"`
+	Error             string `gorm:"type:text"`
+	CompletedAt       int64
+	UpdatedAt         int64 `gorm:"autoUpdateTime"`
+}
+
+// WebhookEndpoint is a partner URL that receives signed event notifications.
+// PayloadFormat is "platform", "cloudevents" (structured) or
+// "cloudevents-binary".
+type WebhookEndpoint struct {
+	ID            string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID        string `gorm:"not null;index"`
+	URL           string `gorm:"not null"`
+	Secret        string `gorm:"not null"`
+	EventTypes    string `gorm:"type:text;not null"` // comma-separated
+	PayloadFormat string `gorm:"not null;default:'platform'"`
+	Active        bool   `gorm:"default:true"`
+	CreatedAt     int64  `gorm:"autoCreateTime"`
+	UpdatedAt     int64  `gorm:"autoUpdateTime"`
+}
+
+// WebhookEncryptionKey is a partner's RSA public key that webhook payloads to
+// an endpoint are encrypted to. The newest key of an endpoint is used; older
+// ones are kept until removed so that a rotation can be rolled back.
+type WebhookEncryptionKey struct {
+	ID         string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	EndpointID string `gorm:"type:uuid;not null;uniqueIndex:idx_webhook_key_endpoint_kid"`
+	KeyID      string `gorm:"not null;uniqueIndex:idx_webhook_key_endpoint_kid"`
+	PublicKey  string `gorm:"type:text;not null"` // PEM or JWK, as registered
+	CreatedAt  int64  `gorm:"autoCreateTime"`
+}
+
+// WebhookDelivery records one attempt sequence to deliver an event to an
+// endpoint. Replays are new deliveries that reference the original.
+type WebhookDelivery struct {
+	ID             string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	EndpointID     string `gorm:"type:uuid;not null;index"`
+	EventID        string `gorm:"not null;index"`
+	EventType      string `gorm:"not null"`
+	Payload        string `gorm:"type:text;not null"`
+	Status         string `gorm:"default:pending;index:idx_webhook_delivery_due"`
+	Attempts       int    `gorm:"default:0"`
+	NextAttemptAt  int64  `gorm:"index:idx_webhook_delivery_due"`
+	ResponseStatus int
+	LastError      string `gorm:"type:text"`
+	DeliveredAt    int64
+	ReplayOf       string
+	CreatedAt      int64 `gorm:"autoCreateTime"`
+	UpdatedAt      int64 `gorm:"autoUpdateTime"`
+}
+
+// Organization groups users so a B2B customer's team can share orders and
+// other org-scoped resources
+type Organization struct {
+	ID        string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Name      string `gorm:"not null"`
+	Slug      string `gorm:"unique;not null"`
+	CreatedBy string `gorm:"not null"`
 	CreatedAt int64  `gorm:"autoCreateTime"`
 	UpdatedAt int64  `gorm:"autoUpdateTime"`
-}
\ No newline at end of file
+}
+
+// Membership gives a user a role (owner or member) in an organization
+type Membership struct {
+	ID             string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID string `gorm:"type:uuid;not null;uniqueIndex:idx_membership_org_user"`
+	UserID         string `gorm:"not null;uniqueIndex:idx_membership_org_user;index"`
+	Role           string `gorm:"not null;default:member"`
+	CreatedAt      int64  `gorm:"autoCreateTime"`
+	UpdatedAt      int64  `gorm:"autoUpdateTime"`
+}
+
+// Invitation invites an email address to join an organization. Only the hash
+// of the invitation token is stored.
+type Invitation struct {
+	ID             string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID string `gorm:"type:uuid;not null;index"`
+	Email          string `gorm:"not null"`
+	Role           string `gorm:"not null;default:member"`
+	TokenHash      string `gorm:"unique;not null"`
+	InvitedBy      string `gorm:"not null"`
+	ExpiresAt      int64  `gorm:"not null"`
+	AcceptedAt     int64
+	AcceptedBy     string
+	CreatedAt      int64 `gorm:"autoCreateTime"`
+}
+
+// KnownDevice is a device a user has signed in from. Fingerprint hashes the
+// device ID the client sent, or its user agent if it sent none.
+type KnownDevice struct {
+	ID          string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID      string `gorm:"not null;uniqueIndex:idx_known_device_user_fingerprint"`
+	Fingerprint string `gorm:"not null;uniqueIndex:idx_known_device_user_fingerprint"`
+	UserAgent   string `gorm:"type:text"`
+	LastIP      string
+	FirstSeenAt int64 `gorm:"autoCreateTime"`
+	LastSeenAt  int64
+}
+
+// LoginEvent records a successful sign-in, where it came from and why it was
+// considered suspicious, if it was. Only the hash of the token of its "secure
+// my account" link is stored.
+type LoginEvent struct {
+	ID                string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID            string `gorm:"not null;index"`
+	DeviceFingerprint string `gorm:"not null"`
+	IPAddress         string
+	UserAgent         string `gorm:"type:text"`
+	// Location, if the IP address could be located
+	Located   bool `gorm:"default:false"`
+	Country   string
+	City      string
+	Latitude  float64
+	Longitude float64
+
+	NewDevice        bool `gorm:"default:false"`
+	ImpossibleTravel bool `gorm:"default:false"`
+
+	SecureTokenHash      string `gorm:"index"`
+	SecureTokenExpiresAt int64
+	SecuredAt            int64
+	CreatedAt            int64 `gorm:"autoCreateTime"`
+}
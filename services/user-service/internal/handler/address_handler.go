@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "microservices-platform/pkg/proto/user/v1"
+	"microservices-platform/services/user-service/internal/database"
+)
+
+// CreateAddress adds an address to a user's address book
+func (h *UserHandler) CreateAddress(ctx context.Context, req *pb.CreateAddressRequest) (*pb.CreateAddressResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.CreateAddress")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("user.id", req.UserId))
+
+	if req.Address == nil {
+		return nil, status.Error(codes.InvalidArgument, "address is required")
+	}
+
+	address := h.convertFromProtoAddress(req.Address)
+	address.ID = ""
+	address.UserID = req.UserId
+
+	created, err := h.addressService.CreateAddress(ctx, address)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to create address: %v", err)
+	}
+
+	return &pb.CreateAddressResponse{
+		Address: h.convertToProtoAddress(created),
+	}, nil
+}
+
+// GetAddress retrieves an address by ID
+func (h *UserHandler) GetAddress(ctx context.Context, req *pb.GetAddressRequest) (*pb.GetAddressResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.GetAddress")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("address.id", req.AddressId),
+	)
+
+	address, err := h.addressService.GetAddress(ctx, req.AddressId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.NotFound, "address not found: %v", err)
+	}
+	if address.UserID != req.UserId {
+		return nil, status.Error(codes.NotFound, "address not found")
+	}
+
+	return &pb.GetAddressResponse{
+		Address: h.convertToProtoAddress(address),
+	}, nil
+}
+
+// ListAddresses lists all addresses for a user
+func (h *UserHandler) ListAddresses(ctx context.Context, req *pb.ListAddressesRequest) (*pb.ListAddressesResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.ListAddresses")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("user.id", req.UserId))
+
+	addresses, err := h.addressService.ListAddresses(ctx, req.UserId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to list addresses: %v", err)
+	}
+
+	var protoAddresses []*pb.Address
+	for _, address := range addresses {
+		protoAddresses = append(protoAddresses, h.convertToProtoAddress(address))
+	}
+
+	return &pb.ListAddressesResponse{
+		Addresses: protoAddresses,
+	}, nil
+}
+
+// UpdateAddress updates an address
+func (h *UserHandler) UpdateAddress(ctx context.Context, req *pb.UpdateAddressRequest) (*pb.UpdateAddressResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.UpdateAddress")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("address.id", req.AddressId),
+	)
+
+	if req.Address == nil {
+		return nil, status.Error(codes.InvalidArgument, "address is required")
+	}
+
+	existing, err := h.addressService.GetAddress(ctx, req.AddressId)
+	if err != nil || existing.UserID != req.UserId {
+		return nil, status.Error(codes.NotFound, "address not found")
+	}
+
+	address := h.convertFromProtoAddress(req.Address)
+	address.ID = req.AddressId
+
+	updated, err := h.addressService.UpdateAddress(ctx, address)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to update address: %v", err)
+	}
+
+	return &pb.UpdateAddressResponse{
+		Address: h.convertToProtoAddress(updated),
+	}, nil
+}
+
+// DeleteAddress deletes an address
+func (h *UserHandler) DeleteAddress(ctx context.Context, req *pb.DeleteAddressRequest) (*pb.DeleteAddressResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.DeleteAddress")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("address.id", req.AddressId),
+	)
+
+	existing, err := h.addressService.GetAddress(ctx, req.AddressId)
+	if err != nil || existing.UserID != req.UserId {
+		return nil, status.Error(codes.NotFound, "address not found")
+	}
+
+	if err := h.addressService.DeleteAddress(ctx, req.AddressId); err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to delete address: %v", err)
+	}
+
+	return &pb.DeleteAddressResponse{
+		Success: true,
+	}, nil
+}
+
+// convertToProtoAddress converts database address to protobuf address
+func (h *UserHandler) convertToProtoAddress(address *database.Address) *pb.Address {
+	return &pb.Address{
+		AddressId:         address.ID,
+		UserId:            address.UserID,
+		Label:             address.Label,
+		RecipientName:     address.RecipientName,
+		Line1:             address.Line1,
+		Line2:             address.Line2,
+		City:              address.City,
+		State:             address.State,
+		PostalCode:        address.PostalCode,
+		Country:           address.Country,
+		Phone:             address.Phone,
+		IsDefaultShipping: address.IsDefaultShipping,
+		IsDefaultBilling:  address.IsDefaultBilling,
+		CreatedAt:         timestamppb.New(time.Unix(address.CreatedAt, 0)),
+		UpdatedAt:         timestamppb.New(time.Unix(address.UpdatedAt, 0)),
+	}
+}
+
+// convertFromProtoAddress converts protobuf address to database address
+func (h *UserHandler) convertFromProtoAddress(address *pb.Address) *database.Address {
+	return &database.Address{
+		ID:                address.AddressId,
+		UserID:            address.UserId,
+		Label:             address.Label,
+		RecipientName:     address.RecipientName,
+		Line1:             address.Line1,
+		Line2:             address.Line2,
+		City:              address.City,
+		State:             address.State,
+		PostalCode:        address.PostalCode,
+		Country:           address.Country,
+		Phone:             address.Phone,
+		IsDefaultShipping: address.IsDefaultShipping,
+		IsDefaultBilling:  address.IsDefaultBilling,
+	}
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "microservices-platform/pkg/proto/user/v1"
+	"microservices-platform/services/user-service/internal/repository"
+	"microservices-platform/services/user-service/internal/service"
+)
+
+// AdminListUsers lists users with status, role and search filters
+func (h *UserHandler) AdminListUsers(ctx context.Context, req *pb.AdminListUsersRequest) (*pb.ListUsersResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.AdminListUsers")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.Int64("pagination.page", int64(req.Page)),
+		attribute.Int64("pagination.page_size", int64(req.PageSize)),
+		attribute.String("filter.status", req.Status),
+		attribute.String("filter.role", req.Role),
+	)
+
+	users, total, err := h.adminService.ListUsers(ctx, int(req.Page), int(req.PageSize), repository.UserFilter{
+		Search: req.Search,
+		Status: req.Status,
+		Role:   req.Role,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to list users: %v", err)
+	}
+
+	var protoUsers []*pb.User
+	for _, user := range users {
+		protoUsers = append(protoUsers, h.convertToProtoUser(user))
+	}
+
+	return &pb.ListUsersResponse{
+		Users:      protoUsers,
+		TotalCount: int32(total),
+		Page:       req.Page,
+		PageSize:   req.PageSize,
+	}, nil
+}
+
+// SuspendUser blocks a user from signing in
+func (h *UserHandler) SuspendUser(ctx context.Context, req *pb.SuspendUserRequest) (*pb.SuspendUserResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.SuspendUser")
+	defer span.End()
+
+	adminID, err := callerAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("admin.id", adminID),
+		attribute.String("user.id", req.UserId),
+	)
+
+	user, err := h.adminService.SuspendUser(ctx, adminID, req.UserId, req.Reason)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to suspend user: %v", err)
+	}
+
+	return &pb.SuspendUserResponse{
+		User: h.convertToProtoUser(user),
+	}, nil
+}
+
+// UnsuspendUser restores a suspended user's access
+func (h *UserHandler) UnsuspendUser(ctx context.Context, req *pb.UnsuspendUserRequest) (*pb.UnsuspendUserResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.UnsuspendUser")
+	defer span.End()
+
+	adminID, err := callerAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("admin.id", adminID),
+		attribute.String("user.id", req.UserId),
+	)
+
+	user, err := h.adminService.UnsuspendUser(ctx, adminID, req.UserId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to unsuspend user: %v", err)
+	}
+
+	return &pb.UnsuspendUserResponse{
+		User: h.convertToProtoUser(user),
+	}, nil
+}
+
+// ForcePasswordReset requires a user to set a new password before signing in
+func (h *UserHandler) ForcePasswordReset(ctx context.Context, req *pb.ForcePasswordResetRequest) (*pb.ForcePasswordResetResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.ForcePasswordReset")
+	defer span.End()
+
+	adminID, err := callerAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("admin.id", adminID),
+		attribute.String("user.id", req.UserId),
+	)
+
+	user, err := h.adminService.ForcePasswordReset(ctx, adminID, req.UserId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to force password reset: %v", err)
+	}
+
+	return &pb.ForcePasswordResetResponse{
+		User:                h.convertToProtoUser(user),
+		ResetTokenExpiresAt: timestamppb.New(time.Unix(user.PasswordResetExpiresAt, 0)),
+	}, nil
+}
+
+// AssignRole changes a user's role
+func (h *UserHandler) AssignRole(ctx context.Context, req *pb.AssignRoleRequest) (*pb.AssignRoleResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.AssignRole")
+	defer span.End()
+
+	adminID, err := callerAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("admin.id", adminID),
+		attribute.String("user.id", req.UserId),
+		attribute.String("user.role", req.Role),
+	)
+
+	user, err := h.adminService.AssignRole(ctx, adminID, req.UserId, req.Role)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to assign role: %v", err)
+	}
+
+	return &pb.AssignRoleResponse{
+		User: h.convertToProtoUser(user),
+	}, nil
+}
+
+// ImportUsers imports users from a CSV or NDJSON file, reporting the outcome
+// of each row
+func (h *UserHandler) ImportUsers(ctx context.Context, req *pb.ImportUsersRequest) (*pb.ImportUsersResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.ImportUsers")
+	defer span.End()
+
+	adminID, err := callerAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("admin.id", adminID),
+		attribute.String("import.format", req.Format),
+		attribute.String("import.on_duplicate", req.OnDuplicate),
+		attribute.Bool("import.dry_run", req.DryRun),
+		attribute.Int("import.size_bytes", len(req.Data)),
+	)
+
+	results, err := h.importService.ImportUsers(ctx, adminID, req.Data, service.ImportOptions{
+		Format:      req.Format,
+		OnDuplicate: req.OnDuplicate,
+		DryRun:      req.DryRun,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to import users: %v", err)
+	}
+
+	resp := &pb.ImportUsersResponse{}
+	for _, result := range results {
+		resp.Results = append(resp.Results, &pb.ImportUserResult{
+			Row:    int32(result.Row),
+			Email:  result.Email,
+			UserId: result.UserID,
+			Status: result.Status,
+			Error:  result.Error,
+		})
+		switch result.Status {
+		case service.ImportCreated:
+			resp.CreatedCount++
+		case service.ImportUpdated:
+			resp.UpdatedCount++
+		case service.ImportSkipped:
+			resp.SkippedCount++
+		case service.ImportFailed:
+			resp.FailedCount++
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int64("import.created", int64(resp.CreatedCount)),
+		attribute.Int64("import.failed", int64(resp.FailedCount)),
+	)
+	return resp, nil
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"microservices-platform/pkg/middleware"
+)
+
+// callerIdentity returns the caller identity the gateway propagated. Calls
+// without one are refused, for operations that must know who is acting
+// rather than trust an ID in the request.
+func callerIdentity(ctx context.Context) (*middleware.Identity, error) {
+	identity, ok := middleware.IdentityFromContext(ctx)
+	if !ok || identity.UserID == "" {
+		return nil, status.Errorf(codes.Unauthenticated, "caller identity required")
+	}
+	return identity, nil
+}
+
+// checkCaller refuses calls without a caller identity and calls made on
+// behalf of another user. Admins may act for anyone.
+func checkCaller(ctx context.Context, userID string) error {
+	identity, err := callerIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	if identity.UserID != userID && !identity.HasRole("admin") {
+		return status.Errorf(codes.PermissionDenied, "cannot act on behalf of another user")
+	}
+	return nil
+}
+
+// callerAdmin returns the ID of the admin making the call, taken from the
+// caller identity rather than the request. Calls from anyone else, or made
+// while impersonating a user, are refused.
+func callerAdmin(ctx context.Context) (string, error) {
+	identity, err := callerIdentity(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !identity.HasRole("admin") || identity.ImpersonatorID != "" {
+		return "", status.Errorf(codes.PermissionDenied, "admin role required")
+	}
+	return identity.UserID, nil
+}
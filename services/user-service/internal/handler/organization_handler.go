@@ -0,0 +1,346 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"microservices-platform/pkg/middleware"
+	pb "microservices-platform/pkg/proto/user/v1"
+	"microservices-platform/services/user-service/internal/database"
+)
+
+// CreateOrganization creates an organization owned by the calling user
+func (h *UserHandler) CreateOrganization(ctx context.Context, req *pb.CreateOrganizationRequest) (*pb.CreateOrganizationResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.CreateOrganization")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("organization.slug", req.Slug),
+	)
+
+	if err := checkActingUser(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	organization, err := h.orgService.CreateOrganization(ctx, req.UserId, req.Name, req.Slug)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to create organization: %v", err)
+	}
+
+	return &pb.CreateOrganizationResponse{
+		Organization: h.convertToProtoOrganization(organization),
+	}, nil
+}
+
+// GetOrganization retrieves an organization the calling user belongs to
+func (h *UserHandler) GetOrganization(ctx context.Context, req *pb.GetOrganizationRequest) (*pb.GetOrganizationResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.GetOrganization")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("organization.id", req.OrganizationId),
+	)
+
+	if err := checkActingUser(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	organization, err := h.orgService.GetOrganization(ctx, req.UserId, req.OrganizationId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.NotFound, "failed to get organization: %v", err)
+	}
+
+	return &pb.GetOrganizationResponse{
+		Organization: h.convertToProtoOrganization(organization),
+	}, nil
+}
+
+// ListOrganizations lists the organizations a user belongs to
+func (h *UserHandler) ListOrganizations(ctx context.Context, req *pb.ListOrganizationsRequest) (*pb.ListOrganizationsResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.ListOrganizations")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.id", req.UserId))
+
+	if err := checkActingUser(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	organizations, err := h.orgService.ListOrganizations(ctx, req.UserId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to list organizations: %v", err)
+	}
+
+	var protoOrganizations []*pb.Organization
+	for _, organization := range organizations {
+		protoOrganizations = append(protoOrganizations, h.convertToProtoOrganization(organization))
+	}
+
+	return &pb.ListOrganizationsResponse{
+		Organizations: protoOrganizations,
+	}, nil
+}
+
+// ListOrganizationMembers lists an organization's members
+func (h *UserHandler) ListOrganizationMembers(ctx context.Context, req *pb.ListOrganizationMembersRequest) (*pb.ListOrganizationMembersResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.ListOrganizationMembers")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("organization.id", req.OrganizationId),
+	)
+
+	if err := checkActingUser(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	memberships, err := h.orgService.ListMembers(ctx, req.UserId, req.OrganizationId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.NotFound, "failed to list organization members: %v", err)
+	}
+
+	var protoMembers []*pb.Membership
+	for _, membership := range memberships {
+		protoMembers = append(protoMembers, h.convertToProtoMembership(membership))
+	}
+
+	return &pb.ListOrganizationMembersResponse{
+		Members: protoMembers,
+	}, nil
+}
+
+// UpdateOrganizationMember changes a member's role
+func (h *UserHandler) UpdateOrganizationMember(ctx context.Context, req *pb.UpdateOrganizationMemberRequest) (*pb.UpdateOrganizationMemberResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.UpdateOrganizationMember")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("organization.id", req.OrganizationId),
+		attribute.String("organization.member_user_id", req.MemberUserId),
+		attribute.String("organization.role", req.Role),
+	)
+
+	if err := checkActingUser(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	membership, err := h.orgService.UpdateMemberRole(ctx, req.UserId, req.OrganizationId, req.MemberUserId, req.Role)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to update organization member: %v", err)
+	}
+
+	return &pb.UpdateOrganizationMemberResponse{
+		Member: h.convertToProtoMembership(membership),
+	}, nil
+}
+
+// RemoveOrganizationMember removes a member from an organization
+func (h *UserHandler) RemoveOrganizationMember(ctx context.Context, req *pb.RemoveOrganizationMemberRequest) (*pb.RemoveOrganizationMemberResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.RemoveOrganizationMember")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("organization.id", req.OrganizationId),
+		attribute.String("organization.member_user_id", req.MemberUserId),
+	)
+
+	if err := checkActingUser(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	if err := h.orgService.RemoveMember(ctx, req.UserId, req.OrganizationId, req.MemberUserId); err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to remove organization member: %v", err)
+	}
+
+	return &pb.RemoveOrganizationMemberResponse{
+		Success: true,
+	}, nil
+}
+
+// CreateOrganizationInvitation invites an email address to an organization
+func (h *UserHandler) CreateOrganizationInvitation(ctx context.Context, req *pb.CreateOrganizationInvitationRequest) (*pb.CreateOrganizationInvitationResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.CreateOrganizationInvitation")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("organization.id", req.OrganizationId),
+		attribute.String("organization.role", req.Role),
+	)
+
+	if err := checkActingUser(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	invitation, token, err := h.orgService.InviteMember(ctx, req.UserId, req.OrganizationId, req.Email, req.Role)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to create invitation: %v", err)
+	}
+
+	return &pb.CreateOrganizationInvitationResponse{
+		Invitation: h.convertToProtoInvitation(invitation),
+		Token:      token,
+	}, nil
+}
+
+// ListOrganizationInvitations lists an organization's pending invitations
+func (h *UserHandler) ListOrganizationInvitations(ctx context.Context, req *pb.ListOrganizationInvitationsRequest) (*pb.ListOrganizationInvitationsResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.ListOrganizationInvitations")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("organization.id", req.OrganizationId),
+	)
+
+	if err := checkActingUser(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	invitations, err := h.orgService.ListInvitations(ctx, req.UserId, req.OrganizationId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to list invitations: %v", err)
+	}
+
+	var protoInvitations []*pb.Invitation
+	for _, invitation := range invitations {
+		protoInvitations = append(protoInvitations, h.convertToProtoInvitation(invitation))
+	}
+
+	return &pb.ListOrganizationInvitationsResponse{
+		Invitations: protoInvitations,
+	}, nil
+}
+
+// RevokeOrganizationInvitation revokes a pending invitation
+func (h *UserHandler) RevokeOrganizationInvitation(ctx context.Context, req *pb.RevokeOrganizationInvitationRequest) (*pb.RevokeOrganizationInvitationResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.RevokeOrganizationInvitation")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("organization.id", req.OrganizationId),
+		attribute.String("organization.invitation_id", req.InvitationId),
+	)
+
+	if err := checkActingUser(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	if err := h.orgService.RevokeInvitation(ctx, req.UserId, req.OrganizationId, req.InvitationId); err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to revoke invitation: %v", err)
+	}
+
+	return &pb.RevokeOrganizationInvitationResponse{
+		Success: true,
+	}, nil
+}
+
+// AcceptOrganizationInvitation adds the calling user to the inviting organization
+func (h *UserHandler) AcceptOrganizationInvitation(ctx context.Context, req *pb.AcceptOrganizationInvitationRequest) (*pb.AcceptOrganizationInvitationResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.AcceptOrganizationInvitation")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.id", req.UserId))
+
+	if err := checkActingUser(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	membership, err := h.orgService.AcceptInvitation(ctx, req.UserId, req.Token)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to accept invitation: %v", err)
+	}
+
+	return &pb.AcceptOrganizationInvitationResponse{
+		Membership: h.convertToProtoMembership(membership),
+	}, nil
+}
+
+// GetOrganizationMembership returns a user's membership in an organization
+func (h *UserHandler) GetOrganizationMembership(ctx context.Context, req *pb.GetOrganizationMembershipRequest) (*pb.GetOrganizationMembershipResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.GetOrganizationMembership")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("organization.id", req.OrganizationId),
+	)
+
+	membership, err := h.orgService.GetMembership(ctx, req.OrganizationId, req.UserId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to get organization membership: %v", err)
+	}
+
+	response := &pb.GetOrganizationMembershipResponse{}
+	if membership != nil {
+		response.Membership = h.convertToProtoMembership(membership)
+	}
+	return response, nil
+}
+
+// checkActingUser rejects requests made on behalf of another user when the
+// gateway propagated a caller identity. Admins may act for anyone.
+func checkActingUser(ctx context.Context, userID string) error {
+	identity, ok := middleware.IdentityFromContext(ctx)
+	if !ok || identity.UserID == userID || identity.HasRole("admin") {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "cannot act on behalf of another user")
+}
+
+// convertToProtoOrganization converts a database organization to protobuf
+func (h *UserHandler) convertToProtoOrganization(organization *database.Organization) *pb.Organization {
+	return &pb.Organization{
+		OrganizationId: organization.ID,
+		Name:           organization.Name,
+		Slug:           organization.Slug,
+		CreatedBy:      organization.CreatedBy,
+		CreatedAt:      timestamppb.New(time.Unix(organization.CreatedAt, 0)),
+	}
+}
+
+// convertToProtoMembership converts a database membership to protobuf
+func (h *UserHandler) convertToProtoMembership(membership *database.Membership) *pb.Membership {
+	return &pb.Membership{
+		OrganizationId: membership.OrganizationID,
+		UserId:         membership.UserID,
+		Role:           membership.Role,
+		CreatedAt:      timestamppb.New(time.Unix(membership.CreatedAt, 0)),
+	}
+}
+
+// convertToProtoInvitation converts a database invitation to protobuf,
+// leaving out the token hash
+func (h *UserHandler) convertToProtoInvitation(invitation *database.Invitation) *pb.Invitation {
+	return &pb.Invitation{
+		InvitationId:   invitation.ID,
+		OrganizationId: invitation.OrganizationID,
+		Email:          invitation.Email,
+		Role:           invitation.Role,
+		InvitedBy:      invitation.InvitedBy,
+		ExpiresAt:      timestamppb.New(time.Unix(invitation.ExpiresAt, 0)),
+		CreatedAt:      timestamppb.New(time.Unix(invitation.CreatedAt, 0)),
+	}
+}
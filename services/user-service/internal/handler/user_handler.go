@@ -2,16 +2,29 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
 	"strconv"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"microservices-platform/pkg/i18n"
+	"microservices-platform/pkg/middleware"
+	"microservices-platform/pkg/operations"
+	"microservices-platform/pkg/passwordpolicy"
+	"microservices-platform/pkg/versioning"
+	"microservices-platform/services/user-service/internal/database"
 	"microservices-platform/services/user-service/internal/service"
 	pb "microservices-platform/pkg/proto/user/v1"
 )
@@ -19,15 +32,31 @@ import (
 // UserHandler implements the gRPC UserService
 type UserHandler struct {
 	pb.UnimplementedUserServiceServer
-	userService service.UserService
-	tracer      trace.Tracer
+	userService     service.UserService
+	addressService  service.AddressService
+	exportService   service.ExportService
+	deletionService service.DeletionService
+	adminService    service.AdminService
+	webhookService  service.WebhookService
+	orgService      service.OrganizationService
+	loginService    service.LoginService
+	importService   service.ImportService
+	tracer          trace.Tracer
 }
 
 // NewUserHandler creates a new UserHandler
-func NewUserHandler(userService service.UserService) *UserHandler {
+func NewUserHandler(userService service.UserService, addressService service.AddressService, exportService service.ExportService, deletionService service.DeletionService, adminService service.AdminService, webhookService service.WebhookService, orgService service.OrganizationService, loginService service.LoginService, importService service.ImportService) *UserHandler {
 	return &UserHandler{
-		userService: userService,
-		tracer:      otel.Tracer("user-service"),
+		userService:     userService,
+		addressService:  addressService,
+		exportService:   exportService,
+		deletionService: deletionService,
+		adminService:    adminService,
+		webhookService:  webhookService,
+		orgService:      orgService,
+		loginService:    loginService,
+		importService:   importService,
+		tracer:          otel.Tracer("user-service"),
 	}
 }
 
@@ -44,6 +73,10 @@ func (h *UserHandler) CreateUser(ctx context.Context, req *pb.CreateUserRequest)
 	user, err := h.userService.CreateUser(ctx, req.Email, req.Username, req.Password, req.FirstName, req.LastName)
 	if err != nil {
 		span.RecordError(err)
+		var violation *passwordpolicy.ViolationError
+		if errors.As(err, &violation) {
+			return nil, violation.GRPCStatus().Err()
+		}
 		return nil, status.Errorf(codes.Internal, "failed to create user: %v", err)
 	}
 
@@ -64,6 +97,7 @@ func (h *UserHandler) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.
 		span.RecordError(err)
 		return nil, status.Errorf(codes.NotFound, "user not found: %v", err)
 	}
+	setETag(ctx, user.Version)
 
 	return &pb.GetUserResponse{
 		User: h.convertToProtoUser(user),
@@ -80,6 +114,43 @@ func (h *UserHandler) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest)
 		attribute.String("user.email", req.Email),
 	)
 
+	match, err := versioning.IfMatch(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid If-Match header: %v", err)
+	}
+
+	if err := checkActingUser(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+	update, err := userUpdate(req)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid update: %v", err)
+	}
+	// Users cannot lift their own suspension; only admins change a status
+	if identity, ok := middleware.IdentityFromContext(ctx); ok && update.Status != nil && !identity.HasRole("admin") {
+		return nil, status.Errorf(codes.PermissionDenied, "only admins can change a user's status")
+	}
+
+	user, err := h.userService.UpdateUser(ctx, req.UserId, update, match)
+	if errors.Is(err, versioning.ErrConflict) {
+		return nil, versioning.PreconditionFailed(ctx, "user")
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to update user: %v", err)
+	}
+	setETag(ctx, user.Version)
+
+	return &pb.UpdateUserResponse{
+		User: h.convertToProtoUser(user),
+	}, nil
+}
+
+// userUpdate selects the fields an UpdateUser request changes: the fields in
+// its update mask, as sent by PATCH, or else every field that is set
+func userUpdate(req *pb.UpdateUserRequest) (service.UserUpdate, error) {
+	var update service.UserUpdate
+
 	statusStr := ""
 	switch req.Status {
 	case pb.UserStatus_USER_STATUS_ACTIVE:
@@ -90,32 +161,149 @@ func (h *UserHandler) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest)
 		statusStr = "suspended"
 	}
 
-	user, err := h.userService.UpdateUser(ctx, req.UserId, req.Email, req.Username, req.FirstName, req.LastName, statusStr)
-	if err != nil {
-		span.RecordError(err)
-		return nil, status.Errorf(codes.Internal, "failed to update user: %v", err)
+	if len(req.GetUpdateMask().GetPaths()) == 0 {
+		if req.Email != "" {
+			update.Email = &req.Email
+		}
+		if req.Username != "" {
+			update.Username = &req.Username
+		}
+		if req.FirstName != "" {
+			update.FirstName = &req.FirstName
+		}
+		if req.LastName != "" {
+			update.LastName = &req.LastName
+		}
+		if statusStr != "" {
+			update.Status = &statusStr
+		}
+		update.MarketingOptOut = req.MarketingOptOut
+		if req.Locale != "" {
+			update.Locale = &req.Locale
+		}
+		if req.TimeZone != "" {
+			update.TimeZone = &req.TimeZone
+		}
+		return update, validPreferences(update)
 	}
 
-	return &pb.UpdateUserResponse{
-		User: h.convertToProtoUser(user),
-	}, nil
+	for _, path := range req.UpdateMask.Paths {
+		switch path {
+		case "email":
+			if req.Email == "" {
+				return update, errors.New("email cannot be empty")
+			}
+			update.Email = &req.Email
+		case "username":
+			if req.Username == "" {
+				return update, errors.New("username cannot be empty")
+			}
+			update.Username = &req.Username
+		case "first_name":
+			update.FirstName = &req.FirstName
+		case "last_name":
+			update.LastName = &req.LastName
+		case "status":
+			if statusStr == "" {
+				return update, errors.New("status must be active, inactive or suspended")
+			}
+			update.Status = &statusStr
+		case "marketing_opt_out":
+			optOut := req.GetMarketingOptOut()
+			update.MarketingOptOut = &optOut
+		case "locale":
+			update.Locale = &req.Locale
+		case "time_zone":
+			update.TimeZone = &req.TimeZone
+		default:
+			return update, fmt.Errorf("field %q cannot be updated", path)
+		}
+	}
+	return update, validPreferences(update)
+}
+
+// validPreferences checks the locale and time zone of an update. An empty
+// one clears the preference, so dates fall back to English and UTC.
+func validPreferences(update service.UserUpdate) error {
+	if update.Locale != nil && *update.Locale != "" && !i18n.ValidLocale(*update.Locale) {
+		return fmt.Errorf("locale %q is not a language tag", *update.Locale)
+	}
+	if update.TimeZone != nil && !i18n.ValidTimeZone(*update.TimeZone) {
+		return fmt.Errorf("time zone %q is not an IANA time zone", *update.TimeZone)
+	}
+	return nil
 }
 
-// DeleteUser deletes a user
+// DeleteUser starts the right-to-be-forgotten deletion of a user across all services
 func (h *UserHandler) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
 	ctx, span := h.tracer.Start(ctx, "UserHandler.DeleteUser")
 	defer span.End()
 
 	span.SetAttributes(attribute.String("user.id", req.UserId))
 
-	err := h.userService.DeleteUser(ctx, req.UserId)
+	match, err := versioning.IfMatch(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid If-Match header: %v", err)
+	}
+
+	request, err := h.deletionService.RequestDeletion(ctx, req.UserId, req.RequestedBy, match)
+	if errors.Is(err, versioning.ErrConflict) {
+		return nil, versioning.PreconditionFailed(ctx, "user")
+	}
 	if err != nil {
 		span.RecordError(err)
 		return nil, status.Errorf(codes.Internal, "failed to delete user: %v", err)
 	}
 
+	if request.OperationID != "" {
+		setOperationHeader(ctx, request.OperationID)
+	}
+
 	return &pb.DeleteUserResponse{
-		Success: true,
+		Success:     true,
+		DeletionId:  request.ID,
+		Status:      request.Status,
+		OperationId: request.OperationID,
+	}, nil
+}
+
+// GetDeletionStatus returns the progress of a user's deletion across services
+func (h *UserHandler) GetDeletionStatus(ctx context.Context, req *pb.GetDeletionStatusRequest) (*pb.GetDeletionStatusResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.GetDeletionStatus")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.id", req.UserId))
+
+	request, err := h.deletionService.GetDeletionStatus(ctx, req.UserId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.NotFound, "failed to get deletion status: %v", err)
+	}
+
+	deletion := &pb.DeletionStatus{
+		DeletionId:  request.ID,
+		UserId:      request.UserID,
+		RequestedBy: request.RequestedBy,
+		Status:      request.Status,
+		CreatedAt:   timestamppb.New(time.Unix(request.CreatedAt, 0)),
+	}
+	if request.CompletedAt > 0 {
+		deletion.CompletedAt = timestamppb.New(time.Unix(request.CompletedAt, 0))
+	}
+	for _, step := range request.Steps {
+		protoStep := &pb.DeletionStep{
+			Service: step.Service,
+			Status:  step.Status,
+			Error:   step.Error,
+		}
+		if step.CompletedAt > 0 {
+			protoStep.CompletedAt = timestamppb.New(time.Unix(step.CompletedAt, 0))
+		}
+		deletion.Steps = append(deletion.Steps, protoStep)
+	}
+
+	return &pb.GetDeletionStatusResponse{
+		Deletion: deletion,
 	}, nil
 }
 
@@ -148,6 +336,32 @@ func (h *UserHandler) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (
 	}, nil
 }
 
+// StreamUsers streams the users matching a filter without buffering them in
+// one response
+func (h *UserHandler) StreamUsers(req *pb.StreamUsersRequest, stream pb.UserService_StreamUsersServer) error {
+	ctx, span := h.tracer.Start(stream.Context(), "UserHandler.StreamUsers")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int64("stream.batch_size", int64(req.BatchSize)))
+
+	sent := 0
+	err := h.userService.StreamUsers(ctx, req.Filter, int(req.BatchSize), func(users []*database.User) error {
+		for _, user := range users {
+			if err := stream.Send(h.convertToProtoUser(user)); err != nil {
+				return err
+			}
+		}
+		sent += len(users)
+		return nil
+	})
+	span.SetAttributes(attribute.Int("users.sent", sent))
+	if err != nil {
+		span.RecordError(err)
+		return status.Errorf(codes.Internal, "failed to stream users: %v", err)
+	}
+	return nil
+}
+
 // AuthenticateUser authenticates a user
 func (h *UserHandler) AuthenticateUser(ctx context.Context, req *pb.AuthenticateUserRequest) (*pb.AuthenticateUserResponse, error) {
 	ctx, span := h.tracer.Start(ctx, "UserHandler.AuthenticateUser")
@@ -161,6 +375,13 @@ func (h *UserHandler) AuthenticateUser(ctx context.Context, req *pb.Authenticate
 		return nil, status.Errorf(codes.Unauthenticated, "authentication failed: %v", err)
 	}
 
+	// Sign-in history is best effort; failing to record it never blocks a
+	// sign-in
+	if _, err := h.loginService.RecordLogin(ctx, user, loginClient(ctx, req.DeviceId)); err != nil {
+		span.RecordError(err)
+		log.Printf("Failed to record sign-in of user %s: %v", user.ID, err)
+	}
+
 	return &pb.AuthenticateUserResponse{
 		AccessToken: token,
 		User:        h.convertToProtoUser(user),
@@ -168,6 +389,166 @@ func (h *UserHandler) AuthenticateUser(ctx context.Context, req *pb.Authenticate
 	}, nil
 }
 
+// ResetPassword sets a new password using a reset token
+func (h *UserHandler) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*pb.ResetPasswordResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.ResetPassword")
+	defer span.End()
+
+	if err := h.userService.ResetPassword(ctx, req.Token, req.NewPassword); err != nil {
+		span.RecordError(err)
+		var violation *passwordpolicy.ViolationError
+		if errors.As(err, &violation) {
+			return nil, violation.GRPCStatus().Err()
+		}
+		return nil, status.Errorf(codes.InvalidArgument, "failed to reset password: %v", err)
+	}
+
+	return &pb.ResetPasswordResponse{
+		Success: true,
+	}, nil
+}
+
+// SecureAccount acts on the "secure my account" link of a suspicious sign-in alert
+func (h *UserHandler) SecureAccount(ctx context.Context, req *pb.SecureAccountRequest) (*pb.SecureAccountResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.SecureAccount")
+	defer span.End()
+
+	if err := h.loginService.SecureAccount(ctx, req.Token); err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to secure account: %v", err)
+	}
+
+	return &pb.SecureAccountResponse{
+		Success: true,
+	}, nil
+}
+
+// loginClient describes the client of a sign-in. Through the gateway, the
+// first X-Forwarded-For address is the client address the gateway saw and
+// the user agent arrives as grpcgateway-user-agent; direct gRPC callers are
+// described by their connection.
+func loginClient(ctx context.Context, deviceID string) service.LoginClient {
+	client := service.LoginClient{DeviceID: deviceID}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-forwarded-for"); len(values) > 0 {
+			client.IPAddress = strings.TrimSpace(strings.Split(values[0], ",")[0])
+		}
+		if values := md.Get("grpcgateway-user-agent"); len(values) > 0 {
+			client.UserAgent = values[0]
+		} else if values := md.Get("user-agent"); len(values) > 0 {
+			client.UserAgent = values[0]
+		}
+	}
+	if client.IPAddress == "" {
+		if p, ok := peer.FromContext(ctx); ok {
+			if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+				client.IPAddress = host
+			}
+		}
+	}
+	return client
+}
+
+// UploadAvatar uploads and resizes a user's avatar
+func (h *UserHandler) UploadAvatar(ctx context.Context, req *pb.UploadAvatarRequest) (*pb.UploadAvatarResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.UploadAvatar")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.Int("avatar.size_bytes", len(req.ImageData)),
+		attribute.String("avatar.content_type", req.ContentType),
+	)
+
+	user, urls, err := h.userService.UploadAvatar(ctx, req.UserId, req.ImageData, req.ContentType)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to upload avatar: %v", err)
+	}
+
+	return &pb.UploadAvatarResponse{
+		User:       h.convertToProtoUser(user),
+		AvatarUrls: urls,
+	}, nil
+}
+
+// ExportUserData starts packaging a user's data and returns the operation
+// that reports the download link. Without operation tracking the export is
+// built before responding and the link returned directly.
+func (h *UserHandler) ExportUserData(ctx context.Context, req *pb.ExportUserDataRequest) (*pb.ExportUserDataResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.ExportUserData")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("export.format", req.Format),
+	)
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	export, op, err := h.exportService.StartExport(ctx, req.UserId, req.Format)
+	if err == nil {
+		setOperationHeader(ctx, op.ID)
+		return &pb.ExportUserDataResponse{
+			ExportId:    export.ID,
+			OperationId: op.ID,
+		}, nil
+	}
+	if !errors.Is(err, operations.ErrUnavailable) {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to export user data: %v", err)
+	}
+
+	export, downloadURL, err := h.exportService.ExportUserData(ctx, req.UserId, req.Format)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to export user data: %v", err)
+	}
+
+	return &pb.ExportUserDataResponse{
+		ExportId:    export.ID,
+		DownloadUrl: downloadURL,
+		ExpiresAt:   timestamppb.New(time.Unix(export.ExpiresAt, 0)),
+	}, nil
+}
+
+// ImpersonateUser issues a scoped, short-lived token for an admin to act as a user
+func (h *UserHandler) ImpersonateUser(ctx context.Context, req *pb.ImpersonateUserRequest) (*pb.ImpersonateUserResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.ImpersonateUser")
+	defer span.End()
+
+	adminID, err := callerAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("admin.id", adminID),
+		attribute.String("user.id", req.UserId),
+	)
+
+	ttl := time.Duration(req.TtlSeconds) * time.Second
+	user, token, session, err := h.userService.ImpersonateUser(ctx, adminID, req.UserId, req.Reason, ttl)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.PermissionDenied, "failed to impersonate user: %v", err)
+	}
+
+	return &pb.ImpersonateUserResponse{
+		AccessToken: token,
+		ExpiresIn:   session.ExpiresAt - time.Now().Unix(),
+		User:        h.convertToProtoUser(user),
+		SessionId:   session.ID,
+	}, nil
+}
+
 // convertToProtoUser converts database user to protobuf user
 func (h *UserHandler) convertToProtoUser(user *service.User) *pb.User {
 	var status pb.UserStatus
@@ -182,14 +563,45 @@ func (h *UserHandler) convertToProtoUser(user *service.User) *pb.User {
 		status = pb.UserStatus_USER_STATUS_UNSPECIFIED
 	}
 
-	return &pb.User{
+	protoUser := &pb.User{
 		UserId:    user.ID,
 		Email:     user.Email,
 		Username:  user.Username,
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
 		Status:    status,
+		AvatarUrl: user.AvatarURL,
+		Role:      user.Role,
 		CreatedAt: timestamppb.New(time.Unix(user.CreatedAt, 0)),
 		UpdatedAt: timestamppb.New(time.Unix(user.UpdatedAt, 0)),
+		Version:   user.Version,
+
+		PasswordResetRequired: user.PasswordResetRequired,
+		SuspensionReason:      user.SuspensionReason,
+		MarketingOptOut:       user.MarketingOptOut,
+		Locale:                user.Locale,
+		TimeZone:              user.TimeZone,
 	}
-}
\ No newline at end of file
+	if user.SuspendedAt > 0 {
+		protoUser.SuspendedAt = timestamppb.New(time.Unix(user.SuspendedAt, 0))
+	}
+
+	return protoUser
+}
+
+// setOperationHeader names the operation a call started so the gateway
+// answers 202 Accepted. The operation ID is also in the response body, so a
+// failure is only logged.
+func setOperationHeader(ctx context.Context, operationID string) {
+	if err := operations.SetHeader(ctx, operationID); err != nil {
+		log.Printf("Failed to set operation header: %v", err)
+	}
+}
+
+// setETag returns a user's version as the ETag of the response. Clients
+// without it can only make unconditional writes, so a failure is only logged.
+func setETag(ctx context.Context, version int64) {
+	if err := versioning.SetETag(ctx, version); err != nil {
+		log.Printf("Failed to set ETag header: %v", err)
+	}
+}
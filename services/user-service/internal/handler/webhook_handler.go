@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"microservices-platform/pkg/jwe"
+	pb "microservices-platform/pkg/proto/user/v1"
+	"microservices-platform/services/user-service/internal/database"
+	"microservices-platform/services/user-service/internal/service"
+)
+
+// CreateWebhookEndpoint registers a partner webhook endpoint
+func (h *UserHandler) CreateWebhookEndpoint(ctx context.Context, req *pb.CreateWebhookEndpointRequest) (*pb.CreateWebhookEndpointResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.CreateWebhookEndpoint")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.StringSlice("webhook.event_types", req.EventTypes),
+		attribute.String("webhook.payload_format", req.PayloadFormat),
+	)
+
+	endpoint, err := h.webhookService.CreateEndpoint(ctx, req.UserId, req.Url, req.EventTypes, req.PayloadFormat)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to create webhook endpoint: %v", err)
+	}
+
+	protoEndpoint := h.convertToProtoWebhookEndpoint(endpoint)
+	protoEndpoint.Secret = endpoint.Secret
+
+	return &pb.CreateWebhookEndpointResponse{
+		Endpoint: protoEndpoint,
+	}, nil
+}
+
+// ListWebhookEndpoints lists a partner's webhook endpoints
+func (h *UserHandler) ListWebhookEndpoints(ctx context.Context, req *pb.ListWebhookEndpointsRequest) (*pb.ListWebhookEndpointsResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.ListWebhookEndpoints")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("user.id", req.UserId))
+
+	endpoints, err := h.webhookService.ListEndpoints(ctx, req.UserId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to list webhook endpoints: %v", err)
+	}
+
+	var protoEndpoints []*pb.WebhookEndpoint
+	for _, endpoint := range endpoints {
+		protoEndpoints = append(protoEndpoints, h.convertToProtoWebhookEndpoint(endpoint))
+	}
+
+	return &pb.ListWebhookEndpointsResponse{
+		Endpoints: protoEndpoints,
+	}, nil
+}
+
+// DeleteWebhookEndpoint deletes a webhook endpoint
+func (h *UserHandler) DeleteWebhookEndpoint(ctx context.Context, req *pb.DeleteWebhookEndpointRequest) (*pb.DeleteWebhookEndpointResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.DeleteWebhookEndpoint")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("webhook.endpoint_id", req.EndpointId),
+	)
+
+	if err := h.webhookService.DeleteEndpoint(ctx, req.UserId, req.EndpointId); err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.NotFound, "failed to delete webhook endpoint: %v", err)
+	}
+
+	return &pb.DeleteWebhookEndpointResponse{
+		Success: true,
+	}, nil
+}
+
+// ListWebhookDeliveries lists the delivery log of a webhook endpoint
+func (h *UserHandler) ListWebhookDeliveries(ctx context.Context, req *pb.ListWebhookDeliveriesRequest) (*pb.ListWebhookDeliveriesResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.ListWebhookDeliveries")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("webhook.endpoint_id", req.EndpointId),
+	)
+
+	deliveries, total, err := h.webhookService.ListDeliveries(ctx, req.UserId, req.EndpointId, int(req.Page), int(req.PageSize))
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.NotFound, "failed to list webhook deliveries: %v", err)
+	}
+
+	var protoDeliveries []*pb.WebhookDelivery
+	for _, delivery := range deliveries {
+		protoDeliveries = append(protoDeliveries, h.convertToProtoWebhookDelivery(delivery))
+	}
+
+	return &pb.ListWebhookDeliveriesResponse{
+		Deliveries: protoDeliveries,
+		TotalCount: int32(total),
+		Page:       req.Page,
+		PageSize:   req.PageSize,
+	}, nil
+}
+
+// ReplayWebhookDelivery delivers a past webhook again
+func (h *UserHandler) ReplayWebhookDelivery(ctx context.Context, req *pb.ReplayWebhookDeliveryRequest) (*pb.ReplayWebhookDeliveryResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.ReplayWebhookDelivery")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("webhook.endpoint_id", req.EndpointId),
+		attribute.String("webhook.delivery_id", req.DeliveryId),
+	)
+
+	delivery, err := h.webhookService.ReplayDelivery(ctx, req.UserId, req.EndpointId, req.DeliveryId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.NotFound, "failed to replay webhook delivery: %v", err)
+	}
+
+	return &pb.ReplayWebhookDeliveryResponse{
+		Delivery: h.convertToProtoWebhookDelivery(delivery),
+	}, nil
+}
+
+// AddWebhookEncryptionKey registers a public key to encrypt an endpoint's
+// payloads to
+func (h *UserHandler) AddWebhookEncryptionKey(ctx context.Context, req *pb.AddWebhookEncryptionKeyRequest) (*pb.AddWebhookEncryptionKeyResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.AddWebhookEncryptionKey")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("webhook.endpoint_id", req.EndpointId),
+	)
+
+	key, err := h.webhookService.AddEncryptionKey(ctx, req.UserId, req.EndpointId, req.PublicKey, req.KeyId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to add webhook encryption key: %v", err)
+	}
+
+	return &pb.AddWebhookEncryptionKeyResponse{
+		Key: h.convertToProtoWebhookEncryptionKey(key, true),
+	}, nil
+}
+
+// ListWebhookEncryptionKeys lists an endpoint's encryption keys
+func (h *UserHandler) ListWebhookEncryptionKeys(ctx context.Context, req *pb.ListWebhookEncryptionKeysRequest) (*pb.ListWebhookEncryptionKeysResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.ListWebhookEncryptionKeys")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("webhook.endpoint_id", req.EndpointId),
+	)
+
+	keys, err := h.webhookService.ListEncryptionKeys(ctx, req.UserId, req.EndpointId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.NotFound, "failed to list webhook encryption keys: %v", err)
+	}
+
+	var protoKeys []*pb.WebhookEncryptionKey
+	for i, key := range keys {
+		protoKeys = append(protoKeys, h.convertToProtoWebhookEncryptionKey(key, i == 0))
+	}
+
+	return &pb.ListWebhookEncryptionKeysResponse{
+		Keys: protoKeys,
+	}, nil
+}
+
+// DeleteWebhookEncryptionKey removes an endpoint's encryption key
+func (h *UserHandler) DeleteWebhookEncryptionKey(ctx context.Context, req *pb.DeleteWebhookEncryptionKeyRequest) (*pb.DeleteWebhookEncryptionKeyResponse, error) {
+	ctx, span := h.tracer.Start(ctx, "UserHandler.DeleteWebhookEncryptionKey")
+	defer span.End()
+
+	if err := checkCaller(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", req.UserId),
+		attribute.String("webhook.endpoint_id", req.EndpointId),
+		attribute.String("webhook.key_id", req.KeyId),
+	)
+
+	if err := h.webhookService.DeleteEncryptionKey(ctx, req.UserId, req.EndpointId, req.KeyId); err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.NotFound, "failed to delete webhook encryption key: %v", err)
+	}
+
+	return &pb.DeleteWebhookEncryptionKeyResponse{
+		Success: true,
+	}, nil
+}
+
+// convertToProtoWebhookEndpoint converts a database webhook endpoint to
+// protobuf, leaving out the signing secret
+func (h *UserHandler) convertToProtoWebhookEndpoint(endpoint *database.WebhookEndpoint) *pb.WebhookEndpoint {
+	return &pb.WebhookEndpoint{
+		EndpointId:    endpoint.ID,
+		UserId:        endpoint.UserID,
+		Url:           endpoint.URL,
+		EventTypes:    strings.Split(endpoint.EventTypes, ","),
+		PayloadFormat: endpoint.PayloadFormat,
+		Active:        endpoint.Active,
+		CreatedAt:     timestamppb.New(time.Unix(endpoint.CreatedAt, 0)),
+	}
+}
+
+// convertToProtoWebhookDelivery converts a database webhook delivery to protobuf
+func (h *UserHandler) convertToProtoWebhookDelivery(delivery *database.WebhookDelivery) *pb.WebhookDelivery {
+	protoDelivery := &pb.WebhookDelivery{
+		DeliveryId:     delivery.ID,
+		EndpointId:     delivery.EndpointID,
+		EventId:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       int32(delivery.Attempts),
+		ResponseStatus: int32(delivery.ResponseStatus),
+		LastError:      delivery.LastError,
+		CreatedAt:      timestamppb.New(time.Unix(delivery.CreatedAt, 0)),
+		ReplayOf:       delivery.ReplayOf,
+	}
+	if delivery.Status == service.WebhookDeliveryPending {
+		protoDelivery.NextAttemptAt = timestamppb.New(time.Unix(delivery.NextAttemptAt, 0))
+	}
+	if delivery.DeliveredAt > 0 {
+		protoDelivery.DeliveredAt = timestamppb.New(time.Unix(delivery.DeliveredAt, 0))
+	}
+	return protoDelivery
+}
+
+// convertToProtoWebhookEncryptionKey converts a database webhook encryption
+// key to protobuf
+func (h *UserHandler) convertToProtoWebhookEncryptionKey(key *database.WebhookEncryptionKey, current bool) *pb.WebhookEncryptionKey {
+	return &pb.WebhookEncryptionKey{
+		KeyId:      key.KeyID,
+		EndpointId: key.EndpointID,
+		PublicKey:  key.PublicKey,
+		Algorithm:  jwe.KeyAlgorithm + "/" + jwe.ContentAlgorithm,
+		Current:    current,
+		CreatedAt:  timestamppb.New(time.Unix(key.CreatedAt, 0)),
+	}
+}
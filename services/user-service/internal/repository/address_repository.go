@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"microservices-platform/services/user-service/internal/database"
+)
+
+// AddressRepository interface defines address data operations
+type AddressRepository interface {
+	Create(ctx context.Context, address *database.Address) error
+	GetByID(ctx context.Context, id string) (*database.Address, error)
+	ListByUserID(ctx context.Context, userID string) ([]*database.Address, error)
+	Update(ctx context.Context, address *database.Address) error
+	Delete(ctx context.Context, id string) error
+	DeleteByUserID(ctx context.Context, userID string) error
+}
+
+// addressRepository implements AddressRepository interface
+type addressRepository struct {
+	db *gorm.DB
+}
+
+// NewAddressRepository creates a new address repository
+func NewAddressRepository(db *gorm.DB) AddressRepository {
+	return &addressRepository{
+		db: db,
+	}
+}
+
+// Create creates a new address. If it is a default address, the user's other
+// addresses stop being the default for its purposes in the same transaction.
+func (r *addressRepository) Create(ctx context.Context, address *database.Address) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := clearDefaults(tx, address); err != nil {
+			return err
+		}
+		return tx.Create(address).Error
+	})
+}
+
+// GetByID retrieves an address by ID
+func (r *addressRepository) GetByID(ctx context.Context, id string) (*database.Address, error) {
+	var address database.Address
+	err := r.db.WithContext(ctx).First(&address, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &address, nil
+}
+
+// ListByUserID lists all addresses belonging to a user, defaults first
+func (r *addressRepository) ListByUserID(ctx context.Context, userID string) ([]*database.Address, error) {
+	var addresses []*database.Address
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("is_default_shipping DESC, is_default_billing DESC, created_at ASC").
+		Find(&addresses).Error
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// Update updates an address. Like Create, it keeps at most one default
+// address per purpose.
+func (r *addressRepository) Update(ctx context.Context, address *database.Address) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := clearDefaults(tx, address); err != nil {
+			return err
+		}
+		return tx.Save(address).Error
+	})
+}
+
+// Delete deletes an address
+func (r *addressRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&database.Address{}, "id = ?", id).Error
+}
+
+// clearDefaults unsets the default shipping and/or billing flag on all of the
+// user's addresses, for the purposes address is the default for
+func clearDefaults(tx *gorm.DB, address *database.Address) error {
+	updates := map[string]interface{}{}
+	if address.IsDefaultShipping {
+		updates["is_default_shipping"] = false
+	}
+	if address.IsDefaultBilling {
+		updates["is_default_billing"] = false
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	return tx.Model(&database.Address{}).Where("user_id = ?", address.UserID).Updates(updates).Error
+}
+
+// DeleteByUserID deletes all addresses belonging to a user
+func (r *addressRepository) DeleteByUserID(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Delete(&database.Address{}, "user_id = ?", userID).Error
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"microservices-platform/services/user-service/internal/database"
+)
+
+// DeletionRepository interface defines user deletion tracking operations
+type DeletionRepository interface {
+	Create(ctx context.Context, request *database.DeletionRequest) error
+	GetByID(ctx context.Context, id string) (*database.DeletionRequest, error)
+	GetLatestByUserID(ctx context.Context, userID string) (*database.DeletionRequest, error)
+	UpdateStatus(ctx context.Context, id, status string) error
+	SetOperationID(ctx context.Context, id, operationID string) error
+	MarkCompleted(ctx context.Context, id string, completedAt int64) (bool, error)
+	UpdateStep(ctx context.Context, step *database.DeletionStep) error
+}
+
+// deletionRepository implements DeletionRepository interface
+type deletionRepository struct {
+	db *gorm.DB
+}
+
+// NewDeletionRepository creates a new deletion repository
+func NewDeletionRepository(db *gorm.DB) DeletionRepository {
+	return &deletionRepository{
+		db: db,
+	}
+}
+
+// Create creates a deletion request together with its steps
+func (r *deletionRepository) Create(ctx context.Context, request *database.DeletionRequest) error {
+	return r.db.WithContext(ctx).Create(request).Error
+}
+
+// GetByID retrieves a deletion request and its steps by ID
+func (r *deletionRepository) GetByID(ctx context.Context, id string) (*database.DeletionRequest, error) {
+	var request database.DeletionRequest
+	err := r.db.WithContext(ctx).Preload("Steps").First(&request, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetLatestByUserID retrieves the most recent deletion request for a user
+func (r *deletionRepository) GetLatestByUserID(ctx context.Context, userID string) (*database.DeletionRequest, error) {
+	var request database.DeletionRequest
+	err := r.db.WithContext(ctx).
+		Preload("Steps").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		First(&request).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// UpdateStatus updates the overall status of a deletion request
+func (r *deletionRepository) UpdateStatus(ctx context.Context, id, status string) error {
+	return r.db.WithContext(ctx).Model(&database.DeletionRequest{}).
+		Where("id = ?", id).
+		Update("status", status).Error
+}
+
+// SetOperationID links a deletion request to the operation reporting its progress
+func (r *deletionRepository) SetOperationID(ctx context.Context, id, operationID string) error {
+	return r.db.WithContext(ctx).Model(&database.DeletionRequest{}).
+		Where("id = ?", id).
+		Update("operation_id", operationID).Error
+}
+
+// MarkCompleted marks a deletion request completed. It reports false if the
+// request was already completed, so completion is only acted on once.
+func (r *deletionRepository) MarkCompleted(ctx context.Context, id string, completedAt int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&database.DeletionRequest{}).
+		Where("id = ? AND status <> ?", id, "completed").
+		Updates(map[string]interface{}{"status": "completed", "completed_at": completedAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UpdateStep records the outcome reported by a service for a deletion request
+func (r *deletionRepository) UpdateStep(ctx context.Context, step *database.DeletionStep) error {
+	return r.db.WithContext(ctx).Model(&database.DeletionStep{}).
+		Where("deletion_request_id = ? AND service = ?", step.DeletionRequestID, step.Service).
+		Updates(map[string]interface{}{
+			"status":       step.Status,
+			"error":        step.Error,
+			"completed_at": step.CompletedAt,
+		}).Error
+}
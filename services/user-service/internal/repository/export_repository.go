@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"microservices-platform/services/user-service/internal/database"
+)
+
+// ExportRepository interface defines data export record operations
+type ExportRepository interface {
+	Create(ctx context.Context, export *database.DataExport) error
+	GetByID(ctx context.Context, id string) (*database.DataExport, error)
+	Update(ctx context.Context, export *database.DataExport) error
+	ListByUserID(ctx context.Context, userID string) ([]*database.DataExport, error)
+}
+
+// exportRepository implements ExportRepository interface
+type exportRepository struct {
+	db *gorm.DB
+}
+
+// NewExportRepository creates a new data export repository
+func NewExportRepository(db *gorm.DB) ExportRepository {
+	return &exportRepository{
+		db: db,
+	}
+}
+
+// Create creates a new data export record
+func (r *exportRepository) Create(ctx context.Context, export *database.DataExport) error {
+	return r.db.WithContext(ctx).Create(export).Error
+}
+
+// GetByID retrieves a data export record by ID
+func (r *exportRepository) GetByID(ctx context.Context, id string) (*database.DataExport, error) {
+	var export database.DataExport
+	err := r.db.WithContext(ctx).First(&export, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &export, nil
+}
+
+// Update updates a data export record
+func (r *exportRepository) Update(ctx context.Context, export *database.DataExport) error {
+	return r.db.WithContext(ctx).Save(export).Error
+}
+
+// ListByUserID lists all data exports for a user
+func (r *exportRepository) ListByUserID(ctx context.Context, userID string) ([]*database.DataExport, error) {
+	var exports []*database.DataExport
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&exports).Error
+	if err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
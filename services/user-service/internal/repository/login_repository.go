@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"microservices-platform/services/user-service/internal/database"
+)
+
+// LoginRepository interface defines sign-in history and known device data operations
+type LoginRepository interface {
+	GetDevice(ctx context.Context, userID, fingerprint string) (*database.KnownDevice, error)
+	CountDevices(ctx context.Context, userID string) (int64, error)
+	// SaveDevice records a sign-in from a device, adding it if it is new
+	SaveDevice(ctx context.Context, device *database.KnownDevice) error
+	DeleteDevice(ctx context.Context, userID, fingerprint string) error
+	CreateEvent(ctx context.Context, event *database.LoginEvent) error
+	// LastLocatedEvent returns the user's latest sign-in whose location is known
+	LastLocatedEvent(ctx context.Context, userID string) (*database.LoginEvent, error)
+	GetEventBySecureToken(ctx context.Context, tokenHash string) (*database.LoginEvent, error)
+	UpdateEvent(ctx context.Context, event *database.LoginEvent) error
+	DeleteByUserID(ctx context.Context, userID string) error
+}
+
+// loginRepository implements LoginRepository interface
+type loginRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginRepository creates a new login repository
+func NewLoginRepository(db *gorm.DB) LoginRepository {
+	return &loginRepository{
+		db: db,
+	}
+}
+
+// GetDevice retrieves a user's known device by fingerprint
+func (r *loginRepository) GetDevice(ctx context.Context, userID, fingerprint string) (*database.KnownDevice, error) {
+	var device database.KnownDevice
+	err := r.db.WithContext(ctx).Where("user_id = ? AND fingerprint = ?", userID, fingerprint).First(&device).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &device, nil
+}
+
+// CountDevices counts a user's known devices
+func (r *loginRepository) CountDevices(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&database.KnownDevice{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// SaveDevice inserts a device or, if the user already has it, updates when
+// and from where it was last seen
+func (r *loginRepository) SaveDevice(ctx context.Context, device *database.KnownDevice) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "fingerprint"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_agent", "last_ip", "last_seen_at"}),
+	}).Create(device).Error
+}
+
+// DeleteDevice forgets a user's device, so the next sign-in from it is new
+func (r *loginRepository) DeleteDevice(ctx context.Context, userID, fingerprint string) error {
+	return r.db.WithContext(ctx).Delete(&database.KnownDevice{}, "user_id = ? AND fingerprint = ?", userID, fingerprint).Error
+}
+
+// CreateEvent records a sign-in
+func (r *loginRepository) CreateEvent(ctx context.Context, event *database.LoginEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+// LastLocatedEvent retrieves the latest located sign-in of a user
+func (r *loginRepository) LastLocatedEvent(ctx context.Context, userID string) (*database.LoginEvent, error) {
+	var event database.LoginEvent
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND located = ?", userID, true).
+		Order("created_at DESC").
+		First(&event).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+// GetEventBySecureToken retrieves the sign-in a "secure my account" token was issued for
+func (r *loginRepository) GetEventBySecureToken(ctx context.Context, tokenHash string) (*database.LoginEvent, error) {
+	var event database.LoginEvent
+	err := r.db.WithContext(ctx).Where("secure_token_hash = ?", tokenHash).First(&event).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+// UpdateEvent updates a recorded sign-in
+func (r *loginRepository) UpdateEvent(ctx context.Context, event *database.LoginEvent) error {
+	return r.db.WithContext(ctx).Save(event).Error
+}
+
+// DeleteByUserID deletes a user's sign-in history and known devices
+func (r *loginRepository) DeleteByUserID(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&database.LoginEvent{}, "user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&database.KnownDevice{}, "user_id = ?", userID).Error
+	})
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"microservices-platform/services/user-service/internal/database"
+)
+
+// OrganizationRepository interface defines organization, membership and
+// invitation data operations
+type OrganizationRepository interface {
+	// Create creates an organization together with its first membership
+	Create(ctx context.Context, organization *database.Organization, owner *database.Membership) error
+	GetByID(ctx context.Context, id string) (*database.Organization, error)
+	GetBySlug(ctx context.Context, slug string) (*database.Organization, error)
+	ListByUserID(ctx context.Context, userID string) ([]*database.Organization, error)
+
+	GetMembership(ctx context.Context, organizationID, userID string) (*database.Membership, error)
+	ListMemberships(ctx context.Context, organizationID string) ([]*database.Membership, error)
+	CountMembershipsByRole(ctx context.Context, organizationID, role string) (int64, error)
+	CreateMembership(ctx context.Context, membership *database.Membership) error
+	UpdateMembership(ctx context.Context, membership *database.Membership) error
+	DeleteMembership(ctx context.Context, organizationID, userID string) error
+	DeleteMembershipsByUserID(ctx context.Context, userID string) error
+
+	CreateInvitation(ctx context.Context, invitation *database.Invitation) error
+	GetInvitationByID(ctx context.Context, id string) (*database.Invitation, error)
+	GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*database.Invitation, error)
+	ListPendingInvitations(ctx context.Context, organizationID string, now int64) ([]*database.Invitation, error)
+	// AcceptInvitation marks an invitation accepted and creates the membership
+	// in one transaction. It fails if the invitation was already accepted.
+	AcceptInvitation(ctx context.Context, invitation *database.Invitation, membership *database.Membership) error
+	DeleteInvitation(ctx context.Context, id string) error
+}
+
+// organizationRepository implements OrganizationRepository interface
+type organizationRepository struct {
+	db *gorm.DB
+}
+
+// NewOrganizationRepository creates a new organization repository
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	return &organizationRepository{
+		db: db,
+	}
+}
+
+// Create creates an organization together with its first membership
+func (r *organizationRepository) Create(ctx context.Context, organization *database.Organization, owner *database.Membership) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+		owner.OrganizationID = organization.ID
+		return tx.Create(owner).Error
+	})
+}
+
+// GetByID retrieves an organization by ID
+func (r *organizationRepository) GetByID(ctx context.Context, id string) (*database.Organization, error) {
+	var organization database.Organization
+	err := r.db.WithContext(ctx).First(&organization, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &organization, nil
+}
+
+// GetBySlug retrieves an organization by slug
+func (r *organizationRepository) GetBySlug(ctx context.Context, slug string) (*database.Organization, error) {
+	var organization database.Organization
+	err := r.db.WithContext(ctx).First(&organization, "slug = ?", slug).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &organization, nil
+}
+
+// ListByUserID lists the organizations a user is a member of
+func (r *organizationRepository) ListByUserID(ctx context.Context, userID string) ([]*database.Organization, error) {
+	var organizations []*database.Organization
+	err := r.db.WithContext(ctx).
+		Joins("JOIN memberships ON memberships.organization_id = organizations.id").
+		Where("memberships.user_id = ?", userID).
+		Order("organizations.name ASC").
+		Find(&organizations).Error
+	if err != nil {
+		return nil, err
+	}
+	return organizations, nil
+}
+
+// GetMembership retrieves a user's membership in an organization
+func (r *organizationRepository) GetMembership(ctx context.Context, organizationID, userID string) (*database.Membership, error) {
+	var membership database.Membership
+	err := r.db.WithContext(ctx).First(&membership, "organization_id = ? AND user_id = ?", organizationID, userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// ListMemberships lists an organization's members, owners first
+func (r *organizationRepository) ListMemberships(ctx context.Context, organizationID string) ([]*database.Membership, error) {
+	var memberships []*database.Membership
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ?", organizationID).
+		Order("role = 'owner' DESC, created_at ASC").
+		Find(&memberships).Error
+	if err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// CountMembershipsByRole counts an organization's members with a role
+func (r *organizationRepository) CountMembershipsByRole(ctx context.Context, organizationID, role string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&database.Membership{}).
+		Where("organization_id = ? AND role = ?", organizationID, role).
+		Count(&count).Error
+	return count, err
+}
+
+// CreateMembership adds a user to an organization
+func (r *organizationRepository) CreateMembership(ctx context.Context, membership *database.Membership) error {
+	return r.db.WithContext(ctx).Create(membership).Error
+}
+
+// UpdateMembership updates a membership
+func (r *organizationRepository) UpdateMembership(ctx context.Context, membership *database.Membership) error {
+	return r.db.WithContext(ctx).Save(membership).Error
+}
+
+// DeleteMembership removes a user from an organization
+func (r *organizationRepository) DeleteMembership(ctx context.Context, organizationID, userID string) error {
+	return r.db.WithContext(ctx).Delete(&database.Membership{}, "organization_id = ? AND user_id = ?", organizationID, userID).Error
+}
+
+// DeleteMembershipsByUserID removes a user from every organization
+func (r *organizationRepository) DeleteMembershipsByUserID(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Delete(&database.Membership{}, "user_id = ?", userID).Error
+}
+
+// CreateInvitation creates an invitation
+func (r *organizationRepository) CreateInvitation(ctx context.Context, invitation *database.Invitation) error {
+	return r.db.WithContext(ctx).Create(invitation).Error
+}
+
+// GetInvitationByID retrieves an invitation by ID
+func (r *organizationRepository) GetInvitationByID(ctx context.Context, id string) (*database.Invitation, error) {
+	var invitation database.Invitation
+	err := r.db.WithContext(ctx).First(&invitation, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// GetInvitationByTokenHash retrieves an invitation by the hash of its token
+func (r *organizationRepository) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*database.Invitation, error) {
+	var invitation database.Invitation
+	err := r.db.WithContext(ctx).First(&invitation, "token_hash = ?", tokenHash).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// ListPendingInvitations lists an organization's unaccepted, unexpired invitations
+func (r *organizationRepository) ListPendingInvitations(ctx context.Context, organizationID string, now int64) ([]*database.Invitation, error) {
+	var invitations []*database.Invitation
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND accepted_at = 0 AND expires_at > ?", organizationID, now).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	if err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// AcceptInvitation marks an invitation accepted and creates the membership
+func (r *organizationRepository) AcceptInvitation(ctx context.Context, invitation *database.Invitation, membership *database.Membership) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&database.Invitation{}).
+			Where("id = ? AND accepted_at = 0", invitation.ID).
+			Updates(map[string]interface{}{
+				"accepted_at": invitation.AcceptedAt,
+				"accepted_by": invitation.AcceptedBy,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("invitation already accepted")
+		}
+		return tx.Create(membership).Error
+	})
+}
+
+// DeleteInvitation deletes an invitation
+func (r *organizationRepository) DeleteInvitation(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&database.Invitation{}, "id = ?", id).Error
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 
 	"gorm.io/gorm"
+	"microservices-platform/pkg/versioning"
 	"microservices-platform/services/user-service/internal/database"
 )
 
@@ -14,8 +15,22 @@ type UserRepository interface {
 	GetByID(ctx context.Context, id string) (*database.User, error)
 	GetByEmail(ctx context.Context, email string) (*database.User, error)
 	Update(ctx context.Context, user *database.User) error
+	// UpdatePasswordHash replaces a user's password hash if it is still
+	// oldHash, without changing the user's version
+	UpdatePasswordHash(ctx context.Context, id, oldHash, newHash string) error
 	Delete(ctx context.Context, id string) error
 	List(ctx context.Context, offset, limit int, filter string) ([]*database.User, int64, error)
+	ListFiltered(ctx context.Context, offset, limit int, filter UserFilter) ([]*database.User, int64, error)
+	Stream(ctx context.Context, filter UserFilter, batchSize int, fn func([]*database.User) error) error
+	GetByPasswordResetToken(ctx context.Context, tokenHash string) (*database.User, error)
+	CreateImpersonationSession(ctx context.Context, session *database.ImpersonationSession) error
+}
+
+// UserFilter narrows user listings. Empty fields are not applied.
+type UserFilter struct {
+	Search string
+	Status string
+	Role   string
 }
 
 // userRepository implements UserRepository interface
@@ -61,9 +76,28 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*databas
 	return &user, nil
 }
 
-// Update updates a user
+// Update updates a user and increments its version. It returns
+// versioning.ErrConflict if the user was updated since it was read.
 func (r *userRepository) Update(ctx context.Context, user *database.User) error {
-	return r.db.WithContext(ctx).Save(user).Error
+	version := user.Version
+	user.Version++
+	result := r.db.WithContext(ctx).Model(user).Where("version = ?", version).Select("*").Updates(user)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = versioning.ErrConflict
+	}
+	if result.Error != nil {
+		user.Version = version
+	}
+	return result.Error
+}
+
+// UpdatePasswordHash rehashes a user's unchanged password. It is a storage
+// detail, so unlike Update it leaves the version, and so the ETag, alone; a
+// hash that changed in the meantime is left as it is.
+func (r *userRepository) UpdatePasswordHash(ctx context.Context, id, oldHash, newHash string) error {
+	return r.db.WithContext(ctx).Model(&database.User{}).
+		Where("id = ? AND password = ?", id, oldHash).
+		Update("password", newHash).Error
 }
 
 // Delete deletes a user
@@ -73,15 +107,16 @@ func (r *userRepository) Delete(ctx context.Context, id string) error {
 
 // List lists users with pagination and filtering
 func (r *userRepository) List(ctx context.Context, offset, limit int, filter string) ([]*database.User, int64, error) {
+	return r.ListFiltered(ctx, offset, limit, UserFilter{Search: filter})
+}
+
+// ListFiltered lists users with pagination, a free-text search and exact
+// status and role filters
+func (r *userRepository) ListFiltered(ctx context.Context, offset, limit int, filter UserFilter) ([]*database.User, int64, error) {
 	var users []*database.User
 	var total int64
 
-	query := r.db.WithContext(ctx).Model(&database.User{})
-	
-	if filter != "" {
-		query = query.Where("email ILIKE ? OR username ILIKE ? OR first_name ILIKE ? OR last_name ILIKE ?",
-			"%"+filter+"%", "%"+filter+"%", "%"+filter+"%", "%"+filter+"%")
-	}
+	query := r.filtered(ctx, filter)
 
 	// Get total count
 	if err := query.Count(&total).Error; err != nil {
@@ -94,4 +129,50 @@ func (r *userRepository) List(ctx context.Context, offset, limit int, filter str
 	}
 
 	return users, total, nil
-}
\ No newline at end of file
+}
+
+// Stream passes the users matching a filter to fn in batches of batchSize,
+// ordered by ID, so only one batch is held in memory. The batch slice is
+// reused, fn must not keep it.
+func (r *userRepository) Stream(ctx context.Context, filter UserFilter, batchSize int, fn func([]*database.User) error) error {
+	var users []*database.User
+	return r.filtered(ctx, filter).FindInBatches(&users, batchSize, func(tx *gorm.DB, batch int) error {
+		return fn(users)
+	}).Error
+}
+
+// filtered returns a user query applying a filter
+func (r *userRepository) filtered(ctx context.Context, filter UserFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&database.User{})
+
+	if filter.Search != "" {
+		search := "%" + filter.Search + "%"
+		query = query.Where("email ILIKE ? OR username ILIKE ? OR first_name ILIKE ? OR last_name ILIKE ?",
+			search, search, search, search)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	return query
+}
+
+// GetByPasswordResetToken retrieves the user holding a password reset token
+func (r *userRepository) GetByPasswordResetToken(ctx context.Context, tokenHash string) (*database.User, error) {
+	var user database.User
+	err := r.db.WithContext(ctx).First(&user, "password_reset_token_hash = ?", tokenHash).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateImpersonationSession records the start of an impersonation session
+func (r *userRepository) CreateImpersonationSession(ctx context.Context, session *database.ImpersonationSession) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
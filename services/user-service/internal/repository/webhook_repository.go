@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"microservices-platform/services/user-service/internal/database"
+)
+
+// WebhookRepository interface defines webhook endpoint and delivery data operations
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint *database.WebhookEndpoint) error
+	GetEndpoint(ctx context.Context, id string) (*database.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, userID string) ([]*database.WebhookEndpoint, error)
+	ListActiveEndpoints(ctx context.Context) ([]*database.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, id string) error
+	DeleteEndpointsByUserID(ctx context.Context, userID string) error
+	CreateDeliveries(ctx context.Context, deliveries []*database.WebhookDelivery) error
+	GetDelivery(ctx context.Context, id string) (*database.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, endpointID string, offset, limit int) ([]*database.WebhookDelivery, int64, error)
+	ClaimDueDeliveries(ctx context.Context, now, leaseUntil int64, limit int) ([]*database.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *database.WebhookDelivery) error
+	CreateEncryptionKey(ctx context.Context, key *database.WebhookEncryptionKey) error
+	ListEncryptionKeys(ctx context.Context, endpointID string) ([]*database.WebhookEncryptionKey, error)
+	GetCurrentEncryptionKey(ctx context.Context, endpointID string) (*database.WebhookEncryptionKey, error)
+	DeleteEncryptionKey(ctx context.Context, endpointID, keyID string) (bool, error)
+}
+
+// webhookRepository implements WebhookRepository interface
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{
+		db: db,
+	}
+}
+
+// CreateEndpoint creates a new webhook endpoint
+func (r *webhookRepository) CreateEndpoint(ctx context.Context, endpoint *database.WebhookEndpoint) error {
+	return r.db.WithContext(ctx).Create(endpoint).Error
+}
+
+// GetEndpoint retrieves a webhook endpoint by ID
+func (r *webhookRepository) GetEndpoint(ctx context.Context, id string) (*database.WebhookEndpoint, error) {
+	var endpoint database.WebhookEndpoint
+	err := r.db.WithContext(ctx).First(&endpoint, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// ListEndpoints lists a user's webhook endpoints, oldest first
+func (r *webhookRepository) ListEndpoints(ctx context.Context, userID string) ([]*database.WebhookEndpoint, error) {
+	var endpoints []*database.WebhookEndpoint
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&endpoints).Error
+	return endpoints, err
+}
+
+// ListActiveEndpoints lists every active webhook endpoint
+func (r *webhookRepository) ListActiveEndpoints(ctx context.Context) ([]*database.WebhookEndpoint, error) {
+	var endpoints []*database.WebhookEndpoint
+	err := r.db.WithContext(ctx).Where("active = ?", true).Find(&endpoints).Error
+	return endpoints, err
+}
+
+// DeleteEndpoint deletes a webhook endpoint, its delivery log and its
+// encryption keys
+func (r *webhookRepository) DeleteEndpoint(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("endpoint_id = ?", id).Delete(&database.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("endpoint_id = ?", id).Delete(&database.WebhookEncryptionKey{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&database.WebhookEndpoint{}, "id = ?", id).Error
+	})
+}
+
+// DeleteEndpointsByUserID deletes all of a user's webhook endpoints, their
+// delivery logs and their encryption keys
+func (r *webhookRepository) DeleteEndpointsByUserID(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		endpointIDs := tx.Model(&database.WebhookEndpoint{}).Select("id").Where("user_id = ?", userID)
+		if err := tx.Where("endpoint_id IN (?)", endpointIDs).Delete(&database.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("endpoint_id IN (?)", endpointIDs).Delete(&database.WebhookEncryptionKey{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&database.WebhookEndpoint{}).Error
+	})
+}
+
+// CreateDeliveries queues deliveries
+func (r *webhookRepository) CreateDeliveries(ctx context.Context, deliveries []*database.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(&deliveries).Error
+}
+
+// GetDelivery retrieves a delivery by ID
+func (r *webhookRepository) GetDelivery(ctx context.Context, id string) (*database.WebhookDelivery, error) {
+	var delivery database.WebhookDelivery
+	err := r.db.WithContext(ctx).First(&delivery, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ListDeliveries lists an endpoint's deliveries, newest first
+func (r *webhookRepository) ListDeliveries(ctx context.Context, endpointID string, offset, limit int) ([]*database.WebhookDelivery, int64, error) {
+	var deliveries []*database.WebhookDelivery
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&database.WebhookDelivery{}).Where("endpoint_id = ?", endpointID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+// ClaimDueDeliveries claims up to limit pending deliveries that are due. Their
+// next attempt is pushed to leaseUntil so that other instances skip them
+// while this one delivers; locked rows are skipped rather than waited on.
+func (r *webhookRepository) ClaimDueDeliveries(ctx context.Context, now, leaseUntil int64, limit int) ([]*database.WebhookDelivery, error) {
+	var deliveries []*database.WebhookDelivery
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", "pending", now).
+			Order("next_attempt_at ASC").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&database.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", leaseUntil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery saves the outcome of a delivery attempt
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, delivery *database.WebhookDelivery) error {
+	return r.db.WithContext(ctx).Save(delivery).Error
+}
+
+// CreateEncryptionKey adds an encryption key to an endpoint
+func (r *webhookRepository) CreateEncryptionKey(ctx context.Context, key *database.WebhookEncryptionKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+// ListEncryptionKeys lists an endpoint's encryption keys, newest first
+func (r *webhookRepository) ListEncryptionKeys(ctx context.Context, endpointID string) ([]*database.WebhookEncryptionKey, error) {
+	var keys []*database.WebhookEncryptionKey
+	err := r.db.WithContext(ctx).Where("endpoint_id = ?", endpointID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// GetCurrentEncryptionKey retrieves the newest encryption key of an endpoint,
+// or nil if it has none
+func (r *webhookRepository) GetCurrentEncryptionKey(ctx context.Context, endpointID string) (*database.WebhookEncryptionKey, error) {
+	var key database.WebhookEncryptionKey
+	err := r.db.WithContext(ctx).Where("endpoint_id = ?", endpointID).Order("created_at DESC").First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// DeleteEncryptionKey deletes an endpoint's encryption key, reporting whether
+// it existed
+func (r *webhookRepository) DeleteEncryptionKey(ctx context.Context, endpointID, keyID string) (bool, error) {
+	result := r.db.WithContext(ctx).Where("endpoint_id = ? AND key_id = ?", endpointID, keyID).Delete(&database.WebhookEncryptionKey{})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"microservices-platform/services/user-service/internal/database"
+	"microservices-platform/services/user-service/internal/repository"
+)
+
+// AddressService interface defines address business logic operations
+type AddressService interface {
+	CreateAddress(ctx context.Context, address *database.Address) (*database.Address, error)
+	GetAddress(ctx context.Context, id string) (*database.Address, error)
+	ListAddresses(ctx context.Context, userID string) ([]*database.Address, error)
+	UpdateAddress(ctx context.Context, address *database.Address) (*database.Address, error)
+	DeleteAddress(ctx context.Context, id string) error
+}
+
+// addressService implements AddressService interface
+type addressService struct {
+	addressRepo repository.AddressRepository
+	userRepo    repository.UserRepository
+}
+
+// NewAddressService creates a new address service
+func NewAddressService(addressRepo repository.AddressRepository, userRepo repository.UserRepository) AddressService {
+	return &addressService{
+		addressRepo: addressRepo,
+		userRepo:    userRepo,
+	}
+}
+
+// CreateAddress adds an address to a user's address book
+func (s *addressService) CreateAddress(ctx context.Context, address *database.Address) (*database.Address, error) {
+	user, err := s.userRepo.GetByID(ctx, address.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	existing, err := s.addressRepo.ListByUserID(ctx, address.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first address a user saves becomes their default for both purposes
+	if len(existing) == 0 {
+		address.IsDefaultShipping = true
+		address.IsDefaultBilling = true
+	}
+
+	if err := s.addressRepo.Create(ctx, address); err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+// GetAddress retrieves an address by ID
+func (s *addressService) GetAddress(ctx context.Context, id string) (*database.Address, error) {
+	address, err := s.addressRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if address == nil {
+		return nil, errors.New("address not found")
+	}
+
+	return address, nil
+}
+
+// ListAddresses lists all addresses for a user
+func (s *addressService) ListAddresses(ctx context.Context, userID string) ([]*database.Address, error) {
+	return s.addressRepo.ListByUserID(ctx, userID)
+}
+
+// UpdateAddress updates an existing address, keeping at most one default per purpose
+func (s *addressService) UpdateAddress(ctx context.Context, address *database.Address) (*database.Address, error) {
+	existing, err := s.addressRepo.GetByID(ctx, address.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, errors.New("address not found")
+	}
+
+	// Ownership cannot be changed through an update
+	address.UserID = existing.UserID
+	address.CreatedAt = existing.CreatedAt
+
+	if err := s.addressRepo.Update(ctx, address); err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+// DeleteAddress deletes an address
+func (s *addressService) DeleteAddress(ctx context.Context, id string) error {
+	address, err := s.addressRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if address == nil {
+		return errors.New("address not found")
+	}
+
+	return s.addressRepo.Delete(ctx, id)
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/revocation"
+	"microservices-platform/services/user-service/internal/database"
+	"microservices-platform/services/user-service/internal/repository"
+)
+
+// validRoles lists the roles that can be assigned to users
+var validRoles = map[string]bool{
+	RoleUser:    true,
+	RolePartner: true,
+	RoleAdmin:   true,
+}
+
+// AdminService interface defines admin user management operations
+type AdminService interface {
+	ListUsers(ctx context.Context, page, pageSize int, filter repository.UserFilter) ([]*database.User, int64, error)
+	SuspendUser(ctx context.Context, adminID, userID, reason string) (*database.User, error)
+	UnsuspendUser(ctx context.Context, adminID, userID string) (*database.User, error)
+	ForcePasswordReset(ctx context.Context, adminID, userID string) (*database.User, error)
+	AssignRole(ctx context.Context, adminID, userID, role string) (*database.User, error)
+}
+
+// adminService implements AdminService interface
+type adminService struct {
+	userRepo      repository.UserRepository
+	eventBus      events.EventBus
+	revocations   revocation.Store
+	tokenLifetime time.Duration
+}
+
+// NewAdminService creates a new admin service. eventBus may be nil, in which
+// case forced password resets are rejected since the reset link cannot be
+// delivered. Suspending a user revokes their tokens in revocations, kept for
+// tokenLifetime; with nil revocations their tokens work until they expire.
+func NewAdminService(userRepo repository.UserRepository, eventBus events.EventBus, revocations revocation.Store, tokenLifetime time.Duration) AdminService {
+	if tokenLifetime < MaxImpersonationTTL {
+		tokenLifetime = MaxImpersonationTTL
+	}
+	return &adminService{
+		userRepo:      userRepo,
+		eventBus:      eventBus,
+		revocations:   revocations,
+		tokenLifetime: tokenLifetime,
+	}
+}
+
+// ListUsers lists users with pagination and status, role and search filters
+func (s *adminService) ListUsers(ctx context.Context, page, pageSize int, filter repository.UserFilter) ([]*database.User, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	users, total, err := s.userRepo.ListFiltered(ctx, (page-1)*pageSize, pageSize, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Don't return password hashes
+	for _, user := range users {
+		user.Password = ""
+	}
+
+	return users, total, nil
+}
+
+// SuspendUser blocks a user from signing in and revokes the tokens they hold
+func (s *adminService) SuspendUser(ctx context.Context, adminID, userID, reason string) (*database.User, error) {
+	if reason == "" {
+		return nil, errors.New("a reason is required to suspend a user")
+	}
+
+	user, err := s.targetUser(ctx, adminID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == RoleAdmin {
+		return nil, errors.New("permission denied: admins cannot be suspended")
+	}
+
+	user.Status = UserStatusSuspended
+	user.SuspensionReason = reason
+	user.SuspendedAt = time.Now().Unix()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("AUDIT user suspended: admin=%s user=%s reason=%q", adminID, userID, reason)
+
+	// Tokens, impersonation tokens included, would otherwise work until they
+	// expire. Suspending again retries a failed revocation.
+	if s.revocations != nil {
+		if err := s.revocations.Revoke(ctx, userID, s.tokenLifetime); err != nil {
+			return nil, fmt.Errorf("user suspended but their tokens could not be revoked: %v", err)
+		}
+	}
+
+	// Don't return password hash
+	user.Password = ""
+	return user, nil
+}
+
+// UnsuspendUser restores a suspended user's access
+func (s *adminService) UnsuspendUser(ctx context.Context, adminID, userID string) (*database.User, error) {
+	user, err := s.targetUser(ctx, adminID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != UserStatusSuspended {
+		return nil, errors.New("user is not suspended")
+	}
+
+	user.Status = UserStatusActive
+	user.SuspensionReason = ""
+	user.SuspendedAt = 0
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("AUDIT user unsuspended: admin=%s user=%s", adminID, userID)
+
+	// Don't return password hash
+	user.Password = ""
+	return user, nil
+}
+
+// ForcePasswordReset blocks sign-in until the user sets a new password. A
+// single-use reset token is published for notification-service to deliver.
+func (s *adminService) ForcePasswordReset(ctx context.Context, adminID, userID string) (*database.User, error) {
+	if s.eventBus == nil {
+		return nil, errors.New("password reset delivery is not available")
+	}
+
+	user, err := s.targetUser(ctx, adminID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt, err := requirePasswordReset(ctx, s.userRepo, s.eventBus, user)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("AUDIT password reset forced: admin=%s user=%s expires=%s", adminID, userID, expiresAt.UTC().Format(time.RFC3339))
+
+	// Don't return password hash
+	user.Password = ""
+	return user, nil
+}
+
+// requirePasswordReset blocks sign-in until the user sets a new password and
+// publishes a single-use reset token for notification-service to deliver
+func requirePasswordReset(ctx context.Context, userRepo repository.UserRepository, eventBus events.EventBus, user *database.User) (time.Time, error) {
+	token, tokenHash, err := newResetToken()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to generate reset token: %v", err)
+	}
+
+	expiresAt := time.Now().Add(PasswordResetTTL)
+	user.PasswordResetRequired = true
+	user.PasswordResetTokenHash = tokenHash
+	user.PasswordResetExpiresAt = expiresAt.Unix()
+	if err := userRepo.Update(ctx, user); err != nil {
+		return time.Time{}, err
+	}
+
+	err = eventBus.Publish(ctx, &events.Event{
+		Type:    events.UserPasswordResetRequested,
+		Source:  "user-service",
+		Subject: user.ID,
+		Data: map[string]interface{}{
+			"user_id":     user.ID,
+			"email":       user.Email,
+			"reset_token": token,
+			"expires_at":  expiresAt.Unix(),
+		},
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to publish password reset: %v", err)
+	}
+	return expiresAt, nil
+}
+
+// AssignRole changes a user's role
+func (s *adminService) AssignRole(ctx context.Context, adminID, userID, role string) (*database.User, error) {
+	if !validRoles[role] {
+		return nil, fmt.Errorf("invalid role: %s", role)
+	}
+
+	user, err := s.targetUser(ctx, adminID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := user.Role
+	user.Role = role
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("AUDIT role assigned: admin=%s user=%s from=%s to=%s", adminID, userID, previous, role)
+
+	// Don't return password hash
+	user.Password = ""
+	return user, nil
+}
+
+// targetUser verifies the acting admin and loads the user an admin action
+// applies to. Admins cannot act on their own account.
+func (s *adminService) targetUser(ctx context.Context, adminID, userID string) (*database.User, error) {
+	if adminID == userID {
+		return nil, errors.New("admins cannot change their own account")
+	}
+
+	if err := requireAdmin(ctx, s.userRepo, adminID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Status == UserStatusDeleted {
+		return nil, errors.New("user not found")
+	}
+
+	return user, nil
+}
+
+// requireAdmin verifies that adminID is an active admin
+func requireAdmin(ctx context.Context, userRepo repository.UserRepository, adminID string) error {
+	admin, err := userRepo.GetByID(ctx, adminID)
+	if err != nil {
+		return err
+	}
+	if admin == nil || admin.Role != RoleAdmin || admin.Status != UserStatusActive {
+		return errors.New("permission denied: admin role required")
+	}
+	return nil
+}
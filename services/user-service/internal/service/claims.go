@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"microservices-platform/pkg/middleware"
+	"microservices-platform/pkg/plans"
+	"microservices-platform/services/user-service/internal/database"
+	"microservices-platform/services/user-service/internal/repository"
+)
+
+// ClaimsEnricher adds claims to a token issued for user. Services register
+// enrichers with TokenSigner.Use; claims without a field in
+// middleware.Claims can be added with claims.Set.
+type ClaimsEnricher func(ctx context.Context, user *database.User, claims *middleware.Claims) error
+
+// OrganizationClaims adds a role per organization the user belongs to, as
+// "org:<organization>:<role>". A user in exactly one organization gets it as
+// the token's tenant; other users are their own tenant.
+func OrganizationClaims(orgRepo repository.OrganizationRepository) ClaimsEnricher {
+	return func(ctx context.Context, user *database.User, claims *middleware.Claims) error {
+		organizations, err := orgRepo.ListByUserID(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		for _, organization := range organizations {
+			membership, err := orgRepo.GetMembership(ctx, organization.ID, user.ID)
+			if err != nil {
+				return err
+			}
+			if membership != nil {
+				claims.Roles = append(claims.Roles, "org:"+organization.ID+":"+membership.Role)
+			}
+		}
+		if len(organizations) == 1 {
+			claims.TenantID = organizations[0].ID
+		}
+		return nil
+	}
+}
+
+// PlanClaims adds the plan and features of the token's tenant. They may
+// change before the token expires, so consumers that gate paid features check
+// the plan itself. If plans cannot be looked up, the claims are left out
+// rather than failing sign-in.
+func PlanClaims(lookup plans.Lookup) ClaimsEnricher {
+	return func(ctx context.Context, user *database.User, claims *middleware.Claims) error {
+		limits, err := lookup.Get(ctx, claims.Tenant())
+		if err != nil {
+			log.Printf("Plan limits unavailable, issuing token for user %s without plan claims: %v", user.ID, err)
+			return nil
+		}
+		if limits != nil {
+			claims.Plan = limits.Plan
+			claims.Features = limits.Features
+		}
+		return nil
+	}
+}
+
+// FeatureFlagClaims turns feature flags on by role. flags maps each flag to
+// the roles it is on for, including organization roles; "*" turns it on for
+// everyone.
+func FeatureFlagClaims(flags map[string][]string) ClaimsEnricher {
+	return func(ctx context.Context, user *database.User, claims *middleware.Claims) error {
+		for flag, roles := range flags {
+			for _, role := range roles {
+				if role == "*" || claims.HasRole(role) {
+					claims.Flags = append(claims.Flags, flag)
+					break
+				}
+			}
+		}
+		sort.Strings(claims.Flags)
+		return nil
+	}
+}
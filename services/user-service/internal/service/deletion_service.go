@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/operations"
+	"microservices-platform/pkg/storage"
+	"microservices-platform/pkg/versioning"
+	"microservices-platform/services/user-service/internal/database"
+	"microservices-platform/services/user-service/internal/repository"
+)
+
+// DeletionServiceName identifies user-service as a deletion participant
+const DeletionServiceName = "user-service"
+
+// Deletion request statuses
+const (
+	DeletionStatusInProgress = "in_progress"
+	DeletionStatusCompleted  = "completed"
+	DeletionStatusFailed     = "failed"
+)
+
+// UserStatusDeleted marks a user record that has been anonymized
+const UserStatusDeleted = "deleted"
+
+// DeletionService interface defines right-to-be-forgotten operations. A
+// deletion is a saga: user-service records the request and publishes
+// user.deletion_requested, every participating service deletes or anonymizes
+// its records and reports back with user.deletion_step_completed.
+type DeletionService interface {
+	RequestDeletion(ctx context.Context, userID, requestedBy string, match *versioning.Precondition) (*database.DeletionRequest, error)
+	GetDeletionStatus(ctx context.Context, userID string) (*database.DeletionRequest, error)
+	AnonymizeUser(ctx context.Context, userID string) error
+	HandleStepCompleted(ctx context.Context, event *events.Event) error
+}
+
+// deletionService implements DeletionService interface
+type deletionService struct {
+	userRepo     repository.UserRepository
+	addressRepo  repository.AddressRepository
+	exportRepo   repository.ExportRepository
+	deletionRepo repository.DeletionRepository
+	webhookRepo  repository.WebhookRepository
+	orgRepo      repository.OrganizationRepository
+	loginRepo    repository.LoginRepository
+	store        storage.ObjectStore
+	eventBus     events.EventBus
+	operations   operations.Store
+	participants []string
+}
+
+// NewDeletionService creates a new deletion service. participants lists the
+// services that must confirm before a deletion is complete. store may be nil;
+// eventBus may be nil, in which case deletions are rejected. Without an
+// operation store, deletion progress is only available from GetDeletionStatus.
+func NewDeletionService(userRepo repository.UserRepository, addressRepo repository.AddressRepository, exportRepo repository.ExportRepository, deletionRepo repository.DeletionRepository, webhookRepo repository.WebhookRepository, orgRepo repository.OrganizationRepository, loginRepo repository.LoginRepository, store storage.ObjectStore, eventBus events.EventBus, opStore operations.Store, participants []string) DeletionService {
+	return &deletionService{
+		userRepo:     userRepo,
+		addressRepo:  addressRepo,
+		exportRepo:   exportRepo,
+		deletionRepo: deletionRepo,
+		webhookRepo:  webhookRepo,
+		orgRepo:      orgRepo,
+		loginRepo:    loginRepo,
+		store:        store,
+		eventBus:     eventBus,
+		operations:   opStore,
+		participants: participants,
+	}
+}
+
+// RequestDeletion starts the deletion saga for a user. Requesting deletion of
+// a user with an unfinished request re-publishes it so services that have not
+// yet confirmed are retried; participants must therefore be idempotent. match
+// applies to starting a deletion only, since a retried one may already have
+// anonymized the user.
+func (s *deletionService) RequestDeletion(ctx context.Context, userID, requestedBy string, match *versioning.Precondition) (*database.DeletionRequest, error) {
+	if s.eventBus == nil {
+		return nil, errors.New("user deletion is not available")
+	}
+
+	request, err := s.deletionRepo.GetLatestByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case request != nil && request.Status == DeletionStatusCompleted:
+		return request, nil
+	case request != nil:
+		if err := s.deletionRepo.UpdateStatus(ctx, request.ID, DeletionStatusInProgress); err != nil {
+			return nil, err
+		}
+		request.Status = DeletionStatusInProgress
+	default:
+		user, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, errors.New("user not found")
+		}
+		if !match.Matches(user.Version) {
+			return nil, versioning.ErrConflict
+		}
+
+		request = &database.DeletionRequest{
+			UserID:      userID,
+			RequestedBy: requestedBy,
+			Status:      DeletionStatusInProgress,
+		}
+		for _, participant := range s.participants {
+			request.Steps = append(request.Steps, database.DeletionStep{
+				Service: participant,
+				Status:  events.DeletionStepPending,
+			})
+		}
+		if err := s.deletionRepo.Create(ctx, request); err != nil {
+			return nil, err
+		}
+	}
+
+	if request.OperationID == "" && s.operations != nil {
+		request.OperationID = operations.New(operations.TypeUserDeletion, DeletionServiceName, requestedBy).ID
+		if err := s.deletionRepo.SetOperationID(ctx, request.ID, request.OperationID); err != nil {
+			return nil, err
+		}
+	}
+	s.recordProgress(ctx, request)
+
+	log.Printf("AUDIT user deletion requested: deletion=%s user=%s requested_by=%s", request.ID, userID, requestedBy)
+
+	event := events.NewDeletionRequestedEvent(DeletionServiceName, request.ID, userID)
+	if err := s.eventBus.Publish(ctx, event); err != nil {
+		return nil, fmt.Errorf("failed to publish deletion request: %v", err)
+	}
+
+	return request, nil
+}
+
+// GetDeletionStatus returns the most recent deletion request for a user
+func (s *deletionService) GetDeletionStatus(ctx context.Context, userID string) (*database.DeletionRequest, error) {
+	request, err := s.deletionRepo.GetLatestByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, errors.New("no deletion request found for user")
+	}
+
+	return request, nil
+}
+
+// AnonymizeUser is user-service's own deletion step. The user row is kept as
+// an anonymized tombstone so other services' references stay valid, while
+// addresses, avatars, data exports and organization memberships are removed.
+func (s *deletionService) AnonymizeUser(ctx context.Context, userID string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+
+	if err := s.addressRepo.DeleteByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete addresses: %v", err)
+	}
+
+	if err := s.webhookRepo.DeleteEndpointsByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete webhook endpoints: %v", err)
+	}
+
+	if err := s.orgRepo.DeleteMembershipsByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete organization memberships: %v", err)
+	}
+
+	if err := s.loginRepo.DeleteByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete sign-in history: %v", err)
+	}
+
+	if s.store != nil {
+		for _, size := range storage.StandardImageSizes {
+			if err := s.store.Delete(ctx, storage.AvatarKey(userID, size)); err != nil {
+				return err
+			}
+		}
+
+		exports, err := s.exportRepo.ListByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, export := range exports {
+			if export.ObjectKey == "" {
+				continue
+			}
+			if err := s.store.Delete(ctx, export.ObjectKey); err != nil {
+				return err
+			}
+		}
+	}
+
+	user.Email = fmt.Sprintf("deleted+%s@deleted.invalid", user.ID)
+	user.Username = "deleted-" + user.ID
+	user.Password = ""
+	user.FirstName = ""
+	user.LastName = ""
+	user.AvatarURL = ""
+	user.Role = RoleUser
+	user.Status = UserStatusDeleted
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// HandleStepCompleted records a participant's result and completes the
+// deletion once every participant has confirmed
+func (s *deletionService) HandleStepCompleted(ctx context.Context, event *events.Event) error {
+	result, err := events.ParseDeletionStepResult(event)
+	if err != nil {
+		return err
+	}
+
+	step := &database.DeletionStep{
+		DeletionRequestID: result.DeletionID,
+		Service:           result.Service,
+		Status:            result.Status,
+		Error:             result.Error,
+	}
+	if result.Status == events.DeletionStepCompleted {
+		step.CompletedAt = time.Now().Unix()
+	}
+	if err := s.deletionRepo.UpdateStep(ctx, step); err != nil {
+		return err
+	}
+
+	request, err := s.deletionRepo.GetByID(ctx, result.DeletionID)
+	if err != nil {
+		return err
+	}
+	if request == nil {
+		return fmt.Errorf("deletion request %s not found", result.DeletionID)
+	}
+
+	pending, failed := 0, 0
+	for _, st := range request.Steps {
+		switch st.Status {
+		case events.DeletionStepCompleted:
+		case events.DeletionStepFailed:
+			failed++
+		default:
+			pending++
+		}
+	}
+
+	switch {
+	case pending == 0 && failed == 0:
+		completed, err := s.deletionRepo.MarkCompleted(ctx, request.ID, time.Now().Unix())
+		if err != nil || !completed {
+			return err
+		}
+		request.Status = DeletionStatusCompleted
+		s.recordProgress(ctx, request)
+
+		log.Printf("AUDIT user deletion completed: deletion=%s user=%s", request.ID, request.UserID)
+		return s.eventBus.Publish(ctx, &events.Event{
+			Type:    events.UserDeleted,
+			Source:  DeletionServiceName,
+			Subject: request.UserID,
+			Data: map[string]interface{}{
+				"deletion_id": request.ID,
+				"user_id":     request.UserID,
+			},
+		})
+	case pending == 0:
+		log.Printf("AUDIT user deletion failed: deletion=%s user=%s failed_steps=%d", request.ID, request.UserID, failed)
+		if err := s.deletionRepo.UpdateStatus(ctx, request.ID, DeletionStatusFailed); err != nil {
+			return err
+		}
+		request.Status = DeletionStatusFailed
+	}
+
+	s.recordProgress(ctx, request)
+	return nil
+}
+
+// recordProgress mirrors a deletion request onto its operation, counting
+// each participant that has confirmed. Progress is best effort: the deletion
+// request remains the record of the saga.
+func (s *deletionService) recordProgress(ctx context.Context, request *database.DeletionRequest) {
+	if s.operations == nil || request.OperationID == "" {
+		return
+	}
+
+	op, err := s.operations.Get(ctx, request.OperationID)
+	if err != nil {
+		log.Printf("Failed to load operation %s for deletion %s: %v", request.OperationID, request.ID, err)
+		return
+	}
+	if op == nil {
+		op = operations.New(operations.TypeUserDeletion, DeletionServiceName, request.RequestedBy)
+		op.ID = request.OperationID
+	}
+
+	completed, failed := 0, 0
+	for _, step := range request.Steps {
+		switch step.Status {
+		case events.DeletionStepCompleted:
+			completed++
+		case events.DeletionStepFailed:
+			failed++
+		}
+	}
+
+	switch request.Status {
+	case DeletionStatusCompleted:
+		op.Succeed(map[string]string{"deletion_id": request.ID, "user_id": request.UserID})
+	case DeletionStatusFailed:
+		op.Fail(fmt.Errorf("%d of %d services failed to delete the user's data", failed, len(request.Steps)))
+	default:
+		percent := 0
+		if len(request.Steps) > 0 {
+			percent = completed * 100 / len(request.Steps)
+		}
+		op.SetProgress(percent, fmt.Sprintf("%d of %d services have deleted the user's data", completed, len(request.Steps)))
+	}
+
+	if err := s.operations.Save(ctx, op); err != nil {
+		log.Printf("Failed to record progress of deletion %s: %v", request.ID, err)
+	}
+}
@@ -0,0 +1,487 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"microservices-platform/pkg/grpcclient"
+	"microservices-platform/pkg/operations"
+	notificationpb "microservices-platform/pkg/proto/notification/v1"
+	orderpb "microservices-platform/pkg/proto/order/v1"
+	paymentpb "microservices-platform/pkg/proto/payment/v1"
+	"microservices-platform/pkg/storage"
+	"microservices-platform/services/user-service/internal/config"
+	"microservices-platform/services/user-service/internal/database"
+	"microservices-platform/services/user-service/internal/repository"
+)
+
+// Supported data export formats
+const (
+	ExportFormatJSON = "json"
+	ExportFormatZIP  = "zip"
+)
+
+// Data export statuses
+const (
+	ExportStatusPending   = "pending"
+	ExportStatusCompleted = "completed"
+	ExportStatusFailed    = "failed"
+)
+
+const (
+	// exportPageSize is the page size used when paging through downstream lists
+	exportPageSize = 100
+	// exportTimeout bounds the fan-out to downstream services
+	exportTimeout = 30 * time.Second
+)
+
+// ExportService interface defines GDPR data export operations
+type ExportService interface {
+	ExportUserData(ctx context.Context, userID, format string) (*database.DataExport, string, error)
+	// StartExport records an export and builds it in the background. It
+	// returns operations.ErrUnavailable if progress can't be tracked.
+	StartExport(ctx context.Context, userID, format string) (*database.DataExport, *operations.Operation, error)
+}
+
+// ExportResult is the result of a data export operation
+type ExportResult struct {
+	ExportID    string    `json:"export_id"`
+	DownloadURL string    `json:"download_url"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// UserDataExport is the document delivered to a user for a data export
+type UserDataExport struct {
+	ExportID      string            `json:"export_id"`
+	GeneratedAt   time.Time         `json:"generated_at"`
+	User          exportedUser      `json:"user"`
+	Addresses     []exportedAddress `json:"addresses"`
+	Orders        []json.RawMessage `json:"orders"`
+	Payments      []json.RawMessage `json:"payments"`
+	Notifications []json.RawMessage `json:"notifications"`
+}
+
+// exportedUser is the user profile as included in a data export. The password
+// hash is deliberately left out.
+type exportedUser struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Username  string    `json:"username"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Status    string    `json:"status"`
+	Role      string    `json:"role"`
+	AvatarURL string    `json:"avatar_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// exportedAddress is a saved address as included in a data export
+type exportedAddress struct {
+	ID                string    `json:"id"`
+	Label             string    `json:"label,omitempty"`
+	RecipientName     string    `json:"recipient_name"`
+	Line1             string    `json:"line1"`
+	Line2             string    `json:"line2,omitempty"`
+	City              string    `json:"city"`
+	State             string    `json:"state,omitempty"`
+	PostalCode        string    `json:"postal_code"`
+	Country           string    `json:"country"`
+	Phone             string    `json:"phone,omitempty"`
+	IsDefaultShipping bool      `json:"is_default_shipping"`
+	IsDefaultBilling  bool      `json:"is_default_billing"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// exportService implements ExportService interface
+type exportService struct {
+	userRepo           repository.UserRepository
+	addressRepo        repository.AddressRepository
+	exportRepo         repository.ExportRepository
+	store              storage.ObjectStore
+	linkTTL            time.Duration
+	runner             *operations.Runner
+	orderClient        orderpb.OrderServiceClient
+	paymentClient      paymentpb.PaymentServiceClient
+	notificationClient notificationpb.NotificationServiceClient
+}
+
+// NewExportService creates a new data export service. store may be nil, in
+// which case exports are rejected; runner may be nil, in which case exports
+// are only built synchronously.
+func NewExportService(userRepo repository.UserRepository, addressRepo repository.AddressRepository, exportRepo repository.ExportRepository, store storage.ObjectStore, runner *operations.Runner, cfg *config.Config) ExportService {
+	// Initialize gRPC connections
+	orderConn, err := grpcclient.Dial(cfg.OrderServiceURL, cfg.GRPCClient)
+	if err != nil {
+		log.Printf("Failed to connect to order service: %v", err)
+	}
+
+	paymentConn, err := grpcclient.Dial(cfg.PaymentServiceURL, cfg.GRPCClient)
+	if err != nil {
+		log.Printf("Failed to connect to payment service: %v", err)
+	}
+
+	notificationConn, err := grpcclient.Dial(cfg.NotificationServiceURL, cfg.GRPCClient)
+	if err != nil {
+		log.Printf("Failed to connect to notification service: %v", err)
+	}
+
+	return &exportService{
+		userRepo:           userRepo,
+		addressRepo:        addressRepo,
+		exportRepo:         exportRepo,
+		store:              store,
+		linkTTL:            cfg.ExportLinkTTL,
+		runner:             runner,
+		orderClient:        orderpb.NewOrderServiceClient(orderConn),
+		paymentClient:      paymentpb.NewPaymentServiceClient(paymentConn),
+		notificationClient: notificationpb.NewNotificationServiceClient(notificationConn),
+	}
+}
+
+// ExportUserData gathers everything the platform holds about a user, uploads
+// it as a JSON document or ZIP archive and returns a time-limited download link
+func (s *exportService) ExportUserData(ctx context.Context, userID, format string) (*database.DataExport, string, error) {
+	user, export, err := s.createExport(ctx, userID, format, "")
+	if err != nil {
+		return nil, "", err
+	}
+
+	downloadURL, err := s.completeExport(ctx, user, export, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return export, downloadURL, nil
+}
+
+// StartExport validates and records an export, then builds it in the
+// background. The operation's result is an ExportResult.
+func (s *exportService) StartExport(ctx context.Context, userID, format string) (*database.DataExport, *operations.Operation, error) {
+	if s.runner.Store() == nil {
+		return nil, nil, operations.ErrUnavailable
+	}
+
+	// The operation ID is chosen up front so the export record names it
+	op := s.runner.New(operations.TypeDataExport, userID)
+	user, export, err := s.createExport(ctx, userID, format, op.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	started, err := s.runner.Run(ctx, op, func(ctx context.Context, progress *operations.Progress) (interface{}, error) {
+		downloadURL, err := s.completeExport(ctx, user, export, progress)
+		if err != nil {
+			return nil, err
+		}
+		return &ExportResult{
+			ExportID:    export.ID,
+			DownloadURL: downloadURL,
+			ExpiresAt:   time.Unix(export.ExpiresAt, 0).UTC(),
+		}, nil
+	})
+	if err != nil {
+		s.recordFailure(ctx, export, err)
+		return nil, nil, err
+	}
+	return export, started, nil
+}
+
+// createExport checks an export request and records the pending export
+func (s *exportService) createExport(ctx context.Context, userID, format, operationID string) (*database.User, *database.DataExport, error) {
+	if s.store == nil {
+		return nil, nil, errors.New("data exports are not available")
+	}
+	if format == "" {
+		format = ExportFormatZIP
+	}
+	if format != ExportFormatJSON && format != ExportFormatZIP {
+		return nil, nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, errors.New("user not found")
+	}
+
+	export := &database.DataExport{
+		UserID:      userID,
+		Format:      format,
+		Status:      ExportStatusPending,
+		OperationID: operationID,
+	}
+	if err := s.exportRepo.Create(ctx, export); err != nil {
+		return nil, nil, err
+	}
+	return user, export, nil
+}
+
+// completeExport builds a recorded export and marks it completed or failed.
+// progress may be nil.
+func (s *exportService) completeExport(ctx context.Context, user *database.User, export *database.DataExport, progress *operations.Progress) (string, error) {
+	downloadURL, err := s.buildExport(ctx, user, export, progress)
+	if err != nil {
+		s.recordFailure(ctx, export, err)
+		return "", err
+	}
+
+	export.Status = ExportStatusCompleted
+	export.ExpiresAt = time.Now().Add(s.linkTTL).Unix()
+	if err := s.exportRepo.Update(ctx, export); err != nil {
+		return "", err
+	}
+
+	log.Printf("AUDIT data export completed: export=%s user=%s format=%s", export.ID, user.ID, export.Format)
+	return downloadURL, nil
+}
+
+// recordFailure marks an export failed
+func (s *exportService) recordFailure(ctx context.Context, export *database.DataExport, err error) {
+	export.Status = ExportStatusFailed
+	export.Error = err.Error()
+	if updateErr := s.exportRepo.Update(ctx, export); updateErr != nil {
+		log.Printf("Failed to record failed export %s: %v", export.ID, updateErr)
+	}
+}
+
+// buildExport collects the user's data, uploads the package and signs a link to it
+func (s *exportService) buildExport(ctx context.Context, user *database.User, export *database.DataExport, progress *operations.Progress) (string, error) {
+	progress.Report(ctx, 10, "Collecting data from services")
+	data, err := s.collect(ctx, user)
+	if err != nil {
+		return "", err
+	}
+	data.ExportID = export.ID
+
+	progress.Report(ctx, 60, "Packaging export")
+
+	var payload []byte
+	var contentType string
+	switch export.Format {
+	case ExportFormatJSON:
+		payload, err = json.MarshalIndent(data, "", "  ")
+		contentType = "application/json"
+	default:
+		payload, err = zipExport(data)
+		contentType = "application/zip"
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to package export: %v", err)
+	}
+
+	progress.Report(ctx, 80, "Uploading export")
+	key := storage.ExportKey(user.ID, export.ID, export.Format)
+	if _, err := s.store.Put(ctx, key, bytes.NewReader(payload), int64(len(payload)), contentType); err != nil {
+		return "", err
+	}
+	export.ObjectKey = key
+
+	return s.store.SignedURL(key, s.linkTTL)
+}
+
+// collect fans out to downstream services in parallel and assembles the export.
+// Any failing source fails the export so users never receive a partial copy.
+func (s *exportService) collect(ctx context.Context, user *database.User) (*UserDataExport, error) {
+	addresses, err := s.addressRepo.ListByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &UserDataExport{
+		GeneratedAt: time.Now().UTC(),
+		User: exportedUser{
+			ID:        user.ID,
+			Email:     user.Email,
+			Username:  user.Username,
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Status:    user.Status,
+			Role:      user.Role,
+			AvatarURL: user.AvatarURL,
+			CreatedAt: time.Unix(user.CreatedAt, 0).UTC(),
+			UpdatedAt: time.Unix(user.UpdatedAt, 0).UTC(),
+		},
+	}
+	for _, address := range addresses {
+		data.Addresses = append(data.Addresses, exportedAddress{
+			ID:                address.ID,
+			Label:             address.Label,
+			RecipientName:     address.RecipientName,
+			Line1:             address.Line1,
+			Line2:             address.Line2,
+			City:              address.City,
+			State:             address.State,
+			PostalCode:        address.PostalCode,
+			Country:           address.Country,
+			Phone:             address.Phone,
+			IsDefaultShipping: address.IsDefaultShipping,
+			IsDefaultBilling:  address.IsDefaultBilling,
+			CreatedAt:         time.Unix(address.CreatedAt, 0).UTC(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var orderErr, paymentErr, notificationErr error
+
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		data.Orders, orderErr = s.collectOrders(ctx, user.ID)
+	}()
+	go func() {
+		defer wg.Done()
+		data.Payments, paymentErr = s.collectPayments(ctx, user.ID)
+	}()
+	go func() {
+		defer wg.Done()
+		data.Notifications, notificationErr = s.collectNotifications(ctx, user.ID)
+	}()
+	wg.Wait()
+
+	if orderErr != nil {
+		return nil, fmt.Errorf("failed to export orders: %v", orderErr)
+	}
+	if paymentErr != nil {
+		return nil, fmt.Errorf("failed to export payments: %v", paymentErr)
+	}
+	if notificationErr != nil {
+		return nil, fmt.Errorf("failed to export notifications: %v", notificationErr)
+	}
+
+	return data, nil
+}
+
+// collectOrders streams all of a user's orders, so orders placed meanwhile
+// can't shift pages and drop or repeat records
+func (s *exportService) collectOrders(ctx context.Context, userID string) ([]json.RawMessage, error) {
+	stream, err := s.orderClient.StreamOrders(ctx, &orderpb.StreamOrdersRequest{UserId: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []json.RawMessage
+	for {
+		order, err := stream.Recv()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		record, err := marshalRecord(order)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// collectPayments pages through all of a user's payments
+func (s *exportService) collectPayments(ctx context.Context, userID string) ([]json.RawMessage, error) {
+	var records []json.RawMessage
+	for page := int32(1); ; page++ {
+		resp, err := s.paymentClient.ListPayments(ctx, &paymentpb.ListPaymentsRequest{
+			UserId:   userID,
+			Page:     page,
+			PageSize: exportPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, payment := range resp.Payments {
+			record, err := marshalRecord(payment)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+		if len(resp.Payments) < exportPageSize {
+			return records, nil
+		}
+	}
+}
+
+// collectNotifications pages through all of a user's notifications
+func (s *exportService) collectNotifications(ctx context.Context, userID string) ([]json.RawMessage, error) {
+	var records []json.RawMessage
+	for page := int32(1); ; page++ {
+		resp, err := s.notificationClient.ListNotifications(ctx, &notificationpb.ListNotificationsRequest{
+			UserId:   userID,
+			Page:     page,
+			PageSize: exportPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, notification := range resp.Notifications {
+			record, err := marshalRecord(notification)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+		if len(resp.Notifications) < exportPageSize {
+			return records, nil
+		}
+	}
+}
+
+// marshalRecord renders a downstream record using its protobuf JSON mapping
+func marshalRecord(m proto.Message) (json.RawMessage, error) {
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+}
+
+// zipExport packages an export as a ZIP archive with one JSON file per source
+func zipExport(data *UserDataExport) ([]byte, error) {
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"user.json", struct {
+			ExportID    string       `json:"export_id"`
+			GeneratedAt time.Time    `json:"generated_at"`
+			User        exportedUser `json:"user"`
+		}{data.ExportID, data.GeneratedAt, data.User}},
+		{"addresses.json", data.Addresses},
+		{"orders.json", data.Orders},
+		{"payments.json", data.Payments},
+		{"notifications.json", data.Notifications},
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range files {
+		content, err := json.MarshalIndent(file.content, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		w, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
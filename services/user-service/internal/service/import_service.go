@@ -0,0 +1,469 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/mail"
+	"strings"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/i18n"
+	"microservices-platform/pkg/passwordpolicy"
+	"microservices-platform/services/user-service/internal/database"
+	"microservices-platform/services/user-service/internal/repository"
+)
+
+// Formats of user import files
+const (
+	// ImportFormatCSV is comma-separated values with a header row naming
+	// the columns
+	ImportFormatCSV = "csv"
+	// ImportFormatNDJSON is one JSON object per line
+	ImportFormatNDJSON = "ndjson"
+)
+
+// How an import treats a row whose email is already registered
+const (
+	// DuplicateSkip leaves the existing user as it is
+	DuplicateSkip = "skip"
+	// DuplicateUpdate overwrites the existing user's profile with the
+	// row's non-empty fields
+	DuplicateUpdate = "update"
+	// DuplicateFail reports the row as failed
+	DuplicateFail = "fail"
+)
+
+// Outcomes of an imported row
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// MaxImportRows caps the rows of one import, so an import finishes within a
+// request; larger customer bases are imported in several files
+const MaxImportRows = 10000
+
+// ImportRow is a user to import. Columns of CSV files and fields of NDJSON
+// lines use the JSON names. PasswordHash is an Argon2id hash in the PHC
+// string format, as exported by another deployment; Password is a plaintext
+// password, which must meet the password policy. Users with neither get a
+// random password and a reset link to choose their own.
+type ImportRow struct {
+	Email        string `json:"email"`
+	Username     string `json:"username"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Role         string `json:"role"`
+	Locale       string `json:"locale"`
+	TimeZone     string `json:"time_zone"`
+	PasswordHash string `json:"password_hash"`
+	Password     string `json:"password"`
+}
+
+// ImportOptions control how an import file is read and applied
+type ImportOptions struct {
+	// Format is ImportFormatCSV or ImportFormatNDJSON
+	Format string
+	// OnDuplicate is DuplicateSkip, DuplicateUpdate or DuplicateFail;
+	// empty means DuplicateSkip
+	OnDuplicate string
+	// DryRun validates the rows and reports what would happen without
+	// changing any user
+	DryRun bool
+}
+
+// ImportResult is the outcome of one row of an import. Row counts data rows
+// from 1, not counting a CSV header.
+type ImportResult struct {
+	Row    int
+	Email  string
+	UserID string
+	Status string
+	Error  string
+}
+
+// ImportService interface defines bulk user import operations
+type ImportService interface {
+	// ImportUsers imports the users of an import file. Rows are applied
+	// one at a time, so a failed row does not undo the others; an error is
+	// returned only if the file cannot be read at all.
+	ImportUsers(ctx context.Context, adminID string, data []byte, opts ImportOptions) ([]ImportResult, error)
+}
+
+// importService implements ImportService interface
+type importService struct {
+	userRepo  repository.UserRepository
+	eventBus  events.EventBus
+	passwords *passwordpolicy.Policy
+	argon2    Argon2Params
+}
+
+// NewImportService creates a new import service. Plaintext passwords must
+// meet passwords and are hashed with hashing. Each imported user is
+// published on eventBus as user.created, and users without a password are
+// sent a reset link through it.
+func NewImportService(userRepo repository.UserRepository, eventBus events.EventBus, passwords *passwordpolicy.Policy, hashing Argon2Params) ImportService {
+	return &importService{
+		userRepo:  userRepo,
+		eventBus:  eventBus,
+		passwords: passwords,
+		argon2:    hashing,
+	}
+}
+
+// ImportUsers imports the users of an import file
+func (s *importService) ImportUsers(ctx context.Context, adminID string, data []byte, opts ImportOptions) ([]ImportResult, error) {
+	switch opts.OnDuplicate {
+	case "":
+		opts.OnDuplicate = DuplicateSkip
+	case DuplicateSkip, DuplicateUpdate, DuplicateFail:
+	default:
+		return nil, fmt.Errorf("invalid duplicate policy: %s", opts.OnDuplicate)
+	}
+
+	if err := requireAdmin(ctx, s.userRepo, adminID); err != nil {
+		return nil, err
+	}
+
+	rows, err := parseImport(data, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ImportResult, len(rows))
+	seen := make(map[string]int, len(rows))
+	counts := make(map[string]int)
+	for i, row := range rows {
+		row.Email = strings.ToLower(strings.TrimSpace(row.Email))
+		result := ImportResult{Row: i + 1, Email: row.Email}
+
+		if first, ok := seen[row.Email]; ok && row.Email != "" {
+			result.Status = ImportFailed
+			result.Error = fmt.Sprintf("email already appears in row %d", first)
+		} else {
+			seen[row.Email] = i + 1
+			user, status, err := s.importRow(ctx, row, opts)
+			result.Status = status
+			if err != nil {
+				result.Error = err.Error()
+			}
+			if user != nil {
+				result.UserID = user.ID
+			}
+		}
+
+		results[i] = result
+		counts[result.Status]++
+	}
+
+	log.Printf("AUDIT users imported: admin=%s rows=%d created=%d updated=%d skipped=%d failed=%d on_duplicate=%s dry_run=%t",
+		adminID, len(rows), counts[ImportCreated], counts[ImportUpdated], counts[ImportSkipped], counts[ImportFailed], opts.OnDuplicate, opts.DryRun)
+
+	return results, nil
+}
+
+// importRow validates a row and creates or updates its user, returning the
+// row's outcome
+func (s *importService) importRow(ctx context.Context, row ImportRow, opts ImportOptions) (*database.User, string, error) {
+	if err := s.validateRow(ctx, row); err != nil {
+		return nil, ImportFailed, err
+	}
+
+	existing, err := s.userRepo.GetByEmail(ctx, row.Email)
+	if err != nil {
+		return nil, ImportFailed, err
+	}
+	if existing == nil {
+		user, err := s.createUser(ctx, row, opts.DryRun)
+		if err != nil {
+			return nil, ImportFailed, err
+		}
+		return user, ImportCreated, nil
+	}
+
+	switch opts.OnDuplicate {
+	case DuplicateUpdate:
+		if err := s.updateUser(ctx, existing, row, opts.DryRun); err != nil {
+			return existing, ImportFailed, err
+		}
+		return existing, ImportUpdated, nil
+	case DuplicateFail:
+		return existing, ImportFailed, errors.New("user with this email already exists")
+	default:
+		return existing, ImportSkipped, nil
+	}
+}
+
+// validateRow checks the fields of a row
+func (s *importService) validateRow(ctx context.Context, row ImportRow) error {
+	if address, err := mail.ParseAddress(row.Email); err != nil || address.Address != row.Email {
+		return fmt.Errorf("invalid email: %q", row.Email)
+	}
+	if strings.TrimSpace(row.Username) == "" {
+		return errors.New("username is required")
+	}
+	if row.Role != "" && !validRoles[row.Role] {
+		return fmt.Errorf("invalid role: %s", row.Role)
+	}
+	// Admins are appointed one by one, not migrated in bulk
+	if row.Role == RoleAdmin {
+		return errors.New("admins cannot be imported")
+	}
+	if row.Locale != "" && !i18n.ValidLocale(row.Locale) {
+		return fmt.Errorf("locale %q is not a language tag", row.Locale)
+	}
+	if !i18n.ValidTimeZone(row.TimeZone) {
+		return fmt.Errorf("time zone %q is not an IANA time zone", row.TimeZone)
+	}
+
+	switch {
+	case row.PasswordHash != "" && row.Password != "":
+		return errors.New("only one of password_hash and password can be set")
+	case row.PasswordHash != "":
+		if _, _, _, ok := parsePasswordHash(row.PasswordHash); !ok {
+			return errors.New("password_hash is not an Argon2id hash in the PHC string format")
+		}
+	case row.Password != "":
+		return s.passwords.Check(ctx, row.Password, row.Email, row.Username)
+	}
+	return nil
+}
+
+// createUser creates the user of a row. A user without a password gets a
+// random one and a reset link, so they choose their own before signing in.
+func (s *importService) createUser(ctx context.Context, row ImportRow, dryRun bool) (*database.User, error) {
+	user := &database.User{
+		Email:     row.Email,
+		Username:  row.Username,
+		FirstName: row.FirstName,
+		LastName:  row.LastName,
+		Role:      row.Role,
+		Locale:    row.Locale,
+		TimeZone:  row.TimeZone,
+		Status:    UserStatusActive,
+	}
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
+	if dryRun {
+		return user, nil
+	}
+
+	password, err := s.passwordHash(row)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = password
+
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	err = s.eventBus.Publish(ctx, &events.Event{
+		Type:    events.UserCreated,
+		Source:  "user-service",
+		Subject: user.ID,
+		Data: map[string]interface{}{
+			"user_id":    user.ID,
+			"email":      user.Email,
+			"username":   user.Username,
+			"first_name": user.FirstName,
+			"last_name":  user.LastName,
+			"role":       user.Role,
+			"imported":   true,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to publish creation of imported user %s: %v", user.ID, err)
+	}
+
+	if row.PasswordHash == "" && row.Password == "" {
+		if _, err := requirePasswordReset(ctx, s.userRepo, s.eventBus, user); err != nil {
+			log.Printf("Failed to send imported user %s a password reset link: %v", user.ID, err)
+		}
+	}
+
+	user.Password = ""
+	return user, nil
+}
+
+// updateUser overwrites a user's profile with the non-empty fields of a row
+func (s *importService) updateUser(ctx context.Context, user *database.User, row ImportRow, dryRun bool) error {
+	switch {
+	case user.Status == UserStatusDeleted:
+		return errors.New("user was deleted")
+	case user.Role == RoleAdmin:
+		return errors.New("admins cannot be updated by an import")
+	}
+
+	setIfNotEmpty(&user.Username, row.Username)
+	setIfNotEmpty(&user.FirstName, row.FirstName)
+	setIfNotEmpty(&user.LastName, row.LastName)
+	setIfNotEmpty(&user.Role, row.Role)
+	setIfNotEmpty(&user.Locale, row.Locale)
+	setIfNotEmpty(&user.TimeZone, row.TimeZone)
+	if dryRun {
+		return nil
+	}
+
+	if row.PasswordHash != "" || row.Password != "" {
+		password, err := s.passwordHash(row)
+		if err != nil {
+			return err
+		}
+		user.Password = password
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	user.Password = ""
+	return nil
+}
+
+// setIfNotEmpty sets field to value unless value is empty
+func setIfNotEmpty(field *string, value string) {
+	if value != "" {
+		*field = value
+	}
+}
+
+// passwordHash returns the hash to store for a row: its pre-hashed password,
+// its plaintext password hashed, or a random password hashed
+func (s *importService) passwordHash(row ImportRow) (string, error) {
+	if row.PasswordHash != "" {
+		return row.PasswordHash, nil
+	}
+
+	password := row.Password
+	if password == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		password = base64.RawURLEncoding.EncodeToString(buf)
+	}
+
+	hash, err := s.argon2.hash(password)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %v", err)
+	}
+	return hash, nil
+}
+
+// parseImport reads the rows of an import file
+func parseImport(data []byte, format string) ([]ImportRow, error) {
+	var rows []ImportRow
+	var err error
+	switch format {
+	case ImportFormatCSV:
+		rows, err = parseImportCSV(data)
+	case ImportFormatNDJSON:
+		rows, err = parseImportNDJSON(data)
+	default:
+		return nil, fmt.Errorf("invalid import format: %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("import file has no rows")
+	}
+	if len(rows) > MaxImportRows {
+		return nil, fmt.Errorf("import file has %d rows, at most %d are allowed", len(rows), MaxImportRows)
+	}
+	return rows, nil
+}
+
+// parseImportCSV reads CSV rows, naming their fields by the header row.
+// Unknown columns are rejected, so a misspelled column is not silently
+// dropped.
+func parseImportCSV(data []byte) ([]ImportRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+
+		fields := make(map[string]string, len(header))
+		for i, column := range header {
+			fields[strings.ToLower(strings.TrimSpace(column))] = record[i]
+		}
+		encoded, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		row, err := decodeImportRow(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV row %d: %v", len(rows)+1, err)
+		}
+		rows = append(rows, row)
+		if len(rows) > MaxImportRows {
+			break
+		}
+	}
+	return rows, nil
+}
+
+// parseImportNDJSON reads one row per non-blank line
+func parseImportNDJSON(data []byte) ([]ImportRow, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var rows []ImportRow
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		row, err := decodeImportRow(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NDJSON row %d: %v", len(rows)+1, err)
+		}
+		rows = append(rows, row)
+		if len(rows) > MaxImportRows {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid NDJSON: %v", err)
+	}
+	return rows, nil
+}
+
+// decodeImportRow decodes a row from JSON, rejecting unknown fields
+func decodeImportRow(data []byte) (ImportRow, error) {
+	var row ImportRow
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&row); err != nil {
+		return ImportRow{}, err
+	}
+	return row, nil
+}
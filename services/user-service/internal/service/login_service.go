@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/geoip"
+	"microservices-platform/services/user-service/internal/config"
+	"microservices-platform/services/user-service/internal/database"
+	"microservices-platform/services/user-service/internal/repository"
+)
+
+// LoginClient describes the client a sign-in came from
+type LoginClient struct {
+	IPAddress string
+	UserAgent string
+	// DeviceID is an identifier the client keeps for its device, if it sends one
+	DeviceID string
+}
+
+// LoginService interface defines sign-in history and alert operations
+type LoginService interface {
+	// RecordLogin records a successful sign-in and alerts the user if it came
+	// from a new device or from impossibly far away from the previous one
+	RecordLogin(ctx context.Context, user *database.User, client LoginClient) (*database.LoginEvent, error)
+	// SecureAccount acts on the "secure my account" link of an alert
+	SecureAccount(ctx context.Context, token string) error
+}
+
+// minTravelDistanceKm is the distance under which two sign-ins are never
+// impossible travel. IP geolocation is only accurate to a region, and mobile
+// and VPN addresses move between nearby cities.
+const minTravelDistanceKm = 500
+
+// loginService implements LoginService interface
+type loginService struct {
+	loginRepo   repository.LoginRepository
+	userRepo    repository.UserRepository
+	locator     geoip.Locator
+	eventBus    events.EventBus
+	travelSpeed float64
+	secureTTL   time.Duration
+}
+
+// NewLoginService creates a new login service. locator may be nil, in which
+// case impossible travel is not detected. eventBus may be nil, in which case
+// suspicious sign-ins are recorded but not alerted and accounts cannot be
+// secured, since the password reset link cannot be delivered.
+func NewLoginService(loginRepo repository.LoginRepository, userRepo repository.UserRepository, locator geoip.Locator, eventBus events.EventBus, cfg *config.Config) LoginService {
+	return &loginService{
+		loginRepo:   loginRepo,
+		userRepo:    userRepo,
+		locator:     locator,
+		eventBus:    eventBus,
+		travelSpeed: cfg.ImpossibleTravelSpeed,
+		secureTTL:   cfg.SecureAccountTTL,
+	}
+}
+
+// RecordLogin records a sign-in and its device. A sign-in is suspicious if it
+// came from a device the user has not used before, other than their first, or
+// from further away from the previous located sign-in than could have been
+// travelled since. The user is sent an alert with a single-use "secure my
+// account" link.
+func (s *loginService) RecordLogin(ctx context.Context, user *database.User, client LoginClient) (*database.LoginEvent, error) {
+	now := time.Now()
+	login := &database.LoginEvent{
+		UserID:            user.ID,
+		DeviceFingerprint: deviceFingerprint(client),
+		IPAddress:         client.IPAddress,
+		UserAgent:         client.UserAgent,
+	}
+
+	device, err := s.loginRepo.GetDevice(ctx, user.ID, login.DeviceFingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if device == nil {
+		devices, err := s.loginRepo.CountDevices(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		login.NewDevice = devices > 0
+	}
+
+	var previous *database.LoginEvent
+	var distance float64
+	if s.locator != nil && client.IPAddress != "" {
+		location, err := s.locator.Locate(ctx, client.IPAddress)
+		if err != nil {
+			// Locating is best effort; the sign-in is still checked for a new device
+			log.Printf("Failed to locate sign-in address %s: %v", client.IPAddress, err)
+		}
+		if location != nil {
+			previous, err = s.loginRepo.LastLocatedEvent(ctx, user.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			login.Located = true
+			login.Country = location.Country
+			login.City = location.City
+			login.Latitude = location.Latitude
+			login.Longitude = location.Longitude
+
+			if previous != nil {
+				distance = geoip.DistanceKm(loginLocation(previous), *location)
+				login.ImpossibleTravel = s.impossibleTravel(distance, now.Sub(time.Unix(previous.CreatedAt, 0)))
+			}
+		}
+	}
+
+	var token string
+	var expiresAt time.Time
+	if login.NewDevice || login.ImpossibleTravel {
+		token, login.SecureTokenHash, err = newResetToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate secure account token: %v", err)
+		}
+		expiresAt = now.Add(s.secureTTL)
+		login.SecureTokenExpiresAt = expiresAt.Unix()
+	}
+
+	if err := s.loginRepo.CreateEvent(ctx, login); err != nil {
+		return nil, err
+	}
+	err = s.loginRepo.SaveDevice(ctx, &database.KnownDevice{
+		UserID:      user.ID,
+		Fingerprint: login.DeviceFingerprint,
+		UserAgent:   client.UserAgent,
+		LastIP:      client.IPAddress,
+		LastSeenAt:  now.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if token == "" {
+		return login, nil
+	}
+
+	var reasons []string
+	if login.NewDevice {
+		reasons = append(reasons, events.LoginReasonNewDevice)
+	}
+	if login.ImpossibleTravel {
+		reasons = append(reasons, events.LoginReasonImpossibleTravel)
+	}
+	log.Printf("SECURITY suspicious login: user=%s login=%s reasons=%s ip=%s country=%s distance_km=%.0f",
+		user.ID, login.ID, strings.Join(reasons, ","), client.IPAddress, login.Country, distance)
+
+	if s.eventBus == nil {
+		return login, nil
+	}
+
+	report := &events.SuspiciousLoginReport{
+		UserID:               user.ID,
+		Email:                user.Email,
+		LoginID:              login.ID,
+		Reasons:              reasons,
+		IPAddress:            client.IPAddress,
+		UserAgent:            client.UserAgent,
+		Country:              login.Country,
+		City:                 login.City,
+		SecureToken:          token,
+		SecureTokenExpiresAt: expiresAt,
+		LoggedInAt:           now,
+	}
+	if login.ImpossibleTravel {
+		report.PreviousCountry = previous.Country
+		report.PreviousCity = previous.City
+		report.DistanceKm = distance
+	}
+	if err := s.eventBus.Publish(ctx, events.NewSuspiciousLoginEvent("user-service", report)); err != nil {
+		return login, fmt.Errorf("failed to publish suspicious login alert: %v", err)
+	}
+	return login, nil
+}
+
+// SecureAccount is the "secure my account" link of a suspicious sign-in
+// alert. The user must set a new password, delivered as for a forced reset,
+// before signing in again, and the device of the sign-in is forgotten so the
+// next sign-in from it alerts again. Issued tokens stay valid until they
+// expire.
+func (s *loginService) SecureAccount(ctx context.Context, token string) error {
+	if token == "" {
+		return errors.New("token is required")
+	}
+	if s.eventBus == nil {
+		return errors.New("password reset delivery is not available")
+	}
+
+	login, err := s.loginRepo.GetEventBySecureToken(ctx, hashResetToken(token))
+	if err != nil {
+		return err
+	}
+	if login == nil || login.SecuredAt != 0 || time.Now().Unix() > login.SecureTokenExpiresAt {
+		return errors.New("invalid or expired token")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, login.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("invalid or expired token")
+	}
+
+	if err := s.loginRepo.DeleteDevice(ctx, user.ID, login.DeviceFingerprint); err != nil {
+		return err
+	}
+	if _, err := requirePasswordReset(ctx, s.userRepo, s.eventBus, user); err != nil {
+		return err
+	}
+
+	login.SecuredAt = time.Now().Unix()
+	login.SecureTokenHash = ""
+	if err := s.loginRepo.UpdateEvent(ctx, login); err != nil {
+		return err
+	}
+
+	log.Printf("AUDIT account secured: user=%s login=%s", user.ID, login.ID)
+	return nil
+}
+
+// impossibleTravel reports whether two sign-ins distance apart and elapsed
+// time apart imply travelling faster than the configured speed
+func (s *loginService) impossibleTravel(distance float64, elapsed time.Duration) bool {
+	if s.travelSpeed <= 0 || distance < minTravelDistanceKm {
+		return false
+	}
+	if elapsed <= 0 {
+		return true
+	}
+	return distance/elapsed.Hours() > s.travelSpeed
+}
+
+// deviceFingerprint identifies the device of a sign-in by the device ID its
+// client keeps or, without one, by its user agent
+func deviceFingerprint(client LoginClient) string {
+	source := "ua:" + client.UserAgent
+	if client.DeviceID != "" {
+		source = "id:" + client.DeviceID
+	}
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// loginLocation returns where a located sign-in came from
+func loginLocation(login *database.LoginEvent) geoip.Location {
+	return geoip.Location{
+		Country:   login.Country,
+		City:      login.City,
+		Latitude:  login.Latitude,
+		Longitude: login.Longitude,
+	}
+}
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"microservices-platform/services/user-service/internal/config"
+	"microservices-platform/services/user-service/internal/database"
+	"microservices-platform/services/user-service/internal/repository"
+)
+
+// Organization roles. Owners manage members and invitations; members share
+// the organization's resources.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleMember = "member"
+)
+
+// slugPattern restricts organization slugs to URL-safe identifiers
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+
+// slugInvalidChars matches runs of characters replaced when deriving a slug
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// OrganizationService interface defines organization and team operations.
+// userID is always the acting user.
+type OrganizationService interface {
+	CreateOrganization(ctx context.Context, userID, name, slug string) (*database.Organization, error)
+	GetOrganization(ctx context.Context, userID, organizationID string) (*database.Organization, error)
+	ListOrganizations(ctx context.Context, userID string) ([]*database.Organization, error)
+	ListMembers(ctx context.Context, userID, organizationID string) ([]*database.Membership, error)
+	UpdateMemberRole(ctx context.Context, userID, organizationID, memberUserID, role string) (*database.Membership, error)
+	RemoveMember(ctx context.Context, userID, organizationID, memberUserID string) error
+	// InviteMember creates an invitation and returns it with its token. The
+	// token is only returned here.
+	InviteMember(ctx context.Context, userID, organizationID, email, role string) (*database.Invitation, string, error)
+	ListInvitations(ctx context.Context, userID, organizationID string) ([]*database.Invitation, error)
+	RevokeInvitation(ctx context.Context, userID, organizationID, invitationID string) error
+	AcceptInvitation(ctx context.Context, userID, token string) (*database.Membership, error)
+	// GetMembership returns a user's membership in an organization, or nil if
+	// they are not a member. Other services use it to authorize org-scoped
+	// resources.
+	GetMembership(ctx context.Context, organizationID, userID string) (*database.Membership, error)
+}
+
+// organizationService implements OrganizationService interface
+type organizationService struct {
+	orgRepo       repository.OrganizationRepository
+	userRepo      repository.UserRepository
+	invitationTTL time.Duration
+}
+
+// NewOrganizationService creates a new organization service
+func NewOrganizationService(orgRepo repository.OrganizationRepository, userRepo repository.UserRepository, cfg *config.Config) OrganizationService {
+	return &organizationService{
+		orgRepo:       orgRepo,
+		userRepo:      userRepo,
+		invitationTTL: cfg.InvitationTTL,
+	}
+}
+
+// CreateOrganization creates an organization owned by the acting user. The
+// slug is derived from the name when empty.
+func (s *organizationService) CreateOrganization(ctx context.Context, userID, name, slug string) (*database.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("organization name is required")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	if slug == "" {
+		slug = strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	}
+	if !slugPattern.MatchString(slug) {
+		return nil, errors.New("organization slug must be 2-63 lowercase letters, digits or '-'")
+	}
+
+	existing, err := s.orgRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("organization slug %q is taken", slug)
+	}
+
+	organization := &database.Organization{
+		Name:      name,
+		Slug:      slug,
+		CreatedBy: userID,
+	}
+	owner := &database.Membership{
+		UserID: userID,
+		Role:   OrgRoleOwner,
+	}
+	if err := s.orgRepo.Create(ctx, organization, owner); err != nil {
+		return nil, err
+	}
+
+	return organization, nil
+}
+
+// GetOrganization retrieves an organization the acting user is a member of
+func (s *organizationService) GetOrganization(ctx context.Context, userID, organizationID string) (*database.Organization, error) {
+	if _, err := s.requireRole(ctx, organizationID, userID, OrgRoleMember); err != nil {
+		return nil, err
+	}
+
+	organization, err := s.orgRepo.GetByID(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if organization == nil {
+		return nil, errors.New("organization not found")
+	}
+
+	return organization, nil
+}
+
+// ListOrganizations lists the organizations the acting user is a member of
+func (s *organizationService) ListOrganizations(ctx context.Context, userID string) ([]*database.Organization, error) {
+	return s.orgRepo.ListByUserID(ctx, userID)
+}
+
+// ListMembers lists an organization's members
+func (s *organizationService) ListMembers(ctx context.Context, userID, organizationID string) ([]*database.Membership, error) {
+	if _, err := s.requireRole(ctx, organizationID, userID, OrgRoleMember); err != nil {
+		return nil, err
+	}
+
+	return s.orgRepo.ListMemberships(ctx, organizationID)
+}
+
+// UpdateMemberRole changes a member's role. Only owners can change roles,
+// and the last owner cannot be demoted.
+func (s *organizationService) UpdateMemberRole(ctx context.Context, userID, organizationID, memberUserID, role string) (*database.Membership, error) {
+	if role != OrgRoleOwner && role != OrgRoleMember {
+		return nil, fmt.Errorf("unknown organization role %q", role)
+	}
+	if _, err := s.requireRole(ctx, organizationID, userID, OrgRoleOwner); err != nil {
+		return nil, err
+	}
+
+	membership, err := s.orgRepo.GetMembership(ctx, organizationID, memberUserID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, errors.New("member not found")
+	}
+	if membership.Role == role {
+		return membership, nil
+	}
+
+	if membership.Role == OrgRoleOwner {
+		if err := s.ensureAnotherOwner(ctx, organizationID); err != nil {
+			return nil, err
+		}
+	}
+
+	membership.Role = role
+	if err := s.orgRepo.UpdateMembership(ctx, membership); err != nil {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+// RemoveMember removes a member from an organization. Owners can remove
+// anyone and members can leave; the last owner cannot leave.
+func (s *organizationService) RemoveMember(ctx context.Context, userID, organizationID, memberUserID string) error {
+	requiredRole := OrgRoleOwner
+	if memberUserID == userID {
+		requiredRole = OrgRoleMember
+	}
+	if _, err := s.requireRole(ctx, organizationID, userID, requiredRole); err != nil {
+		return err
+	}
+
+	membership, err := s.orgRepo.GetMembership(ctx, organizationID, memberUserID)
+	if err != nil {
+		return err
+	}
+	if membership == nil {
+		return errors.New("member not found")
+	}
+
+	if membership.Role == OrgRoleOwner {
+		if err := s.ensureAnotherOwner(ctx, organizationID); err != nil {
+			return err
+		}
+	}
+
+	return s.orgRepo.DeleteMembership(ctx, organizationID, memberUserID)
+}
+
+// InviteMember invites an email address to join an organization
+func (s *organizationService) InviteMember(ctx context.Context, userID, organizationID, email, role string) (*database.Invitation, string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") {
+		return nil, "", errors.New("a valid email address is required")
+	}
+	if role == "" {
+		role = OrgRoleMember
+	}
+	if role != OrgRoleOwner && role != OrgRoleMember {
+		return nil, "", fmt.Errorf("unknown organization role %q", role)
+	}
+	if _, err := s.requireRole(ctx, organizationID, userID, OrgRoleOwner); err != nil {
+		return nil, "", err
+	}
+
+	token, tokenHash, err := newResetToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate invitation token: %v", err)
+	}
+
+	invitation := &database.Invitation{
+		OrganizationID: organizationID,
+		Email:          email,
+		Role:           role,
+		TokenHash:      tokenHash,
+		InvitedBy:      userID,
+		ExpiresAt:      time.Now().Add(s.invitationTTL).Unix(),
+	}
+	if err := s.orgRepo.CreateInvitation(ctx, invitation); err != nil {
+		return nil, "", err
+	}
+
+	return invitation, token, nil
+}
+
+// ListInvitations lists an organization's pending invitations
+func (s *organizationService) ListInvitations(ctx context.Context, userID, organizationID string) ([]*database.Invitation, error) {
+	if _, err := s.requireRole(ctx, organizationID, userID, OrgRoleOwner); err != nil {
+		return nil, err
+	}
+
+	return s.orgRepo.ListPendingInvitations(ctx, organizationID, time.Now().Unix())
+}
+
+// RevokeInvitation deletes a pending invitation
+func (s *organizationService) RevokeInvitation(ctx context.Context, userID, organizationID, invitationID string) error {
+	if _, err := s.requireRole(ctx, organizationID, userID, OrgRoleOwner); err != nil {
+		return err
+	}
+
+	invitation, err := s.orgRepo.GetInvitationByID(ctx, invitationID)
+	if err != nil {
+		return err
+	}
+	if invitation == nil || invitation.OrganizationID != organizationID {
+		return errors.New("invitation not found")
+	}
+	if invitation.AcceptedAt != 0 {
+		return errors.New("invitation was already accepted")
+	}
+
+	return s.orgRepo.DeleteInvitation(ctx, invitationID)
+}
+
+// AcceptInvitation adds the acting user to the inviting organization. The
+// invitation must be unexpired and addressed to the user's email.
+func (s *organizationService) AcceptInvitation(ctx context.Context, userID, token string) (*database.Membership, error) {
+	if token == "" {
+		return nil, errors.New("invitation token is required")
+	}
+
+	invitation, err := s.orgRepo.GetInvitationByTokenHash(ctx, hashResetToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil || invitation.AcceptedAt != 0 || time.Now().Unix() > invitation.ExpiresAt {
+		return nil, errors.New("invalid or expired invitation")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return nil, errors.New("invitation was sent to a different email address")
+	}
+
+	existing, err := s.orgRepo.GetMembership(ctx, invitation.OrganizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errors.New("user is already a member of the organization")
+	}
+
+	invitation.AcceptedAt = time.Now().Unix()
+	invitation.AcceptedBy = userID
+	membership := &database.Membership{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         userID,
+		Role:           invitation.Role,
+	}
+	if err := s.orgRepo.AcceptInvitation(ctx, invitation, membership); err != nil {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+// GetMembership returns a user's membership in an organization
+func (s *organizationService) GetMembership(ctx context.Context, organizationID, userID string) (*database.Membership, error) {
+	return s.orgRepo.GetMembership(ctx, organizationID, userID)
+}
+
+// requireRole returns the user's membership if it has at least the given
+// role; owners have every member permission
+func (s *organizationService) requireRole(ctx context.Context, organizationID, userID, role string) (*database.Membership, error) {
+	membership, err := s.orgRepo.GetMembership(ctx, organizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, errors.New("organization not found")
+	}
+	if role == OrgRoleOwner && membership.Role != OrgRoleOwner {
+		return nil, errors.New("only organization owners can do this")
+	}
+	return membership, nil
+}
+
+// ensureAnotherOwner fails if removing or demoting one owner would leave the
+// organization without any
+func (s *organizationService) ensureAnotherOwner(ctx context.Context, organizationID string) error {
+	owners, err := s.orgRepo.CountMembershipsByRole(ctx, organizationID, OrgRoleOwner)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return errors.New("an organization must keep at least one owner")
+	}
+	return nil
+}
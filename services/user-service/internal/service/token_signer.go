@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	baseconfig "microservices-platform/pkg/config"
+	"microservices-platform/pkg/middleware"
+	"microservices-platform/services/user-service/internal/database"
+)
+
+// TokenSigner signs the access and impersonation tokens user-service issues,
+// after enriching their claims
+type TokenSigner struct {
+	method     jwt.SigningMethod
+	key        interface{}
+	expiration time.Duration
+	enrichers  []ClaimsEnricher
+}
+
+// NewTokenSigner returns the signer configured in cfg: HS256 with the JWT
+// secret or RS256 with the RSA private key file. It refuses placeholder
+// secrets in production.
+func NewTokenSigner(cfg baseconfig.SecurityConfig, environment string) (*TokenSigner, error) {
+	if err := cfg.ValidateJWT(environment); err != nil {
+		return nil, err
+	}
+
+	signer := &TokenSigner{expiration: cfg.JWTExpiration}
+	switch cfg.JWTAlgorithm {
+	case baseconfig.JWTAlgorithmRS256:
+		pem, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT private key: %v", err)
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT private key: %v", err)
+		}
+		signer.method, signer.key = jwt.SigningMethodRS256, key
+	default:
+		signer.method, signer.key = jwt.SigningMethodHS256, []byte(cfg.JWTSecret)
+	}
+	return signer, nil
+}
+
+// Use registers claim enrichers, which run in the order they are registered.
+// It must be called before tokens are issued.
+func (t *TokenSigner) Use(enrichers ...ClaimsEnricher) {
+	t.enrichers = append(t.enrichers, enrichers...)
+}
+
+// Issue enriches the claims of a token for user and signs them
+func (t *TokenSigner) Issue(ctx context.Context, user *database.User, claims *middleware.Claims) (string, error) {
+	for _, enrich := range t.enrichers {
+		if err := enrich(ctx, user, claims); err != nil {
+			return "", fmt.Errorf("failed to enrich token claims: %v", err)
+		}
+	}
+	return t.Sign(claims)
+}
+
+// Sign signs claims as they are
+func (t *TokenSigner) Sign(claims jwt.Claims) (string, error) {
+	return jwt.NewWithClaims(t.method, claims).SignedString(t.key)
+}
+
+// Expiration is the lifetime of access tokens
+func (t *TokenSigner) Expiration() time.Duration {
+	return t.expiration
+}
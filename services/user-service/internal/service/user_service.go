@@ -1,17 +1,26 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/argon2"
+	baseconfig "microservices-platform/pkg/config"
+	"microservices-platform/pkg/middleware"
+	"microservices-platform/pkg/passwordpolicy"
+	"microservices-platform/pkg/storage"
+	"microservices-platform/pkg/versioning"
 	"microservices-platform/services/user-service/internal/database"
 	"microservices-platform/services/user-service/internal/repository"
 )
@@ -20,23 +29,70 @@ import (
 type UserService interface {
 	CreateUser(ctx context.Context, email, username, password, firstName, lastName string) (*database.User, error)
 	GetUser(ctx context.Context, id string) (*database.User, error)
-	UpdateUser(ctx context.Context, id, email, username, firstName, lastName, status string) (*database.User, error)
-	DeleteUser(ctx context.Context, id string) error
+	// UpdateUser updates a user's profile if its version satisfies match
+	UpdateUser(ctx context.Context, id string, update UserUpdate, match *versioning.Precondition) (*database.User, error)
 	ListUsers(ctx context.Context, page, pageSize int, filter string) ([]*database.User, int64, error)
+	// StreamUsers passes all users ListUsers would list to fn, batchSize at
+	// a time
+	StreamUsers(ctx context.Context, filter string, batchSize int, fn func([]*database.User) error) error
 	AuthenticateUser(ctx context.Context, email, password string) (*database.User, string, error)
+	UploadAvatar(ctx context.Context, id string, data []byte, contentType string) (*database.User, map[string]string, error)
+	ImpersonateUser(ctx context.Context, adminID, userID, reason string, ttl time.Duration) (*database.User, string, *database.ImpersonationSession, error)
+	ResetPassword(ctx context.Context, token, newPassword string) error
 }
 
+// User roles, from least to most privileged
+const (
+	RoleUser    = "user"
+	RolePartner = "partner"
+	// RoleAdmin is the role required to impersonate other users
+	RoleAdmin = "admin"
+)
+
+// User statuses
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+)
+
+const (
+	// PasswordResetTTL is how long a password reset token stays valid
+	PasswordResetTTL = 24 * time.Hour
+
+	// MaxImpersonationTTL caps the lifetime of impersonation tokens
+	MaxImpersonationTTL = 30 * time.Minute
+	// DefaultImpersonationTTL is used when no lifetime is requested
+	DefaultImpersonationTTL = 15 * time.Minute
+)
+
+const (
+	// defaultStreamBatchSize is the batch size of streamed user lists when
+	// none is requested
+	defaultStreamBatchSize = 500
+	// maxStreamBatchSize caps the users read from the database at a time
+	maxStreamBatchSize = 1000
+)
+
 // userService implements UserService interface
 type userService struct {
-	userRepo  repository.UserRepository
-	jwtSecret string
+	userRepo    repository.UserRepository
+	avatarStore storage.ObjectStore
+	passwords   *passwordpolicy.Policy
+	argon2      Argon2Params
+	tokens      *TokenSigner
 }
 
-// NewUserService creates a new user service
-func NewUserService(userRepo repository.UserRepository) UserService {
+// NewUserService creates a new user service. avatarStore may be nil, in which
+// case avatar uploads are rejected. New passwords must meet passwords and are
+// hashed with hashing; older hashes are rehashed as their users sign in.
+// Tokens are signed with tokens.
+func NewUserService(userRepo repository.UserRepository, avatarStore storage.ObjectStore, passwords *passwordpolicy.Policy, hashing Argon2Params, tokens *TokenSigner) UserService {
 	return &userService{
-		userRepo:  userRepo,
-		jwtSecret: "your-secret-key", // In production, this should come from config
+		userRepo:    userRepo,
+		avatarStore: avatarStore,
+		passwords:   passwords,
+		argon2:      hashing,
+		tokens:      tokens,
 	}
 }
 
@@ -51,6 +107,10 @@ func (s *userService) CreateUser(ctx context.Context, email, username, password,
 		return nil, errors.New("user with this email already exists")
 	}
 
+	if err := s.passwords.Check(ctx, password, email, username); err != nil {
+		return nil, err
+	}
+
 	// Hash password
 	hashedPassword, err := s.hashPassword(password)
 	if err != nil {
@@ -91,8 +151,25 @@ func (s *userService) GetUser(ctx context.Context, id string) (*database.User, e
 	return user, nil
 }
 
+// UserUpdate holds the profile fields to change. Nil fields are left
+// unchanged; a field set to its zero value is cleared.
+type UserUpdate struct {
+	Email     *string
+	Username  *string
+	FirstName *string
+	LastName  *string
+	Status    *string
+	// MarketingOptOut records whether the user declines promotional
+	// notifications
+	MarketingOptOut *bool
+	// Locale and TimeZone are how the user's dates are formatted, e.g.
+	// "de-DE" and "Europe/Berlin"
+	Locale   *string
+	TimeZone *string
+}
+
 // UpdateUser updates user information
-func (s *userService) UpdateUser(ctx context.Context, id, email, username, firstName, lastName, status string) (*database.User, error) {
+func (s *userService) UpdateUser(ctx context.Context, id string, update UserUpdate, match *versioning.Precondition) (*database.User, error) {
 	user, err := s.userRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
@@ -100,22 +177,34 @@ func (s *userService) UpdateUser(ctx context.Context, id, email, username, first
 	if user == nil {
 		return nil, errors.New("user not found")
 	}
+	if !match.Matches(user.Version) {
+		return nil, versioning.ErrConflict
+	}
 
 	// Update fields
-	if email != "" {
-		user.Email = email
+	if update.Email != nil {
+		user.Email = *update.Email
+	}
+	if update.Username != nil {
+		user.Username = *update.Username
 	}
-	if username != "" {
-		user.Username = username
+	if update.FirstName != nil {
+		user.FirstName = *update.FirstName
 	}
-	if firstName != "" {
-		user.FirstName = firstName
+	if update.LastName != nil {
+		user.LastName = *update.LastName
 	}
-	if lastName != "" {
-		user.LastName = lastName
+	if update.Status != nil {
+		user.Status = *update.Status
 	}
-	if status != "" {
-		user.Status = status
+	if update.MarketingOptOut != nil {
+		user.MarketingOptOut = *update.MarketingOptOut
+	}
+	if update.Locale != nil {
+		user.Locale = *update.Locale
+	}
+	if update.TimeZone != nil {
+		user.TimeZone = *update.TimeZone
 	}
 
 	err = s.userRepo.Update(ctx, user)
@@ -128,19 +217,6 @@ func (s *userService) UpdateUser(ctx context.Context, id, email, username, first
 	return user, nil
 }
 
-// DeleteUser deletes a user
-func (s *userService) DeleteUser(ctx context.Context, id string) error {
-	user, err := s.userRepo.GetByID(ctx, id)
-	if err != nil {
-		return err
-	}
-	if user == nil {
-		return errors.New("user not found")
-	}
-
-	return s.userRepo.Delete(ctx, id)
-}
-
 // ListUsers lists users with pagination and filtering
 func (s *userService) ListUsers(ctx context.Context, page, pageSize int, filter string) ([]*database.User, int64, error) {
 	offset := (page - 1) * pageSize
@@ -157,6 +233,24 @@ func (s *userService) ListUsers(ctx context.Context, page, pageSize int, filter
 	return users, total, nil
 }
 
+// StreamUsers passes the users matching a filter to fn in batches
+func (s *userService) StreamUsers(ctx context.Context, filter string, batchSize int, fn func([]*database.User) error) error {
+	if batchSize <= 0 {
+		batchSize = defaultStreamBatchSize
+	}
+	if batchSize > maxStreamBatchSize {
+		batchSize = maxStreamBatchSize
+	}
+
+	return s.userRepo.Stream(ctx, repository.UserFilter{Search: filter}, batchSize, func(users []*database.User) error {
+		// Don't return password hashes
+		for _, user := range users {
+			user.Password = ""
+		}
+		return fn(users)
+	})
+}
+
 // AuthenticateUser authenticates user with email and password
 func (s *userService) AuthenticateUser(ctx context.Context, email, password string) (*database.User, string, error) {
 	user, err := s.userRepo.GetByEmail(ctx, email)
@@ -168,12 +262,31 @@ func (s *userService) AuthenticateUser(ctx context.Context, email, password stri
 	}
 
 	// Verify password
-	if !s.verifyPassword(password, user.Password) {
+	ok, outdated := s.verifyPassword(password, user.Password)
+	if !ok {
 		return nil, "", errors.New("invalid credentials")
 	}
 
+	// Account controls are only revealed once the password is verified
+	if user.Status == UserStatusSuspended {
+		return nil, "", errors.New("account is suspended")
+	}
+	if user.PasswordResetRequired {
+		return nil, "", errors.New("password reset required")
+	}
+
+	// Bring the hash up to the current parameters while the password is at
+	// hand. Failing to is harmless: the old hash still verifies.
+	if outdated {
+		if rehashed, err := s.hashPassword(password); err != nil {
+			log.Printf("Failed to rehash password of user %s: %v", user.ID, err)
+		} else if err := s.userRepo.UpdatePasswordHash(ctx, user.ID, user.Password, rehashed); err != nil {
+			log.Printf("Failed to store rehashed password of user %s: %v", user.ID, err)
+		}
+	}
+
 	// Generate JWT token
-	token, err := s.generateJWT(user.ID, user.Email)
+	token, err := s.generateJWT(ctx, user)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to generate token: %v", err)
 	}
@@ -183,52 +296,284 @@ func (s *userService) AuthenticateUser(ctx context.Context, email, password stri
 	return user, token, nil
 }
 
-// hashPassword hashes a password using Argon2
+// UploadAvatar resizes an uploaded image to the standard sizes, stores each
+// rendition and points the user's avatar URL at the medium rendition
+func (s *userService) UploadAvatar(ctx context.Context, id string, data []byte, contentType string) (*database.User, map[string]string, error) {
+	if s.avatarStore == nil {
+		return nil, nil, errors.New("avatar uploads are not configured")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, errors.New("user not found")
+	}
+
+	img, err := storage.DecodeImage(data, contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	urls := make(map[string]string, len(storage.StandardImageSizes))
+	for _, size := range storage.StandardImageSizes {
+		resized, err := storage.ResizeSquare(img, size)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		url, err := s.avatarStore.Put(ctx, storage.AvatarKey(user.ID, size), bytes.NewReader(resized), int64(len(resized)), "image/jpeg")
+		if err != nil {
+			return nil, nil, err
+		}
+		urls[size.Name] = url
+	}
+
+	// Bust client caches since the object keys are stable per user
+	user.AvatarURL = fmt.Sprintf("%s?v=%d", urls[storage.ImageSizeMedium.Name], time.Now().Unix())
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, nil, err
+	}
+
+	// Don't return password hash
+	user.Password = ""
+	return user, urls, nil
+}
+
+// ImpersonateUser issues a short-lived token that lets an admin act as another
+// user. The token carries an "act" claim identifying the admin so every
+// request made with it can be attributed and audited.
+func (s *userService) ImpersonateUser(ctx context.Context, adminID, userID, reason string, ttl time.Duration) (*database.User, string, *database.ImpersonationSession, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, "", nil, errors.New("a reason is required to impersonate a user")
+	}
+	if adminID == userID {
+		return nil, "", nil, errors.New("cannot impersonate yourself")
+	}
+	if ttl <= 0 {
+		ttl = DefaultImpersonationTTL
+	}
+	if ttl > MaxImpersonationTTL {
+		ttl = MaxImpersonationTTL
+	}
+
+	admin, err := s.userRepo.GetByID(ctx, adminID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if admin == nil || admin.Role != RoleAdmin || admin.Status != "active" {
+		return nil, "", nil, errors.New("permission denied: admin role required")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if user == nil {
+		return nil, "", nil, errors.New("user not found")
+	}
+	if user.Role == RoleAdmin {
+		return nil, "", nil, errors.New("permission denied: admins cannot be impersonated")
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	session := &database.ImpersonationSession{
+		AdminUserID: adminID,
+		UserID:      userID,
+		Reason:      reason,
+		ExpiresAt:   expiresAt.Unix(),
+	}
+	if err := s.userRepo.CreateImpersonationSession(ctx, session); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to record impersonation session: %v", err)
+	}
+
+	claims := &middleware.Claims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		Roles:     []string{user.Role},
+		Locale:    user.Locale,
+		TimeZone:  user.TimeZone,
+		Scope:     middleware.ScopeImpersonation,
+		SessionID: session.ID,
+		Actor:     &middleware.Actor{Subject: admin.ID, Email: admin.Email},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token, err := s.tokens.Issue(ctx, user, claims)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to generate token: %v", err)
+	}
+
+	log.Printf("AUDIT impersonation started: session=%s admin=%s user=%s expires=%s reason=%q",
+		session.ID, adminID, userID, expiresAt.UTC().Format(time.RFC3339), reason)
+
+	// Don't return password hash
+	user.Password = ""
+	return user, token, session, nil
+}
+
+// ResetPassword sets a new password using a reset token issued by an admin
+// forced reset, and invalidates the token
+func (s *userService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if token == "" || newPassword == "" {
+		return errors.New("token and new password are required")
+	}
+
+	user, err := s.userRepo.GetByPasswordResetToken(ctx, hashResetToken(token))
+	if err != nil {
+		return err
+	}
+	if user == nil || time.Now().Unix() > user.PasswordResetExpiresAt {
+		return errors.New("invalid or expired reset token")
+	}
+
+	if err := s.passwords.Check(ctx, newPassword, user.Email, user.Username); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	user.Password = hashedPassword
+	user.PasswordResetRequired = false
+	user.PasswordResetTokenHash = ""
+	user.PasswordResetExpiresAt = 0
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// newResetToken generates a random password reset token and the hash stored for it
+func newResetToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashResetToken(token), nil
+}
+
+// hashResetToken hashes a reset token for storage and lookup
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Argon2Params are the Argon2id parameters passwords are hashed with
+type Argon2Params struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// NewArgon2Params returns the hashing parameters configured in cfg, with
+// 16-byte salts and 32-byte keys
+func NewArgon2Params(cfg baseconfig.SecurityConfig) Argon2Params {
+	return Argon2Params{
+		Memory:      uint32(cfg.Argon2Memory),
+		Iterations:  uint32(cfg.Argon2Iterations),
+		Parallelism: uint8(cfg.Argon2Parallelism),
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// hashPassword hashes a password with the current parameters
 func (s *userService) hashPassword(password string) (string, error) {
-	salt := make([]byte, 16)
+	return s.argon2.hash(password)
+}
+
+// hash hashes a password using Argon2id, encoding the parameters in the PHC
+// string format: $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<hash>
+func (p Argon2Params) hash(password string) (string, error) {
+	salt := make([]byte, p.SaltLength)
 	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
 
-	hash := argon2.IDKey([]byte(password), salt, 1, 64*1024, 4, 32)
+	hash := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
 
 	b64Salt := base64.RawStdEncoding.EncodeToString(salt)
 	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
 
-	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, 64*1024, 1, 4, b64Salt, b64Hash), nil
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism, b64Salt, b64Hash), nil
+}
+
+// verifyPassword verifies a password against its hash, using the parameters
+// recorded in the hash. outdated reports whether the hash was made with
+// other parameters than the current ones, so it should be rehashed.
+func (s *userService) verifyPassword(password, encodedHash string) (ok, outdated bool) {
+	p, salt, hash, ok := parsePasswordHash(encodedHash)
+	if !ok {
+		return false, false
+	}
+
+	otherHash := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	if subtle.ConstantTimeCompare(hash, otherHash) != 1 {
+		return false, false
+	}
+	return true, p != s.argon2
 }
 
-// verifyPassword verifies a password against its hash
-func (s *userService) verifyPassword(password, encodedHash string) bool {
+// parsePasswordHash decodes an Argon2id hash in the PHC string format into
+// its parameters, salt and key. ok is false for anything else.
+func parsePasswordHash(encodedHash string) (p Argon2Params, salt, hash []byte, ok bool) {
 	parts := strings.Split(encodedHash, "$")
-	if len(parts) != 6 {
-		return false
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, false
 	}
 
-	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
-	if err != nil {
-		return false
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, false
 	}
 
-	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
-	if err != nil {
-		return false
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, false
+	}
+	if p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, false
 	}
 
-	otherHash := argon2.IDKey([]byte(password), salt, 1, 64*1024, 4, 32)
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, false
+	}
 
-	return subtle.ConstantTimeCompare(hash, otherHash) == 1
+	hash, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(hash) == 0 {
+		return p, nil, nil, false
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(hash))
+	return p, salt, hash, true
 }
 
 // generateJWT generates a JWT token for the user
-func (s *userService) generateJWT(userID, email string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"email":   email,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-		"iat":     time.Now().Unix(),
+func (s *userService) generateJWT(ctx context.Context, user *database.User) (string, error) {
+	now := time.Now()
+	claims := &middleware.Claims{
+		UserID:   user.ID,
+		Email:    user.Email,
+		Role:     user.Role,
+		Roles:    []string{user.Role},
+		Locale:   user.Locale,
+		TimeZone: user.TimeZone,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.tokens.Expiration())),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
+	return s.tokens.Issue(ctx, user, claims)
 }
\ No newline at end of file
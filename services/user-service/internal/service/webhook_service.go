@@ -0,0 +1,614 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"microservices-platform/pkg/events"
+	"microservices-platform/pkg/jwe"
+	"microservices-platform/services/user-service/internal/config"
+	"microservices-platform/services/user-service/internal/database"
+	"microservices-platform/services/user-service/internal/repository"
+)
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook request headers. The signature is "t=<unix>,v1=<hex>", where v1 is
+// the HMAC-SHA256 of "<unix>.<body>" keyed with the endpoint secret.
+const (
+	HeaderWebhookID        = "X-Webhook-Id"
+	HeaderWebhookEvent     = "X-Webhook-Event"
+	HeaderWebhookTimestamp = "X-Webhook-Timestamp"
+	HeaderWebhookSignature = "X-Webhook-Signature"
+)
+
+// Webhook payload formats. Platform payloads are the event JSON; CloudEvents
+// payloads follow the CloudEvents 1.0 HTTP binding in structured or binary mode.
+const (
+	WebhookFormatPlatform          = "platform"
+	WebhookFormatCloudEvents       = "cloudevents"
+	WebhookFormatCloudEventsBinary = "cloudevents-binary"
+)
+
+// webhookBatchSize is how many due deliveries are claimed per poll
+const webhookBatchSize = 50
+
+// maxWebhookEncryptionKeys is how many encryption keys an endpoint can hold,
+// enough for a rotation in progress plus a key to roll back to
+const maxWebhookEncryptionKeys = 5
+
+// WebhookService interface defines partner webhook operations
+type WebhookService interface {
+	CreateEndpoint(ctx context.Context, userID, endpointURL string, eventTypes []string, payloadFormat string) (*database.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, userID string) ([]*database.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, userID, endpointID string) error
+	ListDeliveries(ctx context.Context, userID, endpointID string, page, pageSize int) ([]*database.WebhookDelivery, int64, error)
+	ReplayDelivery(ctx context.Context, userID, endpointID, deliveryID string) (*database.WebhookDelivery, error)
+	// AddEncryptionKey registers a public key that the endpoint's payloads are
+	// encrypted to from then on
+	AddEncryptionKey(ctx context.Context, userID, endpointID, publicKey, keyID string) (*database.WebhookEncryptionKey, error)
+	ListEncryptionKeys(ctx context.Context, userID, endpointID string) ([]*database.WebhookEncryptionKey, error)
+	DeleteEncryptionKey(ctx context.Context, userID, endpointID, keyID string) error
+	// HandleEvent queues a delivery of the event to every endpoint subscribed to it
+	HandleEvent(ctx context.Context, event *events.Event) error
+	// Start runs the delivery worker until ctx is cancelled
+	Start(ctx context.Context)
+}
+
+// webhookService implements WebhookService interface
+type webhookService struct {
+	webhookRepo repository.WebhookRepository
+	userRepo    repository.UserRepository
+	client      *http.Client
+
+	eventTypes     map[string]bool
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	timeout        time.Duration
+	pollInterval   time.Duration
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(webhookRepo repository.WebhookRepository, userRepo repository.UserRepository, cfg *config.Config) WebhookService {
+	eventTypes := make(map[string]bool, len(cfg.WebhookEventTypes))
+	for _, eventType := range cfg.WebhookEventTypes {
+		eventTypes[eventType] = true
+	}
+
+	return &webhookService{
+		webhookRepo:    webhookRepo,
+		userRepo:       userRepo,
+		client:         newWebhookClient(cfg.WebhookTimeout),
+		eventTypes:     eventTypes,
+		maxAttempts:    cfg.WebhookMaxAttempts,
+		initialBackoff: cfg.WebhookInitialBackoff,
+		maxBackoff:     cfg.WebhookMaxBackoff,
+		timeout:        cfg.WebhookTimeout,
+		pollInterval:   cfg.WebhookPollInterval,
+	}
+}
+
+// CreateEndpoint registers a webhook endpoint for a partner. The generated
+// signing secret is only returned here.
+func (s *webhookService) CreateEndpoint(ctx context.Context, userID, endpointURL string, eventTypes []string, payloadFormat string) (*database.WebhookEndpoint, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	if user.Role != RolePartner && user.Role != RoleAdmin {
+		return nil, errors.New("only partners can register webhooks")
+	}
+
+	if err := validateWebhookURL(endpointURL); err != nil {
+		return nil, err
+	}
+
+	if len(eventTypes) == 0 {
+		return nil, errors.New("at least one event type is required")
+	}
+	for _, eventType := range eventTypes {
+		if !s.eventTypes[eventType] {
+			return nil, fmt.Errorf("event type %q is not available for webhooks", eventType)
+		}
+	}
+
+	switch payloadFormat {
+	case "":
+		payloadFormat = WebhookFormatPlatform
+	case WebhookFormatPlatform, WebhookFormatCloudEvents, WebhookFormatCloudEventsBinary:
+	default:
+		return nil, fmt.Errorf("unknown payload format %q", payloadFormat)
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := &database.WebhookEndpoint{
+		UserID:        userID,
+		URL:           endpointURL,
+		Secret:        secret,
+		EventTypes:    strings.Join(eventTypes, ","),
+		PayloadFormat: payloadFormat,
+		Active:        true,
+	}
+	if err := s.webhookRepo.CreateEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+// ListEndpoints lists a partner's webhook endpoints
+func (s *webhookService) ListEndpoints(ctx context.Context, userID string) ([]*database.WebhookEndpoint, error) {
+	return s.webhookRepo.ListEndpoints(ctx, userID)
+}
+
+// DeleteEndpoint removes a partner's webhook endpoint and its delivery log
+func (s *webhookService) DeleteEndpoint(ctx context.Context, userID, endpointID string) error {
+	if _, err := s.ownedEndpoint(ctx, userID, endpointID); err != nil {
+		return err
+	}
+	return s.webhookRepo.DeleteEndpoint(ctx, endpointID)
+}
+
+// ListDeliveries lists the delivery log of a partner's endpoint
+func (s *webhookService) ListDeliveries(ctx context.Context, userID, endpointID string, page, pageSize int) ([]*database.WebhookDelivery, int64, error) {
+	if _, err := s.ownedEndpoint(ctx, userID, endpointID); err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	return s.webhookRepo.ListDeliveries(ctx, endpointID, (page-1)*pageSize, pageSize)
+}
+
+// ReplayDelivery queues a new delivery with the same payload as an earlier
+// one. The original delivery and its log are left untouched.
+func (s *webhookService) ReplayDelivery(ctx context.Context, userID, endpointID, deliveryID string) (*database.WebhookDelivery, error) {
+	if _, err := s.ownedEndpoint(ctx, userID, endpointID); err != nil {
+		return nil, err
+	}
+
+	original, err := s.webhookRepo.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if original == nil || original.EndpointID != endpointID {
+		return nil, errors.New("delivery not found")
+	}
+
+	replay := &database.WebhookDelivery{
+		EndpointID:    endpointID,
+		EventID:       original.EventID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        WebhookDeliveryPending,
+		NextAttemptAt: time.Now().Unix(),
+		ReplayOf:      original.ID,
+	}
+	if err := s.webhookRepo.CreateDeliveries(ctx, []*database.WebhookDelivery{replay}); err != nil {
+		return nil, err
+	}
+
+	return replay, nil
+}
+
+// AddEncryptionKey registers an RSA public key, PEM or JWK, for an endpoint.
+// Payloads are encrypted to the newest key, so adding one rotates to it; the
+// key ID in each payload's JWE header tells the partner which private key to
+// decrypt with. keyID defaults to the JWK's "kid" or else the key's RFC 7638
+// thumbprint.
+func (s *webhookService) AddEncryptionKey(ctx context.Context, userID, endpointID, publicKey, keyID string) (*database.WebhookEncryptionKey, error) {
+	if _, err := s.ownedEndpoint(ctx, userID, endpointID); err != nil {
+		return nil, err
+	}
+
+	key, jwkKeyID, err := jwe.ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if keyID == "" {
+		keyID = jwkKeyID
+	}
+	if keyID == "" {
+		keyID = jwe.Thumbprint(key)
+	}
+
+	keys, err := s.webhookRepo.ListEncryptionKeys(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) >= maxWebhookEncryptionKeys {
+		return nil, fmt.Errorf("an endpoint can have at most %d encryption keys; remove a retired one first", maxWebhookEncryptionKeys)
+	}
+	for _, existing := range keys {
+		if existing.KeyID == keyID {
+			return nil, fmt.Errorf("encryption key %q is already registered", keyID)
+		}
+	}
+
+	encryptionKey := &database.WebhookEncryptionKey{
+		EndpointID: endpointID,
+		KeyID:      keyID,
+		PublicKey:  strings.TrimSpace(publicKey),
+	}
+	if err := s.webhookRepo.CreateEncryptionKey(ctx, encryptionKey); err != nil {
+		return nil, err
+	}
+
+	log.Printf("AUDIT webhook encryption key added: endpoint=%s kid=%s", endpointID, keyID)
+	return encryptionKey, nil
+}
+
+// ListEncryptionKeys lists an endpoint's encryption keys, newest, and so
+// current, first
+func (s *webhookService) ListEncryptionKeys(ctx context.Context, userID, endpointID string) ([]*database.WebhookEncryptionKey, error) {
+	if _, err := s.ownedEndpoint(ctx, userID, endpointID); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.ListEncryptionKeys(ctx, endpointID)
+}
+
+// DeleteEncryptionKey removes an endpoint's encryption key. Removing the
+// current key rolls back to the previous one; removing the last key sends
+// payloads unencrypted again.
+func (s *webhookService) DeleteEncryptionKey(ctx context.Context, userID, endpointID, keyID string) error {
+	if _, err := s.ownedEndpoint(ctx, userID, endpointID); err != nil {
+		return err
+	}
+
+	deleted, err := s.webhookRepo.DeleteEncryptionKey(ctx, endpointID, keyID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("encryption key not found")
+	}
+
+	log.Printf("AUDIT webhook encryption key removed: endpoint=%s kid=%s", endpointID, keyID)
+	return nil
+}
+
+// ownedEndpoint loads an endpoint, treating other users' endpoints as missing
+func (s *webhookService) ownedEndpoint(ctx context.Context, userID, endpointID string) (*database.WebhookEndpoint, error) {
+	endpoint, err := s.webhookRepo.GetEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == nil || endpoint.UserID != userID {
+		return nil, errors.New("webhook endpoint not found")
+	}
+	return endpoint, nil
+}
+
+// HandleEvent queues a delivery of the event to every subscribed endpoint
+func (s *webhookService) HandleEvent(ctx context.Context, event *events.Event) error {
+	if !s.eventTypes[string(event.Type)] {
+		return nil
+	}
+
+	endpoints, err := s.webhookRepo.ListActiveEndpoints(ctx)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	now := time.Now().Unix()
+	var deliveries []*database.WebhookDelivery
+	for _, endpoint := range endpoints {
+		if !subscribesTo(endpoint, string(event.Type)) {
+			continue
+		}
+		deliveries = append(deliveries, &database.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventID:       event.ID,
+			EventType:     string(event.Type),
+			Payload:       string(payload),
+			Status:        WebhookDeliveryPending,
+			NextAttemptAt: now,
+		})
+	}
+
+	return s.webhookRepo.CreateDeliveries(ctx, deliveries)
+}
+
+// subscribesTo reports whether an endpoint receives an event type
+func subscribesTo(endpoint *database.WebhookEndpoint, eventType string) bool {
+	for _, subscribed := range strings.Split(endpoint.EventTypes, ",") {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Start runs the delivery worker until ctx is cancelled. Any number of
+// instances can run it; due deliveries are claimed with row locks.
+func (s *webhookService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.deliverDue(ctx); err != nil {
+				log.Printf("Failed to deliver webhooks: %v", err)
+			}
+		}
+	}
+}
+
+// deliverDue attempts every delivery that is due
+func (s *webhookService) deliverDue(ctx context.Context) error {
+	now := time.Now()
+	// Hold claimed deliveries for longer than a batch can take to send
+	leaseUntil := now.Add(s.timeout * (webhookBatchSize + 1)).Unix()
+
+	deliveries, err := s.webhookRepo.ClaimDueDeliveries(ctx, now.Unix(), leaseUntil, webhookBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		endpoint, err := s.webhookRepo.GetEndpoint(ctx, delivery.EndpointID)
+		if err != nil {
+			return err
+		}
+
+		if endpoint == nil || !endpoint.Active {
+			delivery.Status = WebhookDeliveryFailed
+			delivery.LastError = "endpoint removed or disabled"
+		} else {
+			s.attempt(ctx, endpoint, delivery)
+		}
+
+		if err := s.webhookRepo.UpdateDelivery(ctx, delivery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attempt posts a delivery once and records the outcome, scheduling a retry
+// with exponential backoff until the attempts run out
+func (s *webhookService) attempt(ctx context.Context, endpoint *database.WebhookEndpoint, delivery *database.WebhookDelivery) {
+	delivery.Attempts++
+
+	statusCode, err := s.post(ctx, endpoint, delivery)
+	delivery.ResponseStatus = statusCode
+	if err == nil {
+		delivery.Status = WebhookDeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = time.Now().Unix()
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= s.maxAttempts {
+		delivery.Status = WebhookDeliveryFailed
+		log.Printf("Webhook delivery %s to %s failed after %d attempts: %v", delivery.ID, endpoint.URL, delivery.Attempts, err)
+		return
+	}
+
+	backoff := s.initialBackoff << (delivery.Attempts - 1)
+	if backoff <= 0 || backoff > s.maxBackoff {
+		backoff = s.maxBackoff
+	}
+	delivery.NextAttemptAt = time.Now().Add(backoff).Unix()
+}
+
+// post sends a signed delivery, encrypted first if the endpoint has an
+// encryption key. Any 2xx response counts as delivered.
+func (s *webhookService) post(ctx context.Context, endpoint *database.WebhookEndpoint, delivery *database.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	header, body, err := encodeWebhookPayload(endpoint.PayloadFormat, delivery.Payload)
+	if err != nil {
+		return 0, err
+	}
+	if body, err = s.encryptPayload(ctx, endpoint, header, body); err != nil {
+		return 0, err
+	}
+
+	// Endpoints registered before https was required are not delivered to
+	if err := validateWebhookURL(endpoint.URL); err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set(HeaderWebhookID, delivery.ID)
+	req.Header.Set(HeaderWebhookEvent, delivery.EventType)
+	req.Header.Set(HeaderWebhookTimestamp, timestamp)
+	req.Header.Set(HeaderWebhookSignature, SignWebhook(endpoint.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// encodeWebhookPayload converts a stored event payload to the headers and
+// body of the endpoint's payload format
+func encodeWebhookPayload(format, payload string) (http.Header, []byte, error) {
+	if format == "" || format == WebhookFormatPlatform {
+		header := make(http.Header)
+		header.Set("Content-Type", "application/json")
+		return header, []byte(payload), nil
+	}
+
+	var event events.Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal stored event: %v", err)
+	}
+
+	mode := events.CloudEventsStructured
+	if format == WebhookFormatCloudEventsBinary {
+		mode = events.CloudEventsBinary
+	}
+	return events.EncodeCloudEventHTTP(&event, mode)
+}
+
+// encryptPayload encrypts a payload body to the endpoint's current key as a
+// JWE, whose "cty" header keeps the body's content type. The body is returned
+// as is if the endpoint has no key; a key that cannot be used fails the
+// attempt rather than falling back to plaintext.
+func (s *webhookService) encryptPayload(ctx context.Context, endpoint *database.WebhookEndpoint, header http.Header, body []byte) ([]byte, error) {
+	encryptionKey, err := s.webhookRepo.GetCurrentEncryptionKey(ctx, endpoint.ID)
+	if err != nil {
+		return nil, err
+	}
+	if encryptionKey == nil {
+		return body, nil
+	}
+
+	key, _, err := jwe.ParsePublicKey(encryptionKey.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key %q: %v", encryptionKey.KeyID, err)
+	}
+	encrypted, err := jwe.Encrypt(key, encryptionKey.KeyID, header.Get("Content-Type"), body)
+	if err != nil {
+		return nil, err
+	}
+
+	header.Set("Content-Type", jwe.ContentType)
+	return []byte(encrypted), nil
+}
+
+// errWebhookAddress is returned for webhook connections to addresses that are
+// not public
+var errWebhookAddress = errors.New("webhook address is not public")
+
+// validateWebhookURL checks a webhook URL is an absolute https URL whose host
+// is not a loopback, private or link-local address. Hosts that resolve to one
+// are refused when connecting, by publicAddressOnly.
+func validateWebhookURL(endpointURL string) error {
+	parsed, err := url.Parse(endpointURL)
+	if err != nil || parsed.Host == "" || parsed.Scheme != "https" {
+		return errors.New("webhook URL must be an absolute https URL")
+	}
+	host := parsed.Hostname()
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return errWebhookAddress
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicAddress(ip) {
+		return errWebhookAddress
+	}
+	return nil
+}
+
+// newWebhookClient returns the client deliveries are posted with. It only
+// connects to public addresses, checked on every connection rather than when
+// the endpoint is registered, so a host re-resolved to an internal address
+// cannot be reached either. Redirects must stay on https.
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: publicAddressOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be dialled in place of the endpoint, bypassing the check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" {
+				return errors.New("webhook redirected to a non-https URL")
+			}
+			if len(via) >= 5 {
+				return errors.New("webhook redirected too many times")
+			}
+			return nil
+		},
+	}
+}
+
+// publicAddressOnly is a dialer Control hook refusing connections to
+// addresses that are not public. It sees the resolved address, just before
+// connecting.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicAddress(ip) {
+		return fmt.Errorf("%w: %s", errWebhookAddress, host)
+	}
+	return nil
+}
+
+// publicAddress reports whether an address is routable on the internet:
+// not loopback, private, link-local (which holds cloud metadata services
+// such as 169.254.169.254), unspecified or multicast
+func publicAddress(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsUnspecified() && !ip.IsMulticast()
+}
+
+// SignWebhook returns the signature header value for a webhook body
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// generateWebhookSecret returns a random signing secret
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,44 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"microservices-platform/pkg/discovery"
+)
+
+// TestWatchDoesNotSpinOnUnchangedAnswers checks a watch keeps its pace when
+// the registry answers every blocking query straight away without a change
+func TestWatchDoesNotSpinOnUnchangedAnswers(t *testing.T) {
+	var lookups int64
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&lookups, 1)
+		w.Header().Set("X-Consul-Index", "7")
+		w.Write([]byte(`[{"Node":{"Address":"10.0.0.7"},"Service":{"Port":8082}}]`))
+	}))
+	defer consul.Close()
+
+	resolver, err := discovery.New(discovery.Config{Provider: discovery.ProviderConsul, ConsulAddr: consul.URL})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	var updates int64
+	resolver.Watch(ctx, "order-service", func(addresses []string) {
+		atomic.AddInt64(&updates, 1)
+	})
+
+	if got := atomic.LoadInt64(&updates); got != 1 {
+		t.Fatalf("%d updates, want 1", got)
+	}
+	// One lookup at the start and one a second later
+	if got := atomic.LoadInt64(&lookups); got > 3 {
+		t.Fatalf("%d lookups in 1.5s, want at most 3", got)
+	}
+}
@@ -0,0 +1,218 @@
+package gateway
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"microservices-platform/pkg/proxy"
+	"microservices-platform/pkg/resilience"
+)
+
+const maxFailures = 3
+
+// newRouter proxies /orders to a service with a single instance at url,
+// behind a breaker opening after maxFailures failed requests
+func newRouter(t *testing.T, url string) (*gin.Engine, *resilience.CircuitBreaker) {
+	return newRouterWith(t, url, resilience.CircuitBreakerSettings{
+		MaxFailures:      maxFailures,
+		ResetTimeout:     time.Minute,
+		SuccessThreshold: 1,
+		Timeout:          5 * time.Second,
+	})
+}
+
+// newRouterWith is newRouter with the given breaker settings
+func newRouterWith(t *testing.T, url string, settings resilience.CircuitBreakerSettings) (*gin.Engine, *resilience.CircuitBreaker) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	breaker := resilience.NewCircuitBreaker(settings)
+	gateway := proxy.NewGateway()
+	err := gateway.RegisterService(&proxy.ServiceConfig{
+		Name:           "order-service",
+		Instances:      []string{url},
+		Timeout:        5 * time.Second,
+		CircuitBreaker: breaker,
+	})
+	if err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+
+	router := gin.New()
+	router.Any("/orders", gateway.ProxyHandler("order-service"))
+	return router, breaker
+}
+
+// closedAddress returns an address nothing listens on
+func closedAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+func send(router *gin.Engine, method string) int {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(method, "/orders", nil))
+	return rec.Code
+}
+
+// TestBreakerOpensWhenBackendRefusesConnections checks failed attempts count
+// against the breaker, both when they are written to the client and when
+// they were retried first
+func TestBreakerOpensWhenBackendRefusesConnections(t *testing.T) {
+	for _, method := range []string{http.MethodPost, http.MethodGet} {
+		t.Run(method, func(t *testing.T) {
+			router, breaker := newRouter(t, "http://"+closedAddress(t))
+
+			for i := 0; i < maxFailures; i++ {
+				if code := send(router, method); code != http.StatusBadGateway {
+					t.Fatalf("request %d: status %d, want %d", i+1, code, http.StatusBadGateway)
+				}
+			}
+			if !breaker.IsOpen() {
+				t.Fatalf("breaker is %v after %d refused requests, want open", breaker.GetStats()["state"], maxFailures)
+			}
+			if code := send(router, method); code != http.StatusServiceUnavailable {
+				t.Fatalf("request with open breaker: status %d, want %d", code, http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// TestBreakerOpensOnServerErrors checks server errors are passed to the
+// client and count against the breaker
+func TestBreakerOpensOnServerErrors(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+	router, breaker := newRouter(t, backend.URL)
+
+	for i := 0; i < maxFailures; i++ {
+		if code := send(router, http.MethodPost); code != http.StatusInternalServerError {
+			t.Fatalf("request %d: status %d, want %d", i+1, code, http.StatusInternalServerError)
+		}
+	}
+	if !breaker.IsOpen() {
+		t.Fatalf("breaker is %v after %d server errors, want open", breaker.GetStats()["state"], maxFailures)
+	}
+}
+
+// TestBreakerStaysClosedOnSuccess checks successful requests don't count
+// against the breaker
+func TestBreakerStaysClosedOnSuccess(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+	router, breaker := newRouter(t, backend.URL)
+
+	for i := 0; i < 2*maxFailures; i++ {
+		if code := send(router, http.MethodPost); code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want %d", i+1, code, http.StatusOK)
+		}
+	}
+	if breaker.IsOpen() {
+		t.Fatal("breaker opened on successful requests")
+	}
+}
+
+// TestBreakerWarmingUpSendsShedRequestsToFailover checks a breaker ramping
+// traffic back up after closing sends the requests it does not admit yet to
+// the secondary region, and that without one every request is served locally
+func TestBreakerWarmingUpSendsShedRequestsToFailover(t *testing.T) {
+	for _, withFailover := range []bool{true, false} {
+		name := "without failover"
+		if withFailover {
+			name = "with failover"
+		}
+		t.Run(name, func(t *testing.T) {
+			var healthy atomic.Bool
+			local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !healthy.Load() {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("X-Region", "local")
+			}))
+			defer local.Close()
+			secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Region", "secondary")
+			}))
+			defer secondary.Close()
+
+			breaker := resilience.NewCircuitBreaker(resilience.CircuitBreakerSettings{
+				MaxFailures:      maxFailures,
+				ResetTimeout:     50 * time.Millisecond,
+				SuccessThreshold: 1,
+				Timeout:          5 * time.Second,
+				SlowStartWindow:  time.Hour,
+			})
+			service := &proxy.ServiceConfig{
+				Name:           "order-service",
+				Instances:      []string{local.URL},
+				Timeout:        5 * time.Second,
+				CircuitBreaker: breaker,
+			}
+			if withFailover {
+				service.Failover = []string{secondary.URL}
+			}
+			gateway := proxy.NewGateway()
+			if err := gateway.RegisterService(service); err != nil {
+				t.Fatalf("RegisterService: %v", err)
+			}
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Any("/orders", gateway.ProxyHandler("order-service"))
+
+			for i := 0; i < maxFailures; i++ {
+				send(router, http.MethodPost)
+			}
+			if !breaker.IsOpen() {
+				t.Fatalf("breaker is %v after %d server errors, want open", breaker.GetStats()["state"], maxFailures)
+			}
+			healthy.Store(true)
+			time.Sleep(100 * time.Millisecond)
+			if withFailover {
+				// The secondary region serves while the breaker is open; a
+				// request after the reset timeout probes the local instance
+				for i := 0; i < 10 && breaker.GetStats()["state"] != "CLOSED"; i++ {
+					send(router, http.MethodPost)
+				}
+			} else if code := send(router, http.MethodPost); code != http.StatusOK {
+				t.Fatalf("half-open probe: status %d, want %d", code, http.StatusOK)
+			}
+			if state := breaker.GetStats()["state"]; state != "CLOSED" {
+				t.Fatalf("breaker is %v after the backend recovered, want closed", state)
+			}
+
+			// Right after closing the breaker admits a tenth of requests
+			served := map[string]int{}
+			for i := 0; i < 100; i++ {
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", nil))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("request %d: status %d, want %d", i+1, rec.Code, http.StatusOK)
+				}
+				served[rec.Header().Get("X-Region")]++
+			}
+			if withFailover && (served["local"] == 0 || served["secondary"] == 0) {
+				t.Fatalf("served %v, want requests in both regions", served)
+			}
+			if !withFailover && served["local"] != 100 {
+				t.Fatalf("served %v, want every request locally", served)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"microservices-platform/pkg/middleware"
+)
+
+// TestImpersonationTokensAreReadOnly checks an impersonation token can read
+// the user's resources but not change them, and that a token naming an
+// actor without the impersonation scope is refused outright
+func TestImpersonationTokensAreReadOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	orders := router.Group("/orders", middleware.AuthMiddleware(testSecret), middleware.ImpersonationMiddleware(testSecret))
+	orders.GET("", func(c *gin.Context) { c.Status(http.StatusOK) })
+	orders.POST("", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	cases := []struct {
+		name, method, scope string
+		want                int
+	}{
+		{"read", http.MethodGet, middleware.ScopeImpersonation, http.StatusOK},
+		{"write", http.MethodPost, middleware.ScopeImpersonation, http.StatusForbidden},
+		{"read without the scope", http.MethodGet, "", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := &middleware.Claims{
+				UserID:    "user-1",
+				Role:      "user",
+				Scope:     tc.scope,
+				SessionID: "session-1",
+				Actor:     &middleware.Actor{Subject: "admin-1"},
+				RegisteredClaims: jwt.RegisteredClaims{
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				},
+			}
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+			if err != nil {
+				t.Fatalf("sign token: %v", err)
+			}
+
+			req := httptest.NewRequest(tc.method, "/orders", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("status %d, want %d", rec.Code, tc.want)
+			}
+			if rec.Header().Get(middleware.HeaderImpersonatedBy) != "admin-1" {
+				t.Fatalf("missing %s header", middleware.HeaderImpersonatedBy)
+			}
+		})
+	}
+}
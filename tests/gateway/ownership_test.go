@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"microservices-platform/pkg/middleware"
+)
+
+const testSecret = "test-secret"
+
+// bearer returns an Authorization header for a user holding role
+func bearer(t *testing.T, userID, role string) string {
+	t.Helper()
+	claims := &middleware.Claims{
+		UserID: userID,
+		Role:   role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return "Bearer " + token
+}
+
+// TestUserUpdatesAreLimitedToSelfOrAdmin checks a user can only update their
+// own profile unless they are an admin
+func TestUserUpdatesAreLimitedToSelfOrAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.AuthMiddleware(testSecret))
+	router.PATCH("/users/:id", middleware.RequireSelfOrRole("id", "admin"), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	cases := []struct {
+		name, caller, role, target string
+		want                       int
+	}{
+		{"self", "user-1", "user", "user-1", http.StatusNoContent},
+		{"other user", "user-1", "user", "user-2", http.StatusForbidden},
+		{"admin", "admin-1", "admin", "user-2", http.StatusNoContent},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/users/"+tc.target, nil)
+			req.Header.Set("Authorization", bearer(t, tc.caller, tc.role))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("status %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"microservices-platform/pkg/middleware"
+)
+
+// revocations is a revocation.Lookup holding when each user was suspended
+type revocations map[string]time.Time
+
+func (r revocations) RevokedAt(ctx context.Context, userID string) (time.Time, error) {
+	return r[userID], nil
+}
+
+// TestSuspendedUsersTokensAreRejected checks a token issued before its user
+// was suspended is refused before it expires, while tokens issued after a
+// suspension was lifted and tokens of other users are accepted
+func TestSuspendedUsersTokensAreRejected(t *testing.T) {
+	suspendedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	lookup := revocations{"suspended": suspendedAt}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/orders", middleware.AuthMiddleware(testSecret), middleware.RejectRevokedTokens(lookup), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	cases := []struct {
+		name, userID string
+		issuedAt     time.Time
+		want         int
+	}{
+		{"issued before the suspension", "suspended", suspendedAt.Add(-time.Hour), http.StatusUnauthorized},
+		{"issued as the user was suspended", "suspended", suspendedAt, http.StatusUnauthorized},
+		{"issued after the suspension was lifted", "suspended", suspendedAt.Add(time.Second), http.StatusOK},
+		{"another user", "active", suspendedAt.Add(-time.Hour), http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := &middleware.Claims{
+				UserID: tc.userID,
+				Role:   "user",
+				RegisteredClaims: jwt.RegisteredClaims{
+					IssuedAt:  jwt.NewNumericDate(tc.issuedAt),
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+				},
+			}
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+			if err != nil {
+				t.Fatalf("sign token: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("status %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
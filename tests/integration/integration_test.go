@@ -80,7 +80,7 @@ func TestUserServiceIntegration(t *testing.T) {
 	createReq := &userpb.CreateUserRequest{
 		Email:     "test@example.com",
 		Username:  "testuser",
-		Password:  "password123",
+		Password:  "Integration-Pass-42",
 		FirstName: "Test",
 		LastName:  "User",
 	}
@@ -97,7 +97,7 @@ func TestUserServiceIntegration(t *testing.T) {
 	// Test user authentication
 	authReq := &userpb.AuthenticateUserRequest{
 		Email:    "test@example.com",
-		Password: "password123",
+		Password: "Integration-Pass-42",
 	}
 
 	authResp, err := ts.userClient.AuthenticateUser(ctx, authReq)
@@ -136,7 +136,7 @@ func TestOrderWorkflow(t *testing.T) {
 	userReq := &userpb.CreateUserRequest{
 		Email:     "ordertest@example.com",
 		Username:  "orderuser",
-		Password:  "password123",
+		Password:  "Integration-Pass-42",
 		FirstName: "Order",
 		LastName:  "User",
 	}
@@ -222,7 +222,7 @@ func TestConcurrentRequests(t *testing.T) {
 			req := &userpb.CreateUserRequest{
 				Email:     fmt.Sprintf("user%d@example.com", index),
 				Username:  fmt.Sprintf("user%d", index),
-				Password:  "password123",
+				Password:  "Integration-Pass-42",
 				FirstName: "Test",
 				LastName:  "User",
 			}
@@ -0,0 +1,47 @@
+package protocheck
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"microservices-platform/pkg/protocheck"
+)
+
+// protoDir is the proto source directory relative to this package
+const protoDir = "../../proto"
+
+// TestProtoLint fails when a proto definition breaks the API style rules
+func TestProtoLint(t *testing.T) {
+	files, err := protocheck.LoadDir(protoDir)
+	if err != nil {
+		t.Fatalf("Failed to load proto files: %v", err)
+	}
+	for _, finding := range protocheck.Lint(files) {
+		t.Error(finding)
+	}
+}
+
+// TestProtoBreaking fails when proto definitions break compatibility with the
+// git revision in PROTOCHECK_AGAINST, by default the last commit
+func TestProtoBreaking(t *testing.T) {
+	against := os.Getenv("PROTOCHECK_AGAINST")
+	if against == "" {
+		against = "HEAD"
+	}
+	if err := exec.Command("git", "rev-parse", "--verify", "--quiet", against).Run(); err != nil {
+		t.Skipf("Revision %s not available: %v", against, err)
+	}
+
+	files, err := protocheck.LoadDir(protoDir)
+	if err != nil {
+		t.Fatalf("Failed to load proto files: %v", err)
+	}
+	previous, err := protocheck.LoadGitRef(against, protoDir)
+	if err != nil {
+		t.Fatalf("Failed to load proto files at %s: %v", against, err)
+	}
+	for _, finding := range protocheck.Breaking(previous, files) {
+		t.Error(finding)
+	}
+}
@@ -0,0 +1,190 @@
+package scheduling
+
+import (
+	"testing"
+	"time"
+
+	"microservices-platform/pkg/i18n"
+)
+
+// Reminder hours as order-service defaults them
+const (
+	reminderStartHour = 9
+	reminderEndHour   = 21
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s unavailable: %v", name, err)
+	}
+	return loc
+}
+
+// TestDailyDigestAcrossDST checks a daily 08:00 digest stays at 08:00 local
+// time on the days clocks change, 23 and 25 hours after the one before
+func TestDailyDigestAcrossDST(t *testing.T) {
+	tests := []struct {
+		name     string
+		zone     string
+		from     time.Time
+		wantGaps []time.Duration
+	}{
+		{
+			name:     "US spring forward",
+			zone:     "America/New_York",
+			from:     time.Date(2024, time.March, 8, 8, 0, 0, 0, time.UTC),
+			wantGaps: []time.Duration{24 * time.Hour, 23 * time.Hour, 24 * time.Hour},
+		},
+		{
+			name:     "US fall back",
+			zone:     "America/New_York",
+			from:     time.Date(2024, time.November, 1, 8, 0, 0, 0, time.UTC),
+			wantGaps: []time.Duration{24 * time.Hour, 25 * time.Hour, 24 * time.Hour},
+		},
+		{
+			name:     "EU spring forward",
+			zone:     "Europe/Berlin",
+			from:     time.Date(2024, time.March, 30, 0, 0, 0, 0, time.UTC),
+			wantGaps: []time.Duration{23 * time.Hour, 24 * time.Hour},
+		},
+		{
+			name:     "EU fall back",
+			zone:     "Europe/Berlin",
+			from:     time.Date(2024, time.October, 26, 0, 0, 0, 0, time.UTC),
+			wantGaps: []time.Duration{25 * time.Hour, 24 * time.Hour},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := mustLoad(t, tt.zone)
+			due := i18n.NextLocalTime(tt.from, loc, 8, 0)
+			for _, gap := range tt.wantGaps {
+				next := i18n.NextLocalTime(due, loc, 8, 0)
+				if got := next.Sub(due); got != gap {
+					t.Errorf("digest after %s due %s later, want %s", due, got, gap)
+				}
+				if local := next.In(loc); local.Hour() != 8 || local.Minute() != 0 {
+					t.Errorf("digest due at %s local time, want 08:00", local.Format("15:04"))
+				}
+				due = next
+			}
+		})
+	}
+}
+
+// TestDigestInSkippedHour checks a digest set for a wall clock time the
+// spring change skips still goes out that day, as late as the change pushes it
+func TestDigestInSkippedHour(t *testing.T) {
+	loc := mustLoad(t, "America/New_York")
+	from := time.Date(2024, time.March, 10, 0, 0, 0, 0, loc)
+
+	due := i18n.NextLocalTime(from, loc, 2, 30)
+	want := time.Date(2024, time.March, 10, 3, 30, 0, 0, loc)
+	if !due.Equal(want) {
+		t.Errorf("digest due %s, want %s", due, want)
+	}
+	if next := i18n.NextLocalTime(due, loc, 2, 30); next.In(loc).Day() != 11 || next.In(loc).Hour() != 2 {
+		t.Errorf("next digest due %s, want March 11 02:30", next.In(loc))
+	}
+}
+
+// TestReminderDeferredToLocalMorning checks reminders falling outside the
+// reminder hours of the user's time zone wait for the next morning there,
+// including on the nights clocks change
+func TestReminderDeferredToLocalMorning(t *testing.T) {
+	tests := []struct {
+		name      string
+		zone      string
+		abandoned time.Time
+		wantSend  bool
+		wantAt    time.Time
+	}{
+		{
+			name:      "afternoon is sent",
+			zone:      "Europe/Berlin",
+			abandoned: time.Date(2024, time.June, 3, 13, 0, 0, 0, time.UTC),
+			wantSend:  true,
+		},
+		{
+			name:      "late evening waits for morning",
+			zone:      "Europe/Berlin",
+			abandoned: time.Date(2024, time.June, 3, 21, 30, 0, 0, time.UTC),
+			wantAt:    time.Date(2024, time.June, 4, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "night of EU spring forward",
+			zone:      "Europe/Berlin",
+			abandoned: time.Date(2024, time.March, 30, 23, 0, 0, 0, time.UTC),
+			wantAt:    time.Date(2024, time.March, 31, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "night of EU fall back",
+			zone:      "Europe/Berlin",
+			abandoned: time.Date(2024, time.October, 26, 22, 0, 0, 0, time.UTC),
+			wantAt:    time.Date(2024, time.October, 27, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "night of US spring forward",
+			zone:      "America/Los_Angeles",
+			abandoned: time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC),
+			wantAt:    time.Date(2024, time.March, 10, 16, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "night of US fall back",
+			zone:      "America/Los_Angeles",
+			abandoned: time.Date(2024, time.November, 3, 13, 0, 0, 0, time.UTC),
+			wantAt:    time.Date(2024, time.November, 3, 17, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := mustLoad(t, tt.zone)
+			send := i18n.WithinLocalHours(tt.abandoned, loc, reminderStartHour, reminderEndHour)
+			if send != tt.wantSend {
+				t.Fatalf("reminder sent = %v, want %v", send, tt.wantSend)
+			}
+			if send {
+				return
+			}
+			if at := i18n.NextLocalTime(tt.abandoned, loc, reminderStartHour, 0); !at.Equal(tt.wantAt) {
+				t.Errorf("reminder deferred to %s, want %s", at.UTC(), tt.wantAt)
+			}
+		})
+	}
+}
+
+// TestUnknownTimeZoneIsUTC checks a stale time zone preference schedules and
+// formats in UTC instead of failing
+func TestUnknownTimeZoneIsUTC(t *testing.T) {
+	if i18n.ValidTimeZone("Mars/Olympus_Mons") {
+		t.Fatal("unknown time zone accepted")
+	}
+	if loc := i18n.Location("Mars/Olympus_Mons"); loc != time.UTC {
+		t.Errorf("unknown time zone resolved to %s, want UTC", loc)
+	}
+}
+
+// TestFormatTimeInUserZone checks notification dates are written in the
+// user's language and the offset in effect on that date
+func TestFormatTimeInUserZone(t *testing.T) {
+	mustLoad(t, "Europe/Berlin")
+	tests := []struct {
+		at       time.Time
+		language string
+		want     string
+	}{
+		{time.Date(2024, time.January, 15, 17, 5, 0, 0, time.UTC), "de-DE", "15.01.2024 18:05 CET"},
+		{time.Date(2024, time.July, 15, 17, 5, 0, 0, time.UTC), "de-DE", "15.07.2024 19:05 CEST"},
+		{time.Date(2024, time.July, 15, 17, 5, 0, 0, time.UTC), "en-GB", "Jul 15, 2024 7:05 PM CEST"},
+		{time.Date(2024, time.July, 15, 17, 5, 0, 0, time.UTC), "pt-BR", "Jul 15, 2024 7:05 PM CEST"},
+	}
+	for _, tt := range tests {
+		if got := i18n.FormatTime(tt.at, tt.language, "Europe/Berlin"); got != tt.want {
+			t.Errorf("FormatTime(%s, %s) = %q, want %q", tt.at, tt.language, got, tt.want)
+		}
+	}
+}
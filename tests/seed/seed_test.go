@@ -0,0 +1,94 @@
+package seed
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"microservices-platform/pkg/seed"
+)
+
+// TestSameSeedSameData checks environments seeded alike hold the same data
+func TestSameSeedSameData(t *testing.T) {
+	cfg := seed.DefaultConfig()
+	if !reflect.DeepEqual(seed.Generate(cfg), seed.Generate(cfg)) {
+		t.Fatal("the same seed generated different data")
+	}
+
+	other := cfg
+	other.Seed = 2
+	if reflect.DeepEqual(seed.Generate(cfg).Users, seed.Generate(other).Users) {
+		t.Fatal("different seeds generated the same users")
+	}
+}
+
+// TestUniqueUsersAndProducts checks users and products don't collide, so
+// none is skipped as a duplicate
+func TestUniqueUsersAndProducts(t *testing.T) {
+	ds := seed.Generate(seed.DefaultConfig())
+
+	emails := make(map[string]bool)
+	for _, user := range ds.Users {
+		if emails[user.Email] {
+			t.Errorf("email %s generated twice", user.Email)
+		}
+		emails[user.Email] = true
+	}
+
+	skus := make(map[string]bool)
+	for _, product := range ds.Products {
+		if skus[product.SKU] {
+			t.Errorf("SKU %s generated twice", product.SKU)
+		}
+		skus[product.SKU] = true
+		if len(product.Images) == 0 {
+			t.Errorf("product %s has no images", product.SKU)
+		}
+		for _, image := range product.Images {
+			if !strings.HasPrefix(image, "https://") {
+				t.Errorf("product %s has image %q, want an https URL", product.SKU, image)
+			}
+		}
+	}
+}
+
+// TestOrdersCoverEveryState checks a default environment has orders in every
+// state, each one possible to reach
+func TestOrdersCoverEveryState(t *testing.T) {
+	ds := seed.Generate(seed.DefaultConfig())
+
+	counts := make(map[string]int)
+	for i, order := range ds.Orders {
+		counts[order.State]++
+
+		if len(order.Items) == 0 {
+			t.Errorf("order %d has no items", i+1)
+		}
+		for _, item := range order.Items {
+			if ds.Products[item.Product].InventoryQuantity == 0 {
+				t.Errorf("order %d has out of stock product %s", i+1, ds.Products[item.Product].SKU)
+			}
+		}
+		if paid := order.CardNumber != ""; paid != (order.State != seed.StatePending) {
+			t.Errorf("order %d is %s with card %q", i+1, order.State, order.CardNumber)
+		}
+		if order.State == seed.StatePartiallyShipped && len(order.Items) == 1 && order.Items[0].Quantity < 2 {
+			t.Errorf("order %d is partially shipped with nothing left to ship", i+1)
+		}
+	}
+
+	for _, state := range seed.States() {
+		if counts[state] == 0 {
+			t.Errorf("no order is %s", state)
+		}
+	}
+}
+
+// TestEmptyCatalog checks orders are not generated without products to order
+func TestEmptyCatalog(t *testing.T) {
+	cfg := seed.DefaultConfig()
+	cfg.Products = 0
+	if ds := seed.Generate(cfg); len(ds.Orders) != 0 {
+		t.Fatalf("generated %d orders without products", len(ds.Orders))
+	}
+}
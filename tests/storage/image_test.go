@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"microservices-platform/pkg/storage"
+)
+
+// TestDecodeImage checks uploads are decoded only when they are of their
+// declared type and within the pixel cap, which is checked from the header
+// before the pixels are decoded
+func TestDecodeImage(t *testing.T) {
+	var small bytes.Buffer
+	if err := png.Encode(&small, image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+
+	cases := []struct {
+		name, contentType string
+		data              []byte
+		wantErr           string
+	}{
+		{"png", "image/png", small.Bytes(), ""},
+		{"png declared as jpeg", "image/jpeg", small.Bytes(), "not image/jpeg"},
+		{"huge dimensions", "image/png", pngHeader(100000, 100000), "exceeds maximum"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := storage.DecodeImage(tc.data, tc.contentType)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("DecodeImage: %v", err)
+				}
+				if img.Bounds().Dx() != 8 {
+					t.Fatalf("decoded width %d, want 8", img.Bounds().Dx())
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error %v, want one containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// pngHeader returns the signature and header chunk of an 8-bit RGBA PNG of
+// the given dimensions, without any pixel data
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8], ihdr[9] = 8, 6 // bit depth, truecolor with alpha
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}